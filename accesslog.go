@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// AccessLogEntry is one line of the access log.
+type AccessLogEntry struct {
+	Time       time.Time `json:"time"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Status     int       `json:"status"`
+	Bytes      int       `json:"bytes"`
+	DurationMS float64   `json:"durationMs"`
+	RemoteAddr string    `json:"remoteAddr"`
+	// RequestBody and ResponseBody are captured only while the log level
+	// is debug.
+	RequestBody  *CapturedBody `json:"requestBody,omitempty"`
+	ResponseBody *CapturedBody `json:"responseBody,omitempty"`
+}
+
+// CapturedBody is the start of a request or response body.
+type CapturedBody struct {
+	Text string `json:"text"`
+	// Size is the number of bytes that passed through, which may exceed
+	// len(Text).
+	Size      int64 `json:"size"`
+	Truncated bool  `json:"truncated,omitempty"`
+}
+
+// capBuffer keeps the first limit bytes written to it and counts the rest.
+// Writes never fail, so teeing into it cannot disturb the stream it copies.
+type capBuffer struct {
+	limit int
+	buf   []byte
+	size  int64
+}
+
+func (c *capBuffer) Write(p []byte) (int, error) {
+	c.size += int64(len(p))
+	if room := c.limit - len(c.buf); room > 0 {
+		c.buf = append(c.buf, p[:min(room, len(p))]...)
+	}
+	return len(p), nil
+}
+
+func (c *capBuffer) body() *CapturedBody {
+	return &CapturedBody{Text: string(c.buf), Size: c.size, Truncated: c.size > int64(len(c.buf))}
+}
+
+// newAccessLog returns middleware writing an AccessLogEntry as a JSON line
+// to out for every request. While the log level is debug, the first
+// bodyLimit bytes of each request and response body are captured too. The
+// bodies are teed as the handler reads and writes them, never buffered
+// ahead, so streaming and flushing behave as without the log. Emails and
+// secrets in the path and bodies are masked; see redactor.
+func (srv *Server) newAccessLog(out io.Writer, bodyLimit int) func(http.Handler) http.Handler {
+	var mu sync.Mutex
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			capture := bodyLimit > 0 && srv.logEnabled("debug")
+			var reqBody, respBody *capBuffer
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			if capture {
+				reqBody, respBody = &capBuffer{limit: bodyLimit}, &capBuffer{limit: bodyLimit}
+				if r.Body != nil && r.Body != http.NoBody {
+					r.Body = struct {
+						io.Reader
+						io.Closer
+					}{io.TeeReader(r.Body, reqBody), r.Body}
+				}
+				ww.Tee(respBody)
+			}
+
+			start := time.Now()
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			entry := AccessLogEntry{
+				Time:       start.UTC(),
+				Method:     r.Method,
+				Path:       logRedactor().requestURI(r),
+				Status:     status,
+				Bytes:      ww.BytesWritten(),
+				DurationMS: float64(time.Since(start)) / float64(time.Millisecond),
+				RemoteAddr: r.RemoteAddr,
+			}
+			if capture {
+				entry.RequestBody, entry.ResponseBody = reqBody.body(), respBody.body()
+				entry.RequestBody.Text = logRedactor().body(entry.RequestBody.Text)
+				entry.ResponseBody.Text = logRedactor().body(entry.ResponseBody.Text)
+			}
+			line, err := json.Marshal(entry)
+			if err != nil {
+				log.Printf("access log: %v", err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if _, err := out.Write(append(line, '\n')); err != nil {
+				log.Printf("access log: %v", err)
+			}
+		})
+	}
+}
+
+// rotatingFile is an append-only log file that is moved aside and started
+// afresh once it reaches maxSize bytes or maxAge. Zero disables either
+// limit. Rotated files are named path.<UTC timestamp>.
+type rotatingFile struct {
+	path    string
+	maxSize int64
+	maxAge  time.Duration
+	now     func() time.Time
+
+	mu     sync.Mutex
+	f      *os.File
+	size   int64
+	opened time.Time
+}
+
+func openRotatingFile(path string, maxSize int64, maxAge time.Duration) (*rotatingFile, error) {
+	rf := &rotatingFile{path: path, maxSize: maxSize, maxAge: maxAge, now: time.Now}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+// open opens path for appending. An existing file's age counts from now.
+func (rf *rotatingFile) open() error {
+	f, err := os.OpenFile(rf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	rf.f, rf.size, rf.opened = f, info.Size(), rf.now()
+	return nil
+}
+
+// Write appends p, rotating first if p would take the file past maxSize or
+// the file has outlived maxAge. A write is never split across files.
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.size > 0 && ((rf.maxSize > 0 && rf.size+int64(len(p)) > rf.maxSize) ||
+		(rf.maxAge > 0 && rf.now().Sub(rf.opened) >= rf.maxAge)) {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := rf.f.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+func (rf *rotatingFile) rotate() error {
+	_, err := rf.rotateTo()
+	return err
+}
+
+// rotateTo rotates the file, returning the rotated file's name.
+func (rf *rotatingFile) rotateTo() (string, error) {
+	if err := rf.f.Close(); err != nil {
+		return "", err
+	}
+	rotated := fmt.Sprintf("%s.%s", rf.path, rf.now().UTC().Format("20060102T150405.000000000"))
+	if err := os.Rename(rf.path, rotated); err != nil {
+		return "", err
+	}
+	return rotated, rf.open()
+}
+
+// rotateNow rotates the file unless it is empty, returning the rotated
+// file's name, or "" if it was empty.
+func (rf *rotatingFile) rotateNow() (string, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.size == 0 {
+		return "", nil
+	}
+	return rf.rotateTo()
+}
+
+func (rf *rotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	return rf.f.Close()
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// accessLogEntries decodes the JSON lines in buf.
+func accessLogEntries(t *testing.T, buf *bytes.Buffer) []AccessLogEntry {
+	t.Helper()
+	var entries []AccessLogEntry
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var e AccessLogEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// ========== Access Log Middleware Tests ==========
+
+func TestAccessLog_Entry(t *testing.T) {
+	clearRuntimeEnv(t)
+	setupRouter()
+	useLiveConfig(t, "")
+	var buf bytes.Buffer
+	router := server.newRouter(server.newAccessLog(&buf, 1024))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/1?fields=name", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	entries := accessLogEntries(t, &buf)
+	require.Len(t, entries, 1)
+	e := entries[0]
+	assert.Equal(t, http.MethodGet, e.Method)
+	assert.Equal(t, "/users/1?fields=name", e.Path)
+	assert.Equal(t, http.StatusOK, e.Status)
+	assert.Equal(t, w.Body.Len(), e.Bytes)
+	assert.Equal(t, "192.0.2.1:1234", e.RemoteAddr)
+	assert.Nil(t, e.RequestBody, "bodies are only captured at debug")
+	assert.Nil(t, e.ResponseBody)
+}
+
+func TestAccessLog_CapturesBodiesAtDebug(t *testing.T) {
+	clearRuntimeEnv(t)
+	setupRouter()
+	useLiveConfig(t, writeConfigFile(t, `{"logLevel":"debug"}`))
+	var buf bytes.Buffer
+	router := server.newRouter(server.newAccessLog(&buf, 10))
+
+	body := `{"name":"Carol","email":"carol@example.com"}`
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	entries := accessLogEntries(t, &buf)
+	require.Len(t, entries, 1)
+	assert.Equal(t, &CapturedBody{Text: body[:10], Size: int64(len(body)), Truncated: true}, entries[0].RequestBody)
+	assert.Equal(t, &CapturedBody{Text: w.Body.String()[:10], Size: int64(w.Body.Len()), Truncated: true}, entries[0].ResponseBody)
+}
+
+func TestAccessLog_Redacts(t *testing.T) {
+	clearRuntimeEnv(t)
+	setupRouter()
+	useLiveConfig(t, writeConfigFile(t, `{"logLevel":"debug"}`))
+	var buf bytes.Buffer
+	router := server.newRouter(server.newAccessLog(&buf, 1024))
+
+	body := `{"name":"Carol","email":"carol@example.com"}`
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/verify?token=s3cr3t-t0ken", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/invites/s3cr3t-t0ken", nil))
+
+	entries := accessLogEntries(t, &buf)
+	require.Len(t, entries, 3)
+	assert.Equal(t, `{"email":"c***@example.com","name":"Carol"}`, entries[0].RequestBody.Text)
+	assert.Contains(t, entries[0].ResponseBody.Text, `"email":"c***@example.com"`)
+	assert.Equal(t, int64(len(body)), entries[0].RequestBody.Size, "sizes are of the bodies sent")
+	assert.Equal(t, "/verify?token=%2A%2A%2A", entries[1].Path)
+	assert.Equal(t, "/invites/%2A%2A%2A", entries[2].Path)
+	assert.NotContains(t, buf.String(), "carol@example.com")
+	assert.NotContains(t, buf.String(), "s3cr3t-t0ken")
+}
+
+func TestAccessLog_KeepsFlusher(t *testing.T) {
+	clearRuntimeEnv(t)
+	setupRouter()
+	useLiveConfig(t, writeConfigFile(t, `{"logLevel":"debug"}`))
+	var buf bytes.Buffer
+	handler := server.newAccessLog(&buf, 4)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, ok := w.(http.Flusher)
+		require.True(t, ok, "streaming handlers can still flush")
+		w.Write([]byte("chunk"))
+		f.Flush()
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stream", nil))
+
+	assert.True(t, w.Flushed)
+	assert.Equal(t, "chunk", w.Body.String())
+	entries := accessLogEntries(t, &buf)
+	require.Len(t, entries, 1)
+	assert.Equal(t, &CapturedBody{Text: "chun", Size: 5, Truncated: true}, entries[0].ResponseBody)
+	assert.Equal(t, &CapturedBody{}, entries[0].RequestBody, "an unread body captures nothing")
+}
+
+// ========== Rotating File Tests ==========
+
+func TestRotatingFile_RotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	rf, err := openRotatingFile(path, 10, 0)
+	require.NoError(t, err)
+	defer rf.Close()
+
+	for _, line := range []string{"aaaa\n", "bbbb\n", "cccc\n"} {
+		_, err := rf.Write([]byte(line))
+		require.NoError(t, err)
+	}
+
+	current, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "cccc\n", string(current))
+
+	rotated, err := filepath.Glob(path + ".*")
+	require.NoError(t, err)
+	require.Len(t, rotated, 1)
+	old, err := os.ReadFile(rotated[0])
+	require.NoError(t, err)
+	assert.Equal(t, "aaaa\nbbbb\n", string(old))
+}
+
+func TestRotatingFile_RotatesByAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rf := &rotatingFile{path: path, maxAge: time.Hour, now: func() time.Time { return clock }}
+	require.NoError(t, rf.open())
+	defer rf.Close()
+
+	_, err := rf.Write([]byte("first\n"))
+	require.NoError(t, err)
+	clock = clock.Add(time.Hour)
+	_, err = rf.Write([]byte("second\n"))
+	require.NoError(t, err)
+
+	current, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "second\n", string(current))
+	old, err := os.ReadFile(path + ".20240101T010000.000000000")
+	require.NoError(t, err)
+	assert.Equal(t, "first\n", string(old))
+}
+
+func TestRotatingFile_AppendsToExisting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	require.NoError(t, os.WriteFile(path, []byte("12345678\n"), 0o644))
+
+	rf, err := openRotatingFile(path, 10, 0)
+	require.NoError(t, err)
+	defer rf.Close()
+	_, err = rf.Write([]byte("more\n"))
+	require.NoError(t, err)
+
+	current, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "more\n", string(current), "the existing size counts toward the limit")
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// adminUIPage is the admin UI, a single page that calls the API and GET
+// /admin/events from the browser. It holds no data of its own, so it is
+// served without the admin token; the page asks for one.
+//
+//go:embed adminui/index.html
+var adminUIPage []byte
+
+// eventKeepalive is how often an idle event stream sends a comment, so
+// that proxies do not close it.
+const eventKeepalive = 15 * time.Second
+
+// eventBuffer is how many events a stream may fall behind before further
+// ones are dropped for it.
+const eventBuffer = 64
+
+func (srv *Server) adminUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(adminUIPage)
+}
+
+// streamEvents sends every event published on the bus as server-sent
+// events until the client goes away. A client too slow to keep up misses
+// events rather than holding up the publisher.
+func (srv *Server) streamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondError(w, r, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+	ch := make(chan Event, eventBuffer)
+	unsubscribe := srv.events.Subscribe(func(e Event) {
+		select {
+		case ch <- e:
+		default:
+		}
+	})
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("retry: 3000\n\n"))
+	flusher.Flush()
+
+	keepalive := time.NewTicker(eventKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			w.Write([]byte(": keepalive\n\n"))
+		case e := <-ch:
+			data, err := json.Marshal(renamed(e, srv.naming))
+			if err != nil {
+				srv.logAt("warn", "event stream: %v", err)
+				continue
+			}
+			w.Write([]byte("event: " + string(e.Type) + "\ndata: " + string(data) + "\n\n"))
+		}
+		flusher.Flush()
+	}
+}
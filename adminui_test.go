@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openEventStream GETs /admin/events from srv and returns its lines,
+// once the stream's preamble has been read and it is subscribed.
+func openEventStream(t *testing.T, srv *httptest.Server) *bufio.Scanner {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/admin/events", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	resp, err := srv.Client().Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	lines := bufio.NewScanner(resp.Body)
+	require.True(t, lines.Scan())
+	assert.Equal(t, "retry: 3000", lines.Text())
+	require.True(t, lines.Scan())
+	return lines
+}
+
+// nextEvent reads the next event off lines, skipping comments.
+func nextEvent(t *testing.T, lines *bufio.Scanner) (string, Event) {
+	t.Helper()
+	var name string
+	var e Event
+	for lines.Scan() {
+		switch line := lines.Text(); {
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e))
+		case line == "" && name != "":
+			return name, e
+		}
+	}
+	t.Fatalf("stream ended: %v", lines.Err())
+	return "", Event{}
+}
+
+// ========== Admin UI Tests ==========
+
+func TestAdminUI_ServesPage(t *testing.T) {
+	router := setupRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/ui", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code, "the page needs no token")
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "/admin/events")
+}
+
+func TestAdminUI_FollowsIPRules(t *testing.T) {
+	router := setupRouter()
+	useAdminIPRules(t, IPRules{Deny: []string{"192.0.2.1"}})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/ui", nil))
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+// ========== Event Stream Tests ==========
+
+func TestStreamEvents_RequiresAdmin(t *testing.T) {
+	router := setupAdminRouter(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/events", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestStreamEvents_SendsEvents(t *testing.T) {
+	srv := httptest.NewServer(setupAdminRouter(t))
+	t.Cleanup(srv.Close)
+	lines := openEventStream(t, srv)
+
+	user, err := server.Store.CreateUser(User{Name: "Streamed"})
+	require.NoError(t, err)
+	require.NoError(t, server.Store.DeleteUser(user.ID, time.Time{}))
+
+	name, e := nextEvent(t, lines)
+	assert.Equal(t, "created", name)
+	assert.Equal(t, EventCreated, e.Type)
+	assert.Equal(t, "users", e.Resource)
+	assert.Equal(t, user.ID, e.ID)
+
+	name, e = nextEvent(t, lines)
+	assert.Equal(t, "deleted", name)
+	assert.Equal(t, user.ID, e.ID)
+}
+
+func TestStreamEvents_NotBuffered(t *testing.T) {
+	router := setupAdminRouter(t)
+	key := SigningKey{ID: "k1", Secret: []byte("secret")}
+	srv := httptest.NewServer(signResponses(key)(router))
+	t.Cleanup(srv.Close)
+	lines := openEventStream(t, srv)
+
+	_, err := server.Store.CreateUser(User{Name: "Streamed"})
+	require.NoError(t, err)
+
+	name, _ := nextEvent(t, lines)
+	assert.Equal(t, "created", name, "the stream is passed through while the handler runs")
+}
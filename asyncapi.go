@@ -0,0 +1,223 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// asyncAPIVersion is the AsyncAPI version /asyncapi.json is in.
+const asyncAPIVersion = "2.6.0"
+
+// openAPISchemaFormat marks the payload schemas, which are openapi.yaml's.
+const openAPISchemaFormat = "application/vnd.oai.openapi;version=3.0.0"
+
+// asyncAPIDoc is an AsyncAPI document: the asynchronous side of the API,
+// as openapi.yaml is the request/response side. Only the members the
+// server uses are declared.
+type asyncAPIDoc struct {
+	AsyncAPI           string                     `json:"asyncapi"`
+	Info               asyncAPIInfo               `json:"info"`
+	DefaultContentType string                     `json:"defaultContentType"`
+	Servers            map[string]asyncAPIServer  `json:"servers"`
+	Channels           map[string]asyncAPIChannel `json:"channels"`
+	Components         asyncAPIComponents         `json:"components"`
+}
+
+type asyncAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type asyncAPIServer struct {
+	URL         string                            `json:"url"`
+	Protocol    string                            `json:"protocol"`
+	Description string                            `json:"description,omitempty"`
+	Variables   map[string]asyncAPIServerVariable `json:"variables,omitempty"`
+	Security    []map[string][]string             `json:"security,omitempty"`
+}
+
+type asyncAPIServerVariable struct {
+	Default     string `json:"default,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+type asyncAPIChannel struct {
+	Description string             `json:"description,omitempty"`
+	Servers     []string           `json:"servers,omitempty"`
+	Subscribe   *asyncAPIOperation `json:"subscribe,omitempty"`
+}
+
+// asyncAPIOperation is an operation of a channel. In AsyncAPI 2, a
+// subscribe operation is one clients subscribe to: the server sends.
+type asyncAPIOperation struct {
+	OperationID string                    `json:"operationId"`
+	Summary     string                    `json:"summary"`
+	Description string                    `json:"description,omitempty"`
+	Bindings    map[string]map[string]any `json:"bindings,omitempty"`
+	Message     asyncAPIMessageRefs       `json:"message"`
+}
+
+// asyncAPIMessageRefs lists the messages an operation may carry.
+type asyncAPIMessageRefs struct {
+	OneOf []asyncAPIRef `json:"oneOf"`
+}
+
+type asyncAPIRef struct {
+	Ref string `json:"$ref"`
+}
+
+type asyncAPIMessage struct {
+	Name         string                    `json:"name"`
+	Title        string                    `json:"title"`
+	Summary      string                    `json:"summary"`
+	ContentType  string                    `json:"contentType"`
+	SchemaFormat string                    `json:"schemaFormat"`
+	Headers      *openapi3.Schema          `json:"headers,omitempty"`
+	Payload      asyncAPIRef               `json:"payload"`
+	Bindings     map[string]map[string]any `json:"bindings,omitempty"`
+	Examples     []asyncAPIMessageExample  `json:"examples,omitempty"`
+}
+
+type asyncAPIMessageExample struct {
+	Name    string            `json:"name"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Payload Event             `json:"payload"`
+}
+
+type asyncAPIComponents struct {
+	Schemas         map[string]*openapi3.SchemaRef    `json:"schemas"`
+	Messages        map[string]asyncAPIMessage        `json:"messages"`
+	SecuritySchemes map[string]asyncAPISecurityScheme `json:"securitySchemes"`
+}
+
+type asyncAPISecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme"`
+	Description string `json:"description,omitempty"`
+}
+
+// newAsyncAPIDoc describes the entity event channels: the server-sent
+// events of GET /admin/events on the ops listener at opsAddr, and the
+// deliveries POSTed to registered webhooks. Both carry the Event schema of
+// doc; rename is the field naming doc was written for, and the examples are
+// written with it too.
+func newAsyncAPIDoc(doc *openapi3.T, opsAddr string, rename func(string) string) asyncAPIDoc {
+	if strings.HasPrefix(opsAddr, ":") {
+		opsAddr = "localhost" + opsAddr
+	}
+	entityMessages := []asyncAPIRef{
+		{Ref: "#/components/messages/entityCreated"},
+		{Ref: "#/components/messages/entityUpdated"},
+		{Ref: "#/components/messages/entityDeleted"},
+	}
+	eventMessage := func(t EventType, summary string, example Event) asyncAPIMessage {
+		return asyncAPIMessage{
+			Name:         string(t),
+			Title:        "Entity " + string(t),
+			Summary:      summary + " The SSE event field is the message name.",
+			ContentType:  "application/json",
+			SchemaFormat: openAPISchemaFormat,
+			Payload:      asyncAPIRef{Ref: "#/components/schemas/Event"},
+			Examples:     []asyncAPIMessageExample{{Name: eventName(example), Payload: example}},
+		}
+	}
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	user := renamed(User{ID: 3, Name: "Carol", Email: "carol@example.com", Version: 1, CreatedAt: at, UpdatedAt: at}, rename)
+	webhookHeaders := openapi3.NewObjectSchema().
+		WithProperty("X-Webhook-Event", openapi3.NewStringSchema().WithPattern(`^[a-z/]+\.(created|updated|deleted)$`)).
+		WithProperty("X-Webhook-Delivery", openapi3.NewStringSchema().WithPattern(`^[0-9]+$`))
+	webhookHeaders.Required = []string{"X-Webhook-Event", "X-Webhook-Delivery"}
+	webhookHeaders.Properties["X-Webhook-Event"].Value.Description = `The event's "<resource>.<type>" name, as webhooks filter on`
+	webhookHeaders.Properties["X-Webhook-Delivery"].Value.Description = "The delivery's ID; retries of a delivery repeat it"
+	created := Event{Type: EventCreated, Resource: "users", ID: 3, Data: user}
+
+	return asyncAPIDoc{
+		AsyncAPI: asyncAPIVersion,
+		Info: asyncAPIInfo{
+			Title:       doc.Info.Title + " events",
+			Version:     doc.Info.Version,
+			Description: "Entity lifecycle events, published by the store as users, posts, todos, albums, photos and ingest events are created, updated and deleted.",
+		},
+		DefaultContentType: "application/json",
+		Servers: map[string]asyncAPIServer{
+			"ops": {
+				URL:         "{host}",
+				Protocol:    "http",
+				Description: "The ops listener",
+				Variables:   map[string]asyncAPIServerVariable{"host": {Default: opsAddr}},
+				Security:    []map[string][]string{{"adminToken": {}}},
+			},
+			"webhooks": {
+				URL:         "{url}",
+				Protocol:    "http",
+				Description: "Each URL registered with POST /webhooks",
+				Variables:   map[string]asyncAPIServerVariable{"url": {Description: "The webhook's url"}},
+			},
+		},
+		Channels: map[string]asyncAPIChannel{
+			"/admin/events": {
+				Description: "Server-sent events (text/event-stream), one per entity event, with a keepalive comment every 15s. Events published while a client is behind are dropped.",
+				Servers:     []string{"ops"},
+				Subscribe: &asyncAPIOperation{
+					OperationID: "streamEvents",
+					Summary:     "Stream entity events as server-sent events",
+					Bindings:    map[string]map[string]any{"http": {"type": "request", "method": http.MethodGet, "bindingVersion": "0.1.0"}},
+					Message:     asyncAPIMessageRefs{OneOf: entityMessages},
+				},
+			},
+			"/": {
+				Description: "Each event a webhook subscribes to, POSTed to its url. A delivery is retried with exponential backoff until the webhook answers 2xx, up to 8 attempts.",
+				Servers:     []string{"webhooks"},
+				Subscribe: &asyncAPIOperation{
+					OperationID: "deliverWebhook",
+					Summary:     "Deliver an entity event to a webhook",
+					Bindings:    map[string]map[string]any{"http": {"type": "request", "method": http.MethodPost, "bindingVersion": "0.1.0"}},
+					Message:     asyncAPIMessageRefs{OneOf: []asyncAPIRef{{Ref: "#/components/messages/webhookDelivery"}}},
+				},
+			},
+		},
+		Components: asyncAPIComponents{
+			Schemas: map[string]*openapi3.SchemaRef{"Event": doc.Components.Schemas["Event"]},
+			Messages: map[string]asyncAPIMessage{
+				"entityCreated": eventMessage(EventCreated, "An entity was created; data is the new entity.", created),
+				"entityUpdated": eventMessage(EventUpdated, "An entity was updated; data is the entity after the change.",
+					Event{Type: EventUpdated, Resource: "users", ID: 3, Data: user}),
+				"entityDeleted": eventMessage(EventDeleted, "An entity was deleted; data is omitted.",
+					Event{Type: EventDeleted, Resource: "posts", ID: 2}),
+				"webhookDelivery": {
+					Name:         "webhookDelivery",
+					Title:        "Webhook delivery",
+					Summary:      "An entity event, as delivered to a webhook.",
+					ContentType:  "application/json",
+					SchemaFormat: openAPISchemaFormat,
+					Headers:      webhookHeaders,
+					Payload:      asyncAPIRef{Ref: "#/components/schemas/Event"},
+					Bindings:     map[string]map[string]any{"http": {"headers": webhookHeaders, "bindingVersion": "0.1.0"}},
+					Examples: []asyncAPIMessageExample{{
+						Name:    eventName(created),
+						Headers: map[string]string{"X-Webhook-Event": eventName(created), "X-Webhook-Delivery": "1"},
+						Payload: created,
+					}},
+				},
+			},
+			SecuritySchemes: map[string]asyncAPISecurityScheme{
+				"adminToken": {Type: "http", Scheme: "bearer", Description: "The ADMIN_TOKEN"},
+			},
+		},
+	}
+}
+
+// asyncAPIHandler serves the AsyncAPI document of the event channels.
+func (srv *Server) asyncAPIHandler(w http.ResponseWriter, r *http.Request) {
+	doc, err := srv.specDoc()
+	if err != nil {
+		srv.logAt("error", "parsing openapi.yaml: %v", err)
+		respondError(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+	respondFormat(w, http.StatusOK, newAsyncAPIDoc(doc, srv.Config.OpsAddr, srv.naming))
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== AsyncAPI Tests ==========
+
+func TestAsyncAPIDoc_RefsResolve(t *testing.T) {
+	setupRouter()
+	spec, err := server.specDoc()
+	require.NoError(t, err)
+	doc := newAsyncAPIDoc(spec, ":9090", nil)
+
+	assert.Equal(t, "localhost:9090", doc.Servers["ops"].Variables["host"].Default)
+	for name, ch := range doc.Channels {
+		for _, server := range ch.Servers {
+			assert.Contains(t, doc.Servers, server, "channel %s", name)
+		}
+		require.NotNil(t, ch.Subscribe, "channel %s", name)
+		for _, ref := range ch.Subscribe.Message.OneOf {
+			msg, ok := strings.CutPrefix(ref.Ref, "#/components/messages/")
+			require.True(t, ok, ref.Ref)
+			assert.Contains(t, doc.Components.Messages, msg, "channel %s", name)
+		}
+	}
+	for name, msg := range doc.Components.Messages {
+		schema, ok := strings.CutPrefix(msg.Payload.Ref, "#/components/schemas/")
+		require.True(t, ok, msg.Payload.Ref)
+		assert.NotNil(t, doc.Components.Schemas[schema], "message %s", name)
+	}
+}
+
+// Every example payload is an Event as openapi.yaml describes it.
+func TestAsyncAPIDoc_ExamplesMatchEventSchema(t *testing.T) {
+	for _, mode := range []string{"", "snake"} {
+		setupRouter()
+		useFieldNaming(t, mode)
+		spec, err := server.specDoc()
+		require.NoError(t, err)
+		doc := newAsyncAPIDoc(spec, ":9090", server.naming)
+		event := spec.Components.Schemas["Event"].Value
+
+		for name, msg := range doc.Components.Messages {
+			require.NotEmpty(t, msg.Examples, "naming %q, message %s", mode, name)
+			for _, ex := range msg.Examples {
+				data, err := json.Marshal(ex.Payload)
+				require.NoError(t, err)
+				var payload interface{}
+				require.NoError(t, json.Unmarshal(data, &payload))
+				assert.NoError(t, event.VisitJSON(payload), "naming %q, message %s, example %s", mode, name, ex.Name)
+			}
+		}
+	}
+}
+
+// JSON_NAMING renames the event payloads the document describes, never the
+// members AsyncAPI itself defines.
+func TestAsyncAPIHandler_FieldNaming(t *testing.T) {
+	router := setupRouter()
+	useFieldNaming(t, "snake")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/asyncapi.json", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	body := w.Body.String()
+	for _, name := range []string{"defaultContentType", "operationId", "oneOf", "contentType", "schemaFormat", "securitySchemes"} {
+		assert.Contains(t, body, `"`+name+`"`)
+	}
+	assert.NotContains(t, body, "default_content_type")
+	assert.Contains(t, body, `"created_at"`, "example payloads use the served names")
+	assert.NotContains(t, body, `"createdAt"`)
+}
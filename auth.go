@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/api2spec/api2spec-fixture-chi/ctxkit"
+)
+
+// Principal is the user a request is authenticated as, and the session
+// whose token it bears.
+type Principal struct {
+	UserID    int
+	SessionID int
+}
+
+// principalKey carries the Principal authenticate resolved.
+var principalKey = ctxkit.NewKey[Principal]("principal")
+
+// principalFrom returns the user ctx's request is authenticated as, if
+// any.
+func principalFrom(ctx context.Context) (Principal, bool) {
+	return principalKey.From(ctx)
+}
+
+// userTokenMAC returns the MAC of the token for userID's session
+// sessionID under the AUTH_SECRET.
+func (srv *Server) userTokenMAC(userID, sessionID int) []byte {
+	mac := hmac.New(sha256.New, srv.Config.AuthSecret)
+	mac.Write([]byte("user:" + strconv.Itoa(userID) + ":" + strconv.Itoa(sessionID)))
+	return mac.Sum(nil)
+}
+
+// userToken returns the bearer token authenticating as userID in session
+// sessionID: "<userID>.<sessionID>.<MAC>", with the MAC in unpadded
+// base64url.
+func (srv *Server) userToken(userID, sessionID int) string {
+	return strconv.Itoa(userID) + "." + strconv.Itoa(sessionID) + "." +
+		base64.RawURLEncoding.EncodeToString(srv.userTokenMAC(userID, sessionID))
+}
+
+// parseUserToken returns the Principal token authenticates as, checking
+// its MAC in constant time. Whether the session is revoked is up to the
+// caller.
+func (srv *Server) parseUserToken(token string) (Principal, bool) {
+	parts := strings.Split(token, ".")
+	if len(srv.Config.AuthSecret) == 0 || len(parts) != 3 {
+		return Principal{}, false
+	}
+	userID, err := parseID(parts[0])
+	if err != nil {
+		return Principal{}, false
+	}
+	sessionID, err := parseID(parts[1])
+	if err != nil {
+		return Principal{}, false
+	}
+	got, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(got, srv.userTokenMAC(userID, sessionID)) {
+		return Principal{}, false
+	}
+	return Principal{UserID: userID, SessionID: sessionID}, true
+}
+
+// expiringTokenMAC returns the MAC of an expiring token for purpose.
+func (srv *Server) expiringTokenMAC(purpose string, userID int, expires int64, bound []byte) []byte {
+	mac := hmac.New(sha256.New, srv.Config.AuthSecret)
+	mac.Write([]byte(purpose + ":" + strconv.Itoa(userID) + ":" + strconv.FormatInt(expires, 10) + ":"))
+	mac.Write(bound)
+	return mac.Sum(nil)
+}
+
+// expiringToken returns a token for userID, good for purpose until ttl
+// from now: "<userID>.<expires>.<MAC>", with expires in Unix seconds and
+// the MAC in unpadded base64url. The MAC also covers bound, so the token
+// stops working once bound changes.
+func (srv *Server) expiringToken(purpose string, userID int, ttl time.Duration, bound []byte) string {
+	expires := srv.Clock.Now().Add(ttl).Unix()
+	return strconv.Itoa(userID) + "." + strconv.FormatInt(expires, 10) + "." +
+		base64.RawURLEncoding.EncodeToString(srv.expiringTokenMAC(purpose, userID, expires, bound))
+}
+
+// parseExpiringToken returns the user an unexpired token for purpose was
+// issued to, with bound returning what the token was bound to.
+func (srv *Server) parseExpiringToken(purpose, token string, bound func(userID int) ([]byte, error)) (int, bool) {
+	parts := strings.Split(token, ".")
+	if len(srv.Config.AuthSecret) == 0 || len(parts) != 3 {
+		return 0, false
+	}
+	userID, err := parseID(parts[0])
+	if err != nil {
+		return 0, false
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || srv.Clock.Now().Unix() >= expires {
+		return 0, false
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return 0, false
+	}
+	b, err := bound(userID)
+	if err != nil || !hmac.Equal(mac, srv.expiringTokenMAC(purpose, userID, expires, b)) {
+		return 0, false
+	}
+	return userID, true
+}
+
+// authenticate records the Principal of requests bearing a valid user
+// token for an existing user, whose session is not on the revocation list.
+// Other requests, including those bearing the admin token, go on
+// unauthenticated; routes that need a user say so with requireUser.
+func (srv *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		p, ok := srv.parseUserToken(token)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if revoked, err := srv.requestStore(r).SessionRevoked(p.SessionID); err != nil || revoked {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if _, err := srv.requestStore(r).GetUser(p.UserID); err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(principalKey.With(r.Context(), p)))
+	})
+}
+
+// requireUser rejects requests that authenticate did not authenticate.
+func requireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := principalFrom(r.Context()); !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+			respondError(w, r, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// UserToken is the body of POST /admin/users/{id}/token.
+type UserToken struct {
+	UserID int    `json:"userId"`
+	Token  string `json:"token" redact:"secret"`
+}
+
+// issueUserToken returns a bearer token authenticating as the user in the
+// path, in a new session.
+func (srv *Server) issueUserToken(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	if len(srv.Config.AuthSecret) == 0 {
+		respondError(w, r, http.StatusServiceUnavailable, "user tokens are not configured")
+		return
+	}
+	if _, err := srv.requestStore(r).GetUser(id); err != nil {
+		respondNotFound(w, r, "user", id)
+		return
+	}
+	srv.respondSession(w, r, id)
+}
+
+// respondSession starts a session for userID and answers with its token.
+func (srv *Server) respondSession(w http.ResponseWriter, r *http.Request, userID int) {
+	session, err := srv.requestStore(r).CreateSession(Session{UserID: userID, UserAgent: r.UserAgent()})
+	switch {
+	case errors.Is(err, errNotFound):
+		respondNotFound(w, r, "user", userID)
+		return
+	case err != nil:
+		respondError(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+	respondJSON(w, http.StatusOK, UserToken{UserID: userID, Token: srv.userToken(userID, session.ID)})
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useAuthSecret configures user tokens on the test's server.
+func useAuthSecret(t *testing.T) {
+	t.Helper()
+	server.Config.AuthSecret = []byte("auth-secret")
+}
+
+// newUserRequest is newAdminRequest bearing the token of a new session of
+// userID's instead. The session is started in store.
+func newUserRequest(userID int, method, path, body string) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	session, _ := server.Store.CreateSession(Session{UserID: userID})
+	req.Header.Set("Authorization", "Bearer "+server.userToken(userID, session.ID))
+	return req
+}
+
+// ========== User Token Tests ==========
+
+func TestUserToken_RoundTrip(t *testing.T) {
+	useAuthSecret(t)
+
+	p, ok := server.parseUserToken(server.userToken(42, 7))
+	require.True(t, ok)
+	assert.Equal(t, Principal{UserID: 42, SessionID: 7}, p)
+}
+
+func TestParseUserToken_Rejects(t *testing.T) {
+	useAuthSecret(t)
+	valid := server.userToken(1, 1)
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"no MAC", "1.1"},
+		{"other user's MAC", "2" + valid[1:]},
+		{"other session's MAC", "1.2" + valid[3:]},
+		{"tampered MAC", valid[:len(valid)-1] + "A"},
+		{"not base64", "1.1.!!!"},
+		{"invalid ID", "0" + valid[1:]},
+		{"invalid session ID", "1.0" + valid[3:]},
+		{"no session", "1" + valid[3:]},
+		{"admin token", "admin-secret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ok := server.parseUserToken(tt.token)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestParseUserToken_NoSecret(t *testing.T) {
+	useAuthSecret(t)
+	token := server.userToken(1, 1)
+	server.Config.AuthSecret = nil
+
+	_, ok := server.parseUserToken(token)
+	assert.False(t, ok)
+}
+
+// ========== Authentication Middleware Tests ==========
+
+func TestRequireUser(t *testing.T) {
+	router := setupRouter()
+	useAuthSecret(t)
+
+	tests := []struct {
+		name          string
+		authorization string
+		status        int
+	}{
+		{"valid token", "Bearer " + server.userToken(1, 1), http.StatusOK},
+		{"no token", "", http.StatusUnauthorized},
+		{"unknown user", "Bearer " + server.userToken(999, 1), http.StatusUnauthorized},
+		{"tampered token", "Bearer 2" + server.userToken(1, 1)[1:], http.StatusUnauthorized},
+		{"not bearer", "Basic dXNlcjpwYXNz", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/me/usage", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code, w.Body.String())
+			if tt.status == http.StatusUnauthorized {
+				assert.Equal(t, `Bearer realm="api"`, w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestAuthenticate_InvalidTokenIsAnonymous(t *testing.T) {
+	router := setupRouter()
+	useAuthSecret(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set("Authorization", "Bearer not-a-user-token")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+// ========== Issue Token Endpoint Tests ==========
+
+func TestIssueUserToken(t *testing.T) {
+	router := setupAdminRouter(t)
+	useAuthSecret(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/admin/users/1/token", ""))
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var token UserToken
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &token))
+	assert.Equal(t, 1, token.UserID)
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/me/usage", nil)
+	req.Header.Set("Authorization", "Bearer "+token.Token)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestIssueUserToken_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret bool
+		admin  bool
+		path   string
+		status int
+	}{
+		{"not admin", true, false, "/admin/users/1/token", http.StatusUnauthorized},
+		{"unknown user", true, true, "/admin/users/999/token", http.StatusNotFound},
+		{"invalid ID", true, true, "/admin/users/abc/token", http.StatusBadRequest},
+		{"no secret", false, true, "/admin/users/1/token", http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupAdminRouter(t)
+			if tt.secret {
+				useAuthSecret(t)
+			}
+			req := httptest.NewRequest(http.MethodPost, tt.path, nil)
+			if tt.admin {
+				req = newAdminRequest(http.MethodPost, tt.path, "")
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code, w.Body.String())
+		})
+	}
+}
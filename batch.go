@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/api2spec/api2spec-fixture-chi/ctxkit"
+)
+
+const (
+	// maxBatchOperations bounds the operations in one POST /batch.
+	maxBatchOperations = 20
+	// maxBatchParallelism bounds the operations of a parallel batch that
+	// run at once.
+	maxBatchParallelism = 4
+)
+
+// Batch modes, as ?mode= names them.
+const (
+	batchSequential = "sequential"
+	batchParallel   = "parallel"
+)
+
+// batchMethods are the methods a batch operation may use.
+var batchMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// batchOperationKey marks the context of a batch operation's request, so
+// that one cannot run a batch however its path is spelled.
+var batchOperationKey = ctxkit.NewKey[bool]("batchOperation")
+
+// batchResultHeaders are the headers of an operation's answer kept in its
+// BatchResult.
+var batchResultHeaders = []string{"Content-Type", "Location"}
+
+// BatchOperation is one request of a POST /batch body.
+type BatchOperation struct {
+	Method string `json:"method"`
+	// Path is the request's path and query, e.g. "/users?page=2".
+	Path string `json:"path"`
+	// Body is the request's JSON body, if it has one.
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+// BatchResult is how one operation of a POST /batch was answered.
+type BatchResult struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body is the answer's body: a JSON document as is, anything else as a
+	// string.
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+// validateBatch returns one violation per way ops is not a batch that can
+// run.
+func validateBatch(ops []BatchOperation) []string {
+	var violations []string
+	switch {
+	case len(ops) == 0:
+		violations = append(violations, "request body: must list at least one operation")
+	case len(ops) > maxBatchOperations:
+		violations = append(violations, fmt.Sprintf("request body: must list at most %d operations", maxBatchOperations))
+	}
+	for i, op := range ops {
+		if !slices.Contains(batchMethods, op.Method) {
+			violations = append(violations, fmt.Sprintf("request body /%d/method: must be one of %s", i, strings.Join(batchMethods, ", ")))
+		}
+		u, err := url.Parse(op.Path)
+		switch {
+		case err != nil || !strings.HasPrefix(op.Path, "/") || strings.HasPrefix(op.Path, "//") || u.Fragment != "":
+			violations = append(violations, fmt.Sprintf("request body /%d/path: must be an absolute path, with any query", i))
+		case strings.TrimRight(path.Clean(u.Path), "/") == "/batch":
+			violations = append(violations, fmt.Sprintf("request body /%d/path: batches do not nest", i))
+		}
+	}
+	return violations
+}
+
+// runBatch serves POST /batch: it runs each operation as a request of its
+// own through the router serving the batch, middleware and all, with the
+// batch's headers, and answers with a BatchResult per operation, in order.
+// Operations run one after another, or with ?mode=parallel up to
+// maxBatchParallelism at once. The batch is answered 200 however its
+// operations fared.
+func (srv *Server) runBatch(w http.ResponseWriter, r *http.Request) {
+	if nested, _ := batchOperationKey.From(r.Context()); nested {
+		respondProblem(w, r, http.StatusBadRequest, "invalid request", Problem{Detail: "batches do not nest"})
+		return
+	}
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = batchSequential
+	}
+	if mode != batchSequential && mode != batchParallel {
+		respondProblem(w, r, http.StatusBadRequest, "invalid request", Problem{
+			Violations: []string{fmt.Sprintf("query parameter %q: must be one of %s, %s", "mode", batchSequential, batchParallel)},
+		})
+		return
+	}
+	var ops []BatchOperation
+	if err := decodeJSON(r, &ops); err != nil {
+		respondDecodeError(w, r, err)
+		return
+	}
+	if violations := validateBatch(ops); len(violations) > 0 {
+		respondProblem(w, r, http.StatusBadRequest, "invalid request", Problem{Violations: violations})
+		return
+	}
+
+	router, ok := chi.RouteContext(r.Context()).Routes.(http.Handler)
+	if !ok {
+		respondError(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+	results := make([]BatchResult, len(ops))
+	if mode == batchSequential {
+		for i, op := range ops {
+			results[i] = srv.runBatchOperation(router, r, op)
+		}
+	} else {
+		var wg sync.WaitGroup
+		slots := make(chan struct{}, maxBatchParallelism)
+		for i, op := range ops {
+			wg.Add(1)
+			slots <- struct{}{}
+			go func(i int, op BatchOperation) {
+				defer func() { <-slots; wg.Done() }()
+				results[i] = srv.runBatchOperation(router, r, op)
+			}(i, op)
+		}
+		wg.Wait()
+	}
+	respondJSON(w, http.StatusOK, results)
+}
+
+// runBatchOperation serves op, a part of batch, through router. A panic
+// serving it is a 500 for op alone.
+func (srv *Server) runBatchOperation(router http.Handler, batch *http.Request, op BatchOperation) (result BatchResult) {
+	defer func() {
+		if v := recover(); v != nil {
+			srv.logAt("error", "batch: %s %s: panic: %v", op.Method, op.Path, v)
+			result = BatchResult{Status: http.StatusInternalServerError}
+		}
+	}()
+
+	rec := &batchRecorder{header: http.Header{}}
+	router.ServeHTTP(rec, batchRequest(batch, op))
+	return rec.result()
+}
+
+// batchRequest is op as a request made with batch's headers. Its context
+// is batch's, without the batch's route, so the router routes it afresh,
+// and marked as an operation's.
+func batchRequest(batch *http.Request, op BatchOperation) *http.Request {
+	ctx := batchOperationKey.With(context.WithValue(batch.Context(), chi.RouteCtxKey, nil), true)
+	req, _ := http.NewRequestWithContext(ctx, op.Method, op.Path, bytes.NewReader(op.Body))
+	req.Host, req.RemoteAddr, req.RequestURI = batch.Host, batch.RemoteAddr, op.Path
+	req.Proto, req.ProtoMajor, req.ProtoMinor = batch.Proto, batch.ProtoMajor, batch.ProtoMinor
+	req.Header = batch.Header.Clone()
+	// What described the batch's body, or made its request conditional,
+	// says nothing about op.
+	for key := range req.Header {
+		if strings.HasPrefix(key, "Content-") || strings.HasPrefix(key, "If-") {
+			req.Header.Del(key)
+		}
+	}
+	for _, key := range []string{"Digest", "Range", "X-HTTP-Method-Override"} {
+		req.Header.Del(key)
+	}
+	req.Header.Set("Accept", "application/json")
+	if len(op.Body) > 0 {
+		req.Header.Set("Content-Type", "application/json")
+	} else {
+		req.Body = http.NoBody
+	}
+	return req
+}
+
+// batchRecorder holds an operation's answer.
+type batchRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *batchRecorder) Header() http.Header { return w.header }
+
+func (w *batchRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *batchRecorder) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(p)
+}
+
+// result is the answer as a BatchResult.
+func (w *batchRecorder) result() BatchResult {
+	res := BatchResult{Status: w.status}
+	if res.Status == 0 {
+		res.Status = http.StatusOK
+	}
+	for _, key := range batchResultHeaders {
+		if v := w.header.Get(key); v != "" {
+			if res.Headers == nil {
+				res.Headers = map[string]string{}
+			}
+			res.Headers[key] = v
+		}
+	}
+	switch body := w.body.Bytes(); {
+	case len(body) == 0:
+	case isJSONMediaType(w.header.Get("Content-Type")) && json.Valid(body):
+		res.Body = bytes.TrimSpace(body)
+	default:
+		res.Body, _ = json.Marshal(string(body))
+	}
+	return res
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runTestBatch posts body to path through router and returns the results.
+func runTestBatch(t *testing.T, router http.Handler, req *http.Request) []BatchResult {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var results []BatchResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	return results
+}
+
+// ========== Batch Tests ==========
+
+func TestRunBatch_Sequential(t *testing.T) {
+	router := setupRouter()
+	results := runTestBatch(t, router, newAdminRequest(http.MethodPost, "/batch", `[
+		{"method":"POST","path":"/todos","body":{"title":"First"}},
+		{"method":"GET","path":"/todos/4"},
+		{"method":"PUT","path":"/users/1","body":"not a user"},
+		{"method":"GET","path":"/openapi.yaml"},
+		{"method":"DELETE","path":"/users/2"}
+	]`))
+
+	require.Len(t, results, 5)
+	assert.Equal(t, http.StatusCreated, results[0].Status)
+	assert.Equal(t, "/todos/4", results[0].Headers["Location"])
+	assert.Equal(t, http.StatusOK, results[1].Status, "each operation sees those before it")
+	assert.JSONEq(t, string(results[0].Body), string(results[1].Body))
+	assert.Equal(t, http.StatusBadRequest, results[2].Status, "a failure does not stop the rest")
+	var spec string
+	require.NoError(t, json.Unmarshal(results[3].Body, &spec), "other bodies are strings")
+	assert.True(t, strings.HasPrefix(spec, "openapi: "))
+	assert.Equal(t, BatchResult{Status: http.StatusNoContent}, results[4])
+}
+
+func TestRunBatch_Parallel(t *testing.T) {
+	router := setupRouter()
+	var ops []string
+	for i := 0; i < maxBatchOperations; i++ {
+		ops = append(ops, fmt.Sprintf(`{"method":"GET","path":"/todos/%d"}`, i%3+1))
+	}
+	results := runTestBatch(t, router, newAdminRequest(http.MethodPost, "/batch?mode=parallel", "["+strings.Join(ops, ",")+"]"))
+
+	require.Len(t, results, maxBatchOperations)
+	for i, res := range results {
+		require.Equal(t, http.StatusOK, res.Status)
+		var todo Todo
+		require.NoError(t, json.Unmarshal(res.Body, &todo))
+		assert.Equal(t, i%3+1, todo.ID, "results are in the order of the operations")
+	}
+}
+
+func TestRunBatch_InheritsHeaders(t *testing.T) {
+	router := setupAdminRouter(t)
+	body := `[{"method":"GET","path":"/tenants"}]`
+
+	req := httptest.NewRequest(http.MethodPost, "/batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	results := runTestBatch(t, router, req)
+	assert.Equal(t, http.StatusUnauthorized, results[0].Status)
+
+	results = runTestBatch(t, router, newAdminRequest(http.MethodPost, "/batch", body))
+	assert.Equal(t, http.StatusOK, results[0].Status, "operations are authenticated as the batch is")
+
+	req = newAdminRequest(http.MethodPost, "/batch", `[{"method":"GET","path":"/users/99"}]`)
+	req.Header.Set("Accept-Language", "de")
+	results = runTestBatch(t, router, req)
+	assert.Contains(t, string(results[0].Body), "nicht gefunden")
+}
+
+func TestRunBatch_Invalid(t *testing.T) {
+	router := setupRouter()
+	many := strings.Repeat(`{"method":"GET","path":"/users"},`, maxBatchOperations+1)
+	tests := []struct {
+		name, path, body string
+		violations       []string
+	}{
+		{"empty", "/batch", `[]`, []string{"request body: must list at least one operation"}},
+		{"too many", "/batch", "[" + strings.TrimSuffix(many, ",") + "]", []string{"request body: must list at most 20 operations"}},
+		{"bad operations", "/batch", `[{"method":"TRACE","path":"/users"},{"method":"GET","path":"users"},{"method":"GET","path":"//evil.example.com/"},{"method":"POST","path":"/batch?mode=parallel","body":[]},{"method":"POST","path":"/batch/","body":[]},{"method":"POST","path":"/users/../batch","body":[]}]`, []string{
+			"request body /0/method: must be one of GET, POST, PUT, PATCH, DELETE",
+			"request body /1/path: must be an absolute path, with any query",
+			"request body /2/path: must be an absolute path, with any query",
+			"request body /3/path: batches do not nest",
+			"request body /4/path: batches do not nest",
+			"request body /5/path: batches do not nest",
+		}},
+		{"bad mode", "/batch?mode=eventually", `[{"method":"GET","path":"/users"}]`, []string{`query parameter "mode": must be one of sequential, parallel`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, newAdminRequest(http.MethodPost, tt.path, tt.body))
+			require.Equal(t, http.StatusBadRequest, w.Code)
+			var p Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+			assert.Equal(t, tt.violations, p.Violations)
+		})
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/batch", `{"method":"GET"}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code, "the body is an array")
+}
+
+func TestRunBatch_OperationCannotBatch(t *testing.T) {
+	router := setupRouter()
+	batch := newAdminRequest(http.MethodPost, "/batch", "")
+	req := batchRequest(batch, BatchOperation{Method: http.MethodPost, Path: "/batch", Body: json.RawMessage(`[{"method":"GET","path":"/health"}]`)})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "an operation's request runs no batch")
+	assert.Contains(t, w.Body.String(), "batches do not nest")
+}
@@ -0,0 +1,719 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/api2spec/api2spec-fixture-chi/ctxkit"
+	"github.com/api2spec/api2spec-fixture-chi/flags"
+)
+
+// Circuit breaker states, as reported in StoreInfo.
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half-open"
+)
+
+// errUnavailable is returned, without calling the backend, by store writes
+// while the breaker is open.
+var errUnavailable = errors.New("store unavailable")
+
+// breaker is a consecutive-failure circuit breaker. It opens after
+// threshold failures in a row; once cooldown has passed it lets a single
+// trial call through, which closes it on success and reopens it on failure.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{threshold: threshold, cooldown: cooldown, now: time.Now, state: breakerClosed}
+}
+
+// allow reports whether a call may go to the backend. Every allowed call
+// must be followed by record.
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = breakerHalfOpen
+		b.trial = true
+		return true
+	case breakerHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	}
+	return true
+}
+
+// record reports the outcome of an allowed call.
+func (b *breaker) record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	if !failed {
+		b.state, b.failures = breakerClosed, 0
+		return
+	}
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.state, b.openedAt = breakerOpen, b.now()
+	}
+}
+
+// State returns breakerClosed, breakerOpen or breakerHalfOpen.
+func (b *breaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// domainErrors are the store errors the API reports to clients.
+var domainErrors = []error{errNotFound, errDuplicate, errVersionConflict, errModified}
+
+// isBackendFailure reports whether err means the backend failed, rather
+// than that the call was refused for a reason the API reports to clients.
+func isBackendFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	for _, domain := range domainErrors {
+		if errors.Is(err, domain) {
+			return false
+		}
+	}
+	return true
+}
+
+// breakerStore guards a Store with a breaker. Backend failures are errors
+// other than the domain errors, and panics. Reads remember their last
+// successful result, which they return instead of calling the backend
+// while the breaker is open, or when the call fails; writes return
+// errUnavailable while it is open. Info reports the breaker's state.
+type breakerStore struct {
+	next  Store
+	b     *breaker
+	cache readCache
+	stale *atomic.Bool // set when a read returns a cached result; see reporting
+}
+
+// newBreakerStore guards next with b, caching read results in process.
+// Servers sharing Redis replace the cache with a redisReadCache, so every
+// instance can serve them.
+func newBreakerStore(next Store, b *breaker) *breakerStore {
+	return &breakerStore{next: next, b: b, cache: newMemoryReadCache()}
+}
+
+// reporting returns a view of s, for one request, that sets stale whenever
+// a read returns a cached result instead of the backend's.
+func (s *breakerStore) reporting(stale *atomic.Bool) *breakerStore {
+	return &breakerStore{next: s.next, b: s.b, cache: s.cache, stale: stale}
+}
+
+// setClock passes c on to the guarded store; see Server.useClock.
+func (s *breakerStore) setClock(c Clock) {
+	if cs, ok := s.next.(interface{ setClock(Clock) }); ok {
+		cs.setClock(c)
+	}
+}
+
+// cachedRead is the outcome of a read: its result and error.
+type cachedRead struct {
+	v   interface{}
+	err error
+}
+
+// readCache holds the last outcome of each breakerStore read, by key.
+type readCache interface {
+	load(key string) (cachedRead, bool)
+	save(key string, r cachedRead)
+	clear()
+}
+
+// memoryReadCache is an in-process readCache.
+type memoryReadCache struct {
+	mu    sync.Mutex
+	reads map[string]cachedRead
+}
+
+func newMemoryReadCache() *memoryReadCache {
+	return &memoryReadCache{reads: map[string]cachedRead{}}
+}
+
+func (c *memoryReadCache) load(key string) (cachedRead, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.reads[key]
+	return r, ok
+}
+
+func (c *memoryReadCache) save(key string, r cachedRead) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reads[key] = r
+}
+
+func (c *memoryReadCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reads = map[string]cachedRead{}
+}
+
+// call runs fn on the backend, recording whether it failed. A panic counts
+// as a failure and is returned as an error.
+func (s *breakerStore) call(fn func() (interface{}, error)) (v interface{}, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			v, err = nil, fmt.Errorf("%w: %v", errUnavailable, p)
+		}
+		s.b.record(isBackendFailure(err))
+	}()
+	return fn()
+}
+
+// read runs fn, a read identified by key, through the breaker.
+func (s *breakerStore) read(key string, fn func() (interface{}, error)) (interface{}, error) {
+	if s.b.allow() {
+		v, err := s.call(fn)
+		if !isBackendFailure(err) {
+			s.cache.save(key, cachedRead{v, err})
+			return v, err
+		}
+	}
+	cached, ok := s.cache.load(key)
+	if !ok {
+		return nil, errUnavailable
+	}
+	if s.stale != nil {
+		s.stale.Store(true)
+	}
+	return cached.v, cached.err
+}
+
+// write runs fn, a write, through the breaker.
+func (s *breakerStore) write(fn func() (interface{}, error)) (interface{}, error) {
+	if !s.b.allow() {
+		return nil, errUnavailable
+	}
+	return s.call(fn)
+}
+
+// The methods below adapt read and write to each method's result. A read
+// that fails with nothing cached yields the zero value, or an empty list.
+
+func (s *breakerStore) ListUsers() []User {
+	v, _ := s.read("ListUsers", func() (interface{}, error) { return s.next.ListUsers(), nil })
+	users, _ := v.([]User)
+	if users == nil {
+		return []User{}
+	}
+	return users
+}
+
+func (s *breakerStore) CountUsers() int {
+	v, _ := s.read("CountUsers", func() (interface{}, error) { return s.next.CountUsers(), nil })
+	n, _ := v.(int)
+	return n
+}
+
+func (s *breakerStore) SuggestUsers(prefix string, limit int) []UserSuggestion {
+	v, _ := s.read("SuggestUsers:"+strconv.Itoa(limit)+":"+prefix, func() (interface{}, error) { return s.next.SuggestUsers(prefix, limit), nil })
+	suggestions, _ := v.([]UserSuggestion)
+	if suggestions == nil {
+		return []UserSuggestion{}
+	}
+	return suggestions
+}
+
+func (s *breakerStore) GetUser(id int) (User, error) {
+	v, err := s.read("GetUser:"+strconv.Itoa(id), func() (interface{}, error) { return s.next.GetUser(id) })
+	u, _ := v.(User)
+	return u, err
+}
+
+func (s *breakerStore) CreateUser(u User) (User, error) {
+	v, err := s.write(func() (interface{}, error) { return s.next.CreateUser(u) })
+	u, _ = v.(User)
+	return u, err
+}
+
+func (s *breakerStore) UpdateUser(u User) (User, error) {
+	v, err := s.write(func() (interface{}, error) { return s.next.UpdateUser(u) })
+	u, _ = v.(User)
+	return u, err
+}
+
+func (s *breakerStore) DeleteUser(id int, unmodifiedSince time.Time) error {
+	_, err := s.write(func() (interface{}, error) { return nil, s.next.DeleteUser(id, unmodifiedSince) })
+	return err
+}
+
+func (s *breakerStore) ListPosts() []Post {
+	v, _ := s.read("ListPosts", func() (interface{}, error) { return s.next.ListPosts(), nil })
+	posts, _ := v.([]Post)
+	if posts == nil {
+		return []Post{}
+	}
+	return posts
+}
+
+func (s *breakerStore) GetPostBySlug(slug string) (Post, error) {
+	v, err := s.read("GetPostBySlug:"+slug, func() (interface{}, error) { return s.next.GetPostBySlug(slug) })
+	p, _ := v.(Post)
+	return p, err
+}
+
+func (s *breakerStore) CountPosts(userID int) int {
+	v, _ := s.read("CountPosts:"+strconv.Itoa(userID), func() (interface{}, error) { return s.next.CountPosts(userID), nil })
+	n, _ := v.(int)
+	return n
+}
+
+func (s *breakerStore) ListPostsByUser(userID int) []Post {
+	v, _ := s.read("ListPostsByUser:"+strconv.Itoa(userID), func() (interface{}, error) { return s.next.ListPostsByUser(userID), nil })
+	posts, _ := v.([]Post)
+	if posts == nil {
+		return []Post{}
+	}
+	return posts
+}
+
+func (s *breakerStore) GetPost(id int) (Post, error) {
+	v, err := s.read("GetPost:"+strconv.Itoa(id), func() (interface{}, error) { return s.next.GetPost(id) })
+	p, _ := v.(Post)
+	return p, err
+}
+
+func (s *breakerStore) CreatePost(p Post) (Post, error) {
+	v, err := s.write(func() (interface{}, error) { return s.next.CreatePost(p) })
+	p, _ = v.(Post)
+	return p, err
+}
+
+func (s *breakerStore) CreateUserPost(p Post) (Post, error) {
+	v, err := s.write(func() (interface{}, error) { return s.next.CreateUserPost(p) })
+	p, _ = v.(Post)
+	return p, err
+}
+
+func (s *breakerStore) UpdatePost(p Post) (Post, error) {
+	v, err := s.write(func() (interface{}, error) { return s.next.UpdatePost(p) })
+	p, _ = v.(Post)
+	return p, err
+}
+
+func (s *breakerStore) DeletePost(id int, unmodifiedSince time.Time) error {
+	_, err := s.write(func() (interface{}, error) { return nil, s.next.DeletePost(id, unmodifiedSince) })
+	return err
+}
+
+func (s *breakerStore) ListTodos() []Todo {
+	v, _ := s.read("ListTodos", func() (interface{}, error) { return s.next.ListTodos(), nil })
+	todos, _ := v.([]Todo)
+	if todos == nil {
+		return []Todo{}
+	}
+	return todos
+}
+
+func (s *breakerStore) GetTodo(id int) (Todo, error) {
+	v, err := s.read("GetTodo:"+strconv.Itoa(id), func() (interface{}, error) { return s.next.GetTodo(id) })
+	t, _ := v.(Todo)
+	return t, err
+}
+
+func (s *breakerStore) CreateTodo(t Todo) (Todo, error) {
+	v, err := s.write(func() (interface{}, error) { return s.next.CreateTodo(t) })
+	t, _ = v.(Todo)
+	return t, err
+}
+
+func (s *breakerStore) ListAlbums() []Album {
+	v, _ := s.read("ListAlbums", func() (interface{}, error) { return s.next.ListAlbums(), nil })
+	albums, _ := v.([]Album)
+	if albums == nil {
+		return []Album{}
+	}
+	return albums
+}
+
+func (s *breakerStore) GetAlbum(id int) (Album, error) {
+	v, err := s.read("GetAlbum:"+strconv.Itoa(id), func() (interface{}, error) { return s.next.GetAlbum(id) })
+	a, _ := v.(Album)
+	return a, err
+}
+
+func (s *breakerStore) CreateAlbum(a Album) (Album, error) {
+	v, err := s.write(func() (interface{}, error) { return s.next.CreateAlbum(a) })
+	a, _ = v.(Album)
+	return a, err
+}
+
+func (s *breakerStore) ListPhotosByAlbum(albumID int) []Photo {
+	v, _ := s.read("ListPhotosByAlbum:"+strconv.Itoa(albumID), func() (interface{}, error) { return s.next.ListPhotosByAlbum(albumID), nil })
+	photos, _ := v.([]Photo)
+	if photos == nil {
+		return []Photo{}
+	}
+	return photos
+}
+
+func (s *breakerStore) GetPhoto(id int) (Photo, error) {
+	v, err := s.read("GetPhoto:"+strconv.Itoa(id), func() (interface{}, error) { return s.next.GetPhoto(id) })
+	p, _ := v.(Photo)
+	return p, err
+}
+
+func (s *breakerStore) CreatePhoto(p Photo, data []byte) (Photo, error) {
+	v, err := s.write(func() (interface{}, error) { return s.next.CreatePhoto(p, data) })
+	p, _ = v.(Photo)
+	return p, err
+}
+
+func (s *breakerStore) PhotoData(id int) ([]byte, error) {
+	v, err := s.read("PhotoData:"+strconv.Itoa(id), func() (interface{}, error) { return s.next.PhotoData(id) })
+	data, _ := v.([]byte)
+	return data, err
+}
+
+func (s *breakerStore) GetComment(id int) (Comment, error) {
+	v, err := s.read("GetComment:"+strconv.Itoa(id), func() (interface{}, error) { return s.next.GetComment(id) })
+	c, _ := v.(Comment)
+	return c, err
+}
+
+func (s *breakerStore) ListPlaces() []Place {
+	v, _ := s.read("ListPlaces", func() (interface{}, error) { return s.next.ListPlaces(), nil })
+	places, _ := v.([]Place)
+	if places == nil {
+		return []Place{}
+	}
+	return places
+}
+
+func (s *breakerStore) ListIngestEvents() []IngestEvent {
+	v, _ := s.read("ListIngestEvents", func() (interface{}, error) { return s.next.ListIngestEvents(), nil })
+	events, _ := v.([]IngestEvent)
+	if events == nil {
+		return []IngestEvent{}
+	}
+	return events
+}
+
+func (s *breakerStore) GetIngestEvent(id int) (IngestEvent, error) {
+	v, err := s.read("GetIngestEvent:"+strconv.Itoa(id), func() (interface{}, error) { return s.next.GetIngestEvent(id) })
+	e, _ := v.(IngestEvent)
+	return e, err
+}
+
+func (s *breakerStore) CreateIngestEvent(e IngestEvent) (IngestEvent, error) {
+	v, err := s.write(func() (interface{}, error) { return s.next.CreateIngestEvent(e) })
+	e, _ = v.(IngestEvent)
+	return e, err
+}
+
+func (s *breakerStore) ListWebhooks() []Webhook {
+	v, _ := s.read("ListWebhooks", func() (interface{}, error) { return s.next.ListWebhooks(), nil })
+	hooks, _ := v.([]Webhook)
+	if hooks == nil {
+		return []Webhook{}
+	}
+	return hooks
+}
+
+func (s *breakerStore) GetWebhook(id int) (Webhook, error) {
+	v, err := s.read("GetWebhook:"+strconv.Itoa(id), func() (interface{}, error) { return s.next.GetWebhook(id) })
+	w, _ := v.(Webhook)
+	return w, err
+}
+
+func (s *breakerStore) CreateWebhook(w Webhook) (Webhook, error) {
+	v, err := s.write(func() (interface{}, error) { return s.next.CreateWebhook(w) })
+	w, _ = v.(Webhook)
+	return w, err
+}
+
+func (s *breakerStore) DeleteWebhook(id int) error {
+	_, err := s.write(func() (interface{}, error) { return nil, s.next.DeleteWebhook(id) })
+	return err
+}
+
+func (s *breakerStore) ListTenants() []Tenant {
+	v, _ := s.read("ListTenants", func() (interface{}, error) { return s.next.ListTenants(), nil })
+	tenants, _ := v.([]Tenant)
+	if tenants == nil {
+		return []Tenant{}
+	}
+	return tenants
+}
+
+func (s *breakerStore) GetTenant(id int) (Tenant, error) {
+	v, err := s.read("GetTenant:"+strconv.Itoa(id), func() (interface{}, error) { return s.next.GetTenant(id) })
+	t, _ := v.(Tenant)
+	return t, err
+}
+
+func (s *breakerStore) CreateTenant(t Tenant) (Tenant, error) {
+	v, err := s.write(func() (interface{}, error) { return s.next.CreateTenant(t) })
+	t, _ = v.(Tenant)
+	return t, err
+}
+
+func (s *breakerStore) UpdateTenant(t Tenant) (Tenant, error) {
+	v, err := s.write(func() (interface{}, error) { return s.next.UpdateTenant(t) })
+	t, _ = v.(Tenant)
+	return t, err
+}
+
+func (s *breakerStore) DeleteTenant(id int) error {
+	_, err := s.write(func() (interface{}, error) { return nil, s.next.DeleteTenant(id) })
+	return err
+}
+
+func (s *breakerStore) GetFlags() flags.Set {
+	v, _ := s.read("GetFlags", func() (interface{}, error) { return s.next.GetFlags(), nil })
+	set, _ := v.(flags.Set)
+	return set
+}
+
+func (s *breakerStore) UpdateFlags(p flags.Patch) flags.Set {
+	v, err := s.write(func() (interface{}, error) { return s.next.UpdateFlags(p), nil })
+	if err != nil {
+		return s.GetFlags()
+	}
+	return v.(flags.Set)
+}
+
+func (s *breakerStore) EnqueueDelivery(d Delivery) (Delivery, error) {
+	v, err := s.write(func() (interface{}, error) { return s.next.EnqueueDelivery(d) })
+	d, _ = v.(Delivery)
+	return d, err
+}
+
+// DueDeliveries is not cached: while the breaker is open, the dispatcher
+// waits rather than retrying deliveries from a stale queue.
+func (s *breakerStore) DueDeliveries(now time.Time) []Delivery {
+	v, err := s.write(func() (interface{}, error) { return s.next.DueDeliveries(now), nil })
+	if err != nil {
+		return nil
+	}
+	return v.([]Delivery)
+}
+
+func (s *breakerStore) UpdateDelivery(d Delivery) error {
+	_, err := s.write(func() (interface{}, error) { return nil, s.next.UpdateDelivery(d) })
+	return err
+}
+
+func (s *breakerStore) ListDeliveries(webhookID int) []Delivery {
+	v, _ := s.read("ListDeliveries:"+strconv.Itoa(webhookID), func() (interface{}, error) { return s.next.ListDeliveries(webhookID), nil })
+	deliveries, _ := v.([]Delivery)
+	if deliveries == nil {
+		return []Delivery{}
+	}
+	return deliveries
+}
+
+func (s *breakerStore) VerifyUser(id int, email string) (User, error) {
+	v, err := s.write(func() (interface{}, error) { return s.next.VerifyUser(id, email) })
+	u, _ := v.(User)
+	return u, err
+}
+
+func (s *breakerStore) SetPasswordHash(id int, hash []byte) error {
+	_, err := s.write(func() (interface{}, error) { return nil, s.next.SetPasswordHash(id, hash) })
+	return err
+}
+
+// PasswordHash goes through the breaker like a write so that hashes are
+// never cached, and a replaced password is never accepted from the cache.
+func (s *breakerStore) PasswordHash(id int) ([]byte, error) {
+	v, err := s.write(func() (interface{}, error) { return s.next.PasswordHash(id) })
+	hash, _ := v.([]byte)
+	return hash, err
+}
+
+func (s *breakerStore) SetTwoFactor(id int, tf TwoFactor) error {
+	_, err := s.write(func() (interface{}, error) { return nil, s.next.SetTwoFactor(id, tf) })
+	return err
+}
+
+// TwoFactor goes through the breaker like a write, for the same reason as
+// PasswordHash.
+func (s *breakerStore) TwoFactor(id int) (TwoFactor, error) {
+	v, err := s.write(func() (interface{}, error) { return s.next.TwoFactor(id) })
+	tf, _ := v.(TwoFactor)
+	return tf, err
+}
+
+func (s *breakerStore) CreateSession(session Session) (Session, error) {
+	v, err := s.write(func() (interface{}, error) { return s.next.CreateSession(session) })
+	session, _ = v.(Session)
+	return session, err
+}
+
+// ListSessions goes through the breaker like a write so that a revoked
+// session is never listed from the cache.
+func (s *breakerStore) ListSessions(userID int) []Session {
+	v, _ := s.write(func() (interface{}, error) { return s.next.ListSessions(userID), nil })
+	sessions, _ := v.([]Session)
+	if sessions == nil {
+		return []Session{}
+	}
+	return sessions
+}
+
+func (s *breakerStore) RevokeSession(userID, id int) error {
+	_, err := s.write(func() (interface{}, error) { return nil, s.next.RevokeSession(userID, id) })
+	return err
+}
+
+// SessionRevoked goes through the breaker like a write so that a revoked
+// session is never let in from the cache.
+func (s *breakerStore) SessionRevoked(id int) (bool, error) {
+	v, err := s.write(func() (interface{}, error) { return s.next.SessionRevoked(id) })
+	revoked, _ := v.(bool)
+	return revoked, err
+}
+
+func (s *breakerStore) ChargeUsage(userID int, day time.Time, limit int) (int, error) {
+	v, err := s.write(func() (interface{}, error) { return s.next.ChargeUsage(userID, day, limit) })
+	n, _ := v.(int)
+	return n, err
+}
+
+func (s *breakerStore) Usage(userID int, day time.Time) int {
+	v, _ := s.read("Usage:"+strconv.Itoa(userID)+":"+day.Format(time.DateOnly), func() (interface{}, error) { return s.next.Usage(userID, day), nil })
+	n, _ := v.(int)
+	return n
+}
+
+// Snapshot and Restore are administrative and bypass the breaker.
+
+func (s *breakerStore) Snapshot() State {
+	return s.next.Snapshot()
+}
+
+func (s *breakerStore) Restore(st State) {
+	s.next.Restore(st)
+	s.cache.clear()
+}
+
+func (s *breakerStore) Info() StoreInfo {
+	info := s.next.Info()
+	info.Breaker = s.b.State()
+	return info
+}
+
+// Ping bypasses the breaker, so that it reports on the backend itself.
+func (s *breakerStore) Ping(ctx context.Context) error {
+	return s.next.Ping(ctx)
+}
+
+// storeBreakerState returns the state of the breaker guarding the server's
+// store, or "" if it has none.
+func (srv *Server) storeBreakerState() string {
+	if bs, ok := srv.Store.(*breakerStore); ok {
+		return bs.b.State()
+	}
+	return ""
+}
+
+// staleReadKey carries the flag the request's store reads set when they
+// return cached results.
+var staleReadKey = ctxkit.NewKey[*atomic.Bool]("staleRead")
+
+// staleWarning is the Warning header on responses that may be stale.
+const staleWarning = `110 - "Response is Stale"`
+
+// degradedMode returns 503 for writes while the store's breaker is open,
+// and marks every other response as possibly stale with Warning: 110. With
+// the breaker closed, a response is marked if a read for it fell back to
+// the cache because the backend failed.
+func (srv *Server) degradedMode(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if srv.storeBreakerState() != breakerOpen {
+			stale := new(atomic.Bool)
+			next.ServeHTTP(&staleWriter{ResponseWriter: w, stale: stale}, r.WithContext(staleReadKey.With(r.Context(), stale)))
+			return
+		}
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			w.Header().Set("Warning", staleWarning)
+			next.ServeHTTP(w, r)
+		default:
+			w.Header().Set("Retry-After", strconv.Itoa(int(srv.retryAfter().Seconds())))
+			respondError(w, r, http.StatusServiceUnavailable, "store unavailable")
+		}
+	})
+}
+
+// staleWriter sets the Warning header, when the response's headers are
+// written, if stale is set by then.
+type staleWriter struct {
+	http.ResponseWriter
+	stale       *atomic.Bool
+	wroteHeader bool
+}
+
+func (w *staleWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if w.stale.Load() {
+			w.Header().Set("Warning", staleWarning)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *staleWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *staleWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *staleWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// retryAfter is how long until the store's breaker lets a trial call
+// through, at least one second.
+func (srv *Server) retryAfter() time.Duration {
+	bs, ok := srv.Store.(*breakerStore)
+	if !ok {
+		return time.Second
+	}
+	bs.b.mu.Lock()
+	defer bs.b.mu.Unlock()
+	wait := bs.b.cooldown - bs.b.now().Sub(bs.b.openedAt)
+	if wait < time.Second {
+		return time.Second
+	}
+	return wait.Round(time.Second)
+}
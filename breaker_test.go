@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyStore is a memoryStore whose user methods fail while down is set:
+// ListUsers by panicking, CreateUser with an error.
+type flakyStore struct {
+	*memoryStore
+	down bool
+}
+
+var errBackendDown = errors.New("connection refused")
+
+func (s *flakyStore) ListUsers() []User {
+	if s.down {
+		panic(errBackendDown)
+	}
+	return s.memoryStore.ListUsers()
+}
+
+func (s *flakyStore) CreateUser(u User) (User, error) {
+	if s.down {
+		return User{}, errBackendDown
+	}
+	return s.memoryStore.CreateUser(u)
+}
+
+// fakeClock is a settable Clock.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) Now() time.Time { return c.t }
+
+// useBreakerStore installs a breaker-guarded flakyStore as the server's store
+// for the duration of the test.
+func useBreakerStore(t *testing.T, threshold int) (*flakyStore, *breaker, *fakeClock) {
+	t.Helper()
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	backend := &flakyStore{memoryStore: newMemoryStore(server.events)}
+	b := newBreaker(threshold, 30*time.Second)
+	b.now = clock.Now
+	prev := server.Store
+	server.Store = newBreakerStore(backend, b)
+	t.Cleanup(func() { server.Store = prev })
+	return backend, b, clock
+}
+
+// ========== Breaker Tests ==========
+
+func TestBreaker_Transitions(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	b := newBreaker(2, 30*time.Second)
+	b.now = clock.Now
+
+	require.True(t, b.allow())
+	b.record(true)
+	assert.Equal(t, breakerClosed, b.State(), "one failure is below the threshold")
+	require.True(t, b.allow())
+	b.record(true)
+	assert.Equal(t, breakerOpen, b.State())
+	assert.False(t, b.allow(), "open breakers refuse calls")
+
+	clock.t = clock.t.Add(30 * time.Second)
+	require.True(t, b.allow(), "after the cooldown one trial call goes through")
+	assert.Equal(t, breakerHalfOpen, b.State())
+	assert.False(t, b.allow(), "only one trial at a time")
+	b.record(true)
+	assert.Equal(t, breakerOpen, b.State(), "a failed trial reopens the breaker")
+
+	clock.t = clock.t.Add(30 * time.Second)
+	require.True(t, b.allow())
+	b.record(false)
+	assert.Equal(t, breakerClosed, b.State())
+	require.True(t, b.allow())
+	b.record(true)
+	assert.Equal(t, breakerClosed, b.State(), "success resets the failure count")
+}
+
+func TestBreakerStore_ReadsFallBackToCache(t *testing.T) {
+	backend, b, _ := useBreakerStore(t, 2)
+	fresh := server.Store.ListUsers()
+	require.Len(t, fresh, 2)
+
+	backend.down = true
+	assert.Equal(t, fresh, server.Store.ListUsers(), "a failed read returns the last result")
+	assert.Equal(t, fresh, server.Store.ListUsers())
+	assert.Equal(t, breakerOpen, b.State())
+	assert.Equal(t, fresh, server.Store.ListUsers(), "an open breaker serves the last result")
+
+	_, err := server.Store.GetUser(1)
+	assert.ErrorIs(t, err, errUnavailable, "reads with nothing cached fail while open")
+}
+
+func TestBreakerStore_DomainErrorsAreNotFailures(t *testing.T) {
+	_, b, _ := useBreakerStore(t, 1)
+
+	_, err := server.Store.GetUser(999)
+	assert.ErrorIs(t, err, errNotFound)
+	_, err = server.Store.CreateUser(User{Name: "Alice", Email: "alice@example.com"})
+	assert.ErrorIs(t, err, errDuplicate)
+	assert.Equal(t, breakerClosed, b.State())
+}
+
+func TestBreakerStore_WritesFailWhileOpen(t *testing.T) {
+	backend, b, clock := useBreakerStore(t, 1)
+
+	backend.down = true
+	_, err := server.Store.CreateUser(User{Name: "Carol"})
+	assert.ErrorIs(t, err, errBackendDown)
+	assert.Equal(t, breakerOpen, b.State())
+
+	backend.down = false
+	_, err = server.Store.CreateUser(User{Name: "Carol"})
+	assert.ErrorIs(t, err, errUnavailable, "the backend is not called while the breaker is open")
+
+	clock.t = clock.t.Add(30 * time.Second)
+	_, err = server.Store.CreateUser(User{Name: "Carol"})
+	assert.NoError(t, err)
+	assert.Equal(t, breakerClosed, b.State())
+}
+
+// ========== Degraded Mode Tests ==========
+
+func TestDegradedMode(t *testing.T) {
+	router := setupRouter()
+	backend, b, _ := useBreakerStore(t, 1)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Warning"))
+
+	backend.down = true
+	server.Store.ListUsers()
+	require.Equal(t, breakerOpen, b.State())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `110 - "Response is Stale"`, w.Header().Get("Warning"))
+	var users []User
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &users))
+	assert.Len(t, users, 2)
+
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":"Carol"}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var health HealthStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &health))
+	assert.Equal(t, "degraded", health.Status)
+	assert.Equal(t, &StoreInfo{Backend: "memory", Breaker: breakerOpen}, health.Store)
+}
+
+func TestDegradedMode_StaleReadWhileClosed(t *testing.T) {
+	router := setupRouter()
+	backend, b, _ := useBreakerStore(t, 5)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Warning"))
+
+	backend.down = true
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, breakerClosed, b.State())
+	assert.Equal(t, staleWarning, w.Header().Get("Warning"), "the cached result is stale though the breaker is closed")
+	var users []User
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &users))
+	assert.Len(t, users, 2)
+
+	backend.down = false
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Warning"))
+}
+
+func TestReadyHandler_WithoutBreaker(t *testing.T) {
+	router := setupRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var health HealthStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &health))
+	assert.Equal(t, "ready", health.Status)
+	assert.Equal(t, &StoreInfo{Backend: "memory"}, health.Store)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ndjsonMediaType is the media type of JSON Lines bodies: one JSON value
+// per line.
+const ndjsonMediaType = "application/x-ndjson"
+
+// maxBulkBodyBytes bounds a POST /posts/bulk body; each line is still
+// bounded by maxBodyBytes.
+const maxBulkBodyBytes = 16 << 20
+
+// BulkPostResult is a line of the POST /posts/bulk response: what became of
+// the post on line Line of the request, with the status POST /posts would
+// have answered.
+type BulkPostResult struct {
+	Line   int    `json:"line"`
+	Status int    `json:"status"`
+	Post   *Post  `json:"post,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BulkPostSummary counts the results of POST /posts/bulk. It is the last
+// line of the response, under "summary".
+type BulkPostSummary struct {
+	Lines      int `json:"lines"`
+	Created    int `json:"created"`
+	Duplicates int `json:"duplicates"`
+	// Errors counts lines that were not valid posts or could not be
+	// stored.
+	Errors int `json:"errors"`
+	// Error says why the body was not read to the end, such as its size.
+	Error string `json:"error,omitempty"`
+}
+
+// bulkCreatePosts creates a post from each line of a JSON Lines body,
+// decoding them one at a time, and streams back a BulkPostResult per
+// non-blank line followed by a BulkPostSummary. Lines that are not valid
+// posts, or that duplicate an existing post, are reported and skipped.
+// Once results are streaming the status is committed, so a body that
+// cannot be read to the end ends the stream with the summary's Error.
+func (srv *Server) bulkCreatePosts(w http.ResponseWriter, r *http.Request) {
+	body := bufio.NewReader(http.MaxBytesReader(w, r.Body, maxBulkBodyBytes))
+	// Results stream back while the body is still being read. Writers that
+	// cannot do both leave the body to be buffered by the request
+	// validator.
+	http.NewResponseController(w).EnableFullDuplex()
+
+	w.Header().Set("Content-Type", ndjsonMediaType)
+	w.WriteHeader(http.StatusOK)
+	results := newNDJSONWriter(w)
+
+	summary := BulkPostSummary{}
+	for n := 1; ; n++ {
+		line, err := readBulkLine(body)
+		if len(bytes.TrimSpace(line)) > 0 {
+			result := srv.bulkCreatePost(r, n, line)
+			summary.Lines++
+			switch result.Status {
+			case http.StatusCreated:
+				summary.Created++
+			case http.StatusConflict:
+				summary.Duplicates++
+			default:
+				summary.Errors++
+			}
+			results.write(result)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			summary.Error = bulkReadError(n, err)
+			break
+		}
+	}
+	results.write(map[string]BulkPostSummary{"summary": summary})
+}
+
+var errBulkLineTooLong = fmt.Errorf("line longer than %d bytes", maxBodyBytes)
+
+// readBulkLine returns the next line of body without its newline. At the
+// end of the body it returns the last line, if any, with io.EOF.
+func readBulkLine(body *bufio.Reader) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := body.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > maxBodyBytes {
+			return nil, errBulkLineTooLong
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		return bytes.TrimSuffix(line, []byte("\n")), err
+	}
+}
+
+// bulkReadError describes an error reading line n of a bulk body.
+func bulkReadError(n int, err error) string {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return fmt.Sprintf("line %d: request body too large", n)
+	}
+	return fmt.Sprintf("line %d: %v", n, err)
+}
+
+// bulkCreatePost decodes line n of a bulk body and creates its post.
+func (srv *Server) bulkCreatePost(r *http.Request, n int, line []byte) BulkPostResult {
+	stop := phaseTimerFrom(r.Context()).time("decode")
+	var post Post
+	err := decodeNamed(jsonCodec{}, bytes.NewReader(line), &post, requestFieldNaming(r))
+	stop()
+	if err != nil {
+		return BulkPostResult{Line: n, Status: http.StatusBadRequest, Error: err.Error()}
+	}
+	if msg := validateBulkPost(post); msg != "" {
+		return BulkPostResult{Line: n, Status: http.StatusBadRequest, Error: msg}
+	}
+
+	created, err := srv.requestStore(r).CreatePost(post)
+	if errors.Is(err, errDuplicate) {
+		if created.UserID != post.UserID || created.Title != post.Title {
+			return BulkPostResult{Line: n, Status: http.StatusConflict, Error: fmt.Sprintf("post %d already has this slug", created.ID)}
+		}
+		return BulkPostResult{Line: n, Status: http.StatusConflict, Error: fmt.Sprintf("user %d already has post %d with this title", post.UserID, created.ID)}
+	}
+	if errors.Is(err, errUnavailable) {
+		return BulkPostResult{Line: n, Status: http.StatusServiceUnavailable, Error: "store unavailable"}
+	}
+	if err != nil {
+		return BulkPostResult{Line: n, Status: http.StatusInternalServerError, Error: "internal error"}
+	}
+	return BulkPostResult{Line: n, Status: http.StatusCreated, Post: &created}
+}
+
+// validateBulkPost returns why p cannot be created, or "". IDs and versions
+// are assigned by the store, so a line may not set them.
+func validateBulkPost(p Post) string {
+	switch {
+	case p.ID != 0 || p.Version != 0:
+		return "id and version are assigned by the server"
+	case p.UserID <= 0:
+		return "userId is required"
+	case strings.TrimSpace(p.Title) == "":
+		return "title is required"
+	case p.Slug != "" && !slugPattern.MatchString(p.Slug):
+		return "slug must be lowercase letters and digits joined by single hyphens"
+	}
+	return ""
+}
+
+// ndjsonWriter writes one JSON value per line to w, flushing each so
+// clients see results as they are produced. Values are renamed per
+// JSON_NAMING, as writeJSON does.
+type ndjsonWriter struct {
+	w   http.ResponseWriter
+	enc *json.Encoder
+}
+
+func newNDJSONWriter(w http.ResponseWriter) *ndjsonWriter {
+	return &ndjsonWriter{w: w, enc: json.NewEncoder(w)}
+}
+
+func (nw *ndjsonWriter) write(v interface{}) {
+	stop := writerPhaseTimer(nw.w).time("encode")
+	nw.enc.Encode(renamed(v, writerFieldNaming(nw.w)))
+	stop()
+	if f, ok := nw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newBulkPostsRequest returns a POST /posts/bulk request with a JSON Lines
+// body.
+func newBulkPostsRequest(body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/posts/bulk", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	return req
+}
+
+// readBulkResponse splits a POST /posts/bulk response into its results and
+// summary.
+func readBulkResponse(t *testing.T, w *httptest.ResponseRecorder) ([]BulkPostResult, BulkPostSummary) {
+	t.Helper()
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+	lines := []string{}
+	sc := bufio.NewScanner(w.Body)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	require.NotEmpty(t, lines)
+
+	results := make([]BulkPostResult, len(lines)-1)
+	for i, line := range lines[:len(lines)-1] {
+		require.NoError(t, json.Unmarshal([]byte(line), &results[i]), line)
+	}
+	var last struct {
+		Summary *BulkPostSummary `json:"summary"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(lines[len(lines)-1]), &last))
+	require.NotNil(t, last.Summary, "the last line is the summary")
+	return results, *last.Summary
+}
+
+// ========== Bulk Post Tests ==========
+
+func TestBulkCreatePosts_Results(t *testing.T) {
+	router := setupRouter()
+	body := strings.Join([]string{
+		`{"userId":2,"title":"Bulk one","body":"a"}`,
+		``,
+		`{"userId":1,"title":"First Post"}`,
+		`{"userId":1,"title":`,
+		`{"userId":1,"title":"Bulk two","tags":["x"]}`,
+		`{"userId":1}`,
+		`{"id":7,"userId":1,"title":"Bulk three"}`,
+		`{"userId":2,"title":"Bulk one"} {}`,
+		`{"userId":1,"title":"Bulk five","slug":"Bad Slug"}`,
+		`{"userId":2,"title":"Bulk six","slug":"second-post"}`,
+		`{"userId":1,"title":"Bulk four"}`,
+	}, "\n")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newBulkPostsRequest(body))
+	results, summary := readBulkResponse(t, w)
+
+	require.Len(t, results, 10, "blank lines have no result")
+	tests := []struct {
+		line   int
+		status int
+		error  string
+	}{
+		{1, http.StatusCreated, ""},
+		{3, http.StatusConflict, "user 1 already has post 1 with this title"},
+		{4, http.StatusBadRequest, "unexpected EOF"},
+		{5, http.StatusBadRequest, `json: unknown field "tags"`},
+		{6, http.StatusBadRequest, "title is required"},
+		{7, http.StatusBadRequest, "id and version are assigned by the server"},
+		{8, http.StatusBadRequest, "unexpected data after JSON value"},
+		{9, http.StatusBadRequest, "slug must be lowercase letters and digits joined by single hyphens"},
+		{10, http.StatusConflict, "post 2 already has this slug"},
+		{11, http.StatusCreated, ""},
+	}
+	for i, tt := range tests {
+		assert.Equal(t, tt.line, results[i].Line)
+		assert.Equal(t, tt.status, results[i].Status, "line %d", tt.line)
+		assert.Equal(t, tt.error, results[i].Error, "line %d", tt.line)
+	}
+	require.NotNil(t, results[0].Post)
+	assert.Equal(t, "Bulk one", results[0].Post.Title)
+	assert.NotZero(t, results[0].Post.ID)
+	assert.Nil(t, results[1].Post)
+
+	assert.Equal(t, BulkPostSummary{Lines: 10, Created: 2, Duplicates: 2, Errors: 6}, summary)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/posts/"+strconv.Itoa(results[9].Post.ID), nil))
+	assert.Equal(t, http.StatusOK, w.Code, "created posts are stored")
+}
+
+func TestBulkCreatePosts_Empty(t *testing.T) {
+	router := setupRouter()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newBulkPostsRequest("\n\n"))
+	results, summary := readBulkResponse(t, w)
+	assert.Empty(t, results)
+	assert.Equal(t, BulkPostSummary{}, summary)
+}
+
+func TestBulkCreatePosts_UnsupportedMediaType(t *testing.T) {
+	router := setupRouter()
+	req := newBulkPostsRequest(`{"userId":1,"title":"JSON"}`)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
+
+func TestReadBulkLine(t *testing.T) {
+	body := bufio.NewReaderSize(strings.NewReader("one\n"+strings.Repeat("x", 40)+"\nlast"), 16)
+
+	line, err := readBulkLine(body)
+	require.NoError(t, err)
+	assert.Equal(t, "one", string(line))
+	line, err = readBulkLine(body)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("x", 40), string(line), "lines may be longer than the reader's buffer")
+	line, err = readBulkLine(body)
+	assert.Equal(t, "last", string(line))
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestBulkReadError(t *testing.T) {
+	assert.Equal(t, "line 3: request body too large", bulkReadError(3, &http.MaxBytesError{Limit: 10}))
+	assert.Equal(t, "line 2: "+errBulkLineTooLong.Error(), bulkReadError(2, errBulkLineTooLong))
+}
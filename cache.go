@@ -0,0 +1,28 @@
+package main
+
+import "net/http"
+
+// Cache-Control policies for RouteDef.CacheControl.
+const (
+	// cacheNoStore is for routes behind credentials and for live
+	// operational data: admin, tenant, webhook and ingest routes, stats and
+	// health.
+	cacheNoStore = "no-store"
+	// cachePublic is for collections, which shared caches may serve for a
+	// minute.
+	cachePublic = "public, max-age=60"
+	// cachePrivate is for single entities and data about one user, which
+	// only the client's own cache may keep.
+	cachePrivate = "private"
+)
+
+// cacheControl returns middleware setting Cache-Control to policy. Error
+// responses replace it with no-store; see writeJSON.
+func cacheControl(policy string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", policy)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
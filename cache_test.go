@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ========== Cache-Control Tests ==========
+
+func TestCacheControl_PerRouteGroup(t *testing.T) {
+	router := setupAdminRouter(t)
+
+	tests := []struct {
+		name     string
+		req      *http.Request
+		expected string
+	}{
+		{"collection", httptest.NewRequest(http.MethodGet, "/users", nil), "public, max-age=60"},
+		{"nested collection", httptest.NewRequest(http.MethodGet, "/albums/1/photos", nil), "public, max-age=60"},
+		{"entity", httptest.NewRequest(http.MethodGet, "/posts/1", nil), "private"},
+		{"user-specific", httptest.NewRequest(http.MethodGet, "/users/1/posts", nil), "private"},
+		{"admin", newAdminRequest(http.MethodGet, "/admin/flags", ""), "no-store"},
+		{"tenants", newAdminRequest(http.MethodGet, "/tenants", ""), "no-store"},
+		{"stats", httptest.NewRequest(http.MethodGet, "/stats", nil), "no-store"},
+		{"health", httptest.NewRequest(http.MethodGet, "/health", nil), "no-store"},
+		{"not found", httptest.NewRequest(http.MethodGet, "/posts/999", nil), "no-store"},
+		{"unauthorized", httptest.NewRequest(http.MethodGet, "/admin/flags", nil), "no-store"},
+		{"write", httptest.NewRequest(http.MethodDelete, "/posts/1", nil), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, tt.req)
+			assert.Equal(t, tt.expected, w.Header().Get("Cache-Control"))
+		})
+	}
+}
+
+func TestCacheControl_EveryGETRouteHasPolicy(t *testing.T) {
+	policies := []string{cacheNoStore, cachePublic, cachePrivate}
+	for _, d := range append(server.apiRouteDefs(), server.opsRouteDefs()...) {
+		name := d.Method + " " + d.Pattern
+		if d.Method == http.MethodGet {
+			assert.Contains(t, policies, d.CacheControl, name)
+		} else {
+			assert.Empty(t, d.CacheControl, "%s: only reads are cacheable", name)
+		}
+	}
+}
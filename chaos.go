@@ -0,0 +1,93 @@
+package main
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Per-request chaos overrides. They are only honored when chaos is enabled.
+const (
+	// headerChaosLatency forces a delay, e.g. "250ms".
+	headerChaosLatency = "X-Chaos-Latency"
+	// headerChaosError forces an error response with the given 5xx status.
+	headerChaosError = "X-Chaos-Error"
+	// headerChaosDrop forces the connection to be dropped when "true".
+	headerChaosDrop = "X-Chaos-Drop"
+	// headerChaosInjected is set on responses that had a fault injected.
+	headerChaosInjected = "X-Chaos-Injected"
+)
+
+// chaos injects latency, errors, and dropped connections into requests so
+// clients can exercise their failure handling.
+type chaos struct {
+	cfg ChaosConfig
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// newChaos returns the chaos middleware. seed makes the random faults
+// reproducible.
+func newChaos(cfg ChaosConfig, seed int64) func(http.Handler) http.Handler {
+	c := &chaos{cfg: cfg, rng: rand.New(rand.NewSource(seed))}
+	return c.middleware
+}
+
+// roll reports whether an event with probability rate happens.
+func (c *chaos) roll(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rng.Float64() < rate
+}
+
+func (c *chaos) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		latency := time.Duration(0)
+		if c.roll(c.cfg.LatencyRate) {
+			latency = c.cfg.Latency
+		}
+		if v := r.Header.Get(headerChaosLatency); v != "" {
+			if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+				latency = d
+			}
+		}
+		if latency > 0 {
+			w.Header().Add(headerChaosInjected, "latency")
+			timer := time.NewTimer(latency)
+			select {
+			case <-timer.C:
+			case <-r.Context().Done():
+				timer.Stop()
+				return
+			}
+		}
+
+		if r.Header.Get(headerChaosDrop) == "true" || c.roll(c.cfg.DropRate) {
+			// net/http closes the connection without writing a response.
+			panic(http.ErrAbortHandler)
+		}
+
+		status := 0
+		if c.roll(c.cfg.ErrorRate) {
+			status = http.StatusInternalServerError
+		}
+		if v := r.Header.Get(headerChaosError); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n >= 500 && n <= 599 {
+				status = n
+			}
+		}
+		if status != 0 {
+			w.Header().Add(headerChaosInjected, "error")
+			respondError(w, r, status, "injected failure")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
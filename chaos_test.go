@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupChaosRouter(cfg ChaosConfig) http.Handler {
+	setupRouter()
+	return server.newRouter(newChaos(cfg, 1))
+}
+
+// ========== Chaos Middleware Tests ==========
+
+func TestChaos_ZeroRatesPassThrough(t *testing.T) {
+	router := setupChaosRouter(ChaosConfig{Enabled: true})
+
+	for i := 0; i < 50; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/health", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get(headerChaosInjected))
+	}
+}
+
+func TestChaos_ErrorRate(t *testing.T) {
+	router := setupChaosRouter(ChaosConfig{Enabled: true, ErrorRate: 1})
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "error", w.Header().Get(headerChaosInjected))
+	assertJSONContentType(t, w)
+
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "injected failure", body["error"])
+}
+
+func TestChaos_ErrorRateIsApproximate(t *testing.T) {
+	router := setupChaosRouter(ChaosConfig{Enabled: true, ErrorRate: 0.5})
+
+	failures := 0
+	for i := 0; i < 400; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/health", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code == http.StatusInternalServerError {
+			failures++
+		}
+	}
+	assert.InDelta(t, 200, failures, 50)
+}
+
+func TestChaos_ErrorHeader(t *testing.T) {
+	router := setupChaosRouter(ChaosConfig{Enabled: true})
+
+	tests := []struct {
+		name     string
+		value    string
+		expected int
+	}{
+		{"service unavailable", "503", http.StatusServiceUnavailable},
+		{"bad gateway", "502", http.StatusBadGateway},
+		{"non-5xx ignored", "404", http.StatusOK},
+		{"malformed ignored", "boom", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/health", nil)
+			req.Header.Set(headerChaosError, tt.value)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expected, w.Code)
+		})
+	}
+}
+
+func TestChaos_LatencyHeader(t *testing.T) {
+	router := setupChaosRouter(ChaosConfig{Enabled: true})
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.Header.Set(headerChaosLatency, "50ms")
+	w := httptest.NewRecorder()
+
+	start := time.Now()
+	router.ServeHTTP(w, req)
+
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "latency", w.Header().Get(headerChaosInjected))
+}
+
+func TestChaos_LatencyRate(t *testing.T) {
+	router := setupChaosRouter(ChaosConfig{Enabled: true, LatencyRate: 1, Latency: 20 * time.Millisecond})
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	w := httptest.NewRecorder()
+
+	start := time.Now()
+	router.ServeHTTP(w, req)
+
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestChaos_DropHeader(t *testing.T) {
+	router := setupChaosRouter(ChaosConfig{Enabled: true})
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.Header.Set(headerChaosDrop, "true")
+	w := httptest.NewRecorder()
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		router.ServeHTTP(w, req)
+	})
+}
+
+func TestChaos_DropClosesConnection(t *testing.T) {
+	setupRouter()
+	srv := httptest.NewServer(server.newRouter(newChaos(ChaosConfig{Enabled: true, DropRate: 1}, 1)))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/health")
+	if err == nil {
+		resp.Body.Close()
+	}
+	require.Error(t, err)
+}
@@ -0,0 +1,30 @@
+package main
+
+import "time"
+
+// Clock tells the time. The store stamps entities with it and the server
+// counts quotas and metrics and expires tokens and invites by it, so tests
+// can freeze it to keep timestamps, and the golden files that record them,
+// stable.
+type Clock interface {
+	Now() time.Time
+}
+
+// serverClock reads whichever Clock its server has at the time, so what
+// is built from the server follows a clock swapped in later.
+type serverClock struct{ srv *Server }
+
+func (c serverClock) Now() time.Time { return c.srv.Clock.Now() }
+
+// useClock has s, and any store it wraps, stamp entities by the server's
+// Clock.
+func (srv *Server) useClock(s Store) {
+	if cs, ok := s.(interface{ setClock(Clock) }); ok {
+		cs.setClock(serverClock{srv})
+	}
+}
+
+// systemClock reads the wall clock.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== Timestamp Tests ==========
+
+func TestTimestamps_FollowTheClock(t *testing.T) {
+	router := setupRouter()
+	clock := freezeClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":"Carol","email":"carol@example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "2024-03-01T12:00:00Z", created["createdAt"])
+	assert.Equal(t, "2024-03-01T12:00:00Z", created["updatedAt"])
+
+	clock.t = clock.t.Add(time.Hour)
+	req = httptest.NewRequest(http.MethodPut, "/users/3", strings.NewReader(`{"name":"Caroline","email":"carol@example.com","version":1,"createdAt":"2000-01-01T00:00:00Z"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var updated map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+	assert.Equal(t, "2024-03-01T12:00:00Z", updated["createdAt"], "creation times are ignored on writes")
+	assert.Equal(t, "2024-03-01T13:00:00Z", updated["updatedAt"])
+}
+
+func TestTokens_FollowTheClock(t *testing.T) {
+	router := setupAdminRouter(t)
+	useAuthSecret(t)
+	useTestMailer(t)
+	clock := freezeClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	invite := createTestInvite(t, router, "carol@example.com")
+	assert.Equal(t, clock.t.Add(inviteTTL), invite.ExpiresAt)
+	verification := server.verificationToken(1, "alice@example.com")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/invites/"+invite.Token, nil))
+	assert.Equal(t, http.StatusOK, w.Code, "tokens issued by a clock in the past hold while it stands still")
+
+	clock.t = clock.t.Add(inviteTTL)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/invites/"+invite.Token, nil))
+	assert.Equal(t, http.StatusGone, w.Code)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/verify?token="+verification, nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRestoredTimes(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	earlier := now.Add(-time.Hour)
+
+	created, updated := restoredTimes(earlier, earlier, now)
+	assert.Equal(t, []time.Time{earlier, earlier}, []time.Time{created, updated}, "timestamps in the state are kept")
+	created, updated = restoredTimes(earlier, time.Time{}, now)
+	assert.Equal(t, []time.Time{earlier, now}, []time.Time{created, updated})
+	created, updated = restoredTimes(time.Time{}, time.Time{}, now)
+	assert.Equal(t, []time.Time{now, now}, []time.Time{created, updated})
+}
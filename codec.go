@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ugorji/go/codec"
+
+	"github.com/api2spec/api2spec-fixture-chi/ctxkit"
+)
+
+// Codec reads and writes request and response bodies in one media type.
+// Encode is given the value a handler responded with, already renamed per
+// JSON_NAMING for codecs that follow the JSON representation.
+// Handlers never see a codec: decodeJSON decodes with the request's codec
+// and respondJSON encodes with the response's, both chosen per route by
+// negotiateCodec.
+type Codec interface {
+	MediaType() string
+	Encode(w io.Writer, v interface{}) error
+	// Decode reads a single value from r into v. Like JSON decoding, it
+	// rejects unknown fields and trailing data.
+	Decode(r io.Reader, v interface{}) error
+}
+
+// jsonCodec is the default codec, used by every route.
+type jsonCodec struct{}
+
+func (jsonCodec) MediaType() string { return "application/json" }
+
+func (jsonCodec) Encode(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (jsonCodec) Decode(r io.Reader, v interface{}) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	return decodeOne(dec, v)
+}
+
+// decodeTree reads a single value from r as maps, slices and scalars,
+// keeping numbers as written.
+func (jsonCodec) decodeTree(r io.Reader) (interface{}, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var tree interface{}
+	err := decodeOne(dec, &tree)
+	return tree, err
+}
+
+// decodeOne decodes a single value from dec into v, rejecting any data
+// after it.
+func decodeOne(dec *json.Decoder, v interface{}) error {
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if err := dec.Decode(&json.RawMessage{}); err != io.EOF {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return err
+		}
+		return errors.New("unexpected data after JSON value")
+	}
+	return nil
+}
+
+// bridgeCodec is a binary codec for the JSON data model. Values are
+// converted through their JSON form, so a body has exactly the members,
+// names and schema of the JSON body it stands for, JSON_NAMING included,
+// and decoding is as strict as JSON's.
+type bridgeCodec struct {
+	mediaType string
+	handle    codec.Handle
+}
+
+func (c bridgeCodec) MediaType() string { return c.mediaType }
+
+func (c bridgeCodec) Encode(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var tree interface{}
+	if err := dec.Decode(&tree); err != nil {
+		return err
+	}
+	return codec.NewEncoder(w, c.handle).Encode(jsonNumbers(tree))
+}
+
+func (c bridgeCodec) Decode(r io.Reader, v interface{}) error {
+	tree, err := c.decodeTree(r)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	return jsonCodec{}.Decode(bytes.NewReader(data), v)
+}
+
+// decodeTree reads a single value from r as maps, slices and scalars.
+func (c bridgeCodec) decodeTree(r io.Reader) (interface{}, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var tree interface{}
+	dec := codec.NewDecoderBytes(data, c.handle)
+	if err := dec.Decode(&tree); err != nil {
+		return nil, err
+	}
+	if dec.NumBytesRead() != len(data) {
+		return nil, errors.New("unexpected data after " + c.mediaType + " value")
+	}
+	return tree, nil
+}
+
+// jsonNumbers replaces the json.Numbers in tree with int64s, or float64s
+// for numbers that are not integers.
+func jsonNumbers(tree interface{}) interface{} {
+	switch v := tree.(type) {
+	case map[string]interface{}:
+		for k, member := range v {
+			v[k] = jsonNumbers(member)
+		}
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = jsonNumbers(elem)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return tree
+}
+
+var treeMapType = reflect.TypeOf(map[string]interface{}(nil))
+
+func newMsgpackCodec() bridgeCodec {
+	h := &codec.MsgpackHandle{WriteExt: true}
+	h.RawToString = true
+	h.MapType = treeMapType
+	h.Canonical = true
+	return bridgeCodec{mediaType: "application/msgpack", handle: h}
+}
+
+var msgpackCodec = newMsgpackCodec()
+
+// newCBORCodec returns the RFC 8949 codec. Map keys are written sorted, so
+// a value always encodes the same way.
+func newCBORCodec() bridgeCodec {
+	h := &codec.CborHandle{}
+	h.MapType = treeMapType
+	h.Canonical = true
+	return bridgeCodec{mediaType: "application/cbor", handle: h}
+}
+
+var cborCodec = newCBORCodec()
+
+// bodyCodecs are the formats, besides JSON, that user and post routes
+// negotiate.
+var bodyCodecs = []Codec{msgpackCodec, cborCodec}
+
+// negotiatedCodecs is the pair of codecs negotiateCodec chose for a
+// request.
+type negotiatedCodecs struct {
+	request, response Codec
+}
+
+// codecKey carries the codecs negotiateCodec chose.
+var codecKey = ctxkit.NewKey[negotiatedCodecs]("codecs")
+
+// requestCodec returns the codec for r's body: the one negotiateCodec
+// chose from its Content-Type, or JSON.
+func requestCodec(r *http.Request) Codec {
+	if nc, ok := codecKey.From(r.Context()); ok {
+		return nc.request
+	}
+	return jsonCodec{}
+}
+
+// negotiateCodec picks the codecs of a route that offers codecs besides
+// JSON: the request body's from its Content-Type, and the response's from
+// Accept. Either falls back to JSON when the header names none of them.
+func negotiateCodec(codecs []Codec) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			nc := negotiatedCodecs{request: jsonCodec{}, response: jsonCodec{}}
+			if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil {
+				for _, c := range codecs {
+					if c.MediaType() == mediaType {
+						nc.request = c
+					}
+				}
+			}
+			nc.response = acceptCodec(r.Header.Get("Accept"), codecs)
+			w.Header().Add("Vary", "Accept")
+			next.ServeHTTP(codecWriter{ResponseWriter: w, codec: nc.response}, r.WithContext(codecKey.With(r.Context(), nc)))
+		})
+	}
+}
+
+// acceptCodec returns the codec an Accept header prefers among JSON and
+// codecs; of media ranges with the same q, the first listed wins.
+func acceptCodec(header string, codecs []Codec) Codec {
+	type candidate struct {
+		mediaType string
+		q         float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		mediaType := strings.ToLower(strings.TrimSpace(fields[0]))
+		if mediaType == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if q, _ = strconv.ParseFloat(v, 64); q < 0 {
+					q = 0
+				}
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{mediaType, q})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	for _, c := range candidates {
+		switch c.mediaType {
+		case "application/json", "application/*", "*/*":
+			return jsonCodec{}
+		}
+		for _, bc := range codecs {
+			if bc.MediaType() == c.mediaType {
+				return bc
+			}
+		}
+	}
+	return jsonCodec{}
+}
+
+// codecWriter carries the response codec negotiateCodec chose to
+// respondJSON.
+type codecWriter struct {
+	http.ResponseWriter
+	codec Codec
+}
+
+func (w codecWriter) responseCodec() Codec { return w.codec }
+
+func (w codecWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w codecWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// responseCodec returns the codec for w's JSON bodies: the one
+// negotiateCodec chose, or JSON.
+func responseCodec(w http.ResponseWriter) Codec {
+	for {
+		if cw, ok := w.(interface{ responseCodec() Codec }); ok {
+			return cw.responseCodec()
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return jsonCodec{}
+		}
+		w = u.Unwrap()
+	}
+}
+
+// requestMediaTypes lists the media types d's request body may have: JSON
+// and d's codecs for a JSON body, or the media types d names.
+func (d RouteDef) requestMediaTypes() []string {
+	switch body := d.RequestType.(type) {
+	case nil:
+		return nil
+	case MediaType:
+		return []string{string(body)}
+	case MediaTypes:
+		types := make([]string, len(body))
+		for i, mt := range body {
+			types[i] = string(mt)
+		}
+		return types
+	}
+	types := []string{jsonCodec{}.MediaType()}
+	for _, c := range d.Codecs {
+		types = append(types, c.MediaType())
+	}
+	return types
+}
+
+// requireContentType answers 415 Unsupported Media Type, listing
+// supported, to requests whose Content-Type is missing or not one of
+// supported.
+func requireContentType(supported []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil {
+				for _, t := range supported {
+					if t == mediaType {
+						next.ServeHTTP(w, r)
+						return
+					}
+				}
+			}
+			respondProblem(w, r, http.StatusUnsupportedMediaType, "unsupported media type", Problem{Supported: supported})
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// encodeBody encodes v with c for a request body.
+func encodeBody(t *testing.T, c Codec, v interface{}) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, c.Encode(&buf, v))
+	return buf.Bytes()
+}
+
+// newCodecRequest returns a request whose body is body in c's format and
+// that accepts responses in accept.
+func newCodecRequest(method, path string, c Codec, body []byte, accept string) *http.Request {
+	req := httptest.NewRequest(method, path, bytes.NewReader(body))
+	if body != nil {
+		req.Header.Set("Content-Type", c.MediaType())
+	}
+	req.Header.Set("Accept", accept)
+	return req
+}
+
+// ========== Codec Tests ==========
+
+func TestAcceptCodec(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected string
+	}{
+		{"", "application/json"},
+		{"application/msgpack", "application/msgpack"},
+		{"application/json, application/msgpack", "application/json"},
+		{"application/json;q=0.5, application/msgpack", "application/msgpack"},
+		{"application/msgpack;q=0, */*", "application/json"},
+		{"text/html", "application/json"},
+		{"*/*;q=0.1, application/msgpack;q=0.9", "application/msgpack"},
+		{"application/cbor, application/json;q=0.9", "application/cbor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			assert.Equal(t, tt.expected, acceptCodec(tt.header, bodyCodecs).MediaType())
+		})
+	}
+}
+
+func TestBridgeCodec_RoundTrip(t *testing.T) {
+	user := User{ID: 7, Name: "Zoë", Email: "zoe@example.com", Version: 3, PostCount: 2}
+	var got User
+	require.NoError(t, msgpackCodec.Decode(bytes.NewReader(encodeBody(t, msgpackCodec, user)), &got))
+	assert.Equal(t, user, got)
+
+	posts := []Post{{ID: 1, UserID: 1, Title: "A"}, {ID: 2, UserID: 1, Title: "B", Body: "b"}}
+	var gotPosts []Post
+	require.NoError(t, msgpackCodec.Decode(bytes.NewReader(encodeBody(t, msgpackCodec, posts)), &gotPosts))
+	assert.Equal(t, posts, gotPosts)
+}
+
+func TestBridgeCodec_Strict(t *testing.T) {
+	var user User
+	body := encodeBody(t, msgpackCodec, map[string]interface{}{"name": "A", "email": "a@example.com", "role": "admin"})
+	assert.ErrorContains(t, msgpackCodec.Decode(bytes.NewReader(body), &user), `unknown field "role"`)
+
+	body = encodeBody(t, msgpackCodec, map[string]interface{}{"name": "A"})
+	body = append(body, 0xc0)
+	assert.ErrorContains(t, msgpackCodec.Decode(bytes.NewReader(body), &user), "unexpected data after application/msgpack value")
+
+	assert.Error(t, msgpackCodec.Decode(bytes.NewReader([]byte{0xc1}), &user), "0xc1 is never used")
+}
+
+func TestMsgpack_CreateAndGetUser(t *testing.T) {
+	router := setupRouter()
+	body := encodeBody(t, msgpackCodec, map[string]interface{}{"name": "Mia", "email": "mia@example.com"})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newCodecRequest(http.MethodPost, "/users", msgpackCodec, body, "application/msgpack"))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, "application/msgpack", w.Header().Get("Content-Type"))
+	assert.Equal(t, "Accept", w.Header().Get("Vary"))
+	var created User
+	require.NoError(t, msgpackCodec.Decode(w.Body, &created))
+	assert.Equal(t, "Mia", created.Name)
+	assert.NotZero(t, created.ID)
+
+	location := w.Header().Get("Location")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newCodecRequest(http.MethodGet, location, nil, nil, "application/msgpack"))
+	require.Equal(t, http.StatusOK, w.Code)
+	var user User
+	require.NoError(t, msgpackCodec.Decode(w.Body, &user))
+	assert.Equal(t, created, user)
+}
+
+func TestMsgpack_JSONFallbacks(t *testing.T) {
+	tests := []struct {
+		name        string
+		req         *http.Request
+		status      int
+		contentType string
+	}{
+		{"json body, msgpack response", newCodecRequest(http.MethodPost, "/posts", jsonCodec{}, []byte(`{"userId":1,"title":"Mixed"}`), "application/msgpack"), http.StatusCreated, "application/msgpack"},
+		{"msgpack body, json response", newCodecRequest(http.MethodPost, "/posts", msgpackCodec, encodeBody(t, msgpackCodec, Post{UserID: 1, Title: "Mixed"}), ""), http.StatusCreated, "application/json"},
+		{"errors stay json", newCodecRequest(http.MethodGet, "/users/999", nil, nil, "application/msgpack"), http.StatusNotFound, "application/problem+json"},
+		{"routes without codecs", newCodecRequest(http.MethodGet, "/albums", nil, nil, "application/msgpack"), http.StatusOK, "application/json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, tt.req)
+			require.Equal(t, tt.status, w.Code, w.Body.String())
+			assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+		})
+	}
+}
+
+func TestMsgpack_FieldNaming(t *testing.T) {
+	router := setupRouter()
+	useFieldNaming(t, "snake")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newCodecRequest(http.MethodGet, "/posts/1", nil, nil, "application/msgpack"))
+	require.Equal(t, http.StatusOK, w.Code)
+	var post map[string]interface{}
+	require.NoError(t, msgpackCodec.Decode(w.Body, &post))
+	assert.Contains(t, post, "user_id")
+	assert.NotContains(t, post, "userId")
+}
+
+func TestCBOR_Encoding(t *testing.T) {
+	body := encodeBody(t, cborCodec, map[string]interface{}{"title": "A", "id": 1, "tags": []string{}, "due": nil, "done": true, "ratio": 0.5})
+	// A map of 6 pairs, with sorted keys: "done", a text string of 4 bytes,
+	// comes first.
+	assert.Equal(t, []byte{0xa6, 0x64, 'd', 'o', 'n', 'e', 0xf5}, body[:7])
+
+	var got map[string]interface{}
+	require.NoError(t, cborCodec.Decode(bytes.NewReader(body), &got))
+	assert.Equal(t, map[string]interface{}{"title": "A", "id": float64(1), "tags": []interface{}{}, "due": nil, "done": true, "ratio": 0.5}, got)
+}
+
+func TestCBOR_CreateAndGetPost(t *testing.T) {
+	router := setupRouter()
+	body := encodeBody(t, cborCodec, Post{UserID: 1, Title: "Constrained", Body: "Device"})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newCodecRequest(http.MethodPost, "/users/1/posts", cborCodec, body, "application/cbor"))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, "application/cbor", w.Header().Get("Content-Type"))
+	var created Post
+	require.NoError(t, cborCodec.Decode(w.Body, &created))
+	assert.Equal(t, "Constrained", created.Title)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newCodecRequest(http.MethodGet, "/users/1/posts", nil, nil, "application/cbor"))
+	require.Equal(t, http.StatusOK, w.Code)
+	var posts []Post
+	require.NoError(t, cborCodec.Decode(w.Body, &posts))
+	assert.Contains(t, posts, created)
+
+	body = append(encodeBody(t, cborCodec, Post{UserID: 1, Title: "Trailing"}), 0xf6)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newCodecRequest(http.MethodPost, "/posts", cborCodec, body, "application/cbor"))
+	assert.Equal(t, http.StatusBadRequest, w.Code, "trailing data is rejected")
+}
+
+// ========== Content-Type Tests ==========
+
+func TestRequireContentType(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		expected    int
+		supported   []string
+	}{
+		{"json", http.MethodPost, "/users", "application/json", http.StatusCreated, nil},
+		{"json with charset", http.MethodPost, "/users", "application/json; charset=utf-8", http.StatusCreated, nil},
+		{"missing", http.MethodPost, "/users", "", http.StatusUnsupportedMediaType, []string{"application/json", "application/msgpack", "application/cbor"}},
+		{"text", http.MethodPut, "/users/1", "text/plain", http.StatusUnsupportedMediaType, []string{"application/json", "application/msgpack", "application/cbor"}},
+		{"json-only route", http.MethodPost, "/todos", "application/msgpack", http.StatusUnsupportedMediaType, []string{"application/json"}},
+		{"post codecs", http.MethodPost, "/posts", "application/xml", http.StatusUnsupportedMediaType, []string{"application/json", "application/msgpack", "application/cbor", "application/x-protobuf"}},
+		{"malformed", http.MethodPost, "/posts", "application/", http.StatusUnsupportedMediaType, []string{"application/json", "application/msgpack", "application/cbor", "application/x-protobuf"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(`{"name":"Carol"}`))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tt.expected, w.Code, w.Body.String())
+			if tt.supported == nil {
+				return
+			}
+			assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+			var problem Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			assert.Equal(t, tt.supported, problem.Supported)
+		})
+	}
+}
+
+func TestRequireContentType_BeforeAuth(t *testing.T) {
+	router := setupRouter()
+
+	// Credentials are checked before the body's media type.
+	req := httptest.NewRequest(http.MethodPost, "/tenants", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "text/plain")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// postmanSchema is the Postman collection format /postman.json is in.
+const postmanSchema = "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+
+// collectionName names the exported collections and Insomnia workspace.
+const collectionName = "api2spec-fixture-chi"
+
+// exportRequest is one route of the API as the client collections list it.
+type exportRequest struct {
+	Tag, Name, Description string
+	Method                 string
+	// Path is the route's pattern, with {name} path parameters.
+	Path       string
+	PathParams []exportParam
+	// Query lists the documented query parameters, sent disabled.
+	Query []exportParam
+	// MediaType and Body are the request body, if the route takes one;
+	// Body is empty for bodies that are not JSON.
+	MediaType, Body string
+}
+
+// exportParam is a parameter with a value to start from.
+type exportParam struct {
+	Name, Value, Description string
+}
+
+// exportRequests lists the public API's routes, grouped by tag, with path
+// parameters, query parameters and example bodies taken from doc: each
+// body is the one a mock of doc would send, its example or a value made up
+// from its schema.
+func exportRequests(defs []RouteDef, doc *openapi3.T) []exportRequest {
+	reqs := make([]exportRequest, 0, len(defs))
+	for _, d := range defs {
+		req := exportRequest{Tag: d.Tag, Name: d.Summary, Method: d.Method, Path: d.Pattern}
+		var op *openapi3.Operation
+		if item := doc.Paths.Find(d.Pattern); item != nil {
+			op = item.GetOperation(d.Method)
+		}
+		if op != nil {
+			req.Description = op.Description
+			for _, p := range op.Parameters {
+				if p.Value == nil || (p.Value.In != openapi3.ParameterInPath && p.Value.In != openapi3.ParameterInQuery) {
+					continue
+				}
+				param := exportParam{Name: p.Value.Name, Description: p.Value.Description}
+				if v := mockValue(p.Value.Schema, 0); v != nil {
+					param.Value = fmt.Sprint(v)
+				}
+				if p.Value.In == openapi3.ParameterInPath {
+					req.PathParams = append(req.PathParams, param)
+				} else {
+					req.Query = append(req.Query, param)
+				}
+			}
+			if op.RequestBody != nil && op.RequestBody.Value != nil {
+				mediaType, media := mockMediaType(op.RequestBody.Value.Content)
+				req.MediaType = mediaType
+				if media != nil && strings.Contains(mediaType, "json") {
+					if body, err := json.MarshalIndent(mockExample(media, ""), "", "  "); err == nil {
+						req.Body = string(body)
+					}
+				}
+			}
+		}
+		reqs = append(reqs, req)
+	}
+	sort.SliceStable(reqs, func(i, j int) bool { return reqs[i].Tag < reqs[j].Tag })
+	return reqs
+}
+
+// exportedRequests is the public API's routes, for a handler of r.
+func (srv *Server) exportedRequests(w http.ResponseWriter, r *http.Request) ([]exportRequest, bool) {
+	doc, err := srv.specDoc()
+	if err != nil {
+		srv.logAt("error", "parsing openapi.yaml: %v", err)
+		respondError(w, r, http.StatusInternalServerError, "internal error")
+		return nil, false
+	}
+	return exportRequests(srv.apiRouteDefs(), doc), true
+}
+
+// postmanCollection is a Postman collection, v2.1.
+type postmanCollection struct {
+	Info     postmanInfo       `json:"info"`
+	Auth     postmanAuth       `json:"auth"`
+	Variable []postmanVariable `json:"variable"`
+	Item     []postmanFolder   `json:"item"`
+}
+
+type postmanInfo struct {
+	Name   string `json:"name"`
+	Schema string `json:"schema"`
+}
+
+type postmanAuth struct {
+	Type   string            `json:"type"`
+	Bearer []postmanVariable `json:"bearer"`
+}
+
+type postmanVariable struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	Type        string `json:"type,omitempty"`
+	Description string `json:"description,omitempty"`
+	Disabled    bool   `json:"disabled,omitempty"`
+}
+
+type postmanFolder struct {
+	Name string        `json:"name"`
+	Item []postmanItem `json:"item"`
+}
+
+type postmanItem struct {
+	Name    string         `json:"name"`
+	Request postmanRequest `json:"request"`
+}
+
+type postmanRequest struct {
+	Method      string            `json:"method"`
+	Description string            `json:"description,omitempty"`
+	Header      []postmanVariable `json:"header"`
+	Body        *postmanBody      `json:"body,omitempty"`
+	URL         postmanURL        `json:"url"`
+}
+
+type postmanBody struct {
+	Mode    string          `json:"mode"`
+	Raw     string          `json:"raw"`
+	Options json.RawMessage `json:"options,omitempty"`
+}
+
+type postmanURL struct {
+	Raw      string            `json:"raw"`
+	Host     []string          `json:"host"`
+	Path     []string          `json:"path"`
+	Query    []postmanVariable `json:"query,omitempty"`
+	Variable []postmanVariable `json:"variable,omitempty"`
+}
+
+// newPostmanCollection builds a collection of reqs, a folder per tag, sent
+// to the baseUrl variable with the bearer token in the token variable.
+func newPostmanCollection(reqs []exportRequest, baseURL string) postmanCollection {
+	c := postmanCollection{
+		Info: postmanInfo{Name: collectionName, Schema: postmanSchema},
+		Auth: postmanAuth{Type: "bearer", Bearer: []postmanVariable{{Key: "token", Value: "{{token}}", Type: "string"}}},
+		Variable: []postmanVariable{
+			{Key: "baseUrl", Value: baseURL},
+			{Key: "token", Value: "", Description: "An admin or user token, for the routes that need one"},
+		},
+		Item: []postmanFolder{},
+	}
+	for _, req := range reqs {
+		if len(c.Item) == 0 || c.Item[len(c.Item)-1].Name != req.Tag {
+			c.Item = append(c.Item, postmanFolder{Name: req.Tag})
+		}
+		folder := &c.Item[len(c.Item)-1]
+
+		var segments []string
+		for _, s := range strings.Split(strings.TrimPrefix(req.Path, "/"), "/") {
+			if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+				s = ":" + s[1:len(s)-1]
+			}
+			segments = append(segments, s)
+		}
+		url := postmanURL{
+			Raw:  "{{baseUrl}}/" + strings.Join(segments, "/"),
+			Host: []string{"{{baseUrl}}"},
+			Path: segments,
+		}
+		for _, p := range req.PathParams {
+			url.Variable = append(url.Variable, postmanVariable{Key: p.Name, Value: p.Value, Description: p.Description})
+		}
+		for _, p := range req.Query {
+			url.Query = append(url.Query, postmanVariable{Key: p.Name, Value: p.Value, Description: p.Description, Disabled: true})
+		}
+
+		pr := postmanRequest{Method: req.Method, Description: req.Description, Header: []postmanVariable{}, URL: url}
+		if req.MediaType != "" {
+			pr.Header = append(pr.Header, postmanVariable{Key: "Content-Type", Value: req.MediaType})
+			pr.Body = &postmanBody{Mode: "raw", Raw: req.Body}
+			if req.Body != "" {
+				pr.Body.Options = json.RawMessage(`{"raw":{"language":"json"}}`)
+			}
+		}
+		folder.Item = append(folder.Item, postmanItem{Name: req.Name, Request: pr})
+	}
+	return c
+}
+
+// insomniaExport is an Insomnia export, format 4: a workspace holding an
+// environment, and a folder of requests per tag.
+type insomniaExport struct {
+	Type      string             `json:"_type"`
+	Format    int                `json:"__export_format"`
+	Source    string             `json:"__export_source"`
+	Resources []insomniaResource `json:"resources"`
+}
+
+// insomniaResource is any resource of an export; Type says which fields
+// it uses.
+type insomniaResource struct {
+	ID             string              `json:"_id"`
+	Type           string              `json:"_type"`
+	ParentID       string              `json:"parentId,omitempty"`
+	Name           string              `json:"name"`
+	Description    string              `json:"description,omitempty"`
+	Data           map[string]string   `json:"data,omitempty"`
+	Method         string              `json:"method,omitempty"`
+	URL            string              `json:"url,omitempty"`
+	Body           *insomniaBody       `json:"body,omitempty"`
+	Headers        []insomniaParameter `json:"headers,omitempty"`
+	Parameters     []insomniaParameter `json:"parameters,omitempty"`
+	Authentication map[string]string   `json:"authentication,omitempty"`
+}
+
+type insomniaBody struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type insomniaParameter struct {
+	Name        string `json:"name"`
+	Value       string `json:"value"`
+	Description string `json:"description,omitempty"`
+	Disabled    bool   `json:"disabled,omitempty"`
+}
+
+// newInsomniaExport builds an export of reqs. Its environment holds
+// baseUrl, token and a value for every path parameter, which the request
+// URLs refer to.
+func newInsomniaExport(reqs []exportRequest, baseURL string) insomniaExport {
+	const workspace, environment = "wrk_api2spec", "env_api2spec"
+	env := map[string]string{"baseUrl": baseURL, "token": ""}
+	e := insomniaExport{
+		Type:   "export",
+		Format: 4,
+		Source: collectionName,
+		Resources: []insomniaResource{
+			{ID: workspace, Type: "workspace", Name: collectionName},
+			{ID: environment, Type: "environment", ParentID: workspace, Name: "Base Environment", Data: env},
+		},
+	}
+	seenTags := map[string]bool{}
+	for _, req := range reqs {
+		folder := "fld_" + req.Tag
+		if !seenTags[req.Tag] {
+			seenTags[req.Tag] = true
+			e.Resources = append(e.Resources, insomniaResource{ID: folder, Type: "request_group", ParentID: workspace, Name: req.Tag})
+		}
+
+		url := req.Path
+		for _, p := range req.PathParams {
+			url = strings.ReplaceAll(url, "{"+p.Name+"}", "{{ _."+p.Name+" }}")
+			if _, ok := env[p.Name]; !ok {
+				env[p.Name] = p.Value
+			}
+		}
+		res := insomniaResource{
+			ID:             fmt.Sprintf("req_%s_%s", strings.ToLower(req.Method), strings.Trim(strings.NewReplacer("/", "_", "{", "", "}", "").Replace(req.Path), "_")),
+			Type:           "request",
+			ParentID:       folder,
+			Name:           req.Name,
+			Description:    req.Description,
+			Method:         req.Method,
+			URL:            "{{ _.baseUrl }}" + url,
+			Authentication: map[string]string{"type": "bearer", "token": "{{ _.token }}"},
+		}
+		for _, p := range req.Query {
+			res.Parameters = append(res.Parameters, insomniaParameter{Name: p.Name, Value: p.Value, Description: p.Description, Disabled: true})
+		}
+		if req.MediaType != "" {
+			res.Headers = []insomniaParameter{{Name: "Content-Type", Value: req.MediaType}}
+			res.Body = &insomniaBody{MimeType: req.MediaType, Text: req.Body}
+		}
+		e.Resources = append(e.Resources, res)
+	}
+	return e
+}
+
+// postmanHandler serves the public API as a Postman collection, sent to
+// the address the collection was fetched from.
+func (srv *Server) postmanHandler(w http.ResponseWriter, r *http.Request) {
+	reqs, ok := srv.exportedRequests(w, r)
+	if !ok {
+		return
+	}
+	respondFormat(w, http.StatusOK, newPostmanCollection(reqs, requestOrigin(r)))
+}
+
+// insomniaHandler serves the public API as an Insomnia export, sent to the
+// address the export was fetched from.
+func (srv *Server) insomniaHandler(w http.ResponseWriter, r *http.Request) {
+	reqs, ok := srv.exportedRequests(w, r)
+	if !ok {
+		return
+	}
+	respondFormat(w, http.StatusOK, newInsomniaExport(reqs, requestOrigin(r)))
+}
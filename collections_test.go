@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// petRoutes is a route table for mockSpec.
+var petRoutes = []RouteDef{
+	{Method: http.MethodPost, Pattern: "/pets", OperationID: "createPet", Tag: "pets", Summary: "Create a pet"},
+	{Method: http.MethodGet, Pattern: "/pets/{petId}", OperationID: "getPet", Tag: "pets", Summary: "Get a pet"},
+	{Method: http.MethodGet, Pattern: "/health", OperationID: "getHealth", Tag: "health", Summary: "Health check"},
+}
+
+// ========== Client Collection Tests ==========
+
+func TestExportRequests(t *testing.T) {
+	doc, err := openapi3.NewLoader().LoadFromData([]byte(mockSpec))
+	require.NoError(t, err)
+	reqs := exportRequests(petRoutes, doc)
+
+	require.Len(t, reqs, 3)
+	assert.Equal(t, "health", reqs[0].Tag, "grouped by tag")
+	assert.Equal(t, "application/json", reqs[1].MediaType)
+	assert.Contains(t, reqs[1].Body, `"born": "2024-01-01"`)
+	assert.Equal(t, []exportParam{{Name: "petId", Value: "1"}}, reqs[2].PathParams)
+}
+
+func TestPostmanCollection(t *testing.T) {
+	doc, err := openapi3.NewLoader().LoadFromData([]byte(mockSpec))
+	require.NoError(t, err)
+	c := newPostmanCollection(exportRequests(petRoutes, doc), "http://localhost:8080")
+
+	require.Len(t, c.Item, 2)
+	assert.Equal(t, "pets", c.Item[1].Name)
+	get := c.Item[1].Item[1].Request
+	assert.Equal(t, "{{baseUrl}}/pets/:petId", get.URL.Raw)
+	assert.Equal(t, []postmanVariable{{Key: "petId", Value: "1"}}, get.URL.Variable)
+	create := c.Item[1].Item[0].Request
+	require.NotNil(t, create.Body)
+	assert.True(t, json.Valid([]byte(create.Body.Raw)))
+	assert.Contains(t, c.Variable, postmanVariable{Key: "baseUrl", Value: "http://localhost:8080"})
+}
+
+func TestInsomniaExport(t *testing.T) {
+	doc, err := openapi3.NewLoader().LoadFromData([]byte(mockSpec))
+	require.NoError(t, err)
+	e := newInsomniaExport(exportRequests(petRoutes, doc), "http://localhost:8080")
+
+	assert.Equal(t, map[string]string{"baseUrl": "http://localhost:8080", "token": "", "petId": "1"}, e.Resources[1].Data)
+	var get insomniaResource
+	for _, res := range e.Resources {
+		if res.Type == "request" && res.Name == "Get a pet" {
+			get = res
+		}
+	}
+	assert.Equal(t, "req_get_pets_petId", get.ID)
+	assert.Equal(t, "fld_pets", get.ParentID)
+	assert.Equal(t, "{{ _.baseUrl }}/pets/{{ _.petId }}", get.URL)
+}
+
+// JSON_NAMING renames the request bodies the exports carry, never the
+// members Postman and Insomnia read.
+func TestExports_FieldNaming(t *testing.T) {
+	router := setupRouter()
+	useFieldNaming(t, "snake")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/insomnia.json", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var export insomniaExport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &export))
+	assert.Contains(t, w.Body.String(), `"parentId"`)
+	assert.Contains(t, w.Body.String(), `"mimeType"`)
+	assert.NotContains(t, w.Body.String(), `"parent_id"`)
+	assert.NotContains(t, w.Body.String(), `"mime_type"`)
+	assert.Contains(t, w.Body.String(), `\"user_id\"`, "request bodies follow the naming")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/postman.json", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var collection postmanCollection
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &collection))
+	assert.NotEmpty(t, collection.Item)
+	assert.Contains(t, w.Body.String(), `\"user_id\"`, "request bodies follow the naming")
+}
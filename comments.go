@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Comment is a reply to a post. Comments are sample data: they are
+// read-only except through PUT /admin/state.
+type Comment struct {
+	ID     int `json:"id"`
+	PostID int `json:"postId"`
+	// UserID is the commenter, who need not be the post's author.
+	UserID int    `json:"userId"`
+	Body   string `json:"body"`
+	// CreatedAt and UpdatedAt are set by the store. Comments are never
+	// edited, so the two are equal.
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// getUserPostComment serves comment {commentId} on post {postId} written
+// by user {userId}. Each must exist and belong to the one before it; the
+// first that does not is answered with 404.
+func (srv *Server) getUserPostComment(w http.ResponseWriter, r *http.Request) {
+	userID, ok := pathIDParam(w, r, "userId")
+	if !ok {
+		return
+	}
+	postID, ok := pathIDParam(w, r, "postId")
+	if !ok {
+		return
+	}
+	commentID, ok := pathIDParam(w, r, "commentId")
+	if !ok {
+		return
+	}
+
+	s := srv.requestStore(r)
+	if _, err := s.GetUser(userID); err != nil {
+		respondNotFound(w, r, "user", userID)
+		return
+	}
+	switch post, err := s.GetPost(postID); {
+	case err != nil:
+		respondNotFound(w, r, "post", postID)
+		return
+	case post.UserID != userID:
+		respondNotOwned(w, r, "user", userID, "post", postID)
+		return
+	}
+	comment, err := s.GetComment(commentID)
+	switch {
+	case err != nil:
+		respondNotFound(w, r, "comment", commentID)
+		return
+	case comment.PostID != postID:
+		respondNotOwned(w, r, "post", postID, "comment", commentID)
+		return
+	}
+	respondJSON(w, http.StatusOK, comment)
+}
+
+// respondNotOwned reports that child exists but does not belong to parent,
+// which is as good as not existing at that path.
+func respondNotOwned(w http.ResponseWriter, r *http.Request, parent string, parentID int, child string, childID int) {
+	respondProblem(w, r, http.StatusNotFound, "not found", Problem{
+		Detail: fmt.Sprintf("%s %d has no %s %d", parent, parentID, child, childID),
+	})
+}
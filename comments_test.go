@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== Comment Tests ==========
+
+func TestGetUserPostComment(t *testing.T) {
+	router := setupRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/1/posts/2/comments/3", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var comment Comment
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &comment))
+	created := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+	assert.Equal(t, Comment{ID: 3, PostID: 2, UserID: 2, Body: "Keep them coming.", CreatedAt: created, UpdatedAt: created}, comment)
+}
+
+func TestGetUserPostComment_MissingParent(t *testing.T) {
+	router := setupRouter()
+
+	tests := []struct {
+		path   string
+		detail string
+	}{
+		{"/users/9/posts/1/comments/1", "user 9 does not exist"},
+		{"/users/1/posts/9/comments/1", "post 9 does not exist"},
+		{"/users/2/posts/1/comments/1", "user 2 has no post 1"},
+		{"/users/1/posts/1/comments/9", "comment 9 does not exist"},
+		{"/users/1/posts/1/comments/3", "post 1 has no comment 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			assert.Equal(t, http.StatusNotFound, w.Code)
+			assert.Contains(t, w.Body.String(), tt.detail)
+		})
+	}
+}
+
+func TestGetUserPostComment_InvalidID(t *testing.T) {
+	router := setupRouter()
+
+	for _, path := range []string{"/users/x/posts/1/comments/1", "/users/1/posts/0/comments/1", "/users/1/posts/1/comments/-1"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+	}
+}
+
+func TestGetUserPostComment_OutlivesPost(t *testing.T) {
+	router := setupRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodDelete, "/posts/2", ""))
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	c, err := server.Store.GetComment(3)
+	require.NoError(t, err)
+	assert.Equal(t, 2, c.PostID)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/1/posts/2/comments/3", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
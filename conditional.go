@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// setLastModified sets the Last-Modified header to updated, unless it is
+// unknown.
+func setLastModified(w http.ResponseWriter, updated time.Time) {
+	if !updated.IsZero() {
+		w.Header().Set("Last-Modified", updated.UTC().Format(http.TimeFormat))
+	}
+}
+
+// ifUnmodifiedSince returns the time in r's If-Unmodified-Since header, or
+// the zero time if it is missing. An invalid date is ignored, as RFC 9110
+// requires, rather than rejected.
+func ifUnmodifiedSince(r *http.Request) time.Time {
+	t, err := http.ParseTime(r.Header.Get("If-Unmodified-Since"))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// ifModifiedSince returns the time in r's If-Modified-Since header, or the
+// zero time if it is missing or invalid.
+func ifModifiedSince(r *http.Request) time.Time {
+	t, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
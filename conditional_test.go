@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== Conditional Delete Tests ==========
+
+func TestGet_LastModified(t *testing.T) {
+	router := setupRouter()
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/users/1", "Mon, 01 Jan 2024 09:00:00 GMT"},
+		{"/posts/2", "Tue, 02 Jan 2024 14:30:00 GMT"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		require.Equal(t, http.StatusOK, w.Code, tt.path)
+		assert.Equal(t, tt.expected, w.Header().Get("Last-Modified"), tt.path)
+	}
+}
+
+func TestDelete_IfUnmodifiedSince(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		header         string
+		expectedStatus int
+	}{
+		{"user unmodified", "/users/1", "Mon, 01 Jan 2024 09:00:00 GMT", http.StatusNoContent},
+		{"user modified since", "/users/1", "Mon, 01 Jan 2024 08:59:59 GMT", http.StatusPreconditionFailed},
+		{"post unmodified", "/posts/1", "Wed, 01 Jan 2025 00:00:00 GMT", http.StatusNoContent},
+		{"post modified since", "/posts/1", "Sun, 31 Dec 2023 23:00:00 GMT", http.StatusPreconditionFailed},
+		{"invalid date is ignored", "/posts/1", "yesterday", http.StatusNoContent},
+		{"unknown post", "/posts/999", "Mon, 01 Jan 2024 09:00:00 GMT", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+			req := httptest.NewRequest(http.MethodDelete, tt.path, nil)
+			req.Header.Set("If-Unmodified-Since", tt.header)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedStatus, w.Code)
+
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if tt.expectedStatus == http.StatusPreconditionFailed {
+				assert.Equal(t, http.StatusOK, w.Code, "the entity is kept")
+			} else {
+				assert.Equal(t, http.StatusNotFound, w.Code)
+			}
+		})
+	}
+}
+
+func TestDelete_IfUnmodifiedSince_AfterUpdate(t *testing.T) {
+	router := setupRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	lastModified := w.Header().Get("Last-Modified")
+
+	req := httptest.NewRequest(http.MethodPut, "/users/1", strings.NewReader(`{"name":"Alicia","email":"alice@example.com","version":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	req = httptest.NewRequest(http.MethodDelete, "/users/1", nil)
+	req.Header.Set("If-Unmodified-Since", lastModified)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code, "the update is newer than the client's copy")
+}
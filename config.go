@@ -0,0 +1,566 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is the server configuration, read from the environment.
+type Config struct {
+	// Addr is the public API's listen address. ADDR.
+	Addr string
+	// OpsAddr is the listen address for the operational routes (/health,
+	// /metrics, /debug/pprof, /admin). When it equals Addr everything is
+	// served on one listener. OPS_ADDR.
+	OpsAddr string
+	// ShutdownTimeout bounds how long SIGINT or SIGTERM waits for
+	// in-flight requests to finish and subsystems to stop before the
+	// process exits. SHUTDOWN_TIMEOUT.
+	ShutdownTimeout time.Duration
+	// TrailingSlash is how paths ending in "/" are handled: "strip" serves
+	// them as if the slash were absent, "redirect" sends a 301 to the
+	// slashless path. TRAILING_SLASH.
+	TrailingSlash string
+	// SigningKeys sign and verify response bodies. The first key signs.
+	// SIGNING_KEYS, as comma-separated id:secret pairs.
+	SigningKeys []SigningKey
+	// IngestSecret verifies X-Hub-Signature-256 on POST /ingest/events.
+	// INGEST_SECRET.
+	IngestSecret []byte
+	// AdminToken is the bearer token for admin-only routes such as
+	// /tenants. ADMIN_TOKEN.
+	AdminToken []byte
+	// AdminIPs are the client address ranges allowed and denied /admin
+	// until PUT /admin/ip-rules replaces them. ADMIN_IP_ALLOW and
+	// ADMIN_IP_DENY, as comma-separated CIDR ranges or addresses.
+	AdminIPs IPRules
+	// TrustedProxies are the addresses whose X-Forwarded-For and X-Real-IP
+	// headers name the client. TRUSTED_PROXIES, as comma-separated CIDR
+	// ranges or addresses; empty trusts no one.
+	TrustedProxies []string
+	// AuthSecret signs the user bearer tokens issued by
+	// POST /admin/users/{id}/token. Empty disables user tokens. AUTH_SECRET.
+	AuthSecret []byte
+	// PublicURL is the base URL clients reach the API at, e.g.
+	// "https://api.example.com", that links in mail and feeds point to. A
+	// request's Host is the client's to choose, so it is not used for mail;
+	// without PublicURL no such mail is sent. PUBLIC_URL.
+	PublicURL string
+	// DailyQuota is the number of requests each user may make per UTC day;
+	// 0 is unlimited. DAILY_QUOTA.
+	DailyQuota int
+	// ConfigFile holds the RuntimeConfig settings, reloaded on SIGHUP.
+	// CONFIG_FILE.
+	ConfigFile string
+	Record     bool   // RECORD
+	RecordFile string // RECORD_FILE
+	AccessLog  AccessLogConfig
+	Chaos      ChaosConfig
+	Honeypot   HoneypotConfig
+	Breaker    BreakerConfig
+	Shadow     ShadowConfig
+	Proxy      ProxyConfig
+	Outbound   OutboundConfig
+	Leader     LeaderConfig
+	// DatabaseURL selects the Postgres store, as a postgres:// URL. Empty
+	// keeps everything in memory. DATABASE_URL.
+	DatabaseURL string
+	DBPool      DBPoolConfig
+	// FieldKeys encrypt sensitive user fields in the Postgres store. The
+	// first key seals new values; the others only open old ones until
+	// startup has resealed them. FIELD_KEYS, as comma-separated
+	// id:base64 pairs of 32-byte AES keys.
+	FieldKeys []FieldKey
+	// FieldIndexKey keys the blind index that lookups on encrypted fields
+	// use. Required with FIELD_KEYS, and never rotated. FIELD_INDEX_KEY.
+	FieldIndexKey []byte
+	// RedisURL shares rate-limit windows and the store breaker's read
+	// cache between instances, as a redis:// URL. Empty keeps them in
+	// process. REDIS_URL.
+	RedisURL string
+	// JSONNaming names the fields of request and response bodies, and of
+	// the served spec, consistently: "camel" or "snake". Empty keeps the
+	// names as declared. JSON_NAMING.
+	JSONNaming string
+	// PageEnvelope sends collections as a Page of the items ?page= and
+	// ?per_page= select, rather than as a bare array. PAGE_ENVELOPE.
+	PageEnvelope bool
+}
+
+// DBPoolConfig sizes the database connection pool. Zero values keep the
+// driver's defaults.
+type DBPoolConfig struct {
+	MaxConns        int           // DB_MAX_CONNS, open connections at most; 0 is the larger of 4 and the CPU count
+	MinConns        int           // DB_MIN_CONNS, connections kept open even when idle
+	MaxConnLifetime time.Duration // DB_MAX_CONN_LIFETIME, e.g. "1h", before a connection is replaced; 0 is 1h
+	MaxConnIdleTime time.Duration // DB_MAX_CONN_IDLE_TIME, e.g. "30m", before an idle connection is closed; 0 is 30m
+}
+
+// BreakerConfig controls the circuit breaker guarding the store.
+type BreakerConfig struct {
+	Threshold int           // STORE_BREAKER_THRESHOLD, consecutive failures; 0 disables the breaker
+	Cooldown  time.Duration // STORE_BREAKER_COOLDOWN, e.g. "30s", before a trial call
+}
+
+// AccessLogConfig controls the JSON access log. Body capture follows the
+// runtime log level: bodies are logged only while it is debug.
+type AccessLogConfig struct {
+	Path      string        // ACCESS_LOG; empty disables the log
+	MaxSize   int64         // ACCESS_LOG_MAX_SIZE, in bytes; 0 is unlimited
+	MaxAge    time.Duration // ACCESS_LOG_MAX_AGE, e.g. "24h"; 0 is unlimited
+	BodyLimit int           // ACCESS_LOG_BODY_LIMIT, bytes captured per body
+}
+
+// ChaosConfig controls the chaos middleware. Rates are probabilities in
+// [0, 1] applied independently to each request.
+type ChaosConfig struct {
+	Enabled     bool          // CHAOS_ENABLED
+	LatencyRate float64       // CHAOS_LATENCY_RATE
+	Latency     time.Duration // CHAOS_LATENCY, e.g. "250ms"
+	ErrorRate   float64       // CHAOS_ERROR_RATE
+	DropRate    float64       // CHAOS_DROP_RATE
+}
+
+// ShadowConfig controls traffic shadowing: mirroring requests to another
+// implementation of the API to compare its answers with this server's.
+type ShadowConfig struct {
+	URL     string        // SHADOW_URL, the upstream's base URL, e.g. "http://localhost:3000"; empty disables shadowing
+	Rate    float64       // SHADOW_RATE, the probability in [0, 1] that a request is mirrored
+	Timeout time.Duration // SHADOW_TIMEOUT, e.g. "5s", that a mirrored request may take
+}
+
+// ProxyConfig controls the /proxy/{service} passthrough routes.
+type ProxyConfig struct {
+	Services           map[string]string // PROXY_SERVICES, comma-separated name=url pairs, e.g. "billing=http://localhost:3001"; /proxy/billing/... forwards there
+	Timeout            time.Duration     // PROXY_TIMEOUT, e.g. "10s", that a proxied request may take, retries included; 0 is unlimited
+	Retries            int               // PROXY_RETRIES, further attempts at an idempotent request the upstream failed with an error, 502, 503 or 504
+	ForwardCredentials []string          // PROXY_FORWARD_CREDENTIALS, comma-separated services sent the client's Authorization, Proxy-Authorization and Cookie headers, which the rest never see
+}
+
+// OutboundConfig controls the shared client for calls to external
+// services, such as Gravatar.
+type OutboundConfig struct {
+	Timeout     time.Duration // OUTBOUND_TIMEOUT, e.g. "2s", that a call may take, retries included; 0 is unlimited
+	Retries     int           // OUTBOUND_RETRIES, further attempts at a call the service failed with an error, 502, 503 or 504
+	GravatarURL string        // GRAVATAR_URL, the Gravatar API's base URL
+}
+
+// LeaderConfig controls the election of the instance that runs the
+// background jobs, such as webhook delivery, among those sharing Redis or
+// Postgres.
+type LeaderConfig struct {
+	Instance string        // INSTANCE_ID, unique among the instances; empty uses the host name and process ID
+	Lease    time.Duration // LEADER_LEASE, e.g. "15s", that a leader which stops renewing keeps the role
+}
+
+// HoneypotConfig controls the decoy routes that catch scanners.
+type HoneypotConfig struct {
+	Paths []string      // HONEYPOT_PATHS, comma-separated, e.g. "/wp-login.php,/.env"; empty disables the decoys
+	Delay time.Duration // HONEYPOT_DELAY, e.g. "10s", before a decoy answers
+	Ban   time.Duration // HONEYPOT_BAN, e.g. "1h", that the rate limiter refuses a client hitting a decoy; 0 disables bans
+}
+
+// loadConfig reads Config from environment variables. Unset variables keep
+// their defaults; malformed ones are an error.
+func loadConfig() (Config, error) {
+	cfg := Config{
+		Addr:            ":8080",
+		OpsAddr:         ":9090",
+		ShutdownTimeout: 15 * time.Second,
+		TrailingSlash:   "strip",
+		RecordFile:      "recording.har",
+		AccessLog:       AccessLogConfig{MaxSize: 100 << 20, MaxAge: 24 * time.Hour, BodyLimit: 1024},
+		Chaos:           ChaosConfig{Latency: 500 * time.Millisecond},
+		Honeypot:        HoneypotConfig{Delay: 10 * time.Second},
+		Breaker:         BreakerConfig{Threshold: 5, Cooldown: 30 * time.Second},
+		Shadow:          ShadowConfig{Rate: 1, Timeout: 5 * time.Second},
+		Proxy:           ProxyConfig{Timeout: 10 * time.Second, Retries: 2},
+		Outbound:        OutboundConfig{Timeout: 2 * time.Second, Retries: 2, GravatarURL: defaultGravatarURL},
+		Leader:          LeaderConfig{Lease: defaultLeaderLease},
+		DailyQuota:      1000,
+	}
+
+	var err error
+	cfg.Addr = envString("ADDR", cfg.Addr)
+	cfg.OpsAddr = envString("OPS_ADDR", cfg.OpsAddr)
+	if cfg.ShutdownTimeout, err = envDuration("SHUTDOWN_TIMEOUT", cfg.ShutdownTimeout); err != nil {
+		return Config{}, err
+	}
+	cfg.TrailingSlash = envString("TRAILING_SLASH", cfg.TrailingSlash)
+	if _, err := trailingSlashMiddleware(cfg.TrailingSlash); err != nil {
+		return Config{}, fmt.Errorf("TRAILING_SLASH: %w", err)
+	}
+	cfg.ConfigFile = envString("CONFIG_FILE", cfg.ConfigFile)
+	if cfg.Record, err = envBool("RECORD", cfg.Record); err != nil {
+		return Config{}, err
+	}
+	cfg.RecordFile = envString("RECORD_FILE", cfg.RecordFile)
+	if cfg.SigningKeys, err = envSigningKeys("SIGNING_KEYS"); err != nil {
+		return Config{}, err
+	}
+	if v := os.Getenv("INGEST_SECRET"); v != "" {
+		cfg.IngestSecret = []byte(v)
+	}
+	if v := os.Getenv("ADMIN_TOKEN"); v != "" {
+		cfg.AdminToken = []byte(v)
+	}
+	if cfg.AdminIPs.Allow, err = envIPRanges("ADMIN_IP_ALLOW"); err != nil {
+		return Config{}, err
+	}
+	if cfg.AdminIPs.Deny, err = envIPRanges("ADMIN_IP_DENY"); err != nil {
+		return Config{}, err
+	}
+	if cfg.TrustedProxies, err = envIPRanges("TRUSTED_PROXIES"); err != nil {
+		return Config{}, err
+	}
+	if v := os.Getenv("AUTH_SECRET"); v != "" {
+		cfg.AuthSecret = []byte(v)
+	}
+	if cfg.PublicURL, err = envBaseURL("PUBLIC_URL"); err != nil {
+		return Config{}, err
+	}
+	dailyQuota, err := envInt("DAILY_QUOTA", int64(cfg.DailyQuota))
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.DailyQuota = int(dailyQuota)
+	cfg.AccessLog.Path = envString("ACCESS_LOG", cfg.AccessLog.Path)
+	if cfg.AccessLog.MaxSize, err = envInt("ACCESS_LOG_MAX_SIZE", cfg.AccessLog.MaxSize); err != nil {
+		return Config{}, err
+	}
+	if cfg.AccessLog.MaxAge, err = envDuration("ACCESS_LOG_MAX_AGE", cfg.AccessLog.MaxAge); err != nil {
+		return Config{}, err
+	}
+	bodyLimit, err := envInt("ACCESS_LOG_BODY_LIMIT", int64(cfg.AccessLog.BodyLimit))
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.AccessLog.BodyLimit = int(bodyLimit)
+	if cfg.Chaos.Enabled, err = envBool("CHAOS_ENABLED", cfg.Chaos.Enabled); err != nil {
+		return Config{}, err
+	}
+	if cfg.Chaos.LatencyRate, err = envRate("CHAOS_LATENCY_RATE", cfg.Chaos.LatencyRate); err != nil {
+		return Config{}, err
+	}
+	if cfg.Chaos.Latency, err = envDuration("CHAOS_LATENCY", cfg.Chaos.Latency); err != nil {
+		return Config{}, err
+	}
+	if cfg.Chaos.ErrorRate, err = envRate("CHAOS_ERROR_RATE", cfg.Chaos.ErrorRate); err != nil {
+		return Config{}, err
+	}
+	if cfg.Chaos.DropRate, err = envRate("CHAOS_DROP_RATE", cfg.Chaos.DropRate); err != nil {
+		return Config{}, err
+	}
+	if cfg.Honeypot.Paths, err = envPaths("HONEYPOT_PATHS"); err != nil {
+		return Config{}, err
+	}
+	if cfg.Honeypot.Delay, err = envDuration("HONEYPOT_DELAY", cfg.Honeypot.Delay); err != nil {
+		return Config{}, err
+	}
+	if cfg.Honeypot.Ban, err = envDuration("HONEYPOT_BAN", cfg.Honeypot.Ban); err != nil {
+		return Config{}, err
+	}
+	threshold, err := envInt("STORE_BREAKER_THRESHOLD", int64(cfg.Breaker.Threshold))
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.Breaker.Threshold = int(threshold)
+	if cfg.Breaker.Cooldown, err = envDuration("STORE_BREAKER_COOLDOWN", cfg.Breaker.Cooldown); err != nil {
+		return Config{}, err
+	}
+	if cfg.Shadow.URL, err = envBaseURL("SHADOW_URL"); err != nil {
+		return Config{}, err
+	}
+	if cfg.Shadow.Rate, err = envRate("SHADOW_RATE", cfg.Shadow.Rate); err != nil {
+		return Config{}, err
+	}
+	if cfg.Shadow.Timeout, err = envDuration("SHADOW_TIMEOUT", cfg.Shadow.Timeout); err != nil {
+		return Config{}, err
+	}
+	if cfg.Proxy.Services, err = envServices("PROXY_SERVICES"); err != nil {
+		return Config{}, err
+	}
+	if cfg.Proxy.ForwardCredentials, err = envServiceNames("PROXY_FORWARD_CREDENTIALS", cfg.Proxy.Services); err != nil {
+		return Config{}, err
+	}
+	if cfg.Proxy.Timeout, err = envDuration("PROXY_TIMEOUT", cfg.Proxy.Timeout); err != nil {
+		return Config{}, err
+	}
+	retries, err := envInt("PROXY_RETRIES", int64(cfg.Proxy.Retries))
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.Proxy.Retries = int(retries)
+	if cfg.Outbound.Timeout, err = envDuration("OUTBOUND_TIMEOUT", cfg.Outbound.Timeout); err != nil {
+		return Config{}, err
+	}
+	if retries, err = envInt("OUTBOUND_RETRIES", int64(cfg.Outbound.Retries)); err != nil {
+		return Config{}, err
+	}
+	cfg.Outbound.Retries = int(retries)
+	gravatarURL, err := envBaseURL("GRAVATAR_URL")
+	if err != nil {
+		return Config{}, err
+	}
+	if gravatarURL != "" {
+		cfg.Outbound.GravatarURL = gravatarURL
+	}
+	cfg.Leader.Instance = envString("INSTANCE_ID", cfg.Leader.Instance)
+	if cfg.Leader.Lease, err = envDuration("LEADER_LEASE", cfg.Leader.Lease); err != nil {
+		return Config{}, err
+	}
+	if cfg.Leader.Lease == 0 {
+		return Config{}, fmt.Errorf("LEADER_LEASE: must be positive")
+	}
+	cfg.DatabaseURL = envString("DATABASE_URL", cfg.DatabaseURL)
+	if cfg.FieldKeys, err = envFieldKeys("FIELD_KEYS"); err != nil {
+		return Config{}, err
+	}
+	if v := os.Getenv("FIELD_INDEX_KEY"); v != "" {
+		cfg.FieldIndexKey = []byte(v)
+	}
+	if len(cfg.FieldKeys) > 0 && len(cfg.FieldIndexKey) == 0 {
+		return Config{}, fmt.Errorf("FIELD_INDEX_KEY: required with FIELD_KEYS")
+	}
+	cfg.RedisURL = envString("REDIS_URL", cfg.RedisURL)
+	cfg.JSONNaming = envString("JSON_NAMING", cfg.JSONNaming)
+	if _, err := parseFieldNaming(cfg.JSONNaming); err != nil {
+		return Config{}, err
+	}
+	if cfg.PageEnvelope, err = envBool("PAGE_ENVELOPE", cfg.PageEnvelope); err != nil {
+		return Config{}, err
+	}
+	maxConns, err := envInt("DB_MAX_CONNS", int64(cfg.DBPool.MaxConns))
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.DBPool.MaxConns = int(maxConns)
+	minConns, err := envInt("DB_MIN_CONNS", int64(cfg.DBPool.MinConns))
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.DBPool.MinConns = int(minConns)
+	if cfg.DBPool.MaxConns > 0 && cfg.DBPool.MinConns > cfg.DBPool.MaxConns {
+		return Config{}, fmt.Errorf("DB_MIN_CONNS: %d is more than DB_MAX_CONNS (%d)", cfg.DBPool.MinConns, cfg.DBPool.MaxConns)
+	}
+	if cfg.DBPool.MaxConnLifetime, err = envDuration("DB_MAX_CONN_LIFETIME", cfg.DBPool.MaxConnLifetime); err != nil {
+		return Config{}, err
+	}
+	if cfg.DBPool.MaxConnIdleTime, err = envDuration("DB_MAX_CONN_IDLE_TIME", cfg.DBPool.MaxConnIdleTime); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+func envString(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// envSigningKeys parses "id:secret,id:secret". IDs must be unique and
+// secrets non-empty.
+func envSigningKeys(key string) ([]SigningKey, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil, nil
+	}
+	var keys []SigningKey
+	seen := map[string]bool{}
+	for _, pair := range strings.Split(v, ",") {
+		id, secret, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || id == "" || secret == "" {
+			return nil, fmt.Errorf("%s: %q is not id:secret", key, pair)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("%s: duplicate key id %q", key, id)
+		}
+		seen[id] = true
+		keys = append(keys, SigningKey{ID: id, Secret: []byte(secret)})
+	}
+	return keys, nil
+}
+
+// envPaths parses a comma-separated list of URL paths, each starting
+// with "/".
+func envPaths(key string) ([]string, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil, nil
+	}
+	var paths []string
+	for _, p := range strings.Split(v, ",") {
+		p = strings.TrimSpace(p)
+		if !strings.HasPrefix(p, "/") {
+			return nil, fmt.Errorf("%s: %q is not a path", key, p)
+		}
+		paths = append(paths, p)
+	}
+	return paths, nil
+}
+
+// envBaseURL parses an absolute http or https URL, which paths are
+// appended to, so any trailing slash is dropped.
+func envBaseURL(key string) (string, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return "", nil
+	}
+	return parseBaseURL(key, v)
+}
+
+func parseBaseURL(key, v string) (string, error) {
+	u, err := url.Parse(v)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("%s: %q is not an http or https URL", key, v)
+	}
+	return strings.TrimSuffix(v, "/"), nil
+}
+
+// serviceNamePattern matches the names envServices accepts, which stand
+// for path segments.
+var serviceNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// envServices parses "name=url,name=url" into a map of names to base
+// URLs, read as envBaseURL reads them. Names are unique lowercase letters,
+// digits and hyphens.
+func envServices(key string) (map[string]string, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil, nil
+	}
+	services := map[string]string{}
+	for _, pair := range strings.Split(v, ",") {
+		name, base, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || !serviceNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("%s: %q is not name=url", key, pair)
+		}
+		if _, dup := services[name]; dup {
+			return nil, fmt.Errorf("%s: duplicate service %q", key, name)
+		}
+		u, err := parseBaseURL(key, base)
+		if err != nil {
+			return nil, err
+		}
+		services[name] = u
+	}
+	return services, nil
+}
+
+// envServiceNames parses a comma-separated list of names, each one of
+// services.
+func envServiceNames(key string, services map[string]string) ([]string, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil, nil
+	}
+	var names []string
+	for _, name := range strings.Split(v, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := services[name]; !ok {
+			return nil, fmt.Errorf("%s: %q is not a configured service", key, name)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// envIPRanges parses a comma-separated list of CIDR ranges or addresses.
+func envIPRanges(key string) ([]string, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil, nil
+	}
+	var ranges []string
+	for _, s := range strings.Split(v, ",") {
+		s = strings.TrimSpace(s)
+		if _, err := parseIPRange(s); err != nil {
+			return nil, fmt.Errorf("%s: %q is not a CIDR range or an IP address", key, s)
+		}
+		ranges = append(ranges, s)
+	}
+	return ranges, nil
+}
+
+// envFieldKeys parses "id:base64,id:base64", where each key is 32 bytes
+// in standard base64. IDs must be unique.
+func envFieldKeys(key string) ([]FieldKey, error) {
+	pairs, err := envSigningKeys(key)
+	if err != nil {
+		return nil, err
+	}
+	var keys []FieldKey
+	for _, pair := range pairs {
+		k, err := base64.StdEncoding.DecodeString(string(pair.Secret))
+		if err != nil || len(k) != 32 {
+			return nil, fmt.Errorf("%s: key %q is not 32 bytes of base64", key, pair.ID)
+		}
+		keys = append(keys, FieldKey{ID: pair.ID, Key: k})
+	}
+	return keys, nil
+}
+
+func envBool(key string, def bool) (bool, error) {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", key, err)
+	}
+	return b, nil
+}
+
+// envInt parses a non-negative integer.
+func envInt(key string, def int64) (int64, error) {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", key, err)
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("%s: %d is negative", key, n)
+	}
+	return n, nil
+}
+
+func envRate(key string, def float64) (float64, error) {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return def, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", key, err)
+	}
+	if f < 0 || f > 1 {
+		return 0, fmt.Errorf("%s: %v is outside [0, 1]", key, f)
+	}
+	return f, nil
+}
+
+func envDuration(key string, def time.Duration) (time.Duration, error) {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", key, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("%s: negative duration %v", key, d)
+	}
+	return d, nil
+}
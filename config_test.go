@@ -0,0 +1,316 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== Config Tests ==========
+
+func TestLoadConfig_Defaults(t *testing.T) {
+	for _, key := range []string{"ADDR", "OPS_ADDR", "SHUTDOWN_TIMEOUT", "TRAILING_SLASH", "SIGNING_KEYS", "INGEST_SECRET", "ADMIN_TOKEN", "ADMIN_IP_ALLOW", "ADMIN_IP_DENY", "TRUSTED_PROXIES", "AUTH_SECRET", "PUBLIC_URL", "DAILY_QUOTA", "CONFIG_FILE", "RECORD", "RECORD_FILE", "ACCESS_LOG", "ACCESS_LOG_MAX_SIZE", "ACCESS_LOG_MAX_AGE", "ACCESS_LOG_BODY_LIMIT", "CHAOS_ENABLED", "CHAOS_LATENCY_RATE", "CHAOS_LATENCY", "CHAOS_ERROR_RATE", "CHAOS_DROP_RATE", "HONEYPOT_PATHS", "HONEYPOT_DELAY", "HONEYPOT_BAN", "STORE_BREAKER_THRESHOLD", "STORE_BREAKER_COOLDOWN", "SHADOW_URL", "SHADOW_RATE", "SHADOW_TIMEOUT", "PROXY_SERVICES", "PROXY_FORWARD_CREDENTIALS", "PROXY_TIMEOUT", "PROXY_RETRIES", "OUTBOUND_TIMEOUT", "OUTBOUND_RETRIES", "GRAVATAR_URL", "INSTANCE_ID", "LEADER_LEASE", "DATABASE_URL", "DB_MAX_CONNS", "DB_MIN_CONNS", "DB_MAX_CONN_LIFETIME", "DB_MAX_CONN_IDLE_TIME", "REDIS_URL", "JSON_NAMING", "PAGE_ENVELOPE", "FIELD_KEYS", "FIELD_INDEX_KEY"} {
+		t.Setenv(key, "")
+	}
+
+	cfg, err := loadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, ":8080", cfg.Addr)
+	assert.Equal(t, ":9090", cfg.OpsAddr)
+	assert.Equal(t, 15*time.Second, cfg.ShutdownTimeout)
+	assert.Equal(t, "strip", cfg.TrailingSlash)
+	assert.Empty(t, cfg.SigningKeys)
+	assert.Empty(t, cfg.IngestSecret)
+	assert.Empty(t, cfg.AdminToken)
+	assert.Equal(t, IPRules{}, cfg.AdminIPs)
+	assert.Empty(t, cfg.TrustedProxies)
+	assert.Empty(t, cfg.AuthSecret)
+	assert.Empty(t, cfg.PublicURL)
+	assert.Equal(t, 1000, cfg.DailyQuota)
+	assert.Empty(t, cfg.ConfigFile)
+	assert.False(t, cfg.Record)
+	assert.Equal(t, "recording.har", cfg.RecordFile)
+	assert.Equal(t, AccessLogConfig{MaxSize: 100 << 20, MaxAge: 24 * time.Hour, BodyLimit: 1024}, cfg.AccessLog)
+	assert.Equal(t, ChaosConfig{Latency: 500 * time.Millisecond}, cfg.Chaos)
+	assert.Equal(t, HoneypotConfig{Delay: 10 * time.Second}, cfg.Honeypot)
+	assert.Equal(t, BreakerConfig{Threshold: 5, Cooldown: 30 * time.Second}, cfg.Breaker)
+	assert.Equal(t, ShadowConfig{Rate: 1, Timeout: 5 * time.Second}, cfg.Shadow)
+	assert.Equal(t, ProxyConfig{Timeout: 10 * time.Second, Retries: 2}, cfg.Proxy)
+	assert.Equal(t, OutboundConfig{Timeout: 2 * time.Second, Retries: 2, GravatarURL: "https://api.gravatar.com/v3"}, cfg.Outbound)
+	assert.Equal(t, LeaderConfig{Lease: 15 * time.Second}, cfg.Leader)
+	assert.Empty(t, cfg.DatabaseURL)
+	assert.Equal(t, DBPoolConfig{}, cfg.DBPool)
+	assert.Empty(t, cfg.RedisURL)
+	assert.Empty(t, cfg.JSONNaming)
+	assert.False(t, cfg.PageEnvelope)
+	assert.Empty(t, cfg.FieldKeys)
+	assert.Empty(t, cfg.FieldIndexKey)
+}
+
+func TestLoadConfig_SigningKeys(t *testing.T) {
+	t.Setenv("SIGNING_KEYS", "current:abc, previous:x:y")
+
+	cfg, err := loadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, []SigningKey{
+		{ID: "current", Secret: []byte("abc")},
+		{ID: "previous", Secret: []byte("x:y")},
+	}, cfg.SigningKeys)
+}
+
+func TestLoadConfig_FieldKeys(t *testing.T) {
+	current, previous := strings.Repeat("A", 43)+"=", strings.Repeat("B", 43)+"="
+	t.Setenv("FIELD_KEYS", "2:"+current+", 1:"+previous)
+	t.Setenv("FIELD_INDEX_KEY", "index-key")
+
+	cfg, err := loadConfig()
+	require.NoError(t, err)
+	require.Len(t, cfg.FieldKeys, 2)
+	assert.Equal(t, "2", cfg.FieldKeys[0].ID)
+	assert.Equal(t, make([]byte, 32), cfg.FieldKeys[0].Key)
+	assert.Equal(t, "1", cfg.FieldKeys[1].ID)
+	assert.Len(t, cfg.FieldKeys[1].Key, 32)
+	assert.Equal(t, []byte("index-key"), cfg.FieldIndexKey)
+}
+
+func TestLoadConfig_FieldKeysNeedIndexKey(t *testing.T) {
+	t.Setenv("FIELD_KEYS", "1:"+strings.Repeat("A", 43)+"=")
+	t.Setenv("FIELD_INDEX_KEY", "")
+
+	_, err := loadConfig()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "FIELD_INDEX_KEY")
+}
+
+func TestLoadConfig_Addrs(t *testing.T) {
+	t.Setenv("ADDR", ":8000")
+	t.Setenv("OPS_ADDR", "127.0.0.1:9000")
+
+	cfg, err := loadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, ":8000", cfg.Addr)
+	assert.Equal(t, "127.0.0.1:9000", cfg.OpsAddr)
+}
+
+func TestLoadConfig_AdminToken(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "t0ken")
+
+	cfg, err := loadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, []byte("t0ken"), cfg.AdminToken)
+}
+
+func TestLoadConfig_AdminIPs(t *testing.T) {
+	t.Setenv("ADMIN_IP_ALLOW", "10.0.0.0/8, 192.0.2.1")
+	t.Setenv("ADMIN_IP_DENY", "10.0.0.66")
+
+	cfg, err := loadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, IPRules{Allow: []string{"10.0.0.0/8", "192.0.2.1"}, Deny: []string{"10.0.0.66"}}, cfg.AdminIPs)
+}
+
+func TestLoadConfig_TrustedProxies(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8,fd00::1")
+
+	cfg, err := loadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.0/8", "fd00::1"}, cfg.TrustedProxies)
+}
+
+func TestLoadConfig_Auth(t *testing.T) {
+	t.Setenv("AUTH_SECRET", "s3cret")
+	t.Setenv("PUBLIC_URL", "https://api.example.com/")
+	t.Setenv("DAILY_QUOTA", "0")
+
+	cfg, err := loadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, []byte("s3cret"), cfg.AuthSecret)
+	assert.Equal(t, "https://api.example.com", cfg.PublicURL)
+	assert.Zero(t, cfg.DailyQuota)
+}
+
+func TestLoadConfig_Record(t *testing.T) {
+	t.Setenv("RECORD", "1")
+	t.Setenv("RECORD_FILE", "/tmp/session.har")
+
+	cfg, err := loadConfig()
+	require.NoError(t, err)
+	assert.True(t, cfg.Record)
+	assert.Equal(t, "/tmp/session.har", cfg.RecordFile)
+}
+
+func TestLoadConfig_AccessLog(t *testing.T) {
+	t.Setenv("ACCESS_LOG", "/var/log/access.log")
+	t.Setenv("ACCESS_LOG_MAX_SIZE", "1048576")
+	t.Setenv("ACCESS_LOG_MAX_AGE", "1h")
+	t.Setenv("ACCESS_LOG_BODY_LIMIT", "0")
+
+	cfg, err := loadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, AccessLogConfig{Path: "/var/log/access.log", MaxSize: 1 << 20, MaxAge: time.Hour}, cfg.AccessLog)
+}
+
+func TestLoadConfig_Chaos(t *testing.T) {
+	t.Setenv("CHAOS_ENABLED", "true")
+	t.Setenv("CHAOS_LATENCY_RATE", "0.25")
+	t.Setenv("CHAOS_LATENCY", "2s")
+	t.Setenv("CHAOS_ERROR_RATE", "0.1")
+	t.Setenv("CHAOS_DROP_RATE", "1")
+
+	cfg, err := loadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, ChaosConfig{
+		Enabled:     true,
+		LatencyRate: 0.25,
+		Latency:     2 * time.Second,
+		ErrorRate:   0.1,
+		DropRate:    1,
+	}, cfg.Chaos)
+}
+
+func TestLoadConfig_Honeypot(t *testing.T) {
+	t.Setenv("HONEYPOT_PATHS", "/wp-login.php, /.env")
+	t.Setenv("HONEYPOT_DELAY", "30s")
+	t.Setenv("HONEYPOT_BAN", "1h")
+
+	cfg, err := loadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, HoneypotConfig{
+		Paths: []string{"/wp-login.php", "/.env"},
+		Delay: 30 * time.Second,
+		Ban:   time.Hour,
+	}, cfg.Honeypot)
+}
+
+func TestLoadConfig_Breaker(t *testing.T) {
+	t.Setenv("STORE_BREAKER_THRESHOLD", "0")
+	t.Setenv("STORE_BREAKER_COOLDOWN", "5s")
+
+	cfg, err := loadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, BreakerConfig{Cooldown: 5 * time.Second}, cfg.Breaker)
+}
+
+func TestLoadConfig_Shadow(t *testing.T) {
+	t.Setenv("SHADOW_URL", "http://localhost:3000/")
+	t.Setenv("SHADOW_RATE", "0.05")
+	t.Setenv("SHADOW_TIMEOUT", "2s")
+
+	cfg, err := loadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, ShadowConfig{URL: "http://localhost:3000", Rate: 0.05, Timeout: 2 * time.Second}, cfg.Shadow)
+}
+
+func TestLoadConfig_Proxy(t *testing.T) {
+	t.Setenv("PROXY_SERVICES", "billing=http://localhost:3001/, geo-v2=https://geo.example.com/api")
+	t.Setenv("PROXY_FORWARD_CREDENTIALS", "billing")
+	t.Setenv("PROXY_TIMEOUT", "3s")
+	t.Setenv("PROXY_RETRIES", "0")
+
+	cfg, err := loadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, ProxyConfig{
+		Services:           map[string]string{"billing": "http://localhost:3001", "geo-v2": "https://geo.example.com/api"},
+		Timeout:            3 * time.Second,
+		ForwardCredentials: []string{"billing"},
+	}, cfg.Proxy)
+}
+
+func TestLoadConfig_Outbound(t *testing.T) {
+	t.Setenv("OUTBOUND_TIMEOUT", "500ms")
+	t.Setenv("OUTBOUND_RETRIES", "0")
+	t.Setenv("GRAVATAR_URL", "http://localhost:3002/")
+
+	cfg, err := loadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, OutboundConfig{Timeout: 500 * time.Millisecond, GravatarURL: "http://localhost:3002"}, cfg.Outbound)
+}
+
+func TestLoadConfig_Leader(t *testing.T) {
+	t.Setenv("INSTANCE_ID", "api-0")
+	t.Setenv("LEADER_LEASE", "30s")
+
+	cfg, err := loadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, LeaderConfig{Instance: "api-0", Lease: 30 * time.Second}, cfg.Leader)
+}
+
+func TestLoadConfig_DBPool(t *testing.T) {
+	t.Setenv("DB_MAX_CONNS", "20")
+	t.Setenv("DB_MIN_CONNS", "2")
+	t.Setenv("DB_MAX_CONN_LIFETIME", "1h")
+	t.Setenv("DB_MAX_CONN_IDLE_TIME", "5m")
+
+	cfg, err := loadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, DBPoolConfig{MaxConns: 20, MinConns: 2, MaxConnLifetime: time.Hour, MaxConnIdleTime: 5 * time.Minute}, cfg.DBPool)
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	tests := []struct {
+		key   string
+		value string
+	}{
+		{"SHUTDOWN_TIMEOUT", "eventually"},
+		{"TRAILING_SLASH", "keep"},
+		{"SIGNING_KEYS", "nosecret"},
+		{"SIGNING_KEYS", "a:1,a:2"},
+		{"SIGNING_KEYS", ":secret"},
+		{"ADMIN_IP_ALLOW", "10.0.0.0/33"},
+		{"ADMIN_IP_DENY", "localhost"},
+		{"TRUSTED_PROXIES", "10.0.0.0/8;10.1.0.0/16"},
+		{"RECORD", "yes"},
+		{"DAILY_QUOTA", "-1"},
+		{"ACCESS_LOG_MAX_SIZE", "big"},
+		{"ACCESS_LOG_BODY_LIMIT", "-1"},
+		{"ACCESS_LOG_MAX_AGE", "forever"},
+		{"CHAOS_ENABLED", "maybe"},
+		{"CHAOS_LATENCY_RATE", "abc"},
+		{"CHAOS_ERROR_RATE", "1.5"},
+		{"CHAOS_DROP_RATE", "-0.1"},
+		{"CHAOS_LATENCY", "soon"},
+		{"CHAOS_LATENCY", "-1s"},
+		{"HONEYPOT_PATHS", "wp-login.php"},
+		{"HONEYPOT_DELAY", "slowly"},
+		{"HONEYPOT_BAN", "-1h"},
+		{"STORE_BREAKER_THRESHOLD", "-1"},
+		{"STORE_BREAKER_COOLDOWN", "later"},
+		{"SHADOW_URL", "localhost:3000"},
+		{"SHADOW_URL", "ftp://example.com"},
+		{"SHADOW_RATE", "5"},
+		{"SHADOW_TIMEOUT", "never"},
+		{"PROXY_SERVICES", "http://localhost:3001"},
+		{"PROXY_SERVICES", "Billing=http://localhost:3001"},
+		{"PROXY_SERVICES", "billing=localhost:3001"},
+		{"PROXY_SERVICES", "a=http://a.example.com,a=http://b.example.com"},
+		{"PROXY_FORWARD_CREDENTIALS", "billing"},
+		{"PROXY_TIMEOUT", "soon"},
+		{"PROXY_RETRIES", "-1"},
+		{"OUTBOUND_TIMEOUT", "soon"},
+		{"OUTBOUND_RETRIES", "-1"},
+		{"GRAVATAR_URL", "api.gravatar.com"},
+		{"PUBLIC_URL", "api.example.com"},
+		{"LEADER_LEASE", "soon"},
+		{"LEADER_LEASE", "0s"},
+		{"DB_MAX_CONNS", "-1"},
+		{"DB_MIN_CONNS", "lots"},
+		{"DB_MAX_CONN_LIFETIME", "forever"},
+		{"DB_MAX_CONN_IDLE_TIME", "-1m"},
+		{"JSON_NAMING", "kebab"},
+		{"FIELD_KEYS", "1:short"},
+		{"FIELD_KEYS", "1:not base64!"},
+		{"FIELD_KEYS", "nokey"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+			_, err := loadConfig()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.key)
+		})
+	}
+}
@@ -0,0 +1,584 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	// Thumbnails are the only binary responses; let the response validator
+	// read them as opaque strings.
+	openapi3filter.RegisterBodyDecoder("image/png", openapi3filter.FileBodyDecoder)
+}
+
+// loadServedSpec fetches the OpenAPI document from the router itself, so the
+// contract is checked against exactly what clients would download.
+func loadServedSpec(t *testing.T, router http.Handler) (*openapi3.T, routers.Router) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.yaml", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	doc, err := openapi3.NewLoader().LoadFromData(w.Body.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, doc.Validate(context.Background()))
+
+	specRouter, err := gorillamux.NewRouter(doc)
+	require.NoError(t, err)
+	return doc, specRouter
+}
+
+// ========== Contract Tests ==========
+
+func TestContract_HandlersMatchSpec(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+		// skipRequestValidation is set for cases that deliberately send a
+		// request the spec forbids, to check the error response shape.
+		skipRequestValidation bool
+	}{
+		{"GET /openapi.yaml", http.MethodGet, "/openapi.yaml", "", http.StatusOK, false},
+		{"GET /health", http.MethodGet, "/health", "", http.StatusOK, false},
+		{"GET /health/ready", http.MethodGet, "/health/ready", "", http.StatusOK, false},
+		{"GET /_routes", http.MethodGet, "/_routes", "", http.StatusOK, false},
+		{"GET /stats", http.MethodGet, "/stats", "", http.StatusOK, false},
+
+		{"GET /users", http.MethodGet, "/users", "", http.StatusOK, false},
+		{"POST /users", http.MethodPost, "/users", `{"name":"Test","email":"test@example.com"}`, http.StatusCreated, false},
+		{"POST /users duplicate", http.MethodPost, "/users", `{"name":"Alice","email":"alice@example.com"}`, http.StatusConflict, false},
+		{"POST /users invalid json", http.MethodPost, "/users", `not json`, http.StatusBadRequest, true},
+		{"POST /users unknown field", http.MethodPost, "/users", `{"name":"Test","role":"admin"}`, http.StatusBadRequest, true},
+		{"GET /users/1", http.MethodGet, "/users/1", "", http.StatusOK, false},
+		{"GET /users/999", http.MethodGet, "/users/999", "", http.StatusNotFound, false},
+		{"GET /users/abc", http.MethodGet, "/users/abc", "", http.StatusBadRequest, true},
+		{"PUT /users/1", http.MethodPut, "/users/1", `{"name":"Updated","email":"updated@example.com","version":1}`, http.StatusOK, false},
+		{"PUT /users/1 stale", http.MethodPut, "/users/1", `{"name":"Updated","email":"updated@example.com","version":9}`, http.StatusConflict, false},
+		{"PUT /users/1 duplicate email", http.MethodPut, "/users/1", `{"name":"Alice","email":"bob@example.com","version":1}`, http.StatusConflict, false},
+		{"PUT /users/1 no version", http.MethodPut, "/users/1", `{"name":"Updated","email":"updated@example.com"}`, http.StatusPreconditionRequired, false},
+		{"PUT /users/999", http.MethodPut, "/users/999", `{"name":"Ghost","version":1}`, http.StatusNotFound, false},
+		{"DELETE /users/1", http.MethodDelete, "/users/1", "", http.StatusNoContent, false},
+		{"DELETE /users/999", http.MethodDelete, "/users/999", "", http.StatusNotFound, false},
+		{"GET /users/1/posts", http.MethodGet, "/users/1/posts", "", http.StatusOK, false},
+		{"GET /users/1/posts paged", http.MethodGet, "/users/1/posts?page=2&per_page=1&title=post", "", http.StatusOK, false},
+		{"GET /users/42/posts", http.MethodGet, "/users/42/posts", "", http.StatusNotFound, false},
+		{"POST /users/1/posts", http.MethodPost, "/users/1/posts", `{"title":"Test","body":"Content"}`, http.StatusCreated, false},
+		{"POST /users/1/posts duplicate", http.MethodPost, "/users/1/posts", `{"title":"First Post"}`, http.StatusConflict, false},
+		{"POST /users/42/posts", http.MethodPost, "/users/42/posts", `{"title":"Test"}`, http.StatusNotFound, false},
+
+		{"GET /posts", http.MethodGet, "/posts", "", http.StatusOK, false},
+		{"POST /posts", http.MethodPost, "/posts", `{"userId":1,"title":"Test","body":"Content"}`, http.StatusCreated, false},
+		{"POST /posts duplicate", http.MethodPost, "/posts", `{"userId":1,"title":"First Post"}`, http.StatusConflict, false},
+		{"GET /posts/1", http.MethodGet, "/posts/1", "", http.StatusOK, false},
+		{"GET /posts/999", http.MethodGet, "/posts/999", "", http.StatusNotFound, false},
+		{"GET /posts/abc", http.MethodGet, "/posts/abc", "", http.StatusBadRequest, true},
+
+		{"GET /albums", http.MethodGet, "/albums", "", http.StatusOK, false},
+		{"POST /albums", http.MethodPost, "/albums", `{"userId":1,"title":"Test"}`, http.StatusCreated, false},
+		{"GET /albums/1", http.MethodGet, "/albums/1", "", http.StatusOK, false},
+		{"GET /albums/999", http.MethodGet, "/albums/999", "", http.StatusNotFound, false},
+		{"GET /albums/1/photos", http.MethodGet, "/albums/1/photos", "", http.StatusOK, false},
+		{"GET /photos/1", http.MethodGet, "/photos/1", "", http.StatusOK, false},
+		{"GET /photos/999", http.MethodGet, "/photos/999", "", http.StatusNotFound, false},
+		{"GET /photos/1/thumbnail", http.MethodGet, "/photos/1/thumbnail?width=32&height=32", "", http.StatusOK, false},
+		{"GET /photos/1/thumbnail invalid size", http.MethodGet, "/photos/1/thumbnail?size=4", "", http.StatusBadRequest, true},
+
+		{"GET /ingest/events without token", http.MethodGet, "/ingest/events", "", http.StatusUnauthorized, false},
+		{"POST /ingest/events unsigned", http.MethodPost, "/ingest/events", `{"a":1}`, http.StatusUnauthorized, false},
+
+		{"GET /metrics/posts", http.MethodGet, "/metrics/posts", "", http.StatusOK, false},
+		{"GET /metrics/posts hourly", http.MethodGet, "/metrics/posts?from=2024-01-01T00:00:00Z&to=2024-01-03T00:00:00Z&interval=hour", "", http.StatusOK, false},
+		{"GET /metrics/posts invalid", http.MethodGet, "/metrics/posts?interval=week", "", http.StatusBadRequest, true},
+
+		{"GET /places", http.MethodGet, "/places?lat=52.52&lng=13.40&radius=5", "", http.StatusOK, false},
+		{"GET /places invalid", http.MethodGet, "/places?lat=91&lng=abc", "", http.StatusBadRequest, true},
+
+		{"GET /v2/users disabled", http.MethodGet, "/v2/users", "", http.StatusNotFound, false},
+		{"GET /admin/flags without token", http.MethodGet, "/admin/flags", "", http.StatusUnauthorized, false},
+		{"GET /tenants without token", http.MethodGet, "/tenants", "", http.StatusUnauthorized, false},
+
+		{"GET /todos", http.MethodGet, "/todos", "", http.StatusOK, false},
+		{"GET /todos filtered", http.MethodGet, "/todos?completed=false&priority=med&due_after=2024-01-01T00:00:00Z&due_before=2024-03-01T00:00:00Z", "", http.StatusOK, false},
+		{"GET /todos invalid filter", http.MethodGet, "/todos?completed=maybe&priority=urgent", "", http.StatusBadRequest, true},
+		{"POST /todos", http.MethodPost, "/todos", `{"title":"Test","priority":"high","due":"2024-05-01T12:00:00Z"}`, http.StatusCreated, false},
+		{"POST /todos invalid priority", http.MethodPost, "/todos", `{"title":"Test","priority":"urgent"}`, http.StatusBadRequest, true},
+		{"GET /todos/1", http.MethodGet, "/todos/1", "", http.StatusOK, false},
+		{"GET /todos/999", http.MethodGet, "/todos/999", "", http.StatusNotFound, false},
+		{"GET /webhooks without token", http.MethodGet, "/webhooks", "", http.StatusUnauthorized, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			checkContract(t, router, req, tt.body, tt.expectedStatus, tt.skipRequestValidation)
+		})
+	}
+}
+
+// checkContract validates req (whose body is body) against the served spec
+// unless skipRequestValidation is set, serves it, and validates and
+// returns the response.
+func checkContract(t *testing.T, router http.Handler, req *http.Request, body string, expectedStatus int, skipRequestValidation bool) *httptest.ResponseRecorder {
+	t.Helper()
+	_, specRouter := loadServedSpec(t, router)
+
+	route, pathParams, err := specRouter.FindRoute(req)
+	require.NoError(t, err, "route missing from spec")
+
+	reqInput := &openapi3filter.RequestValidationInput{
+		Request:    req,
+		PathParams: pathParams,
+		Route:      route,
+		Options:    &openapi3filter.Options{AuthenticationFunc: openapi3filter.NoopAuthenticationFunc},
+	}
+	if !skipRequestValidation {
+		require.NoError(t, openapi3filter.ValidateRequest(context.Background(), reqInput))
+		// ValidateRequest consumes the body; hand the handler a fresh copy.
+		req.Body = io.NopCloser(strings.NewReader(body))
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, expectedStatus, w.Code)
+
+	respInput := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: reqInput,
+		Status:                 w.Code,
+		Header:                 w.Header(),
+		Body:                   io.NopCloser(bytes.NewReader(w.Body.Bytes())),
+		Options:                &openapi3filter.Options{IncludeResponseStatus: true},
+	}
+	assert.NoError(t, openapi3filter.ValidateResponse(context.Background(), respInput))
+	return w
+}
+
+func TestContract_ConditionalDelete(t *testing.T) {
+	router := setupRouter()
+
+	req := httptest.NewRequest(http.MethodGet, "/posts/1", nil)
+	checkContract(t, router, req, "", http.StatusOK, false)
+
+	req = httptest.NewRequest(http.MethodDelete, "/posts/1", nil)
+	req.Header.Set("If-Unmodified-Since", "Sun, 31 Dec 2023 23:00:00 GMT")
+	checkContract(t, router, req, "", http.StatusPreconditionFailed, false)
+
+	req = httptest.NewRequest(http.MethodDelete, "/posts/1", nil)
+	req.Header.Set("If-Unmodified-Since", "Mon, 01 Jan 2024 09:15:00 GMT")
+	checkContract(t, router, req, "", http.StatusNoContent, false)
+
+	req = httptest.NewRequest(http.MethodDelete, "/posts/1", nil)
+	checkContract(t, router, req, "", http.StatusNotFound, false)
+}
+
+func TestContract_UserImport(t *testing.T) {
+	router := setupRouter()
+
+	body := "name,email\nCarol,carol@example.com\nAlice,alice@example.com\nDave,dave\n"
+	checkContract(t, router, newCSVImportRequest(body), body, http.StatusOK, false)
+
+	body = "name\n"
+	checkContract(t, router, newCSVImportRequest(body), body, http.StatusBadRequest, false)
+}
+
+func TestContract_BulkPosts(t *testing.T) {
+	router := setupRouter()
+
+	body := `{"userId":1,"title":"Bulk"}` + "\n" + `{"userId":1,"title":"First Post"}` + "\n"
+	checkContract(t, router, newBulkPostsRequest(body), body, http.StatusOK, false)
+}
+
+func TestContract_Msgpack(t *testing.T) {
+	router := setupRouter()
+
+	body := encodeBody(t, msgpackCodec, User{Name: "Mia", Email: "mia@example.com"})
+	checkContract(t, router, newCodecRequest(http.MethodPost, "/users", msgpackCodec, body, "application/msgpack"), string(body), http.StatusCreated, false)
+	checkContract(t, router, newCodecRequest(http.MethodGet, "/posts", nil, nil, "application/msgpack"), "", http.StatusOK, false)
+	checkContract(t, router, newCodecRequest(http.MethodGet, "/posts/999", nil, nil, "application/msgpack"), "", http.StatusNotFound, false)
+}
+
+func TestContract_CBOR(t *testing.T) {
+	router := setupRouter()
+
+	body := encodeBody(t, cborCodec, User{ID: 1, Name: "Alicia", Email: "alicia@example.com", Version: 1})
+	checkContract(t, router, newCodecRequest(http.MethodPut, "/users/1", cborCodec, body, "application/cbor"), string(body), http.StatusOK, false)
+	checkContract(t, router, newCodecRequest(http.MethodGet, "/users", nil, nil, "application/cbor"), "", http.StatusOK, false)
+}
+
+func TestContract_Protobuf(t *testing.T) {
+	router := setupRouter()
+
+	body := encodeBody(t, protobufPosts, Post{UserID: 1, Title: "Proto"})
+	checkContract(t, router, newCodecRequest(http.MethodPost, "/posts", protobufPosts, body, "application/x-protobuf"), string(body), http.StatusCreated, false)
+	checkContract(t, router, newCodecRequest(http.MethodGet, "/posts", nil, nil, "application/x-protobuf"), "", http.StatusOK, false)
+}
+
+func TestContract_ItemRanges(t *testing.T) {
+	router := setupRouter()
+
+	req := httptest.NewRequest(http.MethodGet, "/todos", nil)
+	req.Header.Set("Range", "items=0-1")
+	checkContract(t, router, req, "", http.StatusPartialContent, false)
+
+	req = httptest.NewRequest(http.MethodGet, "/posts", nil)
+	req.Header.Set("Range", "items=10-19")
+	checkContract(t, router, req, "", http.StatusRequestedRangeNotSatisfiable, false)
+}
+
+func TestContract_UnsupportedMediaType(t *testing.T) {
+	router := setupRouter()
+
+	req := httptest.NewRequest(http.MethodPost, "/todos", strings.NewReader(`<todo/>`))
+	req.Header.Set("Content-Type", "application/xml")
+	checkContract(t, router, req, `<todo/>`, http.StatusUnsupportedMediaType, true)
+}
+
+func TestContract_Me(t *testing.T) {
+	router := setupRouter()
+	useAuthSecret(t)
+
+	checkContract(t, router, newUserRequest(1, http.MethodGet, "/me", ""), "", http.StatusOK, false)
+	body := `{"name":"Alicia","email":"alicia@example.com","version":1}`
+	checkContract(t, router, newUserRequest(1, http.MethodPut, "/me", body), body, http.StatusOK, false)
+	checkContract(t, router, newUserRequest(1, http.MethodGet, "/me/posts", ""), "", http.StatusOK, false)
+	checkContract(t, router, httptest.NewRequest(http.MethodGet, "/me", nil), "", http.StatusUnauthorized, false)
+}
+
+func TestContract_Passwords(t *testing.T) {
+	router := setupRouter()
+	usePasswords(t)
+
+	body := `{"newPassword":"correct horse"}`
+	checkContract(t, router, newUserRequest(1, http.MethodPost, "/users/1/password", body), body, http.StatusNoContent, false)
+	body = `{"currentPassword":"wrong","newPassword":"battery staple"}`
+	checkContract(t, router, newUserRequest(1, http.MethodPost, "/users/1/password", body), body, http.StatusForbidden, false)
+	body = `{"email":"alice@example.com"}`
+	checkContract(t, router, newAdminRequest(http.MethodPost, "/auth/password-reset", body), body, http.StatusAccepted, false)
+	hash, err := server.Store.PasswordHash(1)
+	require.NoError(t, err)
+	body = `{"token":"` + server.passwordResetToken(1, hash) + `","password":"battery staple"}`
+	checkContract(t, router, newAdminRequest(http.MethodPost, "/auth/password-reset/confirm", body), body, http.StatusNoContent, false)
+	body = `{"token":"1.2.3","password":"battery staple"}`
+	checkContract(t, router, newAdminRequest(http.MethodPost, "/auth/password-reset/confirm", body), body, http.StatusBadRequest, false)
+}
+
+func TestContract_Sessions(t *testing.T) {
+	router := setupRouter()
+	useAuthSecret(t)
+
+	checkContract(t, router, newUserRequest(1, http.MethodGet, "/me/sessions", ""), "", http.StatusOK, false)
+	checkContract(t, router, newUserRequest(1, http.MethodDelete, "/me/sessions/1", ""), "", http.StatusNoContent, false)
+	checkContract(t, router, newUserRequest(1, http.MethodDelete, "/me/sessions/1", ""), "", http.StatusNotFound, false)
+}
+
+func TestContract_TwoFactor(t *testing.T) {
+	router := setupRouter()
+	usePasswords(t)
+	freezeClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	setTestPassword(t, 1, "correct horse")
+
+	body := `{"code":"123456"}`
+	checkContract(t, router, newUserRequest(1, http.MethodPost, "/auth/2fa/verify", body), body, http.StatusConflict, false)
+	checkContract(t, router, newUserRequest(1, http.MethodPost, "/auth/2fa/setup", ""), "", http.StatusOK, false)
+	tf, err := server.Store.TwoFactor(1)
+	require.NoError(t, err)
+	secret, err := server.openTOTPSecret(tf.Secret)
+	require.NoError(t, err)
+	body = `{"code":"` + currentTOTP(secret) + `"}`
+	checkContract(t, router, newUserRequest(1, http.MethodPost, "/auth/2fa/verify", body), body, http.StatusNoContent, false)
+	checkContract(t, router, newUserRequest(1, http.MethodPost, "/auth/2fa/setup", ""), "", http.StatusConflict, false)
+
+	body = `{"email":"alice@example.com","password":"correct horse"}`
+	checkContract(t, router, newAdminRequest(http.MethodPost, "/auth/login", body), body, http.StatusUnauthorized, false)
+	body = `{"email":"alice@example.com","password":"correct horse","code":"` + currentTOTP(secret) + `"}`
+	checkContract(t, router, newAdminRequest(http.MethodPost, "/auth/login", body), body, http.StatusOK, false)
+}
+
+func TestContract_EmailVerification(t *testing.T) {
+	router := setupRouter()
+	useAuthSecret(t)
+	useTestMailer(t)
+
+	checkContract(t, router, newUserRequest(1, http.MethodPost, "/users/1/verify/send", ""), "", http.StatusAccepted, false)
+	checkContract(t, router, httptest.NewRequest(http.MethodGet, "/verify?token="+server.verificationToken(1, "alice@example.com"), nil), "", http.StatusOK, false)
+	checkContract(t, router, newUserRequest(1, http.MethodPost, "/users/1/verify/send", ""), "", http.StatusConflict, false)
+	checkContract(t, router, httptest.NewRequest(http.MethodGet, "/verify?token=bad", nil), "", http.StatusBadRequest, false)
+}
+
+func TestContract_Invites(t *testing.T) {
+	router := setupAdminRouter(t)
+	useAuthSecret(t)
+	useTestMailer(t)
+
+	body := `{"email":"carol@example.com"}`
+	checkContract(t, router, newAdminRequest(http.MethodPost, "/invites", body), body, http.StatusCreated, false)
+	token := server.inviteToken("carol@example.com", time.Now().Add(time.Hour))
+	checkContract(t, router, httptest.NewRequest(http.MethodGet, "/invites/"+token, nil), "", http.StatusOK, false)
+	checkContract(t, router, httptest.NewRequest(http.MethodGet, "/invites/nope", nil), "", http.StatusNotFound, false)
+	expired := server.inviteToken("carol@example.com", time.Now().Add(-time.Hour))
+	checkContract(t, router, httptest.NewRequest(http.MethodGet, "/invites/"+expired, nil), "", http.StatusGone, false)
+	body = `{"name":"Carol"}`
+	checkContract(t, router, newAdminRequest(http.MethodPost, "/invites/"+token+"/accept", body), body, http.StatusCreated, false)
+	checkContract(t, router, newAdminRequest(http.MethodPost, "/invites/"+token+"/accept", body), body, http.StatusConflict, false)
+}
+
+func TestContract_Usage(t *testing.T) {
+	router := setupRouter()
+	useAuthSecret(t)
+	useQuota(t, 1, time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC))
+
+	checkContract(t, router, newUserRequest(1, http.MethodGet, "/me/usage", ""), "", http.StatusOK, false)
+	checkContract(t, router, newUserRequest(1, http.MethodGet, "/me/usage", ""), "", http.StatusTooManyRequests, false)
+	checkContract(t, router, httptest.NewRequest(http.MethodGet, "/me/usage", nil), "", http.StatusUnauthorized, false)
+}
+
+func TestContract_SignedIngest(t *testing.T) {
+	router := setupAdminRouter(t)
+	server.Config.IngestSecret = []byte("contract")
+
+	body := `{"action":"opened","number":7}`
+	req := httptest.NewRequest(http.MethodPost, "/ingest/events", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(headerHubSignature, sign(server.Config.IngestSecret, []byte(body)))
+	checkContract(t, router, req, body, http.StatusAccepted, false)
+
+	req = newAdminRequest(http.MethodGet, "/ingest/events", "")
+	checkContract(t, router, req, "", http.StatusOK, false)
+	req = newAdminRequest(http.MethodGet, "/ingest/events/1", "")
+	checkContract(t, router, req, "", http.StatusOK, false)
+	req = newAdminRequest(http.MethodGet, "/ingest/events/999", "")
+	checkContract(t, router, req, "", http.StatusNotFound, false)
+}
+
+func TestContract_Tenants(t *testing.T) {
+	router := setupAdminRouter(t)
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{"POST /tenants", http.MethodPost, "/tenants", `{"name":"Acme","rateLimit":60,"flags":{"read_only":true},"allowedOrigins":["https://acme.example"]}`, http.StatusCreated},
+		{"GET /tenants", http.MethodGet, "/tenants", "", http.StatusOK},
+		{"GET /tenants/1", http.MethodGet, "/tenants/1", "", http.StatusOK},
+		{"PUT /tenants/1", http.MethodPut, "/tenants/1", `{"name":"Acme","version":1}`, http.StatusOK},
+		{"PUT /tenants/1 stale", http.MethodPut, "/tenants/1", `{"name":"Acme","version":1}`, http.StatusConflict},
+		{"PUT /tenants/1 no version", http.MethodPut, "/tenants/1", `{"name":"Acme"}`, http.StatusPreconditionRequired},
+		{"DELETE /tenants/1", http.MethodDelete, "/tenants/1", "", http.StatusNoContent},
+		{"GET /tenants/1 deleted", http.MethodGet, "/tenants/1", "", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newAdminRequest(tt.method, tt.path, tt.body)
+			checkContract(t, router, req, tt.body, tt.expectedStatus, false)
+		})
+	}
+}
+
+func TestContract_Admin(t *testing.T) {
+	router := setupAdminRouter(t)
+
+	req := newAdminRequest(http.MethodGet, "/admin/flags", "")
+	checkContract(t, router, req, "", http.StatusOK, false)
+
+	req = newAdminRequest(http.MethodGet, "/admin/config", "")
+	checkContract(t, router, req, "", http.StatusOK, false)
+
+	req = newAdminRequest(http.MethodGet, "/admin/dbstats", "")
+	checkContract(t, router, req, "", http.StatusNotFound, false)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodGet, "/admin/state", ""))
+	state := w.Body.String()
+	req = newAdminRequest(http.MethodGet, "/admin/state", "")
+	checkContract(t, router, req, "", http.StatusOK, false)
+	req = newAdminRequest(http.MethodPut, "/admin/state", state)
+	checkContract(t, router, req, state, http.StatusOK, false)
+
+	body := `{"enable_v2_users":true}`
+	req = newAdminRequest(http.MethodPatch, "/admin/flags", body)
+	checkContract(t, router, req, body, http.StatusOK, false)
+
+	req = httptest.NewRequest(http.MethodGet, "/v2/users", nil)
+	checkContract(t, router, req, "", http.StatusOK, false)
+
+	req = httptest.NewRequest(http.MethodGet, "/v2/users/1", nil)
+	checkContract(t, router, req, "", http.StatusOK, false)
+}
+
+func TestContract_IPRules(t *testing.T) {
+	router := setupAdminRouter(t)
+
+	req := newAdminRequest(http.MethodGet, "/admin/ip-rules", "")
+	checkContract(t, router, req, "", http.StatusOK, false)
+
+	body := `{"allow":["10.0.0.0/8"],"deny":[]}`
+	req = newAdminRequest(http.MethodPut, "/admin/ip-rules", body)
+	checkContract(t, router, req, body, http.StatusOK, false)
+
+	req = newAdminRequest(http.MethodGet, "/admin/ip-rules", "")
+	checkContract(t, router, req, "", http.StatusForbidden, false)
+}
+
+func TestContract_Webhooks(t *testing.T) {
+	tests := []struct {
+		name                  string
+		method                string
+		path                  string
+		body                  string
+		expectedStatus        int
+		skipRequestValidation bool
+	}{
+		{"GET /webhooks", http.MethodGet, "/webhooks", "", http.StatusOK, false},
+		{"POST /webhooks", http.MethodPost, "/webhooks", `{"url":"https://example.com/hook","events":["users.created"]}`, http.StatusCreated, false},
+		{"POST /webhooks relative url", http.MethodPost, "/webhooks", `{"url":"/hook"}`, http.StatusBadRequest, true},
+		{"GET /webhooks/999", http.MethodGet, "/webhooks/999", "", http.StatusNotFound, false},
+		{"DELETE /webhooks/999", http.MethodDelete, "/webhooks/999", "", http.StatusNotFound, false},
+		{"GET /webhooks/999/deliveries", http.MethodGet, "/webhooks/999/deliveries", "", http.StatusNotFound, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupAdminRouter(t)
+			req := newAdminRequest(tt.method, tt.path, tt.body)
+			checkContract(t, router, req, tt.body, tt.expectedStatus, tt.skipRequestValidation)
+		})
+	}
+}
+
+func TestContract_WebhookDeliveries(t *testing.T) {
+	router := setupWebhookRouter(t)
+	createTestWebhook(t, router, `{"url":"https://example.invalid/hook"}`)
+	createTestUser(t, router)
+
+	req := newAdminRequest(http.MethodGet, "/webhooks/1/deliveries", "")
+	checkContract(t, router, req, "", http.StatusOK, false)
+
+	req = newAdminRequest(http.MethodDelete, "/webhooks/1", "")
+	checkContract(t, router, req, "", http.StatusNoContent, false)
+}
+
+func TestContract_SnakeNaming(t *testing.T) {
+	tests := []struct {
+		name                  string
+		method                string
+		path                  string
+		body                  string
+		expectedStatus        int
+		skipRequestValidation bool
+	}{
+		{"GET /stats", http.MethodGet, "/stats", "", http.StatusOK, false},
+		{"GET /users/1", http.MethodGet, "/users/1", "", http.StatusOK, false},
+		{"PUT /users/1", http.MethodPut, "/users/1", `{"name":"Updated","email":"updated@example.com","version":1}`, http.StatusOK, false},
+		{"POST /posts", http.MethodPost, "/posts", `{"user_id":1,"title":"Test","body":"Content"}`, http.StatusCreated, false},
+		{"POST /posts declared name", http.MethodPost, "/posts", `{"userId":1,"title":"Test"}`, http.StatusBadRequest, true},
+		{"POST /albums", http.MethodPost, "/albums", `{"user_id":1,"title":"Test"}`, http.StatusCreated, false},
+		{"GET /todos", http.MethodGet, "/todos", "", http.StatusOK, false},
+		{"GET /users/999", http.MethodGet, "/users/999", "", http.StatusNotFound, false},
+		{"POST /webhooks relative url", http.MethodPost, "/webhooks", `{"url":"/hook"}`, http.StatusBadRequest, true},
+		{"GET /admin/flags", http.MethodGet, "/admin/flags", "", http.StatusOK, false},
+		{"PUT /tenants/1", http.MethodPut, "/tenants/1", `{"name":"Acme","rate_limit":60,"allowed_origins":["https://acme.example"],"version":1}`, http.StatusOK, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupAdminRouter(t)
+			useFieldNaming(t, "snake")
+			createTestTenant(t, Tenant{Name: "Acme"})
+			req := newAdminRequest(tt.method, tt.path, tt.body)
+			checkContract(t, router, req, tt.body, tt.expectedStatus, tt.skipRequestValidation)
+		})
+	}
+}
+
+func TestContract_EveryRouteIsDocumented(t *testing.T) {
+	router := setupRouter()
+	doc, _ := loadServedSpec(t, router)
+
+	err := chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		// The profiler is mounted for operators, not documented.
+		if strings.HasPrefix(route, "/debug/") {
+			return nil
+		}
+		path := strings.TrimSuffix(route, "/")
+		if path == "" {
+			path = "/"
+		}
+		item := doc.Paths.Find(path)
+		if assert.NotNil(t, item, "%s %s is routed but not in the spec", method, path) {
+			assert.NotNil(t, item.GetOperation(method), "%s %s is routed but not in the spec", method, path)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestContract_EveryDocumentedOperationIsRouted(t *testing.T) {
+	router := setupRouter()
+	doc, _ := loadServedSpec(t, router)
+
+	for path, item := range doc.Paths.Map() {
+		for method := range item.Operations() {
+			rctx := chi.NewRouteContext()
+			// Substitute a valid value for each path parameter.
+			concrete := strings.NewReplacer("{id}", "1").Replace(path)
+			assert.True(t, router.Match(rctx, method, concrete), "%s %s is in the spec but not routed", method, path)
+		}
+	}
+}
+
+func TestContract_OperationIDsAndTagsMatchRouteTable(t *testing.T) {
+	router := setupRouter()
+	doc, _ := loadServedSpec(t, router)
+
+	seen := map[string]bool{}
+	for _, d := range append(server.apiRouteDefs(), server.opsRouteDefs()...) {
+		assert.False(t, seen[d.OperationID], "operationId %s is not unique", d.OperationID)
+		seen[d.OperationID] = true
+
+		item := doc.Paths.Find(d.Pattern)
+		if !assert.NotNil(t, item, "%s %s is not in the spec", d.Method, d.Pattern) {
+			continue
+		}
+		op := item.GetOperation(d.Method)
+		if !assert.NotNil(t, op, "%s %s is not in the spec", d.Method, d.Pattern) {
+			continue
+		}
+		assert.Equal(t, d.OperationID, op.OperationID, "%s %s", d.Method, d.Pattern)
+		assert.Equal(t, []string{d.Tag}, op.Tags, "%s %s", d.Method, d.Pattern)
+	}
+	assert.Len(t, seen, countOperations(doc), "every documented operation is in the route table")
+}
+
+// countOperations is the number of operations in doc.
+func countOperations(doc *openapi3.T) int {
+	n := 0
+	for _, item := range doc.Paths.Map() {
+		n += len(item.Operations())
+	}
+	return n
+}
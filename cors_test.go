@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ========== CORS Tests ==========
+
+func TestOriginAllowed(t *testing.T) {
+	tests := []struct {
+		name     string
+		allowed  []string
+		origin   string
+		expected bool
+	}{
+		{"exact", []string{"https://a.example"}, "https://a.example", true},
+		{"case-insensitive", []string{"https://A.example"}, "https://a.example", true},
+		{"wildcard", []string{"*"}, "https://b.example", true},
+		{"other", []string{"https://a.example"}, "https://b.example", false},
+		{"none", nil, "https://a.example", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, originAllowed(tt.allowed, tt.origin))
+		})
+	}
+}
+
+func TestAllowCORS(t *testing.T) {
+	w := httptest.NewRecorder()
+	handled := allowCORS(w, httptest.NewRequest(http.MethodGet, "/", nil), "https://a.example")
+	assert.False(t, handled)
+	assert.Equal(t, "https://a.example", w.Header().Get("Access-Control-Allow-Origin"))
+
+	req := httptest.NewRequest(http.MethodOptions, "/", nil)
+	req.Header.Set("Access-Control-Request-Method", http.MethodPut)
+	w = httptest.NewRecorder()
+	handled = allowCORS(w, req, "https://a.example")
+	assert.True(t, handled)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), http.MethodPut)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// Count is the body of the counting endpoints.
+type Count struct {
+	Count int `json:"count"`
+}
+
+func (srv *Server) countUsers(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, Count{Count: srv.requestStore(r).CountUsers()})
+}
+
+// countPosts counts the posts by ?userId=, or every post without it. An
+// unknown user has written none.
+func (srv *Server) countPosts(w http.ResponseWriter, r *http.Request) {
+	userID := 0
+	if v := r.URL.Query().Get("userId"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			respondProblem(w, r, http.StatusBadRequest, "invalid request", Problem{
+				Violations: []string{fmt.Sprintf("query parameter %q: must be a positive integer", "userId")},
+			})
+			return
+		}
+		userID = n
+	}
+	respondJSON(w, http.StatusOK, Count{Count: srv.requestStore(r).CountPosts(userID)})
+}
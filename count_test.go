@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== Count Tests ==========
+
+func TestCountUsers(t *testing.T) {
+	router := setupRouter()
+	_, err := server.Store.CreateUser(User{Name: "Carol"})
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/count", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"count":3}`, w.Body.String())
+}
+
+func TestCountPosts(t *testing.T) {
+	router := setupRouter()
+	_, err := server.Store.CreatePost(Post{UserID: 2, Title: "Bob's"})
+	require.NoError(t, err)
+	require.NoError(t, server.Store.DeletePost(1, time.Time{}))
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"", 2},
+		{"?userId=1", 1},
+		{"?userId=2", 1},
+		{"?userId=99", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/posts/count"+tt.query, nil))
+
+			require.Equal(t, http.StatusOK, w.Code)
+			var got Count
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+			assert.Equal(t, tt.want, got.Count)
+		})
+	}
+}
+
+func TestCountPosts_InvalidUserID(t *testing.T) {
+	router := setupRouter()
+
+	for _, v := range []string{"0", "-1", "one"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/posts/count?userId="+v, nil))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, v)
+		assert.Contains(t, w.Body.String(), `query parameter \"userId\": must be a positive integer`, v)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a parsed cron expression: five fields, minute, hour, day
+// of month, month and day of week, each "*", a number, a range "a-b" or a
+// comma-separated list of them, any of which may step with "/n"; or a
+// macro such as "@daily"; or "@every <duration>". Times are UTC.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// domAll and dowAll are set when the day fields are "*". Cron matches
+	// a day on either field when both are restricted, on both otherwise.
+	domAll, dowAll bool
+	// every, when set, replaces the fields: runs are every apart.
+	every time.Duration
+}
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// parseCron parses spec as described at cronSchedule.
+func parseCron(spec string) (cronSchedule, error) {
+	if d, ok := strings.CutPrefix(spec, "@every "); ok {
+		every, err := time.ParseDuration(strings.TrimSpace(d))
+		if err != nil || every < time.Second {
+			return cronSchedule{}, fmt.Errorf("cron %q: @every needs a duration of at least 1s", spec)
+		}
+		return cronSchedule{every: every}, nil
+	}
+	if expanded, ok := cronMacros[spec]; ok {
+		spec = expanded
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return cronSchedule{}, fmt.Errorf("cron %q: want 5 fields, got %d", spec, len(fields))
+	}
+	var c cronSchedule
+	var err error
+	if c.minute, _, err = parseCronField(fields[0], 0, 59); err != nil {
+		return cronSchedule{}, fmt.Errorf("cron %q: minute: %w", spec, err)
+	}
+	if c.hour, _, err = parseCronField(fields[1], 0, 23); err != nil {
+		return cronSchedule{}, fmt.Errorf("cron %q: hour: %w", spec, err)
+	}
+	if c.dom, c.domAll, err = parseCronField(fields[2], 1, 31); err != nil {
+		return cronSchedule{}, fmt.Errorf("cron %q: day of month: %w", spec, err)
+	}
+	if c.month, _, err = parseCronField(fields[3], 1, 12); err != nil {
+		return cronSchedule{}, fmt.Errorf("cron %q: month: %w", spec, err)
+	}
+	if c.dow, c.dowAll, err = parseCronField(fields[4], 0, 7); err != nil {
+		return cronSchedule{}, fmt.Errorf("cron %q: day of week: %w", spec, err)
+	}
+	// 7 is Sunday too.
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	return c, nil
+}
+
+// parseCronField returns the values field allows between first and last as a
+// bit set, and whether field is "*".
+func parseCronField(field string, first, last int) (bits uint64, all bool, err error) {
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if r, s, ok := strings.Cut(part, "/"); ok {
+			if step, err = strconv.Atoi(s); err != nil || step < 1 {
+				return 0, false, fmt.Errorf("bad step %q", s)
+			}
+			rng = r
+		}
+		lo, hi := first, last
+		switch {
+		case rng == "*":
+			all = all || step == 1
+		case strings.Contains(rng, "-"):
+			a, b, _ := strings.Cut(rng, "-")
+			if lo, err = strconv.Atoi(a); err != nil {
+				return 0, false, fmt.Errorf("bad value %q", a)
+			}
+			if hi, err = strconv.Atoi(b); err != nil {
+				return 0, false, fmt.Errorf("bad value %q", b)
+			}
+		default:
+			if lo, err = strconv.Atoi(rng); err != nil {
+				return 0, false, fmt.Errorf("bad value %q", rng)
+			}
+			hi = lo
+			if step > 1 {
+				hi = last
+			}
+		}
+		if lo < first || hi > last || lo > hi {
+			return 0, false, fmt.Errorf("%q is outside %d-%d", part, first, last)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, all, nil
+}
+
+// next returns the first time after after that c fires, or the zero time
+// if it never does (as for "0 0 30 2 *").
+func (c cronSchedule) next(after time.Time) time.Time {
+	if c.every > 0 {
+		return after.Add(c.every)
+	}
+	t := after.UTC().Truncate(time.Minute).Add(time.Minute)
+	// Every match recurs within four years, leap days included.
+	limit := t.AddDate(4, 0, 1)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (c cronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domAll || c.dowAll {
+		return dom && dow
+	}
+	return dom || dow
+}
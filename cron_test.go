@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== Cron Tests ==========
+
+func TestCronSchedule_Next(t *testing.T) {
+	// A Friday.
+	after := time.Date(2024, 3, 1, 12, 34, 56, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2024, 3, 1, 12, 35, 0, 0, time.UTC)},
+		{"*/5 * * * *", time.Date(2024, 3, 1, 12, 35, 0, 0, time.UTC)},
+		{"0 * * * *", time.Date(2024, 3, 1, 13, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 3, 1, 13, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{"30 9-17/4 * * *", time.Date(2024, 3, 1, 13, 30, 0, 0, time.UTC)},
+		{"0 0 * * 1", time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either matches.
+		{"0 0 15 * 0", time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 90s", after.Add(90 * time.Second)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			c, err := parseCron(tt.spec)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, c.next(after))
+		})
+	}
+}
+
+func TestParseCron_Invalid(t *testing.T) {
+	for _, spec := range []string{
+		"", "* * * *", "* * * * * *", "60 * * * *", "* 24 * * *", "* * 0 * *",
+		"* * * 13 *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "a * * * *",
+		"@every", "@every soon", "@every 10ms", "@fortnightly",
+	} {
+		_, err := parseCron(spec)
+		assert.Error(t, err, spec)
+	}
+}
@@ -0,0 +1,36 @@
+// Package ctxkit carries request-scoped values through contexts under
+// typed keys. Middleware sets a value with Key.With and handlers read it
+// back with Key.From, without declaring a key type or asserting the
+// value's type themselves.
+package ctxkit
+
+import "context"
+
+// Key identifies a value of type T in a context. Keys are compared by
+// identity, so each is declared once, as a package-level variable.
+type Key[T any] struct {
+	name string
+}
+
+// NewKey returns a new key for values of type T. The name is for
+// debugging only; two keys with the same name are still distinct.
+func NewKey[T any](name string) *Key[T] {
+	return &Key[T]{name: name}
+}
+
+// With returns ctx carrying v under k.
+func (k *Key[T]) With(ctx context.Context, v T) context.Context {
+	return context.WithValue(ctx, k, v)
+}
+
+// From returns the value ctx carries under k, or the zero T and false if
+// it carries none.
+func (k *Key[T]) From(ctx context.Context) (T, bool) {
+	v, ok := ctx.Value(k).(T)
+	return v, ok
+}
+
+// String returns the key's name.
+func (k *Key[T]) String() string {
+	return "ctxkit." + k.name
+}
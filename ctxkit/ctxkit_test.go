@@ -0,0 +1,43 @@
+package ctxkit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ========== Key Tests ==========
+
+func TestKey_WithFrom(t *testing.T) {
+	key := NewKey[int]("count")
+
+	_, ok := key.From(context.Background())
+	assert.False(t, ok)
+
+	ctx := key.With(context.Background(), 3)
+	got, ok := key.From(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, 3, got)
+
+	got, _ = key.From(key.With(ctx, 4))
+	assert.Equal(t, 4, got, "the innermost value wins")
+}
+
+func TestKey_Distinct(t *testing.T) {
+	a, b := NewKey[string]("name"), NewKey[string]("name")
+
+	ctx := a.With(context.Background(), "alice")
+	_, ok := b.From(ctx)
+	assert.False(t, ok, "keys with the same name are still distinct")
+	assert.Equal(t, "ctxkit.name", a.String())
+}
+
+func TestKey_ZeroValue(t *testing.T) {
+	key := NewKey[*int]("ptr")
+
+	ctx := key.With(context.Background(), nil)
+	got, ok := key.From(ctx)
+	assert.Nil(t, got)
+	assert.True(t, ok, "a nil *int is a value like any other")
+}
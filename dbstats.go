@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+)
+
+// DBStats describes the store's database connection pool, for capacity
+// monitoring through GET /admin/dbstats. The fields follow database/sql's
+// DBStats.
+type DBStats struct {
+	// MaxOpenConnections is the pool's size limit.
+	MaxOpenConnections int `json:"maxOpenConnections"`
+	// OpenConnections is InUse plus Idle, and connections being opened.
+	OpenConnections int `json:"openConnections"`
+	InUse           int `json:"inUse"`
+	Idle            int `json:"idle"`
+	// WaitCount is the number of times a caller had to wait for a
+	// connection, and WaitDurationMS the total time spent acquiring one.
+	WaitCount      int64   `json:"waitCount"`
+	WaitDurationMS float64 `json:"waitDurationMs"`
+	// MaxIdleClosed and MaxLifetimeClosed count the connections closed
+	// for having been idle, or open, too long.
+	MaxIdleClosed     int64 `json:"maxIdleClosed"`
+	MaxLifetimeClosed int64 `json:"maxLifetimeClosed"`
+}
+
+// dbStatser is implemented by stores backed by a connection pool.
+type dbStatser interface {
+	DBStats() DBStats
+}
+
+// storeDBStats returns the pool statistics of the server's store, looking
+// through a breaker, or false if the store has no pool.
+func (srv *Server) storeDBStats() (DBStats, bool) {
+	s := srv.Store
+	if bs, ok := s.(*breakerStore); ok {
+		s = bs.next
+	}
+	if ds, ok := s.(dbStatser); ok {
+		return ds.DBStats(), true
+	}
+	return DBStats{}, false
+}
+
+// getDBStats serves the pool statistics. Stores without a database, such
+// as the in-memory one, have none and get 404.
+func (srv *Server) getDBStats(w http.ResponseWriter, r *http.Request) {
+	stats, ok := srv.storeDBStats()
+	if !ok {
+		respondProblem(w, r, http.StatusNotFound, "not found", Problem{Detail: "the store has no database connection pool"})
+		return
+	}
+	respondJSON(w, http.StatusOK, stats)
+}
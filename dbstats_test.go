@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pooledStore is a memoryStore that reports fixed pool statistics.
+type pooledStore struct {
+	*memoryStore
+	stats DBStats
+}
+
+func (s *pooledStore) DBStats() DBStats { return s.stats }
+
+// usePooledStore installs a pooledStore as the server's store for the
+// duration of the test.
+func usePooledStore(t *testing.T, stats DBStats) {
+	t.Helper()
+	prev := server.Store
+	server.Store = &pooledStore{memoryStore: newMemoryStore(server.events), stats: stats}
+	t.Cleanup(func() { server.Store = prev })
+}
+
+// ========== DB Stats Tests ==========
+
+func TestGetDBStats(t *testing.T) {
+	router := setupAdminRouter(t)
+	want := DBStats{MaxOpenConnections: 10, OpenConnections: 3, InUse: 1, Idle: 2, WaitCount: 5, WaitDurationMS: 12.5}
+	usePooledStore(t, want)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodGet, "/admin/dbstats", ""))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, cacheNoStore, w.Header().Get("Cache-Control"))
+	var got DBStats
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, want, got)
+}
+
+func TestGetDBStats_ThroughBreaker(t *testing.T) {
+	want := DBStats{MaxOpenConnections: 4}
+	usePooledStore(t, want)
+	server.Store = newBreakerStore(server.Store, newBreaker(1, time.Second))
+
+	got, ok := server.storeDBStats()
+	require.True(t, ok)
+	assert.Equal(t, want, got)
+}
+
+func TestGetDBStats_MemoryStore(t *testing.T) {
+	router := setupAdminRouter(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodGet, "/admin/dbstats", ""))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/dbstats", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Deprecation marks a route deprecated in the route table. Its responses
+// carry the Deprecation (RFC 9745), Sunset (RFC 8594) and successor-version
+// Link headers, its operation in openapi.yaml is deprecated, and GET
+// /_routes lists it with these details.
+type Deprecation struct {
+	// Since is when the route was deprecated.
+	Since time.Time `json:"since"`
+	// Sunset is when the route may stop being served, if that is set.
+	Sunset *time.Time `json:"sunset,omitempty"`
+	// Successor is the pattern of the route that replaces it, if any. Its
+	// path parameters are filled in from the deprecated route's.
+	Successor string `json:"successor,omitempty"`
+}
+
+// deprecated returns middleware announcing dep on every response,
+// errors included.
+func deprecated(dep Deprecation) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set("Deprecation", "@"+strconv.FormatInt(dep.Since.Unix(), 10))
+			if dep.Sunset != nil {
+				h.Set("Sunset", dep.Sunset.UTC().Format(http.TimeFormat))
+			}
+			if dep.Successor != "" {
+				h.Add("Link", "<"+successorPath(dep.Successor, r)+`>; rel="successor-version"`)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// successorPath fills pattern's path parameters with r's.
+func successorPath(pattern string, r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return pattern
+	}
+	for i, key := range rctx.URLParams.Keys {
+		pattern = strings.ReplaceAll(pattern, "{"+key+"}", rctx.URLParams.Values[i])
+	}
+	return pattern
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== Deprecation Tests ==========
+
+func TestDeprecated_Headers(t *testing.T) {
+	router := setupRouter()
+	useAuthSecret(t)
+
+	for _, tt := range []struct {
+		name string
+		req  *http.Request
+		want int
+	}{
+		{"success", newUserRequest(1, http.MethodGet, "/users/me", ""), http.StatusOK},
+		{"error", httptest.NewRequest(http.MethodGet, "/users/me", nil), http.StatusUnauthorized},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, tt.req)
+			require.Equal(t, tt.want, w.Code)
+			assert.Equal(t, "@1717200000", w.Header().Get("Deprecation"))
+			assert.Equal(t, "Tue, 01 Jun 2027 00:00:00 GMT", w.Header().Get("Sunset"))
+			assert.Equal(t, `</me>; rel="successor-version"`, w.Header().Get("Link"))
+		})
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newUserRequest(1, http.MethodGet, "/me", ""))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Deprecation"), "the successor is not deprecated")
+	assert.Empty(t, w.Header().Get("Link"))
+}
+
+func TestDeprecated_SuccessorPathParams(t *testing.T) {
+	r := chi.NewRouter()
+	r.With(deprecated(Deprecation{Since: time.Unix(0, 0), Successor: "/v2/users/{id}/posts/{postId}"})).
+		Get("/users/{id}/posts/{postId}", func(w http.ResponseWriter, r *http.Request) {})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/7/posts/3", nil))
+	assert.Equal(t, "@0", w.Header().Get("Deprecation"))
+	assert.Empty(t, w.Header().Get("Sunset"), "no sunset is scheduled")
+	assert.Equal(t, `</v2/users/7/posts/3>; rel="successor-version"`, w.Header().Get("Link"))
+}
+
+func TestListRoutes_Deprecated(t *testing.T) {
+	router := setupRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/_routes", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var routes []RouteInfo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &routes))
+	deprecated := map[string]*Deprecation{}
+	for _, route := range routes {
+		if route.Deprecated != nil {
+			deprecated[route.OperationID] = route.Deprecated
+		}
+	}
+	require.Contains(t, deprecated, "getMyUser")
+	assert.Equal(t, "/me", deprecated["getMyUser"].Successor)
+	assert.True(t, deprecated["getMyUser"].Since.Equal(usersMeDeprecated))
+	require.NotNil(t, deprecated["getMyUser"].Sunset)
+	assert.True(t, deprecated["getMyUser"].Sunset.Equal(usersMeSunset))
+}
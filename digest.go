@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const (
+	// headerDigest carries RFC 3230 instance digests, such as
+	// "sha-256=<base64>", one or more separated by commas.
+	headerDigest = "Digest"
+	// headerContentMD5 carries the base64 MD5 of the body (RFC 1864).
+	headerContentMD5 = "Content-MD5"
+)
+
+// digestAlgorithms are the Digest algorithms checked on requests, by their
+// lowercased RFC 3230 names. Requests may name others; they are ignored.
+var digestAlgorithms = map[string]func() hash.Hash{
+	"md5":     md5.New,
+	"sha-256": sha256.New,
+	"sha-512": sha512.New,
+}
+
+// maxDigestBodyBytes bounds the body buffered to check its digest: the
+// largest body any route accepts.
+const maxDigestBodyBytes = maxBulkBodyBytes
+
+var errDigestInvalid = errors.New("invalid digest")
+
+// digestMismatch reports a request digest that does not match the body.
+type digestMismatch struct {
+	algorithm string
+}
+
+func (e digestMismatch) Error() string {
+	return e.algorithm + " digest does not match the body"
+}
+
+// requestDigests returns the digests r claims for its body from its Digest
+// and Content-MD5 headers, keyed by algorithm and decoded. Algorithms not in
+// digestAlgorithms are left out.
+func requestDigests(r *http.Request) (map[string][]byte, error) {
+	digests := map[string][]byte{}
+	for _, header := range r.Header.Values(headerDigest) {
+		for _, part := range strings.Split(header, ",") {
+			algorithm, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+			if !ok {
+				return nil, errDigestInvalid
+			}
+			algorithm = strings.ToLower(algorithm)
+			if _, known := digestAlgorithms[algorithm]; !known {
+				continue
+			}
+			sum, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return nil, errDigestInvalid
+			}
+			digests[algorithm] = sum
+		}
+	}
+	if value := r.Header.Get(headerContentMD5); value != "" {
+		sum, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+		if err != nil {
+			return nil, errDigestInvalid
+		}
+		if md5Sum, ok := digests["md5"]; ok && !bytes.Equal(md5Sum, sum) {
+			return nil, digestMismatch{algorithm: "md5"}
+		}
+		digests["md5"] = sum
+	}
+	return digests, nil
+}
+
+// verifyDigests checks body against each of digests.
+func verifyDigests(body []byte, digests map[string][]byte) error {
+	for algorithm, want := range digests {
+		h := digestAlgorithms[algorithm]()
+		h.Write(body)
+		if !bytes.Equal(h.Sum(nil), want) {
+			return digestMismatch{algorithm: algorithm}
+		}
+	}
+	return nil
+}
+
+// digestHeader returns the Digest header value for body.
+func digestHeader(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "sha-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// checkDigests verifies request bodies against their Digest or Content-MD5
+// headers, when they have them, answering 400 on a mismatch; bodies without
+// them pass untouched. It also sends a Digest header with every JSON
+// response, which means holding JSON bodies back until the handler returns.
+func checkDigests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		digests, err := requestDigests(r)
+		if err == nil && len(digests) > 0 {
+			var body []byte
+			body, err = io.ReadAll(http.MaxBytesReader(w, r.Body, maxDigestBodyBytes))
+			r.Body.Close()
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				respondError(w, r, http.StatusRequestEntityTooLarge, "request body too large")
+				return
+			}
+			if err == nil {
+				err = verifyDigests(body, digests)
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		var mismatch digestMismatch
+		if errors.As(err, &mismatch) {
+			respondProblem(w, r, http.StatusBadRequest, "digest mismatch", Problem{Detail: mismatch.Error()})
+			return
+		}
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, "invalid digest")
+			return
+		}
+
+		dw := &digestWriter{ResponseWriter: w}
+		next.ServeHTTP(dw, r)
+		dw.finish()
+	})
+}
+
+// digestWriter holds back JSON bodies so their Digest can be sent as a
+// header. Other bodies, including streams, are written through.
+type digestWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	buffering   bool
+	body        bytes.Buffer
+}
+
+// isJSONMediaType reports whether a Content-Type is JSON, including
+// +json types such as application/problem+json.
+func isJSONMediaType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
+
+func (w *digestWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = status
+	w.buffering = isJSONMediaType(w.Header().Get("Content-Type"))
+	if !w.buffering {
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (w *digestWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.buffering {
+		return w.body.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *digestWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// Flush passes through unless the body is being held back for its digest.
+func (w *digestWriter) Flush() {
+	if w.buffering {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// finish writes a held-back JSON body with its Digest.
+func (w *digestWriter) finish() {
+	if !w.buffering {
+		return
+	}
+	body := w.body.Bytes()
+	w.Header().Set(headerDigest, digestHeader(body))
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(body)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/api2spec/api2spec-fixture-chi/flags"
+)
+
+const digestTestBody = `{"name":"Carol","email":"carol@example.com"}`
+
+func base64Sum(sum []byte) string {
+	return base64.StdEncoding.EncodeToString(sum)
+}
+
+// ========== Request Digest Tests ==========
+
+func TestCheckDigests_Requests(t *testing.T) {
+	md5Sum := md5.Sum([]byte(digestTestBody))
+	sha256Sum := sha256.Sum256([]byte(digestTestBody))
+	sha512Sum := sha512.Sum512([]byte(digestTestBody))
+	otherSum := sha256.Sum256([]byte("other"))
+
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		expected int
+		title    string
+	}{
+		{"no digest", nil, http.StatusCreated, ""},
+		{"sha-256", map[string]string{"Digest": "sha-256=" + base64Sum(sha256Sum[:])}, http.StatusCreated, ""},
+		{"algorithm is case-insensitive", map[string]string{"Digest": "SHA-256=" + base64Sum(sha256Sum[:])}, http.StatusCreated, ""},
+		{"several", map[string]string{"Digest": "sha-512=" + base64Sum(sha512Sum[:]) + ", md5=" + base64Sum(md5Sum[:])}, http.StatusCreated, ""},
+		{"unknown algorithm ignored", map[string]string{"Digest": "unixsum=30637"}, http.StatusCreated, ""},
+		{"content-md5", map[string]string{"Content-MD5": base64Sum(md5Sum[:])}, http.StatusCreated, ""},
+		{"sha-256 mismatch", map[string]string{"Digest": "sha-256=" + base64Sum(otherSum[:])}, http.StatusBadRequest, "digest mismatch"},
+		{"one of several mismatches", map[string]string{"Digest": "sha-256=" + base64Sum(sha256Sum[:]) + ",md5=" + base64Sum(otherSum[:16])}, http.StatusBadRequest, "digest mismatch"},
+		{"content-md5 mismatch", map[string]string{"Content-MD5": base64Sum(otherSum[:16])}, http.StatusBadRequest, "digest mismatch"},
+		{"content-md5 disagrees with digest", map[string]string{"Digest": "md5=" + base64Sum(md5Sum[:]), "Content-MD5": base64Sum(otherSum[:16])}, http.StatusBadRequest, "digest mismatch"},
+		{"not base64", map[string]string{"Digest": "sha-256=not base64!"}, http.StatusBadRequest, ""},
+		{"no value", map[string]string{"Digest": "sha-256"}, http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+			req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(digestTestBody))
+			req.Header.Set("Content-Type", "application/json")
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tt.expected, w.Code, w.Body.String())
+			if tt.title != "" {
+				var problem Problem
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+				assert.Equal(t, tt.title, problem.Title)
+			}
+		})
+	}
+}
+
+func TestCheckDigests_HandlerStillReadsBody(t *testing.T) {
+	router := setupRouter()
+	sum := sha256.Sum256([]byte(digestTestBody))
+
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(digestTestBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Digest", "sha-256="+base64Sum(sum[:]))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusCreated, w.Code)
+	var user User
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+	assert.Equal(t, "Carol", user.Name)
+}
+
+// ========== Response Digest Tests ==========
+
+func TestCheckDigests_Responses(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		digest bool
+	}{
+		{"json", "/users/1", true},
+		{"problem", "/users/999", true},
+		{"ops", "/health", true},
+		{"binary", "/photos/1/thumbnail", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if !tt.digest {
+				assert.Empty(t, w.Header().Get("Digest"))
+				return
+			}
+			sum := sha256.Sum256(w.Body.Bytes())
+			assert.Equal(t, "sha-256="+base64Sum(sum[:]), w.Header().Get("Digest"))
+		})
+	}
+}
+
+func TestCheckDigests_CoversEnvelope(t *testing.T) {
+	router := setupRouter()
+	setFlag(t, flags.EnvelopeResponses)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+
+	require.True(t, strings.HasPrefix(w.Body.String(), `{"data":`), w.Body.String())
+	sum := sha256.Sum256(w.Body.Bytes())
+	assert.Equal(t, "sha-256="+base64Sum(sum[:]), w.Header().Get("Digest"))
+}
@@ -0,0 +1,65 @@
+package main
+
+import "sync"
+
+// EventType identifies what happened to an entity.
+type EventType string
+
+const (
+	EventCreated EventType = "created"
+	EventUpdated EventType = "updated"
+	EventDeleted EventType = "deleted"
+)
+
+// Event describes a lifecycle change to a single entity.
+type Event struct {
+	Type     EventType   `json:"type"`
+	Resource string      `json:"resource"`
+	ID       int         `json:"id"`
+	Data     interface{} `json:"data,omitempty"`
+}
+
+// EventBus is a small in-process pub/sub bus for entity lifecycle events.
+// Subscribers are called synchronously in the publishing goroutine, so
+// anything slow (network delivery, disk writes) should hand off to its own
+// goroutine or queue.
+type EventBus struct {
+	mu     sync.RWMutex
+	nextID int
+	subs   map[int]func(Event)
+}
+
+// NewEventBus returns an empty bus.
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[int]func(Event))}
+}
+
+// Subscribe registers fn for every published event and returns a function
+// that removes the subscription.
+func (b *EventBus) Subscribe(fn func(Event)) (unsubscribe func()) {
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	b.subs[id] = fn
+	b.mu.Unlock()
+
+	return func() {
+		b.mu.Lock()
+		delete(b.subs, id)
+		b.mu.Unlock()
+	}
+}
+
+// Publish delivers e to all current subscribers.
+func (b *EventBus) Publish(e Event) {
+	b.mu.RLock()
+	subs := make([]func(Event), 0, len(b.subs))
+	for _, fn := range b.subs {
+		subs = append(subs, fn)
+	}
+	b.mu.RUnlock()
+
+	for _, fn := range subs {
+		fn(e)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== Event Bus Tests ==========
+
+func TestEventBus_PublishReachesAllSubscribers(t *testing.T) {
+	bus := NewEventBus()
+
+	var got1, got2 []Event
+	bus.Subscribe(func(e Event) { got1 = append(got1, e) })
+	bus.Subscribe(func(e Event) { got2 = append(got2, e) })
+
+	bus.Publish(Event{Type: EventCreated, Resource: "users", ID: 7})
+
+	require.Len(t, got1, 1)
+	require.Len(t, got2, 1)
+	assert.Equal(t, EventCreated, got1[0].Type)
+	assert.Equal(t, 7, got2[0].ID)
+}
+
+func TestEventBus_Unsubscribe(t *testing.T) {
+	bus := NewEventBus()
+
+	count := 0
+	unsubscribe := bus.Subscribe(func(Event) { count++ })
+
+	bus.Publish(Event{Type: EventCreated})
+	unsubscribe()
+	bus.Publish(Event{Type: EventCreated})
+
+	assert.Equal(t, 1, count)
+}
+
+func TestEventBus_ConcurrentPublishAndSubscribe(t *testing.T) {
+	bus := NewEventBus()
+	const numGoroutines = 50
+
+	var mu sync.Mutex
+	received := 0
+	bus.Subscribe(func(Event) {
+		mu.Lock()
+		received++
+		mu.Unlock()
+	})
+
+	var wg sync.WaitGroup
+	wg.Add(numGoroutines * 2)
+	for i := 0; i < numGoroutines; i++ {
+		// Churn short-lived subscriptions while publishing.
+		go func() {
+			defer wg.Done()
+			unsubscribe := bus.Subscribe(func(Event) {})
+			unsubscribe()
+		}()
+		go func(idx int) {
+			defer wg.Done()
+			bus.Publish(Event{Type: EventUpdated, ID: idx})
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, numGoroutines, received)
+}
+
+// ========== Handler Publishing Tests ==========
+
+func TestHandlers_PublishLifecycleEvents(t *testing.T) {
+	router := setupRouter()
+
+	var got []Event
+	unsubscribe := server.events.Subscribe(func(e Event) { got = append(got, e) })
+	defer unsubscribe()
+
+	body, err := json.Marshal(User{Name: "Dana", Email: "dana@example.com", Version: 1})
+	require.NoError(t, err)
+	updateBody, err := json.Marshal(User{Name: "Alicia", Email: "alicia@example.com", Version: 1})
+	require.NoError(t, err)
+
+	requests := []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/users", bytes.NewReader(body)),
+		httptest.NewRequest(http.MethodPut, "/users/1", bytes.NewReader(updateBody)),
+		httptest.NewRequest(http.MethodDelete, "/users/1", nil),
+		httptest.NewRequest(http.MethodPost, "/posts", bytes.NewReader([]byte(`{"title":"t"}`))),
+	}
+	for _, req := range requests {
+		if req.Body != http.NoBody {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	require.Len(t, got, 4)
+	created, ok := got[0].Data.(User)
+	require.True(t, ok)
+	assert.False(t, created.CreatedAt.IsZero())
+	assert.Equal(t, created.CreatedAt, created.UpdatedAt)
+	created.CreatedAt, created.UpdatedAt = time.Time{}, time.Time{}
+	got[0].Data = created
+	assert.Equal(t, Event{Type: EventCreated, Resource: "users", ID: 3, Data: User{ID: 3, Name: "Dana", Email: "dana@example.com", Version: 1}}, got[0])
+	assert.Equal(t, EventUpdated, got[1].Type)
+	assert.Equal(t, Event{Type: EventDeleted, Resource: "users", ID: 1}, got[2])
+	assert.Equal(t, "posts", got[3].Resource)
+}
+
+func TestHandlers_FailedRequestsDoNotPublish(t *testing.T) {
+	router := setupRouter()
+
+	var got []Event
+	unsubscribe := server.events.Subscribe(func(e Event) { got = append(got, e) })
+	defer unsubscribe()
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/users", bytes.NewReader([]byte("not json"))))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/users/abc", nil))
+
+	assert.Empty(t, got)
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// exampleFiles holds the request/response examples, one file per example
+// at testdata/examples/<operationId>/<name>.json. The tests run each one
+// against its handler, so the examples in the spec are what the API does.
+//
+//go:embed testdata/examples
+var exampleFiles embed.FS
+
+// Example is a request to one operation and the API's response to it, on
+// the sample data with the clock at 2024-03-01T12:00:00Z.
+type Example struct {
+	Summary string `json:"summary"`
+	// Path is the request's path and query.
+	Path string `json:"path"`
+	// Request is the JSON request body, if the operation takes one.
+	Request json.RawMessage `json:"request,omitempty"`
+	Status  int             `json:"status"`
+	// Response is the JSON response body.
+	Response json.RawMessage `json:"response"`
+}
+
+// loadExamples reads the examples in fsys, by operation ID and then name.
+func loadExamples(fsys fs.FS) (map[string]map[string]Example, error) {
+	files, err := fs.Glob(fsys, "*/*.json")
+	if err != nil {
+		return nil, err
+	}
+	examples := map[string]map[string]Example{}
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		var ex Example
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&ex); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		if ex.Path == "" || ex.Status == 0 || len(ex.Response) == 0 {
+			return nil, fmt.Errorf("%s: path, status and response are required", file)
+		}
+		op, name := path.Dir(file), strings.TrimSuffix(path.Base(file), ".json")
+		if examples[op] == nil {
+			examples[op] = map[string]Example{}
+		}
+		examples[op][name] = ex
+	}
+	return examples, nil
+}
+
+// exampleObject is an OpenAPI Example Object.
+type exampleObject struct {
+	Summary string `yaml:"summary,omitempty"`
+	Value   any    `yaml:"value"`
+}
+
+// mediaExamples are the examples to add to one media type object.
+type mediaExamples struct {
+	key, value *yaml.Node
+	examples   map[string]exampleObject
+}
+
+// addExamples returns spec with each example added, by name, to the
+// examples of its operation's application/json request body and response.
+// Those media types must be written as block mappings without examples of
+// their own. The rest of spec is kept byte for byte.
+func addExamples(spec []byte, examples map[string]map[string]Example) ([]byte, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(spec, &root); err != nil {
+		return nil, err
+	}
+	ops := map[string]*yaml.Node{}
+	if paths := yamlValue(root.Content[0], "paths"); paths != nil {
+		for i := 1; i < len(paths.Content); i += 2 {
+			item := paths.Content[i]
+			for j := 1; j < len(item.Content); j += 2 {
+				if id := yamlValue(item.Content[j], "operationId"); id != nil {
+					ops[id.Value] = item.Content[j]
+				}
+			}
+		}
+	}
+
+	// media holds the media types given examples, by their key node.
+	media := map[*yaml.Node]*mediaExamples{}
+	add := func(content *yaml.Node, where, name string, ex exampleObject) error {
+		key, value := yamlEntry(content, "application/json")
+		if key == nil {
+			return fmt.Errorf("%s has no application/json content", where)
+		}
+		if media[key] == nil {
+			media[key] = &mediaExamples{key: key, value: value, examples: map[string]exampleObject{}}
+		}
+		media[key].examples[name] = ex
+		return nil
+	}
+	for id, byName := range examples {
+		op := ops[id]
+		if op == nil {
+			return nil, fmt.Errorf("examples for unknown operation %s", id)
+		}
+		for name, ex := range byName {
+			where := id + " example " + name
+			if len(ex.Request) > 0 {
+				var value any
+				if err := json.Unmarshal(ex.Request, &value); err != nil {
+					return nil, fmt.Errorf("%s: request: %w", where, err)
+				}
+				body := yamlValue(op, "requestBody")
+				if err := add(yamlValue(body, "content"), id+" request body", name, exampleObject{ex.Summary, value}); err != nil {
+					return nil, err
+				}
+			}
+			var value any
+			if err := json.Unmarshal(ex.Response, &value); err != nil {
+				return nil, fmt.Errorf("%s: response: %w", where, err)
+			}
+			resp := yamlValue(yamlValue(op, "responses"), strconv.Itoa(ex.Status))
+			if resp == nil || yamlValue(resp, "$ref") != nil {
+				return nil, fmt.Errorf("%s: %s has no inline %d response", where, id, ex.Status)
+			}
+			if err := add(yamlValue(resp, "content"), fmt.Sprintf("%s response %d", id, ex.Status), name, exampleObject{ex.Summary, value}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Each media type's examples go on the lines after its key, so later
+	// insertions are made first.
+	sorted := make([]*mediaExamples, 0, len(media))
+	for _, m := range media {
+		sorted = append(sorted, m)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].key.Line > sorted[j].key.Line })
+	lines := strings.SplitAfter(string(spec), "\n")
+	for _, m := range sorted {
+		if m.value.Kind != yaml.MappingNode || m.value.Style&yaml.FlowStyle != 0 || len(m.value.Content) == 0 {
+			return nil, fmt.Errorf("line %d: %s must be a block mapping to take examples", m.key.Line, m.key.Value)
+		}
+		if k, _ := yamlEntry(m.value, "examples"); k != nil {
+			return nil, fmt.Errorf("line %d: %s already has examples", m.key.Line, m.key.Value)
+		}
+		if k, _ := yamlEntry(m.value, "example"); k != nil {
+			return nil, fmt.Errorf("line %d: %s already has an example", m.key.Line, m.key.Value)
+		}
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(map[string]any{"examples": m.examples}); err != nil {
+			return nil, err
+		}
+		indent := strings.Repeat(" ", m.value.Content[0].Column-1)
+		var block strings.Builder
+		for _, line := range strings.SplitAfter(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+			block.WriteString(indent + line)
+		}
+		block.WriteString("\n")
+		lines = slices.Insert(lines, m.key.Line, block.String())
+	}
+	return []byte(strings.Join(lines, "")), nil
+}
+
+// yamlEntry returns the key and value nodes of name in mapping n, or nils.
+func yamlEntry(n *yaml.Node, name string) (key, value *yaml.Node) {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == name {
+			return n.Content[i], n.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// yamlValue returns the value of name in mapping n, or nil.
+func yamlValue(n *yaml.Node, name string) *yaml.Node {
+	_, value := yamlEntry(n, name)
+	return value
+}
+
+// withExamples is spec with the examples in exampleFiles added. They are
+// embedded, so an error is a bug the tests catch.
+func withExamples(spec []byte) []byte {
+	sub, err := fs.Sub(exampleFiles, "testdata/examples")
+	if err != nil {
+		panic(err)
+	}
+	examples, err := loadExamples(sub)
+	if err != nil {
+		panic(err)
+	}
+	spec, err = addExamples(spec, examples)
+	if err != nil {
+		panic(err)
+	}
+	return spec
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadTestExamples(t *testing.T) map[string]map[string]Example {
+	t.Helper()
+	sub, err := fs.Sub(exampleFiles, "testdata/examples")
+	require.NoError(t, err)
+	examples, err := loadExamples(sub)
+	require.NoError(t, err)
+	require.NotEmpty(t, examples)
+	return examples
+}
+
+// TestExamples runs each example against its handler: the request and
+// response must match the spec, and the response must be the example's.
+func TestExamples(t *testing.T) {
+	methods := map[string]string{}
+	for _, def := range append(server.apiRouteDefs(), server.opsRouteDefs()...) {
+		methods[def.OperationID] = def.Method
+	}
+	for id, byName := range loadTestExamples(t) {
+		for name, ex := range byName {
+			t.Run(id+"/"+name, func(t *testing.T) {
+				method, ok := methods[id]
+				require.True(t, ok, "no operation %s", id)
+				router := setupAdminRouter(t)
+				freezeClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+
+				req := newAdminRequest(method, ex.Path, string(ex.Request))
+				w := checkContract(t, router, req, string(ex.Request), ex.Status, false)
+				assert.JSONEq(t, string(ex.Response), w.Body.String())
+			})
+		}
+	}
+}
+
+func TestExamples_InServedSpec(t *testing.T) {
+	setupRouter()
+	doc, err := server.specDoc()
+	require.NoError(t, err)
+	type operation struct {
+		key string
+		*openapi3.Operation
+	}
+	ops := map[string]*operation{}
+	for path, item := range doc.Paths.Map() {
+		for method, op := range item.Operations() {
+			ops[op.OperationID] = &operation{method + " " + path, op}
+		}
+	}
+
+	for id, byName := range loadTestExamples(t) {
+		op := ops[id]
+		require.NotNil(t, op, "no operation %s", id)
+		for name, ex := range byName {
+			resp := op.Responses.Status(ex.Status)
+			require.NotNil(t, resp, "%s: no %d response", op.key, ex.Status)
+			examples := resp.Value.Content.Get("application/json").Examples
+			require.Contains(t, examples, name, "%s response %d", op.key, ex.Status)
+			assert.Equal(t, ex.Summary, examples[name].Value.Summary)
+			if len(ex.Request) > 0 {
+				examples := op.RequestBody.Value.Content.Get("application/json").Examples
+				require.Contains(t, examples, name, "%s request body", op.key)
+			}
+		}
+	}
+}
+
+func TestAddExamples_Errors(t *testing.T) {
+	spec := []byte(`openapi: 3.0.3
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      responses:
+        "200":
+          description: OK
+          content:
+            application/json: {schema: {type: array}}
+        "404":
+          $ref: "#/components/responses/NotFound"
+`)
+	tests := []struct {
+		name    string
+		example Example
+		id      string
+		want    string
+	}{
+		{"unknown operation", Example{Status: 200}, "getPet", "unknown operation getPet"},
+		{"undocumented status", Example{Status: 500}, "listPets", "no inline 500 response"},
+		{"response by reference", Example{Status: 404}, "listPets", "no inline 404 response"},
+		{"flow mapping", Example{Status: 200}, "listPets", "must be a block mapping"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.example.Path, tt.example.Response = "/pets", []byte(`[]`)
+			_, err := addExamples(spec, map[string]map[string]Example{tt.id: {"empty": tt.example}})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestLoadExamples_RequiresResponse(t *testing.T) {
+	_, err := loadExamples(fstest.MapFS{
+		"getUser/alice.json": {Data: []byte(`{"summary": "A user", "path": "/users/1", "status": 200}`)},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "getUser/alice.json")
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/api2spec/api2spec-fixture-chi/flags"
+)
+
+// withFlags stores the current feature flags in the request context, so a
+// request sees one consistent set even if they are toggled mid-flight.
+func (srv *Server) withFlags(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(flags.NewContext(r.Context(), srv.requestStore(r).GetFlags())))
+	})
+}
+
+// flagged returns middleware that routes requests through mw only while the
+// named flag is on.
+func flagged(name flags.Name, mw func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		wrapped := mw(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if flags.Enabled(r.Context(), name) {
+				wrapped.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requireFlag answers 404 while the named flag is off, hiding the routes
+// behind it.
+func requireFlag(name flags.Name) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !flags.Enabled(r.Context(), name) {
+				respondProblem(w, r, http.StatusNotFound, "not found", Problem{
+					Detail: fmt.Sprintf("%s is disabled", name),
+				})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// envelopeResponses wraps successful application/json bodies in
+// {"data": ...} while the envelope_responses flag is on. Errors and
+// problems are left as they are.
+func envelopeResponses(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !flags.Enabled(r.Context(), flags.EnvelopeResponses) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		bw := &bufferedWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(bw, r)
+		if bw.streamed {
+			return
+		}
+
+		body := bw.body.Bytes()
+		mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+		if mediaType == "application/json" && bw.status >= 200 && bw.status < 300 {
+			var buf bytes.Buffer
+			buf.WriteString(`{"data":`)
+			buf.Write(bytes.TrimRight(body, "\n"))
+			buf.WriteString("}\n")
+			body = buf.Bytes()
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		}
+		w.WriteHeader(bw.status)
+		w.Write(body)
+	})
+}
+
+func (srv *Server) getFlags(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, srv.requestStore(r).GetFlags())
+}
+
+// patchFlags changes the flags present in the body and leaves the rest.
+func (srv *Server) patchFlags(w http.ResponseWriter, r *http.Request) {
+	var patch flags.Patch
+	if err := decodeJSON(r, &patch); err != nil {
+		respondDecodeError(w, r, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, srv.requestStore(r).UpdateFlags(patch))
+}
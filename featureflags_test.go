@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/api2spec/api2spec-fixture-chi/flags"
+)
+
+// setFlag turns the named flag on in the current store.
+func setFlag(t *testing.T, name flags.Name) {
+	t.Helper()
+	on := true
+	var p flags.Patch
+	switch name {
+	case flags.EnableV2Users:
+		p.EnableV2Users = &on
+	case flags.EnableChaos:
+		p.EnableChaos = &on
+	case flags.EnvelopeResponses:
+		p.EnvelopeResponses = &on
+	}
+	server.Store.UpdateFlags(p)
+}
+
+// ========== Admin Flags Tests ==========
+
+func TestGetFlags(t *testing.T) {
+	router := setupAdminRouter(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodGet, "/admin/flags", ""))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"enable_v2_users":false,"enable_chaos":false,"envelope_responses":false}`, w.Body.String())
+}
+
+func TestPatchFlags(t *testing.T) {
+	router := setupAdminRouter(t)
+	setFlag(t, flags.EnableChaos)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPatch, "/admin/flags", `{"enable_v2_users":true}`))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"enable_v2_users":true,"enable_chaos":true,"envelope_responses":false}`, w.Body.String())
+	assert.Equal(t, flags.Set{EnableV2Users: true, EnableChaos: true}, server.Store.GetFlags(), "flags are persisted in the store")
+}
+
+func TestPatchFlags_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"unknown flag", `{"enable_turbo":true}`},
+		{"not a boolean", `{"enable_chaos":"yes"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupAdminRouter(t)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, newAdminRequest(http.MethodPatch, "/admin/flags", tt.body))
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Equal(t, flags.Set{}, server.Store.GetFlags())
+		})
+	}
+}
+
+func TestPatchFlags_RequiresAdmin(t *testing.T) {
+	router := setupAdminRouter(t)
+	req := newAdminRequest(http.MethodPatch, "/admin/flags", `{"enable_chaos":true}`)
+	req.Header.Del("Authorization")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.False(t, server.Store.GetFlags().EnableChaos)
+}
+
+// ========== Flag Middleware Tests ==========
+
+func TestFlagged(t *testing.T) {
+	marker := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Marker", "on")
+			next.ServeHTTP(w, r)
+		})
+	}
+	setupRouter()
+	handler := server.withFlags(flagged(flags.EnableChaos, marker)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Empty(t, w.Header().Get("X-Marker"))
+
+	setFlag(t, flags.EnableChaos)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, "on", w.Header().Get("X-Marker"))
+}
+
+func TestEnvelopeResponses(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"object", "/users/1", http.StatusOK, `{"data":{"id":1,"name":"Alice","email":"alice@example.com","version":1,"postCount":2,"verified":false,"createdAt":"2024-01-01T09:00:00Z","updatedAt":"2024-01-01T09:00:00Z"}}`},
+		{"error left alone", "/nope", http.StatusNotFound, `{"error":"not found"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+			setFlag(t, flags.EnvelopeResponses)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.JSONEq(t, tt.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestEnvelopeResponses_List(t *testing.T) {
+	router := setupRouter()
+	setFlag(t, flags.EnvelopeResponses)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var body struct {
+		Data []User `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Len(t, body.Data, 2)
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// feedSize is how many of the newest posts a feed carries.
+const feedSize = 20
+
+const (
+	atomMediaType = "application/atom+xml"
+	rssMediaType  = "application/rss+xml"
+)
+
+// feedTitle titles both feeds.
+const feedTitle = "api2spec-fixture-chi posts"
+
+// atomFeed is an Atom (RFC 4287) feed document.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID        string     `xml:"id"`
+	Title     string     `xml:"title"`
+	Updated   string     `xml:"updated"`
+	Published string     `xml:"published"`
+	Author    atomPerson `xml:"author"`
+	Link      atomLink   `xml:"link"`
+	Content   atomText   `xml:"content"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+// rssFeed is an RSS 2.0 document. Its channel links to itself with an
+// atom:link, as the RSS Advisory Board recommends.
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Self          atomLink  `xml:"http://www.w3.org/2005/Atom link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Author      string  `xml:"author,omitempty"`
+	Description string  `xml:"description"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	ID          string `xml:",chardata"`
+}
+
+// feedEntry is a post as both feeds show it.
+type feedEntry struct {
+	Post
+	Author User
+	URL    string
+}
+
+// feedOrigin returns the base URL of a feed's links: PUBLIC_URL, or
+// failing that the origin r was sent to. A feed linking to r's Host is
+// kept from shared caches, which would serve its links to clients that
+// sent another.
+func (srv *Server) feedOrigin(w http.ResponseWriter, r *http.Request) string {
+	if srv.Config.PublicURL != "" {
+		return srv.Config.PublicURL
+	}
+	w.Header().Add("Vary", "Host")
+	w.Header().Set("Cache-Control", cachePrivate)
+	return requestOrigin(r)
+}
+
+// feedPosts returns the newest feedSize posts, newest first, with their
+// authors and URLs under origin, and when the newest of them was last
+// updated.
+func (srv *Server) feedPosts(r *http.Request, origin string) ([]feedEntry, time.Time) {
+	store := srv.requestStore(r)
+	posts := store.ListPosts()
+	sort.SliceStable(posts, func(i, j int) bool {
+		if !posts[i].CreatedAt.Equal(posts[j].CreatedAt) {
+			return posts[i].CreatedAt.After(posts[j].CreatedAt)
+		}
+		return posts[i].ID > posts[j].ID
+	})
+	if len(posts) > feedSize {
+		posts = posts[:feedSize]
+	}
+
+	var updated time.Time
+	authors := map[int]User{}
+	entries := make([]feedEntry, len(posts))
+	for i, p := range posts {
+		author, ok := authors[p.UserID]
+		if !ok {
+			if author, _ = store.GetUser(p.UserID); author.ID == 0 {
+				author = User{ID: p.UserID, Name: "user " + strconv.Itoa(p.UserID)}
+			}
+			authors[p.UserID] = author
+		}
+		entries[i] = feedEntry{Post: p, Author: author, URL: origin + "/posts/" + strconv.Itoa(p.ID)}
+		if p.UpdatedAt.After(updated) {
+			updated = p.UpdatedAt
+		}
+	}
+	return entries, updated
+}
+
+// getAtomFeed serves the newest posts as an Atom feed.
+func (srv *Server) getAtomFeed(w http.ResponseWriter, r *http.Request) {
+	origin := srv.feedOrigin(w, r)
+	entries, updated := srv.feedPosts(r, origin)
+	if notModified(w, r, updated) {
+		return
+	}
+	feed := atomFeed{
+		ID:      origin + "/posts",
+		Title:   feedTitle,
+		Updated: feedTime(updated, srv.Clock.Now()).Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "self", Type: atomMediaType, Href: origin + "/posts/feed.atom"},
+			{Rel: "alternate", Type: "application/json", Href: origin + "/posts"},
+		},
+		Entries: make([]atomEntry, len(entries)),
+	}
+	for i, e := range entries {
+		feed.Entries[i] = atomEntry{
+			ID:        e.URL,
+			Title:     e.Title,
+			Updated:   e.UpdatedAt.UTC().Format(time.RFC3339),
+			Published: e.CreatedAt.UTC().Format(time.RFC3339),
+			Author:    atomPerson{Name: e.Author.Name, URI: origin + "/users/" + strconv.Itoa(e.Author.ID)},
+			Link:      atomLink{Rel: "alternate", Type: "application/json", Href: e.URL},
+			Content:   atomText{Type: "text", Text: e.Body},
+		}
+	}
+	srv.respondXML(w, r, atomMediaType, feed)
+}
+
+// getRSSFeed serves the newest posts as an RSS 2.0 feed.
+func (srv *Server) getRSSFeed(w http.ResponseWriter, r *http.Request) {
+	origin := srv.feedOrigin(w, r)
+	entries, updated := srv.feedPosts(r, origin)
+	if notModified(w, r, updated) {
+		return
+	}
+	feed := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:       feedTitle,
+			Link:        origin + "/posts",
+			Description: "The newest posts",
+			Self:        atomLink{Rel: "self", Type: rssMediaType, Href: origin + "/posts/feed.rss"},
+			Items:       make([]rssItem, len(entries)),
+		},
+	}
+	if !updated.IsZero() {
+		feed.Channel.LastBuildDate = updated.UTC().Format(time.RFC1123Z)
+	}
+	for i, e := range entries {
+		feed.Channel.Items[i] = rssItem{
+			Title:       e.Title,
+			Link:        e.URL,
+			GUID:        rssGUID{IsPermaLink: true, ID: e.URL},
+			PubDate:     e.CreatedAt.UTC().Format(time.RFC1123Z),
+			Author:      rssAuthor(e.Author),
+			Description: e.Body,
+		}
+	}
+	srv.respondXML(w, r, rssMediaType, feed)
+}
+
+// rssAuthor is u as RSS writes an item's author: an email address, with
+// the name in parentheses.
+func rssAuthor(u User) string {
+	if u.Email == "" {
+		return ""
+	}
+	return u.Email + " (" + u.Name + ")"
+}
+
+// feedTime is updated, or now for a feed with no posts, which Atom still
+// requires a time for.
+func feedTime(updated, now time.Time) time.Time {
+	if updated.IsZero() {
+		return now.UTC()
+	}
+	return updated.UTC()
+}
+
+// notModified sets Last-Modified to updated and, if r's If-Modified-Since
+// is not before it, answers 304 Not Modified and reports true.
+func notModified(w http.ResponseWriter, r *http.Request, updated time.Time) bool {
+	setLastModified(w, updated)
+	since := ifModifiedSince(r)
+	if updated.IsZero() || since.IsZero() || updated.Truncate(time.Second).After(since) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// respondXML writes v as an XML document of contentType.
+func (srv *Server) respondXML(w http.ResponseWriter, r *http.Request, contentType string, v interface{}) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		srv.logAt("error", "xml: %v", err)
+		respondError(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+	buf.WriteByte('\n')
+	w.Header().Set("Content-Type", contentType+"; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== Feed Tests ==========
+
+func TestAtomFeed(t *testing.T) {
+	router := setupAdminRouter(t)
+	server.Config.PublicURL = "https://api.example.com"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/posts", `{"userId":2,"title":"Fish & <Chips>","body":"Vinegar"}`))
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/posts/feed.atom", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/atom+xml; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, cachePublic, w.Header().Get("Cache-Control"))
+	assert.NotEmpty(t, w.Header().Get("Last-Modified"))
+	assert.Contains(t, w.Body.String(), "<title>Fish &amp; &lt;Chips&gt;</title>")
+
+	var feed atomFeed
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &feed))
+	assert.Equal(t, "http://www.w3.org/2005/Atom", feed.XMLName.Space)
+	require.NotEmpty(t, feed.Entries)
+	newest := feed.Entries[0]
+	assert.Equal(t, "Fish & <Chips>", newest.Title, "newest first")
+	assert.Equal(t, "Vinegar", newest.Content.Text)
+	assert.Equal(t, newest.Updated, feed.Updated)
+	assert.Equal(t, "https://api.example.com/users/2", newest.Author.URI)
+}
+
+func TestRSSFeed(t *testing.T) {
+	router := setupRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/posts/feed.rss", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/rss+xml; charset=utf-8", w.Header().Get("Content-Type"))
+
+	var feed rssFeed
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &feed))
+	assert.Equal(t, "2.0", feed.Version)
+	assert.Contains(t, w.Body.String(), `<link xmlns="http://www.w3.org/2005/Atom" rel="self" type="application/rss+xml" href="http://example.com/posts/feed.rss"></link>`)
+	assert.Equal(t, cachePrivate, w.Header().Get("Cache-Control"), "links to the request's Host are not for shared caches")
+	assert.Contains(t, w.Header().Values("Vary"), "Host")
+	require.NotEmpty(t, feed.Channel.Items)
+	for _, item := range feed.Channel.Items {
+		assert.Equal(t, item.Link, item.GUID.ID)
+		_, err := time.Parse(time.RFC1123Z, item.PubDate)
+		assert.NoError(t, err)
+	}
+}
+
+func TestFeed_NotModified(t *testing.T) {
+	router := setupRouter()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/posts/feed.atom", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	lastModified := w.Header().Get("Last-Modified")
+	modified, err := http.ParseTime(lastModified)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		since  string
+		status int
+	}{
+		{"unchanged", lastModified, http.StatusNotModified},
+		{"later", modified.Add(time.Hour).Format(http.TimeFormat), http.StatusNotModified},
+		{"earlier", modified.Add(-time.Second).Format(http.TimeFormat), http.StatusOK},
+		{"invalid", "yesterday", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, path := range []string{"/posts/feed.atom", "/posts/feed.rss"} {
+				req := httptest.NewRequest(http.MethodGet, path, nil)
+				req.Header.Set("If-Modified-Since", tt.since)
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				assert.Equal(t, tt.status, w.Code, path)
+				assert.Equal(t, lastModified, w.Header().Get("Last-Modified"), path)
+			}
+		})
+	}
+}
+
+func TestFeed_Size(t *testing.T) {
+	router := setupAdminRouter(t)
+	for i := 0; i < feedSize+3; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/posts", `{"userId":1,"title":"Post `+strconv.Itoa(i)+`"}`))
+		require.Equal(t, http.StatusCreated, w.Code)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/posts/feed.rss", nil))
+	var feed rssFeed
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &feed))
+	require.Len(t, feed.Channel.Items, feedSize)
+	assert.Equal(t, "Post "+strconv.Itoa(feedSize+2), feed.Channel.Items[0].Title)
+}
+
+func TestAtomFeed_Empty(t *testing.T) {
+	router := setupAdminRouter(t)
+	freezeClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPut, "/admin/state", `{"users":[]}`))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/posts/feed.atom", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Last-Modified"))
+	var feed atomFeed
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &feed))
+	assert.Empty(t, feed.Entries)
+	assert.Equal(t, "2024-03-01T12:00:00Z", feed.Updated, "Atom requires a time")
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// sealedPrefix starts every sealed field value:
+// "enc:<key ID>:<nonce and ciphertext in unpadded base64url>". Values
+// without it are plaintext, written before encryption was turned on.
+const sealedPrefix = "enc:"
+
+// FieldKey is one of the AES-256 keys sensitive fields are encrypted with.
+type FieldKey struct {
+	ID  string
+	Key []byte
+}
+
+// FieldKeyring supplies the keys sensitive fields are encrypted with. The
+// keys configured in FIELD_KEYS are one; a KMS client can be another.
+type FieldKeyring interface {
+	// Primary returns the ID of the key new values are sealed with.
+	Primary() string
+	// Key returns the key with id, for opening values sealed with it.
+	Key(id string) ([]byte, bool)
+	// IndexKey returns the key of the blind index that equality lookups
+	// on sealed fields use. Unlike the others it cannot be rotated.
+	IndexKey() []byte
+}
+
+// staticKeyring is a FieldKeyring of fixed keys; the first is primary.
+type staticKeyring struct {
+	keys  []FieldKey
+	index []byte
+}
+
+func newStaticKeyring(keys []FieldKey, index []byte) staticKeyring {
+	return staticKeyring{keys: keys, index: index}
+}
+
+func (k staticKeyring) Primary() string { return k.keys[0].ID }
+
+func (k staticKeyring) Key(id string) ([]byte, bool) {
+	for _, key := range k.keys {
+		if key.ID == id {
+			return key.Key, true
+		}
+	}
+	return nil, false
+}
+
+func (k staticKeyring) IndexKey() []byte { return k.index }
+
+// fieldCipher seals and opens sensitive field values with AES-256-GCM
+// under the keys of a FieldKeyring. Each value is sealed with the name of
+// its field as additional data, so a sealed value only opens in the field
+// it was written to.
+type fieldCipher struct {
+	keys FieldKeyring
+}
+
+func newFieldCipher(keys FieldKeyring) *fieldCipher {
+	return &fieldCipher{keys: keys}
+}
+
+// aead returns the cipher for key id.
+func (c *fieldCipher) aead(id string) (cipher.AEAD, error) {
+	key, ok := c.keys.Key(id)
+	if !ok {
+		return nil, fmt.Errorf("field key %q is not configured", id)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts value, for field, with the primary key. Empty values stay
+// empty.
+func (c *fieldCipher) seal(field, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	id := c.keys.Primary()
+	aead, err := c.aead(id)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(value), []byte(field))
+	return sealedPrefix + id + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// open decrypts a value of field written by seal, with whichever key it
+// was sealed with. Plaintext values are returned as they are.
+func (c *fieldCipher) open(field, stored string) (string, error) {
+	rest, ok := strings.CutPrefix(stored, sealedPrefix)
+	if !ok {
+		return stored, nil
+	}
+	if c == nil {
+		return "", errors.New("sealed field, but no field keys are configured")
+	}
+	id, encoded, ok := strings.Cut(rest, ":")
+	if !ok {
+		return "", errors.New("malformed sealed field")
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("malformed sealed field: %w", err)
+	}
+	aead, err := c.aead(id)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("malformed sealed field")
+	}
+	value, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(field))
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
+
+// current reports whether stored needs no rewriting: it is empty, or sealed
+// with the primary key.
+func (c *fieldCipher) current(stored string) bool {
+	return stored == "" || strings.HasPrefix(stored, sealedPrefix+c.keys.Primary()+":")
+}
+
+// index returns the blind index of value for field: a keyed hash that is
+// equal for equal values, so lookups and uniqueness work without the
+// plaintext. Empty values index as empty.
+func (c *fieldCipher) index(field, value string) string {
+	if value == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, c.keys.IndexKey())
+	mac.Write([]byte(field + ":" + value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
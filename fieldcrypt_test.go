@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testFieldCipher returns a fieldCipher whose primary key is the first of
+// ids. Key i is 32 bytes of i+1.
+func testFieldCipher(ids ...string) *fieldCipher {
+	var keys []FieldKey
+	for i, id := range ids {
+		keys = append(keys, FieldKey{ID: id, Key: bytes.Repeat([]byte{byte(i + 1)}, 32)})
+	}
+	return newFieldCipher(newStaticKeyring(keys, []byte("index-key")))
+}
+
+// ========== Field Cipher Tests ==========
+
+func TestFieldCipher_RoundTrip(t *testing.T) {
+	c := testFieldCipher("1")
+
+	sealed, err := c.seal("users.email", "alice@example.com")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(sealed, "enc:1:"))
+	assert.NotContains(t, sealed, "alice")
+	again, err := c.seal("users.email", "alice@example.com")
+	require.NoError(t, err)
+	assert.NotEqual(t, sealed, again, "nonces are random")
+
+	opened, err := c.open("users.email", sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", opened)
+}
+
+func TestFieldCipher_Empty(t *testing.T) {
+	c := testFieldCipher("1")
+
+	sealed, err := c.seal("users.email", "")
+	require.NoError(t, err)
+	assert.Empty(t, sealed)
+	assert.Empty(t, c.index("users.email", ""))
+}
+
+func TestFieldCipher_OpenPlaintext(t *testing.T) {
+	opened, err := testFieldCipher("1").open("users.email", "alice@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", opened, "values from before encryption")
+
+	var off *fieldCipher
+	opened, err = off.open("users.email", "alice@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", opened)
+}
+
+func TestFieldCipher_OpenRejects(t *testing.T) {
+	c := testFieldCipher("1")
+	sealed, err := c.seal("users.email", "alice@example.com")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		cipher *fieldCipher
+		field  string
+		stored string
+	}{
+		{"other field", c, "users.name", sealed},
+		{"unknown key", testFieldCipher("2"), "users.email", sealed},
+		{"wrong key", newFieldCipher(newStaticKeyring([]FieldKey{{ID: "1", Key: make([]byte, 32)}}, nil)), "users.email", sealed},
+		{"tampered", c, "users.email", sealed[:len(sealed)-2] + "AA"},
+		{"no key ID", c, "users.email", "enc:abc"},
+		{"not base64", c, "users.email", "enc:1:!!!"},
+		{"too short", c, "users.email", "enc:1:AAAA"},
+		{"no keys configured", nil, "users.email", sealed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.cipher.open(tt.field, tt.stored)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestFieldCipher_Rotation(t *testing.T) {
+	old := testFieldCipher("1")
+	sealed, err := old.seal("users.email", "alice@example.com")
+	require.NoError(t, err)
+	assert.True(t, old.current(sealed))
+
+	// Key 2 is added as primary; key 1 stays for opening.
+	rotated := newFieldCipher(newStaticKeyring([]FieldKey{
+		{ID: "2", Key: bytes.Repeat([]byte{9}, 32)},
+		{ID: "1", Key: bytes.Repeat([]byte{1}, 32)},
+	}, []byte("index-key")))
+	assert.False(t, rotated.current(sealed))
+	assert.False(t, rotated.current("alice@example.com"), "plaintext needs sealing")
+	assert.True(t, rotated.current(""))
+
+	opened, err := rotated.open("users.email", sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", opened)
+	resealed, err := rotated.seal("users.email", opened)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(resealed, "enc:2:"))
+	assert.True(t, rotated.current(resealed))
+}
+
+func TestFieldCipher_Index(t *testing.T) {
+	c := testFieldCipher("1", "2")
+
+	index := c.index("users.email", "alice@example.com")
+	assert.Len(t, index, 64)
+	assert.NotContains(t, index, "alice")
+	assert.Equal(t, index, c.index("users.email", "alice@example.com"))
+	assert.Equal(t, index, testFieldCipher("2").index("users.email", "alice@example.com"), "independent of the encryption keys")
+	assert.NotEqual(t, index, c.index("users.email", "bob@example.com"))
+	assert.NotEqual(t, index, c.index("users.name", "alice@example.com"))
+}
@@ -0,0 +1,81 @@
+// Package flags defines the server's runtime feature flags and carries
+// their values through request contexts.
+package flags
+
+import (
+	"context"
+
+	"github.com/api2spec/api2spec-fixture-chi/ctxkit"
+)
+
+// Name identifies a flag in JSON documents.
+type Name string
+
+const (
+	// EnableV2Users serves the /v2/users routes.
+	EnableV2Users Name = "enable_v2_users"
+	// EnableChaos turns on the chaos middleware.
+	EnableChaos Name = "enable_chaos"
+	// EnvelopeResponses wraps successful JSON responses in {"data": ...}.
+	EnvelopeResponses Name = "envelope_responses"
+)
+
+// Set is the value of every flag. The zero Set has every flag off.
+type Set struct {
+	EnableV2Users     bool `json:"enable_v2_users"`
+	EnableChaos       bool `json:"enable_chaos"`
+	EnvelopeResponses bool `json:"envelope_responses"`
+}
+
+// Enabled reports whether the named flag is on. Unknown names are off.
+func (s Set) Enabled(name Name) bool {
+	switch name {
+	case EnableV2Users:
+		return s.EnableV2Users
+	case EnableChaos:
+		return s.EnableChaos
+	case EnvelopeResponses:
+		return s.EnvelopeResponses
+	}
+	return false
+}
+
+// Patch changes the flags it sets and leaves nil ones alone.
+type Patch struct {
+	EnableV2Users     *bool `json:"enable_v2_users,omitempty"`
+	EnableChaos       *bool `json:"enable_chaos,omitempty"`
+	EnvelopeResponses *bool `json:"envelope_responses,omitempty"`
+}
+
+// Apply returns s with p's changes.
+func (p Patch) Apply(s Set) Set {
+	if p.EnableV2Users != nil {
+		s.EnableV2Users = *p.EnableV2Users
+	}
+	if p.EnableChaos != nil {
+		s.EnableChaos = *p.EnableChaos
+	}
+	if p.EnvelopeResponses != nil {
+		s.EnvelopeResponses = *p.EnvelopeResponses
+	}
+	return s
+}
+
+// key carries a request's flags.
+var key = ctxkit.NewKey[Set]("flags")
+
+// NewContext returns ctx carrying s.
+func NewContext(ctx context.Context, s Set) context.Context {
+	return key.With(ctx, s)
+}
+
+// FromContext returns the flags carried by ctx, or the zero Set.
+func FromContext(ctx context.Context) Set {
+	s, _ := key.From(ctx)
+	return s
+}
+
+// Enabled reports whether the named flag is on in ctx.
+func Enabled(ctx context.Context, name Name) bool {
+	return FromContext(ctx).Enabled(name)
+}
@@ -0,0 +1,37 @@
+package flags
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== Flag Tests ==========
+
+func TestSet_Enabled(t *testing.T) {
+	s := Set{EnableChaos: true}
+
+	assert.True(t, s.Enabled(EnableChaos))
+	assert.False(t, s.Enabled(EnableV2Users))
+	assert.False(t, s.Enabled(EnvelopeResponses))
+	assert.False(t, s.Enabled("unknown"))
+}
+
+func TestPatch_Apply(t *testing.T) {
+	var p Patch
+	require.NoError(t, json.Unmarshal([]byte(`{"enable_chaos":false,"envelope_responses":true}`), &p))
+
+	got := p.Apply(Set{EnableV2Users: true, EnableChaos: true})
+	assert.Equal(t, Set{EnableV2Users: true, EnvelopeResponses: true}, got)
+}
+
+func TestContext(t *testing.T) {
+	assert.Equal(t, Set{}, FromContext(context.Background()))
+
+	ctx := NewContext(context.Background(), Set{EnvelopeResponses: true})
+	assert.True(t, Enabled(ctx, EnvelopeResponses))
+	assert.False(t, Enabled(ctx, EnableChaos))
+}
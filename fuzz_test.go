@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fuzzBodies seeds every JSON-decoding fuzz target.
+var fuzzBodies = []string{
+	`{}`,
+	`{"name":"Alice","email":"alice@example.com"}`,
+	`{"name":"Alice","email":"alice@example.com","version":1}`,
+	`{"userId":1,"title":"Title","body":"Body"}`,
+	`{"id":"not a number"}`,
+	`{"version":-1}`,
+	`[]`,
+	`null`,
+	`"string"`,
+	`not json`,
+	`{"name":`,
+	"",
+	"\x00\xff",
+}
+
+// checkFuzzResponse fails unless the response has an allowed status and a
+// JSON body (204 responses must be empty).
+func checkFuzzResponse(t *testing.T, w *httptest.ResponseRecorder, allowed ...int) {
+	t.Helper()
+
+	ok := false
+	for _, status := range allowed {
+		if w.Code == status {
+			ok = true
+			break
+		}
+	}
+	if !ok {
+		t.Fatalf("unexpected status %d (allowed %v), body %q", w.Code, allowed, w.Body.String())
+	}
+
+	if w.Code == http.StatusNoContent {
+		if w.Body.Len() != 0 {
+			t.Fatalf("204 response with body %q", w.Body.String())
+		}
+		return
+	}
+	if !json.Valid(w.Body.Bytes()) {
+		t.Fatalf("status %d response is not valid JSON: %q", w.Code, w.Body.String())
+	}
+}
+
+// ========== Fuzz Targets ==========
+
+func FuzzCreateUser(f *testing.F) {
+	for _, body := range fuzzBodies {
+		f.Add([]byte(body))
+	}
+	router := setupRouter()
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		req := httptest.NewRequest(http.MethodPost, "/users", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		checkFuzzResponse(t, w, http.StatusCreated, http.StatusBadRequest, http.StatusConflict, http.StatusRequestEntityTooLarge)
+	})
+}
+
+func FuzzUpdateUser(f *testing.F) {
+	for _, body := range fuzzBodies {
+		f.Add("1", []byte(body))
+		f.Add("999", []byte(body))
+	}
+	f.Add("abc", []byte(`{"version":1}`))
+	f.Add("-1", []byte(`{"version":1}`))
+	router := setupRouter()
+
+	f.Fuzz(func(t *testing.T, id string, body []byte) {
+		req := httptest.NewRequest(http.MethodPut, "/users/1", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		// Set the path directly: arbitrary fuzz strings are not always valid
+		// request-target syntax for httptest.NewRequest.
+		req.URL.Path = "/users/" + id
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		if w.Code == http.StatusMethodNotAllowed {
+			// The id contained a slash or was empty, so another route
+			// matched; chi's empty 405 response is fine here.
+			return
+		}
+		checkFuzzResponse(t, w,
+			http.StatusOK,
+			http.StatusBadRequest,
+			http.StatusNotFound,
+			http.StatusRequestEntityTooLarge,
+			http.StatusConflict,
+			http.StatusPreconditionRequired,
+		)
+	})
+}
+
+func FuzzCreatePost(f *testing.F) {
+	for _, body := range fuzzBodies {
+		f.Add([]byte(body))
+	}
+	router := setupRouter()
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		req := httptest.NewRequest(http.MethodPost, "/posts", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		checkFuzzResponse(t, w, http.StatusCreated, http.StatusBadRequest, http.StatusConflict, http.StatusRequestEntityTooLarge)
+	})
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/brianvoe/gofakeit/v6"
+)
+
+// dataSpec is how much fake data "--generate" fills the store with.
+// The same spec always generates the same data.
+type dataSpec struct {
+	Users, Posts, Comments, Todos, Albums int
+	// Seed seeds the generator; never 0, which gofakeit treats as random.
+	Seed int64
+}
+
+// generateEpoch is when the first generated entity was created; each
+// later one was created a minute after the one before.
+var generateEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// parseGenerateArgs reads the arguments of "--generate", such as
+// "users=1000 posts=5000 seed=7". The seed defaults to 1.
+func parseGenerateArgs(args []string) (dataSpec, error) {
+	spec := dataSpec{Seed: 1}
+	counts := map[string]*int{
+		"users": &spec.Users, "posts": &spec.Posts, "comments": &spec.Comments,
+		"todos": &spec.Todos, "albums": &spec.Albums,
+	}
+	if len(args) == 0 {
+		return dataSpec{}, fmt.Errorf("usage: --generate users=N posts=N comments=N todos=N albums=N [seed=N]")
+	}
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			return dataSpec{}, fmt.Errorf("--generate: %q is not key=value", arg)
+		}
+		if key == "seed" {
+			seed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || seed == 0 {
+				return dataSpec{}, fmt.Errorf("--generate: seed must be a non-zero integer")
+			}
+			spec.Seed = seed
+			continue
+		}
+		count, known := counts[key]
+		if !known {
+			return dataSpec{}, fmt.Errorf("--generate: unknown collection %q (want users, posts, comments, todos or albums)", key)
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return dataSpec{}, fmt.Errorf("--generate: %s must be a count", key)
+		}
+		*count = n
+	}
+	return spec, nil
+}
+
+// generateState returns st with each collection spec counts replaced by
+// that many fake entities, numbered from 1. Posts, comments and albums
+// belong to random users, and comments to random posts, so they need some
+// to exist, generated or already in st. Authors' postCounts are
+// recomputed.
+func generateState(st State, spec dataSpec) (State, error) {
+	f := gofakeit.New(spec.Seed)
+	at := func(i int) time.Time { return generateEpoch.Add(time.Duration(i) * time.Minute) }
+
+	if spec.Users > 0 {
+		st.Users = make([]User, spec.Users)
+		for i := range st.Users {
+			first, last := f.FirstName(), f.LastName()
+			id := i + 1
+			st.Users[i] = User{
+				ID:        id,
+				Name:      first + " " + last,
+				Email:     fmt.Sprintf("%s.%s%d@example.com", emailPart(first), emailPart(last), id),
+				Version:   1,
+				Verified:  f.Bool(),
+				CreatedAt: at(i),
+				UpdatedAt: at(i),
+			}
+		}
+	}
+	randomUser := func() int { return st.Users[f.IntRange(0, len(st.Users)-1)].ID }
+	if (spec.Posts > 0 || spec.Comments > 0 || spec.Albums > 0) && len(st.Users) == 0 {
+		return State{}, fmt.Errorf("--generate: posts, comments and albums need users")
+	}
+
+	if spec.Posts > 0 {
+		st.Posts = make([]Post, spec.Posts)
+		for i := range st.Posts {
+			st.Posts[i] = Post{
+				ID:        i + 1,
+				UserID:    randomUser(),
+				Title:     strings.TrimSuffix(f.Sentence(f.IntRange(3, 8)), "."),
+				Body:      f.Paragraph(1, f.IntRange(2, 5), 12, " "),
+				Version:   1,
+				CreatedAt: at(i),
+				UpdatedAt: at(i),
+			}
+		}
+	}
+	counts := map[int]int{}
+	for _, p := range st.Posts {
+		counts[p.UserID]++
+	}
+	for i := range st.Users {
+		st.Users[i].PostCount = counts[st.Users[i].ID]
+	}
+
+	if spec.Comments > 0 {
+		if len(st.Posts) == 0 {
+			return State{}, fmt.Errorf("--generate: comments need posts")
+		}
+		st.Comments = make([]Comment, spec.Comments)
+		for i := range st.Comments {
+			st.Comments[i] = Comment{
+				ID:        i + 1,
+				PostID:    st.Posts[f.IntRange(0, len(st.Posts)-1)].ID,
+				UserID:    randomUser(),
+				Body:      f.Sentence(f.IntRange(5, 20)),
+				CreatedAt: at(i),
+				UpdatedAt: at(i),
+			}
+		}
+	}
+
+	if spec.Todos > 0 {
+		priorities := []Priority{PriorityLow, PriorityMed, PriorityHigh}
+		st.Todos = make([]Todo, spec.Todos)
+		for i := range st.Todos {
+			t := Todo{
+				ID:        i + 1,
+				Title:     strings.TrimSuffix(f.Sentence(f.IntRange(2, 6)), "."),
+				Completed: f.Bool(),
+				Priority:  priorities[f.IntRange(0, len(priorities)-1)],
+				Version:   1,
+				CreatedAt: at(i),
+				UpdatedAt: at(i),
+			}
+			if f.Bool() {
+				due := at(i).AddDate(0, 0, f.IntRange(1, 365))
+				t.Due = &due
+			}
+			st.Todos[i] = t
+		}
+	}
+
+	if spec.Albums > 0 {
+		st.Albums = make([]Album, spec.Albums)
+		for i := range st.Albums {
+			st.Albums[i] = Album{
+				ID:        i + 1,
+				UserID:    randomUser(),
+				Title:     f.BookTitle(),
+				Version:   1,
+				CreatedAt: at(i),
+				UpdatedAt: at(i),
+			}
+		}
+		// Generated albums replace the old ones, and their photos with them.
+		st.Photos = nil
+	}
+	return st, nil
+}
+
+// emailPart lowercases s and drops everything but letters and digits.
+func emailPart(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== Generated Data Tests ==========
+
+func TestParseGenerateArgs(t *testing.T) {
+	spec, err := parseGenerateArgs([]string{"users=1000", "posts=5000", "seed=7"})
+	require.NoError(t, err)
+	assert.Equal(t, dataSpec{Users: 1000, Posts: 5000, Seed: 7}, spec)
+
+	spec, err = parseGenerateArgs([]string{"todos=3"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), spec.Seed, "seed defaults to 1")
+
+	for _, args := range [][]string{
+		nil,
+		{"users"},
+		{"users=-1"},
+		{"users=many"},
+		{"widgets=5"},
+		{"seed=0"},
+	} {
+		_, err := parseGenerateArgs(args)
+		assert.Error(t, err, "%q", args)
+	}
+}
+
+func TestGenerateState(t *testing.T) {
+	spec := dataSpec{Users: 50, Posts: 200, Comments: 300, Todos: 40, Albums: 10, Seed: 3}
+	st, err := generateState(State{}, spec)
+	require.NoError(t, err)
+
+	require.Len(t, st.Users, 50)
+	require.Len(t, st.Posts, 200)
+	require.Len(t, st.Comments, 300)
+	require.Len(t, st.Todos, 40)
+	require.Len(t, st.Albums, 10)
+
+	emails := map[string]bool{}
+	postCounts := 0
+	for i, u := range st.Users {
+		assert.Equal(t, i+1, u.ID)
+		assert.False(t, emails[u.Email], "duplicate email %s", u.Email)
+		emails[u.Email] = true
+		postCounts += u.PostCount
+	}
+	assert.Equal(t, len(st.Posts), postCounts)
+
+	again, err := generateState(State{}, spec)
+	require.NoError(t, err)
+	assert.Equal(t, st, again, "the same spec generates the same data")
+
+	spec.Seed = 4
+	other, err := generateState(State{}, spec)
+	require.NoError(t, err)
+	assert.NotEqual(t, st.Users, other.Users)
+}
+
+func TestGenerateState_KeepsOtherCollections(t *testing.T) {
+	base := newMemoryStore(NewEventBus()).Snapshot()
+	st, err := generateState(base, dataSpec{Posts: 30, Seed: 1})
+	require.NoError(t, err)
+
+	assert.Len(t, st.Posts, 30)
+	assert.Len(t, st.Users, len(base.Users))
+	assert.Equal(t, base.Todos, st.Todos)
+}
+
+func TestGenerateState_NeedsUsers(t *testing.T) {
+	_, err := generateState(State{}, dataSpec{Posts: 5, Seed: 1})
+	assert.Error(t, err)
+
+	_, err = generateState(State{}, dataSpec{Users: 5, Comments: 5, Seed: 1})
+	assert.Error(t, err, "comments need posts")
+}
+
+func TestGenerateState_Served(t *testing.T) {
+	router := setupRouter()
+	st, err := generateState(server.Store.Snapshot(), dataSpec{Users: 250, Seed: 1})
+	require.NoError(t, err)
+	server.Store.Restore(st)
+
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set("Range", "items=200-")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "items 200-249/250", w.Header().Get("Content-Range"))
+	var users []User
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &users))
+	assert.Equal(t, 201, users[0].ID)
+}
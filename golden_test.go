@@ -0,0 +1,433 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// goldenCase is the canonical request recorded for one operation.
+type goldenCase struct {
+	// setup prepares the store through router before the request.
+	setup   func(t *testing.T, router http.Handler)
+	request func(t *testing.T) *http.Request
+	// volatile names JSON members whose values differ from run to run. They
+	// are recorded as "<volatile>", so only their presence is checked. In
+	// plain-text bodies it names metrics whose samples are left out.
+	volatile []string
+}
+
+// goldenGet is a goldenCase for an admin GET of path.
+func goldenGet(path string, volatile ...string) goldenCase {
+	return goldenSend(http.MethodGet, path, "", volatile...)
+}
+
+// goldenSend is a goldenCase for an admin request with a JSON body.
+func goldenSend(method, path, body string, volatile ...string) goldenCase {
+	return goldenCase{
+		request:  func(*testing.T) *http.Request { return newAdminRequest(method, path, body) },
+		volatile: volatile,
+	}
+}
+
+// goldenMe is a goldenCase for a request by user 1 with a JSON body.
+func goldenMe(method, path, body string, volatile ...string) goldenCase {
+	return goldenCase{
+		request:  func(*testing.T) *http.Request { return newUserRequest(1, method, path, body) },
+		volatile: volatile,
+	}
+}
+
+// goldenCases holds a goldenCase for every operation in the route table,
+// by operationId.
+var goldenCases = map[string]goldenCase{
+	"getSpec":              goldenGet("/openapi.yaml"),
+	"getPostmanCollection": goldenGet("/postman.json"),
+	"getInsomniaExport":    goldenGet("/insomnia.json"),
+	"getAsyncAPI":          goldenGet("/asyncapi.json"),
+	"diffSpecs":            goldenGet("/spec/diff?from=v1&to=v2"),
+	"getStats":             goldenGet("/stats", "requests", "startedAt", "uptimeSeconds"),
+	"listUsers":            goldenGet("/users"),
+	"createUser":           goldenSend(http.MethodPost, "/users", `{"name":"Carol","email":"carol@example.com"}`),
+	"importUsers": {request: func(*testing.T) *http.Request {
+		return newCSVImportRequest("name,email\nCarol,carol@example.com\nDave,dave\n")
+	}},
+	"countUsers":         goldenGet("/users/count"),
+	"sampleUsers":        goldenGet("/users/sample?n=2&seed=1"),
+	"getMyUser":          goldenMe(http.MethodGet, "/users/me", ""),
+	"newUser":            goldenGet("/users/new"),
+	"suggestUsers":       goldenGet("/users/suggest?q=AL&limit=5"),
+	"getUser":            goldenGet("/users/1"),
+	"updateUser":         goldenSend(http.MethodPut, "/users/1", `{"name":"Alicia","email":"alicia@example.com","version":1}`),
+	"deleteUser":         goldenSend(http.MethodDelete, "/users/2", ""),
+	"changePassword":     goldenMe(http.MethodPost, "/users/1/password", `{"newPassword":"correct horse"}`),
+	"listUserPosts":      goldenGet("/users/1/posts"),
+	"createUserPost":     goldenSend(http.MethodPost, "/users/1/posts", `{"title":"Third Post","body":"More"}`),
+	"getUserPostComment": goldenGet("/users/1/posts/1/comments/2"),
+	"getUserGravatar": {
+		setup:   func(t *testing.T, _ http.Handler) { useFakeGravatar(t) },
+		request: func(*testing.T) *http.Request { return newAdminRequest(http.MethodGet, "/users/1/gravatar", "") },
+	},
+	"listUsersV2": {
+		setup:   enableV2Users,
+		request: func(*testing.T) *http.Request { return newAdminRequest(http.MethodGet, "/v2/users", "") },
+	},
+	"getUserV2": {
+		setup:   enableV2Users,
+		request: func(*testing.T) *http.Request { return newAdminRequest(http.MethodGet, "/v2/users/1", "") },
+	},
+	"listPosts":  goldenGet("/posts"),
+	"createPost": goldenSend(http.MethodPost, "/posts", `{"userId":2,"title":"Hello","body":"From Bob"}`),
+	"bulkCreatePosts": {request: func(*testing.T) *http.Request {
+		return newBulkPostsRequest(`{"userId":2,"title":"Hello","body":"From Bob"}` + "\n" + `{"userId":1,"title":"First Post"}` + "\n" + `{"title":""}` + "\n")
+	}},
+	"getRandomPost":    goldenGet("/posts/random?seed=1"),
+	"countPosts":       goldenGet("/posts/count?userId=1"),
+	"getPostsAtomFeed": goldenGet("/posts/feed.atom"),
+	"getPostsRSSFeed":  goldenGet("/posts/feed.rss"),
+	"getPost":          goldenGet("/posts/1"),
+	"updatePost":       goldenSend(http.MethodPut, "/posts/1", `{"title":"First Post, revised","body":"Edited","version":1}`),
+	"getPostBySlug":    goldenGet("/posts/slug/second-post"),
+	"deletePost":       goldenSend(http.MethodDelete, "/posts/1", ""),
+	"listAlbums":       goldenGet("/albums"),
+	"createAlbum":      goldenSend(http.MethodPost, "/albums", `{"userId":1,"title":"Holidays"}`),
+	"getAlbum":         goldenGet("/albums/1"),
+	"listAlbumPhotos":  goldenGet("/albums/1/photos"),
+	"uploadPhoto": {request: func(t *testing.T) *http.Request {
+		return newUploadRequest(t, "/albums/1/photos", "Beach", encodePNG(t, 30, 20))
+	}},
+	"getPhoto":          goldenGet("/photos/1"),
+	"getPhotoThumbnail": goldenGet("/photos/1/thumbnail?width=16&height=16"),
+	"listIngestEvents":  {setup: ingestGoldenEvent, request: func(*testing.T) *http.Request { return newAdminRequest(http.MethodGet, "/ingest/events", "") }},
+	"ingestEvent": {request: func(*testing.T) *http.Request {
+		body := `{"action":"opened","number":7}`
+		return newIngestRequest(body, sign(server.Config.IngestSecret, []byte(body)))
+	}},
+	"getIngestEvent": {setup: ingestGoldenEvent, request: func(*testing.T) *http.Request { return newAdminRequest(http.MethodGet, "/ingest/events/1", "") }},
+	"login": {
+		setup: func(t *testing.T, _ http.Handler) { setTestPassword(t, 1, "correct horse") },
+		request: func(*testing.T) *http.Request {
+			return newAdminRequest(http.MethodPost, "/auth/login", `{"email":"alice@example.com","password":"correct horse"}`)
+		},
+	},
+	"setupTwoFactor": goldenMe(http.MethodPost, "/auth/2fa/setup", "", "qrCode", "secret", "uri"),
+	"verifyTwoFactor": {
+		setup: func(t *testing.T, _ http.Handler) {
+			useGoldenTokenClock(t)
+			sealed, err := server.sealTOTPSecret([]byte(goldenTOTPSecret))
+			require.NoError(t, err)
+			require.NoError(t, server.Store.SetTwoFactor(1, TwoFactor{Secret: sealed}))
+		},
+		request: func(*testing.T) *http.Request {
+			return newUserRequest(1, http.MethodPost, "/auth/2fa/verify", `{"code":"`+currentTOTP([]byte(goldenTOTPSecret))+`"}`)
+		},
+	},
+	"requestPasswordReset": goldenSend(http.MethodPost, "/auth/password-reset", `{"email":"alice@example.com"}`),
+	"confirmPasswordReset": {request: func(*testing.T) *http.Request {
+		return newAdminRequest(http.MethodPost, "/auth/password-reset/confirm", `{"token":"`+server.passwordResetToken(1, nil)+`","password":"correct horse"}`)
+	}},
+	"sendVerification": goldenMe(http.MethodPost, "/users/1/verify/send", ""),
+	"verifyEmail": {request: func(*testing.T) *http.Request {
+		return newAdminRequest(http.MethodGet, "/verify?token="+server.verificationToken(1, "alice@example.com"), "")
+	}},
+	"createInvite": {
+		setup: func(t *testing.T, _ http.Handler) { useGoldenTokenClock(t) },
+		request: func(*testing.T) *http.Request {
+			return newAdminRequest(http.MethodPost, "/invites", `{"email":"carol@example.com"}`)
+		},
+	},
+	"getInvite": {
+		setup: func(t *testing.T, _ http.Handler) { useGoldenTokenClock(t) },
+		request: func(*testing.T) *http.Request {
+			return newAdminRequest(http.MethodGet, "/invites/"+server.inviteToken("carol@example.com", time.Date(2024, 3, 8, 12, 0, 0, 0, time.UTC)), "")
+		},
+	},
+	"acceptInvite": {
+		setup: func(t *testing.T, _ http.Handler) { useGoldenTokenClock(t) },
+		request: func(*testing.T) *http.Request {
+			token := server.inviteToken("carol@example.com", time.Date(2024, 3, 8, 12, 0, 0, 0, time.UTC))
+			return newAdminRequest(http.MethodPost, "/invites/"+token+"/accept", `{"name":"Carol"}`)
+		},
+	},
+	"getMe":           goldenMe(http.MethodGet, "/me", ""),
+	"updateMe":        goldenMe(http.MethodPut, "/me", `{"name":"Alicia","email":"alicia@example.com","version":1}`),
+	"listMyPosts":     goldenMe(http.MethodGet, "/me/posts", ""),
+	"listMySessions":  goldenMe(http.MethodGet, "/me/sessions", ""),
+	"revokeMySession": goldenMe(http.MethodDelete, "/me/sessions/1", ""),
+	"getMyUsage": {
+		setup:   func(t *testing.T, _ http.Handler) { useQuota(t, 100, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)) },
+		request: func(*testing.T) *http.Request { return newUserRequest(1, http.MethodGet, "/me/usage", "") },
+	},
+	"getPostMetrics": goldenGet("/metrics/posts?from=2024-01-01T00:00:00Z&to=2024-01-03T00:00:00Z&interval=day"),
+	"listPlaces":     goldenGet("/places?lat=52.52&lng=13.40&radius=5"),
+	"proxyGet":       goldenProxy(http.MethodGet, "/proxy/echo/things?page=2", ""),
+	"proxyPost":      goldenProxy(http.MethodPost, "/proxy/echo/things", `{"name":"Widget"}`),
+	"proxyPut":       goldenProxy(http.MethodPut, "/proxy/echo/things/1", `{"name":"Gadget"}`),
+	"proxyPatch":     goldenProxy(http.MethodPatch, "/proxy/echo/things/1", `{"name":"Gizmo"}`),
+	"proxyDelete":    goldenProxy(http.MethodDelete, "/proxy/echo/things/1", ""),
+	"listTenants":    {setup: createGoldenTenant, request: func(*testing.T) *http.Request { return newAdminRequest(http.MethodGet, "/tenants", "") }},
+	"createTenant":   goldenSend(http.MethodPost, "/tenants", `{"name":"Acme","rateLimit":60,"flags":{"read_only":true},"allowedOrigins":["https://acme.example"]}`),
+	"getTenant":      {setup: createGoldenTenant, request: func(*testing.T) *http.Request { return newAdminRequest(http.MethodGet, "/tenants/1", "") }},
+	"updateTenant": {setup: createGoldenTenant, request: func(*testing.T) *http.Request {
+		return newAdminRequest(http.MethodPut, "/tenants/1", `{"name":"Acme Corp","version":1}`)
+	}},
+	"deleteTenant":  {setup: createGoldenTenant, request: func(*testing.T) *http.Request { return newAdminRequest(http.MethodDelete, "/tenants/1", "") }},
+	"listTodos":     goldenGet("/todos"),
+	"createTodo":    goldenSend(http.MethodPost, "/todos", `{"title":"Ship it","priority":"low","due":"2024-02-01T12:00:00Z"}`),
+	"getTodo":       goldenGet("/todos/1"),
+	"listWebhooks":  {setup: createGoldenWebhook, request: func(*testing.T) *http.Request { return newAdminRequest(http.MethodGet, "/webhooks", "") }},
+	"createWebhook": goldenSend(http.MethodPost, "/webhooks", `{"url":"https://example.invalid/hook","events":["users.created"]}`),
+	"getWebhook":    {setup: createGoldenWebhook, request: func(*testing.T) *http.Request { return newAdminRequest(http.MethodGet, "/webhooks/1", "") }},
+	"deleteWebhook": {setup: createGoldenWebhook, request: func(*testing.T) *http.Request { return newAdminRequest(http.MethodDelete, "/webhooks/1", "") }},
+	"listWebhookDeliveries": {
+		setup: func(t *testing.T, router http.Handler) {
+			t.Cleanup(server.events.Subscribe(server.enqueueDeliveries))
+			createGoldenWebhook(t, router)
+			createTestUser(t, router)
+		},
+		request: func(*testing.T) *http.Request { return newAdminRequest(http.MethodGet, "/webhooks/1/deliveries", "") },
+	},
+	"runBatch": goldenSend(http.MethodPost, "/batch",
+		`[{"method":"GET","path":"/users/1"},{"method":"POST","path":"/todos","body":{"title":"Batch","completed":false}},{"method":"DELETE","path":"/users/99"}]`),
+	"getHealth":    goldenGet("/health"),
+	"getReadiness": goldenGet("/health/ready"),
+	"getHealthHistory": {
+		setup: func(t *testing.T, router http.Handler) {
+			for i := 0; i < 2; i++ {
+				router.ServeHTTP(httptest.NewRecorder(), newAdminRequest(http.MethodGet, "/health/ready", ""))
+			}
+		},
+		request: func(*testing.T) *http.Request { return newAdminRequest(http.MethodGet, "/health/history?limit=5", "") },
+	},
+	"getMetrics": goldenGet("/metrics", "http_requests_total", "route_requests_total", "route_latency_budget_violations_total", "process_uptime_seconds"),
+	"listRoutes": goldenGet("/_routes"),
+	"getConfig":  goldenGet("/admin/config", "loadedAt"),
+	"getDBStats": goldenGet("/admin/dbstats"),
+	"streamEvents": {request: func(t *testing.T) *http.Request {
+		// Cancelled up front, the stream ends after its preamble.
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		return newAdminRequest(http.MethodGet, "/admin/events", "").WithContext(ctx)
+	}},
+	"listJobs": {
+		setup: func(t *testing.T, router http.Handler) {
+			router.ServeHTTP(httptest.NewRecorder(), newAdminRequest(http.MethodPost, "/admin/jobs/purge-expired/run", ""))
+		},
+		request: func(*testing.T) *http.Request { return newAdminRequest(http.MethodGet, "/admin/jobs", "") },
+	},
+	"runJob": goldenSend(http.MethodPost, "/admin/jobs/refresh-stats/run", ""),
+	"getLeader": {
+		setup: func(*testing.T, http.Handler) {
+			server.leader = newLeaderElection(LeaderConfig{Instance: "api-0"}, newMemoryLease(server.Clock.Now), server.Clock.Now)
+			server.leader.campaign(context.Background())
+		},
+		request: func(*testing.T) *http.Request { return newAdminRequest(http.MethodGet, "/admin/leader", "") },
+	},
+	"getFlags":        goldenGet("/admin/flags"),
+	"updateFlags":     goldenSend(http.MethodPatch, "/admin/flags", `{"enable_v2_users":true}`),
+	"getIPRules":      goldenGet("/admin/ip-rules"),
+	"updateIPRules":   goldenSend(http.MethodPut, "/admin/ip-rules", `{"allow":["192.0.2.0/24","2001:db8::1"],"deny":["192.0.2.99"]}`),
+	"listRules":       {setup: createGoldenRule, request: func(*testing.T) *http.Request { return newAdminRequest(http.MethodGet, "/admin/rules", "") }},
+	"createRule":      goldenSend(http.MethodPost, "/admin/rules", goldenRule),
+	"deleteRule":      {setup: createGoldenRule, request: func(*testing.T) *http.Request { return newAdminRequest(http.MethodDelete, "/admin/rules/1", "") }},
+	"getShadowReport": {setup: useGoldenShadow, request: func(*testing.T) *http.Request { return newAdminRequest(http.MethodGet, "/admin/shadow", "") }},
+	"getState":        goldenGet("/admin/state"),
+	"getAdminUI":      goldenGet("/admin/ui"),
+	"issueUserToken":  goldenSend(http.MethodPost, "/admin/users/1/token", ""),
+	"restoreState": {request: func(t *testing.T) *http.Request {
+		return newAdminRequest(http.MethodPut, "/admin/state", `{"users":[{"id":1,"name":"Solo","email":"solo@example.com","version":1}]}`)
+	}},
+}
+
+// goldenTOTPSecret is the TOTP secret two-factor golden cases use.
+const goldenTOTPSecret = "12345678901234567890"
+
+// useGoldenTokenClock issues and checks invites and TOTP codes as of the
+// golden clock.
+func useGoldenTokenClock(t *testing.T) {
+	freezeClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+}
+
+func enableV2Users(t *testing.T, router http.Handler) {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPatch, "/admin/flags", `{"enable_v2_users":true}`))
+	require.Equal(t, http.StatusOK, w.Code)
+}
+
+func ingestGoldenEvent(t *testing.T, router http.Handler) {
+	body := `{"action":"opened","number":7}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newIngestRequest(body, sign(server.Config.IngestSecret, []byte(body))))
+	require.Equal(t, http.StatusAccepted, w.Code)
+}
+
+func createGoldenTenant(t *testing.T, router http.Handler) {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/tenants", `{"name":"Acme","rateLimit":60}`))
+	require.Equal(t, http.StatusCreated, w.Code)
+}
+
+// goldenRule forces a 500 on GET /users for a minute.
+const goldenRule = `{"method":"GET","path":"/users","script":"return {status = 500}","ttl":"1m"}`
+
+func createGoldenRule(t *testing.T, router http.Handler) {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/admin/rules", goldenRule))
+	require.Equal(t, http.StatusCreated, w.Code)
+}
+
+// useGoldenShadow turns shadowing on with the counters holding one
+// comparison.
+func useGoldenShadow(t *testing.T, _ http.Handler) {
+	server.Config.Shadow = ShadowConfig{URL: "http://shadow.example", Rate: 0.5, Timeout: time.Second}
+	server.shadowStats.record(ShadowComparison{
+		Method: http.MethodGet, Path: "/users/1", Status: http.StatusOK, LatencyMS: 2,
+		ShadowStatus: http.StatusNotFound, ShadowLatencyMS: 5,
+	}, 2*time.Millisecond, 5*time.Millisecond)
+}
+
+// goldenProxy is a goldenCase for an admin request through /proxy/echo to
+// an echo upstream.
+func goldenProxy(method, path, body string) goldenCase {
+	tc := goldenSend(method, path, body)
+	tc.setup = func(t *testing.T, _ http.Handler) {
+		server.Config.Proxy = ProxyConfig{Services: map[string]string{"echo": newEchoUpstream(t)}, Timeout: time.Second}
+	}
+	return tc
+}
+
+func createGoldenWebhook(t *testing.T, router http.Handler) {
+	createTestWebhook(t, router, `{"url":"https://example.invalid/hook"}`)
+}
+
+// goldenRecord renders a response for a golden file: the status, the
+// headers that are part of the contract, and the body. JSON bodies are
+// indented with their volatile members blanked, plain text is recorded
+// without the volatile metrics' samples, and XML as it is; other bodies are
+// recorded by length and digest.
+func goldenRecord(t *testing.T, w *httptest.ResponseRecorder, volatile []string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%d %s\n", w.Code, http.StatusText(w.Code))
+	for _, name := range []string{"Content-Type", "Location", "Cache-Control", "Deprecation", "Sunset", "Link"} {
+		if v := w.Header().Get(name); v != "" {
+			fmt.Fprintf(&buf, "%s: %s\n", name, v)
+		}
+	}
+	buf.WriteString("\n")
+
+	mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	switch {
+	case w.Body.Len() == 0:
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		dec := json.NewDecoder(w.Body)
+		dec.UseNumber()
+		var body interface{}
+		require.NoError(t, dec.Decode(&body))
+		body = blankVolatile(body, volatile)
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		require.NoError(t, enc.Encode(body))
+	case mediaType == "text/plain" || mediaType == ndjsonMediaType || strings.HasSuffix(mediaType, "+xml"):
+		for _, line := range strings.SplitAfter(w.Body.String(), "\n") {
+			name := strings.FieldsFunc(line, func(r rune) bool { return r == '{' || r == ' ' })
+			if len(name) > 0 && contains(volatile, name[0]) {
+				continue
+			}
+			buf.WriteString(line)
+		}
+	default:
+		fmt.Fprintf(&buf, "%d bytes, sha256 %x\n", w.Body.Len(), sha256.Sum256(w.Body.Bytes()))
+	}
+	return buf.Bytes()
+}
+
+// blankVolatile replaces the values of members named in volatile, at any
+// depth, with "<volatile>".
+func blankVolatile(v interface{}, volatile []string) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, member := range v {
+			v[k] = blankVolatile(member, volatile)
+			if contains(volatile, k) {
+				v[k] = "<volatile>"
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = blankVolatile(v[i], volatile)
+		}
+	}
+	return v
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ========== Golden File Tests ==========
+
+// TestGolden_Responses records each operation's canonical response under
+// testdata/golden and fails when it changes. Run with -update to rewrite
+// the files after an intended change.
+func TestGolden_Responses(t *testing.T) {
+	for _, def := range append(server.apiRouteDefs(), server.opsRouteDefs()...) {
+		def := def
+		t.Run(def.OperationID, func(t *testing.T) {
+			tc, ok := goldenCases[def.OperationID]
+			require.True(t, ok, "no golden case for %s %s", def.Method, def.Pattern)
+
+			router := setupAdminRouter(t)
+			server.Config.IngestSecret, server.Config.AuthSecret = []byte("golden"), []byte("golden")
+			server.Config.PublicURL = "https://api.example.com"
+			freezeClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+			if tc.setup != nil {
+				tc.setup(t, router)
+			}
+
+			req := tc.request(t)
+			require.Equal(t, def.Method, req.Method)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			got := goldenRecord(t, w, tc.volatile)
+
+			golden := filepath.Join("testdata", "golden", def.OperationID+".golden")
+			if *updateGolden {
+				require.NoError(t, os.MkdirAll(filepath.Dir(golden), 0o755))
+				require.NoError(t, os.WriteFile(golden, got, 0o644))
+			}
+			want, err := os.ReadFile(golden)
+			require.NoError(t, err, "run go test -run Golden -update to record it")
+			assert.Equal(t, string(want), string(got))
+		})
+	}
+}
+
+func TestGolden_EveryCaseIsRouted(t *testing.T) {
+	routed := map[string]bool{}
+	for _, def := range append(server.apiRouteDefs(), server.opsRouteDefs()...) {
+		routed[def.OperationID] = true
+	}
+	for id := range goldenCases {
+		assert.True(t, routed[id], "golden case for unknown operation %s", id)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// defaultGravatarURL is the Gravatar REST API, version 3.
+const defaultGravatarURL = "https://api.gravatar.com/v3"
+
+// maxGravatarProfile bounds the profile document read from Gravatar.
+const maxGravatarProfile = 1 << 20
+
+// Gravatar is a user's public Gravatar profile.
+type Gravatar struct {
+	// Hash is the SHA-256 of the user's trimmed, lowercased email address,
+	// which Gravatar knows them by.
+	Hash        string `json:"hash"`
+	AvatarURL   string `json:"avatarUrl"`
+	ProfileURL  string `json:"profileUrl"`
+	DisplayName string `json:"displayName,omitempty"`
+	Location    string `json:"location,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// gravatarProfile is the part of Gravatar's profile document kept.
+type gravatarProfile struct {
+	AvatarURL   string `json:"avatar_url"`
+	ProfileURL  string `json:"profile_url"`
+	DisplayName string `json:"display_name"`
+	Location    string `json:"location"`
+	Description string `json:"description"`
+}
+
+// gravatarHash is the hash Gravatar knows email by.
+func gravatarHash(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(sum[:])
+}
+
+// fetchGravatar looks up email's Gravatar profile. It returns errNotFound
+// if there is none, and an *upstreamError if Gravatar fails.
+func (srv *Server) fetchGravatar(ctx context.Context, email string) (Gravatar, error) {
+	base := srv.Config.Outbound.GravatarURL
+	if base == "" {
+		base = defaultGravatarURL
+	}
+	hash := gravatarHash(email)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/profiles/"+hash, nil)
+	if err != nil {
+		return Gravatar{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := srv.outbound.do("gravatar", req)
+	if err != nil {
+		return Gravatar{}, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return Gravatar{}, errNotFound
+	default:
+		return Gravatar{}, &upstreamError{Upstream: "gravatar", Status: resp.StatusCode}
+	}
+	var p gravatarProfile
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxGravatarProfile)).Decode(&p); err != nil {
+		return Gravatar{}, &upstreamError{Upstream: "gravatar", Err: fmt.Errorf("unreadable profile: %w", err)}
+	}
+	return Gravatar{
+		Hash:        hash,
+		AvatarURL:   p.AvatarURL,
+		ProfileURL:  p.ProfileURL,
+		DisplayName: p.DisplayName,
+		Location:    p.Location,
+		Description: p.Description,
+	}, nil
+}
+
+// getUserGravatar serves a user's Gravatar profile, looked up by their
+// email address. Gravatar failing, or refused by its circuit breaker after
+// failing repeatedly, is a 502.
+func (srv *Server) getUserGravatar(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	u, err := srv.requestStore(r).GetUser(id)
+	if err != nil {
+		respondNotFound(w, r, "user", id)
+		return
+	}
+	if u.Email == "" {
+		respondProblem(w, r, http.StatusNotFound, "not found", Problem{Detail: fmt.Sprintf("user %d has no email address", id)})
+		return
+	}
+	g, err := srv.fetchGravatar(r.Context(), u.Email)
+	switch {
+	case errors.Is(err, errNotFound):
+		respondProblem(w, r, http.StatusNotFound, "not found", Problem{Detail: fmt.Sprintf("user %d has no Gravatar profile", id)})
+		return
+	case err != nil:
+		respondUpstreamError(w, r, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, g)
+}
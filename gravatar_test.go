@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== Gravatar Tests ==========
+
+// useFakeGravatar points the server at a fake Gravatar that knows Alice
+// only, and returns it.
+func useFakeGravatar(t *testing.T) *httptest.Server {
+	t.Helper()
+	alice := gravatarHash("alice@example.com")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/profiles/"+alice {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"hash":         alice,
+			"display_name": "Alice",
+			"profile_url":  "https://gravatar.com/alice",
+			"avatar_url":   "https://0.gravatar.com/avatar/" + alice,
+			"location":     "Lisbon",
+			"job_title":    "Engineer",
+		})
+	}))
+	t.Cleanup(ts.Close)
+	server.Config.Outbound.GravatarURL = ts.URL
+	return ts
+}
+
+func TestGravatarHash(t *testing.T) {
+	// Gravatar's documented example.
+	assert.Equal(t, "84059b07d4be67b806386c0aad8070a23f18836bbaae342275dc0a83414c32ee", gravatarHash(" MyEmailAddress@example.com "))
+}
+
+func TestGetUserGravatar(t *testing.T) {
+	router := setupRouter()
+	useFakeGravatar(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/1/gravatar", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var g Gravatar
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &g))
+	assert.Equal(t, Gravatar{
+		Hash:        gravatarHash("alice@example.com"),
+		AvatarURL:   "https://0.gravatar.com/avatar/" + gravatarHash("alice@example.com"),
+		ProfileURL:  "https://gravatar.com/alice",
+		DisplayName: "Alice",
+		Location:    "Lisbon",
+	}, g)
+
+	for _, tt := range []struct {
+		path   string
+		status int
+		detail string
+	}{
+		{"/users/2/gravatar", http.StatusNotFound, "user 2 has no Gravatar profile"},
+		{"/users/99/gravatar", http.StatusNotFound, "user 99 does not exist"},
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		assert.Equal(t, tt.status, w.Code, tt.path)
+		assert.Contains(t, w.Body.String(), tt.detail)
+	}
+}
+
+func TestGetUserGravatar_UpstreamFailure(t *testing.T) {
+	router := setupRouter()
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+	server.Config.Outbound.GravatarURL = ts.URL
+	server.outbound = newOutboundClient(OutboundConfig{Retries: 1})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/1/gravatar", nil))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	var p Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	assert.Equal(t, "bad gateway", p.Title)
+	assert.Equal(t, "gravatar answered 503", p.Detail)
+	assert.EqualValues(t, 2, calls.Load(), "retried once")
+}
+
+func TestGetUserGravatar_Unreadable(t *testing.T) {
+	router := setupRouter()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html>"))
+	}))
+	defer ts.Close()
+	server.Config.Outbound.GravatarURL = ts.URL
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/1/gravatar", nil))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Contains(t, w.Body.String(), "gravatar: unreadable profile")
+}
+
+func TestGetUserGravatar_Timeout(t *testing.T) {
+	router := setupRouter()
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer ts.Close()
+	defer close(release)
+	server.Config.Outbound.GravatarURL = ts.URL
+	server.outbound = newOutboundClient(OutboundConfig{Timeout: 50 * time.Millisecond})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/1/gravatar", nil))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Contains(t, w.Body.String(), "gravatar timed out")
+}
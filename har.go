@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// The types below are the subset of HAR 1.2
+// (http://www.softwareishard.com/blog/har-12-spec/) the recorder writes and
+// the replayer reads.
+
+// HAR is the top-level HAR document.
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog holds the recorded entries.
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+// HARCreator names the application that wrote the log.
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry is one request/response exchange.
+type HAREntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+}
+
+// HARRequest is a recorded request.
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARResponse is a recorded response.
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARNameValue is a header, cookie, or query parameter.
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARPostData is a recorded request body.
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// HARContent is a recorded response body.
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// HARTimings breaks down Time. The recorder only measures the wait.
+type HARTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// harRecorder captures exchanges and rewrites the HAR file at path after
+// each one, so the file is complete whenever the server stops.
+type harRecorder struct {
+	path string
+
+	mu      sync.Mutex
+	entries []HAREntry
+}
+
+func newHARRecorder(path string) *harRecorder {
+	return &harRecorder{path: path, entries: []HAREntry{}}
+}
+
+// middleware records every request that passes through it. Request bodies
+// larger than maxBodyBytes are rejected downstream anyway, so they are
+// read no further than that.
+func (h *harRecorder) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqBody []byte
+		if r.Body != nil {
+			reqBody, _ = io.ReadAll(io.LimitReader(r.Body, maxBodyBytes+1))
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(reqBody), r.Body))
+		}
+
+		var respBody bytes.Buffer
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		ww.Tee(&respBody)
+
+		start := time.Now()
+		next.ServeHTTP(ww, r)
+		elapsed := float64(time.Since(start)) / float64(time.Millisecond)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		entry := HAREntry{
+			StartedDateTime: start,
+			Time:            elapsed,
+			Request:         harRequest(r, reqBody),
+			Response: HARResponse{
+				Status:      status,
+				StatusText:  http.StatusText(status),
+				HTTPVersion: r.Proto,
+				Cookies:     []HARNameValue{},
+				Headers:     harHeaders(ww.Header()),
+				Content: HARContent{
+					Size:     respBody.Len(),
+					MimeType: ww.Header().Get("Content-Type"),
+					Text:     respBody.String(),
+				},
+				HeadersSize: -1,
+				BodySize:    respBody.Len(),
+			},
+			Timings: HARTimings{Wait: elapsed},
+		}
+		if err := h.add(entry); err != nil {
+			log.Printf("har: %v", err)
+		}
+	})
+}
+
+// add appends entry and rewrites the file via a temporary file, so a crash
+// mid-write never leaves a truncated log behind.
+func (h *harRecorder) add(entry HAREntry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries = append(h.entries, entry)
+
+	data, err := json.MarshalIndent(HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "api2spec-fixture-chi", Version: "0.1.0"},
+		Entries: h.entries,
+	}}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(h.path), ".har-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), h.path)
+}
+
+func harRequest(r *http.Request, body []byte) HARRequest {
+	req := HARRequest{
+		Method:      r.Method,
+		URL:         requestOrigin(r) + r.URL.RequestURI(),
+		HTTPVersion: r.Proto,
+		Cookies:     []HARNameValue{},
+		Headers:     harHeaders(r.Header),
+		QueryString: []HARNameValue{},
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+	for name, values := range r.URL.Query() {
+		for _, v := range values {
+			req.QueryString = append(req.QueryString, HARNameValue{Name: name, Value: v})
+		}
+	}
+	sort.Slice(req.QueryString, func(i, j int) bool { return req.QueryString[i].Name < req.QueryString[j].Name })
+	if len(body) > 0 {
+		req.PostData = &HARPostData{MimeType: r.Header.Get("Content-Type"), Text: string(body)}
+	}
+	return req
+}
+
+// harHeaders flattens h into name/value pairs sorted by name.
+func harHeaders(h http.Header) []HARNameValue {
+	out := []HARNameValue{}
+	for name, values := range h {
+		for _, v := range values {
+			out = append(out, HARNameValue{Name: name, Value: v})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// replayer serves responses from a HAR log. Requests are matched on method
+// and path plus query; when an exchange was recorded more than once the
+// responses are served in recorded order and the last one repeats.
+type replayer struct {
+	mu        sync.Mutex
+	responses map[string][]HARResponse
+}
+
+// newReplayer reads a HAR log from r.
+func newReplayer(r io.Reader) (*replayer, error) {
+	var har HAR
+	if err := json.NewDecoder(r).Decode(&har); err != nil {
+		return nil, err
+	}
+	rp := &replayer{responses: make(map[string][]HARResponse)}
+	for _, e := range har.Log.Entries {
+		key := replayKey(e.Request.Method, e.Request.URL)
+		rp.responses[key] = append(rp.responses[key], e.Response)
+	}
+	return rp, nil
+}
+
+// replayKey identifies an exchange by method and request URI; scheme and
+// host are ignored so a log replays on any address.
+func replayKey(method, rawURL string) string {
+	if i := strings.Index(rawURL, "://"); i >= 0 {
+		rest := rawURL[i+3:]
+		if j := strings.IndexByte(rest, '/'); j >= 0 {
+			rawURL = rest[j:]
+		} else {
+			rawURL = "/"
+		}
+	}
+	return method + " " + rawURL
+}
+
+func (rp *replayer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := replayKey(r.Method, r.URL.RequestURI())
+
+	rp.mu.Lock()
+	queue := rp.responses[key]
+	var resp HARResponse
+	found := len(queue) > 0
+	if found {
+		resp = queue[0]
+		if len(queue) > 1 {
+			rp.responses[key] = queue[1:]
+		}
+	}
+	rp.mu.Unlock()
+
+	if !found {
+		notFoundHandler(w, r)
+		return
+	}
+	for _, h := range resp.Headers {
+		if strings.EqualFold(h.Name, "Content-Length") {
+			continue
+		}
+		w.Header().Add(h.Name, h.Value)
+	}
+	w.WriteHeader(resp.Status)
+	io.WriteString(w, resp.Content.Text)
+}
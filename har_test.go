@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readHAR(t *testing.T, path string) HAR {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var har HAR
+	require.NoError(t, json.Unmarshal(data, &har))
+	return har
+}
+
+// ========== Recording Tests ==========
+
+func TestHARRecorder_RecordsExchanges(t *testing.T) {
+	setupRouter()
+	path := filepath.Join(t.TempDir(), "recording.har")
+	router := server.newRouter(newHARRecorder(path).middleware)
+
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":"Carol","email":"carol@example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/3/posts?page=1", nil))
+
+	har := readHAR(t, path)
+	assert.Equal(t, "1.2", har.Log.Version)
+	require.Len(t, har.Log.Entries, 2)
+
+	create := har.Log.Entries[0]
+	assert.Equal(t, http.MethodPost, create.Request.Method)
+	assert.Equal(t, "http://example.com/users", create.Request.URL)
+	require.NotNil(t, create.Request.PostData)
+	assert.Equal(t, "application/json", create.Request.PostData.MimeType)
+	assert.Contains(t, create.Request.PostData.Text, "Carol")
+	assert.Equal(t, http.StatusCreated, create.Response.Status)
+	assert.Equal(t, "Created", create.Response.StatusText)
+	assert.Equal(t, "application/json", create.Response.Content.MimeType)
+	assert.Contains(t, create.Response.Content.Text, `"id":3`)
+	assert.Contains(t, create.Response.Headers, HARNameValue{Name: "Location", Value: "/users/3"})
+
+	list := har.Log.Entries[1]
+	assert.Nil(t, list.Request.PostData)
+	assert.Equal(t, []HARNameValue{{Name: "page", Value: "1"}}, list.Request.QueryString)
+	assert.Equal(t, http.StatusOK, list.Response.Status)
+}
+
+func TestHARRecorder_HandlerStillReadsBody(t *testing.T) {
+	setupRouter()
+	router := server.newRouter(newHARRecorder(filepath.Join(t.TempDir(), "recording.har")).middleware)
+
+	req := httptest.NewRequest(http.MethodPost, "/posts", strings.NewReader(`{"userId":1,"title":"Recorded","body":"b"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), "Recorded")
+}
+
+// ========== Replay Tests ==========
+
+func TestReplayer_ServesRecordedResponses(t *testing.T) {
+	setupRouter()
+	path := filepath.Join(t.TempDir(), "recording.har")
+	router := server.newRouter(newHARRecorder(path).middleware)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/users/1", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	rp, err := newReplayer(f)
+	require.NoError(t, err)
+
+	tests := []struct {
+		method   string
+		path     string
+		expected int
+		body     string
+	}{
+		{http.MethodGet, "/users/1", http.StatusOK, "Alice"},
+		{http.MethodGet, "/users/1", http.StatusNotFound, "user 1 does not exist"},
+		{http.MethodGet, "/users/1", http.StatusNotFound, "user 1 does not exist"},
+		{http.MethodDelete, "/users/1", http.StatusNoContent, ""},
+		{http.MethodGet, "/users/2", http.StatusNotFound, "not found"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		rp.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		assert.Equal(t, tt.expected, w.Code, "%s %s", tt.method, tt.path)
+		assert.Contains(t, w.Body.String(), tt.body, "%s %s", tt.method, tt.path)
+	}
+}
+
+func TestReplayer_InvalidLog(t *testing.T) {
+	_, err := newReplayer(strings.NewReader("not json"))
+	assert.Error(t, err)
+}
+
+func TestReplayKey(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"http://localhost:8080/users?page=2", "GET /users?page=2"},
+		{"https://example.com", "GET /"},
+		{"/users", "GET /users"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, replayKey(http.MethodGet, tt.url))
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// healthHistorySize is how many readiness transitions are kept.
+const healthHistorySize = 50
+
+// defaultHealthHistoryLimit is how many transitions GET /health/history
+// lists without ?limit=.
+const defaultHealthHistoryLimit = 20
+
+// HealthTransition is a run of readiness checks that found the same
+// status and store breaker state: when it began, and how often and when
+// last it was found since.
+type HealthTransition struct {
+	Status string `json:"status"`
+	// Breaker is the store breaker's state, empty if the store has none.
+	Breaker     string    `json:"breaker,omitempty"`
+	Since       time.Time `json:"since"`
+	LastChecked time.Time `json:"lastChecked"`
+	Checks      int       `json:"checks"`
+}
+
+// healthHistory is a ring buffer of the latest readiness transitions.
+// Checks that find what the previous one did extend its transition rather
+// than taking a slot, so a stable server keeps its history however often
+// it is probed, and a flapping one shows each flip.
+type healthHistory struct {
+	mu   sync.Mutex
+	ring []HealthTransition
+	// next is the slot the next transition goes in, and n how many slots
+	// are filled.
+	next, n int
+}
+
+func newHealthHistory(size int) *healthHistory {
+	return &healthHistory{ring: make([]HealthTransition, size)}
+}
+
+// record adds the result of a readiness check at now.
+func (h *healthHistory) record(status, breaker string, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.n > 0 {
+		last := &h.ring[(h.next+len(h.ring)-1)%len(h.ring)]
+		if last.Status == status && last.Breaker == breaker {
+			last.LastChecked = now
+			last.Checks++
+			return
+		}
+	}
+	h.ring[h.next] = HealthTransition{Status: status, Breaker: breaker, Since: now, LastChecked: now, Checks: 1}
+	h.next = (h.next + 1) % len(h.ring)
+	if h.n < len(h.ring) {
+		h.n++
+	}
+}
+
+// recent returns at most limit transitions, newest first.
+func (h *healthHistory) recent(limit int) []HealthTransition {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([]HealthTransition, 0, min(limit, h.n))
+	for i := 1; i <= h.n && len(out) < limit; i++ {
+		out = append(out, h.ring[(h.next+len(h.ring)-i)%len(h.ring)])
+	}
+	return out
+}
+
+// getHealthHistory lists the latest ?limit= readiness transitions, newest
+// first.
+func (srv *Server) getHealthHistory(w http.ResponseWriter, r *http.Request) {
+	limit := defaultHealthHistoryLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > healthHistorySize {
+			respondProblem(w, r, http.StatusBadRequest, "invalid request", Problem{
+				Violations: []string{fmt.Sprintf("query parameter %q: must be an integer between 1 and %d", "limit", healthHistorySize)},
+			})
+			return
+		}
+		limit = n
+	}
+	respondJSON(w, http.StatusOK, srv.health.recent(limit))
+}
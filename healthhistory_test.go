@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== Health History Tests ==========
+
+func TestHealthHistory_CoalescesRepeatedChecks(t *testing.T) {
+	h := newHealthHistory(4)
+	t0 := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	h.record("ready", breakerClosed, t0)
+	h.record("ready", breakerClosed, t0.Add(time.Second))
+	h.record("degraded", breakerOpen, t0.Add(2*time.Second))
+	h.record("degraded", breakerHalfOpen, t0.Add(3*time.Second))
+	h.record("ready", breakerClosed, t0.Add(4*time.Second))
+
+	assert.Equal(t, []HealthTransition{
+		{Status: "ready", Breaker: breakerClosed, Since: t0.Add(4 * time.Second), LastChecked: t0.Add(4 * time.Second), Checks: 1},
+		{Status: "degraded", Breaker: breakerHalfOpen, Since: t0.Add(3 * time.Second), LastChecked: t0.Add(3 * time.Second), Checks: 1},
+		{Status: "degraded", Breaker: breakerOpen, Since: t0.Add(2 * time.Second), LastChecked: t0.Add(2 * time.Second), Checks: 1},
+		{Status: "ready", Breaker: breakerClosed, Since: t0, LastChecked: t0.Add(time.Second), Checks: 2},
+	}, h.recent(10))
+}
+
+func TestHealthHistory_Bounded(t *testing.T) {
+	h := newHealthHistory(3)
+	t0 := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 7; i++ {
+		status := "ready"
+		if i%2 == 1 {
+			status = "degraded"
+		}
+		h.record(status, "", t0.Add(time.Duration(i)*time.Second))
+	}
+
+	recent := h.recent(10)
+	require.Len(t, recent, 3, "the oldest transitions are dropped")
+	assert.Equal(t, t0.Add(6*time.Second), recent[0].Since)
+	assert.Equal(t, t0.Add(4*time.Second), recent[2].Since)
+	assert.Len(t, h.recent(2), 2)
+	assert.Empty(t, newHealthHistory(3).recent(10))
+}
+
+func TestGetHealthHistory(t *testing.T) {
+	router := setupRouter()
+	clock := freezeClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	server.health.record("degraded", breakerOpen, clock.Now().Add(-time.Minute))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health/history", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var history []HealthTransition
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &history))
+	require.Len(t, history, 2)
+	assert.Equal(t, "ready", history[0].Status, "newest first")
+	assert.Equal(t, clock.Now(), history[0].Since)
+	assert.Equal(t, "degraded", history[1].Status)
+}
+
+func TestGetHealthHistory_InvalidLimit(t *testing.T) {
+	router := setupRouter()
+
+	for _, limit := range []string{"0", "51", "all"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health/history?limit="+limit, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, limit)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// honeypot answers decoy paths that only scanners request, such as
+// /wp-login.php or /.env. Each hit is logged and counted, answered 404
+// after a delay that ties the scanner up, and, with a ban duration, gets
+// the client refused by the rate limiter for that long.
+type honeypot struct {
+	paths map[string]bool
+	delay time.Duration
+	ban   time.Duration
+	bans  *banList
+	stats *abuseCounters
+	log   logFunc
+}
+
+// newHoneypot returns srv's honeypot for cfg, banning into srv's client
+// bans and counting into its abuseStats.
+func (srv *Server) newHoneypot(cfg HoneypotConfig) *honeypot {
+	h := &honeypot{paths: make(map[string]bool, len(cfg.Paths)), delay: cfg.Delay, ban: cfg.Ban, bans: srv.bans, stats: srv.abuseStats, log: srv.logAt}
+	for _, p := range cfg.Paths {
+		h.paths[p] = true
+	}
+	return h
+}
+
+func (h *honeypot) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.paths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ip := clientIP(r)
+		h.log.at("warn", "honeypot: %s %s from %s (%q)", r.Method, r.URL.Path, ip, r.UserAgent())
+		h.stats.hit(r.URL.Path)
+		if h.ban > 0 {
+			h.bans.ban(ip, h.ban)
+			h.stats.banned()
+		}
+		select {
+		case <-time.After(h.delay):
+		case <-r.Context().Done():
+			return
+		}
+		respondError(w, r, http.StatusNotFound, "not found")
+	})
+}
+
+// abuseCounters count honeypot hits, by path, and the bans they caused.
+type abuseCounters struct {
+	mu   sync.Mutex
+	hits map[string]int64
+	bans int64
+}
+
+func newAbuseCounters() *abuseCounters {
+	return &abuseCounters{hits: make(map[string]int64)}
+}
+
+func (c *abuseCounters) hit(path string) {
+	c.mu.Lock()
+	c.hits[path]++
+	c.mu.Unlock()
+}
+
+func (c *abuseCounters) banned() {
+	c.mu.Lock()
+	c.bans++
+	c.mu.Unlock()
+}
+
+// pathHits is the hits on one decoy path.
+type pathHits struct {
+	Path string
+	Hits int64
+}
+
+// snapshot returns the hits by path, in path order, and the bans.
+func (c *abuseCounters) snapshot() ([]pathHits, int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	hits := make([]pathHits, 0, len(c.hits))
+	for p, n := range c.hits {
+		hits = append(hits, pathHits{p, n})
+	}
+	sort.Slice(hits, func(i, j int) bool { return hits[i].Path < hits[j].Path })
+	return hits, c.bans
+}
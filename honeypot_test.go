@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== Honeypot Tests ==========
+
+func TestHoneypot_AnswersDecoysSlowly(t *testing.T) {
+	setupRouter()
+	h := server.newHoneypot(HoneypotConfig{Paths: []string{"/.env", "/wp-login.php"}, Delay: 20 * time.Millisecond})
+	router := server.newRouter(h.middleware)
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/.env", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/wp-login.php", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/.env", nil))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "other paths are served as usual")
+
+	hits, bans := server.abuseStats.snapshot()
+	assert.Equal(t, []pathHits{{"/.env", 2}, {"/wp-login.php", 1}}, hits)
+	assert.Zero(t, bans)
+}
+
+func TestHoneypot_Bans(t *testing.T) {
+	setupRouter()
+	h := server.newHoneypot(HoneypotConfig{Paths: []string{"/.env"}, Ban: time.Hour})
+	router := server.newRouter(h.middleware)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/.env", nil))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "3600", w.Header().Get("Retry-After"))
+
+	other := httptest.NewRequest(http.MethodGet, "/users", nil)
+	other.RemoteAddr = "198.51.100.7:1234"
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, other)
+	assert.Equal(t, http.StatusOK, w.Code, "other clients are not banned")
+
+	_, bans := server.abuseStats.snapshot()
+	assert.Equal(t, int64(1), bans)
+}
+
+func TestHoneypot_GivesUpOnClosedConnections(t *testing.T) {
+	setupRouter()
+	h := server.newHoneypot(HoneypotConfig{Paths: []string{"/.env"}, Delay: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		h.middleware(http.NotFoundHandler()).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/.env", nil).WithContext(ctx))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		require.Fail(t, "the honeypot held a closed connection")
+	}
+	assert.Zero(t, w.Body.Len())
+}
+
+func TestHoneypot_Metrics(t *testing.T) {
+	router := setupRouter()
+	h := server.newHoneypot(HoneypotConfig{Paths: []string{"/.env"}, Ban: time.Minute})
+	h.middleware(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/.env", nil))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.RemoteAddr = "198.51.100.7:1234"
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `abuse_honeypot_hits_total{path="/.env"} 1`+"\n")
+	assert.Contains(t, w.Body.String(), "abuse_bans_total 1\n")
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+)
+
+//go:embed templates
+var templateFiles embed.FS
+
+// htmlCodec renders users and posts as HTML pages, for browsers, from the
+// same values their JSON bodies encode. It has no page for other bodies,
+// so only the routes serving a single user or post offer it, and pages
+// are not accepted as request bodies.
+type htmlCodec struct {
+	user, post *template.Template
+}
+
+func (htmlCodec) MediaType() string { return "text/html" }
+
+func (c htmlCodec) Encode(w io.Writer, v interface{}) error {
+	switch v := v.(type) {
+	case User:
+		return c.user.Execute(w, v)
+	case Post:
+		return c.post.Execute(w, v)
+	}
+	return fmt.Errorf("html: no page for %T", v)
+}
+
+func (htmlCodec) Decode(io.Reader, interface{}) error {
+	return errors.New("html: pages are not request bodies")
+}
+
+// pageTemplate parses templates/layout.html with the page's "title" and
+// "content" templates from templates/<name>.
+func pageTemplate(name string) *template.Template {
+	funcs := template.FuncMap{
+		"rfc3339": func(t time.Time) string { return t.Format(time.RFC3339) },
+	}
+	return template.Must(template.New("layout.html").Funcs(funcs).ParseFS(templateFiles, "templates/layout.html", "templates/"+name))
+}
+
+var htmlPages = htmlCodec{user: pageTemplate("user.html"), post: pageTemplate("post.html")}
+
+// userPageCodecs and postPageCodecs are the formats, besides JSON, that
+// the single user and post routes negotiate: their other routes' and
+// HTML.
+var (
+	userPageCodecs = []Codec{msgpackCodec, cborCodec, htmlPages}
+	postPageCodecs = []Codec{msgpackCodec, cborCodec, protobufPosts, htmlPages}
+)
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== HTML Page Tests ==========
+
+func getWithAccept(router http.Handler, path, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Accept", accept)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestHTML_UserPage(t *testing.T) {
+	router := setupRouter()
+	freezeClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/users", `{"name":"Zoë <Admin>","email":"zoe@example.com"}`))
+	require.Equal(t, http.StatusCreated, w.Code)
+	location := w.Header().Get("Location")
+
+	w = getWithAccept(router, location, "text/html,application/xhtml+xml;q=0.9,*/*;q=0.8")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html", w.Header().Get("Content-Type"))
+	assert.Equal(t, "Accept", w.Header().Get("Vary"))
+	page := w.Body.String()
+	assert.Contains(t, page, "<title>Zoë &lt;Admin&gt;</title>", "names are escaped")
+	assert.Contains(t, page, `<a href="mailto:zoe@example.com">zoe@example.com</a>`)
+	assert.Contains(t, page, `<time datetime="2024-03-01T12:00:00Z">1 March 2024</time>`)
+	assert.NotContains(t, page, "<Admin>")
+}
+
+func TestHTML_PostPage(t *testing.T) {
+	router := setupRouter()
+
+	w := getWithAccept(router, "/posts/1", "text/html")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html", w.Header().Get("Content-Type"))
+
+	var post Post
+	w = getWithAccept(router, "/posts/1", "application/json")
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &post))
+	page := getWithAccept(router, "/posts/1", "text/html").Body.String()
+	assert.Contains(t, page, "<h1>"+post.Title+"</h1>", "the page shows the JSON body's data")
+	assert.Contains(t, page, `<a href="/users/`)
+}
+
+func TestHTML_Negotiation(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		accept      string
+		status      int
+		contentType string
+	}{
+		{"json preferred", "/users/1", "application/json, text/html;q=0.5", http.StatusOK, "application/json"},
+		{"no accept", "/users/1", "", http.StatusOK, "application/json"},
+		{"errors stay json", "/users/999", "text/html", http.StatusNotFound, "application/problem+json"},
+		{"lists have no page", "/users", "text/html", http.StatusOK, "application/json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := getWithAccept(setupRouter(), tt.path, tt.accept)
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+		})
+	}
+}
+
+func TestHTMLCodec_OnlyPages(t *testing.T) {
+	var buf bytes.Buffer
+	assert.EqualError(t, htmlPages.Encode(&buf, []User{}), "html: no page for []main.User")
+	assert.Error(t, htmlPages.Decode(strings.NewReader("<p>hi</p>"), &User{}))
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/api2spec/api2spec-fixture-chi/ctxkit"
+)
+
+// defaultLanguage is used when Accept-Language is missing or matches nothing
+// bundled. Error messages are written in this language in the code and
+// translated on the way out.
+const defaultLanguage = "en"
+
+// translations maps a language to translations of the English error
+// messages. Messages missing from a catalog fall back to English.
+var translations = map[string]map[string]string{
+	"es": {
+		"already exists":                 "ya existe",
+		"bad gateway":                    "puerta de enlace incorrecta",
+		"digest mismatch":                "el resumen no coincide",
+		"forbidden":                      "prohibido",
+		"incorrect password":             "contraseña incorrecta",
+		"invalid reset token":            "token de restablecimiento no válido",
+		"email already verified":         "el correo ya está verificado",
+		"invalid verification token":     "token de verificación no válido",
+		"mail not sent":                  "correo no enviado",
+		"no email to verify":             "no hay correo que verificar",
+		"invite expired":                 "la invitación caducó",
+		"invalid credentials":            "credenciales no válidas",
+		"invalid two-factor code":        "código de dos factores no válido",
+		"two-factor already enabled":     "la autenticación de dos factores ya está activada",
+		"two-factor code required":       "se requiere el código de dos factores",
+		"two-factor not set up":          "la autenticación de dos factores no está configurada",
+		"injected failure":               "fallo inyectado",
+		"invalid csv":                    "csv no válido",
+		"invalid digest":                 "resumen no válido",
+		"invalid filter":                 "filtro no válido",
+		"invalid id":                     "id no válido",
+		"id is too large":                "el id es demasiado grande",
+		"id must not be negative":        "el id no debe ser negativo",
+		"id must not be zero":            "el id no debe ser cero",
+		"invalid image":                  "imagen no válida",
+		"invalid json":                   "json no válido",
+		"invalid method override":        "cambio de método no válido",
+		"invalid pagination":             "paginación no válida",
+		"invalid request":                "solicitud no válida",
+		"invalid signature":              "firma no válida",
+		"not found":                      "no encontrado",
+		"origin not allowed":             "origen no permitido",
+		"precondition failed":            "la condición previa falló",
+		"quota exceeded":                 "cuota excedida",
+		"range not satisfiable":          "rango no satisfacible",
+		"rate limit exceeded":            "límite de solicitudes excedido",
+		"store unavailable":              "almacén no disponible",
+		"tenant is read-only":            "el inquilino es de solo lectura",
+		"unauthorized":                   "no autorizado",
+		"unknown tenant":                 "inquilino desconocido",
+		"unsupported media type":         "tipo de medio no admitido",
+		"user tokens are not configured": "los tokens de usuario no están configurados",
+		"public URL is not configured":   "la URL pública no está configurada",
+		"version required":               "se requiere la versión",
+		"request body too large":         "el cuerpo de la solicitud es demasiado grande",
+	},
+	"de": {
+		"already exists":                 "existiert bereits",
+		"bad gateway":                    "fehlerhaftes Gateway",
+		"digest mismatch":                "Prüfsumme stimmt nicht überein",
+		"forbidden":                      "verboten",
+		"incorrect password":             "falsches Passwort",
+		"invalid reset token":            "ungültiges Zurücksetzungstoken",
+		"email already verified":         "E-Mail bereits bestätigt",
+		"invalid verification token":     "ungültiges Bestätigungstoken",
+		"mail not sent":                  "E-Mail nicht gesendet",
+		"no email to verify":             "keine E-Mail zu bestätigen",
+		"invite expired":                 "Einladung abgelaufen",
+		"invalid credentials":            "ungültige Anmeldedaten",
+		"invalid two-factor code":        "ungültiger Zwei-Faktor-Code",
+		"two-factor already enabled":     "Zwei-Faktor-Authentifizierung bereits aktiviert",
+		"two-factor code required":       "Zwei-Faktor-Code erforderlich",
+		"two-factor not set up":          "Zwei-Faktor-Authentifizierung nicht eingerichtet",
+		"injected failure":               "eingeschleuster Fehler",
+		"invalid csv":                    "ungültiges CSV",
+		"invalid digest":                 "ungültige Prüfsumme",
+		"invalid filter":                 "ungültiger Filter",
+		"invalid id":                     "ungültige ID",
+		"id is too large":                "ID ist zu groß",
+		"id must not be negative":        "ID darf nicht negativ sein",
+		"id must not be zero":            "ID darf nicht null sein",
+		"invalid image":                  "ungültiges Bild",
+		"invalid json":                   "ungültiges JSON",
+		"invalid method override":        "ungültige Methodenüberschreibung",
+		"invalid pagination":             "ungültige Seitenangabe",
+		"invalid request":                "ungültige Anfrage",
+		"invalid signature":              "ungültige Signatur",
+		"not found":                      "nicht gefunden",
+		"origin not allowed":             "Herkunft nicht erlaubt",
+		"precondition failed":            "Vorbedingung fehlgeschlagen",
+		"quota exceeded":                 "Kontingent überschritten",
+		"range not satisfiable":          "Bereich nicht erfüllbar",
+		"rate limit exceeded":            "Anfragelimit überschritten",
+		"store unavailable":              "Speicher nicht verfügbar",
+		"tenant is read-only":            "Mandant ist schreibgeschützt",
+		"unauthorized":                   "nicht autorisiert",
+		"unknown tenant":                 "unbekannter Mandant",
+		"unsupported media type":         "nicht unterstützter Medientyp",
+		"user tokens are not configured": "Benutzertoken sind nicht konfiguriert",
+		"public URL is not configured":   "öffentliche URL ist nicht konfiguriert",
+		"version required":               "Version erforderlich",
+		"request body too large":         "Anfragetext zu groß",
+	},
+}
+
+// negotiateLanguage picks the best bundled language for an Accept-Language
+// header value. Region subtags are ignored ("de-AT" matches "de"), entries
+// with q=0 are excluded, and ties keep header order.
+func negotiateLanguage(header string) string {
+	type candidate struct {
+		lang string
+		q    float64
+	}
+	var candidates []candidate
+
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if v, ok := strings.CutPrefix(param, "q="); ok {
+				parsed, err := strconv.ParseFloat(v, 64)
+				if err != nil {
+					parsed = 0
+				}
+				q = parsed
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		lang, _, _ := strings.Cut(tag, "-")
+		candidates = append(candidates, candidate{lang: lang, q: q})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	for _, c := range candidates {
+		if c.lang == defaultLanguage || c.lang == "*" {
+			return defaultLanguage
+		}
+		if _, ok := translations[c.lang]; ok {
+			return c.lang
+		}
+	}
+	return defaultLanguage
+}
+
+// translate returns msg in lang, falling back to the English original.
+func translate(lang, msg string) string {
+	if t, ok := translations[lang][msg]; ok {
+		return t
+	}
+	return msg
+}
+
+// localeKey carries the language withLocale negotiated.
+var localeKey = ctxkit.NewKey[string]("locale")
+
+// withLocale negotiates the request's language once, ahead of everything
+// that may localize a message while serving it.
+func withLocale(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lang := negotiateLanguage(r.Header.Get("Accept-Language"))
+		next.ServeHTTP(w, r.WithContext(localeKey.With(r.Context(), lang)))
+	})
+}
+
+// requestLocale returns the language withLocale negotiated for r, or
+// negotiates it for a request served without withLocale.
+func requestLocale(r *http.Request) string {
+	if lang, ok := localeKey.From(r.Context()); ok {
+		return lang
+	}
+	return negotiateLanguage(r.Header.Get("Accept-Language"))
+}
+
+// localize sets Content-Language on w to r's language and returns msg
+// translated into it.
+func localize(w http.ResponseWriter, r *http.Request, msg string) string {
+	lang := requestLocale(r)
+	w.Header().Set("Content-Language", lang)
+	return translate(lang, msg)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== Language Negotiation Tests ==========
+
+func TestNegotiateLanguage(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		expected string
+	}{
+		{"empty header", "", "en"},
+		{"exact match", "es", "es"},
+		{"region subtag", "de-AT", "de"},
+		{"case insensitive", "ES-mx", "es"},
+		{"unsupported falls back", "fr", "en"},
+		{"first supported wins", "fr, de, es", "de"},
+		{"quality ordering", "es;q=0.5, de;q=0.9", "de"},
+		{"english preferred", "en-GB, es;q=0.8", "en"},
+		{"wildcard", "fr, *;q=0.5", "en"},
+		{"q zero excluded", "es;q=0, de;q=0.1", "de"},
+		{"malformed q", "es;q=abc, de;q=0.2", "de"},
+		{"whitespace", "  de ;  q=0.7 ,es;q=0.6", "de"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, negotiateLanguage(tt.header))
+		})
+	}
+}
+
+func TestTranslate_FallsBackToEnglish(t *testing.T) {
+	assert.Equal(t, "ungültige ID", translate("de", "invalid id"))
+	assert.Equal(t, "invalid id", translate("en", "invalid id"))
+	assert.Equal(t, "something new", translate("es", "something new"))
+}
+
+func TestRequestLocale(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Language", "de")
+	assert.Equal(t, "de", requestLocale(req), "negotiated without withLocale")
+
+	var got string
+	withLocale(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Set("Accept-Language", "es")
+		got = requestLocale(r)
+	})).ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "de", got, "negotiated once, by withLocale")
+}
+
+// ========== Localized Error Response Tests ==========
+
+func TestErrorResponses_AreLocalized(t *testing.T) {
+	tests := []struct {
+		name             string
+		method           string
+		path             string
+		body             string
+		acceptLanguage   string
+		expectedStatus   int
+		expectedLanguage string
+		expectedError    string
+	}{
+		{"invalid id default", http.MethodGet, "/users/abc", "", "", http.StatusBadRequest, "en", "invalid id"},
+		{"invalid id es", http.MethodGet, "/users/abc", "", "es-ES,es;q=0.9", http.StatusBadRequest, "es", "id no válido"},
+		{"invalid id de", http.MethodGet, "/posts/abc", "", "de", http.StatusBadRequest, "de", "ungültige ID"},
+		{"invalid json es", http.MethodPost, "/users", "not json", "es", http.StatusBadRequest, "es", "json no válido"},
+		{"invalid json de", http.MethodPost, "/posts", "not json", "de", http.StatusBadRequest, "de", "ungültiges JSON"},
+		{"not found de", http.MethodGet, "/nonexistent", "", "de", http.StatusNotFound, "de", "nicht gefunden"},
+		{"not found unsupported", http.MethodGet, "/nonexistent", "", "fr", http.StatusNotFound, "en", "not found"},
+		{"version required es", http.MethodPut, "/users/1", "{}", "es", http.StatusPreconditionRequired, "es", "se requiere la versión"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+
+			var req *http.Request
+			if tt.body != "" {
+				req = httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+				req.Header.Set("Content-Type", "application/json")
+			} else {
+				req = httptest.NewRequest(tt.method, tt.path, nil)
+			}
+			if tt.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assertJSONContentType(t, w)
+			assert.Equal(t, tt.expectedLanguage, w.Header().Get("Content-Language"))
+
+			var body map[string]string
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, tt.expectedError, body["error"])
+		})
+	}
+}
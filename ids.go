@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxID is the largest ID the API hands out or accepts, so IDs fit a
+// 32-bit integer in every client.
+const maxID = math.MaxInt32
+
+// The reasons parseID rejects an ID. Their messages are the error
+// responses' messages.
+var (
+	errInvalidID  = errors.New("invalid id")
+	errNegativeID = errors.New("id must not be negative")
+	errZeroID     = errors.New("id must not be zero")
+	errIDTooLarge = errors.New("id is too large")
+)
+
+// parseID parses a resource ID: a positive decimal integer no greater than
+// maxID, written without a sign or leading zeros.
+func parseID(s string) (int, error) {
+	digits := s
+	negative := len(s) > 0 && s[0] == '-'
+	if negative {
+		digits = s[1:]
+	}
+	if digits == "" {
+		return 0, errInvalidID
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return 0, errInvalidID
+		}
+	}
+	switch {
+	case negative:
+		return 0, errNegativeID
+	case digits == "0":
+		return 0, errZeroID
+	case digits[0] == '0':
+		return 0, errInvalidID
+	}
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || n > maxID {
+		return 0, errIDTooLarge
+	}
+	return int(n), nil
+}
+
+// pathID parses the {id} URL parameter, answering 400 with the reason and
+// returning false if it is not a valid ID.
+func pathID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	return pathIDParam(w, r, "id")
+}
+
+// pathIDParam is pathID for the URL parameter named name, for patterns
+// with more than one ID.
+func pathIDParam(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	id, err := parseID(chi.URLParam(r, name))
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err.Error())
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== ID Parsing Tests ==========
+
+func TestParseID(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    int
+		expectedErr error
+	}{
+		{"1", 1, nil},
+		{"42", 42, nil},
+		{"2147483647", 2147483647, nil},
+		{"2147483648", 0, errIDTooLarge},
+		{"9223372036854775808", 0, errIDTooLarge},
+		{"99999999999999999999999", 0, errIDTooLarge},
+		{"0", 0, errZeroID},
+		{"-1", 0, errNegativeID},
+		{"-0", 0, errNegativeID},
+		{"-9223372036854775809", 0, errNegativeID},
+		{"007", 0, errInvalidID},
+		{"00", 0, errInvalidID},
+		{"+5", 0, errInvalidID},
+		{"", 0, errInvalidID},
+		{"-", 0, errInvalidID},
+		{"abc", 0, errInvalidID},
+		{"1e3", 0, errInvalidID},
+		{" 1", 0, errInvalidID},
+		{"1.0", 0, errInvalidID},
+		{"٣", 0, errInvalidID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			id, err := parseID(tt.input)
+			assert.Equal(t, tt.expectedErr, err)
+			assert.Equal(t, tt.expected, id)
+		})
+	}
+}
+
+func TestPathID_ErrorMessages(t *testing.T) {
+	tests := []struct {
+		path          string
+		expectedError string
+	}{
+		{"/users/abc", "invalid id"},
+		{"/users/-3", "id must not be negative"},
+		{"/posts/0", "id must not be zero"},
+		{"/todos/4294967296", "id is too large"},
+		{"/albums/+1", "invalid id"},
+		{"/users/01", "invalid id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			router := setupRouter()
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			require.Equal(t, http.StatusBadRequest, w.Code)
+			var body map[string]string
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, tt.expectedError, body["error"])
+		})
+	}
+}
+
+func TestTenantHeader_RejectsNonCanonicalID(t *testing.T) {
+	router := setupRouter()
+	createTestTenant(t, Tenant{Name: "Acme"})
+
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set(headerTenantID, "+1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
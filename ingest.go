@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// headerHubSignature carries the HMAC of an ingested body, in the same
+// "sha256=<hex>" form as X-Signature.
+const headerHubSignature = "X-Hub-Signature-256"
+
+// IngestEvent is a payload accepted by POST /ingest/events.
+type IngestEvent struct {
+	ID         int             `json:"id"`
+	ReceivedAt time.Time       `json:"receivedAt"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// ingestEvent accepts a JSON payload signed with the configured
+// IngestSecret. The signature is checked over the raw bytes before the body
+// is parsed. While there is no secret every ingest is rejected.
+func (srv *Server) ingestEvent(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			respondError(w, r, http.StatusRequestEntityTooLarge, "request body too large")
+			return
+		}
+		respondError(w, r, http.StatusBadRequest, "invalid request")
+		return
+	}
+	if len(srv.Config.IngestSecret) == 0 || verifySignature(srv.Config.IngestSecret, body, r.Header.Get(headerHubSignature)) != nil {
+		respondError(w, r, http.StatusUnauthorized, "invalid signature")
+		return
+	}
+	if !json.Valid(body) {
+		respondError(w, r, http.StatusBadRequest, "invalid json")
+		return
+	}
+
+	event, err := srv.requestStore(r).CreateIngestEvent(IngestEvent{Payload: body})
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+	w.Header().Set("Location", "/ingest/events/"+strconv.Itoa(event.ID))
+	respondJSON(w, http.StatusAccepted, event)
+}
+
+func (srv *Server) listIngestEvents(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, srv.requestStore(r).ListIngestEvents())
+}
+
+func (srv *Server) getIngestEvent(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	event, err := srv.requestStore(r).GetIngestEvent(id)
+	if err != nil {
+		respondNotFound(w, r, "event", id)
+		return
+	}
+	respondJSON(w, http.StatusOK, event)
+}
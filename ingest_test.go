@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupIngestRouter is setupAdminRouter with an ingest secret configured.
+func setupIngestRouter(t *testing.T) http.Handler {
+	t.Helper()
+	router := setupAdminRouter(t)
+	server.Config.IngestSecret = []byte("webhook-secret")
+	return router
+}
+
+func newIngestRequest(body, signature string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/ingest/events", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if signature != "" {
+		req.Header.Set(headerHubSignature, signature)
+	}
+	return req
+}
+
+// ========== Ingest Signature Tests ==========
+
+func TestIngestEvent_Accepted(t *testing.T) {
+	router := setupIngestRouter(t)
+
+	body := `{"action":"opened", "number": 7}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newIngestRequest(body, sign(server.Config.IngestSecret, []byte(body))))
+
+	require.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "/ingest/events/1", w.Header().Get("Location"))
+
+	var event IngestEvent
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &event))
+	assert.Equal(t, 1, event.ID)
+	assert.False(t, event.ReceivedAt.IsZero())
+	assert.JSONEq(t, body, string(event.Payload))
+
+	stored, err := server.Store.GetIngestEvent(1)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(stored.Payload), "payload is stored byte for byte")
+}
+
+func TestIngestEvent_Rejected(t *testing.T) {
+	body := `{"action":"opened"}`
+	secret := []byte("webhook-secret")
+
+	tests := []struct {
+		name      string
+		body      string
+		signature string
+	}{
+		{"unsigned", body, ""},
+		{"tampered body", `{"action":"closed"}`, sign(secret, []byte(body))},
+		{"wrong secret", body, sign([]byte("guess"), []byte(body))},
+		{"malformed signature", body, "sha1=abc"},
+		{"trailing whitespace added", body + "\n", sign(secret, []byte(body))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupIngestRouter(t)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, newIngestRequest(tt.body, tt.signature))
+
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			assert.Contains(t, w.Body.String(), "invalid signature")
+			assert.Empty(t, server.Store.ListIngestEvents())
+		})
+	}
+}
+
+func TestIngestEvent_NoSecretRejectsEverything(t *testing.T) {
+	router := setupRouter()
+
+	body := `{}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newIngestRequest(body, sign(nil, []byte(body))))
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestIngestEvent_SignedButNotJSON(t *testing.T) {
+	router := setupIngestRouter(t)
+
+	body := `not json`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newIngestRequest(body, sign(server.Config.IngestSecret, []byte(body))))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestIngestEvent_TooLarge(t *testing.T) {
+	router := setupIngestRouter(t)
+
+	body := `"` + string(bytes.Repeat([]byte("a"), maxBodyBytes)) + `"`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newIngestRequest(body, sign(server.Config.IngestSecret, []byte(body))))
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+// ========== Ingest Retrieval Tests ==========
+
+func TestIngestEvents_Retrieval(t *testing.T) {
+	router := setupIngestRouter(t)
+
+	for _, body := range []string{`{"n":1}`, `{"n":2}`} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newIngestRequest(body, sign(server.Config.IngestSecret, []byte(body))))
+		require.Equal(t, http.StatusAccepted, w.Code)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodGet, "/ingest/events", ""))
+	require.Equal(t, http.StatusOK, w.Code)
+	var events []IngestEvent
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &events))
+	require.Len(t, events, 2)
+	assert.JSONEq(t, `{"n":2}`, string(events[1].Payload))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodGet, "/ingest/events/2", ""))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodGet, "/ingest/events/3", ""))
+	assertNotFoundProblem(t, w, "/ingest/events/3")
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// inviteTTL is how long an invite is good for.
+const inviteTTL = 7 * 24 * time.Hour
+
+// Invite is an invitation for email to create a user.
+type Invite struct {
+	Email string `json:"email" redact:"email"`
+	// ExpiresAt, Token and URL are set by the server and ignored on
+	// writes. Token and URL are returned only by POST /invites.
+	ExpiresAt time.Time `json:"expiresAt"`
+	Token     string    `json:"token,omitempty" redact:"secret"`
+	URL       string    `json:"url,omitempty"`
+}
+
+// InviteAcceptance is the body of POST /invites/{token}/accept.
+type InviteAcceptance struct {
+	Name string `json:"name"`
+	// Password is optional; without one the user can set it through a
+	// password reset.
+	Password string `json:"password,omitempty" redact:"secret"`
+}
+
+// errInviteExpired is the error of a well-signed invite past its expiry.
+var errInviteExpired = errors.New("invite expired")
+
+// inviteMAC returns the MAC of an invite for email expiring at expires.
+func (srv *Server) inviteMAC(email string, expires int64) []byte {
+	mac := hmac.New(sha256.New, srv.Config.AuthSecret)
+	mac.Write([]byte("invite:" + strconv.FormatInt(expires, 10) + ":" + email))
+	return mac.Sum(nil)
+}
+
+// inviteToken returns the token for an invite: "<email>.<expires>.<MAC>",
+// with the email and MAC in unpadded base64url and expires in Unix
+// seconds, so that it can be a path segment.
+func (srv *Server) inviteToken(email string, expires time.Time) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(email)) + "." +
+		strconv.FormatInt(expires.Unix(), 10) + "." +
+		base64.RawURLEncoding.EncodeToString(srv.inviteMAC(email, expires.Unix()))
+}
+
+// parseInviteToken returns the invite token stands for. Tokens that are
+// not well-signed are errNotFound; expired ones are errInviteExpired.
+func (srv *Server) parseInviteToken(token string) (Invite, error) {
+	parts := strings.Split(token, ".")
+	if len(srv.Config.AuthSecret) == 0 || len(parts) != 3 {
+		return Invite{}, errNotFound
+	}
+	email, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return Invite{}, errNotFound
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return Invite{}, errNotFound
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(mac, srv.inviteMAC(string(email), expires)) {
+		return Invite{}, errNotFound
+	}
+	invite := Invite{Email: string(email), ExpiresAt: time.Unix(expires, 0).UTC()}
+	if !srv.Clock.Now().Before(invite.ExpiresAt) {
+		return invite, errInviteExpired
+	}
+	return invite, nil
+}
+
+// pathInvite parses the {token} path parameter, answering 404 or 410 when
+// it is not a current invite.
+func (srv *Server) pathInvite(w http.ResponseWriter, r *http.Request) (Invite, bool) {
+	invite, err := srv.parseInviteToken(chi.URLParam(r, "token"))
+	switch {
+	case errors.Is(err, errInviteExpired):
+		respondError(w, r, http.StatusGone, "invite expired")
+		return Invite{}, false
+	case err != nil:
+		respondError(w, r, http.StatusNotFound, "not found")
+		return Invite{}, false
+	}
+	return invite, true
+}
+
+// createInvite signs an invite for the email in the body and mails its
+// link there.
+func (srv *Server) createInvite(w http.ResponseWriter, r *http.Request) {
+	if len(srv.Config.AuthSecret) == 0 {
+		respondError(w, r, http.StatusServiceUnavailable, "user tokens are not configured")
+		return
+	}
+	var invite Invite
+	if err := decodeJSON(r, &invite); err != nil {
+		respondDecodeError(w, r, err)
+		return
+	}
+	if strings.TrimSpace(invite.Email) == "" {
+		respondProblem(w, r, http.StatusBadRequest, "invalid request", Problem{Violations: []string{"request body /email: is required"}})
+		return
+	}
+	invite.ExpiresAt = srv.Clock.Now().Add(inviteTTL).Truncate(time.Second).UTC()
+	invite.Token = srv.inviteToken(invite.Email, invite.ExpiresAt)
+	var ok bool
+	if invite.URL, ok = srv.publicLink(w, r, "/invites/"+invite.Token); !ok {
+		return
+	}
+	err := srv.Mailer.Send(Mail{
+		To:      invite.Email,
+		Subject: "You're invited",
+		Body: "To accept, POST your name to " + invite.URL + "/accept before " +
+			invite.ExpiresAt.Format(time.RFC1123) + ".\n",
+	})
+	if err != nil {
+		// The link is in the response, so the invite is still usable.
+		srv.logAt("warn", "invite: mail to %s: %v", maskEmail(invite.Email), err)
+	}
+	w.Header().Set("Location", "/invites/"+invite.Token)
+	respondJSON(w, http.StatusCreated, invite)
+}
+
+// getInvite reports the email and expiry of the invite in the path.
+func (srv *Server) getInvite(w http.ResponseWriter, r *http.Request) {
+	if invite, ok := srv.pathInvite(w, r); ok {
+		respondJSON(w, http.StatusOK, invite)
+	}
+}
+
+// acceptInvite creates the user the invite in the path is for.
+func (srv *Server) acceptInvite(w http.ResponseWriter, r *http.Request) {
+	invite, ok := srv.pathInvite(w, r)
+	if !ok {
+		return
+	}
+	var acceptance InviteAcceptance
+	if err := decodeJSON(r, &acceptance); err != nil {
+		respondDecodeError(w, r, err)
+		return
+	}
+	var violations []string
+	if strings.TrimSpace(acceptance.Name) == "" {
+		violations = append(violations, "request body /name: is required")
+	}
+	if acceptance.Password != "" {
+		violations = append(violations, validatePassword("/password", acceptance.Password)...)
+	}
+	if len(violations) > 0 {
+		respondProblem(w, r, http.StatusBadRequest, "invalid request", Problem{Violations: violations})
+		return
+	}
+	var hash []byte
+	if acceptance.Password != "" {
+		var err error
+		if hash, err = hashPassword(acceptance.Password); err != nil {
+			respondError(w, r, http.StatusInternalServerError, "internal error")
+			return
+		}
+	}
+
+	user, err := srv.requestStore(r).CreateUser(User{Name: acceptance.Name, Email: invite.Email})
+	if errors.Is(err, errDuplicate) {
+		// Accepting again, or the email was taken since the invite.
+		respondDuplicate(w, r, "a user with this email already exists", "/users/"+strconv.Itoa(user.ID))
+		return
+	}
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if hash != nil {
+		if err := srv.requestStore(r).SetPasswordHash(user.ID, hash); err != nil {
+			srv.logAt("warn", "invite: password for user %d: %v", user.ID, err)
+		}
+	}
+	w.Header().Set("Location", "/users/"+strconv.Itoa(user.ID))
+	respondJSON(w, http.StatusCreated, user)
+}
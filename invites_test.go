@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// createTestInvite invites email through the API and returns the invite.
+func createTestInvite(t *testing.T, router http.Handler, email string) Invite {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/invites", `{"email":"`+email+`"}`))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var invite Invite
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &invite))
+	return invite
+}
+
+// ========== Invite Token Tests ==========
+
+func TestInviteToken_RoundTrip(t *testing.T) {
+	useAuthSecret(t)
+	expires := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+
+	invite, err := server.parseInviteToken(server.inviteToken("carol+test@example.com", expires))
+	require.NoError(t, err)
+	assert.Equal(t, Invite{Email: "carol+test@example.com", ExpiresAt: expires}, invite)
+}
+
+func TestParseInviteToken_Rejects(t *testing.T) {
+	useAuthSecret(t)
+	expires := time.Now().Add(time.Hour)
+	valid := strings.Split(server.inviteToken("carol@example.com", expires), ".")
+	other := strings.Split(server.inviteToken("mallory@example.com", expires), ".")
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"empty", "", errNotFound},
+		{"garbage", "garbage", errNotFound},
+		{"other email", other[0] + "." + valid[1] + "." + valid[2], errNotFound},
+		{"extended", valid[0] + ".9999999999." + valid[2], errNotFound},
+		{"not base64", "!!!." + valid[1] + "." + valid[2], errNotFound},
+		{"expired", server.inviteToken("carol@example.com", time.Now().Add(-time.Second)), errInviteExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := server.parseInviteToken(tt.token)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+// ========== Invite Endpoint Tests ==========
+
+func TestCreateInvite(t *testing.T) {
+	router := setupAdminRouter(t)
+	useAuthSecret(t)
+	sent := useTestMailer(t)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	freezeClock(now)
+
+	invite := createTestInvite(t, router, "carol@example.com")
+
+	assert.Equal(t, "carol@example.com", invite.Email)
+	assert.Equal(t, now.Add(inviteTTL), invite.ExpiresAt)
+	assert.Equal(t, testPublicURL+"/invites/"+invite.Token, invite.URL)
+	require.Len(t, *sent, 1)
+	assert.Equal(t, "carol@example.com", (*sent)[0].To)
+	assert.Contains(t, (*sent)[0].Body, invite.URL+"/accept")
+}
+
+func TestCreateInvite_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret bool
+		req    *http.Request
+		status int
+	}{
+		{"not admin", true, httptest.NewRequest(http.MethodPost, "/invites", nil), http.StatusUnauthorized},
+		{"no email", true, newAdminRequest(http.MethodPost, "/invites", `{}`), http.StatusBadRequest},
+		{"no secret", false, newAdminRequest(http.MethodPost, "/invites", `{"email":"carol@example.com"}`), http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupAdminRouter(t)
+			if tt.secret {
+				useAuthSecret(t)
+			}
+			useTestMailer(t)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, tt.req)
+			assert.Equal(t, tt.status, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestCreateInvite_IgnoresHost(t *testing.T) {
+	router := setupAdminRouter(t)
+	useAuthSecret(t)
+	sent := useTestMailer(t)
+
+	req := newAdminRequest(http.MethodPost, "/invites", `{"email":"carol@example.com"}`)
+	req.Host = "evil.example"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	require.Len(t, *sent, 1)
+	assert.Contains(t, (*sent)[0].Body, testPublicURL+"/invites/")
+	assert.NotContains(t, (*sent)[0].Body, "evil.example")
+
+	server.Config.PublicURL = ""
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/invites", `{"email":"carol@example.com"}`))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Len(t, *sent, 1, "no link is mailed without PUBLIC_URL")
+}
+
+func TestGetInvite(t *testing.T) {
+	router := setupAdminRouter(t)
+	useAuthSecret(t)
+	useTestMailer(t)
+	invite := createTestInvite(t, router, "carol@example.com")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/invites/"+invite.Token, nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var got Invite
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, Invite{Email: invite.Email, ExpiresAt: invite.ExpiresAt}, got, "the token is not repeated")
+}
+
+func TestGetInvite_NotFoundOrGone(t *testing.T) {
+	router := setupAdminRouter(t)
+	useAuthSecret(t)
+	useTestMailer(t)
+	invite := createTestInvite(t, router, "carol@example.com")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/invites/not-a-token", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	freezeClock(invite.ExpiresAt)
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/invites/"+invite.Token, nil),
+		newAdminRequest(http.MethodPost, "/invites/"+invite.Token+"/accept", `{"name":"Carol"}`),
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusGone, w.Code, "%s %s", req.Method, req.URL)
+	}
+}
+
+func TestAcceptInvite(t *testing.T) {
+	router := setupAdminRouter(t)
+	usePasswords(t)
+	invite := createTestInvite(t, router, "carol@example.com")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/invites/"+invite.Token+"/accept", `{"name":"Carol","password":"correct horse"}`))
+
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var user User
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+	assert.Equal(t, "Carol", user.Name)
+	assert.Equal(t, "carol@example.com", user.Email)
+	assert.Equal(t, "/users/3", w.Header().Get("Location"))
+	hash, err := server.Store.PasswordHash(user.ID)
+	require.NoError(t, err)
+	assert.NoError(t, bcrypt.CompareHashAndPassword(hash, []byte("correct horse")))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/invites/"+invite.Token+"/accept", `{"name":"Carol again"}`))
+	assert.Equal(t, http.StatusConflict, w.Code, "an invite creates one user")
+}
+
+func TestAcceptInvite_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"no name", `{}`},
+		{"short password", `{"name":"Carol","password":"short"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupAdminRouter(t)
+			usePasswords(t)
+			invite := createTestInvite(t, router, "carol@example.com")
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/invites/"+invite.Token+"/accept", tt.body))
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Len(t, server.Store.ListUsers(), 2)
+		})
+	}
+}
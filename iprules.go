@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+)
+
+// IPRules are the client address ranges allowed and denied the /admin
+// routes, as CIDR ranges or single addresses.
+type IPRules struct {
+	// Allow, unless empty, admits only clients in these ranges.
+	Allow []string `json:"allow"`
+	// Deny refuses clients in these ranges, even allowed ones.
+	Deny []string `json:"deny"`
+}
+
+// ipRuleSet is IPRules, parsed.
+type ipRuleSet struct {
+	allow, deny []netip.Prefix
+}
+
+// parseIPRange parses a CIDR range, or an address as the range of just it.
+func parseIPRange(s string) (netip.Prefix, error) {
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), nil
+	}
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return p.Masked(), nil
+}
+
+// parseIPRules parses rules, returning the violations of its malformed
+// ranges.
+func parseIPRules(rules IPRules) (ipRuleSet, []string) {
+	var set ipRuleSet
+	var violations []string
+	for _, list := range []struct {
+		name   string
+		ranges []string
+		dst    *[]netip.Prefix
+	}{
+		{"allow", rules.Allow, &set.allow},
+		{"deny", rules.Deny, &set.deny},
+	} {
+		for i, s := range list.ranges {
+			p, err := parseIPRange(strings.TrimSpace(s))
+			if err != nil {
+				violations = append(violations, fmt.Sprintf("request body /%s/%d: must be a CIDR range or an IP address", list.name, i))
+				continue
+			}
+			*list.dst = append(*list.dst, p)
+		}
+	}
+	return set, violations
+}
+
+// rules returns set as IPRules, in canonical form.
+func (set ipRuleSet) rules() IPRules {
+	rules := IPRules{Allow: []string{}, Deny: []string{}}
+	for _, p := range set.allow {
+		rules.Allow = append(rules.Allow, p.String())
+	}
+	for _, p := range set.deny {
+		rules.Deny = append(rules.Deny, p.String())
+	}
+	return rules
+}
+
+// admits reports whether set lets ip through. Addresses that do not parse
+// are only admitted while no rule is set.
+func (set ipRuleSet) admits(ip string) bool {
+	if len(set.allow) == 0 && len(set.deny) == 0 {
+		return true
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range set.deny {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	if len(set.allow) == 0 {
+		return true
+	}
+	for _, p := range set.allow {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// adminIPRules are the rules in effect, set from ADMIN_IP_ALLOW and
+// ADMIN_IP_DENY at startup and by PUT /admin/ip-rules after.
+type adminIPRules struct {
+	sync.RWMutex
+	set ipRuleSet
+}
+
+func (srv *Server) currentAdminIPRules() ipRuleSet {
+	srv.adminIPs.RLock()
+	defer srv.adminIPs.RUnlock()
+	return srv.adminIPs.set
+}
+
+func (srv *Server) setAdminIPRules(set ipRuleSet) {
+	srv.adminIPs.Lock()
+	srv.adminIPs.set = set
+	srv.adminIPs.Unlock()
+}
+
+// filterAdminIPs answers 403 to requests for /admin and below from
+// clients the admin IP rules do not admit. It runs before routing, so
+// unknown admin paths are refused too.
+func (srv *Server) filterAdminIPs(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if (r.URL.Path == "/admin" || strings.HasPrefix(r.URL.Path, "/admin/")) && !srv.currentAdminIPRules().admits(clientIP(r)) {
+			respondError(w, r, http.StatusForbidden, "forbidden")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (srv *Server) getIPRules(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, srv.currentAdminIPRules().rules())
+}
+
+// putIPRules replaces the rules. They last until the server restarts.
+func (srv *Server) putIPRules(w http.ResponseWriter, r *http.Request) {
+	var rules IPRules
+	if err := decodeJSON(r, &rules); err != nil {
+		respondDecodeError(w, r, err)
+		return
+	}
+	set, violations := parseIPRules(rules)
+	if len(violations) > 0 {
+		respondProblem(w, r, http.StatusBadRequest, "invalid request", Problem{Violations: violations})
+		return
+	}
+	srv.setAdminIPRules(set)
+	srv.logAt("info", "admin ip rules: allow %v, deny %v", set.rules().Allow, set.rules().Deny)
+	respondJSON(w, http.StatusOK, set.rules())
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useAdminIPRules installs rules on the test's server.
+func useAdminIPRules(t *testing.T, rules IPRules) {
+	t.Helper()
+	set, violations := parseIPRules(rules)
+	require.Empty(t, violations)
+	server.setAdminIPRules(set)
+}
+
+// ========== IP Rule Tests ==========
+
+func TestParseIPRules(t *testing.T) {
+	set, violations := parseIPRules(IPRules{
+		Allow: []string{"10.1.2.3/8", "192.0.2.1", "2001:db8::/32", "::ffff:198.51.100.1"},
+		Deny:  []string{"nope", "10.0.0.0/99"},
+	})
+
+	assert.Equal(t, []string{
+		"request body /deny/0: must be a CIDR range or an IP address",
+		"request body /deny/1: must be a CIDR range or an IP address",
+	}, violations)
+	assert.Equal(t, IPRules{
+		Allow: []string{"10.0.0.0/8", "192.0.2.1/32", "2001:db8::/32", "198.51.100.1/32"},
+		Deny:  []string{},
+	}, set.rules(), "ranges are masked and mapped addresses unmapped")
+}
+
+func TestIPRuleSet_Admits(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules IPRules
+		ip    string
+		want  bool
+	}{
+		{"no rules", IPRules{}, "203.0.113.9", true},
+		{"no rules, unparsable", IPRules{}, "pipe", true},
+		{"allowed", IPRules{Allow: []string{"10.0.0.0/8"}}, "10.9.8.7", true},
+		{"not allowed", IPRules{Allow: []string{"10.0.0.0/8"}}, "11.0.0.1", false},
+		{"denied", IPRules{Deny: []string{"10.0.0.0/8"}}, "10.9.8.7", false},
+		{"not denied", IPRules{Deny: []string{"10.0.0.0/8"}}, "11.0.0.1", true},
+		{"deny wins", IPRules{Allow: []string{"10.0.0.0/8"}, Deny: []string{"10.0.0.66"}}, "10.0.0.66", false},
+		{"mapped", IPRules{Allow: []string{"10.0.0.0/8"}}, "::ffff:10.0.0.1", true},
+		{"v6", IPRules{Allow: []string{"2001:db8::/32"}}, "2001:db8::1", true},
+		{"unparsable", IPRules{Allow: []string{"10.0.0.0/8"}}, "pipe", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, violations := parseIPRules(tt.rules)
+			require.Empty(t, violations)
+			assert.Equal(t, tt.want, set.admits(tt.ip))
+		})
+	}
+}
+
+// ========== Middleware Tests ==========
+
+func TestFilterAdminIPs(t *testing.T) {
+	router := setupAdminRouter(t)
+	useAdminIPRules(t, IPRules{Deny: []string{"192.0.2.1"}})
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/admin/flags", http.StatusForbidden},
+		{"/admin/nonexistent", http.StatusForbidden},
+		{"/admin", http.StatusForbidden},
+		{"/administrators", http.StatusNotFound},
+		{"/users", http.StatusOK},
+		{"/health", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, newAdminRequest(http.MethodGet, tt.path, ""))
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+
+	req := newAdminRequest(http.MethodGet, "/admin/flags", "")
+	req.RemoteAddr = "198.51.100.7:1234"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "other clients are admitted")
+}
+
+func TestFilterAdminIPs_OpsRouter(t *testing.T) {
+	setupRouter()
+	useAdminIPRules(t, IPRules{Allow: []string{"10.0.0.0/8"}})
+	router := server.newOpsRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodGet, "/admin/flags", ""))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+// ========== Handler Tests ==========
+
+func TestPutIPRules(t *testing.T) {
+	router := setupAdminRouter(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPut, "/admin/ip-rules", `{"allow":["192.0.2.0/24"],"deny":["192.0.2.1"]}`))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"allow":["192.0.2.0/24"],"deny":["192.0.2.1/32"]}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodGet, "/admin/ip-rules", ""))
+	assert.Equal(t, http.StatusForbidden, w.Code, "the rules apply at once, even to the caller")
+}
+
+func TestPutIPRules_Invalid(t *testing.T) {
+	router := setupAdminRouter(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPut, "/admin/ip-rules", `{"allow":["10.0.0.0/33"],"deny":[]}`))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "request body /allow/0: must be a CIDR range or an IP address")
+	assert.Equal(t, IPRules{Allow: []string{}, Deny: []string{}}, server.currentAdminIPRules().rules(), "the rules are unchanged")
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Job is a task the scheduler runs on a cron schedule, and that
+// POST /admin/jobs/{name}/run runs at once.
+type Job struct {
+	Name        string
+	Description string
+	// Schedule is a cron expression, as parseCron reads it.
+	Schedule string
+	// Run does the work and says briefly what it did.
+	Run func(ctx context.Context) (string, error)
+}
+
+// JobRun is the outcome of one run of a job.
+type JobRun struct {
+	// Trigger is "schedule" or "manual".
+	Trigger    string    `json:"trigger"`
+	StartedAt  time.Time `json:"startedAt"`
+	DurationMs float64   `json:"durationMs"`
+	// Status is "succeeded" or "failed".
+	Status string `json:"status"`
+	// Result says what a successful run did; Error why a run failed.
+	Result string `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// JobStatus is a job as GET /admin/jobs lists it.
+type JobStatus struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Schedule    string `json:"schedule"`
+	// NextRun is when the schedule next runs the job; unset on instances
+	// that are not the leader, which leave scheduled runs to it.
+	NextRun *time.Time `json:"nextRun,omitempty"`
+	Running bool       `json:"running"`
+	LastRun *JobRun    `json:"lastRun,omitempty"`
+}
+
+var errJobRunning = errors.New("job already running")
+
+// scheduledJob is a Job with its parsed schedule and its state.
+type scheduledJob struct {
+	Job
+	schedule cronSchedule
+	next     time.Time
+	running  bool
+	last     *JobRun
+}
+
+// scheduler runs jobs on their schedules. It keeps each job's last run so
+// GET /admin/jobs can show it, and never runs a job twice at once.
+type scheduler struct {
+	now func() time.Time
+	log logFunc
+
+	mu   sync.Mutex
+	jobs map[string]*scheduledJob
+}
+
+func newScheduler(now func() time.Time) *scheduler {
+	return &scheduler{now: now, jobs: map[string]*scheduledJob{}}
+}
+
+// add registers job. Its schedule must parse and its name be new.
+func (s *scheduler) add(job Job) error {
+	schedule, err := parseCron(job.Schedule)
+	if err != nil {
+		return fmt.Errorf("job %s: %w", job.Name, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, dup := s.jobs[job.Name]; dup {
+		return fmt.Errorf("job %s: registered twice", job.Name)
+	}
+	s.jobs[job.Name] = &scheduledJob{Job: job, schedule: schedule}
+	return nil
+}
+
+// list returns the jobs' status, by name.
+func (s *scheduler) list() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]JobStatus, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		status := JobStatus{Name: j.Name, Description: j.Description, Schedule: j.Schedule, Running: j.running, LastRun: j.last}
+		if !j.next.IsZero() {
+			next := j.next
+			status.NextRun = &next
+		}
+		out = append(out, status)
+	}
+	sort.Slice(out, func(i, k int) bool { return out[i].Name < out[k].Name })
+	return out
+}
+
+// run runs job name now, unless it is running already, and returns how it
+// went.
+func (s *scheduler) run(ctx context.Context, name, trigger string) (JobRun, error) {
+	s.mu.Lock()
+	j, ok := s.jobs[name]
+	switch {
+	case !ok:
+		s.mu.Unlock()
+		return JobRun{}, errNotFound
+	case j.running:
+		s.mu.Unlock()
+		return JobRun{}, errJobRunning
+	}
+	j.running = true
+	s.mu.Unlock()
+
+	run := JobRun{Trigger: trigger, StartedAt: s.now()}
+	result, err := j.Run(ctx)
+	run.DurationMs = float64(s.now().Sub(run.StartedAt).Microseconds()) / 1000
+	if err != nil {
+		run.Status, run.Error = "failed", err.Error()
+		s.log.at("warn", "job %s: %v", name, err)
+	} else {
+		run.Status, run.Result = "succeeded", result
+		s.log.at("info", "job %s: %s", name, result)
+	}
+
+	s.mu.Lock()
+	j.running, j.last = false, &run
+	s.mu.Unlock()
+	return run, nil
+}
+
+// schedule runs each job when its schedule says until ctx is done.
+func (s *scheduler) schedule(ctx context.Context) {
+	defer func() {
+		s.mu.Lock()
+		for _, j := range s.jobs {
+			j.next = time.Time{}
+		}
+		s.mu.Unlock()
+	}()
+	for {
+		now := s.now()
+		var due []string
+		var wake time.Time
+		s.mu.Lock()
+		for name, j := range s.jobs {
+			if j.next.IsZero() {
+				j.next = j.schedule.next(now)
+			}
+			if !j.next.After(now) {
+				due = append(due, name)
+				j.next = j.schedule.next(now)
+			}
+			if !j.next.IsZero() && (wake.IsZero() || j.next.Before(wake)) {
+				wake = j.next
+			}
+		}
+		s.mu.Unlock()
+
+		sort.Strings(due)
+		for _, name := range due {
+			if _, err := s.run(ctx, name, "schedule"); errors.Is(err, errJobRunning) {
+				s.log.at("warn", "job %s: still running; skipping its scheduled run", name)
+			}
+		}
+		if len(due) > 0 {
+			continue
+		}
+		if wake.IsZero() {
+			<-ctx.Done()
+			return
+		}
+		timer := time.NewTimer(wake.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// builtinJobs are the jobs every server schedules.
+func (srv *Server) builtinJobs() []Job {
+	return []Job{
+		{
+			Name:        "purge-expired",
+			Description: "Drop expired response override rules and lapsed client bans",
+			Schedule:    "*/5 * * * *",
+			Run: func(context.Context) (string, error) {
+				now := srv.Clock.Now()
+				rules, bans := srv.rules.purge(now), srv.bans.purge(now)
+				return fmt.Sprintf("purged %d expired rules and %d lapsed bans", rules, bans), nil
+			},
+		},
+		{
+			Name:        "refresh-stats",
+			Description: "Refresh the database's query planner statistics",
+			Schedule:    "@hourly",
+			Run: func(ctx context.Context) (string, error) {
+				s := srv.Store
+				if bs, ok := s.(*breakerStore); ok {
+					s = bs.next
+				}
+				pg, ok := s.(*pgStore)
+				if !ok {
+					return "nothing to refresh: the store keeps no statistics", nil
+				}
+				if _, err := pg.pool.Exec(ctx, "ANALYZE"); err != nil {
+					return "", err
+				}
+				return "analyzed the database", nil
+			},
+		},
+	}
+}
+
+// rotateAccessLogJob starts a new access log file every day, so each file
+// covers one day however little traffic there is.
+func rotateAccessLogJob(rf *rotatingFile) Job {
+	return Job{
+		Name:        "rotate-access-log",
+		Description: "Start a new ACCESS_LOG file",
+		Schedule:    "@daily",
+		Run: func(context.Context) (string, error) {
+			rotated, err := rf.rotateNow()
+			switch {
+			case err != nil:
+				return "", err
+			case rotated == "":
+				return "nothing to rotate: the log is empty", nil
+			}
+			return "rotated to " + rotated, nil
+		},
+	}
+}
+
+// listJobs lists the scheduled jobs with their last runs.
+func (srv *Server) listJobs(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, srv.jobs.list())
+}
+
+// runJob runs a job at once, on this instance whether it leads or not, and
+// answers with how it went.
+func (srv *Server) runJob(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	run, err := srv.jobs.run(r.Context(), name, "manual")
+	switch {
+	case errors.Is(err, errNotFound):
+		respondProblem(w, r, http.StatusNotFound, "not found", Problem{Detail: fmt.Sprintf("no job named %q", name)})
+		return
+	case errors.Is(err, errJobRunning):
+		respondError(w, r, http.StatusConflict, "job already running")
+		return
+	}
+	respondJSON(w, http.StatusOK, run)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== Scheduled Job Tests ==========
+
+func TestScheduler_Run(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	s := newScheduler(clock.Now)
+	require.NoError(t, s.add(Job{Name: "ok", Schedule: "@hourly", Run: func(context.Context) (string, error) {
+		clock.t = clock.t.Add(1500 * time.Microsecond)
+		return "done", nil
+	}}))
+	require.NoError(t, s.add(Job{Name: "broken", Schedule: "@daily", Run: func(context.Context) (string, error) {
+		return "", errors.New("disk full")
+	}}))
+
+	run, err := s.run(context.Background(), "ok", "manual")
+	require.NoError(t, err)
+	assert.Equal(t, JobRun{Trigger: "manual", StartedAt: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), DurationMs: 1.5, Status: "succeeded", Result: "done"}, run)
+
+	run, err = s.run(context.Background(), "broken", "schedule")
+	require.NoError(t, err)
+	assert.Equal(t, "failed", run.Status)
+	assert.Equal(t, "disk full", run.Error)
+
+	_, err = s.run(context.Background(), "missing", "manual")
+	assert.ErrorIs(t, err, errNotFound)
+
+	jobs := s.list()
+	require.Len(t, jobs, 2)
+	assert.Equal(t, "broken", jobs[0].Name, "sorted by name")
+	assert.Equal(t, "failed", jobs[0].LastRun.Status)
+	assert.Nil(t, jobs[1].NextRun, "not scheduling")
+}
+
+func TestScheduler_Add(t *testing.T) {
+	s := newScheduler(time.Now)
+	require.NoError(t, s.add(Job{Name: "a", Schedule: "@daily"}))
+	assert.Error(t, s.add(Job{Name: "a", Schedule: "@hourly"}), "duplicate")
+	assert.Error(t, s.add(Job{Name: "b", Schedule: "every day"}))
+}
+
+func TestScheduler_NoOverlap(t *testing.T) {
+	s := newScheduler(time.Now)
+	started, release := make(chan struct{}), make(chan struct{})
+	require.NoError(t, s.add(Job{Name: "slow", Schedule: "@daily", Run: func(context.Context) (string, error) {
+		close(started)
+		<-release
+		return "", nil
+	}}))
+
+	go s.run(context.Background(), "slow", "schedule")
+	<-started
+	assert.True(t, s.list()[0].Running)
+	_, err := s.run(context.Background(), "slow", "manual")
+	assert.ErrorIs(t, err, errJobRunning)
+	close(release)
+}
+
+func TestScheduler_Schedule(t *testing.T) {
+	s := newScheduler(time.Now)
+	runs := make(chan struct{}, 4)
+	require.NoError(t, s.add(Job{Name: "tick", Schedule: "@every 1s", Run: func(context.Context) (string, error) {
+		runs <- struct{}{}
+		return "ticked", nil
+	}}))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.schedule(ctx)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool { return s.list()[0].NextRun != nil }, time.Second, 10*time.Millisecond)
+	select {
+	case <-runs:
+	case <-time.After(3 * time.Second):
+		t.Fatal("the job never ran")
+	}
+	cancel()
+	<-done
+	job := s.list()[0]
+	assert.Equal(t, "schedule", job.LastRun.Trigger)
+	assert.Nil(t, job.NextRun, "nothing is scheduled once stopped")
+}
+
+func TestPurgeExpiredJob(t *testing.T) {
+	router := setupAdminRouter(t)
+	clock := freezeClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	_, violations := server.rules.add(Rule{Path: "/users", Script: "return nil", TTL: "1m"}, clock.Now())
+	require.Empty(t, violations)
+	_, violations = server.rules.add(Rule{Path: "/posts", Script: "return nil"}, clock.Now())
+	require.Empty(t, violations)
+	server.bans.until["198.51.100.1"] = clock.Now().Add(time.Minute)
+	server.bans.until["198.51.100.2"] = clock.Now().Add(time.Hour)
+	clock.t = clock.t.Add(2 * time.Minute)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/admin/jobs/purge-expired/run", ""))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var run JobRun
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &run))
+	assert.Equal(t, "purged 1 expired rules and 1 lapsed bans", run.Result)
+	assert.Len(t, server.rules.list(clock.Now()), 1)
+	assert.Len(t, server.bans.until, 1)
+}
+
+func TestRotateAccessLogJob(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	rf, err := openRotatingFile(path, 0, 0)
+	require.NoError(t, err)
+	defer rf.Close()
+	job := rotateAccessLogJob(rf)
+
+	result, err := job.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "nothing to rotate: the log is empty", result)
+
+	_, err = rf.Write([]byte("line\n"))
+	require.NoError(t, err)
+	result, err = job.Run(context.Background())
+	require.NoError(t, err)
+	matches, _ := filepath.Glob(path + ".*")
+	require.Len(t, matches, 1)
+	assert.Equal(t, "rotated to "+matches[0], result)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Zero(t, info.Size(), "a fresh file takes new lines")
+}
+
+func TestRunJob_Errors(t *testing.T) {
+	router := setupAdminRouter(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/admin/jobs/reindex/run", ""))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), `no job named \"reindex\"`)
+
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	require.NoError(t, server.jobs.add(Job{Name: "slow", Schedule: "@daily", Run: func(context.Context) (string, error) {
+		close(started)
+		<-release
+		return "", nil
+	}}))
+	go server.jobs.run(context.Background(), "slow", "schedule")
+	<-started
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/admin/jobs/slow/run", ""))
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultLatencyBudget is the latency budget of routes that do not declare
+// one.
+const defaultLatencyBudget = 250 * time.Millisecond
+
+// budgetCounters counts, by operation, the requests each route served and
+// how many of them overran its latency budget.
+type budgetCounters struct {
+	mu         sync.Mutex
+	requests   map[string]int64
+	violations map[string]int64
+}
+
+func newBudgetCounters() *budgetCounters {
+	return &budgetCounters{requests: map[string]int64{}, violations: map[string]int64{}}
+}
+
+func (c *budgetCounters) record(operationID string, over bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests[operationID]++
+	if over {
+		c.violations[operationID]++
+	}
+}
+
+// BudgetCount is a route's row in a budgetCounters snapshot.
+type BudgetCount struct {
+	OperationID string
+	Requests    int64
+	Violations  int64
+}
+
+// snapshot returns the counts sorted by operation.
+func (c *budgetCounters) snapshot() []BudgetCount {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := make([]BudgetCount, 0, len(c.requests))
+	for id, n := range c.requests {
+		counts = append(counts, BudgetCount{OperationID: id, Requests: n, Violations: c.violations[id]})
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].OperationID < counts[j].OperationID })
+	return counts
+}
+
+// budget returns d's latency budget.
+func (d RouteDef) budget() time.Duration {
+	if d.LatencyBudget > 0 {
+		return d.LatencyBudget
+	}
+	return defaultLatencyBudget
+}
+
+// latencyBudget times d's handler, route middlewares included, and records
+// in srv.budgetStats whether it overran d's budget. It marks the request's
+// phaseTimer as routed, so Server-Timing reports the handler's time against
+// the budget, and hands the timer to the handler's writes.
+func (srv *Server) latencyBudget(d RouteDef) func(http.Handler) http.Handler {
+	budget := d.budget()
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			if t := phaseTimerFrom(r.Context()); t != nil {
+				t.routed(budget)
+				w = timedWriter{ResponseWriter: w, t: t}
+			}
+			next.ServeHTTP(w, r)
+			srv.budgetStats.record(d.OperationID, time.Since(start) > budget)
+		})
+	}
+}
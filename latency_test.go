@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupBudgetRouter mounts a route that sleeps for the duration in its
+// ?sleep query parameter, with a 20ms latency budget.
+func setupBudgetRouter(t *testing.T) http.Handler {
+	t.Helper()
+	setupRouter()
+
+	r := chi.NewRouter()
+	r.Use(withServerTiming)
+	server.mountRoutes(r, []RouteDef{{
+		Method: http.MethodGet, Pattern: "/slow", OperationID: "getSlow",
+		Handler: func(w http.ResponseWriter, r *http.Request) {
+			d, _ := time.ParseDuration(r.URL.Query().Get("sleep"))
+			time.Sleep(d)
+			respondJSON(w, http.StatusOK, map[string]string{})
+		},
+		LatencyBudget: 20 * time.Millisecond,
+	}})
+	return r
+}
+
+// ========== Latency Budget Tests ==========
+
+func TestLatencyBudget_CountsViolations(t *testing.T) {
+	router := setupBudgetRouter(t)
+
+	for _, sleep := range []string{"0s", "0s", "40ms"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow?sleep="+sleep, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	assert.Equal(t, []BudgetCount{{OperationID: "getSlow", Requests: 3, Violations: 1}}, server.budgetStats.snapshot())
+}
+
+func TestLatencyBudget_ServerTiming(t *testing.T) {
+	router := setupBudgetRouter(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow?sleep=5ms", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	header := w.Header().Get("Server-Timing")
+	m := regexp.MustCompile(`, handler;dur=(\d+\.\d{3});desc="budget 20ms", `).FindStringSubmatch(header)
+	require.NotNil(t, m, header)
+	dur, err := time.ParseDuration(m[1] + "ms")
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, dur, 5*time.Millisecond)
+}
+
+func TestLatencyBudget_RouteDefaults(t *testing.T) {
+	assert.Equal(t, defaultLatencyBudget, RouteDef{}.budget())
+	for _, d := range append(server.apiRouteDefs(), server.opsRouteDefs()...) {
+		assert.Positive(t, d.budget(), d.OperationID)
+	}
+}
+
+func TestPrometheusHandler_LatencyBudgets(t *testing.T) {
+	router := setupRouter()
+	server.budgetStats.record("getUser", true)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	body := w.Body.String()
+	assert.Contains(t, body, `route_latency_budget_seconds{operation="getPhotoThumbnail"} 0.5`)
+	assert.Contains(t, body, `route_requests_total{operation="listUsers"} 1`)
+	assert.Contains(t, body, `route_latency_budget_violations_total{operation="listUsers"} 0`)
+	assert.Contains(t, body, `route_latency_budget_violations_total{operation="getUser"} 1`)
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+)
+
+// leaderLeaseName names the lease the instances compete for.
+const leaderLeaseName = "background-jobs"
+
+// defaultLeaderLease is how long a lease lasts when LEADER_LEASE is unset.
+const defaultLeaderLease = 15 * time.Second
+
+// leaderResignTimeout bounds giving the lease up on shutdown.
+const leaderResignTimeout = 5 * time.Second
+
+// LeaderStatus is what an instance knows of the leader election.
+type LeaderStatus struct {
+	// Instance names this instance: INSTANCE_ID, or its host name and
+	// process ID.
+	Instance string `json:"instance"`
+	// Backend is where the lease is kept: "redis" or "postgres" when
+	// instances share one, otherwise "memory", where this instance only
+	// competes with itself.
+	Backend string `json:"backend"`
+	// Leader is whether this instance holds the lease and so runs the
+	// background jobs.
+	Leader bool `json:"leader"`
+	// Holder is the instance the lease was last found with, if any.
+	Holder string `json:"holder,omitempty"`
+	// Since is when Leader last changed, and LastChecked when the lease was
+	// last asked for. Both are unset until the election first runs.
+	Since       *time.Time `json:"since,omitempty"`
+	LastChecked *time.Time `json:"lastChecked,omitempty"`
+	// Error is why the last attempt to take or renew the lease failed.
+	Error string `json:"error,omitempty"`
+}
+
+// leaseBackend grants a lease to one instance at a time.
+type leaseBackend interface {
+	// name is reported as LeaderStatus.Backend.
+	name() string
+	// acquire takes the lease for instance, or renews it if instance holds
+	// it already, for ttl, and returns the instance holding it afterwards;
+	// empty if none does.
+	acquire(ctx context.Context, instance string, ttl time.Duration) (holder string, err error)
+	// release gives the lease up if instance holds it.
+	release(ctx context.Context, instance string) error
+}
+
+// leaderElection keeps one of the instances sharing a backend leader, so
+// that work which must not run twice, such as webhook delivery, runs on
+// that instance only. Every instance asks for the lease a few times per
+// lease period; the leader's asking renews it, and if the leader stops
+// asking the lease lapses and another instance takes it.
+type leaderElection struct {
+	instance string
+	lease    time.Duration
+	now      func() time.Time
+	log      logFunc
+
+	mu      sync.Mutex
+	backend leaseBackend
+	status  LeaderStatus
+	// flip is closed, and replaced, whenever status.Leader changes.
+	flip chan struct{}
+}
+
+func newLeaderElection(cfg LeaderConfig, backend leaseBackend, now func() time.Time) *leaderElection {
+	instance := cfg.Instance
+	if instance == "" {
+		host, _ := os.Hostname()
+		instance = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	lease := cfg.Lease
+	if lease <= 0 {
+		lease = defaultLeaderLease
+	}
+	return &leaderElection{
+		instance: instance,
+		lease:    lease,
+		now:      now,
+		backend:  backend,
+		status:   LeaderStatus{Instance: instance, Backend: backend.name()},
+		flip:     make(chan struct{}),
+	}
+}
+
+// use keeps the lease in backend from the next campaign on.
+func (e *leaderElection) use(backend leaseBackend) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.backend = backend
+	e.status.Backend = backend.name()
+}
+
+// current returns a copy of the election's status.
+func (e *leaderElection) current() LeaderStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.status
+}
+
+// leading reports whether this instance leads, and a channel closed when
+// that changes.
+func (e *leaderElection) leading() (bool, <-chan struct{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.status.Leader, e.flip
+}
+
+// setLeader records whether this instance leads as of now. e.mu is held.
+func (e *leaderElection) setLeader(leader bool, now time.Time) {
+	if e.status.Since != nil && e.status.Leader == leader {
+		return
+	}
+	changed := e.status.Leader != leader
+	e.status.Leader = leader
+	e.status.Since = &now
+	if !changed {
+		return
+	}
+	close(e.flip)
+	e.flip = make(chan struct{})
+	if leader {
+		e.log.at("info", "leader election: %s is the leader", e.instance)
+	} else {
+		e.log.at("info", "leader election: %s is no longer the leader", e.instance)
+	}
+}
+
+// campaign asks for the lease once.
+func (e *leaderElection) campaign(ctx context.Context) {
+	e.mu.Lock()
+	backend := e.backend
+	e.mu.Unlock()
+	holder, err := backend.acquire(ctx, e.instance, e.lease)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := e.now()
+	e.status.LastChecked = &now
+	if err != nil {
+		// Without an answer this instance cannot tell whether the lease is
+		// still its own, so it steps down rather than risk a second leader.
+		if e.status.Error == "" {
+			e.log.at("warn", "leader election: %v", err)
+		}
+		e.status.Error = err.Error()
+		e.status.Holder = ""
+		e.setLeader(false, now)
+		return
+	}
+	e.status.Error = ""
+	e.status.Holder = holder
+	e.setLeader(holder == e.instance, now)
+}
+
+// run campaigns for the lease until ctx is done, then gives it up.
+func (e *leaderElection) run(ctx context.Context) {
+	ticker := time.NewTicker(e.lease / 3)
+	defer ticker.Stop()
+	for {
+		e.campaign(ctx)
+		select {
+		case <-ctx.Done():
+			e.resign()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// resign steps down and releases the lease, so another instance need not
+// wait for it to lapse.
+func (e *leaderElection) resign() {
+	e.mu.Lock()
+	backend := e.backend
+	wasLeader := e.status.Leader
+	e.setLeader(false, e.now())
+	e.mu.Unlock()
+	if !wasLeader {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), leaderResignTimeout)
+	defer cancel()
+	if err := backend.release(ctx, e.instance); err != nil {
+		e.log.at("warn", "leader election: release: %v", err)
+	}
+}
+
+// whileLeader runs fn whenever this instance leads, cancelling its context
+// when it stops leading, until ctx is done.
+func (e *leaderElection) whileLeader(ctx context.Context, fn func(ctx context.Context)) {
+	for ctx.Err() == nil {
+		leader, flip := e.leading()
+		if !leader {
+			select {
+			case <-ctx.Done():
+			case <-flip:
+			}
+			continue
+		}
+		term, cancel := context.WithCancel(ctx)
+		go func() {
+			select {
+			case <-flip:
+			case <-term.Done():
+			}
+			cancel()
+		}()
+		fn(term)
+		cancel()
+		// fn may return before the term ends; wait for it all the same.
+		select {
+		case <-ctx.Done():
+		case <-flip:
+		}
+	}
+}
+
+// sharedLease picks where the leader lease is kept: Redis when REDIS_URL is
+// set, else the Postgres store, else in process.
+func (srv *Server) sharedLease() leaseBackend {
+	if srv.Redis != nil {
+		return redisLease{client: srv.Redis, key: redisKeyPrefix + "leader:" + leaderLeaseName}
+	}
+	s := srv.Store
+	if bs, ok := s.(*breakerStore); ok {
+		s = bs.next
+	}
+	if pg, ok := s.(*pgStore); ok {
+		return pgLease{pool: pg.pool}
+	}
+	return newMemoryLease(srv.Clock.Now)
+}
+
+// getLeader reports this instance's view of the leader election.
+func (srv *Server) getLeader(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, srv.leader.current())
+}
+
+// memoryLease keeps the lease in process, where only the instances of one
+// process compete for it.
+type memoryLease struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	holder  string
+	expires time.Time
+}
+
+func newMemoryLease(now func() time.Time) *memoryLease {
+	return &memoryLease{now: now}
+}
+
+func (l *memoryLease) name() string { return "memory" }
+
+func (l *memoryLease) acquire(_ context.Context, instance string, ttl time.Duration) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if l.holder == "" || l.holder == instance || !now.Before(l.expires) {
+		l.holder, l.expires = instance, now.Add(ttl)
+	}
+	return l.holder, nil
+}
+
+func (l *memoryLease) release(_ context.Context, instance string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.holder == instance {
+		l.holder = ""
+	}
+	return nil
+}
+
+// redisLease keeps the lease in a Redis key holding the leader's name,
+// expiring when the lease does.
+type redisLease struct {
+	client *redis.Client
+	key    string
+}
+
+// renewLease and releaseLease extend and delete the lease key, but only
+// while it still names the instance doing so.
+var (
+	renewLease   = redis.NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) end return 0`)
+	releaseLease = redis.NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) end return 0`)
+)
+
+func (l redisLease) name() string { return "redis" }
+
+func (l redisLease) acquire(ctx context.Context, instance string, ttl time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+	if ok, err := l.client.SetNX(ctx, l.key, instance, ttl).Result(); err != nil || ok {
+		return instance, err
+	}
+	renewed, err := renewLease.Run(ctx, l.client, []string{l.key}, instance, ttl.Milliseconds()).Int()
+	if err != nil {
+		return "", err
+	}
+	if renewed == 1 {
+		return instance, nil
+	}
+	holder, err := l.client.Get(ctx, l.key).Result()
+	if errors.Is(err, redis.Nil) {
+		// It lapsed just now; the next campaign may take it.
+		return "", nil
+	}
+	return holder, err
+}
+
+func (l redisLease) release(ctx context.Context, instance string) error {
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+	return releaseLease.Run(ctx, l.client, []string{l.key}, instance).Err()
+}
+
+// pgLease keeps the lease in a leader_leases row. Expiry is judged by the
+// database's clock, so the instances' clocks need not agree.
+type pgLease struct {
+	pool *pgxpool.Pool
+}
+
+func (l pgLease) name() string { return "postgres" }
+
+func (l pgLease) acquire(ctx context.Context, instance string, ttl time.Duration) (string, error) {
+	_, err := l.pool.Exec(ctx, `
+		INSERT INTO leader_leases (name, holder, expires_at)
+		VALUES ($1, $2, now() + make_interval(secs => $3))
+		ON CONFLICT (name) DO UPDATE SET holder = EXCLUDED.holder, expires_at = EXCLUDED.expires_at
+		WHERE leader_leases.holder = EXCLUDED.holder OR leader_leases.expires_at <= now()`,
+		leaderLeaseName, instance, ttl.Seconds())
+	if err != nil {
+		return "", err
+	}
+	var holder string
+	err = l.pool.QueryRow(ctx, `SELECT holder FROM leader_leases WHERE name = $1 AND expires_at > now()`, leaderLeaseName).Scan(&holder)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return holder, err
+}
+
+func (l pgLease) release(ctx context.Context, instance string) error {
+	_, err := l.pool.Exec(ctx, `DELETE FROM leader_leases WHERE name = $1 AND holder = $2`, leaderLeaseName, instance)
+	return err
+}
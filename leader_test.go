@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== Leader Election Tests ==========
+
+// failingLease is a lease backend that cannot be reached.
+type failingLease struct{}
+
+func (failingLease) name() string { return "redis" }
+
+func (failingLease) acquire(context.Context, string, time.Duration) (string, error) {
+	return "", errors.New("connection refused")
+}
+
+func (failingLease) release(context.Context, string) error { return nil }
+
+// electors returns one election per instance, all sharing backend.
+func electors(backend leaseBackend, clock *fakeClock, instances ...string) []*leaderElection {
+	out := make([]*leaderElection, len(instances))
+	for i, instance := range instances {
+		out[i] = newLeaderElection(LeaderConfig{Instance: instance, Lease: 15 * time.Second}, backend, clock.Now)
+	}
+	return out
+}
+
+// assertLeaseBackend runs an election between two instances sharing
+// backend, with lapse passing a lease period.
+func assertLeaseBackend(t *testing.T, backend leaseBackend, clock *fakeClock, lapse func()) {
+	t.Helper()
+	ctx := context.Background()
+	e := electors(backend, clock, "api-0", "api-1")
+	a, b := e[0], e[1]
+
+	a.campaign(ctx)
+	b.campaign(ctx)
+	assert.True(t, a.current().Leader)
+	assert.False(t, b.current().Leader, "one leader at a time")
+	assert.Equal(t, "api-0", b.current().Holder)
+
+	a.campaign(ctx)
+	b.campaign(ctx)
+	assert.True(t, a.current().Leader, "the leader renews its lease")
+
+	lapse()
+	b.campaign(ctx)
+	a.campaign(ctx)
+	assert.True(t, b.current().Leader, "a lapsed lease passes on")
+	assert.False(t, a.current().Leader)
+
+	b.resign()
+	a.campaign(ctx)
+	assert.True(t, a.current().Leader, "a resigned lease is free at once")
+	assert.False(t, b.current().Leader)
+}
+
+func TestLeaderElection_Memory(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	assertLeaseBackend(t, newMemoryLease(clock.Now), clock, func() { clock.t = clock.t.Add(15 * time.Second) })
+}
+
+func TestLeaderElection_Redis(t *testing.T) {
+	setupRouter()
+	mr := useRedis(t)
+	clock := &fakeClock{t: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	backend := server.sharedLease()
+	require.Equal(t, "redis", backend.name())
+	assertLeaseBackend(t, backend, clock, func() { mr.FastForward(15 * time.Second) })
+}
+
+func TestLeaderElection_Postgres(t *testing.T) {
+	s := newTestPostgresStore(t)
+	srv := newServer(Config{}, newBreakerStore(s, newBreaker(5, time.Minute)))
+	backend := srv.sharedLease()
+	require.Equal(t, "postgres", backend.name())
+	clock := &fakeClock{t: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	_, err := s.pool.Exec(context.Background(), `DELETE FROM leader_leases`)
+	require.NoError(t, err)
+	assertLeaseBackend(t, backend, clock, func() {
+		_, err := s.pool.Exec(context.Background(), `UPDATE leader_leases SET expires_at = now()`)
+		require.NoError(t, err)
+	})
+}
+
+func TestLeaderElection_BackendFailureStepsDown(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	e := electors(newMemoryLease(clock.Now), clock, "api-0")[0]
+	e.campaign(context.Background())
+	require.True(t, e.current().Leader)
+
+	e.use(failingLease{})
+	e.campaign(context.Background())
+	status := e.current()
+	assert.False(t, status.Leader)
+	assert.Equal(t, "connection refused", status.Error)
+	assert.Empty(t, status.Holder)
+	assert.Equal(t, "redis", status.Backend)
+}
+
+func TestLeaderElection_WhileLeader(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	lease := newMemoryLease(clock.Now)
+	e := electors(lease, clock, "api-0")[0]
+	terms := make(chan context.Context, 4)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		e.whileLeader(ctx, func(term context.Context) {
+			terms <- term
+			<-term.Done()
+		})
+		close(stopped)
+	}()
+
+	select {
+	case <-terms:
+		t.Fatal("a follower runs nothing")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	e.campaign(context.Background())
+	first := <-terms
+	e.resign()
+	select {
+	case <-first.Done():
+	case <-time.After(time.Second):
+		t.Fatal("losing the lease ends the term")
+	}
+
+	e.campaign(context.Background())
+	second := <-terms
+	cancel()
+	<-second.Done()
+	<-stopped
+}
+
+func TestGetLeader(t *testing.T) {
+	router := setupAdminRouter(t)
+	clock := freezeClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	server.leader = newLeaderElection(LeaderConfig{Instance: "api-0"}, failingLease{}, clock.Now)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodGet, "/admin/leader", ""))
+	require.Equal(t, http.StatusOK, w.Code)
+	var status LeaderStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, LeaderStatus{Instance: "api-0", Backend: "redis"}, status, "not yet elected")
+
+	server.leader.campaign(context.Background())
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodGet, "/admin/leader", ""))
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.False(t, status.Leader)
+	assert.Equal(t, "connection refused", status.Error)
+	assert.Equal(t, clock.Now(), *status.LastChecked)
+}
+
+func TestNewLeaderElection_DefaultInstance(t *testing.T) {
+	e := newLeaderElection(LeaderConfig{}, newMemoryLease(time.Now), time.Now)
+	assert.NotEmpty(t, e.instance)
+	assert.Equal(t, defaultLeaderLease, e.lease)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// Subsystem is a part of the server with a life of its own: a store
+// connection, a background worker, a listener. Any of its hooks may be nil.
+type Subsystem struct {
+	Name string
+	// DependsOn names the subsystems that must have started before this
+	// one starts, and that stop only after it has stopped.
+	DependsOn []string
+	// Start brings the subsystem up and returns once it is ready. ctx
+	// bounds startup only; work that outlives Start needs its own context,
+	// which Stop ends.
+	Start func(ctx context.Context) error
+	// Stop releases what Start acquired, giving up when ctx is done.
+	Stop func(ctx context.Context) error
+}
+
+// Lifecycle starts registered subsystems in dependency order and stops
+// them in the reverse of the order they started in.
+type Lifecycle struct {
+	subsystems []Subsystem
+	started    []Subsystem
+	log        logFunc
+}
+
+// Register adds subsystems to lc. Register them all before Start.
+func (lc *Lifecycle) Register(subsystems ...Subsystem) {
+	lc.subsystems = append(lc.subsystems, subsystems...)
+}
+
+// order sorts the subsystems so each follows everything it depends on,
+// otherwise keeping the order they were registered in.
+func (lc *Lifecycle) order() ([]Subsystem, error) {
+	byName := make(map[string]Subsystem, len(lc.subsystems))
+	for _, s := range lc.subsystems {
+		if _, dup := byName[s.Name]; dup {
+			return nil, fmt.Errorf("lifecycle: subsystem %q registered twice", s.Name)
+		}
+		byName[s.Name] = s
+	}
+	for _, s := range lc.subsystems {
+		for _, dep := range s.DependsOn {
+			if _, ok := byName[dep]; !ok {
+				return nil, fmt.Errorf("lifecycle: %s depends on unknown subsystem %q", s.Name, dep)
+			}
+		}
+	}
+
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int, len(lc.subsystems))
+	ordered := make([]Subsystem, 0, len(lc.subsystems))
+	var visit func(s Subsystem, path []string) error
+	visit = func(s Subsystem, path []string) error {
+		switch state[s.Name] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("lifecycle: dependency cycle %v", append(path, s.Name))
+		}
+		state[s.Name] = visiting
+		for _, dep := range s.DependsOn {
+			if err := visit(byName[dep], append(path, s.Name)); err != nil {
+				return err
+			}
+		}
+		state[s.Name] = done
+		ordered = append(ordered, s)
+		return nil
+	}
+	for _, s := range lc.subsystems {
+		if err := visit(s, nil); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// Start starts every subsystem in dependency order. If one fails, those
+// already started are stopped again and its error is returned.
+func (lc *Lifecycle) Start(ctx context.Context) error {
+	ordered, err := lc.order()
+	if err != nil {
+		return err
+	}
+	for _, s := range ordered {
+		if s.Start != nil {
+			if err := s.Start(ctx); err != nil {
+				err = fmt.Errorf("start %s: %w", s.Name, err)
+				return errors.Join(err, lc.Stop(ctx))
+			}
+		}
+		lc.log.at("debug", "lifecycle: started %s", s.Name)
+		lc.started = append(lc.started, s)
+	}
+	return nil
+}
+
+// Stop stops the started subsystems, last started first. A subsystem that
+// fails to stop does not keep the others running; all their errors are
+// returned together.
+func (lc *Lifecycle) Stop(ctx context.Context) error {
+	var errs []error
+	for i := len(lc.started) - 1; i >= 0; i-- {
+		s := lc.started[i]
+		if s.Stop != nil {
+			if err := s.Stop(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("stop %s: %w", s.Name, err))
+				continue
+			}
+		}
+		lc.log.at("debug", "lifecycle: stopped %s", s.Name)
+	}
+	lc.started = nil
+	return errors.Join(errs...)
+}
+
+// background is a subsystem that runs fn in its own goroutine from Start
+// until Stop cancels fn's context and waits for it to return.
+func background(name string, fn func(ctx context.Context), dependsOn ...string) Subsystem {
+	var (
+		cancel context.CancelFunc
+		wg     sync.WaitGroup
+	)
+	return Subsystem{
+		Name:      name,
+		DependsOn: dependsOn,
+		Start: func(context.Context) error {
+			var ctx context.Context
+			ctx, cancel = context.WithCancel(context.Background())
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				fn(ctx)
+			}()
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			cancel()
+			stopped := make(chan struct{})
+			go func() {
+				wg.Wait()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	}
+}
+
+// listener is a subsystem that serves hs on its address. Start returns
+// once the address is bound; a later failure to serve is sent on failed.
+// Stop shuts hs down gracefully, first ending the contexts of long-lived
+// requests such as event streams so that they do not hold it open.
+func (srv *Server) listener(name string, hs *http.Server, failed chan<- error, dependsOn ...string) Subsystem {
+	base, cancel := context.WithCancel(context.Background())
+	hs.BaseContext = func(net.Listener) context.Context { return base }
+	hs.RegisterOnShutdown(cancel)
+	return Subsystem{
+		Name:      name,
+		DependsOn: dependsOn,
+		Start: func(context.Context) error {
+			ln, err := net.Listen("tcp", hs.Addr)
+			if err != nil {
+				return err
+			}
+			srv.logAt("info", "%s listening on %s", name, ln.Addr())
+			go func() {
+				if err := hs.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+					select {
+					case failed <- fmt.Errorf("%s: %w", name, err):
+					default:
+					}
+				}
+			}()
+			return nil
+		},
+		Stop: hs.Shutdown,
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== Lifecycle Tests ==========
+
+// recordingSubsystem appends "start name" and "stop name" to calls.
+func recordingSubsystem(calls *[]string, name string, dependsOn ...string) Subsystem {
+	return Subsystem{
+		Name:      name,
+		DependsOn: dependsOn,
+		Start: func(context.Context) error {
+			*calls = append(*calls, "start "+name)
+			return nil
+		},
+		Stop: func(context.Context) error {
+			*calls = append(*calls, "stop "+name)
+			return nil
+		},
+	}
+}
+
+func TestLifecycle_DependencyOrder(t *testing.T) {
+	var calls []string
+	lc := &Lifecycle{}
+	lc.Register(
+		recordingSubsystem(&calls, "http", "store", "webhooks"),
+		recordingSubsystem(&calls, "webhooks", "store"),
+		recordingSubsystem(&calls, "store", "config"),
+		recordingSubsystem(&calls, "config"),
+		recordingSubsystem(&calls, "metrics"),
+	)
+
+	require.NoError(t, lc.Start(context.Background()))
+	require.NoError(t, lc.Stop(context.Background()))
+	assert.Equal(t, []string{
+		"start config", "start store", "start webhooks", "start http", "start metrics",
+		"stop metrics", "stop http", "stop webhooks", "stop store", "stop config",
+	}, calls)
+}
+
+func TestLifecycle_StartFailureStopsStarted(t *testing.T) {
+	var calls []string
+	lc := &Lifecycle{}
+	broken := recordingSubsystem(&calls, "store", "config")
+	broken.Start = func(context.Context) error { return errors.New("connection refused") }
+	lc.Register(
+		recordingSubsystem(&calls, "config"),
+		broken,
+		recordingSubsystem(&calls, "http", "store"),
+	)
+
+	err := lc.Start(context.Background())
+	assert.EqualError(t, err, "start store: connection refused")
+	assert.Equal(t, []string{"start config", "stop config"}, calls)
+	require.NoError(t, lc.Stop(context.Background()))
+	assert.Len(t, calls, 2, "nothing is stopped twice")
+}
+
+func TestLifecycle_StopErrors(t *testing.T) {
+	var calls []string
+	lc := &Lifecycle{}
+	stuck := recordingSubsystem(&calls, "webhooks", "store")
+	stuck.Stop = func(context.Context) error { return errors.New("still delivering") }
+	lc.Register(recordingSubsystem(&calls, "store"), stuck, Subsystem{Name: "hookless"})
+
+	require.NoError(t, lc.Start(context.Background()))
+	err := lc.Stop(context.Background())
+	assert.EqualError(t, err, "stop webhooks: still delivering")
+	assert.Equal(t, []string{"start store", "start webhooks", "stop store"}, calls, "the others still stop")
+}
+
+func TestLifecycle_InvalidGraph(t *testing.T) {
+	tests := []struct {
+		name       string
+		subsystems []Subsystem
+		err        string
+	}{
+		{"cycle", []Subsystem{
+			{Name: "a", DependsOn: []string{"b"}},
+			{Name: "b", DependsOn: []string{"c"}},
+			{Name: "c", DependsOn: []string{"a"}},
+		}, "lifecycle: dependency cycle [a b c a]"},
+		{"unknown", []Subsystem{{Name: "a", DependsOn: []string{"redis"}}}, `lifecycle: a depends on unknown subsystem "redis"`},
+		{"duplicate", []Subsystem{{Name: "a"}, {Name: "a"}}, `lifecycle: subsystem "a" registered twice`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lc := &Lifecycle{}
+			lc.Register(tt.subsystems...)
+			assert.EqualError(t, lc.Start(context.Background()), tt.err)
+		})
+	}
+}
+
+func TestBackground_StopWaits(t *testing.T) {
+	done := make(chan struct{})
+	s := background("worker", func(ctx context.Context) {
+		<-ctx.Done()
+		close(done)
+	})
+
+	require.NoError(t, s.Start(context.Background()))
+	require.NoError(t, s.Stop(context.Background()))
+	select {
+	case <-done:
+	default:
+		t.Fatal("Stop returned before the worker did")
+	}
+}
+
+func TestBackground_StopTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	s := background("worker", func(context.Context) { <-release })
+
+	require.NoError(t, s.Start(context.Background()))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, s.Stop(ctx), context.DeadlineExceeded)
+}
+
+func TestListener_ShutdownEndsStreams(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	streaming := make(chan struct{})
+	hs := &http.Server{Addr: addr, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		close(streaming)
+		<-r.Context().Done()
+	})}
+	failed := make(chan error, 1)
+	s := server.listener("api", hs, failed)
+	require.NoError(t, s.Start(context.Background()))
+
+	res, err := http.Get("http://" + addr + "/events")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	<-streaming
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, s.Stop(ctx), "the open stream does not hold up shutdown")
+	_, err = io.ReadAll(res.Body)
+	assert.NoError(t, err)
+	assert.Empty(t, failed)
+}
+
+func TestListener_AddressInUse(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	s := server.listener("api", &http.Server{Addr: ln.Addr().String()}, make(chan error, 1))
+	assert.Error(t, s.Start(context.Background()))
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// loadTarget is a request the load generator sends, labelled with the route
+// it exercises.
+type loadTarget struct {
+	route string
+	path  string
+}
+
+// loadTargets are sent in turn. They are all reads, so a run leaves the
+// data as it found it.
+var loadTargets = []loadTarget{
+	{"GET /users", "/users"},
+	{"GET /users/{id}", "/users/1"},
+	{"GET /users/{id}/posts", "/users/1/posts"},
+	{"GET /posts", "/posts"},
+	{"GET /posts/{id}", "/posts/1"},
+	{"GET /albums/{id}/photos", "/albums/1/photos"},
+	{"GET /todos", "/todos"},
+	{"GET /places", "/places?lat=52.52&lng=13.40&radius=5"},
+}
+
+// loadResult is the outcome of one request. status is zero when the
+// request failed before a response arrived.
+type loadResult struct {
+	route   string
+	status  int
+	latency time.Duration
+}
+
+// generateLoad sends loadTargets in turn at rps requests per second for
+// duration, without waiting for earlier responses, and returns every
+// outcome once the last response is in.
+func generateLoad(ctx context.Context, rps int, duration time.Duration, send func(loadTarget) int) []loadResult {
+	ctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+	ticker := time.NewTicker(time.Second / time.Duration(rps))
+	defer ticker.Stop()
+
+	var (
+		mu      sync.Mutex
+		results []loadResult
+		wg      sync.WaitGroup
+	)
+	for i := 0; ; i++ {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return results
+		case <-ticker.C:
+		}
+		target := loadTargets[i%len(loadTargets)]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			status := send(target)
+			r := loadResult{route: target.route, status: status, latency: time.Since(start)}
+			mu.Lock()
+			results = append(results, r)
+			mu.Unlock()
+		}()
+	}
+}
+
+// routeLatency summarizes the results for one route.
+type routeLatency struct {
+	Route    string
+	Requests int
+	// Errors counts 5xx responses and requests that got no response.
+	Errors             int
+	P50, P90, P99, Max time.Duration
+}
+
+// summarizeLoad groups results by route, sorted by route, followed by a
+// summary of all of them.
+func summarizeLoad(results []loadResult) []routeLatency {
+	byRoute := map[string][]loadResult{}
+	for _, r := range results {
+		byRoute[r.route] = append(byRoute[r.route], r)
+	}
+	routes := make([]string, 0, len(byRoute))
+	for route := range byRoute {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+
+	summary := make([]routeLatency, 0, len(routes)+1)
+	for _, route := range routes {
+		summary = append(summary, summarizeRoute(route, byRoute[route]))
+	}
+	return append(summary, summarizeRoute("all", results))
+}
+
+func summarizeRoute(route string, results []loadResult) routeLatency {
+	s := routeLatency{Route: route, Requests: len(results)}
+	latencies := make([]time.Duration, len(results))
+	for i, r := range results {
+		latencies[i] = r.latency
+		if r.status == 0 || r.status >= 500 {
+			s.Errors++
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	s.P50, s.P90, s.P99 = percentile(latencies, 50), percentile(latencies, 90), percentile(latencies, 99)
+	if len(latencies) > 0 {
+		s.Max = latencies[len(latencies)-1]
+	}
+	return s
+}
+
+// percentile returns the nearest-rank pth percentile of sorted.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+// writeLoadReport prints summary as a table, followed by the rate
+// achieved over elapsed.
+func writeLoadReport(out io.Writer, summary []routeLatency, elapsed time.Duration) {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "route\trequests\terrors\tp50\tp90\tp99\tmax\t")
+	for _, s := range summary {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\t%s\t\n", s.Route, s.Requests, s.Errors,
+			s.P50.Round(time.Microsecond), s.P90.Round(time.Microsecond), s.P99.Round(time.Microsecond), s.Max.Round(time.Microsecond))
+	}
+	tw.Flush()
+	total := summary[len(summary)-1]
+	fmt.Fprintf(out, "%.1f requests/s over %s\n", float64(total.Requests)/elapsed.Seconds(), elapsed.Round(time.Millisecond))
+}
+
+// discardWriter is a ResponseWriter that keeps only the status.
+type discardWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *discardWriter) Header() http.Header { return w.header }
+
+func (w *discardWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *discardWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return len(p), nil
+}
+
+// inProcessSender sends targets straight to handler, without a network.
+func inProcessSender(handler http.Handler) func(loadTarget) int {
+	return func(t loadTarget) int {
+		req, _ := http.NewRequest(http.MethodGet, t.path, nil)
+		req.RemoteAddr = "127.0.0.1:0"
+		w := &discardWriter{header: http.Header{}}
+		handler.ServeHTTP(w, req)
+		if w.status == 0 {
+			return http.StatusOK
+		}
+		return w.status
+	}
+}
+
+// remoteSender sends targets to the server at baseURL.
+func remoteSender(baseURL string) func(loadTarget) int {
+	client := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{MaxIdleConnsPerHost: 256},
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	return func(t loadTarget) int {
+		resp, err := client.Get(baseURL + t.path)
+		if err != nil {
+			return 0
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+}
+
+// runLoadtest implements "loadtest [-rps 100] [-duration 10s] [-url URL]":
+// it sends loadTargets at a constant rate, to the server at URL or, without
+// one, to the router in process, and reports latency percentiles by route.
+func runLoadtest(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("loadtest", flag.ContinueOnError)
+	rps := fs.Int("rps", 100, "requests per second")
+	duration := fs.Duration("duration", 10*time.Second, "how long to send requests")
+	url := fs.String("url", "", "base URL of a running server; empty drives the router in process")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 || *rps <= 0 || *duration <= 0 {
+		return errors.New("usage: loadtest [-rps 100] [-duration 10s] [-url http://host:8080]")
+	}
+
+	send := remoteSender(*url)
+	if *url == "" {
+		send = inProcessSender(newMemoryServer(Config{}).newRouter())
+	}
+	start := time.Now()
+	results := generateLoad(context.Background(), *rps, *duration, send)
+	if len(results) == 0 {
+		return errors.New("loadtest: no requests sent")
+	}
+	writeLoadReport(out, summarizeLoad(results), time.Since(start))
+	return nil
+}
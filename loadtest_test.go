@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== Load Test Tests ==========
+
+func TestPercentile(t *testing.T) {
+	var sorted []time.Duration
+	for i := 1; i <= 100; i++ {
+		sorted = append(sorted, time.Duration(i)*time.Millisecond)
+	}
+
+	assert.Equal(t, 50*time.Millisecond, percentile(sorted, 50))
+	assert.Equal(t, 99*time.Millisecond, percentile(sorted, 99))
+	assert.Equal(t, 100*time.Millisecond, percentile(sorted, 100))
+	assert.Equal(t, time.Millisecond, percentile(sorted, 0))
+	assert.Equal(t, time.Duration(0), percentile(nil, 50))
+}
+
+func TestSummarizeLoad(t *testing.T) {
+	results := []loadResult{
+		{route: "GET /users", status: 200, latency: 3 * time.Millisecond},
+		{route: "GET /users", status: 200, latency: time.Millisecond},
+		{route: "GET /posts", status: 503, latency: 2 * time.Millisecond},
+		{route: "GET /posts", status: 0, latency: 10 * time.Millisecond},
+	}
+
+	summary := summarizeLoad(results)
+	require.Len(t, summary, 3)
+	assert.Equal(t, routeLatency{Route: "GET /posts", Requests: 2, Errors: 2, P50: 2 * time.Millisecond, P90: 10 * time.Millisecond, P99: 10 * time.Millisecond, Max: 10 * time.Millisecond}, summary[0])
+	assert.Equal(t, routeLatency{Route: "GET /users", Requests: 2, P50: time.Millisecond, P90: 3 * time.Millisecond, P99: 3 * time.Millisecond, Max: 3 * time.Millisecond}, summary[1])
+	assert.Equal(t, "all", summary[2].Route)
+	assert.Equal(t, 4, summary[2].Requests)
+	assert.Equal(t, 2, summary[2].Errors)
+}
+
+func TestGenerateLoad_InProcess(t *testing.T) {
+	results := generateLoad(context.Background(), 200, 200*time.Millisecond, inProcessSender(setupRouter()))
+
+	require.NotEmpty(t, results)
+	assert.LessOrEqual(t, len(results), 41)
+	for _, r := range results {
+		assert.Equal(t, http.StatusOK, r.status, r.route)
+	}
+}
+
+func TestGenerateLoad_Remote(t *testing.T) {
+	server := httptest.NewServer(setupRouter())
+	t.Cleanup(server.Close)
+
+	results := generateLoad(context.Background(), 100, 100*time.Millisecond, remoteSender(server.URL))
+	require.NotEmpty(t, results)
+	for _, r := range results {
+		assert.Equal(t, http.StatusOK, r.status, r.route)
+	}
+}
+
+func TestRunLoadtest(t *testing.T) {
+	setupRouter()
+	var out bytes.Buffer
+	require.NoError(t, runLoadtest([]string{"-rps", "100", "-duration", "100ms"}, &out))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Contains(t, lines[0], "p99")
+	assert.Contains(t, out.String(), "GET /users/{id}")
+	assert.Contains(t, lines[len(lines)-2], "all")
+	assert.Contains(t, lines[len(lines)-1], "requests/s")
+
+	assert.Error(t, runLoadtest([]string{"-rps", "0"}, &out))
+	assert.Error(t, runLoadtest([]string{"extra"}, &out))
+}
@@ -0,0 +1,48 @@
+package main
+
+import "net/http"
+
+// Mail is a message to a user.
+type Mail struct {
+	To      string `redact:"email"`
+	Subject string
+	Body    string
+}
+
+// MailSender delivers mail.
+type MailSender interface {
+	Send(m Mail) error
+}
+
+// logMailSender is the default MailSender. The fixture has no mail
+// server, so it writes each message to log. The recipient is masked; the
+// body is not, since the log is where its links are delivered.
+type logMailSender struct {
+	log logFunc
+}
+
+func (s logMailSender) Send(m Mail) error {
+	m = redacted(m)
+	s.log.at("info", "mail to %s: %s\n%s", m.To, m.Subject, m.Body)
+	return nil
+}
+
+// publicLink returns the absolute URL of path under PUBLIC_URL, for links
+// in mail. Without PUBLIC_URL it answers 503 and returns false.
+func (srv *Server) publicLink(w http.ResponseWriter, r *http.Request, path string) (string, bool) {
+	if srv.Config.PublicURL == "" {
+		respondError(w, r, http.StatusServiceUnavailable, "public URL is not configured")
+		return "", false
+	}
+	return srv.Config.PublicURL + path, true
+}
+
+// requestOrigin returns the scheme and host r was sent to, for absolute
+// URLs. The host is whatever the client sent, so links that leave the
+// response, such as those in mail, use publicLink instead.
+func requestOrigin(r *http.Request) string {
+	if r.TLS != nil {
+		return "https://" + r.Host
+	}
+	return "http://" + r.Host
+}
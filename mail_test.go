@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingMailer is a MailSender that keeps what it is sent, or fails
+// with err.
+type recordingMailer struct {
+	sent []Mail
+	err  error
+}
+
+func (m *recordingMailer) Send(mail Mail) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, mail)
+	return nil
+}
+
+// testPublicURL is the PUBLIC_URL useTestMailer configures.
+const testPublicURL = "https://api.example.com"
+
+// useTestMailer replaces the test server's Mailer, configures a public URL
+// for the links it mails, and returns the mail sent.
+func useTestMailer(t *testing.T) *[]Mail {
+	t.Helper()
+	m := &recordingMailer{}
+	server.Mailer = m
+	server.Config.PublicURL = testPublicURL
+	return &m.sent
+}
+
+// ========== Mail Tests ==========
+
+func TestLogMailSender(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	require.NoError(t, logMailSender{log: server.logAt}.Send(Mail{To: "alice@example.com", Subject: "Hello", Body: "Hi Alice"}))
+
+	assert.Contains(t, buf.String(), "mail to a***@example.com: Hello")
+	assert.NotContains(t, buf.String(), "alice@example.com")
+	assert.Contains(t, buf.String(), "Hi Alice")
+}
+
+func TestRequestOrigin(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://api.example/users", nil)
+	assert.Equal(t, "http://api.example", requestOrigin(req))
+
+	req.TLS = &tls.ConnectionState{}
+	assert.Equal(t, "https://api.example", requestOrigin(req))
+}
@@ -1,185 +1,533 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/api2spec/api2spec-fixture-chi/flags"
 )
 
 type HealthStatus struct {
 	Status  string `json:"status"`
 	Version string `json:"version"`
+	// Store is reported by GET /health/ready only.
+	Store *StoreInfo `json:"store,omitempty"`
 }
 
 type User struct {
-	ID    int    `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Email   string `json:"email" redact:"email"`
+	Version int    `json:"version"`
+	// PostCount is the number of posts the user has written, kept by the
+	// store. It is ignored on writes.
+	PostCount int `json:"postCount"`
+	// Verified is whether the user has confirmed their email through
+	// GET /verify. It is kept by the store, ignored on writes, and cleared
+	// when the email changes.
+	Verified bool `json:"verified"`
+	// CreatedAt and UpdatedAt are set by the store from its Clock and
+	// ignored on writes. UpdatedAt changes on every write and backs
+	// Last-Modified and If-Unmodified-Since.
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
 }
 
 type Post struct {
-	ID     int    `json:"id"`
-	UserID int    `json:"userId"`
-	Title  string `json:"title"`
-	Body   string `json:"body"`
+	ID      int    `json:"id"`
+	UserID  int    `json:"userId"`
+	Title   string `json:"title"`
+	Body    string `json:"body"`
+	Version int    `json:"version"`
+	// Slug identifies the post in GET /posts/slug/{slug}. It is unique,
+	// and derived from the title when omitted on create.
+	Slug string `json:"slug"`
+	// CreatedAt and UpdatedAt are set by the store from its Clock and
+	// ignored on writes. CreatedAt feeds /metrics/posts, UpdatedAt backs
+	// Last-Modified and If-Unmodified-Since.
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
 }
 
 func main() {
-	r := chi.NewRouter()
-	r.Use(middleware.Logger)
-
-	// Health routes
-	r.Get("/health", healthHandler)
-	r.Get("/health/ready", readyHandler)
-
-	// User routes
-	r.Route("/users", func(r chi.Router) {
-		r.Get("/", listUsers)
-		r.Post("/", createUser)
-		r.Route("/{id}", func(r chi.Router) {
-			r.Get("/", getUser)
-			r.Put("/", updateUser)
-			r.Delete("/", deleteUser)
-			r.Get("/posts", getUserPosts)
-		})
-	})
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		if err := runReplay(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "mock" {
+		if err := runMock(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "loadtest" {
+		if err := runLoadtest(os.Args[2:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "gen-spec" {
+		if err := runGenSpec(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-	// Post routes
-	r.Route("/posts", func(r chi.Router) {
-		r.Get("/", listPosts)
-		r.Post("/", createPost)
-		r.Get("/{id}", getPost)
-	})
+	var generate *dataSpec
+	if len(os.Args) > 1 && os.Args[1] == "--generate" {
+		spec, err := parseGenerateArgs(os.Args[2:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		generate = &spec
+	}
 
-	if err := http.ListenAndServe(":8080", r); err != nil {
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	srv := newServer(cfg, nil)
+	validateRequests, err := newRequestValidator(srv.spec)
+	if err != nil {
 		log.Fatal(err)
 	}
-}
 
-func healthHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(HealthStatus{Status: "ok", Version: "0.1.0"})
+	slashes, err := trailingSlashMiddleware(cfg.TrailingSlash)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	middlewares := []func(http.Handler) http.Handler{srv.logRequests}
+	if cfg.AccessLog.Path != "" {
+		accessLog, err := openRotatingFile(cfg.AccessLog.Path, cfg.AccessLog.MaxSize, cfg.AccessLog.MaxAge)
+		if err != nil {
+			log.Fatal(err)
+		}
+		middlewares = append(middlewares, srv.newAccessLog(accessLog, cfg.AccessLog.BodyLimit))
+		if err := srv.jobs.add(rotateAccessLogJob(accessLog)); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if len(cfg.Honeypot.Paths) > 0 {
+		middlewares = append(middlewares, srv.newHoneypot(cfg.Honeypot).middleware)
+	}
+	middlewares = append(middlewares, slashes)
+	if len(cfg.SigningKeys) > 0 {
+		middlewares = append(middlewares, signResponses(cfg.SigningKeys[0]))
+	}
+	if cfg.Record {
+		middlewares = append(middlewares, newHARRecorder(cfg.RecordFile).middleware)
+	}
+	middlewares = append(middlewares, flagged(flags.EnableChaos, newChaos(cfg.Chaos, time.Now().UnixNano())))
+	if cfg.Shadow.URL != "" {
+		middlewares = append(middlewares, srv.newShadower(cfg.Shadow, srv.shadowStats, time.Now().UnixNano()))
+	}
+	middlewares = append(middlewares, validateRequests)
+
+	var checks []routerCheck
+	var servers []*http.Server
+	if cfg.OpsAddr == cfg.Addr {
+		router := srv.newRouter(middlewares...)
+		checks = []routerCheck{{"api", router, append(srv.apiRouteDefs(), srv.opsRouteDefs()...)}}
+		servers = []*http.Server{{Addr: cfg.Addr, Handler: router}}
+	} else {
+		api, ops := srv.newAPIRouter(middlewares...), srv.newOpsRouter(srv.logRequests, validateRequests)
+		checks = []routerCheck{{"api", api, srv.apiRouteDefs()}, {"ops", ops, srv.opsRouteDefs()}}
+		servers = []*http.Server{{Addr: cfg.Addr, Handler: api}, {Addr: cfg.OpsAddr, Handler: ops}}
+	}
+
+	// The subsystems, each after what it needs. The listeners come up
+	// last and, on shutdown, stop first, so that no request arrives after
+	// the store or the workers behind it are gone.
+	lc := &Lifecycle{log: srv.logAt}
+	lc.Register(
+		Subsystem{Name: "config", Start: func(context.Context) error {
+			return srv.live.reload()
+		}},
+		background("config-reload", srv.reloadOnHangup, "config"),
+		srv.redisSubsystem(),
+		srv.storeSubsystem(generate),
+		srv.eventsSubsystem(),
+		background("leader", func(ctx context.Context) {
+			srv.leader.use(srv.sharedLease())
+			srv.leader.run(ctx)
+		}, "redis", "store"),
+		background("webhooks", func(ctx context.Context) {
+			srv.leader.whileLeader(ctx, func(ctx context.Context) {
+				d := newWebhookDispatcher(srv.Store)
+				d.now = serverClock{srv}.Now
+				d.log = srv.logAt
+				d.run(ctx, time.Second)
+			})
+		}, "store", "leader"),
+		background("jobs", func(ctx context.Context) {
+			srv.leader.whileLeader(ctx, srv.jobs.schedule)
+		}, "store", "leader"),
+		Subsystem{Name: "self-check", DependsOn: []string{"store"}, Start: func(ctx context.Context) error {
+			return selfCheck(ctx, srv.spec, srv.Store, checks...)
+		}},
+	)
+	failed := make(chan error, len(servers))
+	names := []string{"api", "ops"}
+	for i, hs := range servers {
+		lc.Register(srv.listener(names[i], hs, failed, "config", "store", "events", "webhooks", "jobs", "self-check"))
+	}
+	if err := lc.Start(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	select {
+	case sig := <-stop:
+		srv.logAt("info", "%v: shutting down", sig)
+	case err := <-failed:
+		srv.logAt("error", "%v: shutting down", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := lc.Stop(ctx); err != nil {
+		log.Fatal(err)
+	}
 }
 
-func readyHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(HealthStatus{Status: "ready", Version: "0.1.0"})
+// redisSubsystem connects to REDIS_URL, if set, sharing the client through
+// srv.Redis.
+func (srv *Server) redisSubsystem() Subsystem {
+	return Subsystem{
+		Name: "redis",
+		Start: func(ctx context.Context) error {
+			if srv.Config.RedisURL == "" {
+				return nil
+			}
+			var err error
+			srv.Redis, err = newRedisClient(ctx, srv.Config.RedisURL)
+			return err
+		},
+		Stop: func(context.Context) error {
+			if srv.Redis == nil {
+				return nil
+			}
+			return srv.Redis.Close()
+		},
+	}
 }
 
-func listUsers(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	users := []User{
-		{ID: 1, Name: "Alice", Email: "alice@example.com"},
-		{ID: 2, Name: "Bob", Email: "bob@example.com"},
+// storeSubsystem opens srv's store: Postgres when DATABASE_URL is set,
+// with its field keys rotated, otherwise memory, filled from generate if
+// that is set, behind the circuit breaker when one is configured.
+func (srv *Server) storeSubsystem(generate *dataSpec) Subsystem {
+	cfg := srv.Config
+	var pg *pgStore
+	return Subsystem{
+		Name:      "store",
+		DependsOn: []string{"config", "redis"},
+		Start: func(ctx context.Context) error {
+			var store Store = newMemoryStore(srv.events)
+			if cfg.DatabaseURL != "" {
+				var err error
+				if pg, err = newPostgresStore(ctx, cfg.DatabaseURL, cfg.DBPool, srv.events); err != nil {
+					return err
+				}
+				if len(cfg.FieldKeys) > 0 {
+					pg.fields = newFieldCipher(newStaticKeyring(cfg.FieldKeys, cfg.FieldIndexKey))
+					n, err := pg.rotateFieldKeys()
+					if err != nil {
+						pg.Close()
+						return fmt.Errorf("field encryption: %w", err)
+					}
+					if n > 0 {
+						srv.logAt("info", "field encryption: resealed %d emails with key %s", n, cfg.FieldKeys[0].ID)
+					}
+				}
+				store = pg
+			}
+			if generate != nil {
+				st, err := generateState(store.Snapshot(), *generate)
+				if err != nil {
+					if pg != nil {
+						pg.Close()
+					}
+					return err
+				}
+				store.Restore(st)
+				srv.logAt("info", "generated %d users, %d posts, %d comments, %d todos and %d albums with seed %d",
+					generate.Users, generate.Posts, generate.Comments, generate.Todos, generate.Albums, generate.Seed)
+			}
+			if cfg.Breaker.Threshold > 0 {
+				bs := newBreakerStore(store, newBreaker(cfg.Breaker.Threshold, cfg.Breaker.Cooldown))
+				if srv.Redis != nil {
+					bs.cache = newRedisReadCache(srv.Redis, srv.logAt)
+				}
+				store = bs
+			}
+			store.UpdateFlags(flags.Patch{EnableChaos: &cfg.Chaos.Enabled})
+			srv.useClock(store)
+			srv.Store = store
+			return nil
+		},
+		Stop: func(context.Context) error {
+			if pg != nil {
+				pg.Close()
+			}
+			return nil
+		},
 	}
-	json.NewEncoder(w).Encode(users)
 }
 
-func getUser(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.Atoi(chi.URLParam(r, "id"))
-	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "invalid id"})
-		return
+// eventsSubsystem forwards the store's entity events to srv's plugins.
+func (srv *Server) eventsSubsystem() Subsystem {
+	var unsubscribe func()
+	return Subsystem{
+		Name:      "events",
+		DependsOn: []string{"store"},
+		Start: func(context.Context) error {
+			unsubscribe = srv.events.Subscribe(srv.entityChanged)
+			return nil
+		},
+		Stop: func(context.Context) error {
+			unsubscribe()
+			return nil
+		},
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(User{ID: id, Name: "Sample User", Email: "user@example.com"})
 }
 
-func createUser(w http.ResponseWriter, r *http.Request) {
-	var user User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "invalid json"})
-		return
+// reloadOnHangup reloads the runtime configuration on every SIGHUP until
+// ctx is done. A bad configuration is logged and the previous one kept.
+func (srv *Server) reloadOnHangup(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			if err := srv.live.reload(); err != nil {
+				srv.logAt("error", "config reload failed: %v", err)
+				continue
+			}
+			srv.logAt("info", "config reloaded")
+		}
 	}
-	user.ID = 1
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(user)
 }
 
-func updateUser(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+// runReplay implements "replay [-addr :8080] recording.har": it serves the
+// responses recorded in a HAR log instead of the live API.
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	addr := fs.String("addr", ":8080", "listen address")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: replay [-addr :8080] recording.har")
+	}
+
+	f, err := os.Open(fs.Arg(0))
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "invalid id"})
-		return
+		return err
 	}
-	var user User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "invalid json"})
-		return
+	rp, err := newReplayer(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(0), err)
 	}
-	user.ID = id
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(user)
+	return http.ListenAndServe(*addr, middleware.Logger(rp))
 }
 
-func deleteUser(w http.ResponseWriter, r *http.Request) {
-	_, err := strconv.Atoi(chi.URLParam(r, "id"))
-	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "invalid id"})
-		return
+// trailingSlashMiddleware returns the chi middleware for a TrailingSlash
+// mode.
+func trailingSlashMiddleware(mode string) (func(http.Handler) http.Handler, error) {
+	switch mode {
+	case "strip":
+		return middleware.StripSlashes, nil
+	case "redirect":
+		return middleware.RedirectSlashes, nil
 	}
-	w.WriteHeader(http.StatusNoContent)
+	return nil, fmt.Errorf("unknown trailing slash mode %q (want strip or redirect)", mode)
 }
 
-func getUserPosts(w http.ResponseWriter, r *http.Request) {
-	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
-	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "invalid id"})
-		return
+// newRouter builds a router serving both the public API and the
+// operational routes, for running on a single listener.
+func (srv *Server) newRouter(middlewares ...func(http.Handler) http.Handler) *chi.Mux {
+	r := srv.newAPIRouter(middlewares...)
+	srv.opsRoutes(r)
+	return r
+}
+
+// newAPIRouter builds the public API router, with middlewares installed
+// after the built-in stages and before response envelopes.
+func (srv *Server) newAPIRouter(middlewares ...func(http.Handler) http.Handler) *chi.Mux {
+	r := chi.NewRouter()
+	r.Use(withLocale, srv.resolveClientIP, withServerTiming, srv.nameFields, varyHeaders, srv.runPlugins, srv.filterAdminIPs, srv.withFlags, methodOverride, checkDigests, srv.degradedMode, srv.newTenantMiddleware(), srv.newClientLimits(), srv.authenticate, srv.enforceQuota, srv.applyRules)
+	r.Use(middlewares...)
+	r.Use(envelopeResponses)
+	r.NotFound(notFoundHandler)
+	srv.apiRoutes(r)
+	return r
+}
+
+// newOpsRouter builds the router for the internal operations listener.
+func (srv *Server) newOpsRouter(middlewares ...func(http.Handler) http.Handler) *chi.Mux {
+	r := chi.NewRouter()
+	r.Use(withLocale, srv.resolveClientIP, withServerTiming, srv.nameFields, varyHeaders, srv.runPlugins, srv.filterAdminIPs, srv.withFlags, checkDigests, srv.degradedMode)
+	r.Use(middlewares...)
+	r.NotFound(notFoundHandler)
+	srv.opsRoutes(r)
+	return r
+}
+
+// opsRoutes registers the operational routes: health checks, Prometheus
+// metrics, the route table, profiling and administration.
+func (srv *Server) opsRoutes(r chi.Router) {
+	srv.mountRoutes(r, srv.opsRouteDefs())
+	r.Mount("/debug", middleware.Profiler())
+}
+
+// apiRoutes registers the public API routes. The /posts subtree has a
+// subrouter of its own, so that its middleware applies to posts alone, and
+// the proxy routes are mounted under /proxy/{service}, as a gateway would
+// mount an upstream.
+func (srv *Server) apiRoutes(r chi.Router) {
+	var posts, proxied, rest []RouteDef
+	for _, d := range srv.apiRouteDefs() {
+		switch {
+		case d.Pattern == "/posts" || strings.HasPrefix(d.Pattern, "/posts/"):
+			if d.Pattern = strings.TrimPrefix(d.Pattern, "/posts"); d.Pattern == "" {
+				d.Pattern = "/"
+			}
+			posts = append(posts, d)
+		case strings.HasPrefix(d.Pattern, proxyPrefix+"/"):
+			d.Pattern = strings.TrimPrefix(d.Pattern, proxyPrefix)
+			proxied = append(proxied, d)
+		default:
+			rest = append(rest, d)
+		}
+	}
+	srv.mountRoutes(r, rest)
+	r.Route("/posts", func(r chi.Router) {
+		r.Use(resourceVersion(postsResourceVersion))
+		srv.mountRoutes(r, posts)
+	})
+	proxy := chi.NewRouter()
+	srv.mountRoutes(proxy, proxied)
+	r.Mount(proxyPrefix, proxy)
+}
+
+func notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	respondError(w, r, http.StatusNotFound, "not found")
+}
+
+func (srv *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, HealthStatus{Status: "ok", Version: "0.1.0"})
+}
+
+// readyHandler reports "degraded" while the store's breaker is not closed.
+// The server still serves reads then, so it stays ready. Each check is
+// recorded for GET /health/history.
+func (srv *Server) readyHandler(w http.ResponseWriter, r *http.Request) {
+	info := srv.requestStore(r).Info()
+	status := "ready"
+	if info.Breaker != "" && info.Breaker != breakerClosed {
+		status = "degraded"
 	}
-	w.Header().Set("Content-Type", "application/json")
-	posts := []Post{{ID: 1, UserID: userID, Title: "User Post", Body: "Content"}}
-	json.NewEncoder(w).Encode(posts)
+	srv.health.record(status, info.Breaker, srv.Clock.Now())
+	respondJSON(w, http.StatusOK, HealthStatus{Status: status, Version: "0.1.0", Store: &info})
 }
 
-func listPosts(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	posts := []Post{
-		{ID: 1, UserID: 1, Title: "First Post", Body: "Hello world"},
-		{ID: 2, UserID: 1, Title: "Second Post", Body: "Another post"},
+// newUser serves a blank user, every field at its zero value, for create
+// forms to start from.
+func (srv *Server) newUser(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, User{})
+}
+
+func (srv *Server) getUserPosts(w http.ResponseWriter, r *http.Request) {
+	if userID, ok := pathID(w, r); ok {
+		srv.respondUserPosts(w, r, userID)
 	}
-	json.NewEncoder(w).Encode(posts)
 }
 
-func getPost(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+// respondUserPosts serves userID's posts, for GET /users/{id}/posts and
+// GET /me/posts.
+func (srv *Server) respondUserPosts(w http.ResponseWriter, r *http.Request, userID int) {
+	page, err := parsePageParams(r)
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "invalid id"})
+		respondError(w, r, http.StatusBadRequest, "invalid pagination")
+		return
+	}
+	if _, err := srv.requestStore(r).GetUser(userID); err != nil {
+		respondNotFound(w, r, "user", userID)
+		return
+	}
+
+	posts := srv.requestStore(r).ListPostsByUser(userID)
+	if title := r.URL.Query().Get("title"); title != "" {
+		filtered := []Post{}
+		for _, p := range posts {
+			if strings.Contains(strings.ToLower(p.Title), strings.ToLower(title)) {
+				filtered = append(filtered, p)
+			}
+		}
+		posts = filtered
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(posts)))
+	if _, ok := parseItemsRange(r); ok || srv.Config.PageEnvelope {
+		// A Range header takes the place of page and per_page.
+		respondList(w, r, posts, srv.Config.PageEnvelope)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(Post{ID: id, UserID: 1, Title: "Sample Post", Body: "Post body"})
+	respondJSON(w, http.StatusOK, paginate(posts, page))
 }
 
-func createPost(w http.ResponseWriter, r *http.Request) {
+// createUserPost creates a post written by user {id}. The post and the
+// user's postCount change together or not at all.
+func (srv *Server) createUserPost(w http.ResponseWriter, r *http.Request) {
+	userID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
 	var post Post
-	if err := json.NewDecoder(r.Body).Decode(&post); err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "invalid json"})
+	if err := decodeJSON(r, &post); err != nil {
+		respondDecodeError(w, r, err)
+		return
+	}
+	post.UserID = userID
+	if violations := validatePostSlug(post); len(violations) > 0 {
+		respondProblem(w, r, http.StatusBadRequest, "invalid request", Problem{Violations: violations})
+		return
+	}
+	created, err := srv.requestStore(r).CreateUserPost(post)
+	switch {
+	case errors.Is(err, errNotFound):
+		respondNotFound(w, r, "user", userID)
+		return
+	case errors.Is(err, errDuplicate):
+		respondDuplicate(w, r, duplicatePostDetail(post, created), "/posts/"+strconv.Itoa(created.ID))
 		return
 	}
-	post.ID = 1
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(post)
+	w.Header().Set("Location", "/posts/"+strconv.Itoa(created.ID))
+	respondJSON(w, http.StatusCreated, created)
 }
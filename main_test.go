@@ -6,42 +6,36 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+// server is the Server behind the routers the tests build. Tests reach
+// its store through it, and swap its fields to inject fakes.
+var server = newMemoryServer(Config{})
+
 // setupRouter creates a new chi router with all routes configured for testing.
+// It also replaces server, so every test starts from the seed data, the
+// default configuration and zeroed counters.
 func setupRouter() *chi.Mux {
-	r := chi.NewRouter()
-
-	// Health routes
-	r.Get("/health", healthHandler)
-	r.Get("/health/ready", readyHandler)
-
-	// User routes
-	r.Route("/users", func(r chi.Router) {
-		r.Get("/", listUsers)
-		r.Post("/", createUser)
-		r.Route("/{id}", func(r chi.Router) {
-			r.Get("/", getUser)
-			r.Put("/", updateUser)
-			r.Delete("/", deleteUser)
-			r.Get("/posts", getUserPosts)
-		})
-	})
-
-	// Post routes
-	r.Route("/posts", func(r chi.Router) {
-		r.Get("/", listPosts)
-		r.Post("/", createPost)
-		r.Get("/{id}", getPost)
-	})
+	server = newMemoryServer(Config{})
+	return server.newRouter()
+}
 
-	return r
+// freezeClock stops server's clock, which its store also reads, at t, so
+// the timestamps they hand out are known.
+func freezeClock(t time.Time) *fakeClock {
+	clock := &fakeClock{t: t}
+	server.Clock = clock
+	return clock
 }
 
 // ========== Health Endpoint Tests ==========
@@ -114,7 +108,7 @@ func TestListUsers_Success(t *testing.T) {
 func TestGetUser_Success(t *testing.T) {
 	router := setupRouter()
 
-	req := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+	req := httptest.NewRequest(http.MethodGet, "/users/2", nil)
 	w := httptest.NewRecorder()
 
 	router.ServeHTTP(w, req)
@@ -126,37 +120,38 @@ func TestGetUser_Success(t *testing.T) {
 	err := json.Unmarshal(w.Body.Bytes(), &user)
 	require.NoError(t, err)
 
-	assert.Equal(t, 42, user.ID)
-	assert.Equal(t, "Sample User", user.Name)
-	assert.Equal(t, "user@example.com", user.Email)
+	assert.Equal(t, 2, user.ID)
+	assert.Equal(t, "Bob", user.Name)
+	assert.Equal(t, "bob@example.com", user.Email)
 }
 
 func TestGetUser_DifferentIDs(t *testing.T) {
 	tests := []struct {
-		name       string
-		userID     string
-		expectedID int
+		name         string
+		userID       string
+		expectedName string
 	}{
 		{
-			name:       "user id 1",
-			userID:     "1",
-			expectedID: 1,
+			name:         "user id 1",
+			userID:       "1",
+			expectedName: "Alice",
 		},
 		{
-			name:       "user id 100",
-			userID:     "100",
-			expectedID: 100,
+			name:         "user id 2",
+			userID:       "2",
+			expectedName: "Bob",
 		},
 		{
-			name:       "user id 999",
-			userID:     "999",
-			expectedID: 999,
+			name:         "created user",
+			userID:       "3",
+			expectedName: "Carol",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			router := setupRouter()
+			server.Store.CreateUser(User{Name: "Carol", Email: "carol@example.com"})
 
 			req := httptest.NewRequest(http.MethodGet, "/users/"+tt.userID, nil)
 			w := httptest.NewRecorder()
@@ -169,7 +164,8 @@ func TestGetUser_DifferentIDs(t *testing.T) {
 			err := json.Unmarshal(w.Body.Bytes(), &user)
 			require.NoError(t, err)
 
-			assert.Equal(t, tt.expectedID, user.ID)
+			assert.Equal(t, tt.userID, strconv.Itoa(user.ID))
+			assert.Equal(t, tt.expectedName, user.Name)
 		})
 	}
 }
@@ -197,7 +193,9 @@ func TestCreateUser_Success(t *testing.T) {
 	err = json.Unmarshal(w.Body.Bytes(), &createdUser)
 	require.NoError(t, err)
 
-	assert.Equal(t, 1, createdUser.ID)
+	assert.Equal(t, 3, createdUser.ID)
+	assert.Equal(t, "/users/3", w.Header().Get("Location"))
+	assert.Equal(t, 1, createdUser.Version)
 	assert.Equal(t, "Charlie", createdUser.Name)
 	assert.Equal(t, "charlie@example.com", createdUser.Email)
 }
@@ -211,21 +209,22 @@ func TestCreateUser_EmptyBody(t *testing.T) {
 
 	router.ServeHTTP(w, req)
 
-	// Handler accepts any valid JSON and assigns ID=1
+	// Handler accepts any valid JSON and assigns the next free ID
 	assert.Equal(t, http.StatusCreated, w.Code)
 
 	var createdUser User
 	err := json.Unmarshal(w.Body.Bytes(), &createdUser)
 	require.NoError(t, err)
-	assert.Equal(t, 1, createdUser.ID)
+	assert.Equal(t, 3, createdUser.ID)
 }
 
 func TestUpdateUser_Success(t *testing.T) {
 	router := setupRouter()
 
 	updatedUser := User{
-		Name:  "Alice Updated",
-		Email: "alice.updated@example.com",
+		Name:    "Alice Updated",
+		Email:   "alice.updated@example.com",
+		Version: 1,
 	}
 	body, err := json.Marshal(updatedUser)
 	require.NoError(t, err)
@@ -243,6 +242,7 @@ func TestUpdateUser_Success(t *testing.T) {
 	require.NoError(t, err)
 
 	assert.Equal(t, 1, user.ID)
+	assert.Equal(t, 2, user.Version)
 	assert.Equal(t, "Alice Updated", user.Name)
 	assert.Equal(t, "alice.updated@example.com", user.Email)
 }
@@ -254,14 +254,14 @@ func TestUpdateUser_DifferentIDs(t *testing.T) {
 		expectedID int
 	}{
 		{
-			name:       "update user 5",
-			userID:     "5",
-			expectedID: 5,
+			name:       "update user 1",
+			userID:     "1",
+			expectedID: 1,
 		},
 		{
-			name:       "update user 123",
-			userID:     "123",
-			expectedID: 123,
+			name:       "update user 2",
+			userID:     "2",
+			expectedID: 2,
 		},
 	}
 
@@ -270,8 +270,9 @@ func TestUpdateUser_DifferentIDs(t *testing.T) {
 			router := setupRouter()
 
 			updatedUser := User{
-				Name:  "Updated Name",
-				Email: "updated@example.com",
+				Name:    "Updated Name",
+				Email:   "updated@example.com",
+				Version: 1,
 			}
 			body, err := json.Marshal(updatedUser)
 			require.NoError(t, err)
@@ -293,6 +294,59 @@ func TestUpdateUser_DifferentIDs(t *testing.T) {
 	}
 }
 
+func TestUpdateUser_StaleVersion_ReturnsConflict(t *testing.T) {
+	router := setupRouter()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	freezeClock(now)
+
+	body, err := json.Marshal(User{Name: "First", Email: "first@example.com", Version: 1})
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/users/1", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// Second writer still holds version 1.
+	body, err = json.Marshal(User{Name: "Second", Email: "second@example.com", Version: 1})
+	require.NoError(t, err)
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPut, "/users/1", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assertJSONContentType(t, w)
+
+	var latest User
+	err = json.Unmarshal(w.Body.Bytes(), &latest)
+	require.NoError(t, err)
+	assert.Equal(t, User{
+		ID: 1, Name: "First", Email: "first@example.com", Version: 2, PostCount: 2,
+		CreatedAt: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC), UpdatedAt: now,
+	}, latest)
+}
+
+func TestUpdateUser_MissingVersion_ReturnsPreconditionRequired(t *testing.T) {
+	router := setupRouter()
+
+	body, err := json.Marshal(User{Name: "No Version", Email: "nov@example.com"})
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/users/1", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+	assertJSONContentType(t, w)
+
+	// The stored user is untouched.
+	user, err := server.Store.GetUser(1)
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", user.Name)
+	assert.Equal(t, 1, user.Version)
+}
+
 func TestDeleteUser_Success(t *testing.T) {
 	router := setupRouter()
 
@@ -314,31 +368,71 @@ func TestGetUserPosts_Success(t *testing.T) {
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-Total-Count"))
 
 	var posts []Post
 	err := json.Unmarshal(w.Body.Bytes(), &posts)
 	require.NoError(t, err)
 
-	assert.Len(t, posts, 1)
+	assert.Len(t, posts, 2)
 	assert.Equal(t, 1, posts[0].UserID)
-	assert.Equal(t, "User Post", posts[0].Title)
+	assert.Equal(t, "First Post", posts[0].Title)
+	assert.Equal(t, "Second Post", posts[1].Title)
 }
 
-func TestGetUserPosts_DifferentUserIDs(t *testing.T) {
+func TestCreateUserPost(t *testing.T) {
 	tests := []struct {
 		name           string
-		userID         string
-		expectedUserID int
+		path           string
+		body           string
+		expectedStatus int
+		expectedCount  int
+	}{
+		{"created", "/users/1/posts", `{"title":"Third Post","body":"More"}`, http.StatusCreated, 3},
+		{"path wins over body", "/users/1/posts", `{"userId":2,"title":"Third Post"}`, http.StatusCreated, 3},
+		{"duplicate title", "/users/1/posts", `{"title":"First Post"}`, http.StatusConflict, 2},
+		{"unknown user", "/users/999/posts", `{"title":"Orphan"}`, http.StatusNotFound, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if w.Code == http.StatusCreated {
+				var post Post
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &post))
+				assert.Equal(t, 1, post.UserID)
+				assert.Equal(t, "/posts/"+strconv.Itoa(post.ID), w.Header().Get("Location"))
+			}
+
+			user, err := server.Store.GetUser(1)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedCount, user.PostCount)
+			assert.Len(t, server.Store.ListPostsByUser(1), tt.expectedCount)
+		})
+	}
+}
+
+func TestGetUserPosts_DifferentUserIDs(t *testing.T) {
+	tests := []struct {
+		name          string
+		userID        string
+		expectedCount int
 	}{
 		{
-			name:           "user 1 posts",
-			userID:         "1",
-			expectedUserID: 1,
+			name:          "user 1 posts",
+			userID:        "1",
+			expectedCount: 2,
 		},
 		{
-			name:           "user 42 posts",
-			userID:         "42",
-			expectedUserID: 42,
+			name:          "user 2 has no posts",
+			userID:        "2",
+			expectedCount: 0,
 		},
 	}
 
@@ -357,8 +451,69 @@ func TestGetUserPosts_DifferentUserIDs(t *testing.T) {
 			err := json.Unmarshal(w.Body.Bytes(), &posts)
 			require.NoError(t, err)
 
-			assert.Len(t, posts, 1)
-			assert.Equal(t, tt.expectedUserID, posts[0].UserID)
+			// An empty page is [], never null
+			assert.NotNil(t, posts)
+			assert.Len(t, posts, tt.expectedCount)
+		})
+	}
+}
+
+func TestGetUserPosts_UnknownUser_ReturnsNotFound(t *testing.T) {
+	router := setupRouter()
+
+	req := httptest.NewRequest(http.MethodGet, "/users/42/posts", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assertNotFoundProblem(t, w, "/users/42/posts")
+}
+
+func TestGetUserPosts_Pagination(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedTitles []string
+	}{
+		{"first page", "?per_page=2", http.StatusOK, []string{"First Post", "Second Post"}},
+		{"second page", "?page=2&per_page=2", http.StatusOK, []string{"Third Post", "Fourth Post"}},
+		{"last partial page", "?page=3&per_page=2", http.StatusOK, []string{"Fifth Post"}},
+		{"past the end", "?page=9&per_page=2", http.StatusOK, []string{}},
+		{"title filter", "?title=th", http.StatusOK, []string{"Third Post", "Fourth Post", "Fifth Post"}},
+		{"title filter is case insensitive", "?title=FIRST", http.StatusOK, []string{"First Post"}},
+		{"title filter with paging", "?title=th&page=2&per_page=2", http.StatusOK, []string{"Fifth Post"}},
+		{"zero page", "?page=0", http.StatusBadRequest, nil},
+		{"non-numeric page", "?page=abc", http.StatusBadRequest, nil},
+		{"per_page too large", "?per_page=101", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+			for _, title := range []string{"Third Post", "Fourth Post", "Fifth Post"} {
+				server.Store.CreatePost(Post{UserID: 1, Title: title})
+			}
+			// Another user's post must never show up.
+			server.Store.CreatePost(Post{UserID: 2, Title: "Other Post"})
+
+			req := httptest.NewRequest(http.MethodGet, "/users/1/posts"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var posts []Post
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &posts))
+			titles := []string{}
+			for _, p := range posts {
+				titles = append(titles, p.Title)
+			}
+			assert.Equal(t, tt.expectedTitles, titles)
 		})
 	}
 }
@@ -401,8 +556,8 @@ func TestGetPost_Success(t *testing.T) {
 	require.NoError(t, err)
 
 	assert.Equal(t, 1, post.ID)
-	assert.Equal(t, "Sample Post", post.Title)
-	assert.Equal(t, "Post body", post.Body)
+	assert.Equal(t, "First Post", post.Title)
+	assert.Equal(t, "Hello world", post.Body)
 }
 
 func TestGetPost_DifferentIDs(t *testing.T) {
@@ -417,14 +572,9 @@ func TestGetPost_DifferentIDs(t *testing.T) {
 			expectedID: 1,
 		},
 		{
-			name:       "post id 50",
-			postID:     "50",
-			expectedID: 50,
-		},
-		{
-			name:       "post id 999",
-			postID:     "999",
-			expectedID: 999,
+			name:       "post id 2",
+			postID:     "2",
+			expectedID: 2,
 		},
 	}
 
@@ -472,7 +622,9 @@ func TestCreatePost_Success(t *testing.T) {
 	err = json.Unmarshal(w.Body.Bytes(), &createdPost)
 	require.NoError(t, err)
 
-	assert.Equal(t, 1, createdPost.ID)
+	assert.Equal(t, 3, createdPost.ID)
+	assert.Equal(t, "/posts/3", w.Header().Get("Location"))
+	assert.Equal(t, 1, createdPost.Version)
 	assert.Equal(t, 1, createdPost.UserID)
 	assert.Equal(t, "My New Post", createdPost.Title)
 	assert.Equal(t, "This is the content of my new post", createdPost.Body)
@@ -487,13 +639,89 @@ func TestCreatePost_EmptyBody(t *testing.T) {
 
 	router.ServeHTTP(w, req)
 
-	// Handler accepts any valid JSON and assigns ID=1
+	// Handler accepts any valid JSON and assigns the next free ID
 	assert.Equal(t, http.StatusCreated, w.Code)
 
 	var createdPost Post
 	err := json.Unmarshal(w.Body.Bytes(), &createdPost)
 	require.NoError(t, err)
-	assert.Equal(t, 1, createdPost.ID)
+	assert.Equal(t, 3, createdPost.ID)
+}
+
+// putPost sends PUT /posts/{id} with p as its JSON body.
+func putPost(t *testing.T, router http.Handler, id string, p Post) *httptest.ResponseRecorder {
+	t.Helper()
+	body, err := json.Marshal(p)
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPut, "/posts/"+id, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestUpdatePost_Success(t *testing.T) {
+	router := setupRouter()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	freezeClock(now)
+
+	w := putPost(t, router, "1", Post{UserID: 2, Title: "First Post, revised", Body: "Edited", Version: 1})
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var post Post
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &post))
+	assert.Equal(t, Post{
+		ID: 1, UserID: 1, Title: "First Post, revised", Body: "Edited", Version: 2, Slug: "first-post",
+		CreatedAt: time.Date(2024, 1, 1, 9, 15, 0, 0, time.UTC), UpdatedAt: now,
+	}, post, "the author and slug are kept")
+}
+
+func TestUpdatePost_StaleVersion_ReturnsConflict(t *testing.T) {
+	router := setupRouter()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	freezeClock(now)
+
+	w := putPost(t, router, "1", Post{Title: "First", Body: "First writer", Version: 1})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Second writer still holds version 1.
+	w = putPost(t, router, "1", Post{Title: "Second", Body: "Second writer", Version: 1})
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assertJSONContentType(t, w)
+	var latest Post
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &latest))
+	assert.Equal(t, Post{
+		ID: 1, UserID: 1, Title: "First", Body: "First writer", Version: 2, Slug: "first-post",
+		CreatedAt: time.Date(2024, 1, 1, 9, 15, 0, 0, time.UTC), UpdatedAt: now,
+	}, latest)
+}
+
+func TestUpdatePost_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		id     string
+		post   Post
+		status int
+	}{
+		{"missing version", "1", Post{Title: "No version"}, http.StatusPreconditionRequired},
+		{"unknown post", "99", Post{Title: "Nowhere", Version: 1}, http.StatusNotFound},
+		{"author's title taken", "1", Post{Title: "Second Post", Version: 1}, http.StatusConflict},
+		{"slug taken", "1", Post{Title: "Renamed", Slug: "second-post", Version: 1}, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+
+			w := putPost(t, router, tt.id, tt.post)
+
+			assert.Equal(t, tt.status, w.Code, w.Body.String())
+			post, err := server.Store.GetPost(1)
+			require.NoError(t, err)
+			assert.Equal(t, 1, post.Version, "the stored post is untouched")
+		})
+	}
 }
 
 // ========== Error Cases ==========
@@ -522,6 +750,116 @@ func TestMethodNotAllowed_WrongMethod(t *testing.T) {
 	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
 }
 
+// ========== Listener Split Tests ==========
+
+func TestRouters_SplitOperationalRoutes(t *testing.T) {
+	setupRouter()
+	server.Config.AdminToken = []byte("admin-secret")
+	api, ops := server.newAPIRouter(), server.newOpsRouter()
+
+	tests := []struct {
+		path        string
+		expectedAPI int
+		expectedOps int
+	}{
+		{"/health", http.StatusNotFound, http.StatusOK},
+		{"/health/ready", http.StatusNotFound, http.StatusOK},
+		{"/metrics", http.StatusNotFound, http.StatusOK},
+		{"/debug/pprof/", http.StatusNotFound, http.StatusOK},
+		{"/admin/flags", http.StatusNotFound, http.StatusOK},
+		{"/users", http.StatusOK, http.StatusNotFound},
+		{"/metrics/posts", http.StatusOK, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			for _, target := range []struct {
+				router   http.Handler
+				expected int
+			}{
+				{api, tt.expectedAPI},
+				{ops, tt.expectedOps},
+			} {
+				req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+				req.Header.Set("Authorization", "Bearer admin-secret")
+				w := httptest.NewRecorder()
+				target.router.ServeHTTP(w, req)
+				assert.Equal(t, target.expected, w.Code)
+			}
+		})
+	}
+}
+
+// ========== Trailing Slash Tests ==========
+
+// routedPaths lists every routed method and concrete path, without a
+// trailing slash, with {id} replaced by 1. Profiler routes are skipped:
+// /debug/pprof/profile alone samples for 30 seconds.
+func routedPaths(t *testing.T) [][2]string {
+	t.Helper()
+	var routes [][2]string
+	seen := map[[2]string]bool{}
+	err := chi.Walk(setupRouter(), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if strings.HasPrefix(route, "/debug/") {
+			return nil
+		}
+		path := strings.ReplaceAll(strings.TrimSuffix(route, "/"), "{id}", "1")
+		key := [2]string{method, path}
+		if !seen[key] {
+			seen[key] = true
+			routes = append(routes, key)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	return routes
+}
+
+func TestTrailingSlash_StripServesEveryRoute(t *testing.T) {
+	for _, route := range routedPaths(t) {
+		method, path := route[0], route[1]
+		t.Run(method+" "+path, func(t *testing.T) {
+			serve := func(target string) *httptest.ResponseRecorder {
+				setupRouter()
+				router := server.newRouter(middleware.StripSlashes)
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+				return w
+			}
+
+			plain := serve(path)
+			slashed := serve(path + "/")
+			assert.Equal(t, plain.Code, slashed.Code)
+			assert.Equal(t, plain.Header().Get("Content-Type"), slashed.Header().Get("Content-Type"))
+		})
+	}
+}
+
+func TestTrailingSlash_RedirectEveryRoute(t *testing.T) {
+	router := server.newRouter(middleware.RedirectSlashes)
+
+	for _, route := range routedPaths(t) {
+		method, path := route[0], route[1]
+		t.Run(method+" "+path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(method, path+"/?page=1", nil))
+
+			assert.Equal(t, http.StatusMovedPermanently, w.Code)
+			assert.Equal(t, "//example.com"+path+"?page=1", w.Header().Get("Location"))
+		})
+	}
+}
+
+func TestTrailingSlashMiddleware_Modes(t *testing.T) {
+	for _, mode := range []string{"strip", "redirect"} {
+		mw, err := trailingSlashMiddleware(mode)
+		require.NoError(t, err)
+		assert.NotNil(t, mw)
+	}
+	_, err := trailingSlashMiddleware("keep")
+	assert.Error(t, err)
+}
+
 func TestCreateUser_InvalidJSON_ReturnsBadRequest(t *testing.T) {
 	router := setupRouter()
 
@@ -550,6 +888,169 @@ func TestCreatePost_InvalidJSON_ReturnsBadRequest(t *testing.T) {
 	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
 }
 
+func TestCreateUser_UnknownField_ReturnsBadRequest(t *testing.T) {
+	router := setupRouter()
+
+	req := httptest.NewRequest(http.MethodPost, "/users", bytes.NewReader([]byte(`{"name":"Eve","role":"admin"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	// Bodies are decoded strictly: unknown fields are rejected
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Len(t, server.Store.ListUsers(), 2)
+}
+
+func TestUpdateUser_TrailingData_ReturnsBadRequest(t *testing.T) {
+	router := setupRouter()
+
+	req := httptest.NewRequest(http.MethodPut, "/users/1", bytes.NewReader([]byte(`{"name":"Eve","version":1} {}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCreatePost_BodyTooLarge_ReturnsRequestEntityTooLarge(t *testing.T) {
+	router := setupRouter()
+
+	body := `{"title":"big","body":"` + strings.Repeat("x", maxBodyBytes) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/posts", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assertJSONContentType(t, w)
+}
+
+// assertNotFoundProblem checks for a 404 application/problem+json response
+// for the given path.
+func assertNotFoundProblem(t *testing.T, w *httptest.ResponseRecorder, path string) {
+	t.Helper()
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+
+	var problem Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, "about:blank", problem.Type)
+	assert.Equal(t, http.StatusNotFound, problem.Status)
+	assert.Equal(t, "not found", problem.Title)
+	assert.Equal(t, path, problem.Instance)
+	assert.NotEmpty(t, problem.Detail)
+}
+
+func TestNonexistentEntities_ReturnNotFound(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{"GET user", http.MethodGet, "/users/999999", ""},
+		{"PUT user", http.MethodPut, "/users/999999", `{"name":"Ghost","version":1}`},
+		{"DELETE user", http.MethodDelete, "/users/999999", ""},
+		{"GET post", http.MethodGet, "/posts/999999", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assertNotFoundProblem(t, w, tt.path)
+		})
+	}
+}
+
+func TestDeleteUser_Twice_ReturnsNotFound(t *testing.T) {
+	router := setupRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/users/2", nil))
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/2", nil))
+	assertNotFoundProblem(t, w, "/users/2")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/users/2", nil))
+	assertNotFoundProblem(t, w, "/users/2")
+}
+
+func TestCreateDuplicates_ReturnConflict(t *testing.T) {
+	tests := []struct {
+		name             string
+		path             string
+		body             string
+		expectedResource string
+	}{
+		{"user with existing email", "/users", `{"name":"Alice 2","email":"alice@example.com"}`, "/users/1"},
+		{"user email differs only in case", "/users", `{"name":"Bob 2","email":"BOB@Example.com"}`, "/users/2"},
+		{"post with existing title and user", "/posts", `{"userId":1,"title":"Second Post"}`, "/posts/2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusConflict, w.Code)
+			assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+			assert.Empty(t, w.Header().Get("Location"))
+
+			var problem Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			assert.Equal(t, http.StatusConflict, problem.Status)
+			assert.Equal(t, tt.expectedResource, problem.Resource)
+		})
+	}
+}
+
+func TestCreatePost_SameTitleDifferentUser_IsAllowed(t *testing.T) {
+	router := setupRouter()
+
+	req := httptest.NewRequest(http.MethodPost, "/posts", strings.NewReader(`{"userId":2,"title":"First Post"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestUpdateUser_EmailTakenByAnotherUser_ReturnsConflict(t *testing.T) {
+	router := setupRouter()
+
+	req := httptest.NewRequest(http.MethodPut, "/users/2", strings.NewReader(`{"name":"Bob","email":"alice@example.com","version":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+
+	var problem Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, "/users/1", problem.Resource)
+}
+
 func TestGetUser_InvalidPathParam(t *testing.T) {
 	router := setupRouter()
 
@@ -592,7 +1093,7 @@ func TestAllEndpoints_StatusCodes(t *testing.T) {
 		{"GET /users", http.MethodGet, "/users", nil, http.StatusOK},
 		{"POST /users", http.MethodPost, "/users", User{Name: "Test", Email: "test@example.com"}, http.StatusCreated},
 		{"GET /users/1", http.MethodGet, "/users/1", nil, http.StatusOK},
-		{"PUT /users/1", http.MethodPut, "/users/1", User{Name: "Updated", Email: "updated@example.com"}, http.StatusOK},
+		{"PUT /users/1", http.MethodPut, "/users/1", User{Name: "Updated", Email: "updated@example.com", Version: 1}, http.StatusOK},
 		{"DELETE /users/1", http.MethodDelete, "/users/1", nil, http.StatusNoContent},
 		{"GET /users/1/posts", http.MethodGet, "/users/1/posts", nil, http.StatusOK},
 
@@ -730,6 +1231,7 @@ func TestConcurrentCreates_Users(t *testing.T) {
 	wg.Add(numRequests)
 
 	errors := make(chan error, numRequests)
+	ids := make(chan int, numRequests)
 
 	for i := 0; i < numRequests; i++ {
 		go func(idx int) {
@@ -762,20 +1264,34 @@ func TestConcurrentCreates_Users(t *testing.T) {
 				return
 			}
 
-			// Verify the response has an ID assigned
+			// Verify the response has an ID assigned and points at it
 			if created.ID == 0 {
 				errors <- fmt.Errorf("expected non-zero ID, got 0")
 				return
 			}
+			if want := fmt.Sprintf("/%s/%d", "users", created.ID); w.Header().Get("Location") != want {
+				errors <- fmt.Errorf("expected Location %s, got %q", want, w.Header().Get("Location"))
+				return
+			}
+			ids <- created.ID
 		}(i)
 	}
 
 	wg.Wait()
 	close(errors)
+	close(ids)
 
 	for err := range errors {
 		t.Error(err)
 	}
+
+	// Every create must get its own ID
+	seen := make(map[int]bool)
+	for id := range ids {
+		assert.False(t, seen[id], "duplicate ID %d", id)
+		seen[id] = true
+	}
+	assert.Len(t, seen, numRequests)
 }
 
 func TestConcurrentCreates_Posts(t *testing.T) {
@@ -786,6 +1302,7 @@ func TestConcurrentCreates_Posts(t *testing.T) {
 	wg.Add(numRequests)
 
 	errors := make(chan error, numRequests)
+	ids := make(chan int, numRequests)
 
 	for i := 0; i < numRequests; i++ {
 		go func(idx int) {
@@ -819,20 +1336,34 @@ func TestConcurrentCreates_Posts(t *testing.T) {
 				return
 			}
 
-			// Verify the response has an ID assigned
+			// Verify the response has an ID assigned and points at it
 			if created.ID == 0 {
 				errors <- fmt.Errorf("expected non-zero ID, got 0")
 				return
 			}
+			if want := fmt.Sprintf("/%s/%d", "posts", created.ID); w.Header().Get("Location") != want {
+				errors <- fmt.Errorf("expected Location %s, got %q", want, w.Header().Get("Location"))
+				return
+			}
+			ids <- created.ID
 		}(i)
 	}
 
 	wg.Wait()
 	close(errors)
+	close(ids)
 
 	for err := range errors {
 		t.Error(err)
 	}
+
+	// Every create must get its own ID
+	seen := make(map[int]bool)
+	for id := range ids {
+		assert.False(t, seen[id], "duplicate ID %d", id)
+		seen[id] = true
+	}
+	assert.Len(t, seen, numRequests)
 }
 
 func TestConcurrentUpdates_Users(t *testing.T) {
@@ -843,14 +1374,16 @@ func TestConcurrentUpdates_Users(t *testing.T) {
 	wg.Add(numRequests)
 
 	errors := make(chan error, numRequests)
+	statuses := make(chan int, numRequests)
 
 	for i := 0; i < numRequests; i++ {
 		go func(idx int) {
 			defer wg.Done()
 
 			user := User{
-				Name:  fmt.Sprintf("UpdatedUser%d", idx),
-				Email: fmt.Sprintf("updated%d@example.com", idx),
+				Name:    fmt.Sprintf("UpdatedUser%d", idx),
+				Email:   fmt.Sprintf("updated%d@example.com", idx),
+				Version: 1,
 			}
 			body, err := json.Marshal(user)
 			if err != nil {
@@ -858,17 +1391,19 @@ func TestConcurrentUpdates_Users(t *testing.T) {
 				return
 			}
 
-			// All goroutines update the same user ID to stress test
+			// All goroutines update the same user ID from the same version,
+			// so exactly one write may win.
 			req := httptest.NewRequest(http.MethodPut, "/users/1", bytes.NewReader(body))
 			req.Header.Set("Content-Type", "application/json")
 			w := httptest.NewRecorder()
 
 			router.ServeHTTP(w, req)
 
-			if w.Code != http.StatusOK {
-				errors <- fmt.Errorf("expected status 200, got %d", w.Code)
+			if w.Code != http.StatusOK && w.Code != http.StatusConflict {
+				errors <- fmt.Errorf("expected status 200 or 409, got %d", w.Code)
 				return
 			}
+			statuses <- w.Code
 
 			var updated User
 			if err := json.Unmarshal(w.Body.Bytes(), &updated); err != nil {
@@ -881,15 +1416,28 @@ func TestConcurrentUpdates_Users(t *testing.T) {
 				errors <- fmt.Errorf("expected ID 1, got %d", updated.ID)
 				return
 			}
+			if updated.Version != 2 {
+				errors <- fmt.Errorf("expected version 2, got %d", updated.Version)
+				return
+			}
 		}(i)
 	}
 
 	wg.Wait()
 	close(errors)
+	close(statuses)
 
 	for err := range errors {
 		t.Error(err)
 	}
+
+	succeeded := 0
+	for status := range statuses {
+		if status == http.StatusOK {
+			succeeded++
+		}
+	}
+	assert.Equal(t, 1, succeeded)
 }
 
 func TestConcurrentMixedOperations(t *testing.T) {
@@ -0,0 +1,22 @@
+package main
+
+import "net/http"
+
+// The /me routes act on the authenticated user, as the /users/{id} routes
+// do on the user in the path. requireUser guards them, so they always
+// have a Principal.
+
+func (srv *Server) getMe(w http.ResponseWriter, r *http.Request) {
+	p, _ := principalFrom(r.Context())
+	srv.users().respond(w, r, p.UserID)
+}
+
+func (srv *Server) updateMe(w http.ResponseWriter, r *http.Request) {
+	p, _ := principalFrom(r.Context())
+	srv.users().replace(w, r, p.UserID)
+}
+
+func (srv *Server) getMyPosts(w http.ResponseWriter, r *http.Request) {
+	p, _ := principalFrom(r.Context())
+	srv.respondUserPosts(w, r, p.UserID)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== Me Endpoint Tests ==========
+
+func TestGetMe(t *testing.T) {
+	router := setupRouter()
+	useAuthSecret(t)
+
+	for _, userID := range []int{1, 2} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUserRequest(userID, http.MethodGet, "/me", ""))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+		var user User
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+		assert.Equal(t, userID, user.ID)
+	}
+}
+
+func TestUpdateMe(t *testing.T) {
+	router := setupRouter()
+	useAuthSecret(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newUserRequest(2, http.MethodPut, "/me", `{"name":"Robert","email":"robert@example.com","version":1}`))
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	user, err := server.Store.GetUser(2)
+	require.NoError(t, err)
+	assert.Equal(t, "Robert", user.Name)
+	assert.Equal(t, 2, user.Version)
+}
+
+func TestUpdateMe_IgnoresBodyID(t *testing.T) {
+	router := setupRouter()
+	useAuthSecret(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newUserRequest(2, http.MethodPut, "/me", `{"id":1,"name":"Robert","email":"robert@example.com","version":1}`))
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	alice, err := server.Store.GetUser(1)
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", alice.Name)
+}
+
+func TestUpdateMe_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"stale version", `{"name":"Robert","email":"robert@example.com","version":7}`, http.StatusConflict},
+		{"missing version", `{"name":"Robert","email":"robert@example.com"}`, http.StatusPreconditionRequired},
+		{"another user's email", `{"name":"Robert","email":"alice@example.com","version":1}`, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+			useAuthSecret(t)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, newUserRequest(2, http.MethodPut, "/me", tt.body))
+
+			assert.Equal(t, tt.status, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestGetMyPosts(t *testing.T) {
+	router := setupRouter()
+	useAuthSecret(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newUserRequest(1, http.MethodGet, "/me/posts?per_page=1", ""))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-Total-Count"))
+	var posts []Post
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &posts))
+	require.Len(t, posts, 1)
+	assert.Equal(t, 1, posts[0].UserID)
+}
+
+func TestMe_RequiresUser(t *testing.T) {
+	router := setupAdminRouter(t)
+	useAuthSecret(t)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/me", nil),
+		newAdminRequest(http.MethodGet, "/me", ""),
+		newAdminRequest(http.MethodPut, "/me", `{"name":"Robert","email":"robert@example.com","version":1}`),
+		httptest.NewRequest(http.MethodGet, "/me/posts", nil),
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "%s %s", req.Method, req.URL)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// MetricBucket is the number of events in [Start, Start+interval).
+type MetricBucket struct {
+	Start time.Time `json:"start"`
+	Count int       `json:"count"`
+}
+
+// maxMetricBuckets bounds the size of a time-series response.
+const maxMetricBuckets = 1000
+
+// metricIntervals maps the accepted ?interval= values to bucket widths.
+var metricIntervals = map[string]time.Duration{
+	"hour": time.Hour,
+	"day":  24 * time.Hour,
+}
+
+// metricQuery is the parsed query of a time-series endpoint.
+type metricQuery struct {
+	From, To time.Time
+	Interval time.Duration
+}
+
+// parseMetricQuery reads ?from=, ?to= (RFC 3339) and ?interval= (hour or
+// day, default day) from r. to defaults to now and from to 30 buckets
+// before to. Bucket boundaries are aligned to UTC, so from is rounded down
+// to the start of its bucket.
+func parseMetricQuery(r *http.Request, now time.Time) (metricQuery, []string) {
+	q := r.URL.Query()
+	mq := metricQuery{Interval: metricIntervals["day"]}
+	var violations []string
+
+	if v := q.Get("interval"); v != "" {
+		d, ok := metricIntervals[v]
+		if !ok {
+			violations = append(violations, `query parameter "interval": must be one of hour, day`)
+		}
+		mq.Interval = d
+	}
+	var from, to *time.Time
+	for _, p := range []struct {
+		name string
+		dst  **time.Time
+	}{
+		{"from", &from},
+		{"to", &to},
+	} {
+		if v := q.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				violations = append(violations, fmt.Sprintf("query parameter %q: must be an RFC 3339 date-time", p.name))
+				continue
+			}
+			*p.dst = &t
+		}
+	}
+	if len(violations) > 0 {
+		return metricQuery{}, violations
+	}
+
+	mq.To = now.UTC()
+	if to != nil {
+		mq.To = to.UTC()
+	}
+	mq.From = mq.To.Add(-30 * mq.Interval)
+	if from != nil {
+		mq.From = from.UTC()
+	}
+	mq.From = mq.From.Truncate(mq.Interval)
+
+	switch {
+	case !mq.From.Before(mq.To):
+		violations = append(violations, `query parameter "from": must be earlier than to`)
+	case mq.To.Sub(mq.From) > maxMetricBuckets*mq.Interval:
+		violations = append(violations, fmt.Sprintf("query parameter %q: range spans more than %d buckets", "from", maxMetricBuckets))
+	}
+	return mq, violations
+}
+
+// bucketize counts times into consecutive buckets covering [From, To).
+// Empty buckets are included with a zero count.
+func bucketize(times []time.Time, mq metricQuery) []MetricBucket {
+	buckets := []MetricBucket{}
+	for start := mq.From; start.Before(mq.To); start = start.Add(mq.Interval) {
+		buckets = append(buckets, MetricBucket{Start: start})
+	}
+	for _, t := range times {
+		if t.Before(mq.From) || !t.Before(mq.To) {
+			continue
+		}
+		buckets[int(t.Sub(mq.From)/mq.Interval)].Count++
+	}
+	return buckets
+}
+
+// getPostMetrics serves the number of posts created per hour or day.
+func (srv *Server) getPostMetrics(w http.ResponseWriter, r *http.Request) {
+	mq, violations := parseMetricQuery(r, srv.Clock.Now())
+	if len(violations) > 0 {
+		respondProblem(w, r, http.StatusBadRequest, "invalid request", Problem{Violations: violations})
+		return
+	}
+
+	var times []time.Time
+	for _, p := range srv.requestStore(r).ListPosts() {
+		times = append(times, p.CreatedAt)
+	}
+	respondJSON(w, http.StatusOK, bucketize(times, mq))
+}
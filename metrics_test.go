@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getBuckets(t *testing.T, router http.Handler, query string) []MetricBucket {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics/posts?"+query, nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assertJSONContentType(t, w)
+
+	var buckets []MetricBucket
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &buckets))
+	return buckets
+}
+
+// ========== Post Metrics Tests ==========
+
+func TestPostMetrics_Daily(t *testing.T) {
+	// Seeded posts were created 2024-01-01T09:15Z and 2024-01-02T14:30Z.
+	router := setupRouter()
+
+	buckets := getBuckets(t, router, "from=2023-12-31T00:00:00Z&to=2024-01-04T00:00:00Z&interval=day")
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	assert.Equal(t, []MetricBucket{
+		{Start: time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC), Count: 0},
+		{Start: day(1), Count: 1},
+		{Start: day(2), Count: 1},
+		{Start: day(3), Count: 0},
+	}, buckets)
+}
+
+func TestPostMetrics_Hourly(t *testing.T) {
+	router := setupRouter()
+
+	buckets := getBuckets(t, router, "from=2024-01-01T08:30:00Z&to=2024-01-01T11:00:00Z&interval=hour")
+	require.Len(t, buckets, 3)
+	assert.Equal(t, time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC), buckets[0].Start, "from is rounded down")
+	assert.Equal(t, []int{0, 1, 0}, []int{buckets[0].Count, buckets[1].Count, buckets[2].Count})
+}
+
+func TestPostMetrics_ToIsExclusive(t *testing.T) {
+	router := setupRouter()
+
+	buckets := getBuckets(t, router, "from=2024-01-01T00:00:00Z&to=2024-01-01T09:15:00Z&interval=hour")
+	for _, b := range buckets {
+		assert.Zero(t, b.Count)
+	}
+}
+
+func TestPostMetrics_TimeZonesNormalizedToUTC(t *testing.T) {
+	router := setupRouter()
+
+	buckets := getBuckets(t, router, "from=2024-01-01T01:00:00%2B02:00&to=2024-01-02T02:00:00%2B02:00&interval=day")
+	require.Len(t, buckets, 2)
+	assert.Equal(t, time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC), buckets[0].Start)
+	assert.Equal(t, 1, buckets[1].Count)
+}
+
+func TestPostMetrics_CountsNewPosts(t *testing.T) {
+	router := setupRouter()
+	server.Store.CreatePost(Post{UserID: 2, Title: "Fresh"})
+
+	buckets := getBuckets(t, router, "interval=hour")
+	require.Len(t, buckets, 31)
+	assert.Equal(t, 1, buckets[len(buckets)-1].Count)
+}
+
+func TestPostMetrics_InvalidQuery(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		violations []string
+	}{
+		{"interval", "interval=week", []string{`query parameter "interval": must be one of hour, day`}},
+		{"from", "from=yesterday", []string{`query parameter "from": must be an RFC 3339 date-time`}},
+		{"both", "from=x&to=y", []string{
+			`query parameter "from": must be an RFC 3339 date-time`,
+			`query parameter "to": must be an RFC 3339 date-time`,
+		}},
+		{"inverted", "from=2024-02-01T00:00:00Z&to=2024-01-01T00:00:00Z", []string{`query parameter "from": must be earlier than to`}},
+		{"too many buckets", "from=2020-01-01T00:00:00Z&to=2024-01-01T00:00:00Z&interval=hour", []string{`query parameter "from": range spans more than 1000 buckets`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics/posts?"+tt.query, nil))
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			var problem Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			assert.Equal(t, tt.violations, problem.Violations)
+		})
+	}
+}
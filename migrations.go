@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// migrationFiles holds the Postgres schema migrations, applied by the
+// migrate subcommand.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// newMigrate returns a migrator applying migrationFiles to the database at
+// databaseURL, a postgres:// or postgresql:// URL.
+func newMigrate(databaseURL string) (*migrate.Migrate, error) {
+	u, err := url.Parse(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "postgres" && u.Scheme != "postgresql" {
+		return nil, fmt.Errorf("unsupported database URL scheme %q (want postgres)", u.Scheme)
+	}
+	// The migrate driver for pgx v5 registers itself as "pgx5".
+	u.Scheme = "pgx5"
+	src, err := iofs.New(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return migrate.NewWithSourceInstance("iofs", src, u.String())
+}
+
+// runMigrate implements "migrate [-seed] up|down|version" against
+// DATABASE_URL: up applies every pending migration, down reverts the last
+// one and version prints the current one. With -seed, up then replaces the
+// database's contents with the sample data the memory store starts with.
+func runMigrate(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	seed := fs.Bool("seed", false, "load the sample data after migrating up")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: migrate [-seed] up|down|version")
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if cfg.DatabaseURL == "" {
+		return errors.New("migrate: DATABASE_URL is not set")
+	}
+
+	m, err := newMigrate(cfg.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	switch fs.Arg(0) {
+	case "up":
+		if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+			return err
+		}
+	case "down":
+		if err := m.Steps(-1); err != nil {
+			return err
+		}
+	case "version":
+	default:
+		return fmt.Errorf("migrate: unknown command %q (want up, down or version)", fs.Arg(0))
+	}
+
+	version, dirty, err := m.Version()
+	switch {
+	case errors.Is(err, migrate.ErrNilVersion):
+		fmt.Fprintln(out, "no migrations applied")
+	case err != nil:
+		return err
+	case dirty:
+		fmt.Fprintf(out, "version %d (dirty)\n", version)
+	default:
+		fmt.Fprintf(out, "version %d\n", version)
+	}
+
+	if *seed && fs.Arg(0) == "up" {
+		bus := NewEventBus()
+		pg, err := newPostgresStore(context.Background(), cfg.DatabaseURL, cfg.DBPool, bus)
+		if err != nil {
+			return err
+		}
+		defer pg.Close()
+		if err := pg.restore(newMemoryStore(bus).Snapshot()); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "sample data loaded")
+	}
+	return nil
+}
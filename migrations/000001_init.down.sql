@@ -0,0 +1,11 @@
+DROP TABLE feature_flags;
+DROP TABLE tenants;
+DROP TABLE deliveries;
+DROP TABLE webhooks;
+DROP TABLE ingest_events;
+DROP TABLE places;
+DROP TABLE photos;
+DROP TABLE albums;
+DROP TABLE todos;
+DROP TABLE posts;
+DROP TABLE users;
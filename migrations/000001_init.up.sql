@@ -0,0 +1,109 @@
+-- The tables behind pgStore, one per Store collection. IDs are identity
+-- columns generated by default so Restore can insert explicit IDs.
+
+CREATE TABLE users (
+    id         integer GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    name       text NOT NULL,
+    email      text NOT NULL,
+    version    integer NOT NULL,
+    updated_at timestamptz NOT NULL
+);
+
+-- Emails are unique ignoring case; empty emails never collide.
+CREATE UNIQUE INDEX users_email_key ON users (lower(email)) WHERE email <> '';
+
+CREATE TABLE posts (
+    id         integer GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id    integer NOT NULL,
+    title      text NOT NULL,
+    body       text NOT NULL,
+    version    integer NOT NULL,
+    created_at timestamptz NOT NULL,
+    updated_at timestamptz NOT NULL
+);
+
+-- A user's post titles are unique; empty titles never collide.
+CREATE UNIQUE INDEX posts_user_title_key ON posts (user_id, title) WHERE title <> '';
+
+CREATE TABLE todos (
+    id        integer GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    title     text NOT NULL,
+    completed boolean NOT NULL,
+    priority  text NOT NULL,
+    due       timestamptz,
+    version   integer NOT NULL
+);
+
+CREATE TABLE albums (
+    id      integer GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id integer NOT NULL,
+    title   text NOT NULL,
+    version integer NOT NULL
+);
+
+CREATE TABLE photos (
+    id           integer GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    album_id     integer NOT NULL,
+    title        text NOT NULL,
+    content_type text NOT NULL,
+    width        integer NOT NULL,
+    height       integer NOT NULL,
+    version      integer NOT NULL,
+    data         bytea NOT NULL
+);
+
+CREATE INDEX photos_album_id_idx ON photos (album_id);
+
+CREATE TABLE places (
+    id   integer PRIMARY KEY,
+    name text NOT NULL,
+    lat  double precision NOT NULL,
+    lng  double precision NOT NULL
+);
+
+-- Payloads are json, not jsonb, so they are served byte for byte as
+-- received.
+CREATE TABLE ingest_events (
+    id          integer GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    received_at timestamptz NOT NULL,
+    payload     json NOT NULL
+);
+
+CREATE TABLE webhooks (
+    id      integer GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    url     text NOT NULL,
+    events  text[],
+    version integer NOT NULL
+);
+
+CREATE TABLE deliveries (
+    id               integer GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    webhook_id       integer NOT NULL,
+    event            json NOT NULL,
+    status           text NOT NULL,
+    attempts         integer NOT NULL,
+    created_at       timestamptz NOT NULL,
+    next_attempt_at  timestamptz NOT NULL,
+    last_status_code integer NOT NULL,
+    last_error       text NOT NULL
+);
+
+CREATE INDEX deliveries_webhook_id_idx ON deliveries (webhook_id);
+CREATE INDEX deliveries_due_idx ON deliveries (next_attempt_at) WHERE status = 'pending';
+
+CREATE TABLE tenants (
+    id              integer GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    name            text NOT NULL,
+    rate_limit      integer NOT NULL,
+    flags           jsonb,
+    allowed_origins text[],
+    version         integer NOT NULL
+);
+
+-- feature_flags holds a single row with the flags.Set document.
+CREATE TABLE feature_flags (
+    id    boolean PRIMARY KEY DEFAULT true CHECK (id),
+    flags jsonb NOT NULL
+);
+
+INSERT INTO feature_flags (flags) VALUES ('{}');
@@ -0,0 +1,5 @@
+-- post_count is the number of posts each user has written, kept in step by
+-- pgStore's post writes.
+ALTER TABLE users ADD COLUMN post_count integer NOT NULL DEFAULT 0;
+
+UPDATE users SET post_count = (SELECT count(*) FROM posts WHERE posts.user_id = users.id);
@@ -0,0 +1,8 @@
+-- usage counts each user's requests per UTC day, for daily quotas. Rows
+-- are not part of State, so Restore leaves them alone.
+CREATE TABLE usage (
+    user_id  integer NOT NULL,
+    day      date NOT NULL,
+    requests integer NOT NULL,
+    PRIMARY KEY (user_id, day)
+);
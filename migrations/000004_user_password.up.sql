@@ -0,0 +1,3 @@
+-- password_hash is each user's bcrypt password hash, NULL until one is
+-- set. It is never part of a User or a State.
+ALTER TABLE users ADD COLUMN password_hash bytea;
@@ -0,0 +1,3 @@
+-- verified is whether each user has confirmed their email. pgStore clears
+-- it when the email changes.
+ALTER TABLE users ADD COLUMN verified boolean NOT NULL DEFAULT false;
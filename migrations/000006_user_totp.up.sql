@@ -0,0 +1,6 @@
+-- totp_secret is each user's TOTP secret, encrypted under a key derived
+-- from AUTH_SECRET, NULL until they set up two-factor authentication;
+-- totp_enabled is set once they confirm it with a code. Neither is ever
+-- part of a User or a State.
+ALTER TABLE users ADD COLUMN totp_secret bytea;
+ALTER TABLE users ADD COLUMN totp_enabled boolean NOT NULL DEFAULT false;
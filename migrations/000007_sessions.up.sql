@@ -0,0 +1,12 @@
+-- sessions records the user tokens issued, one row each. Revoking one sets
+-- revoked_at, and the rows with it set are the revocation list every
+-- authenticated request is checked against. Rows are not part of State,
+-- so Restore leaves them alone.
+CREATE TABLE sessions (
+    id         serial PRIMARY KEY,
+    user_id    integer NOT NULL,
+    user_agent text NOT NULL DEFAULT '',
+    created_at timestamptz NOT NULL,
+    revoked_at timestamptz
+);
+CREATE INDEX sessions_user_id ON sessions (user_id);
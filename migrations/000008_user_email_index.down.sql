@@ -0,0 +1,3 @@
+DROP INDEX users_email_index_key;
+CREATE UNIQUE INDEX users_email_key ON users (lower(email)) WHERE email <> '';
+ALTER TABLE users DROP COLUMN email_index;
@@ -0,0 +1,8 @@
+-- email_index is what equality on emails is checked against, since with
+-- field encryption on, email holds ciphertext: the lowercased email while
+-- encryption is off, and a keyed hash of it (a blind index) while it is
+-- on. Emails are unique by it; empty emails never collide.
+ALTER TABLE users ADD COLUMN email_index text NOT NULL DEFAULT '';
+UPDATE users SET email_index = lower(email);
+DROP INDEX users_email_key;
+CREATE UNIQUE INDEX users_email_index_key ON users (email_index) WHERE email_index <> '';
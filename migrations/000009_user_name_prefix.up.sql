@@ -0,0 +1,3 @@
+-- Serves GET /users/suggest: prefix matches on lowercased names, in the
+-- bytewise order it returns them in.
+CREATE INDEX users_name_prefix_idx ON users (lower(name) COLLATE "C");
@@ -0,0 +1,8 @@
+-- Slugs are unique. Existing posts get one derived from the title as the
+-- server derives them, suffixed with the ID where that is taken.
+ALTER TABLE posts ADD COLUMN slug text NOT NULL DEFAULT '';
+UPDATE posts SET slug = coalesce(nullif(trim(both '-' from regexp_replace(lower(title), '[^a-z0-9]+', '-', 'g')), ''), 'post');
+UPDATE posts p SET slug = p.slug || '-' || p.id
+WHERE EXISTS (SELECT 1 FROM posts q WHERE q.slug = p.slug AND q.id < p.id);
+ALTER TABLE posts ALTER COLUMN slug DROP DEFAULT;
+CREATE UNIQUE INDEX posts_slug_key ON posts (slug);
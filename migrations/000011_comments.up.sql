@@ -0,0 +1,8 @@
+-- Comments are read-only sample data. They have no foreign keys, so that
+-- deleting a post or user leaves them be, as the memory store does.
+CREATE TABLE comments (
+    id      integer PRIMARY KEY,
+    post_id integer NOT NULL,
+    user_id integer NOT NULL,
+    body    text NOT NULL
+);
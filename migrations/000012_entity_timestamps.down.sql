@@ -0,0 +1,5 @@
+ALTER TABLE photos DROP COLUMN created_at, DROP COLUMN updated_at;
+ALTER TABLE albums DROP COLUMN created_at, DROP COLUMN updated_at;
+ALTER TABLE todos DROP COLUMN created_at, DROP COLUMN updated_at;
+ALTER TABLE comments DROP COLUMN created_at, DROP COLUMN updated_at;
+ALTER TABLE users DROP COLUMN created_at;
@@ -0,0 +1,26 @@
+-- Every entity served by the API carries created_at and updated_at, set by
+-- pgStore from its clock. Users already had updated_at, and it is the best
+-- guess at their creation time; other existing rows are stamped now.
+ALTER TABLE users ADD COLUMN created_at timestamptz;
+UPDATE users SET created_at = updated_at;
+ALTER TABLE users ALTER COLUMN created_at SET NOT NULL;
+
+ALTER TABLE comments
+    ADD COLUMN created_at timestamptz NOT NULL DEFAULT now(),
+    ADD COLUMN updated_at timestamptz NOT NULL DEFAULT now();
+ALTER TABLE comments ALTER COLUMN created_at DROP DEFAULT, ALTER COLUMN updated_at DROP DEFAULT;
+
+ALTER TABLE todos
+    ADD COLUMN created_at timestamptz NOT NULL DEFAULT now(),
+    ADD COLUMN updated_at timestamptz NOT NULL DEFAULT now();
+ALTER TABLE todos ALTER COLUMN created_at DROP DEFAULT, ALTER COLUMN updated_at DROP DEFAULT;
+
+ALTER TABLE albums
+    ADD COLUMN created_at timestamptz NOT NULL DEFAULT now(),
+    ADD COLUMN updated_at timestamptz NOT NULL DEFAULT now();
+ALTER TABLE albums ALTER COLUMN created_at DROP DEFAULT, ALTER COLUMN updated_at DROP DEFAULT;
+
+ALTER TABLE photos
+    ADD COLUMN created_at timestamptz NOT NULL DEFAULT now(),
+    ADD COLUMN updated_at timestamptz NOT NULL DEFAULT now();
+ALTER TABLE photos ALTER COLUMN created_at DROP DEFAULT, ALTER COLUMN updated_at DROP DEFAULT;
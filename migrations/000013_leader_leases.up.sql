@@ -0,0 +1,7 @@
+-- Instances sharing the database elect the one that runs the background
+-- jobs by holding a row here until expires_at, renewing it as they go.
+CREATE TABLE leader_leases (
+    name       text PRIMARY KEY,
+    holder     text NOT NULL,
+    expires_at timestamptz NOT NULL
+);
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== Migration Tests ==========
+
+func TestMigrationFiles_ComeInPairs(t *testing.T) {
+	names, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	require.NoError(t, err)
+	require.NotEmpty(t, names)
+
+	ups := map[string]bool{}
+	downs := map[string]bool{}
+	for _, name := range names {
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			ups[strings.TrimSuffix(name, ".up.sql")] = true
+		case strings.HasSuffix(name, ".down.sql"):
+			downs[strings.TrimSuffix(name, ".down.sql")] = true
+		default:
+			t.Errorf("%s: want a .up.sql or .down.sql suffix", name)
+		}
+	}
+	assert.Equal(t, ups, downs, "every migration can be reverted")
+}
+
+func TestNewMigrate_RejectsOtherSchemes(t *testing.T) {
+	_, err := newMigrate("mysql://localhost/fixture")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "mysql")
+}
+
+func TestRunMigrate_Usage(t *testing.T) {
+	t.Setenv("DATABASE_URL", "")
+	var out strings.Builder
+
+	assert.ErrorContains(t, runMigrate(nil, &out), "usage")
+	assert.ErrorContains(t, runMigrate([]string{"up"}, &out), "DATABASE_URL")
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// maxMockDepth bounds how deep mockValue follows nested and recursive
+// schemas.
+const maxMockDepth = 8
+
+// runMock implements "mock [-addr :8080] openapi.yaml": it serves stub
+// responses for every operation in an OpenAPI document instead of the live
+// API.
+func runMock(args []string) error {
+	fs := flag.NewFlagSet("mock", flag.ContinueOnError)
+	addr := fs.String("addr", ":8080", "listen address")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: mock [-addr :8080] openapi.yaml")
+	}
+
+	loader := openapi3.NewLoader()
+	loader.IsExternalRefsAllowed = true
+	doc, err := loader.LoadFromFile(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(0), err)
+	}
+	router, err := newMockRouter(doc)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(0), err)
+	}
+	return http.ListenAndServe(*addr, middleware.Logger(router))
+}
+
+// newMockRouter registers a stub route for each operation in doc. A stub
+// ignores the request and answers with the operation's first success
+// response, or the one named by a "Prefer: code=404" header; its body is
+// the media type's example (chosen with "Prefer: example=name" among
+// several), else the schema's, else a value made up from the schema.
+func newMockRouter(doc *openapi3.T) (r *chi.Mux, err error) {
+	r = chi.NewRouter()
+	r.NotFound(notFoundHandler)
+	defer func() {
+		// chi panics on patterns it cannot route, such as two parameters
+		// in one segment.
+		if p := recover(); p != nil {
+			r, err = nil, fmt.Errorf("%v", p)
+		}
+	}()
+	if doc.Paths == nil {
+		return r, nil
+	}
+	for _, path := range doc.Paths.InMatchingOrder() {
+		for method, op := range doc.Paths.Value(path).Operations() {
+			if op.Responses == nil || op.Responses.Len() == 0 {
+				return nil, fmt.Errorf("%s %s: no responses", method, path)
+			}
+			r.Method(method, path, mockOperation(op))
+		}
+	}
+	return r, nil
+}
+
+// mockOperation is the stub handler for op.
+func mockOperation(op *openapi3.Operation) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		prefer := parsePrefer(r.Header.Get("Prefer"))
+		status, resp := mockResponse(op.Responses, prefer["code"])
+		if resp == nil {
+			which := "success"
+			if code := prefer["code"]; code != "" {
+				which = code
+			}
+			respondError(w, r, http.StatusNotFound, fmt.Sprintf("no %s response is documented", which))
+			return
+		}
+		for name, h := range resp.Headers {
+			if h.Value == nil || h.Value.Schema == nil {
+				continue
+			}
+			if v, ok := h.Value.Example.(string); ok {
+				w.Header().Set(name, v)
+			} else if v := mockValue(h.Value.Schema, 0); v != nil {
+				w.Header().Set(name, fmt.Sprint(v))
+			}
+		}
+
+		mediaType, media := mockMediaType(resp.Content)
+		if media == nil {
+			w.WriteHeader(status)
+			return
+		}
+		body := mockExample(media, prefer["example"])
+		w.Header().Set("Content-Type", mediaType)
+		w.WriteHeader(status)
+		if s, ok := body.(string); ok && !strings.Contains(mediaType, "json") {
+			w.Write([]byte(s))
+			return
+		}
+		json.NewEncoder(w).Encode(body)
+	}
+}
+
+// parsePrefer reads the key=value preferences of a Prefer header
+// (RFC 7240), such as "code=404, example=empty".
+func parsePrefer(header string) map[string]string {
+	prefs := map[string]string{}
+	for _, part := range strings.FieldsFunc(header, func(r rune) bool { return r == ',' || r == ';' }) {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		prefs[strings.ToLower(key)] = strings.Trim(value, `"`)
+	}
+	return prefs
+}
+
+// mockResponse picks the response to send and its status: the response for
+// code if it is set, else the lowest 2xx response, else the default one as
+// a 200. It returns a nil response if there is none to send.
+func mockResponse(responses *openapi3.Responses, code string) (int, *openapi3.Response) {
+	if code != "" {
+		status, err := strconv.Atoi(code)
+		if err != nil {
+			return 0, nil
+		}
+		if ref := responses.Status(status); ref != nil && ref.Value != nil {
+			return status, ref.Value
+		}
+		return 0, nil
+	}
+	var codes []string
+	for key := range responses.Map() {
+		if strings.HasPrefix(key, "2") {
+			codes = append(codes, key)
+		}
+	}
+	sort.Strings(codes)
+	for _, key := range codes {
+		status, err := strconv.Atoi(strings.ReplaceAll(key, "X", "0"))
+		if ref := responses.Value(key); err == nil && ref.Value != nil {
+			return status, ref.Value
+		}
+	}
+	if ref := responses.Default(); ref != nil && ref.Value != nil {
+		return http.StatusOK, ref.Value
+	}
+	return 0, nil
+}
+
+// mockMediaType picks the media type to answer with: JSON if the response
+// has it, else the first in order.
+func mockMediaType(content openapi3.Content) (string, *openapi3.MediaType) {
+	if len(content) == 0 {
+		return "", nil
+	}
+	names := make([]string, 0, len(content))
+	for name := range content {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "application/json" || strings.HasSuffix(name, "+json") {
+			return name, content[name]
+		}
+	}
+	return names[0], content[names[0]]
+}
+
+// mockExample is the body for media: the example named name, or its
+// example, or its first example by name, or one made up from its schema.
+func mockExample(media *openapi3.MediaType, name string) any {
+	if ex := media.Examples[name]; name != "" && ex != nil && ex.Value != nil {
+		return ex.Value.Value
+	}
+	if media.Example != nil {
+		return media.Example
+	}
+	names := make([]string, 0, len(media.Examples))
+	for name, ex := range media.Examples {
+		if ex != nil && ex.Value != nil {
+			names = append(names, name)
+		}
+	}
+	if len(names) > 0 {
+		sort.Strings(names)
+		return media.Examples[names[0]].Value.Value
+	}
+	return mockValue(media.Schema, 0)
+}
+
+// mockValue makes up a value that fits ref: its example, default or first
+// enum value if it has one, else one of its type built from its
+// properties, items and format.
+func mockValue(ref *openapi3.SchemaRef, depth int) any {
+	if ref == nil || ref.Value == nil || depth > maxMockDepth {
+		return nil
+	}
+	s := ref.Value
+	switch {
+	case s.Example != nil:
+		return s.Example
+	case s.Default != nil:
+		return s.Default
+	case len(s.Enum) > 0:
+		return s.Enum[0]
+	case len(s.AllOf) > 0:
+		merged := map[string]any{}
+		for _, sub := range s.AllOf {
+			if obj, ok := mockValue(sub, depth+1).(map[string]any); ok {
+				for k, v := range obj {
+					merged[k] = v
+				}
+			}
+		}
+		return merged
+	case len(s.OneOf) > 0:
+		return mockValue(s.OneOf[0], depth+1)
+	case len(s.AnyOf) > 0:
+		return mockValue(s.AnyOf[0], depth+1)
+	}
+
+	switch {
+	case s.Type.Includes("object") || (s.Type == nil && len(s.Properties) > 0):
+		obj := map[string]any{}
+		for name, prop := range s.Properties {
+			if v := mockValue(prop, depth+1); v != nil {
+				obj[name] = v
+			}
+		}
+		return obj
+	case s.Type.Includes("array"):
+		if item := mockValue(s.Items, depth+1); item != nil {
+			return []any{item}
+		}
+		return []any{}
+	case s.Type.Includes("integer"):
+		if s.Min != nil {
+			return int64(*s.Min)
+		}
+		return 1
+	case s.Type.Includes("number"):
+		if s.Min != nil {
+			return *s.Min
+		}
+		return 1.5
+	case s.Type.Includes("boolean"):
+		return true
+	case s.Type.Includes("string"):
+		return mockString(s.Format)
+	}
+	return nil
+}
+
+// mockString is a string in format.
+func mockString(format string) string {
+	switch format {
+	case "date-time":
+		return "2024-01-01T00:00:00Z"
+	case "date":
+		return "2024-01-01"
+	case "email":
+		return "user@example.com"
+	case "uuid":
+		return "3fa85f64-5717-4562-b3fc-2c963f66afa6"
+	case "uri", "url":
+		return "https://example.com"
+	case "byte":
+		return "c3RyaW5n"
+	}
+	return "string"
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const mockSpec = `
+openapi: 3.0.3
+info: {title: Pets, version: "1"}
+paths:
+  /pets:
+    get:
+      responses:
+        "200":
+          description: The pets
+          content:
+            application/json:
+              example: [{id: 1, name: Rex}]
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema: {$ref: "#/components/schemas/Pet"}
+      responses:
+        "201":
+          description: Created
+          headers:
+            Location:
+              schema: {type: string, example: /pets/2}
+          content:
+            application/json:
+              schema: {$ref: "#/components/schemas/Pet"}
+  /pets/{petId}:
+    get:
+      parameters:
+        - {name: petId, in: path, required: true, schema: {type: integer}}
+      responses:
+        "200":
+          description: A pet
+          content:
+            application/json:
+              examples:
+                rex: {value: {id: 1, name: Rex}}
+                tom: {value: {id: 2, name: Tom}}
+        "404":
+          description: No such pet
+          content:
+            text/plain:
+              example: no such pet
+    delete:
+      parameters:
+        - {name: petId, in: path, required: true, schema: {type: integer}}
+      responses:
+        "204": {description: Deleted}
+components:
+  schemas:
+    Pet:
+      type: object
+      properties:
+        id: {type: integer, minimum: 1}
+        name: {type: string}
+        born: {type: string, format: date}
+        tags: {type: array, items: {type: string, enum: [good]}}
+        owner: {$ref: "#/components/schemas/Pet"}
+`
+
+// ========== Mock Server Tests ==========
+
+func TestMockRouter(t *testing.T) {
+	doc, err := openapi3.NewLoader().LoadFromData([]byte(mockSpec))
+	require.NoError(t, err)
+	router, err := newMockRouter(doc)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		prefer      string
+		status      int
+		contentType string
+		body        string
+	}{
+		{"example", http.MethodGet, "/pets", "", http.StatusOK, "application/json", `[{"id":1,"name":"Rex"}]`},
+		{"first named example", http.MethodGet, "/pets/7", "", http.StatusOK, "application/json", `{"id":1,"name":"Rex"}`},
+		{"preferred example", http.MethodGet, "/pets/7", "example=tom", http.StatusOK, "application/json", `{"id":2,"name":"Tom"}`},
+		{"preferred code", http.MethodGet, "/pets/7", "code=404", http.StatusNotFound, "text/plain", "no such pet"},
+		{"undocumented code", http.MethodGet, "/pets/7", "code=500", http.StatusNotFound, "application/json", ""},
+		{"no content", http.MethodDelete, "/pets/7", "", http.StatusNoContent, "", ""},
+		{"unknown path", http.MethodGet, "/owners", "", http.StatusNotFound, "application/json", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.prefer != "" {
+				req.Header.Set("Prefer", tt.prefer)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+			switch {
+			case tt.body == "":
+			case tt.contentType == "application/json":
+				assert.JSONEq(t, tt.body, w.Body.String())
+			default:
+				assert.Equal(t, tt.body, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestMockRouter_SchemaValue(t *testing.T) {
+	doc, err := openapi3.NewLoader().LoadFromData([]byte(mockSpec))
+	require.NoError(t, err)
+	router, err := newMockRouter(doc)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/pets", nil))
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/pets/2", w.Header().Get("Location"))
+	assert.Contains(t, w.Body.String(), `"id":1,"name":"string","owner":{"born":"2024-01-01"`)
+	assert.Contains(t, w.Body.String(), `"tags":["good"]`)
+}
+
+// The fixture's own spec mocks cleanly, so the mock can stand in for it.
+func TestMockRouter_OwnSpec(t *testing.T) {
+	doc, err := openapi3.NewLoader().LoadFromData(openAPISpec)
+	require.NoError(t, err)
+	router, err := newMockRouter(doc)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"email"`)
+}
@@ -0,0 +1,468 @@
+package main
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/getkin/kin-openapi/openapi3"
+
+	"github.com/api2spec/api2spec-fixture-chi/ctxkit"
+)
+
+// parseFieldNaming returns the renaming for a JSON_NAMING value: "camel",
+// "snake", or empty to keep the names the types declare.
+func parseFieldNaming(mode string) (func(string) string, error) {
+	switch mode {
+	case "":
+		return nil, nil
+	case "camel":
+		return camelCase, nil
+	case "snake":
+		return snakeCase, nil
+	}
+	return nil, fmt.Errorf("JSON_NAMING: unknown naming %q (want camel or snake)", mode)
+}
+
+// snakeCase converts a camelCase name to snake_case: postCount becomes
+// post_count and enableV2Users enable_v2_users.
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			prevLower := i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]))
+			acronymEnd := i > 0 && unicode.IsUpper(runes[i-1]) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || acronymEnd {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// camelCase converts a snake_case name to camelCase: post_count becomes
+// postCount and enable_v2_users enableV2Users.
+func camelCase(name string) string {
+	var b strings.Builder
+	upper := false
+	for _, r := range name {
+		switch {
+		case r == '_' && b.Len() > 0:
+			upper = true
+		case upper:
+			b.WriteRune(unicode.ToUpper(r))
+			upper = false
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// jsonField is one member of a renamedObject.
+type jsonField struct {
+	name  string
+	value interface{}
+}
+
+// renamedObject is a struct's JSON object with its fields renamed, in
+// declaration order.
+type renamedObject []jsonField
+
+func (o renamedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(f.name)
+		buf.Write(name)
+		buf.WriteByte(':')
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// setFieldNaming has srv name JSON fields per rename, or as declared when
+// it is nil, and serve an OpenAPI document that says so.
+func (srv *Server) setFieldNaming(rename func(string) string) {
+	srv.naming = rename
+	srv.spec = openAPISpec
+	if rename != nil {
+		spec, err := renameSpec(openAPISpec, rename)
+		if err != nil {
+			panic(err) // openAPISpec has already been parsed once
+		}
+		srv.spec = spec
+	}
+	spec := srv.spec
+	srv.specDoc = sync.OnceValues(func() (*openapi3.T, error) {
+		return openapi3.NewLoader().LoadFromData(spec)
+	})
+	specDoc := srv.specDoc
+	srv.specVersions = sync.OnceValues(func() (map[string]*openapi3.T, error) {
+		doc, err := specDoc()
+		if err != nil {
+			return nil, err
+		}
+		return specVersions(doc), nil
+	})
+}
+
+// fieldNamingKey carries the renaming nameFields applies to request bodies.
+var fieldNamingKey = ctxkit.NewKey[func(string) string]("fieldNaming")
+
+// nameFields has the JSON bodies of the server's routes named per
+// JSON_NAMING, so that names are consistently snake_case or camelCase
+// whatever their tags say: responses are written with the names, and
+// request bodies read with them. Map keys are data rather than field names
+// and are left alone, as are values that marshal themselves, such as times
+// and raw payloads.
+func (srv *Server) nameFields(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if srv.naming == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		r = r.WithContext(fieldNamingKey.With(r.Context(), srv.naming))
+		next.ServeHTTP(namingWriter{ResponseWriter: w, rename: srv.naming}, r)
+	})
+}
+
+// requestFieldNaming returns the renaming nameFields applies to r's body,
+// if any.
+func requestFieldNaming(r *http.Request) func(string) string {
+	rename, _ := fieldNamingKey.From(r.Context())
+	return rename
+}
+
+// namingWriter carries the renaming nameFields applies to writeJSON.
+type namingWriter struct {
+	http.ResponseWriter
+	rename func(string) string
+}
+
+func (w namingWriter) fieldNaming() func(string) string { return w.rename }
+
+func (w namingWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w namingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// writerFieldNaming returns the renaming carried by w, or by a writer it
+// wraps, if any.
+func writerFieldNaming(w http.ResponseWriter) func(string) string {
+	for {
+		if nw, ok := w.(interface{ fieldNaming() func(string) string }); ok {
+			return nw.fieldNaming()
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return nil
+		}
+		w = u.Unwrap()
+	}
+}
+
+// treeDecoder is a Codec that can read a body without a type to decode it
+// into, as maps, slices and scalars.
+type treeDecoder interface {
+	decodeTree(r io.Reader) (interface{}, error)
+}
+
+// decodeNamed is c.Decode for a body whose field names are passed through
+// rename, if any: the body is read as a tree, its members are given the
+// names v declares back, and the result is decoded as strictly as c would
+// have. Codecs whose names are fixed by a schema of
+// their own decode as usual.
+func decodeNamed(c Codec, r io.Reader, v interface{}, rename func(string) string) error {
+	td, ok := c.(treeDecoder)
+	if rename == nil || !ok {
+		return c.Decode(r, v)
+	}
+	tree, err := td.decodeTree(r)
+	if err != nil {
+		return err
+	}
+	tree, err = declaredNames(tree, reflect.TypeOf(v), rename)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	return jsonCodec{}.Decode(bytes.NewReader(data), v)
+}
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// declaredNames returns tree, a body to be decoded into a t, with every
+// object member named by rename given its field's declared name back. A
+// member naming no field, its declared name included, is an unknown field.
+func declaredNames(tree interface{}, t reflect.Type, rename func(string) string) (interface{}, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if pt := reflect.PointerTo(t); pt.Implements(jsonUnmarshalerType) || pt.Implements(textUnmarshalerType) {
+		return tree, nil
+	}
+	var err error
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := tree.(map[string]interface{})
+		if !ok {
+			return tree, nil
+		}
+		fields := map[string]reflect.StructField{}
+		declaredFields(t, rename, fields)
+		named := make(map[string]interface{}, len(obj))
+		for k, member := range obj {
+			sf, ok := fields[k]
+			if !ok {
+				return nil, fmt.Errorf("json: unknown field %q", k)
+			}
+			name, _ := fieldName(sf)
+			if named[name], err = declaredNames(member, sf.Type, rename); err != nil {
+				return nil, err
+			}
+		}
+		return named, nil
+	case reflect.Slice, reflect.Array:
+		list, ok := tree.([]interface{})
+		if !ok {
+			return tree, nil
+		}
+		for i, elem := range list {
+			if list[i], err = declaredNames(elem, t.Elem(), rename); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	case reflect.Map:
+		obj, ok := tree.(map[string]interface{})
+		if !ok {
+			return tree, nil
+		}
+		for k, member := range obj {
+			if obj[k], err = declaredNames(member, t.Elem(), rename); err != nil {
+				return nil, err
+			}
+		}
+		return obj, nil
+	}
+	return tree, nil
+}
+
+// declaredFields adds the fields a struct of type t is decoded into to
+// fields, by their renamed names, flattening embedded structs as
+// structFields does.
+func declaredFields(t reflect.Type, rename func(string) string, fields map[string]reflect.StructField) {
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if name, _, _ := strings.Cut(sf.Tag.Get("json"), ","); sf.Anonymous && name == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, ft)
+				continue
+			}
+		}
+		if name, ok := fieldName(sf); ok {
+			fields[rename(name)] = sf
+		}
+	}
+	for _, et := range embedded {
+		inner := map[string]reflect.StructField{}
+		declaredFields(et, rename, inner)
+		for name, sf := range inner {
+			if _, ok := fields[name]; !ok {
+				fields[name] = sf
+			}
+		}
+	}
+}
+
+// renamed returns v with its field names passed through rename, if any.
+func renamed(v interface{}, rename func(string) string) interface{} {
+	if rename == nil {
+		return v
+	}
+	return renameFields(v, rename)
+}
+
+// renameFields returns a value that marshals like v but with every struct
+// field name passed through rename.
+func renameFields(v interface{}, rename func(string) string) interface{} {
+	if v == nil {
+		return nil
+	}
+	return renameValue(reflect.ValueOf(v), rename)
+}
+
+func renameValue(v reflect.Value, rename func(string) string) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	t := v.Type()
+	if t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) {
+		return v.Interface()
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return renameValue(v.Elem(), rename)
+	case reflect.Struct:
+		return structFields(v, rename)
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 {
+			return v.Interface() // base64, as encoding/json does
+		}
+		fallthrough
+	case reflect.Array:
+		list := make([]interface{}, v.Len())
+		for i := range list {
+			list[i] = renameValue(v.Index(i), rename)
+		}
+		return list
+	case reflect.Map:
+		if v.IsNil() || t.Key().Kind() != reflect.String {
+			return v.Interface()
+		}
+		m := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[iter.Key().String()] = renameValue(iter.Value(), rename)
+		}
+		return m
+	}
+	return v.Interface()
+}
+
+// structFields returns v's exported fields as a renamedObject, flattening
+// embedded structs as encoding/json does: a field declared by the outer
+// struct hides an embedded one of the same name.
+func structFields(v reflect.Value, rename func(string) string) renamedObject {
+	t := v.Type()
+	outer := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		if name, ok := fieldName(t.Field(i)); ok {
+			outer[rename(name)] = true
+		}
+	}
+
+	obj := renamedObject{}
+	for i := 0; i < t.NumField(); i++ {
+		sf, fv := t.Field(i), v.Field(i)
+		if inner, ok := embeddedStruct(sf, fv); ok {
+			for _, f := range structFields(inner, rename) {
+				if !outer[f.name] {
+					obj = append(obj, f)
+				}
+			}
+			continue
+		}
+		name, ok := fieldName(sf)
+		if !ok {
+			continue
+		}
+		if _, opts, _ := strings.Cut(sf.Tag.Get("json"), ","); strings.Contains(opts, "omitempty") && isEmptyValue(fv) {
+			continue
+		}
+		obj = append(obj, jsonField{name: rename(name), value: renameValue(fv, rename)})
+	}
+	return obj
+}
+
+// fieldName returns the JSON name of a field that is marshaled under its
+// own name, and false for unexported, ignored and flattened fields.
+func fieldName(sf reflect.StructField) (string, bool) {
+	tag := sf.Tag.Get("json")
+	name, _, _ := strings.Cut(tag, ",")
+	if tag == "-" || !sf.IsExported() && !sf.Anonymous {
+		return "", false
+	}
+	if sf.Anonymous && name == "" {
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct || !sf.IsExported() {
+			return "", false
+		}
+	}
+	if name == "" {
+		name = sf.Name
+	}
+	return name, true
+}
+
+// embeddedStruct returns the struct an untagged embedded field flattens
+// into its parent, if sf is one and is not a nil pointer.
+func embeddedStruct(sf reflect.StructField, fv reflect.Value) (reflect.Value, bool) {
+	tag := sf.Tag.Get("json")
+	if name, _, _ := strings.Cut(tag, ","); !sf.Anonymous || name != "" || tag == "-" {
+		return reflect.Value{}, false
+	}
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return reflect.Value{}, false
+		}
+		fv = fv.Elem()
+	}
+	return fv, fv.Kind() == reflect.Struct
+}
+
+// isEmptyValue reports whether v is empty in the sense of omitempty.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
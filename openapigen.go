@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3gen"
+
+	"github.com/api2spec/api2spec-fixture-chi/flags"
+)
+
+// schemaNames overrides the component schema name of Go types whose name
+// would be ambiguous in the document.
+var schemaNames = map[reflect.Type]string{
+	reflect.TypeOf(flags.Set{}): "Flags",
+}
+
+var rawJSONType = reflect.TypeOf(json.RawMessage{})
+
+// schemaName is the component schema name for the Go type t.
+func schemaName(t reflect.Type) string {
+	if name, ok := schemaNames[t]; ok {
+		return name
+	}
+	return t.Name()
+}
+
+// typeName describes a RouteDef body for GET /_routes: a schema name, "[]"
+// and the item schema's name for arrays, a MediaType as is, MediaTypes
+// joined by ", ", "JSON" for any JSON document, and "" for no body.
+func typeName(body interface{}) string {
+	if body == nil {
+		return ""
+	}
+	switch body := body.(type) {
+	case MediaType:
+		return string(body)
+	case MediaTypes:
+		names := make([]string, len(body))
+		for i, mt := range body {
+			names[i] = string(mt)
+		}
+		return strings.Join(names, ", ")
+	}
+	t := reflect.TypeOf(body)
+	switch {
+	case t == rawJSONType:
+		return "JSON"
+	case t.Kind() == reflect.Slice:
+		return "[]" + schemaName(t.Elem())
+	}
+	return schemaName(t)
+}
+
+var pathParamPattern = regexp.MustCompile(`\{(\w+)\}`)
+
+// generateSpec builds an OpenAPI document from defs alone: every route's
+// operation ID, tag, summary and path parameters, its request body and the
+// bodies of its ResponseTypes, with JSON schemas generated from the Go
+// types. Error responses and query parameters are not in the table, so the
+// result is a skeleton of openapi.yaml rather than a replacement for it.
+func generateSpec(defs []RouteDef) (*openapi3.T, error) {
+	doc := &openapi3.T{
+		OpenAPI:    "3.0.3",
+		Info:       &openapi3.Info{Title: "API", Version: "1.0.0"},
+		Paths:      openapi3.NewPaths(),
+		Components: &openapi3.Components{Schemas: openapi3.Schemas{}},
+	}
+
+	for _, d := range defs {
+		op := openapi3.NewOperation()
+		op.OperationID = d.OperationID
+		op.Tags = []string{d.Tag}
+		op.Summary = d.Summary
+		op.Deprecated = d.Deprecated != nil
+		for _, m := range pathParamPattern.FindAllStringSubmatch(d.Pattern, -1) {
+			param := openapi3.NewPathParameter(m[1]).WithSchema(openapi3.NewStringSchema())
+			op.AddParameter(param)
+		}
+		if d.RequestType != nil {
+			content, err := bodyContent(d.RequestType, doc.Components.Schemas)
+			if err != nil {
+				return nil, err
+			}
+			addCodecContent(content, d.Codecs)
+			op.RequestBody = &openapi3.RequestBodyRef{Value: openapi3.NewRequestBody().WithRequired(true).WithContent(content)}
+		}
+		op.Responses = openapi3.NewResponsesWithCapacity(len(d.ResponseTypes))
+		for status, body := range d.ResponseTypes {
+			resp := openapi3.NewResponse().WithDescription(http.StatusText(status))
+			if body != nil {
+				content, err := bodyContent(body, doc.Components.Schemas)
+				if err != nil {
+					return nil, err
+				}
+				if status < 400 {
+					addCodecContent(content, d.Codecs)
+				}
+				resp.WithContent(content)
+			}
+			op.Responses.Set(strconv.Itoa(status), &openapi3.ResponseRef{Value: resp})
+		}
+
+		item := doc.Paths.Value(d.Pattern)
+		if item == nil {
+			item = &openapi3.PathItem{}
+			doc.Paths.Set(d.Pattern, item)
+		}
+		item.SetOperation(d.Method, op)
+	}
+	return doc, nil
+}
+
+// bodyContent describes body. Struct types, and the items of slices of
+// them, become component schemas in schemas; types nested inside them are
+// inlined.
+func bodyContent(body interface{}, schemas openapi3.Schemas) (openapi3.Content, error) {
+	switch body := body.(type) {
+	case MediaType:
+		return openapi3.Content{string(body): openapi3.NewMediaType()}, nil
+	case MediaTypes:
+		content := openapi3.Content{}
+		for _, mt := range body {
+			content[string(mt)] = openapi3.NewMediaType()
+		}
+		return content, nil
+	}
+	t := reflect.TypeOf(body)
+	if t == rawJSONType {
+		return openapi3.Content{"application/json": openapi3.NewMediaType()}, nil
+	}
+	if t.Kind() == reflect.Slice {
+		items, err := componentRef(t.Elem(), schemas)
+		if err != nil {
+			return nil, err
+		}
+		array := openapi3.NewArraySchema()
+		array.Items = items
+		return openapi3.NewContentWithJSONSchema(array), nil
+	}
+	ref, err := componentRef(t, schemas)
+	if err != nil {
+		return nil, err
+	}
+	return openapi3.NewContentWithJSONSchemaRef(ref), nil
+}
+
+// addCodecContent offers a JSON body of content in each of codecs too, with
+// the same schema; HTML pages are strings.
+func addCodecContent(content openapi3.Content, codecs []Codec) {
+	if json := content.Get("application/json"); json != nil {
+		for _, c := range codecs {
+			if _, ok := c.(htmlCodec); ok {
+				content[c.MediaType()] = openapi3.NewMediaType().WithSchema(openapi3.NewStringSchema())
+				continue
+			}
+			content[c.MediaType()] = json
+		}
+	}
+}
+
+// componentRef adds the schema of t to schemas under schemaName(t) and
+// returns a reference to it.
+func componentRef(t reflect.Type, schemas openapi3.Schemas) (*openapi3.SchemaRef, error) {
+	name := schemaName(t)
+	if _, ok := schemas[name]; !ok {
+		schema, err := openapi3gen.NewSchemaRefForValue(reflect.Zero(t).Interface(), nil)
+		if err != nil {
+			return nil, err
+		}
+		schemas[name] = schema
+	}
+	return openapi3.NewSchemaRef("#/components/schemas/"+name, nil), nil
+}
+
+// runGenSpec writes the document generated from the route tables to w as
+// JSON.
+func runGenSpec(w io.Writer) error {
+	// Only the route tables' metadata is read, so they need no working
+	// server behind their handlers.
+	var srv Server
+	doc, err := generateSpec(append(srv.apiRouteDefs(), srv.opsRouteDefs()...))
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
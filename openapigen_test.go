@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== Spec Generator Tests ==========
+
+func TestGenerateSpec_IsValid(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, runGenSpec(&buf))
+
+	doc, err := openapi3.NewLoader().LoadFromData(buf.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, doc.Validate(context.Background()))
+	assert.Equal(t, len(server.apiRouteDefs())+len(server.opsRouteDefs()), countOperations(doc))
+}
+
+func TestTypeName(t *testing.T) {
+	tests := []struct {
+		body     interface{}
+		expected string
+	}{
+		{nil, ""},
+		{User{}, "User"},
+		{[]Post{}, "[]Post"},
+		{MediaType("image/png"), "image/png"},
+		{MediaTypes{"multipart/form-data", "text/csv"}, "multipart/form-data, text/csv"},
+		{rawJSON, "JSON"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, typeName(tt.body))
+	}
+}
+
+// schemaProperties lists the property names of ref's schema, sorted.
+func schemaProperties(ref *openapi3.SchemaRef) []string {
+	var names []string
+	for name := range ref.Value.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// bodySchemaName is the component schema a JSON body refers to, prefixed
+// with "[]" for an array of them.
+func bodySchemaName(mt *openapi3.MediaType) string {
+	if mt == nil || mt.Schema == nil {
+		return ""
+	}
+	if mt.Schema.Ref == "" && mt.Schema.Value.Items != nil {
+		return "[]" + refName(mt.Schema.Value.Items.Ref)
+	}
+	return refName(mt.Schema.Ref)
+}
+
+func refName(ref string) string {
+	const prefix = "#/components/schemas/"
+	if len(ref) > len(prefix) {
+		return ref[len(prefix):]
+	}
+	return ""
+}
+
+// TestGenerateSpec_MatchesServedSpec holds the route table and the
+// hand-written openapi.yaml in step: summaries, deprecations, body media
+// types, the schemas bodies refer to, and those schemas' properties must
+// agree.
+func TestGenerateSpec_MatchesServedSpec(t *testing.T) {
+	served, _ := loadServedSpec(t, setupRouter())
+	generated, err := generateSpec(append(server.apiRouteDefs(), server.opsRouteDefs()...))
+	require.NoError(t, err)
+
+	for _, d := range append(server.apiRouteDefs(), server.opsRouteDefs()...) {
+		name := d.Method + " " + d.Pattern
+		want := served.Paths.Find(d.Pattern).GetOperation(d.Method)
+		got := generated.Paths.Find(d.Pattern).GetOperation(d.Method)
+		require.NotNil(t, want, name)
+		assert.Equal(t, d.Summary, want.Summary, name)
+		assert.Equal(t, d.Deprecated != nil, want.Deprecated, "%s: deprecated", name)
+
+		if d.RequestType != nil {
+			require.NotNil(t, want.RequestBody, name)
+			for mediaType, content := range got.RequestBody.Value.Content {
+				wantContent := want.RequestBody.Value.Content.Get(mediaType)
+				if assert.NotNil(t, wantContent, "%s: request body %s", name, mediaType) {
+					assert.Equal(t, bodySchemaName(content), bodySchemaName(wantContent), "%s: request body", name)
+				}
+			}
+		}
+		for status := range d.ResponseTypes {
+			code := strconv.Itoa(status)
+			wantResp := want.Responses.Value(code)
+			require.NotNil(t, wantResp, "%s: %s response", name, code)
+			for mediaType, content := range got.Responses.Value(code).Value.Content {
+				wantContent := wantResp.Value.Content.Get(mediaType)
+				if assert.NotNil(t, wantContent, "%s: %s response %s", name, code, mediaType) {
+					assert.Equal(t, bodySchemaName(content), bodySchemaName(wantContent), "%s: %s response", name, code)
+				}
+			}
+		}
+	}
+
+	for schemaName, ref := range generated.Components.Schemas {
+		wantRef := served.Components.Schemas[schemaName]
+		if assert.NotNil(t, wantRef, "schema %s", schemaName) {
+			assert.Equal(t, schemaProperties(wantRef), schemaProperties(ref), "schema %s", schemaName)
+		}
+	}
+}
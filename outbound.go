@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// The breaker guarding each upstream opens after outboundBreakerThreshold
+// failed calls in a row and tries again after outboundBreakerCooldown.
+const (
+	outboundBreakerThreshold = 5
+	outboundBreakerCooldown  = 30 * time.Second
+	// outboundBackoff is the wait before a call's first retry, doubling
+	// with each further one.
+	outboundBackoff = 100 * time.Millisecond
+)
+
+// errCircuitOpen is the cause of an upstreamError for a call refused,
+// without sending it, while the upstream's breaker is open.
+var errCircuitOpen = errors.New("circuit breaker open")
+
+// upstreamError is a call to an external service that failed: it could
+// not be sent, was refused by the breaker, or the service answered with
+// a status the caller cannot use.
+type upstreamError struct {
+	Upstream string
+	// Status is the service's answer, if it gave one.
+	Status int
+	Err    error
+}
+
+func (e *upstreamError) Error() string {
+	if e.Status != 0 {
+		return fmt.Sprintf("%s answered %d", e.Upstream, e.Status)
+	}
+	err := e.Err
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		if uerr.Timeout() {
+			return e.Upstream + " timed out"
+		}
+		// Leave out the URL.
+		err = uerr.Err
+	}
+	return fmt.Sprintf("%s: %v", e.Upstream, err)
+}
+
+func (e *upstreamError) Unwrap() error { return e.Err }
+
+// respondUpstreamError reports that an external service the request
+// depends on failed.
+func respondUpstreamError(w http.ResponseWriter, r *http.Request, err error) {
+	respondProblem(w, r, http.StatusBadGateway, "bad gateway", Problem{Detail: err.Error()})
+}
+
+// outboundClient is the HTTP client shared by every call to an external
+// service. Calls are bounded by OUTBOUND_TIMEOUT, retries included, and
+// retried while the service cannot be reached or answers 502, 503 or 504.
+// Each service, or upstream, has its own circuit breaker, which refuses
+// calls after repeated failures, and its own counters for /metrics.
+type outboundClient struct {
+	cfg OutboundConfig
+	log logFunc
+
+	mu        sync.Mutex
+	upstreams map[string]*outboundUpstream
+}
+
+// outboundUpstream is the client, breaker and counters for one upstream.
+type outboundUpstream struct {
+	client  *http.Client
+	breaker *breaker
+
+	mu       sync.Mutex
+	outcomes map[string]int64
+	retries  int64
+	seconds  float64
+	calls    int64
+}
+
+func newOutboundClient(cfg OutboundConfig) *outboundClient {
+	return &outboundClient{cfg: cfg, upstreams: map[string]*outboundUpstream{}}
+}
+
+// upstream returns name's state, creating it on first use.
+func (c *outboundClient) upstream(name string) *outboundUpstream {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	u, ok := c.upstreams[name]
+	if !ok {
+		u = &outboundUpstream{breaker: newBreaker(outboundBreakerThreshold, outboundBreakerCooldown), outcomes: map[string]int64{}}
+		u.client = &http.Client{
+			Timeout: c.cfg.Timeout,
+			Transport: retryTransport{
+				next:    http.DefaultTransport,
+				retries: c.cfg.Retries,
+				backoff: outboundBackoff,
+				retried: u.retried,
+			},
+		}
+		c.upstreams[name] = u
+	}
+	return u
+}
+
+// do sends req to upstream. It returns an *upstreamError, having closed
+// any body, when the breaker refuses the call, the upstream cannot be
+// reached or it answers 500 or above after the retries; any other answer
+// is the caller's to read and close.
+func (c *outboundClient) do(upstream string, req *http.Request) (*http.Response, error) {
+	u := c.upstream(upstream)
+	if !u.breaker.allow() {
+		u.record("rejected", 0)
+		return nil, &upstreamError{Upstream: upstream, Err: errCircuitOpen}
+	}
+	start := time.Now()
+	resp, err := u.client.Do(req)
+	elapsed := time.Since(start)
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		// The caller went away; that says nothing about the upstream.
+		u.breaker.record(false)
+		u.record("cancelled", elapsed)
+		return nil, err
+	case err != nil:
+		u.breaker.record(true)
+		u.record("failed", elapsed)
+		c.log.at("warn", "outbound %s: %v", upstream, err)
+		return nil, &upstreamError{Upstream: upstream, Err: err}
+	case resp.StatusCode >= 500:
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		u.breaker.record(true)
+		u.record("failed", elapsed)
+		c.log.at("warn", "outbound %s: answered %d", upstream, resp.StatusCode)
+		return nil, &upstreamError{Upstream: upstream, Status: resp.StatusCode}
+	}
+	u.breaker.record(false)
+	u.record("ok", elapsed)
+	return resp, nil
+}
+
+func (u *outboundUpstream) retried() {
+	u.mu.Lock()
+	u.retries++
+	u.mu.Unlock()
+}
+
+func (u *outboundUpstream) record(outcome string, elapsed time.Duration) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.outcomes[outcome]++
+	if outcome != "rejected" {
+		u.calls++
+		u.seconds += elapsed.Seconds()
+	}
+}
+
+// upstreamStats is one upstream's counters, as /metrics reports them.
+type upstreamStats struct {
+	Name     string
+	Outcomes map[string]int64
+	Retries  int64
+	Calls    int64
+	Seconds  float64
+	Breaker  string
+}
+
+// snapshot returns each upstream's counters, by name.
+func (c *outboundClient) snapshot() []upstreamStats {
+	c.mu.Lock()
+	names := make([]string, 0, len(c.upstreams))
+	for name := range c.upstreams {
+		names = append(names, name)
+	}
+	c.mu.Unlock()
+	sort.Strings(names)
+
+	out := make([]upstreamStats, 0, len(names))
+	for _, name := range names {
+		u := c.upstream(name)
+		u.mu.Lock()
+		s := upstreamStats{Name: name, Outcomes: make(map[string]int64, len(u.outcomes)), Retries: u.retries, Calls: u.calls, Seconds: u.seconds}
+		for k, v := range u.outcomes {
+			s.Outcomes[k] = v
+		}
+		u.mu.Unlock()
+		s.Breaker = u.breaker.State()
+		out = append(out, s)
+	}
+	return out
+}
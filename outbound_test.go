@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== Outbound Client Tests ==========
+
+func TestOutboundClient_Retries(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer ts.Close()
+	c := newOutboundClient(OutboundConfig{Retries: 2})
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	resp, err := c.do("teapot", req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusTeapot, resp.StatusCode, "answers below 500 are the caller's")
+
+	stats := c.snapshot()
+	require.Len(t, stats, 1)
+	assert.Equal(t, "teapot", stats[0].Name)
+	assert.Equal(t, map[string]int64{"ok": 1}, stats[0].Outcomes)
+	assert.EqualValues(t, 2, stats[0].Retries)
+	assert.EqualValues(t, 1, stats[0].Calls)
+	assert.Equal(t, breakerClosed, stats[0].Breaker)
+}
+
+func TestOutboundClient_Breaker(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+	c := newOutboundClient(OutboundConfig{})
+	clock := &fakeClock{t: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	c.upstream("flaky").breaker.now = clock.Now
+
+	call := func() error {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+		_, err := c.do("flaky", req)
+		return err
+	}
+	for i := 0; i < outboundBreakerThreshold; i++ {
+		var uerr *upstreamError
+		require.ErrorAs(t, call(), &uerr)
+		assert.Equal(t, http.StatusInternalServerError, uerr.Status)
+	}
+	err := call()
+	assert.ErrorIs(t, err, errCircuitOpen)
+	assert.Equal(t, "flaky: circuit breaker open", err.Error())
+	assert.EqualValues(t, outboundBreakerThreshold, calls.Load(), "an open breaker sends nothing")
+
+	clock.t = clock.t.Add(outboundBreakerCooldown)
+	assert.Error(t, call(), "the trial call fails too")
+	assert.EqualValues(t, outboundBreakerThreshold+1, calls.Load())
+
+	stats := c.snapshot()[0]
+	assert.Equal(t, map[string]int64{"failed": 6, "rejected": 1}, stats.Outcomes)
+	assert.Equal(t, breakerOpen, stats.Breaker)
+}
+
+func TestOutboundClient_CallerGoneIsNoFailure(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer ts.Close()
+	defer close(release)
+	c := newOutboundClient(OutboundConfig{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	_, err := c.do("slow", req)
+	assert.True(t, errors.Is(err, context.Canceled), "%v", err)
+	assert.Equal(t, map[string]int64{"cancelled": 1}, c.snapshot()[0].Outcomes)
+}
+
+func TestUpstreamError(t *testing.T) {
+	c := newOutboundClient(OutboundConfig{})
+	ts := httptest.NewServer(nil)
+	url := ts.URL
+	ts.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, url+"/secret-path", nil)
+	_, err := c.do("gone", req)
+	require.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "gone: "), err.Error())
+	assert.NotContains(t, err.Error(), "secret-path", "the URL stays out of problem details")
+}
+
+func TestPrometheus_Outbound(t *testing.T) {
+	router := setupAdminRouter(t)
+	useFakeGravatar(t)
+	req := newAdminRequest(http.MethodGet, "/users/1/gravatar", "")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodGet, "/metrics", ""))
+	body := w.Body.String()
+	assert.Contains(t, body, `outbound_requests_total{upstream="gravatar",outcome="ok"} 1`)
+	assert.Contains(t, body, `outbound_retries_total{upstream="gravatar"} 0`)
+	assert.Contains(t, body, `outbound_request_duration_seconds_count{upstream="gravatar"} 1`)
+	assert.Contains(t, body, `outbound_breaker_open{upstream="gravatar"} 0`)
+}
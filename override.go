@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// headerMethodOverride carries the intended method of a tunneled request.
+const headerMethodOverride = "X-HTTP-Method-Override"
+
+// overridableMethods are the methods a POST may be tunneled as.
+var overridableMethods = map[string]bool{
+	http.MethodPut:    true,
+	http.MethodPatch:  true,
+	http.MethodDelete: true,
+}
+
+// methodOverride lets clients behind proxies that only pass GET and POST
+// tunnel other methods through POST, via the X-HTTP-Method-Override header
+// or a ?_method= query parameter (the header wins). Only POST requests are
+// rewritten; an override to anything but PUT, PATCH, or DELETE is a 400.
+// The override is consumed, so routing and handlers see a plain request.
+func methodOverride(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		query := r.URL.Query()
+		method := r.Header.Get(headerMethodOverride)
+		if method == "" {
+			method = query.Get("_method")
+		}
+		if method == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		method = strings.ToUpper(method)
+		if !overridableMethods[method] {
+			respondError(w, r, http.StatusBadRequest, "invalid method override")
+			return
+		}
+
+		r = r.WithContext(r.Context())
+		r.Method = method
+		r.Header = r.Header.Clone()
+		r.Header.Del(headerMethodOverride)
+		if query.Has("_method") {
+			u := *r.URL
+			query.Del("_method")
+			u.RawQuery = query.Encode()
+			r.URL = &u
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== Method Override Tests ==========
+
+func TestMethodOverride_Header(t *testing.T) {
+	router := setupRouter()
+
+	req := httptest.NewRequest(http.MethodPost, "/users/1", strings.NewReader(`{"name":"Alicia","email":"alicia@example.com","version":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(headerMethodOverride, "PUT")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var user User
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+	assert.Equal(t, "Alicia", user.Name)
+	assert.Equal(t, 2, user.Version)
+}
+
+func TestMethodOverride_QueryParam(t *testing.T) {
+	router := setupRouter()
+
+	req := httptest.NewRequest(http.MethodPost, "/users/2?_method=delete", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	_, err := server.Store.GetUser(2)
+	assert.ErrorIs(t, err, errNotFound)
+}
+
+func TestMethodOverride_QueryParamIsConsumed(t *testing.T) {
+	router := setupRouter()
+	var seen *http.Request
+	router.With(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = r
+			next.ServeHTTP(w, r)
+		})
+	}).Delete("/probe", func(w http.ResponseWriter, r *http.Request) {})
+
+	req := httptest.NewRequest(http.MethodPost, "/probe?_method=DELETE&keep=1", nil)
+	req.Header.Set(headerMethodOverride, "DELETE")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	require.NotNil(t, seen)
+	assert.Equal(t, http.MethodDelete, seen.Method)
+	assert.Equal(t, "keep=1", seen.URL.RawQuery)
+	assert.Empty(t, seen.Header.Get(headerMethodOverride))
+	assert.Equal(t, "DELETE", req.Header.Get(headerMethodOverride), "the caller's request is not modified")
+}
+
+func TestMethodOverride_HeaderWinsOverQuery(t *testing.T) {
+	router := setupRouter()
+
+	req := httptest.NewRequest(http.MethodPost, "/users/2?_method=PUT", nil)
+	req.Header.Set(headerMethodOverride, "DELETE")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestMethodOverride_OnlyFromPost(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		expected int
+	}{
+		{"GET header ignored", http.MethodGet, "/users/1", http.StatusOK},
+		{"GET query ignored", http.MethodGet, "/users/1?_method=DELETE", http.StatusOK},
+		{"PUT header ignored", http.MethodPut, "/users/1", http.StatusPreconditionRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(`{}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(headerMethodOverride, "DELETE")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expected, w.Code)
+			_, err := server.Store.GetUser(1)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestMethodOverride_UnsupportedTarget(t *testing.T) {
+	for _, method := range []string{"GET", "HEAD", "CONNECT", "bogus"} {
+		t.Run(method, func(t *testing.T) {
+			router := setupRouter()
+			req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":"X"}`))
+			req.Header.Set(headerMethodOverride, method)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), "invalid method override")
+			assert.Len(t, server.Store.ListUsers(), 2)
+		})
+	}
+}
+
+func TestMethodOverride_BeforeRequestValidation(t *testing.T) {
+	router := setupValidatedRouter(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/users/1?_method=PUT", strings.NewReader(`{"name":5,"version":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "request body /name")
+}
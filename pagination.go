@@ -0,0 +1,212 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+var errInvalidPagination = errors.New("invalid pagination")
+
+// pageParams is a 1-based page request.
+type pageParams struct {
+	Page    int
+	PerPage int
+}
+
+// parsePageParams reads ?page= and ?per_page= from r, applying defaults.
+// page must be >= 1 and per_page between 1 and maxPerPage.
+func parsePageParams(r *http.Request) (pageParams, error) {
+	p := pageParams{Page: 1, PerPage: defaultPerPage}
+	q := r.URL.Query()
+
+	if v := q.Get("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return pageParams{}, errInvalidPagination
+		}
+		p.Page = n
+	}
+	if v := q.Get("per_page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPerPage {
+			return pageParams{}, errInvalidPagination
+		}
+		p.PerPage = n
+	}
+	return p, nil
+}
+
+// paginate returns the requested page of items. Pages past the end are
+// empty, never nil, so they encode as [].
+func paginate[T any](items []T, p pageParams) []T {
+	start := (p.Page - 1) * p.PerPage
+	if start >= len(items) {
+		return []T{}
+	}
+	end := start + p.PerPage
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[start:end]
+}
+
+// rangeUnit is the Range unit of collection routes: items counted from 0.
+const rangeUnit = "items"
+
+// itemsRange is a Range: items=First-Last request. Last is inclusive, and
+// -1 for a range with no end. A Suffix range asks for the last Suffix
+// items.
+type itemsRange struct {
+	First, Last int
+	Suffix      int
+}
+
+// parseItemsRange reads a single "items" range from r's Range header, such
+// as items=0-9, items=10- or items=-5. ok is false when there is none, and
+// for other units, several ranges and malformed values, which RFC 7233
+// lets a server ignore.
+func parseItemsRange(r *http.Request) (rng itemsRange, ok bool) {
+	spec, found := strings.CutPrefix(r.Header.Get("Range"), rangeUnit+"=")
+	if !found || strings.Contains(spec, ",") {
+		return itemsRange{}, false
+	}
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return itemsRange{}, false
+	}
+	if first == "" {
+		n, err := strconv.Atoi(last)
+		if err != nil || n < 1 {
+			return itemsRange{}, false
+		}
+		return itemsRange{Suffix: n}, true
+	}
+	rng.First, rng.Last = -1, -1
+	if n, err := strconv.Atoi(first); err == nil && n >= 0 {
+		rng.First = n
+	}
+	if last != "" {
+		n, err := strconv.Atoi(last)
+		if err != nil || n < rng.First {
+			return itemsRange{}, false
+		}
+		rng.Last = n
+	}
+	return rng, rng.First >= 0
+}
+
+// bounds returns the indexes of the first and last of total items rng
+// selects, at most maxPerPage of them. ok is false when it selects none.
+func (rng itemsRange) bounds(total int) (first, last int, ok bool) {
+	if rng.Suffix > 0 {
+		first = max(total-rng.Suffix, 0)
+		last = total - 1
+	} else {
+		first, last = rng.First, rng.Last
+		if last < 0 || last >= total {
+			last = total - 1
+		}
+	}
+	if first >= total {
+		return 0, 0, false
+	}
+	return first, min(last, first+maxPerPage-1), true
+}
+
+// respondItems writes items as a JSON array. A request with a Range header
+// in the items unit gets just those items, at most maxPerPage of them, as
+// 206 Partial Content with a Content-Range header, or 416 when the range
+// starts past the end.
+func respondItems[T any](w http.ResponseWriter, r *http.Request, items []T) {
+	w.Header().Set("Accept-Ranges", rangeUnit)
+	rng, ok := parseItemsRange(r)
+	if !ok {
+		respondJSON(w, http.StatusOK, items)
+		return
+	}
+	first, last, ok := rng.bounds(len(items))
+	if !ok {
+		w.Header().Set("Content-Range", fmt.Sprintf("%s */%d", rangeUnit, len(items)))
+		respondProblem(w, r, http.StatusRequestedRangeNotSatisfiable, "range not satisfiable", Problem{
+			Detail: fmt.Sprintf("the collection has %d items", len(items)),
+		})
+		return
+	}
+	w.Header().Set("Content-Range", fmt.Sprintf("%s %d-%d/%d", rangeUnit, first, last, len(items)))
+	respondJSON(w, http.StatusPartialContent, items[first:last+1])
+}
+
+// Page is a collection response while PAGE_ENVELOPE is set: one page of
+// the collection and where to find the others.
+type Page[T any] struct {
+	Items      []T       `json:"items"`
+	Page       int       `json:"page"`
+	PerPage    int       `json:"per_page"`
+	Total      int       `json:"total"`
+	TotalPages int       `json:"total_pages"`
+	Links      PageLinks `json:"links"`
+}
+
+// PageLinks are the paths of a Page and its neighbours, keeping the
+// request's other query parameters. Next is empty on the last page and
+// Prev on the first.
+type PageLinks struct {
+	Self string `json:"self"`
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+// newPage returns the page of items p selects, linked from r's URL.
+func newPage[T any](r *http.Request, items []T, p pageParams) Page[T] {
+	totalPages := (len(items) + p.PerPage - 1) / p.PerPage
+	page := Page[T]{
+		Items:      paginate(items, p),
+		Page:       p.Page,
+		PerPage:    p.PerPage,
+		Total:      len(items),
+		TotalPages: totalPages,
+		Links:      PageLinks{Self: pageLink(r, p.Page, p.PerPage)},
+	}
+	if p.Page < totalPages {
+		page.Links.Next = pageLink(r, p.Page+1, p.PerPage)
+	}
+	if p.Page > 1 {
+		// Past the end, the previous page is the last one there is.
+		page.Links.Prev = pageLink(r, min(p.Page-1, max(totalPages, 1)), p.PerPage)
+	}
+	return page
+}
+
+// pageLink returns r's path and query with page and per_page replaced.
+func pageLink(r *http.Request, page, perPage int) string {
+	q := r.URL.Query()
+	q.Set("page", strconv.Itoa(page))
+	q.Set("per_page", strconv.Itoa(perPage))
+	return r.URL.Path + "?" + q.Encode()
+}
+
+// respondList writes items, a whole collection, for a list route. With
+// envelope set, and no Range header, it sends the Page that ?page= and
+// ?per_page= select, or 400 for invalid ones; otherwise it is
+// respondItems.
+func respondList[T any](w http.ResponseWriter, r *http.Request, items []T, envelope bool) {
+	if _, ok := parseItemsRange(r); !envelope || ok {
+		respondItems(w, r, items)
+		return
+	}
+	p, err := parsePageParams(r)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, "invalid pagination")
+		return
+	}
+	w.Header().Set("Accept-Ranges", rangeUnit)
+	respondJSON(w, http.StatusOK, newPage(r, items, p))
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== Range Header Tests ==========
+
+func TestParseItemsRange(t *testing.T) {
+	tests := []struct {
+		header string
+		want   itemsRange
+		ok     bool
+	}{
+		{"items=0-9", itemsRange{First: 0, Last: 9}, true},
+		{"items=5-5", itemsRange{First: 5, Last: 5}, true},
+		{"items=10-", itemsRange{First: 10, Last: -1}, true},
+		{"items=-5", itemsRange{Suffix: 5}, true},
+		{"", itemsRange{}, false},
+		{"bytes=0-9", itemsRange{}, false},
+		{"items=9-0", itemsRange{}, false},
+		{"items=0-9,20-29", itemsRange{}, false},
+		{"items=-0", itemsRange{}, false},
+		{"items=a-b", itemsRange{}, false},
+		{"items=-", itemsRange{}, false},
+		{"items=5", itemsRange{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			req.Header.Set("Range", tt.header)
+			got, ok := parseItemsRange(req)
+			assert.Equal(t, tt.ok, ok)
+			if tt.ok {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestItemsRange_Bounds(t *testing.T) {
+	tests := []struct {
+		name        string
+		rng         itemsRange
+		total       int
+		first, last int
+		ok          bool
+	}{
+		{"within", itemsRange{First: 0, Last: 9}, 57, 0, 9, true},
+		{"clipped to the end", itemsRange{First: 50, Last: 99}, 57, 50, 56, true},
+		{"open-ended", itemsRange{First: 10, Last: -1}, 57, 10, 56, true},
+		{"at most maxPerPage", itemsRange{First: 0, Last: 499}, 500, 0, maxPerPage - 1, true},
+		{"suffix", itemsRange{Suffix: 5}, 57, 52, 56, true},
+		{"suffix longer than the collection", itemsRange{Suffix: 100}, 3, 0, 2, true},
+		{"past the end", itemsRange{First: 57, Last: 60}, 57, 0, 0, false},
+		{"empty collection", itemsRange{First: 0, Last: 9}, 0, 0, 0, false},
+		{"suffix of empty collection", itemsRange{Suffix: 5}, 0, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, last, ok := tt.rng.bounds(tt.total)
+			assert.Equal(t, tt.ok, ok)
+			if tt.ok {
+				assert.Equal(t, tt.first, first)
+				assert.Equal(t, tt.last, last)
+			}
+		})
+	}
+}
+
+func TestRespondItems(t *testing.T) {
+	tests := []struct {
+		name         string
+		path         string
+		rangeHeader  string
+		status       int
+		contentRange string
+		ids          []int
+	}{
+		{"no range", "/users", "", http.StatusOK, "", []int{1, 2}},
+		{"first item", "/users", "items=0-0", http.StatusPartialContent, "items 0-0/2", []int{1}},
+		{"suffix", "/posts", "items=-1", http.StatusPartialContent, "items 1-1/2", []int{2}},
+		{"open-ended", "/todos", "items=1-", http.StatusPartialContent, "items 1-2/3", []int{2, 3}},
+		{"other unit ignored", "/users", "bytes=0-0", http.StatusOK, "", []int{1, 2}},
+		{"replaces page", "/users/1/posts?page=2&per_page=1", "items=0-1", http.StatusPartialContent, "items 0-1/2", []int{1, 2}},
+		{"past the end", "/users", "items=2-5", http.StatusRequestedRangeNotSatisfiable, "items */2", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.rangeHeader != "" {
+				req.Header.Set("Range", tt.rangeHeader)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tt.status, w.Code, w.Body.String())
+			assert.Equal(t, "items", w.Header().Get("Accept-Ranges"))
+			assert.Equal(t, tt.contentRange, w.Header().Get("Content-Range"))
+			if tt.ids == nil {
+				assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+				return
+			}
+			var items []struct {
+				ID int `json:"id"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &items))
+			ids := make([]int, len(items))
+			for i, item := range items {
+				ids[i] = item.ID
+			}
+			assert.Equal(t, tt.ids, ids)
+		})
+	}
+}
+
+// ========== Page Envelope Tests ==========
+
+func TestRespondList_Envelope(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		want   PageLinks
+		total  int
+		pages  int
+		ids    []int
+	}{
+		{"defaults", "/users", http.StatusOK, PageLinks{Self: "/users?page=1&per_page=20"}, 2, 1, []int{1, 2}},
+		{"first page", "/todos?per_page=2", http.StatusOK, PageLinks{
+			Self: "/todos?page=1&per_page=2",
+			Next: "/todos?page=2&per_page=2",
+		}, 3, 2, []int{1, 2}},
+		{"last page keeps the query", "/todos?completed=false&page=2&per_page=1", http.StatusOK, PageLinks{
+			Self: "/todos?completed=false&page=2&per_page=1",
+			Prev: "/todos?completed=false&page=1&per_page=1",
+		}, 2, 2, []int{3}},
+		{"past the end", "/posts?page=5", http.StatusOK, PageLinks{
+			Self: "/posts?page=5&per_page=20",
+			Prev: "/posts?page=1&per_page=20",
+		}, 2, 1, []int{}},
+		{"user posts", "/users/1/posts?per_page=1", http.StatusOK, PageLinks{
+			Self: "/users/1/posts?page=1&per_page=1",
+			Next: "/users/1/posts?page=2&per_page=1",
+		}, 2, 2, []int{1}},
+		{"invalid", "/albums?per_page=0", http.StatusBadRequest, PageLinks{}, 0, 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+			server.Config.PageEnvelope = true
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			require.Equal(t, tt.status, w.Code, w.Body.String())
+			if tt.ids == nil {
+				return
+			}
+			var page Page[struct {
+				ID int `json:"id"`
+			}]
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+			assert.Equal(t, tt.want, page.Links)
+			assert.Equal(t, tt.total, page.Total)
+			assert.Equal(t, tt.pages, page.TotalPages)
+			ids := []int{}
+			for _, item := range page.Items {
+				ids = append(ids, item.ID)
+			}
+			assert.Equal(t, tt.ids, ids)
+		})
+	}
+}
+
+func TestRespondList_EnvelopeRangeWins(t *testing.T) {
+	router := setupRouter()
+	server.Config.PageEnvelope = true
+	req := httptest.NewRequest(http.MethodGet, "/users?page=2", nil)
+	req.Header.Set("Range", "items=0-0")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusPartialContent, w.Code)
+	var users []User
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &users))
+	require.Len(t, users, 1)
+	assert.Equal(t, 1, users[0].ID)
+}
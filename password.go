@@ -0,0 +1,237 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Passwords are at least minPasswordLength bytes, and at most
+// maxPasswordLength, the most bcrypt reads.
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72
+)
+
+// passwordCost is the bcrypt cost passwords are hashed at.
+var passwordCost = bcrypt.DefaultCost
+
+// passwordResetTTL is how long a password reset token is good for.
+const passwordResetTTL = time.Hour
+
+// validatePassword returns the ways password, the body member at pointer,
+// is unacceptable.
+func validatePassword(pointer, password string) []string {
+	switch {
+	case len(password) < minPasswordLength:
+		return []string{"request body " + pointer + ": must be at least " + strconv.Itoa(minPasswordLength) + " bytes"}
+	case len(password) > maxPasswordLength:
+		return []string{"request body " + pointer + ": must be at most " + strconv.Itoa(maxPasswordLength) + " bytes"}
+	}
+	return nil
+}
+
+// hashPassword returns password's bcrypt hash at passwordCost.
+func hashPassword(password string) ([]byte, error) {
+	return bcrypt.GenerateFromPassword([]byte(password), passwordCost)
+}
+
+// PasswordChange is the body of POST /users/{id}/password.
+type PasswordChange struct {
+	// CurrentPassword is required once the user has a password.
+	CurrentPassword string `json:"currentPassword" redact:"secret"`
+	NewPassword     string `json:"newPassword" redact:"secret"`
+}
+
+// changePassword sets the password of the user in the path, who must be
+// the caller.
+func (srv *Server) changePassword(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	if p, _ := principalFrom(r.Context()); p.UserID != id {
+		respondError(w, r, http.StatusForbidden, "forbidden")
+		return
+	}
+	var change PasswordChange
+	if err := decodeJSON(r, &change); err != nil {
+		respondDecodeError(w, r, err)
+		return
+	}
+	if violations := validatePassword("/newPassword", change.NewPassword); len(violations) > 0 {
+		respondProblem(w, r, http.StatusBadRequest, "invalid request", Problem{Violations: violations})
+		return
+	}
+	current, err := srv.requestStore(r).PasswordHash(id)
+	if err != nil {
+		respondNotFound(w, r, "user", id)
+		return
+	}
+	if current != nil && bcrypt.CompareHashAndPassword(current, []byte(change.CurrentPassword)) != nil {
+		respondError(w, r, http.StatusForbidden, "incorrect password")
+		return
+	}
+	srv.setPassword(w, r, id, change.NewPassword)
+}
+
+// setPassword stores password as user id's and answers 204.
+func (srv *Server) setPassword(w http.ResponseWriter, r *http.Request, id int, password string) {
+	hash, err := hashPassword(password)
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+	switch err := srv.requestStore(r).SetPasswordHash(id, hash); {
+	case errors.Is(err, errNotFound):
+		respondNotFound(w, r, "user", id)
+		return
+	case err != nil:
+		respondError(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// passwordResetToken returns a token letting userID, whose password hash
+// is hash, choose a new password within passwordResetTTL. It covers the
+// hash, so it stops working once it, or anything else, has changed the
+// password.
+func (srv *Server) passwordResetToken(userID int, hash []byte) string {
+	return srv.expiringToken("password-reset", userID, passwordResetTTL, hash)
+}
+
+// parsePasswordResetToken returns the user token lets reset their
+// password.
+func (srv *Server) parsePasswordResetToken(s Store, token string) (int, bool) {
+	return srv.parseExpiringToken("password-reset", token, s.PasswordHash)
+}
+
+// Login is the body of POST /auth/login.
+type Login struct {
+	Email    string `json:"email" redact:"email"`
+	Password string `json:"password" redact:"secret"`
+	// Code is the current TOTP code, required once the user has enabled
+	// two-factor authentication.
+	Code string `json:"code,omitempty" redact:"secret"`
+}
+
+// login returns a user token for the user with the email and password in
+// the body. Unknown emails and wrong passwords get the same answer.
+func (srv *Server) login(w http.ResponseWriter, r *http.Request) {
+	if len(srv.Config.AuthSecret) == 0 {
+		respondError(w, r, http.StatusServiceUnavailable, "user tokens are not configured")
+		return
+	}
+	var creds Login
+	if err := decodeJSON(r, &creds); err != nil {
+		respondDecodeError(w, r, err)
+		return
+	}
+	var hash []byte
+	userID := 0
+	for _, u := range srv.requestStore(r).ListUsers() {
+		if u.Email != "" && strings.EqualFold(u.Email, creds.Email) {
+			userID = u.ID
+			hash, _ = srv.requestStore(r).PasswordHash(u.ID)
+			break
+		}
+	}
+	if hash == nil || bcrypt.CompareHashAndPassword(hash, []byte(creds.Password)) != nil {
+		respondError(w, r, http.StatusUnauthorized, "invalid credentials")
+		return
+	}
+	tf, err := srv.twoFactor(srv.requestStore(r), userID)
+	if err != nil {
+		respondError(w, r, http.StatusUnauthorized, "invalid credentials")
+		return
+	}
+	if tf.Enabled && creds.Code == "" {
+		respondError(w, r, http.StatusUnauthorized, "two-factor code required")
+		return
+	}
+	if ok, err := srv.checkTwoFactor(tf, creds.Code); err != nil || !ok {
+		respondError(w, r, http.StatusUnauthorized, "invalid two-factor code")
+		return
+	}
+	srv.respondSession(w, r, userID)
+}
+
+// PasswordResetRequest is the body of POST /auth/password-reset.
+type PasswordResetRequest struct {
+	Email string `json:"email" redact:"email"`
+}
+
+// requestPasswordReset mails a reset token to the user with the email in
+// the body. It answers 202 whether or not there is such a user, so that
+// it cannot be used to find out who has an account.
+func (srv *Server) requestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	if len(srv.Config.AuthSecret) == 0 {
+		respondError(w, r, http.StatusServiceUnavailable, "user tokens are not configured")
+		return
+	}
+	confirm, ok := srv.publicLink(w, r, "/auth/password-reset/confirm")
+	if !ok {
+		return
+	}
+	var req PasswordResetRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondDecodeError(w, r, err)
+		return
+	}
+	if strings.TrimSpace(req.Email) == "" {
+		respondProblem(w, r, http.StatusBadRequest, "invalid request", Problem{Violations: []string{"request body /email: is required"}})
+		return
+	}
+	for _, u := range srv.requestStore(r).ListUsers() {
+		if !strings.EqualFold(u.Email, req.Email) {
+			continue
+		}
+		hash, err := srv.requestStore(r).PasswordHash(u.ID)
+		if err != nil {
+			break
+		}
+		err = srv.Mailer.Send(Mail{
+			To:      u.Email,
+			Subject: "Reset your password",
+			Body: "To choose a new password, POST it with this token to " + confirm +
+				" within " + passwordResetTTL.String() + ":\n\n" + srv.passwordResetToken(u.ID, hash) + "\n",
+		})
+		if err != nil {
+			// The answer must not tell whether the email is known.
+			srv.logAt("warn", "password reset: mail to user %d: %v", u.ID, err)
+		}
+		break
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// PasswordReset is the body of POST /auth/password-reset/confirm.
+type PasswordReset struct {
+	Token    string `json:"token" redact:"secret"`
+	Password string `json:"password" redact:"secret"`
+}
+
+// confirmPasswordReset sets a new password with a token mailed by
+// requestPasswordReset. Each token works once.
+func (srv *Server) confirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var reset PasswordReset
+	if err := decodeJSON(r, &reset); err != nil {
+		respondDecodeError(w, r, err)
+		return
+	}
+	if violations := validatePassword("/password", reset.Password); len(violations) > 0 {
+		respondProblem(w, r, http.StatusBadRequest, "invalid request", Problem{Violations: violations})
+		return
+	}
+	userID, ok := srv.parsePasswordResetToken(srv.requestStore(r), reset.Token)
+	if !ok {
+		respondError(w, r, http.StatusBadRequest, "invalid reset token")
+		return
+	}
+	srv.setPassword(w, r, userID, reset.Password)
+}
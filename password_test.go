@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// usePasswords sets up user tokens and cheap password hashing, and returns
+// the mail sent during the test.
+func usePasswords(t *testing.T) *[]Mail {
+	t.Helper()
+	useAuthSecret(t)
+	cost := passwordCost
+	passwordCost = bcrypt.MinCost
+	t.Cleanup(func() { passwordCost = cost })
+	return useTestMailer(t)
+}
+
+// setTestPassword gives user id password directly in the store.
+func setTestPassword(t *testing.T, id int, password string) {
+	t.Helper()
+	hash, err := hashPassword(password)
+	require.NoError(t, err)
+	require.NoError(t, server.Store.SetPasswordHash(id, hash))
+}
+
+// assertPassword checks that user id's password is password.
+func assertPassword(t *testing.T, id int, password string) {
+	t.Helper()
+	hash, err := server.Store.PasswordHash(id)
+	require.NoError(t, err)
+	assert.NoError(t, bcrypt.CompareHashAndPassword(hash, []byte(password)))
+}
+
+// mailedResetToken requests a password reset for email and returns the
+// token mailed.
+func mailedResetToken(t *testing.T, router http.Handler, sent *[]Mail, email string) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/auth/password-reset", `{"email":"`+email+`"}`))
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	require.NotEmpty(t, *sent)
+	lines := strings.Split(strings.TrimSpace((*sent)[len(*sent)-1].Body), "\n")
+	return lines[len(lines)-1]
+}
+
+// ========== Login Tests ==========
+
+func TestLogin(t *testing.T) {
+	router := setupRouter()
+	usePasswords(t)
+	setTestPassword(t, 1, "correct horse")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/auth/login", `{"email":"ALICE@example.com","password":"correct horse"}`))
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var token UserToken
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &token))
+	assert.Equal(t, 1, token.UserID)
+	p, ok := server.parseUserToken(token.Token)
+	require.True(t, ok)
+	assert.Equal(t, 1, p.UserID)
+	sessions := server.Store.ListSessions(1)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, sessions[0].ID, p.SessionID)
+}
+
+func TestLogin_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret bool
+		body   string
+		status int
+	}{
+		{"wrong password", true, `{"email":"alice@example.com","password":"battery staple"}`, http.StatusUnauthorized},
+		{"unknown email", true, `{"email":"nobody@example.com","password":"correct horse"}`, http.StatusUnauthorized},
+		{"no password set", true, `{"email":"bob@example.com","password":""}`, http.StatusUnauthorized},
+		{"invalid json", true, `{`, http.StatusBadRequest},
+		{"no secret", false, `{"email":"alice@example.com","password":"correct horse"}`, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+			usePasswords(t)
+			setTestPassword(t, 1, "correct horse")
+			if !tt.secret {
+				server.Config.AuthSecret = nil
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/auth/login", tt.body))
+
+			assert.Equal(t, tt.status, w.Code, w.Body.String())
+		})
+	}
+}
+
+// ========== Change Password Tests ==========
+
+func TestChangePassword(t *testing.T) {
+	router := setupRouter()
+	usePasswords(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newUserRequest(1, http.MethodPost, "/users/1/password", `{"newPassword":"correct horse"}`))
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	assertPassword(t, 1, "correct horse")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newUserRequest(1, http.MethodPost, "/users/1/password", `{"currentPassword":"correct horse","newPassword":"battery staple"}`))
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	assertPassword(t, 1, "battery staple")
+}
+
+func TestChangePassword_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		userID int
+		path   string
+		body   string
+		status int
+	}{
+		{"another user", 2, "/users/1/password", `{"currentPassword":"old password","newPassword":"new password"}`, http.StatusForbidden},
+		{"wrong current password", 1, "/users/1/password", `{"currentPassword":"guess","newPassword":"new password"}`, http.StatusForbidden},
+		{"missing current password", 1, "/users/1/password", `{"newPassword":"new password"}`, http.StatusForbidden},
+		{"too short", 1, "/users/1/password", `{"currentPassword":"old password","newPassword":"short"}`, http.StatusBadRequest},
+		{"too long", 1, "/users/1/password", `{"currentPassword":"old password","newPassword":"` + strings.Repeat("x", 73) + `"}`, http.StatusBadRequest},
+		{"invalid json", 1, "/users/1/password", `{`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+			usePasswords(t)
+			setTestPassword(t, 1, "old password")
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, newUserRequest(tt.userID, http.MethodPost, tt.path, tt.body))
+
+			assert.Equal(t, tt.status, w.Code, w.Body.String())
+			assertPassword(t, 1, "old password")
+		})
+	}
+}
+
+func TestChangePassword_RequiresUser(t *testing.T) {
+	router := setupRouter()
+	usePasswords(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/users/1/password", strings.NewReader(`{"newPassword":"new password"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestPasswordHash_NeverInResponses(t *testing.T) {
+	router := setupRouter()
+	usePasswords(t)
+	setTestPassword(t, 1, "secret password")
+	hash, err := server.Store.PasswordHash(1)
+	require.NoError(t, err)
+
+	for _, path := range []string{"/users", "/users/1", "/me"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUserRequest(1, http.MethodGet, path, ""))
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), string(hash), path)
+		assert.NotContains(t, strings.ToLower(w.Body.String()), "password", path)
+	}
+}
+
+// ========== Password Reset Tests ==========
+
+func TestPasswordReset(t *testing.T) {
+	router := setupRouter()
+	sent := usePasswords(t)
+	setTestPassword(t, 1, "forgotten password")
+
+	token := mailedResetToken(t, router, sent, "ALICE@example.com")
+	assert.Equal(t, "alice@example.com", (*sent)[0].To)
+	assert.Contains(t, (*sent)[0].Body, testPublicURL+"/auth/password-reset/confirm")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/auth/password-reset/confirm", `{"token":"`+token+`","password":"remembered now"}`))
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	assertPassword(t, 1, "remembered now")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/auth/password-reset/confirm", `{"token":"`+token+`","password":"and again"}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code, "tokens work once")
+	assertPassword(t, 1, "remembered now")
+}
+
+func TestPasswordReset_IgnoresHost(t *testing.T) {
+	router := setupRouter()
+	sent := usePasswords(t)
+	setTestPassword(t, 1, "forgotten password")
+
+	req := newAdminRequest(http.MethodPost, "/auth/password-reset", `{"email":"alice@example.com"}`)
+	req.Host = "evil.example"
+	req.Header.Set("X-Forwarded-Host", "evil.example")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusAccepted, w.Code)
+	require.Len(t, *sent, 1)
+	assert.Contains(t, (*sent)[0].Body, testPublicURL+"/auth/password-reset/confirm")
+	assert.NotContains(t, (*sent)[0].Body, "evil.example")
+}
+
+func TestPasswordReset_NoPublicURL(t *testing.T) {
+	router := setupRouter()
+	sent := usePasswords(t)
+	server.Config.PublicURL = ""
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/auth/password-reset", `{"email":"alice@example.com"}`))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Empty(t, *sent, "no link is mailed from the request's Host")
+}
+
+func TestPasswordReset_UnknownEmail(t *testing.T) {
+	router := setupRouter()
+	sent := usePasswords(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/auth/password-reset", `{"email":"nobody@example.com"}`))
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Empty(t, *sent)
+}
+
+func TestPasswordReset_Expired(t *testing.T) {
+	router := setupRouter()
+	sent := usePasswords(t)
+	clock := freezeClock(time.Now())
+
+	token := mailedResetToken(t, router, sent, "bob@example.com")
+	clock.t = clock.t.Add(passwordResetTTL)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/auth/password-reset/confirm", `{"token":"`+token+`","password":"too late now"}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestPasswordReset_InvalidTokens(t *testing.T) {
+	router := setupRouter()
+	sent := usePasswords(t)
+	token := mailedResetToken(t, router, sent, "bob@example.com")
+	parts := strings.Split(token, ".")
+
+	for _, bad := range []string{
+		"",
+		"garbage",
+		"1." + parts[1] + "." + parts[2],
+		parts[0] + ".9999999999." + parts[2],
+		parts[0] + "." + parts[1] + ".AAAA",
+		server.userToken(2, 1),
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/auth/password-reset/confirm", `{"token":"`+bad+`","password":"hijacked it"}`))
+		assert.Equal(t, http.StatusBadRequest, w.Code, bad)
+	}
+	hash, err := server.Store.PasswordHash(2)
+	require.NoError(t, err)
+	assert.Nil(t, hash)
+}
+
+func TestPasswordReset_NotConfigured(t *testing.T) {
+	router := setupRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/auth/password-reset", `{"email":"alice@example.com"}`))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type Album struct {
+	ID      int    `json:"id"`
+	UserID  int    `json:"userId"`
+	Title   string `json:"title"`
+	Version int    `json:"version"`
+	// CreatedAt and UpdatedAt are set by the store and ignored on writes.
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Photo is a photo's metadata. The image itself is kept by the store and
+// only served as a thumbnail.
+type Photo struct {
+	ID          int    `json:"id"`
+	AlbumID     int    `json:"albumId"`
+	Title       string `json:"title"`
+	ContentType string `json:"contentType"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Version     int    `json:"version"`
+	// CreatedAt and UpdatedAt are set by the store and ignored on writes.
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Thumbnail dimensions, in pixels.
+const (
+	defaultThumbnailSize = 128
+	minThumbnailSize     = 16
+	maxThumbnailSize     = 512
+
+	// maxPhotoPixels bounds uploads by decoded size, so a small compressed
+	// file cannot expand into an enormous image.
+	maxPhotoPixels = 4096 * 4096
+)
+
+// samplePhoto draws the gradient used for the seeded photo.
+func samplePhoto() []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 64; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 4), G: uint8(y * 5), B: 160, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+// parseThumbnailSize reads ?size= (a square box) or ?width= and ?height=
+// from r. Each must be between minThumbnailSize and maxThumbnailSize; an
+// omitted dimension takes the other's value, or the default.
+func parseThumbnailSize(r *http.Request) (width, height int, violations []string) {
+	q := r.URL.Query()
+	dims := map[string]int{}
+	for _, name := range []string{"size", "width", "height"} {
+		v := q.Get(name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < minThumbnailSize || n > maxThumbnailSize {
+			violations = append(violations, fmt.Sprintf("query parameter %q: must be an integer between %d and %d", name, minThumbnailSize, maxThumbnailSize))
+			continue
+		}
+		dims[name] = n
+	}
+	if len(violations) > 0 {
+		return 0, 0, violations
+	}
+
+	width, height = defaultThumbnailSize, defaultThumbnailSize
+	if n, ok := dims["size"]; ok {
+		width, height = n, n
+	}
+	w, hasW := dims["width"]
+	h, hasH := dims["height"]
+	switch {
+	case hasW && hasH:
+		width, height = w, h
+	case hasW:
+		width, height = w, w
+	case hasH:
+		width, height = h, h
+	}
+	return width, height, nil
+}
+
+// thumbnail scales img to fit within width×height, keeping its aspect
+// ratio, using nearest-neighbor sampling.
+func thumbnail(img image.Image, width, height int) image.Image {
+	b := img.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	dw, dh := width, sh*width/sw
+	if dh > height {
+		dw, dh = sw*height/sh, height
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			dst.Set(x, y, img.At(b.Min.X+x*sw/dw, b.Min.Y+y*sh/dh))
+		}
+	}
+	return dst
+}
+
+func (srv *Server) getAlbumPhotos(w http.ResponseWriter, r *http.Request) {
+	albumID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	if _, err := srv.requestStore(r).GetAlbum(albumID); err != nil {
+		respondNotFound(w, r, "album", albumID)
+		return
+	}
+	respondList(w, r, srv.requestStore(r).ListPhotosByAlbum(albumID), srv.Config.PageEnvelope)
+}
+
+// uploadPhoto accepts a multipart/form-data body with a "file" part holding
+// a PNG, JPEG, or GIF image and an optional "title" field.
+func (srv *Server) uploadPhoto(w http.ResponseWriter, r *http.Request) {
+	albumID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	if _, err := srv.requestStore(r).GetAlbum(albumID); err != nil {
+		respondNotFound(w, r, "album", albumID)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	if err := r.ParseMultipartForm(maxBodyBytes); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			respondError(w, r, http.StatusRequestEntityTooLarge, "request body too large")
+			return
+		}
+		respondError(w, r, http.StatusBadRequest, "invalid request")
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, "invalid request")
+		return
+	}
+	defer file.Close()
+
+	var data bytes.Buffer
+	if _, err := data.ReadFrom(file); err != nil {
+		respondError(w, r, http.StatusBadRequest, "invalid request")
+		return
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data.Bytes()))
+	if err != nil || cfg.Width*cfg.Height > maxPhotoPixels {
+		respondError(w, r, http.StatusBadRequest, "invalid image")
+		return
+	}
+
+	photo, err := srv.requestStore(r).CreatePhoto(Photo{
+		AlbumID:     albumID,
+		Title:       r.FormValue("title"),
+		ContentType: "image/" + format,
+		Width:       cfg.Width,
+		Height:      cfg.Height,
+	}, data.Bytes())
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+	w.Header().Set("Location", "/photos/"+strconv.Itoa(photo.ID))
+	respondJSON(w, http.StatusCreated, photo)
+}
+
+func (srv *Server) getPhoto(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	photo, err := srv.requestStore(r).GetPhoto(id)
+	if err != nil {
+		respondNotFound(w, r, "photo", id)
+		return
+	}
+	respondJSON(w, http.StatusOK, photo)
+}
+
+func (srv *Server) getPhotoThumbnail(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	width, height, violations := parseThumbnailSize(r)
+	if len(violations) > 0 {
+		respondProblem(w, r, http.StatusBadRequest, "invalid request", Problem{Violations: violations})
+		return
+	}
+	data, err := srv.requestStore(r).PhotoData(id)
+	if err != nil {
+		respondNotFound(w, r, "photo", id)
+		return
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, thumbnail(img, width, height)); err != nil {
+		respondError(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
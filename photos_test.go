@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newUploadRequest builds a multipart photo upload for albumPath.
+func newUploadRequest(t *testing.T, albumPath, title string, file []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if title != "" {
+		require.NoError(t, mw.WriteField("title", title))
+	}
+	part, err := mw.CreateFormFile("file", "photo.png")
+	require.NoError(t, err)
+	_, err = part.Write(file)
+	require.NoError(t, err)
+	require.NoError(t, mw.Close())
+
+	req := httptest.NewRequest(http.MethodPost, albumPath, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))))
+	return buf.Bytes()
+}
+
+// ========== Album Tests ==========
+
+func TestAlbums_CreateAndGet(t *testing.T) {
+	router := setupRouter()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	freezeClock(now)
+
+	req := httptest.NewRequest(http.MethodPost, "/albums", strings.NewReader(`{"userId":2,"title":"Pets"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/albums/2", w.Header().Get("Location"))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/albums/2", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var album Album
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &album))
+	assert.Equal(t, Album{ID: 2, UserID: 2, Title: "Pets", Version: 1, CreatedAt: now, UpdatedAt: now}, album)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/albums", nil))
+	var albums []Album
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &albums))
+	assert.Len(t, albums, 2)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/albums/99", nil))
+	assertNotFoundProblem(t, w, "/albums/99")
+}
+
+func TestGetAlbumPhotos(t *testing.T) {
+	router := setupRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/albums/1/photos", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var photos []Photo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &photos))
+	require.Len(t, photos, 1)
+	assert.Equal(t, "Sunset", photos[0].Title)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/albums/99/photos", nil))
+	assertNotFoundProblem(t, w, "/albums/99/photos")
+}
+
+// ========== Photo Upload Tests ==========
+
+func TestUploadPhoto_Success(t *testing.T) {
+	router := setupRouter()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	freezeClock(now)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newUploadRequest(t, "/albums/1/photos", "Beach", encodePNG(t, 300, 200)))
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/photos/2", w.Header().Get("Location"))
+
+	var photo Photo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &photo))
+	assert.Equal(t, Photo{ID: 2, AlbumID: 1, Title: "Beach", ContentType: "image/png", Width: 300, Height: 200, Version: 1, CreatedAt: now, UpdatedAt: now}, photo)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/photos/2", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestUploadPhoto_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		file     []byte
+		expected int
+		message  string
+	}{
+		{"not an image", "/albums/1/photos", []byte("hello"), http.StatusBadRequest, "invalid image"},
+		{"unknown album", "/albums/99/photos", encodePNG(t, 8, 8), http.StatusNotFound, ""},
+		{"too large", "/albums/1/photos", bytes.Repeat([]byte("a"), maxBodyBytes+1), http.StatusRequestEntityTooLarge, "request body too large"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, newUploadRequest(t, tt.path, "", tt.file))
+
+			assert.Equal(t, tt.expected, w.Code)
+			if tt.message != "" {
+				assert.Contains(t, w.Body.String(), tt.message)
+			}
+		})
+	}
+}
+
+func TestUploadPhoto_MissingFile(t *testing.T) {
+	router := setupRouter()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	require.NoError(t, mw.WriteField("title", "x"))
+	require.NoError(t, mw.Close())
+	req := httptest.NewRequest(http.MethodPost, "/albums/1/photos", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestUploadPhoto_NotMultipart(t *testing.T) {
+	router := setupRouter()
+
+	req := httptest.NewRequest(http.MethodPost, "/albums/1/photos", strings.NewReader(`{"title":"x"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
+
+func TestUploadPhoto_PassesRequestValidation(t *testing.T) {
+	router := setupValidatedRouter(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newUploadRequest(t, "/albums/1/photos", "Validated", encodePNG(t, 10, 10)))
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+}
+
+// ========== Thumbnail Tests ==========
+
+func TestGetPhotoThumbnail_Sizes(t *testing.T) {
+	// The seeded photo is 64x48.
+	tests := []struct {
+		name           string
+		query          string
+		expectedWidth  int
+		expectedHeight int
+	}{
+		{"default", "", 128, 96},
+		{"size", "size=32", 32, 24},
+		{"width only", "width=16", 16, 12},
+		{"height only", "height=24", 24, 18},
+		{"height limits", "width=512&height=48", 64, 48},
+		{"width overrides size", "size=100&width=40&height=400", 40, 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/photos/1/thumbnail?"+tt.query, nil))
+
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+			img, err := png.Decode(w.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedWidth, img.Bounds().Dx())
+			assert.Equal(t, tt.expectedHeight, img.Bounds().Dy())
+		})
+	}
+}
+
+func TestGetPhotoThumbnail_InvalidSize(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		violations []string
+	}{
+		{"too small", "size=8", []string{`query parameter "size": must be an integer between 16 and 512`}},
+		{"too large", "width=1024", []string{`query parameter "width": must be an integer between 16 and 512`}},
+		{"not a number", "height=big", []string{`query parameter "height": must be an integer between 16 and 512`}},
+		{"several", "width=0&height=0", []string{
+			`query parameter "width": must be an integer between 16 and 512`,
+			`query parameter "height": must be an integer between 16 and 512`,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/photos/1/thumbnail?"+tt.query, nil))
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			var problem Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			assert.Equal(t, tt.violations, problem.Violations)
+		})
+	}
+}
+
+func TestGetPhotoThumbnail_UnknownPhoto(t *testing.T) {
+	router := setupRouter()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/photos/99/thumbnail", nil))
+	assertNotFoundProblem(t, w, "/photos/99/thumbnail")
+}
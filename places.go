@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+type Place struct {
+	ID   int     `json:"id"`
+	Name string  `json:"name"`
+	Lat  float64 `json:"lat"`
+	Lng  float64 `json:"lng"`
+}
+
+// NearbyPlace is a Place with its distance from the search origin.
+type NearbyPlace struct {
+	Place
+	DistanceKm float64 `json:"distanceKm"`
+}
+
+// Search radius bounds, in kilometers.
+const (
+	defaultRadiusKm = 10.0
+	maxRadiusKm     = 500.0
+)
+
+// earthRadiusKm is the mean radius used by haversineKm.
+const earthRadiusKm = 6371.0
+
+// placeQuery is the parsed query of GET /places.
+type placeQuery struct {
+	Lat, Lng, RadiusKm float64
+}
+
+// parsePlaceQuery reads the required ?lat= and ?lng= and the optional
+// ?radius= (kilometers) from r, returning one violation per bad parameter.
+func parsePlaceQuery(r *http.Request) (placeQuery, []string) {
+	q := r.URL.Query()
+	pq := placeQuery{RadiusKm: defaultRadiusKm}
+	var violations []string
+
+	params := []struct {
+		name     string
+		dst      *float64
+		required bool
+		min, max float64
+		// exclusiveMin rejects min itself.
+		exclusiveMin bool
+	}{
+		{name: "lat", dst: &pq.Lat, required: true, min: -90, max: 90},
+		{name: "lng", dst: &pq.Lng, required: true, min: -180, max: 180},
+		{name: "radius", dst: &pq.RadiusKm, min: 0, max: maxRadiusKm, exclusiveMin: true},
+	}
+	for _, p := range params {
+		v := q.Get(p.name)
+		if v == "" {
+			if p.required {
+				violations = append(violations, fmt.Sprintf("query parameter %q: is required", p.name))
+			}
+			continue
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			violations = append(violations, fmt.Sprintf("query parameter %q: must be a number", p.name))
+			continue
+		}
+		if f < p.min || f > p.max || (p.exclusiveMin && f == p.min) {
+			lower := "["
+			if p.exclusiveMin {
+				lower = "("
+			}
+			violations = append(violations, fmt.Sprintf("query parameter %q: must be in %s%g, %g]", p.name, lower, p.min, p.max))
+			continue
+		}
+		*p.dst = f
+	}
+	return pq, violations
+}
+
+// haversineKm is the great-circle distance between two points.
+func haversineKm(lat1, lng1, lat2, lng2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLng := (lng2 - lng1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// listPlaces returns the places within radius of (lat, lng), nearest first.
+func (srv *Server) listPlaces(w http.ResponseWriter, r *http.Request) {
+	pq, violations := parsePlaceQuery(r)
+	if len(violations) > 0 {
+		respondProblem(w, r, http.StatusBadRequest, "invalid request", Problem{Violations: violations})
+		return
+	}
+
+	nearby := []NearbyPlace{}
+	for _, p := range srv.requestStore(r).ListPlaces() {
+		d := haversineKm(pq.Lat, pq.Lng, p.Lat, p.Lng)
+		if d <= pq.RadiusKm {
+			nearby = append(nearby, NearbyPlace{Place: p, DistanceKm: math.Round(d*1000) / 1000})
+		}
+	}
+	sort.SliceStable(nearby, func(i, j int) bool { return nearby[i].DistanceKm < nearby[j].DistanceKm })
+	respondJSON(w, http.StatusOK, nearby)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== Places Tests ==========
+
+func TestListPlaces_Nearby(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected []string
+	}{
+		{"default radius", "lat=52.5200&lng=13.4050", []string{"Berlin TV Tower", "Checkpoint Charlie", "Brandenburg Gate"}},
+		{"small radius", "lat=52.5163&lng=13.3777&radius=0.5", []string{"Brandenburg Gate"}},
+		{"large radius", "lat=50&lng=8&radius=500", []string{"Eiffel Tower", "Brandenburg Gate", "Checkpoint Charlie", "Berlin TV Tower"}},
+		{"southern hemisphere", "lat=-33.86&lng=151.21&radius=1", []string{"Sydney Opera House"}},
+		{"nothing nearby", "lat=0&lng=0", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/places?"+tt.query, nil))
+
+			require.Equal(t, http.StatusOK, w.Code)
+			var places []NearbyPlace
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &places))
+			names := []string{}
+			for i, p := range places {
+				names = append(names, p.Name)
+				if i > 0 {
+					assert.GreaterOrEqual(t, p.DistanceKm, places[i-1].DistanceKm)
+				}
+			}
+			assert.Equal(t, tt.expected, names)
+		})
+	}
+}
+
+func TestListPlaces_FloatFields(t *testing.T) {
+	router := setupRouter()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/places?lat=52.5163&lng=13.3777&radius=1", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"id":1,"name":"Brandenburg Gate","lat":52.5163,"lng":13.3777,"distanceKm":0}]`, w.Body.String())
+}
+
+func TestListPlaces_InvalidQuery(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		violations []string
+	}{
+		{"missing", "", []string{`query parameter "lat": is required`, `query parameter "lng": is required`}},
+		{"latitude bounds", "lat=90.1&lng=0", []string{`query parameter "lat": must be in [-90, 90]`}},
+		{"longitude bounds", "lat=0&lng=-180.5", []string{`query parameter "lng": must be in [-180, 180]`}},
+		{"not a number", "lat=north&lng=0", []string{`query parameter "lat": must be a number`}},
+		{"nan", "lat=NaN&lng=0", []string{`query parameter "lat": must be a number`}},
+		{"zero radius", "lat=0&lng=0&radius=0", []string{`query parameter "radius": must be in (0, 500]`}},
+		{"radius too large", "lat=0&lng=0&radius=501", []string{`query parameter "radius": must be in (0, 500]`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/places?"+tt.query, nil))
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			var problem Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			assert.Equal(t, tt.violations, problem.Violations)
+		})
+	}
+}
+
+func TestHaversineKm(t *testing.T) {
+	// Brandenburg Gate to the Eiffel Tower is about 878 km.
+	assert.InDelta(t, 878, haversineKm(52.5163, 13.3777, 48.8584, 2.2945), 2)
+	assert.Equal(t, 0.0, haversineKm(10, 20, 10, 20))
+}
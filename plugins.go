@@ -0,0 +1,93 @@
+package main
+
+import "net/http"
+
+// Plugin extends the server without changing its handlers. Every hook is
+// optional, and a server runs its plugins' hooks in the order they were
+// registered.
+type Plugin struct {
+	// Name identifies the plugin in logs.
+	Name string
+	// OnRequest runs as each request arrives, before routing, the caller's
+	// tenant and authentication are resolved. It may set response headers.
+	OnRequest func(w http.ResponseWriter, r *http.Request)
+	// OnResponse runs once the response's status is known, just before its
+	// headers are sent, so it may still change them. A handler that writes
+	// nothing has answered 200.
+	OnResponse func(r *http.Request, status int, header http.Header)
+	// OnEntityChange receives every entity event the store publishes, in
+	// the publishing goroutine; anything slow should queue its work.
+	OnEntityChange func(e Event)
+}
+
+// Register adds plugins to srv. Register them before srv serves requests;
+// the hooks of a running server do not change.
+func (srv *Server) Register(plugins ...Plugin) {
+	srv.plugins = append(srv.plugins, plugins...)
+}
+
+// runPlugins is the middleware that calls the registered plugins'
+// OnRequest and OnResponse hooks.
+func (srv *Server) runPlugins(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, p := range srv.plugins {
+			if p.OnRequest != nil {
+				p.OnRequest(w, r)
+			}
+		}
+		pw := &pluginWriter{ResponseWriter: w, srv: srv, r: r}
+		next.ServeHTTP(pw, r)
+		if !pw.wroteHeader {
+			pw.WriteHeader(http.StatusOK)
+		}
+	})
+}
+
+// entityChanged passes e to the registered plugins' OnEntityChange hooks.
+// main subscribes it to the event bus.
+func (srv *Server) entityChanged(e Event) {
+	for _, p := range srv.plugins {
+		if p.OnEntityChange != nil {
+			p.OnEntityChange(e)
+		}
+	}
+}
+
+// pluginWriter calls the OnResponse hooks when the response's headers are
+// written.
+type pluginWriter struct {
+	http.ResponseWriter
+	srv         *Server
+	r           *http.Request
+	wroteHeader bool
+}
+
+func (w *pluginWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		for _, p := range w.srv.plugins {
+			if p.OnResponse != nil {
+				p.OnResponse(w.r, status, w.Header())
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *pluginWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *pluginWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *pluginWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== Plugin Tests ==========
+
+func TestPlugins_RequestAndResponseHooks(t *testing.T) {
+	router := setupRouter()
+	var calls []string
+	server.Register(Plugin{
+		Name: "test",
+		OnRequest: func(w http.ResponseWriter, r *http.Request) {
+			calls = append(calls, "request "+r.URL.Path)
+			w.Header().Set("X-Plugin", "seen")
+		},
+		OnResponse: func(r *http.Request, status int, header http.Header) {
+			calls = append(calls, "response "+http.StatusText(status))
+			header.Set("X-Plugin-Status", http.StatusText(status))
+		},
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/999", nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, []string{"request /users/999", "response Not Found"}, calls)
+	assert.Equal(t, "seen", w.Header().Get("X-Plugin"))
+	assert.Equal(t, "Not Found", w.Header().Get("X-Plugin-Status"))
+}
+
+func TestPlugins_ResponseWithoutBody(t *testing.T) {
+	srv := newMemoryServer(Config{})
+	var status int
+	srv.Register(Plugin{OnResponse: func(r *http.Request, s int, header http.Header) { status = s }})
+	h := srv.runPlugins(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestPlugins_OnEntityChange(t *testing.T) {
+	router := setupRouter()
+	var changes []Event
+	server.Register(Plugin{OnEntityChange: func(e Event) { changes = append(changes, e) }})
+	unsubscribe := server.events.Subscribe(server.entityChanged)
+	t.Cleanup(unsubscribe)
+
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":"Carol","email":"carol@example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Len(t, changes, 1)
+	assert.Equal(t, EventCreated, changes[0].Type)
+	assert.Equal(t, "users", changes[0].Resource)
+}
+
+func TestPlugins_WebhooksBuiltIn(t *testing.T) {
+	setupRouter()
+	hook, err := server.Store.CreateWebhook(Webhook{URL: "https://example.com/hook", Events: []string{"users.created"}})
+	require.NoError(t, err)
+
+	server.entityChanged(Event{Type: EventCreated, Resource: "users", ID: 3})
+
+	deliveries := server.Store.ListDeliveries(hook.ID)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, 3, deliveries[0].Event.ID)
+}
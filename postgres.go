@@ -0,0 +1,1406 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/api2spec/api2spec-fixture-chi/flags"
+)
+
+// pgQueryTimeout bounds every statement pgStore runs, so a stalled
+// database shows up as a failure the breaker can count.
+const pgQueryTimeout = 5 * time.Second
+
+// pgStore is a Store backed by Postgres. The schema is created by the
+// embedded migrations (see runMigrate). Reads without an error result panic
+// when the database fails; breakerStore turns those panics into backend
+// failures.
+type pgStore struct {
+	pool  *pgxpool.Pool
+	bus   *EventBus
+	clock Clock
+	// fields seals sensitive user fields at rest; nil stores them as
+	// plaintext. See sealEmail.
+	fields *fieldCipher
+}
+
+// newPostgresStore connects to the database at url with a pool sized by
+// poolCfg.
+func newPostgresStore(ctx context.Context, url string, poolCfg DBPoolConfig, bus *EventBus) (*pgStore, error) {
+	cfg, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		return nil, err
+	}
+	if poolCfg.MaxConns > 0 {
+		cfg.MaxConns = int32(poolCfg.MaxConns)
+	}
+	cfg.MinConns = int32(poolCfg.MinConns)
+	if poolCfg.MaxConnLifetime > 0 {
+		cfg.MaxConnLifetime = poolCfg.MaxConnLifetime
+	}
+	if poolCfg.MaxConnIdleTime > 0 {
+		cfg.MaxConnIdleTime = poolCfg.MaxConnIdleTime
+	}
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return &pgStore{pool: pool, bus: bus, clock: systemClock{}}, nil
+}
+
+// Close releases the store's connections.
+func (s *pgStore) Close() {
+	s.pool.Close()
+}
+
+func (s *pgStore) setClock(c Clock) { s.clock = c }
+
+// stamp returns the current time at the microsecond resolution Postgres
+// keeps, so what a write returns matches what a later read sees.
+func (s *pgStore) stamp() time.Time {
+	return s.clock.Now().UTC().Truncate(time.Microsecond)
+}
+
+// pgQuerier is what pgStore's helpers need from a pool or a transaction.
+type pgQuerier interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// queryEach runs sql and calls each for every row.
+func queryEach(ctx context.Context, q pgQuerier, each func(row pgx.Row) error, sql string, args ...interface{}) error {
+	rows, err := q.Query(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := each(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// list is queryEach on the pool for reads that cannot return an error.
+func (s *pgStore) list(each func(row pgx.Row) error, sql string, args ...interface{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), pgQueryTimeout)
+	defer cancel()
+	if err := queryEach(ctx, s.pool, each, sql, args...); err != nil {
+		panic(err)
+	}
+}
+
+// get scans the single row sql returns, mapping no row to errNotFound.
+func (s *pgStore) get(scan func(row pgx.Row) error, sql string, args ...interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), pgQueryTimeout)
+	defer cancel()
+	err := scan(s.pool.QueryRow(ctx, sql, args...))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return errNotFound
+	}
+	return err
+}
+
+// inTx runs fn in a transaction, committed if fn returns nil.
+func (s *pgStore) inTx(fn func(ctx context.Context, tx pgx.Tx) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), pgQueryTimeout)
+	defer cancel()
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error { return fn(ctx, tx) })
+}
+
+// isUniqueViolation reports whether err is a unique constraint violation.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// ========== Users and posts ==========
+
+const userColumns = "id, name, email, version, post_count, verified, created_at, updated_at"
+
+// emailField names users.email to fieldCipher.
+const emailField = "users.email"
+
+// scanUser reads a user, opening its email if sealed.
+func (s *pgStore) scanUser(row pgx.Row) (User, error) {
+	var u User
+	err := row.Scan(&u.ID, &u.Name, &u.Email, &u.Version, &u.PostCount, &u.Verified, &u.CreatedAt, &u.UpdatedAt)
+	u.CreatedAt, u.UpdatedAt = u.CreatedAt.UTC(), u.UpdatedAt.UTC()
+	if err != nil {
+		return u, err
+	}
+	u.Email, err = s.fields.open(emailField, u.Email)
+	return u, err
+}
+
+// sealEmail returns what is stored for email: the email column, sealed
+// when field encryption is on, and email_index, which equality on emails
+// is checked against since sealed emails cannot be compared. The index is
+// the lowercased email, or its blind index when encryption is on.
+func (s *pgStore) sealEmail(email string) (stored, index string, err error) {
+	if s.fields == nil {
+		return email, strings.ToLower(email), nil
+	}
+	stored, err = s.fields.seal(emailField, email)
+	return stored, s.emailIndex(email), err
+}
+
+// emailIndex returns the email_index of email.
+func (s *pgStore) emailIndex(email string) string {
+	if s.fields == nil {
+		return strings.ToLower(email)
+	}
+	return s.fields.index(emailField, strings.ToLower(email))
+}
+
+func (s *pgStore) ListUsers() []User {
+	users := []User{}
+	s.list(func(row pgx.Row) error {
+		u, err := s.scanUser(row)
+		users = append(users, u)
+		return err
+	}, "SELECT "+userColumns+" FROM users ORDER BY id")
+	return users
+}
+
+func (s *pgStore) CountUsers() int {
+	var n int
+	if err := s.get(func(row pgx.Row) error { return row.Scan(&n) }, "SELECT count(*) FROM users"); err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// likePrefix escapes the LIKE wildcards in a string.
+var likePrefix = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// SuggestUsers scans users_name_prefix_idx. Names are ordered bytewise, as
+// the memory store orders them, rather than by the database's collation.
+func (s *pgStore) SuggestUsers(prefix string, limit int) []UserSuggestion {
+	suggestions := []UserSuggestion{}
+	s.list(func(row pgx.Row) error {
+		var u UserSuggestion
+		err := row.Scan(&u.ID, &u.Name)
+		suggestions = append(suggestions, u)
+		return err
+	}, `SELECT id, name FROM users WHERE lower(name) COLLATE "C" LIKE $1 ORDER BY lower(name) COLLATE "C", id LIMIT $2`,
+		likePrefix.Replace(strings.ToLower(prefix))+"%", limit)
+	return suggestions
+}
+
+func (s *pgStore) GetUser(id int) (User, error) {
+	var u User
+	err := s.get(func(row pgx.Row) (err error) {
+		u, err = s.scanUser(row)
+		return err
+	}, "SELECT "+userColumns+" FROM users WHERE id = $1", id)
+	return u, err
+}
+
+// userByEmail returns the user other than exceptID whose email matches,
+// ignoring case.
+func (s *pgStore) userByEmail(email string, exceptID int) (User, error) {
+	var u User
+	err := s.get(func(row pgx.Row) (err error) {
+		u, err = s.scanUser(row)
+		return err
+	}, "SELECT "+userColumns+" FROM users WHERE email_index = $1 AND email_index <> '' AND id <> $2", s.emailIndex(email), exceptID)
+	return u, err
+}
+
+func (s *pgStore) CreateUser(u User) (User, error) {
+	u.Version = 1
+	u.PostCount = 0
+	u.Verified = false
+	u.CreatedAt = s.stamp()
+	u.UpdatedAt = u.CreatedAt
+	email, index, err := s.sealEmail(u.Email)
+	if err != nil {
+		return User{}, err
+	}
+	err = s.get(func(row pgx.Row) error { return row.Scan(&u.ID) },
+		"INSERT INTO users (name, email, email_index, version, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
+		u.Name, email, index, u.Version, u.CreatedAt, u.UpdatedAt)
+	if isUniqueViolation(err) {
+		if existing, err := s.userByEmail(u.Email, 0); err == nil {
+			return existing, errDuplicate
+		}
+	}
+	if err != nil {
+		return User{}, err
+	}
+
+	s.bus.Publish(Event{Type: EventCreated, Resource: "users", ID: u.ID, Data: u})
+	return u, nil
+}
+
+func (s *pgStore) UpdateUser(u User) (User, error) {
+	var current User
+	err := s.inTx(func(ctx context.Context, tx pgx.Tx) error {
+		var err error
+		current, err = s.scanUser(tx.QueryRow(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1 FOR UPDATE", u.ID))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if u.Version != current.Version {
+			return errVersionConflict
+		}
+		u.Version = current.Version + 1
+		u.PostCount = current.PostCount
+		u.Verified = current.Verified && strings.EqualFold(u.Email, current.Email)
+		u.CreatedAt = current.CreatedAt
+		u.UpdatedAt = s.stamp()
+		email, index, err := s.sealEmail(u.Email)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, "UPDATE users SET name = $2, email = $3, email_index = $4, version = $5, verified = $6, updated_at = $7 WHERE id = $1",
+			u.ID, u.Name, email, index, u.Version, u.Verified, u.UpdatedAt)
+		return err
+	})
+	switch {
+	case errors.Is(err, errNotFound):
+		return User{}, err
+	case errors.Is(err, errVersionConflict):
+		return current, err
+	case isUniqueViolation(err):
+		if existing, err := s.userByEmail(u.Email, u.ID); err == nil {
+			return existing, errDuplicate
+		}
+		return User{}, err
+	case err != nil:
+		return User{}, err
+	}
+
+	s.bus.Publish(Event{Type: EventUpdated, Resource: "users", ID: u.ID, Data: u})
+	return u, nil
+}
+
+// deleteRow deletes row id of table unless it was modified after
+// unmodifiedSince. If before is not empty it is run first, in the same
+// transaction, with id as $1.
+func (s *pgStore) deleteRow(table string, id int, unmodifiedSince time.Time, before string) error {
+	return s.inTx(func(ctx context.Context, tx pgx.Tx) error {
+		var updated time.Time
+		err := tx.QueryRow(ctx, "SELECT updated_at FROM "+table+" WHERE id = $1 FOR UPDATE", id).Scan(&updated)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if modifiedSince(updated, unmodifiedSince) {
+			return errModified
+		}
+		if before != "" {
+			if _, err := tx.Exec(ctx, before, id); err != nil {
+				return err
+			}
+		}
+		_, err = tx.Exec(ctx, "DELETE FROM "+table+" WHERE id = $1", id)
+		return err
+	})
+}
+
+func (s *pgStore) DeleteUser(id int, unmodifiedSince time.Time) error {
+	if err := s.deleteRow("users", id, unmodifiedSince, ""); err != nil {
+		return err
+	}
+	s.bus.Publish(Event{Type: EventDeleted, Resource: "users", ID: id})
+	return nil
+}
+
+const postColumns = "id, user_id, title, slug, body, version, created_at, updated_at"
+
+func scanPost(row pgx.Row) (Post, error) {
+	var p Post
+	err := row.Scan(&p.ID, &p.UserID, &p.Title, &p.Slug, &p.Body, &p.Version, &p.CreatedAt, &p.UpdatedAt)
+	p.CreatedAt, p.UpdatedAt = p.CreatedAt.UTC(), p.UpdatedAt.UTC()
+	return p, err
+}
+
+func (s *pgStore) listPosts(sql string, args ...interface{}) []Post {
+	posts := []Post{}
+	s.list(func(row pgx.Row) error {
+		p, err := scanPost(row)
+		posts = append(posts, p)
+		return err
+	}, sql, args...)
+	return posts
+}
+
+func (s *pgStore) VerifyUser(id int, email string) (User, error) {
+	var u User
+	err := s.get(func(row pgx.Row) (err error) {
+		u, err = s.scanUser(row)
+		return err
+	}, `UPDATE users SET
+		verified = true,
+		version = CASE WHEN verified THEN version ELSE version + 1 END,
+		updated_at = CASE WHEN verified THEN updated_at ELSE $3 END
+		WHERE id = $1 AND email_index <> '' AND email_index = $2
+		RETURNING `+userColumns, id, s.emailIndex(email), s.stamp())
+	if err != nil {
+		return User{}, err
+	}
+	s.bus.Publish(Event{Type: EventUpdated, Resource: "users", ID: u.ID, Data: u})
+	return u, nil
+}
+
+func (s *pgStore) SetPasswordHash(id int, hash []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), pgQueryTimeout)
+	defer cancel()
+	tag, err := s.pool.Exec(ctx, "UPDATE users SET password_hash = $2 WHERE id = $1", id, hash)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errNotFound
+	}
+	return nil
+}
+
+func (s *pgStore) PasswordHash(id int) ([]byte, error) {
+	var hash []byte
+	err := s.get(func(row pgx.Row) error { return row.Scan(&hash) },
+		"SELECT password_hash FROM users WHERE id = $1", id)
+	return hash, err
+}
+
+func (s *pgStore) SetTwoFactor(id int, tf TwoFactor) error {
+	ctx, cancel := context.WithTimeout(context.Background(), pgQueryTimeout)
+	defer cancel()
+	tag, err := s.pool.Exec(ctx, "UPDATE users SET totp_secret = $2, totp_enabled = $3 WHERE id = $1",
+		id, tf.Secret, tf.Enabled)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errNotFound
+	}
+	return nil
+}
+
+func (s *pgStore) TwoFactor(id int) (TwoFactor, error) {
+	var tf TwoFactor
+	err := s.get(func(row pgx.Row) error { return row.Scan(&tf.Secret, &tf.Enabled) },
+		"SELECT totp_secret, totp_enabled FROM users WHERE id = $1", id)
+	return tf, err
+}
+
+func (s *pgStore) ListPosts() []Post {
+	return s.listPosts("SELECT " + postColumns + " FROM posts ORDER BY id")
+}
+
+func (s *pgStore) ListPostsByUser(userID int) []Post {
+	return s.listPosts("SELECT "+postColumns+" FROM posts WHERE user_id = $1 ORDER BY id", userID)
+}
+
+func (s *pgStore) GetPostBySlug(slug string) (Post, error) {
+	var p Post
+	err := s.get(func(row pgx.Row) (err error) {
+		p, err = scanPost(row)
+		return err
+	}, "SELECT "+postColumns+" FROM posts WHERE slug = $1", slug)
+	return p, err
+}
+
+// freeSlug returns base, or the first of base-2, base-3, ... no post has.
+func freeSlug(ctx context.Context, tx pgx.Tx, base string) (string, error) {
+	taken := map[string]bool{}
+	err := queryEach(ctx, tx, func(row pgx.Row) error {
+		var slug string
+		err := row.Scan(&slug)
+		taken[slug] = true
+		return err
+	}, `SELECT slug FROM posts WHERE slug = $1 OR slug LIKE $2`, base, likePrefix.Replace(base)+"-%")
+	if err != nil {
+		return "", err
+	}
+	return uniqueSlug(base, func(slug string) bool { return taken[slug] }), nil
+}
+
+func (s *pgStore) CountPosts(userID int) int {
+	var n int
+	err := s.get(func(row pgx.Row) error { return row.Scan(&n) },
+		"SELECT count(*) FROM posts WHERE $1 = 0 OR user_id = $1", userID)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+func (s *pgStore) GetPost(id int) (Post, error) {
+	var p Post
+	err := s.get(func(row pgx.Row) (err error) {
+		p, err = scanPost(row)
+		return err
+	}, "SELECT "+postColumns+" FROM posts WHERE id = $1", id)
+	return p, err
+}
+
+func (s *pgStore) CreatePost(p Post) (Post, error) {
+	return s.createPost(p, false)
+}
+
+func (s *pgStore) CreateUserPost(p Post) (Post, error) {
+	return s.createPost(p, true)
+}
+
+// createPost inserts p and counts it on its author in one transaction.
+// With authorRequired a missing author is errNotFound and rolls the insert
+// back.
+func (s *pgStore) createPost(p Post, authorRequired bool) (Post, error) {
+	p.Version = 1
+	p.CreatedAt = s.stamp()
+	p.UpdatedAt = p.CreatedAt
+	err := s.inTx(func(ctx context.Context, tx pgx.Tx) error {
+		if p.Slug == "" {
+			slug, err := freeSlug(ctx, tx, slugify(p.Title))
+			if err != nil {
+				return err
+			}
+			p.Slug = slug
+		}
+		err := tx.QueryRow(ctx, "INSERT INTO posts (user_id, title, slug, body, version, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id",
+			p.UserID, p.Title, p.Slug, p.Body, p.Version, p.CreatedAt, p.UpdatedAt).Scan(&p.ID)
+		if err != nil {
+			return err
+		}
+		tag, err := tx.Exec(ctx, "UPDATE users SET post_count = post_count + 1 WHERE id = $1", p.UserID)
+		if err != nil {
+			return err
+		}
+		if authorRequired && tag.RowsAffected() == 0 {
+			return errNotFound
+		}
+		return nil
+	})
+	if isUniqueViolation(err) {
+		var existing Post
+		if err := s.get(func(row pgx.Row) (err error) {
+			existing, err = scanPost(row)
+			return err
+		}, "SELECT "+postColumns+" FROM posts WHERE (user_id = $1 AND title = $2 AND title <> '') OR slug = $3 ORDER BY slug = $3 LIMIT 1",
+			p.UserID, p.Title, p.Slug); err == nil {
+			return existing, errDuplicate
+		}
+	}
+	if err != nil {
+		return Post{}, err
+	}
+
+	s.bus.Publish(Event{Type: EventCreated, Resource: "posts", ID: p.ID, Data: p})
+	return p, nil
+}
+
+func (s *pgStore) UpdatePost(p Post) (Post, error) {
+	var current Post
+	err := s.inTx(func(ctx context.Context, tx pgx.Tx) error {
+		var err error
+		current, err = scanPost(tx.QueryRow(ctx, "SELECT "+postColumns+" FROM posts WHERE id = $1 FOR UPDATE", p.ID))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if p.Version != current.Version {
+			return errVersionConflict
+		}
+		p.UserID = current.UserID
+		if p.Slug == "" {
+			p.Slug = current.Slug
+		}
+		p.Version = current.Version + 1
+		p.CreatedAt = current.CreatedAt
+		p.UpdatedAt = s.stamp()
+		_, err = tx.Exec(ctx, "UPDATE posts SET title = $2, slug = $3, body = $4, version = $5, updated_at = $6 WHERE id = $1",
+			p.ID, p.Title, p.Slug, p.Body, p.Version, p.UpdatedAt)
+		return err
+	})
+	switch {
+	case errors.Is(err, errNotFound):
+		return Post{}, err
+	case errors.Is(err, errVersionConflict):
+		return current, err
+	case isUniqueViolation(err):
+		var existing Post
+		if err := s.get(func(row pgx.Row) (err error) {
+			existing, err = scanPost(row)
+			return err
+		}, "SELECT "+postColumns+" FROM posts WHERE id <> $4 AND ((user_id = $1 AND title = $2 AND title <> '') OR slug = $3) ORDER BY slug = $3 LIMIT 1",
+			p.UserID, p.Title, p.Slug, p.ID); err == nil {
+			return existing, errDuplicate
+		}
+		return Post{}, err
+	case err != nil:
+		return Post{}, err
+	}
+
+	s.bus.Publish(Event{Type: EventUpdated, Resource: "posts", ID: p.ID, Data: p})
+	return p, nil
+}
+
+func (s *pgStore) DeletePost(id int, unmodifiedSince time.Time) error {
+	if err := s.deleteRow("posts", id, unmodifiedSince,
+		"UPDATE users SET post_count = greatest(post_count - 1, 0) WHERE id = (SELECT user_id FROM posts WHERE id = $1)"); err != nil {
+		return err
+	}
+	s.bus.Publish(Event{Type: EventDeleted, Resource: "posts", ID: id})
+	return nil
+}
+
+const commentColumns = "id, post_id, user_id, body, created_at, updated_at"
+
+func scanComment(row pgx.Row) (Comment, error) {
+	var c Comment
+	err := row.Scan(&c.ID, &c.PostID, &c.UserID, &c.Body, &c.CreatedAt, &c.UpdatedAt)
+	c.CreatedAt, c.UpdatedAt = c.CreatedAt.UTC(), c.UpdatedAt.UTC()
+	return c, err
+}
+
+func (s *pgStore) GetComment(id int) (Comment, error) {
+	var c Comment
+	err := s.get(func(row pgx.Row) (err error) {
+		c, err = scanComment(row)
+		return err
+	}, "SELECT "+commentColumns+" FROM comments WHERE id = $1", id)
+	return c, err
+}
+
+// ========== Todos, albums, photos and places ==========
+
+const todoColumns = "id, title, completed, priority, due, version, created_at, updated_at"
+
+func scanTodo(row pgx.Row) (Todo, error) {
+	var t Todo
+	var priority string
+	err := row.Scan(&t.ID, &t.Title, &t.Completed, &priority, &t.Due, &t.Version, &t.CreatedAt, &t.UpdatedAt)
+	t.Priority = Priority(priority)
+	t.CreatedAt, t.UpdatedAt = t.CreatedAt.UTC(), t.UpdatedAt.UTC()
+	if t.Due != nil {
+		due := t.Due.UTC()
+		t.Due = &due
+	}
+	return t, err
+}
+
+func (s *pgStore) ListTodos() []Todo {
+	todos := []Todo{}
+	s.list(func(row pgx.Row) error {
+		t, err := scanTodo(row)
+		todos = append(todos, t)
+		return err
+	}, "SELECT "+todoColumns+" FROM todos ORDER BY id")
+	return todos
+}
+
+func (s *pgStore) GetTodo(id int) (Todo, error) {
+	var t Todo
+	err := s.get(func(row pgx.Row) (err error) {
+		t, err = scanTodo(row)
+		return err
+	}, "SELECT "+todoColumns+" FROM todos WHERE id = $1", id)
+	return t, err
+}
+
+func (s *pgStore) CreateTodo(t Todo) (Todo, error) {
+	t.Version = 1
+	t.CreatedAt = s.stamp()
+	t.UpdatedAt = t.CreatedAt
+	err := s.get(func(row pgx.Row) error { return row.Scan(&t.ID) },
+		"INSERT INTO todos (title, completed, priority, due, version, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id",
+		t.Title, t.Completed, string(t.Priority), t.Due, t.Version, t.CreatedAt, t.UpdatedAt)
+	if err != nil {
+		return Todo{}, err
+	}
+
+	s.bus.Publish(Event{Type: EventCreated, Resource: "todos", ID: t.ID, Data: t})
+	return t, nil
+}
+
+const albumColumns = "id, user_id, title, version, created_at, updated_at"
+
+func scanAlbum(row pgx.Row) (Album, error) {
+	var a Album
+	err := row.Scan(&a.ID, &a.UserID, &a.Title, &a.Version, &a.CreatedAt, &a.UpdatedAt)
+	a.CreatedAt, a.UpdatedAt = a.CreatedAt.UTC(), a.UpdatedAt.UTC()
+	return a, err
+}
+
+func (s *pgStore) ListAlbums() []Album {
+	albums := []Album{}
+	s.list(func(row pgx.Row) error {
+		a, err := scanAlbum(row)
+		albums = append(albums, a)
+		return err
+	}, "SELECT "+albumColumns+" FROM albums ORDER BY id")
+	return albums
+}
+
+func (s *pgStore) GetAlbum(id int) (Album, error) {
+	var a Album
+	err := s.get(func(row pgx.Row) (err error) {
+		a, err = scanAlbum(row)
+		return err
+	}, "SELECT "+albumColumns+" FROM albums WHERE id = $1", id)
+	return a, err
+}
+
+func (s *pgStore) CreateAlbum(a Album) (Album, error) {
+	a.Version = 1
+	a.CreatedAt = s.stamp()
+	a.UpdatedAt = a.CreatedAt
+	err := s.get(func(row pgx.Row) error { return row.Scan(&a.ID) },
+		"INSERT INTO albums (user_id, title, version, created_at, updated_at) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+		a.UserID, a.Title, a.Version, a.CreatedAt, a.UpdatedAt)
+	if err != nil {
+		return Album{}, err
+	}
+
+	s.bus.Publish(Event{Type: EventCreated, Resource: "albums", ID: a.ID, Data: a})
+	return a, nil
+}
+
+const photoColumns = "id, album_id, title, content_type, width, height, version, created_at, updated_at"
+
+func scanPhoto(row pgx.Row) (Photo, error) {
+	var p Photo
+	err := row.Scan(&p.ID, &p.AlbumID, &p.Title, &p.ContentType, &p.Width, &p.Height, &p.Version, &p.CreatedAt, &p.UpdatedAt)
+	p.CreatedAt, p.UpdatedAt = p.CreatedAt.UTC(), p.UpdatedAt.UTC()
+	return p, err
+}
+
+func (s *pgStore) ListPhotosByAlbum(albumID int) []Photo {
+	photos := []Photo{}
+	s.list(func(row pgx.Row) error {
+		p, err := scanPhoto(row)
+		photos = append(photos, p)
+		return err
+	}, "SELECT "+photoColumns+" FROM photos WHERE album_id = $1 ORDER BY id", albumID)
+	return photos
+}
+
+func (s *pgStore) GetPhoto(id int) (Photo, error) {
+	var p Photo
+	err := s.get(func(row pgx.Row) (err error) {
+		p, err = scanPhoto(row)
+		return err
+	}, "SELECT "+photoColumns+" FROM photos WHERE id = $1", id)
+	return p, err
+}
+
+func (s *pgStore) CreatePhoto(p Photo, data []byte) (Photo, error) {
+	p.Version = 1
+	p.CreatedAt = s.stamp()
+	p.UpdatedAt = p.CreatedAt
+	err := s.get(func(row pgx.Row) error { return row.Scan(&p.ID) },
+		"INSERT INTO photos (album_id, title, content_type, width, height, version, created_at, updated_at, data) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id",
+		p.AlbumID, p.Title, p.ContentType, p.Width, p.Height, p.Version, p.CreatedAt, p.UpdatedAt, data)
+	if err != nil {
+		return Photo{}, err
+	}
+
+	s.bus.Publish(Event{Type: EventCreated, Resource: "photos", ID: p.ID, Data: p})
+	return p, nil
+}
+
+func (s *pgStore) PhotoData(id int) ([]byte, error) {
+	var data []byte
+	err := s.get(func(row pgx.Row) error { return row.Scan(&data) }, "SELECT data FROM photos WHERE id = $1", id)
+	return data, err
+}
+
+const placeColumns = "id, name, lat, lng"
+
+func scanPlace(row pgx.Row) (Place, error) {
+	var p Place
+	err := row.Scan(&p.ID, &p.Name, &p.Lat, &p.Lng)
+	return p, err
+}
+
+func (s *pgStore) ListPlaces() []Place {
+	places := []Place{}
+	s.list(func(row pgx.Row) error {
+		p, err := scanPlace(row)
+		places = append(places, p)
+		return err
+	}, "SELECT "+placeColumns+" FROM places ORDER BY id")
+	return places
+}
+
+// ========== Ingest events, webhooks and tenants ==========
+
+const ingestEventColumns = "id, received_at, payload"
+
+func scanIngestEvent(row pgx.Row) (IngestEvent, error) {
+	var e IngestEvent
+	var payload []byte
+	err := row.Scan(&e.ID, &e.ReceivedAt, &payload)
+	e.ReceivedAt = e.ReceivedAt.UTC()
+	e.Payload = payload
+	return e, err
+}
+
+func (s *pgStore) ListIngestEvents() []IngestEvent {
+	events := []IngestEvent{}
+	s.list(func(row pgx.Row) error {
+		e, err := scanIngestEvent(row)
+		events = append(events, e)
+		return err
+	}, "SELECT "+ingestEventColumns+" FROM ingest_events ORDER BY id")
+	return events
+}
+
+func (s *pgStore) GetIngestEvent(id int) (IngestEvent, error) {
+	var e IngestEvent
+	err := s.get(func(row pgx.Row) (err error) {
+		e, err = scanIngestEvent(row)
+		return err
+	}, "SELECT "+ingestEventColumns+" FROM ingest_events WHERE id = $1", id)
+	return e, err
+}
+
+func (s *pgStore) CreateIngestEvent(e IngestEvent) (IngestEvent, error) {
+	e.ReceivedAt = s.stamp()
+	err := s.get(func(row pgx.Row) error { return row.Scan(&e.ID) },
+		"INSERT INTO ingest_events (received_at, payload) VALUES ($1, $2) RETURNING id",
+		e.ReceivedAt, e.Payload)
+	if err != nil {
+		return IngestEvent{}, err
+	}
+
+	s.bus.Publish(Event{Type: EventCreated, Resource: "ingest/events", ID: e.ID, Data: e})
+	return e, nil
+}
+
+const webhookColumns = "id, url, events, version"
+
+func scanWebhook(row pgx.Row) (Webhook, error) {
+	var w Webhook
+	err := row.Scan(&w.ID, &w.URL, &w.Events, &w.Version)
+	return w, err
+}
+
+func (s *pgStore) ListWebhooks() []Webhook {
+	hooks := []Webhook{}
+	s.list(func(row pgx.Row) error {
+		w, err := scanWebhook(row)
+		hooks = append(hooks, w)
+		return err
+	}, "SELECT "+webhookColumns+" FROM webhooks ORDER BY id")
+	return hooks
+}
+
+func (s *pgStore) GetWebhook(id int) (Webhook, error) {
+	var w Webhook
+	err := s.get(func(row pgx.Row) (err error) {
+		w, err = scanWebhook(row)
+		return err
+	}, "SELECT "+webhookColumns+" FROM webhooks WHERE id = $1", id)
+	return w, err
+}
+
+func (s *pgStore) CreateWebhook(w Webhook) (Webhook, error) {
+	w.Version = 1
+	err := s.get(func(row pgx.Row) error { return row.Scan(&w.ID) },
+		"INSERT INTO webhooks (url, events, version) VALUES ($1, $2, $3) RETURNING id",
+		w.URL, w.Events, w.Version)
+	if err != nil {
+		return Webhook{}, err
+	}
+
+	s.bus.Publish(Event{Type: EventCreated, Resource: "webhooks", ID: w.ID, Data: w})
+	return w, nil
+}
+
+// deleteByID deletes row id of table, mapping a missing row to errNotFound.
+func (s *pgStore) deleteByID(table string, id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), pgQueryTimeout)
+	defer cancel()
+	tag, err := s.pool.Exec(ctx, "DELETE FROM "+table+" WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errNotFound
+	}
+	return nil
+}
+
+func (s *pgStore) DeleteWebhook(id int) error {
+	if err := s.deleteByID("webhooks", id); err != nil {
+		return err
+	}
+	s.bus.Publish(Event{Type: EventDeleted, Resource: "webhooks", ID: id})
+	return nil
+}
+
+const tenantColumns = "id, name, rate_limit, flags, allowed_origins, version"
+
+func scanTenant(row pgx.Row) (Tenant, error) {
+	var t Tenant
+	err := row.Scan(&t.ID, &t.Name, &t.RateLimit, &t.Flags, &t.AllowedOrigins, &t.Version)
+	return t, err
+}
+
+func (s *pgStore) ListTenants() []Tenant {
+	tenants := []Tenant{}
+	s.list(func(row pgx.Row) error {
+		t, err := scanTenant(row)
+		tenants = append(tenants, t)
+		return err
+	}, "SELECT "+tenantColumns+" FROM tenants ORDER BY id")
+	return tenants
+}
+
+func (s *pgStore) GetTenant(id int) (Tenant, error) {
+	var t Tenant
+	err := s.get(func(row pgx.Row) (err error) {
+		t, err = scanTenant(row)
+		return err
+	}, "SELECT "+tenantColumns+" FROM tenants WHERE id = $1", id)
+	return t, err
+}
+
+func (s *pgStore) CreateTenant(t Tenant) (Tenant, error) {
+	t.Version = 1
+	err := s.get(func(row pgx.Row) error { return row.Scan(&t.ID) },
+		"INSERT INTO tenants (name, rate_limit, flags, allowed_origins, version) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+		t.Name, t.RateLimit, t.Flags, t.AllowedOrigins, t.Version)
+	if err != nil {
+		return Tenant{}, err
+	}
+
+	s.bus.Publish(Event{Type: EventCreated, Resource: "tenants", ID: t.ID, Data: t})
+	return t, nil
+}
+
+func (s *pgStore) UpdateTenant(t Tenant) (Tenant, error) {
+	var current Tenant
+	err := s.inTx(func(ctx context.Context, tx pgx.Tx) error {
+		var err error
+		current, err = scanTenant(tx.QueryRow(ctx, "SELECT "+tenantColumns+" FROM tenants WHERE id = $1 FOR UPDATE", t.ID))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if t.Version != current.Version {
+			return errVersionConflict
+		}
+		t.Version = current.Version + 1
+		_, err = tx.Exec(ctx, "UPDATE tenants SET name = $2, rate_limit = $3, flags = $4, allowed_origins = $5, version = $6 WHERE id = $1",
+			t.ID, t.Name, t.RateLimit, t.Flags, t.AllowedOrigins, t.Version)
+		return err
+	})
+	switch {
+	case errors.Is(err, errVersionConflict):
+		return current, err
+	case err != nil:
+		return Tenant{}, err
+	}
+
+	s.bus.Publish(Event{Type: EventUpdated, Resource: "tenants", ID: t.ID, Data: t})
+	return t, nil
+}
+
+func (s *pgStore) DeleteTenant(id int) error {
+	if err := s.deleteByID("tenants", id); err != nil {
+		return err
+	}
+	s.bus.Publish(Event{Type: EventDeleted, Resource: "tenants", ID: id})
+	return nil
+}
+
+// ========== Flags and the outbox ==========
+
+func (s *pgStore) GetFlags() flags.Set {
+	var set flags.Set
+	if err := s.get(func(row pgx.Row) error { return row.Scan(&set) }, "SELECT flags FROM feature_flags"); err != nil {
+		panic(err)
+	}
+	return set
+}
+
+func (s *pgStore) UpdateFlags(p flags.Patch) flags.Set {
+	var set flags.Set
+	err := s.inTx(func(ctx context.Context, tx pgx.Tx) error {
+		if err := tx.QueryRow(ctx, "SELECT flags FROM feature_flags FOR UPDATE").Scan(&set); err != nil {
+			return err
+		}
+		set = p.Apply(set)
+		_, err := tx.Exec(ctx, "UPDATE feature_flags SET flags = $1", set)
+		return err
+	})
+	if err != nil {
+		panic(err)
+	}
+	return set
+}
+
+const deliveryColumns = "id, webhook_id, event, status, attempts, created_at, next_attempt_at, last_status_code, last_error"
+
+func scanDelivery(row pgx.Row) (Delivery, error) {
+	var d Delivery
+	var status string
+	err := row.Scan(&d.ID, &d.WebhookID, &d.Event, &status, &d.Attempts, &d.CreatedAt, &d.NextAttemptAt, &d.LastStatusCode, &d.LastError)
+	d.Status = DeliveryStatus(status)
+	d.CreatedAt, d.NextAttemptAt = d.CreatedAt.UTC(), d.NextAttemptAt.UTC()
+	return d, err
+}
+
+func (s *pgStore) listDeliveries(sql string, args ...interface{}) []Delivery {
+	deliveries := []Delivery{}
+	s.list(func(row pgx.Row) error {
+		d, err := scanDelivery(row)
+		deliveries = append(deliveries, d)
+		return err
+	}, sql, args...)
+	return deliveries
+}
+
+func (s *pgStore) EnqueueDelivery(d Delivery) (Delivery, error) {
+	d.Status = DeliveryPending
+	d.Attempts = 0
+	d.CreatedAt = s.stamp()
+	d.NextAttemptAt = d.CreatedAt
+	err := s.get(func(row pgx.Row) error { return row.Scan(&d.ID) },
+		"INSERT INTO deliveries (webhook_id, event, status, attempts, created_at, next_attempt_at, last_status_code, last_error) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id",
+		d.WebhookID, d.Event, string(d.Status), d.Attempts, d.CreatedAt, d.NextAttemptAt, d.LastStatusCode, d.LastError)
+	if err != nil {
+		return Delivery{}, err
+	}
+	return d, nil
+}
+
+func (s *pgStore) DueDeliveries(now time.Time) []Delivery {
+	return s.listDeliveries("SELECT "+deliveryColumns+" FROM deliveries WHERE status = $1 AND next_attempt_at <= $2 ORDER BY id",
+		string(DeliveryPending), now)
+}
+
+func (s *pgStore) UpdateDelivery(d Delivery) error {
+	ctx, cancel := context.WithTimeout(context.Background(), pgQueryTimeout)
+	defer cancel()
+	tag, err := s.pool.Exec(ctx, "UPDATE deliveries SET webhook_id = $2, event = $3, status = $4, attempts = $5, created_at = $6, next_attempt_at = $7, last_status_code = $8, last_error = $9 WHERE id = $1",
+		d.ID, d.WebhookID, d.Event, string(d.Status), d.Attempts, d.CreatedAt, d.NextAttemptAt, d.LastStatusCode, d.LastError)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errNotFound
+	}
+	return nil
+}
+
+func (s *pgStore) ListDeliveries(webhookID int) []Delivery {
+	return s.listDeliveries("SELECT "+deliveryColumns+" FROM deliveries WHERE webhook_id = $1 ORDER BY id", webhookID)
+}
+
+// ========== Usage ==========
+
+func (s *pgStore) ChargeUsage(userID int, day time.Time, limit int) (int, error) {
+	var n int
+	err := s.get(func(row pgx.Row) error { return row.Scan(&n) },
+		`INSERT INTO usage (user_id, day, requests) VALUES ($1, $2, 1)
+		ON CONFLICT (user_id, day) DO UPDATE SET requests = usage.requests + 1
+		WHERE $3 = 0 OR usage.requests < $3
+		RETURNING requests`, userID, day, limit)
+	if errors.Is(err, errNotFound) {
+		// The conflicting row was at the limit, so it was not updated.
+		return s.Usage(userID, day), errQuotaExceeded
+	}
+	return n, err
+}
+
+func (s *pgStore) Usage(userID int, day time.Time) int {
+	var n int
+	err := s.get(func(row pgx.Row) error { return row.Scan(&n) },
+		"SELECT requests FROM usage WHERE user_id = $1 AND day = $2", userID, day)
+	if errors.Is(err, errNotFound) {
+		return 0
+	}
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// sessionColumns are the sessions columns scanSession reads, in order.
+const sessionColumns = "id, user_id, user_agent, created_at"
+
+func scanSession(row pgx.Row) (Session, error) {
+	var session Session
+	err := row.Scan(&session.ID, &session.UserID, &session.UserAgent, &session.CreatedAt)
+	session.CreatedAt = session.CreatedAt.UTC()
+	return session, err
+}
+
+func (s *pgStore) CreateSession(session Session) (Session, error) {
+	if _, err := s.GetUser(session.UserID); err != nil {
+		return Session{}, err
+	}
+	session.CreatedAt = s.stamp()
+	session.Current = false
+	err := s.get(func(row pgx.Row) error { return row.Scan(&session.ID) },
+		"INSERT INTO sessions (user_id, user_agent, created_at) VALUES ($1, $2, $3) RETURNING id",
+		session.UserID, session.UserAgent, session.CreatedAt)
+	if err != nil {
+		return Session{}, err
+	}
+	return session, nil
+}
+
+func (s *pgStore) ListSessions(userID int) []Session {
+	sessions := []Session{}
+	s.list(func(row pgx.Row) error {
+		session, err := scanSession(row)
+		sessions = append(sessions, session)
+		return err
+	}, "SELECT "+sessionColumns+" FROM sessions WHERE user_id = $1 AND revoked_at IS NULL ORDER BY id", userID)
+	return sessions
+}
+
+func (s *pgStore) RevokeSession(userID, id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), pgQueryTimeout)
+	defer cancel()
+	tag, err := s.pool.Exec(ctx,
+		"UPDATE sessions SET revoked_at = $3 WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL",
+		id, userID, s.stamp())
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errNotFound
+	}
+	return nil
+}
+
+func (s *pgStore) SessionRevoked(id int) (bool, error) {
+	var revoked bool
+	err := s.get(func(row pgx.Row) error { return row.Scan(&revoked) },
+		"SELECT revoked_at IS NOT NULL FROM sessions WHERE id = $1", id)
+	if errors.Is(err, errNotFound) {
+		return false, nil
+	}
+	return revoked, err
+}
+
+// rotateFieldKeys rewrites every stored email that is not sealed with the
+// primary field key: plaintext from before encryption was turned on, and
+// values sealed with a key since rotated out. It returns how many it
+// rewrote. Once it has run, keys other than the primary can be retired.
+func (s *pgStore) rotateFieldKeys() (int, error) {
+	if s.fields == nil {
+		return 0, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), pgQueryTimeout)
+	defer cancel()
+	n := 0
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, "SELECT id, email FROM users ORDER BY id FOR UPDATE")
+		if err != nil {
+			return err
+		}
+		stale := map[int]string{}
+		for rows.Next() {
+			var id int
+			var stored string
+			if err := rows.Scan(&id, &stored); err != nil {
+				rows.Close()
+				return err
+			}
+			if !s.fields.current(stored) {
+				stale[id] = stored
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for id, stored := range stale {
+			email, err := s.fields.open(emailField, stored)
+			if err != nil {
+				return fmt.Errorf("user %d: %w", id, err)
+			}
+			sealed, index, err := s.sealEmail(email)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, "UPDATE users SET email = $2, email_index = $3 WHERE id = $1", id, sealed, index); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// ========== Snapshot and restore ==========
+
+// pgSequences maps each table with a generated ID to its NextIDs field.
+func pgSequences(ids *NextIDs) []struct {
+	table string
+	next  *int
+} {
+	return []struct {
+		table string
+		next  *int
+	}{
+		{"users", &ids.Users},
+		{"posts", &ids.Posts},
+		{"todos", &ids.Todos},
+		{"albums", &ids.Albums},
+		{"photos", &ids.Photos},
+		{"ingest_events", &ids.IngestEvents},
+		{"webhooks", &ids.Webhooks},
+		{"deliveries", &ids.Deliveries},
+		{"tenants", &ids.Tenants},
+	}
+}
+
+// Snapshot reads every table in one repeatable-read transaction, so the
+// state is consistent.
+func (s *pgStore) Snapshot() State {
+	st := State{
+		Users: []User{}, Posts: []Post{}, Comments: []Comment{}, Todos: []Todo{}, Albums: []Album{}, Photos: []StatePhoto{},
+		Places: []Place{}, IngestEvents: []IngestEvent{}, Webhooks: []Webhook{}, Deliveries: []Delivery{}, Tenants: []Tenant{},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), pgQueryTimeout)
+	defer cancel()
+	err := pgx.BeginTxFunc(ctx, s.pool, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly}, func(tx pgx.Tx) error {
+		queries := []struct {
+			sql  string
+			each func(row pgx.Row) error
+		}{
+			{"SELECT " + userColumns + " FROM users ORDER BY id", func(row pgx.Row) error {
+				u, err := s.scanUser(row)
+				st.Users = append(st.Users, u)
+				return err
+			}},
+			{"SELECT " + postColumns + " FROM posts ORDER BY id", func(row pgx.Row) error {
+				p, err := scanPost(row)
+				st.Posts = append(st.Posts, p)
+				return err
+			}},
+			{"SELECT " + commentColumns + " FROM comments ORDER BY id", func(row pgx.Row) error {
+				c, err := scanComment(row)
+				st.Comments = append(st.Comments, c)
+				return err
+			}},
+			{"SELECT " + todoColumns + " FROM todos ORDER BY id", func(row pgx.Row) error {
+				t, err := scanTodo(row)
+				st.Todos = append(st.Todos, t)
+				return err
+			}},
+			{"SELECT " + albumColumns + " FROM albums ORDER BY id", func(row pgx.Row) error {
+				a, err := scanAlbum(row)
+				st.Albums = append(st.Albums, a)
+				return err
+			}},
+			{"SELECT " + photoColumns + ", data FROM photos ORDER BY id", func(row pgx.Row) error {
+				var p StatePhoto
+				err := row.Scan(&p.ID, &p.AlbumID, &p.Title, &p.ContentType, &p.Width, &p.Height, &p.Version, &p.Data)
+				st.Photos = append(st.Photos, p)
+				return err
+			}},
+			{"SELECT " + placeColumns + " FROM places ORDER BY id", func(row pgx.Row) error {
+				p, err := scanPlace(row)
+				st.Places = append(st.Places, p)
+				return err
+			}},
+			{"SELECT " + ingestEventColumns + " FROM ingest_events ORDER BY id", func(row pgx.Row) error {
+				e, err := scanIngestEvent(row)
+				st.IngestEvents = append(st.IngestEvents, e)
+				return err
+			}},
+			{"SELECT " + webhookColumns + " FROM webhooks ORDER BY id", func(row pgx.Row) error {
+				w, err := scanWebhook(row)
+				st.Webhooks = append(st.Webhooks, w)
+				return err
+			}},
+			{"SELECT " + deliveryColumns + " FROM deliveries ORDER BY id", func(row pgx.Row) error {
+				d, err := scanDelivery(row)
+				st.Deliveries = append(st.Deliveries, d)
+				return err
+			}},
+			{"SELECT " + tenantColumns + " FROM tenants ORDER BY id", func(row pgx.Row) error {
+				t, err := scanTenant(row)
+				st.Tenants = append(st.Tenants, t)
+				return err
+			}},
+			{"SELECT flags FROM feature_flags", func(row pgx.Row) error { return row.Scan(&st.Flags) }},
+		}
+		for _, q := range queries {
+			if err := queryEach(ctx, tx, q.each, q.sql); err != nil {
+				return err
+			}
+		}
+		for _, seq := range pgSequences(&st.NextIDs) {
+			var name string
+			if err := tx.QueryRow(ctx, "SELECT pg_get_serial_sequence($1, 'id')", seq.table).Scan(&name); err != nil {
+				return err
+			}
+			// name comes from the catalog and is already quoted.
+			if err := tx.QueryRow(ctx, "SELECT CASE WHEN is_called THEN last_value + 1 ELSE last_value END FROM "+name).Scan(seq.next); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+	return st
+}
+
+func (s *pgStore) Restore(st State) {
+	if err := s.restore(st); err != nil {
+		panic(err)
+	}
+}
+
+// restore replaces every table's rows in one transaction and moves each
+// ID sequence to the restored next ID.
+func (s *pgStore) restore(st State) error {
+	now := s.stamp()
+	st.Posts = withSlugs(st.Posts)
+	emails := make([][2]string, len(st.Users))
+	for i, u := range st.Users {
+		stored, index, err := s.sealEmail(u.Email)
+		if err != nil {
+			return err
+		}
+		emails[i] = [2]string{stored, index}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), pgQueryTimeout)
+	defer cancel()
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "TRUNCATE users, posts, comments, todos, albums, photos, places, ingest_events, webhooks, deliveries, tenants"); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, "UPDATE sessions SET revoked_at = $1 WHERE revoked_at IS NULL", now); err != nil {
+			return err
+		}
+		copies := []struct {
+			table   string
+			columns []string
+			n       int
+			row     func(i int) []interface{}
+		}{
+			{"users", []string{"id", "name", "email", "email_index", "version", "post_count", "verified", "created_at", "updated_at"}, len(st.Users), func(i int) []interface{} {
+				u := st.Users[i]
+				created, updated := restoredTimes(u.CreatedAt, u.UpdatedAt, now)
+				return []interface{}{u.ID, u.Name, emails[i][0], emails[i][1], u.Version, u.PostCount, u.Verified, created, updated}
+			}},
+			{"posts", []string{"id", "user_id", "title", "slug", "body", "version", "created_at", "updated_at"}, len(st.Posts), func(i int) []interface{} {
+				p := st.Posts[i]
+				created, updated := restoredTimes(p.CreatedAt, p.UpdatedAt, now)
+				return []interface{}{p.ID, p.UserID, p.Title, p.Slug, p.Body, p.Version, created, updated}
+			}},
+			{"comments", []string{"id", "post_id", "user_id", "body", "created_at", "updated_at"}, len(st.Comments), func(i int) []interface{} {
+				c := st.Comments[i]
+				created, updated := restoredTimes(c.CreatedAt, c.UpdatedAt, now)
+				return []interface{}{c.ID, c.PostID, c.UserID, c.Body, created, updated}
+			}},
+			{"todos", []string{"id", "title", "completed", "priority", "due", "version", "created_at", "updated_at"}, len(st.Todos), func(i int) []interface{} {
+				t := st.Todos[i]
+				created, updated := restoredTimes(t.CreatedAt, t.UpdatedAt, now)
+				return []interface{}{t.ID, t.Title, t.Completed, string(t.Priority), t.Due, t.Version, created, updated}
+			}},
+			{"albums", []string{"id", "user_id", "title", "version", "created_at", "updated_at"}, len(st.Albums), func(i int) []interface{} {
+				a := st.Albums[i]
+				created, updated := restoredTimes(a.CreatedAt, a.UpdatedAt, now)
+				return []interface{}{a.ID, a.UserID, a.Title, a.Version, created, updated}
+			}},
+			{"photos", []string{"id", "album_id", "title", "content_type", "width", "height", "version", "created_at", "updated_at", "data"}, len(st.Photos), func(i int) []interface{} {
+				p := st.Photos[i]
+				created, updated := restoredTimes(p.CreatedAt, p.UpdatedAt, now)
+				return []interface{}{p.ID, p.AlbumID, p.Title, p.ContentType, p.Width, p.Height, p.Version, created, updated, p.Data}
+			}},
+			{"places", []string{"id", "name", "lat", "lng"}, len(st.Places), func(i int) []interface{} {
+				p := st.Places[i]
+				return []interface{}{p.ID, p.Name, p.Lat, p.Lng}
+			}},
+			{"ingest_events", []string{"id", "received_at", "payload"}, len(st.IngestEvents), func(i int) []interface{} {
+				e := st.IngestEvents[i]
+				return []interface{}{e.ID, e.ReceivedAt, e.Payload}
+			}},
+			{"webhooks", []string{"id", "url", "events", "version"}, len(st.Webhooks), func(i int) []interface{} {
+				w := st.Webhooks[i]
+				return []interface{}{w.ID, w.URL, w.Events, w.Version}
+			}},
+			{"deliveries", []string{"id", "webhook_id", "event", "status", "attempts", "created_at", "next_attempt_at", "last_status_code", "last_error"}, len(st.Deliveries), func(i int) []interface{} {
+				d := st.Deliveries[i]
+				return []interface{}{d.ID, d.WebhookID, d.Event, string(d.Status), d.Attempts, d.CreatedAt, d.NextAttemptAt, d.LastStatusCode, d.LastError}
+			}},
+			{"tenants", []string{"id", "name", "rate_limit", "flags", "allowed_origins", "version"}, len(st.Tenants), func(i int) []interface{} {
+				t := st.Tenants[i]
+				return []interface{}{t.ID, t.Name, t.RateLimit, t.Flags, t.AllowedOrigins, t.Version}
+			}},
+		}
+		for _, c := range copies {
+			rows := pgx.CopyFromSlice(c.n, func(i int) ([]interface{}, error) { return c.row(i), nil })
+			if _, err := tx.CopyFrom(ctx, pgx.Identifier{c.table}, c.columns, rows); err != nil {
+				return fmt.Errorf("restore %s: %w", c.table, err)
+			}
+		}
+		if _, err := tx.Exec(ctx, "UPDATE feature_flags SET flags = $1", st.Flags); err != nil {
+			return err
+		}
+		next := st.NextIDs
+		for _, seq := range pgSequences(&next) {
+			// The sequence's next value is the larger of the requested
+			// one and one past the largest restored ID.
+			_, err := tx.Exec(ctx, "SELECT setval(pg_get_serial_sequence($1, 'id'), greatest($2::integer, (SELECT coalesce(max(id), 0) + 1 FROM "+seq.table+")), false)",
+				seq.table, max(*seq.next, 1))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *pgStore) Info() StoreInfo {
+	return StoreInfo{Backend: "postgres"}
+}
+
+func (s *pgStore) Ping(ctx context.Context) error {
+	return s.pool.Ping(ctx)
+}
+
+// DBStats maps pgxpool's statistics onto DBStats. Waits are acquires that
+// found no idle connection.
+func (s *pgStore) DBStats() DBStats {
+	st := s.pool.Stat()
+	return DBStats{
+		MaxOpenConnections: int(st.MaxConns()),
+		OpenConnections:    int(st.TotalConns()),
+		InUse:              int(st.AcquiredConns()),
+		Idle:               int(st.IdleConns()),
+		WaitCount:          st.EmptyAcquireCount(),
+		WaitDurationMS:     float64(st.AcquireDuration()) / float64(time.Millisecond),
+		MaxIdleClosed:      st.MaxIdleDestroyCount(),
+		MaxLifetimeClosed:  st.MaxLifetimeDestroyCount(),
+	}
+}
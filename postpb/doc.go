@@ -0,0 +1,5 @@
+// Package postpb holds the Protocol Buffers messages that
+// application/x-protobuf post bodies carry, generated from post.proto.
+package postpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative post.proto
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/api2spec/api2spec-fixture-chi/postpb"
+)
+
+// protobufCodec reads and writes posts, and lists of them, as the messages
+// in postpb/post.proto. It has no representation for other bodies, so only
+// post routes offer it.
+type protobufCodec struct{}
+
+func (protobufCodec) MediaType() string { return "application/x-protobuf" }
+
+func (protobufCodec) Encode(w io.Writer, v interface{}) error {
+	var m proto.Message
+	switch v := v.(type) {
+	case Post:
+		m = postToProto(v)
+	case []Post:
+		list := &postpb.PostList{Posts: make([]*postpb.Post, len(v))}
+		for i, p := range v {
+			list.Posts[i] = postToProto(p)
+		}
+		m = list
+	default:
+		return fmt.Errorf("protobuf: no message for %T", v)
+	}
+	data, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (protobufCodec) Decode(r io.Reader, v interface{}) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case *Post:
+		var m postpb.Post
+		if err := unmarshalProto(data, &m); err != nil {
+			return err
+		}
+		*v = postFromProto(&m)
+	case *[]Post:
+		var m postpb.PostList
+		if err := unmarshalProto(data, &m); err != nil {
+			return err
+		}
+		*v = make([]Post, len(m.Posts))
+		for i, p := range m.Posts {
+			(*v)[i] = postFromProto(p)
+		}
+	default:
+		return fmt.Errorf("protobuf: no message for %T", v)
+	}
+	return nil
+}
+
+// unmarshalProto is proto.Unmarshal, rejecting fields post.proto does not
+// declare as JSON decoding rejects unknown members.
+func unmarshalProto(data []byte, m proto.Message) error {
+	if err := proto.Unmarshal(data, m); err != nil {
+		return err
+	}
+	if len(m.ProtoReflect().GetUnknown()) > 0 {
+		return fmt.Errorf("protobuf: unknown fields in %s", m.ProtoReflect().Descriptor().FullName())
+	}
+	return nil
+}
+
+func postToProto(p Post) *postpb.Post {
+	return &postpb.Post{
+		Id:      int64(p.ID),
+		UserId:  int64(p.UserID),
+		Title:   p.Title,
+		Body:    p.Body,
+		Version: int64(p.Version),
+		Slug:    p.Slug,
+	}
+}
+
+func postFromProto(m *postpb.Post) Post {
+	return Post{
+		ID:      int(m.GetId()),
+		UserID:  int(m.GetUserId()),
+		Title:   m.GetTitle(),
+		Body:    m.GetBody(),
+		Version: int(m.GetVersion()),
+		Slug:    m.GetSlug(),
+	}
+}
+
+var protobufPosts = protobufCodec{}
+
+// postCodecs are the formats, besides JSON, that post routes negotiate.
+var postCodecs = []Codec{msgpackCodec, cborCodec, protobufPosts}
+
+// protobufBodyDecoder decodes application/x-protobuf bodies for the request
+// validator into the values encoding/json would decode the equivalent JSON
+// into: a PostList for an array schema, a Post otherwise.
+func protobufBodyDecoder(body io.Reader, _ http.Header, schema *openapi3.SchemaRef, _ openapi3filter.EncodingFn) (interface{}, error) {
+	var v interface{} = &Post{}
+	if schema != nil && schema.Value != nil && schema.Value.Type.Is(openapi3.TypeArray) {
+		v = &[]Post{}
+	}
+	if err := protobufPosts.Decode(body, v); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var tree interface{}
+	err = json.Unmarshal(data, &tree)
+	return tree, err
+}
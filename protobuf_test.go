@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/api2spec/api2spec-fixture-chi/postpb"
+)
+
+// ========== Protobuf Tests ==========
+
+func TestProtobufCodec_RoundTrip(t *testing.T) {
+	post := Post{ID: 3, UserID: 2, Title: "Wire", Body: "Format", Version: 4}
+	var got Post
+	require.NoError(t, protobufPosts.Decode(bytes.NewReader(encodeBody(t, protobufPosts, post)), &got))
+	assert.Equal(t, post, got)
+
+	posts := []Post{post, {ID: 4, UserID: 1, Title: "Second"}}
+	var gotPosts []Post
+	require.NoError(t, protobufPosts.Decode(bytes.NewReader(encodeBody(t, protobufPosts, posts)), &gotPosts))
+	assert.Equal(t, posts, gotPosts)
+
+	var empty []Post
+	require.NoError(t, protobufPosts.Decode(bytes.NewReader(encodeBody(t, protobufPosts, []Post{})), &empty))
+	assert.Empty(t, empty)
+}
+
+func TestProtobufCodec_MatchesGeneratedMessages(t *testing.T) {
+	data := encodeBody(t, protobufPosts, Post{ID: 1, UserID: 2, Title: "T", Body: "B", Version: 3})
+	var m postpb.Post
+	require.NoError(t, proto.Unmarshal(data, &m))
+	assert.True(t, proto.Equal(&postpb.Post{Id: 1, UserId: 2, Title: "T", Body: "B", Version: 3}, &m))
+}
+
+func TestProtobufCodec_Strict(t *testing.T) {
+	data, err := proto.Marshal(&postpb.Post{UserId: 1, Title: "Extra"})
+	require.NoError(t, err)
+	data = protowire.AppendTag(data, 9, protowire.VarintType)
+	data = protowire.AppendVarint(data, 1)
+
+	var post Post
+	assert.ErrorContains(t, protobufPosts.Decode(bytes.NewReader(data), &post), "unknown fields")
+	assert.Error(t, protobufPosts.Decode(bytes.NewReader([]byte{0xff}), &post))
+	assert.Error(t, protobufPosts.Encode(&bytes.Buffer{}, User{}), "users have no message")
+}
+
+func TestProtobuf_CreateAndListPosts(t *testing.T) {
+	router := setupRouter()
+	body := encodeBody(t, protobufPosts, Post{UserID: 2, Title: "Over the wire", Body: "Binary"})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newCodecRequest(http.MethodPost, "/posts", protobufPosts, body, "application/x-protobuf"))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, "application/x-protobuf", w.Header().Get("Content-Type"))
+	var created postpb.Post
+	require.NoError(t, proto.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "Over the wire", created.GetTitle())
+	assert.NotZero(t, created.GetId())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newCodecRequest(http.MethodGet, "/posts", nil, nil, "application/x-protobuf"))
+	require.Equal(t, http.StatusOK, w.Code)
+	var list postpb.PostList
+	require.NoError(t, proto.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.GetPosts(), 3)
+	assert.True(t, proto.Equal(&created, list.GetPosts()[2]))
+}
+
+func TestProtobuf_OnlyPostRoutes(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+	}{
+		{"post", "/posts/1", "application/x-protobuf"},
+		{"user", "/users/1", "application/json"},
+		{"user's posts", "/users/1/posts", "application/json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, newCodecRequest(http.MethodGet, tt.path, nil, nil, "application/x-protobuf"))
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// proxyPrefix is the mount path of the passthrough routes; the service
+// they forward to is its parameter.
+const proxyPrefix = "/proxy/{service}"
+
+// proxyLatencyBudget is the proxy routes' latency budget, which covers
+// the upstream's time as well as ours.
+const proxyLatencyBudget = 10 * time.Second
+
+// proxyBackoff is the wait before a proxied request's first retry,
+// doubled before each later one.
+const proxyBackoff = 100 * time.Millisecond
+
+// proxyCredentialHeaders are the request headers carrying the client's
+// credentials for this API, which only the services in
+// ProxyConfig.ForwardCredentials are sent.
+var proxyCredentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// proxyVia is the Via header entry the proxy adds both ways.
+const proxyVia = "1.1 " + collectionName
+
+// proxy forwards a request under /proxy/{service} to the service's
+// configured base URL, with the rest of the path and the query appended:
+// /proxy/billing/invoices?page=2 goes to <billing>/invoices?page=2. The
+// upstream sees X-Forwarded-For, -Host and -Proto and a Via header, and
+// the client's credentials only if it is trusted with them; its
+// response comes back with a Via header, any Location under its base URL
+// rewritten to the proxied path, and the route's Cache-Control unless it
+// sent its own.
+func (srv *Server) proxy(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "service")
+	base, ok := srv.Config.Proxy.Services[name]
+	if !ok {
+		respondProblem(w, r, http.StatusNotFound, "not found", Problem{Detail: fmt.Sprintf("no upstream service %q is configured", name)})
+		return
+	}
+	target, err := url.Parse(base)
+	if err != nil {
+		srv.logAt("error", "proxy: service %s: %v", name, err)
+		respondError(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+	prefix := "/proxy/" + name
+
+	ctx := r.Context()
+	if timeout := srv.Config.Proxy.Timeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	r = r.WithContext(ctx)
+	if r.Body != nil && r.Body != http.NoBody {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	}
+	if idempotent(r.Method) && r.Body != nil && r.Body != http.NoBody {
+		// Buffered, the body can be sent again on a retry.
+		body, err := io.ReadAll(r.Body)
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			respondError(w, r, http.StatusRequestEntityTooLarge, "request body too large")
+			return
+		}
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, "unreadable request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		r.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	}
+
+	policy := w.Header().Get("Cache-Control")
+	w.Header().Del("Cache-Control")
+	rp := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL.Path = strings.TrimPrefix(pr.In.URL.Path, prefix)
+			pr.Out.URL.RawPath = strings.TrimPrefix(pr.In.URL.RawPath, prefix)
+			pr.SetURL(target)
+			pr.SetXForwarded()
+			pr.Out.Header.Add("Via", proxyVia)
+			if !slices.Contains(srv.Config.Proxy.ForwardCredentials, name) {
+				for _, h := range proxyCredentialHeaders {
+					pr.Out.Header.Del(h)
+				}
+			}
+		},
+		Transport: retryTransport{next: http.DefaultTransport, retries: srv.Config.Proxy.Retries, backoff: proxyBackoff},
+		ModifyResponse: func(resp *http.Response) error {
+			upstream := strings.TrimSuffix(target.String(), "/")
+			if loc := resp.Header.Get("Location"); loc == upstream || strings.HasPrefix(loc, upstream+"/") {
+				resp.Header.Set("Location", prefix+strings.TrimPrefix(loc, upstream))
+			}
+			if resp.Header.Get("Cache-Control") == "" && policy != "" {
+				resp.Header.Set("Cache-Control", policy)
+			}
+			resp.Header.Add("Via", proxyVia)
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			var maxErr *http.MaxBytesError
+			switch {
+			case errors.As(err, &maxErr):
+				respondError(w, r, http.StatusRequestEntityTooLarge, "request body too large")
+			case errors.Is(err, context.DeadlineExceeded):
+				respondError(w, r, http.StatusGatewayTimeout, "upstream timed out")
+			case errors.Is(err, context.Canceled):
+				// The client went away.
+			default:
+				srv.logAt("warn", "proxy: %s %s: %v", r.Method, r.URL.Path, err)
+				respondError(w, r, http.StatusBadGateway, "upstream unavailable")
+			}
+		},
+	}
+	rp.ServeHTTP(w, r)
+}
+
+// idempotent reports whether requests with method may be sent twice.
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryTransport sends idempotent requests again, up to retries times with
+// exponential backoff, while the upstream cannot be reached or answers
+// 502, 503 or 504.
+type retryTransport struct {
+	next    http.RoundTripper
+	retries int
+	backoff time.Duration
+	// retried, if set, is called before each further attempt.
+	retried func()
+}
+
+func (t retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	for attempt := 0; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+		if attempt >= t.retries || !idempotent(req.Method) || !replayable || req.Context().Err() != nil {
+			return resp, err
+		}
+		if err == nil {
+			switch resp.StatusCode {
+			case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			default:
+				return resp, nil
+			}
+		}
+
+		select {
+		case <-time.After(t.backoff << attempt):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		if t.retried != nil {
+			t.retried()
+		}
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
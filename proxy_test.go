@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== Reverse Proxy Tests ==========
+
+// newEchoUpstream starts an upstream that describes each request it gets
+// in a JSON answer, and returns its base URL, which has the path /api. It
+// answers POSTs with a Location under that URL.
+func newEchoUpstream(t *testing.T) string {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method == http.MethodPost {
+			w.Header().Set("Location", "http://"+r.Host+"/api/things/1")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"method":         r.Method,
+			"path":           r.URL.Path,
+			"query":          r.URL.RawQuery,
+			"body":           string(body),
+			"via":            r.Header.Get("Via"),
+			"forwardedHost":  r.Header.Get("X-Forwarded-Host"),
+			"forwardedProto": r.Header.Get("X-Forwarded-Proto"),
+			"authorization":  r.Header.Get("Authorization"),
+			"cookie":         r.Header.Get("Cookie"),
+		})
+	}))
+	t.Cleanup(ts.Close)
+	return ts.URL + "/api"
+}
+
+// flakyUpstream fails its first failures requests with 503, and counts
+// the requests and bodies it gets.
+type flakyUpstream struct {
+	mu       sync.Mutex
+	failures int
+	bodies   []string
+}
+
+func newFlakyUpstream(t *testing.T, failures int) (*flakyUpstream, string) {
+	u := &flakyUpstream{failures: failures}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		u.bodies = append(u.bodies, string(body))
+		if len(u.bodies) <= u.failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return u, ts.URL
+}
+
+func (u *flakyUpstream) attempts() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.bodies
+}
+
+// setupProxyRouter returns a router proxying /proxy/{name} to each
+// service's URL, with one retry.
+func setupProxyRouter(services map[string]string) http.Handler {
+	router := setupRouter()
+	server.Config.Proxy = ProxyConfig{Services: services, Timeout: time.Second, Retries: 1}
+	return router
+}
+
+func TestProxy_Forwards(t *testing.T) {
+	router := setupProxyRouter(map[string]string{"echo": newEchoUpstream(t)})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/proxy/echo/things/a%2Fb?page=2", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var echo map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &echo))
+	assert.Equal(t, "GET", echo["method"])
+	assert.Equal(t, "/api/things/a/b", echo["path"])
+	assert.Equal(t, "page=2", echo["query"])
+	assert.Equal(t, proxyVia, echo["via"])
+	assert.Equal(t, "example.com", echo["forwardedHost"])
+	assert.Equal(t, "http", echo["forwardedProto"])
+
+	assert.Equal(t, proxyVia, w.Header().Get("Via"))
+	assert.Equal(t, cacheNoStore, w.Header().Get("Cache-Control"), "the route's policy when the upstream sets none")
+}
+
+func TestProxy_Credentials(t *testing.T) {
+	echo := newEchoUpstream(t)
+	router := setupProxyRouter(map[string]string{"echo": echo, "trusted": echo})
+	server.Config.Proxy.ForwardCredentials = []string{"trusted"}
+
+	for service, forwarded := range map[string]bool{"echo": false, "trusted": true} {
+		req := httptest.NewRequest(http.MethodGet, "/proxy/"+service+"/things", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("Cookie", "session=abc")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var got map[string]string
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		if forwarded {
+			assert.Equal(t, "Bearer secret", got["authorization"], service)
+			assert.Equal(t, "session=abc", got["cookie"], service)
+		} else {
+			assert.Empty(t, got["authorization"], service)
+			assert.Empty(t, got["cookie"], service)
+		}
+	}
+}
+
+func TestProxy_RewritesLocation(t *testing.T) {
+	router := setupProxyRouter(map[string]string{"echo": newEchoUpstream(t)})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/proxy/echo/things", `{"name":"Widget"}`))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "/proxy/echo/things/1", w.Header().Get("Location"))
+	assert.Contains(t, w.Body.String(), `"body":"{\"name\":\"Widget\"}"`)
+}
+
+func TestProxy_KeepsUpstreamCacheControl(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=300")
+	}))
+	defer ts.Close()
+	router := setupProxyRouter(map[string]string{"cdn": ts.URL})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/proxy/cdn/logo", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"public, max-age=300"}, w.Header().Values("Cache-Control"))
+}
+
+func TestProxy_UnknownService(t *testing.T) {
+	router := setupProxyRouter(nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/proxy/billing/invoices", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), `no upstream service \"billing\" is configured`)
+}
+
+func TestProxy_RetriesIdempotentRequests(t *testing.T) {
+	upstream, url := newFlakyUpstream(t, 1)
+	router := setupProxyRouter(map[string]string{"flaky": url})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPut, "/proxy/flaky/things/1", `{"name":"Gadget"}`))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{`{"name":"Gadget"}`, `{"name":"Gadget"}`}, upstream.attempts(), "the body is sent again")
+}
+
+func TestProxy_GivesUpAfterRetries(t *testing.T) {
+	upstream, url := newFlakyUpstream(t, 5)
+	router := setupProxyRouter(map[string]string{"flaky": url})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/proxy/flaky/things", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, "the last answer is passed on")
+	assert.Len(t, upstream.attempts(), 2)
+}
+
+func TestProxy_DoesNotRetryPOST(t *testing.T) {
+	upstream, url := newFlakyUpstream(t, 1)
+	router := setupProxyRouter(map[string]string{"flaky": url})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/proxy/flaky/things", `{"name":"Widget"}`))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Len(t, upstream.attempts(), 1)
+}
+
+func TestProxy_Unreachable(t *testing.T) {
+	ts := httptest.NewServer(nil)
+	ts.Close()
+	router := setupProxyRouter(map[string]string{"down": ts.URL})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/proxy/down/things", nil))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+}
+
+func TestProxy_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer ts.Close()
+	defer close(release)
+	router := setupProxyRouter(map[string]string{"slow": ts.URL})
+	server.Config.Proxy.Timeout = 50 * time.Millisecond
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/proxy/slow/things", nil))
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+}
+
+func TestProxy_BodyTooLarge(t *testing.T) {
+	router := setupProxyRouter(map[string]string{"echo": newEchoUpstream(t)})
+	body := `"` + strings.Repeat("x", maxBodyBytes) + `"`
+
+	for _, method := range []string{http.MethodPut, http.MethodPost} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newAdminRequest(method, "/proxy/echo/things", body))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code, method)
+	}
+}
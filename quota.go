@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// quotaDay returns the UTC date of t and when that day ends.
+func quotaDay(t time.Time) (day, reset time.Time) {
+	t = t.UTC()
+	day = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return day, day.AddDate(0, 0, 1)
+}
+
+// enforceQuota counts each authenticated request in the store and, when
+// there is a daily quota, sets the X-Quota-* headers. Once the quota is
+// used up it answers 429 with Retry-After until the next UTC day.
+// Unauthenticated requests are not counted, and if the store cannot count
+// a request it is let through.
+func (srv *Server) enforceQuota(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := principalFrom(r.Context())
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		now := srv.Clock.Now()
+		day, reset := quotaDay(now)
+		used, err := srv.requestStore(r).ChargeUsage(p.UserID, day, srv.Config.DailyQuota)
+		if err != nil && !errors.Is(err, errQuotaExceeded) {
+			srv.logAt("warn", "quota: user %d: %v; not counted", p.UserID, err)
+			next.ServeHTTP(w, r)
+			return
+		}
+		if srv.Config.DailyQuota <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("X-Quota-Limit", strconv.Itoa(srv.Config.DailyQuota))
+		w.Header().Set("X-Quota-Remaining", strconv.Itoa(max(srv.Config.DailyQuota-used, 0)))
+		w.Header().Set("X-Quota-Reset", strconv.FormatInt(reset.Unix(), 10))
+		if err != nil {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(reset.Sub(now).Seconds()))))
+			respondError(w, r, http.StatusTooManyRequests, "quota exceeded")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Usage is the body of GET /me/usage: the caller's requests today.
+type Usage struct {
+	UserID int `json:"userId"`
+	// Day is the UTC date being counted, as YYYY-MM-DD.
+	Day      string `json:"day"`
+	Requests int    `json:"requests"`
+	// Limit and Remaining are omitted when there is no quota.
+	Limit     int  `json:"limit,omitempty"`
+	Remaining *int `json:"remaining,omitempty"`
+	// Reset is when the count starts again from zero.
+	Reset time.Time `json:"reset"`
+}
+
+// getMyUsage reports the caller's consumption of their daily quota. The
+// request itself is included.
+func (srv *Server) getMyUsage(w http.ResponseWriter, r *http.Request) {
+	p, _ := principalFrom(r.Context())
+	day, reset := quotaDay(srv.Clock.Now())
+	usage := Usage{
+		UserID:   p.UserID,
+		Day:      day.Format(time.DateOnly),
+		Requests: srv.requestStore(r).Usage(p.UserID, day),
+		Reset:    reset,
+	}
+	if srv.Config.DailyQuota > 0 {
+		remaining := max(srv.Config.DailyQuota-usage.Requests, 0)
+		usage.Limit, usage.Remaining = srv.Config.DailyQuota, &remaining
+	}
+	respondJSON(w, http.StatusOK, usage)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useQuota sets the server's daily quota to limit and stops its clock at
+// now. setupRouter undoes both.
+func useQuota(t *testing.T, limit int, now time.Time) {
+	t.Helper()
+	server.Config.DailyQuota = limit
+	server.Clock = &fakeClock{t: now}
+}
+
+// ========== Quota Tests ==========
+
+func TestQuotaDay(t *testing.T) {
+	day, reset := quotaDay(time.Date(2024, 3, 9, 23, 30, 0, 0, time.FixedZone("", -2*60*60)))
+
+	assert.Equal(t, time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC), day)
+	assert.Equal(t, time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC), reset)
+}
+
+func TestEnforceQuota_Headers(t *testing.T) {
+	router := setupRouter()
+	useAuthSecret(t)
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	useQuota(t, 3, now)
+
+	for remaining := 2; remaining >= 0; remaining-- {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUserRequest(1, http.MethodGet, "/users", ""))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "3", w.Header().Get("X-Quota-Limit"))
+		assert.Equal(t, strconv.Itoa(remaining), w.Header().Get("X-Quota-Remaining"))
+		assert.Equal(t, "1710115200", w.Header().Get("X-Quota-Reset"))
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newUserRequest(1, http.MethodGet, "/users", ""))
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("X-Quota-Remaining"))
+	assert.Equal(t, "43200", w.Header().Get("Retry-After"))
+}
+
+func TestEnforceQuota_PerUser(t *testing.T) {
+	router := setupRouter()
+	useAuthSecret(t)
+	useQuota(t, 1, time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC))
+
+	for _, userID := range []int{1, 2} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newUserRequest(userID, http.MethodGet, "/users", ""))
+		assert.Equal(t, http.StatusOK, w.Code, "user %d", userID)
+	}
+}
+
+func TestEnforceQuota_Anonymous(t *testing.T) {
+	router := setupRouter()
+	useQuota(t, 1, time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC))
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("X-Quota-Limit"))
+	}
+}
+
+func TestEnforceQuota_ResetsNextDay(t *testing.T) {
+	router := setupRouter()
+	useAuthSecret(t)
+	now := time.Date(2024, 3, 10, 23, 59, 0, 0, time.UTC)
+	useQuota(t, 1, now)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newUserRequest(1, http.MethodGet, "/users", ""))
+	require.Equal(t, http.StatusOK, w.Code)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newUserRequest(1, http.MethodGet, "/users", ""))
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	server.Clock = &fakeClock{t: now.Add(2 * time.Minute)}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newUserRequest(1, http.MethodGet, "/users", ""))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+// ========== Usage Endpoint Tests ==========
+
+func TestGetMyUsage(t *testing.T) {
+	router := setupRouter()
+	useAuthSecret(t)
+	useQuota(t, 10, time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC))
+
+	router.ServeHTTP(httptest.NewRecorder(), newUserRequest(1, http.MethodGet, "/users", ""))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newUserRequest(1, http.MethodGet, "/me/usage", ""))
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	var usage Usage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &usage))
+	remaining := 8
+	assert.Equal(t, Usage{
+		UserID:    1,
+		Day:       "2024-03-10",
+		Requests:  2,
+		Limit:     10,
+		Remaining: &remaining,
+		Reset:     time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC),
+	}, usage)
+}
+
+func TestGetMyUsage_Unlimited(t *testing.T) {
+	router := setupRouter()
+	useAuthSecret(t)
+	useQuota(t, 0, time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newUserRequest(1, http.MethodGet, "/me/usage", ""))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("X-Quota-Limit"))
+	assert.Contains(t, w.Body.String(), `"requests":1`)
+	assert.NotContains(t, w.Body.String(), "remaining")
+	assert.NotContains(t, w.Body.String(), "limit")
+}
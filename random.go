@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+)
+
+// Sample sizes for GET /users/sample.
+const (
+	defaultSampleSize = 1
+	maxSampleSize     = 100
+)
+
+// requestRand returns the random source for r: seeded by ?seed=, so that
+// the same seed picks the same items from the same store, or randomly. A
+// seeded answer is repeatable, so it may be cached like a collection;
+// others must not be.
+func requestRand(w http.ResponseWriter, r *http.Request) (*rand.Rand, []string) {
+	v := r.URL.Query().Get("seed")
+	if v == "" {
+		return rand.New(rand.NewSource(rand.Int63())), nil
+	}
+	seed, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return nil, []string{fmt.Sprintf("query parameter %q: must be an integer", "seed")}
+	}
+	w.Header().Set("Cache-Control", cachePublic)
+	return rand.New(rand.NewSource(seed)), nil
+}
+
+// randomPost returns a post picked at random.
+func (srv *Server) randomPost(w http.ResponseWriter, r *http.Request) {
+	rng, violations := requestRand(w, r)
+	if len(violations) > 0 {
+		respondProblem(w, r, http.StatusBadRequest, "invalid request", Problem{Violations: violations})
+		return
+	}
+	posts := srv.requestStore(r).ListPosts()
+	if len(posts) == 0 {
+		respondError(w, r, http.StatusNotFound, "no posts")
+		return
+	}
+	respondJSON(w, http.StatusOK, posts[rng.Intn(len(posts))])
+}
+
+// sampleUsers returns ?n= distinct users picked at random, in the order
+// picked, or every user, shuffled, if there are fewer.
+func (srv *Server) sampleUsers(w http.ResponseWriter, r *http.Request) {
+	rng, violations := requestRand(w, r)
+	n := defaultSampleSize
+	if v := r.URL.Query().Get("n"); v != "" {
+		var err error
+		if n, err = strconv.Atoi(v); err != nil || n < 1 || n > maxSampleSize {
+			violations = append(violations, fmt.Sprintf("query parameter %q: must be an integer between 1 and %d", "n", maxSampleSize))
+		}
+	}
+	if len(violations) > 0 {
+		respondProblem(w, r, http.StatusBadRequest, "invalid request", Problem{Violations: violations})
+		return
+	}
+	users := srv.requestStore(r).ListUsers()
+	sample := make([]User, 0, min(n, len(users)))
+	for _, i := range rng.Perm(len(users))[:cap(sample)] {
+		sample = append(sample, users[i])
+	}
+	respondJSON(w, http.StatusOK, sample)
+}
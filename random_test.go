@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getRandom(t *testing.T, router http.Handler, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+// ========== Random Post Tests ==========
+
+func TestRandomPost_Seeded(t *testing.T) {
+	router := setupRouter()
+	for i := 0; i < 8; i++ {
+		_, err := server.Store.CreatePost(Post{UserID: 2, Title: "Post " + string(rune('A'+i))})
+		require.NoError(t, err)
+	}
+
+	first := getRandom(t, router, "/posts/random?seed=42")
+	require.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, cachePublic, first.Header().Get("Cache-Control"))
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, first.Body.String(), getRandom(t, router, "/posts/random?seed=42").Body.String())
+	}
+
+	picked := map[int]bool{}
+	for seed := 0; seed < 20; seed++ {
+		var p Post
+		w := getRandom(t, router, "/posts/random?seed="+strconv.Itoa(seed))
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+		picked[p.ID] = true
+	}
+	assert.Greater(t, len(picked), 1, "seeds pick different posts")
+}
+
+func TestRandomPost_Unseeded(t *testing.T) {
+	router := setupRouter()
+
+	w := getRandom(t, router, "/posts/random")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, cacheNoStore, w.Header().Get("Cache-Control"))
+}
+
+func TestRandomPost_NoPosts(t *testing.T) {
+	router := setupRouter()
+	require.NoError(t, server.Store.DeletePost(1, time.Time{}))
+	require.NoError(t, server.Store.DeletePost(2, time.Time{}))
+
+	assert.Equal(t, http.StatusNotFound, getRandom(t, router, "/posts/random").Code)
+}
+
+// ========== User Sample Tests ==========
+
+func TestSampleUsers(t *testing.T) {
+	router := setupRouter()
+	for _, name := range []string{"Carol", "Dave", "Erin"} {
+		_, err := server.Store.CreateUser(User{Name: name})
+		require.NoError(t, err)
+	}
+
+	var sample []User
+	w := getRandom(t, router, "/users/sample?n=3&seed=7")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sample))
+	require.Len(t, sample, 3)
+	ids := map[int]bool{}
+	for _, u := range sample {
+		ids[u.ID] = true
+	}
+	assert.Len(t, ids, 3, "users are distinct")
+	assert.Equal(t, w.Body.String(), getRandom(t, router, "/users/sample?n=3&seed=7").Body.String())
+
+	require.NoError(t, json.Unmarshal(getRandom(t, router, "/users/sample?n=100").Body.Bytes(), &sample))
+	assert.Len(t, sample, 5, "every user when there are fewer")
+
+	require.NoError(t, json.Unmarshal(getRandom(t, router, "/users/sample").Body.Bytes(), &sample))
+	assert.Len(t, sample, 1)
+}
+
+func TestSampleUsers_Validation(t *testing.T) {
+	router := setupRouter()
+
+	for _, query := range []string{"n=0", "n=101", "n=x", "seed=x", "seed=1.5"} {
+		w := getRandom(t, router, "/users/sample?"+query)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		assert.Equal(t, cacheNoStore, w.Header().Get("Cache-Control"), query)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// rateWindow is the length of a rate-limit window.
+const rateWindow = time.Minute
+
+// rateLimiter counts requests per key in fixed one-minute windows. While
+// shared returns a client the windows are counted in Redis, so every
+// instance sees the same counts; if Redis fails, the limiter counts in
+// process until it recovers.
+type rateLimiter struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	windows map[string]*rateCount
+
+	shared func() *redis.Client
+	log    logFunc
+	prefix string
+	// bans, if set, lists keys refused whatever their count.
+	bans *banList
+}
+
+type rateCount struct {
+	start time.Time
+	count int
+}
+
+// newRateLimiter returns a limiter counting in process, whose keys are
+// namespaced by scope in Redis once it is given a shared client.
+func newRateLimiter(scope string) *rateLimiter {
+	return &rateLimiter{
+		now:     time.Now,
+		windows: make(map[string]*rateCount),
+		prefix:  redisKeyPrefix + "ratelimit:" + scope + ":",
+	}
+}
+
+// sharedClient returns the Redis client to count or ban in, if any.
+func sharedClient(shared func() *redis.Client) *redis.Client {
+	if shared == nil {
+		return nil
+	}
+	return shared()
+}
+
+// allow records a request by key and reports whether it is within limit
+// requests per window, along with the requests remaining and when the
+// window resets.
+func (l *rateLimiter) allow(key string, limit int) (ok bool, remaining int, reset time.Time) {
+	if client := sharedClient(l.shared); client != nil {
+		ok, remaining, reset, err := l.allowShared(client, key, limit)
+		if err == nil {
+			return ok, remaining, reset
+		}
+		l.log.at("warn", "rate limit: redis: %v; counting in process", err)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	w, found := l.windows[key]
+	if !found || !now.Before(w.start.Add(rateWindow)) {
+		w = &rateCount{start: now}
+		l.windows[key] = w
+	}
+	reset = w.start.Add(rateWindow)
+	if w.count >= limit {
+		return false, 0, reset
+	}
+	w.count++
+	return true, limit - w.count, reset
+}
+
+// rateScript counts a request against the window at KEYS[1], allowing
+// ARGV[1] requests per ARGV[2] milliseconds. It returns whether the request
+// was allowed, the count and the window's remaining time in milliseconds.
+var rateScript = redis.NewScript(`
+local n = tonumber(redis.call('GET', KEYS[1]) or '0')
+if n >= tonumber(ARGV[1]) then
+	return {0, n, redis.call('PTTL', KEYS[1])}
+end
+n = redis.call('INCR', KEYS[1])
+if n == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return {1, n, redis.call('PTTL', KEYS[1])}
+`)
+
+// allowShared is allow, counting in Redis through client.
+func (l *rateLimiter) allowShared(client *redis.Client, key string, limit int) (ok bool, remaining int, reset time.Time, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	res, err := rateScript.Run(ctx, client, []string{l.prefix + key}, limit, rateWindow.Milliseconds()).Int64Slice()
+	if err != nil {
+		return false, 0, time.Time{}, err
+	}
+	ttl := time.Duration(res[2]) * time.Millisecond
+	if ttl < 0 {
+		ttl = rateWindow
+	}
+	return res[0] == 1, max(limit-int(res[1]), 0), l.now().Add(ttl), nil
+}
+
+// admit applies limit to key, setting the X-RateLimit-* headers. Over the
+// limit, or while key is banned, it answers 429 with Retry-After and
+// returns false. A limit of zero admits every key not banned.
+func (l *rateLimiter) admit(w http.ResponseWriter, r *http.Request, key string, limit int) bool {
+	if l.bans != nil {
+		if until, ok := l.bans.banned(key); ok {
+			retry := math.Ceil(until.Sub(l.now()).Seconds())
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Max(retry, 1))))
+			respondError(w, r, http.StatusTooManyRequests, "rate limit exceeded")
+			return false
+		}
+	}
+	if limit <= 0 {
+		return true
+	}
+	ok, remaining, reset := l.allow(key, limit)
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	if !ok {
+		retry := math.Ceil(reset.Sub(l.now()).Seconds())
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Max(retry, 1))))
+		respondError(w, r, http.StatusTooManyRequests, "rate limit exceeded")
+		return false
+	}
+	return true
+}
+
+// banList holds keys refused until a time. While shared returns a client
+// the bans are kept in Redis, so every instance refuses them; if Redis
+// fails, they are kept in process.
+type banList struct {
+	mu     sync.Mutex
+	now    func() time.Time
+	until  map[string]time.Time
+	shared func() *redis.Client
+	log    logFunc
+	prefix string
+}
+
+func newBanList(scope string) *banList {
+	return &banList{now: time.Now, until: make(map[string]time.Time), prefix: redisKeyPrefix + "ban:" + scope + ":"}
+}
+
+// ban refuses key for d.
+func (b *banList) ban(key string, d time.Duration) {
+	if client := sharedClient(b.shared); client != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+		defer cancel()
+		err := client.Set(ctx, b.prefix+key, 1, d).Err()
+		if err == nil {
+			return
+		}
+		b.log.at("warn", "ban: redis: %v; banning in process", err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.until[key] = b.now().Add(d)
+}
+
+// banned reports whether key is banned, and until when.
+func (b *banList) banned(key string) (time.Time, bool) {
+	if client := sharedClient(b.shared); client != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+		defer cancel()
+		ttl, err := client.PTTL(ctx, b.prefix+key).Result()
+		if err == nil && ttl > 0 {
+			return b.now().Add(ttl), true
+		}
+		if err != nil {
+			b.log.at("warn", "ban: redis: %v; checking in process", err)
+		}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	until, ok := b.until[key]
+	if ok && !b.now().Before(until) {
+		delete(b.until, key)
+		return time.Time{}, false
+	}
+	return until, ok
+}
+
+// purge drops the in-process bans lapsed at now, returning how many there
+// were. banned drops a lapsed ban only when its key comes back.
+func (b *banList) purge(now time.Time) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := 0
+	for key, until := range b.until {
+		if !now.Before(until) {
+			delete(b.until, key)
+			n++
+		}
+	}
+	return n
+}
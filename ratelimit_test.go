@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ========== Rate Limiter Tests ==========
+
+func TestRateLimiter_WindowResets(t *testing.T) {
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newRateLimiter("client")
+	l.now = func() time.Time { return clock }
+
+	ok, _, reset := l.allow("1", 1)
+	assert.True(t, ok)
+	assert.Equal(t, clock.Add(time.Minute), reset)
+
+	ok, _, _ = l.allow("1", 1)
+	assert.False(t, ok)
+
+	ok, _, _ = l.allow("2", 1)
+	assert.True(t, ok, "keys are limited independently")
+
+	clock = clock.Add(time.Minute)
+	ok, remaining, _ := l.allow("1", 1)
+	assert.True(t, ok)
+	assert.Zero(t, remaining)
+}
+
+func TestRateLimiter_Admit(t *testing.T) {
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newRateLimiter("client")
+	l.now = func() time.Time { return clock }
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	w := httptest.NewRecorder()
+	assert.True(t, l.admit(w, req, "k", 0), "zero is unlimited")
+	assert.Empty(t, w.Header().Get("X-RateLimit-Limit"))
+
+	w = httptest.NewRecorder()
+	assert.True(t, l.admit(w, req, "k", 1))
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+
+	clock = clock.Add(20 * time.Second)
+	w = httptest.NewRecorder()
+	assert.False(t, l.admit(w, req, "k", 1))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "40", w.Header().Get("Retry-After"))
+}
+
+func TestBanList(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	b := newBanList("client")
+	b.now = clock.Now
+
+	_, ok := b.banned("1")
+	assert.False(t, ok)
+
+	b.ban("1", time.Hour)
+	until, ok := b.banned("1")
+	assert.True(t, ok)
+	assert.Equal(t, clock.t.Add(time.Hour), until)
+	_, ok = b.banned("2")
+	assert.False(t, ok, "keys are banned independently")
+
+	clock.t = clock.t.Add(time.Hour)
+	_, ok = b.banned("1")
+	assert.False(t, ok, "the ban expires")
+}
+
+func TestRateLimiter_AdmitRefusesBanned(t *testing.T) {
+	l := newRateLimiter("client")
+	l.bans = newBanList("client")
+	l.bans.ban("1", time.Minute)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	w := httptest.NewRecorder()
+	assert.False(t, l.admit(w, req, "1", 0), "bans apply without a limit")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	assert.True(t, l.admit(httptest.NewRecorder(), req, "2", 0))
+}
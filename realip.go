@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// trusted reports whether addr is one of the TRUSTED_PROXIES, whose
+// X-Forwarded-For and X-Real-IP headers are believed.
+func (srv *Server) trusted(addr netip.Addr) bool {
+	for _, p := range srv.trustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveClientIP sets r.RemoteAddr to the client's address when the
+// request came through trusted proxies, so that logging, rate limiting and
+// the admin address rules all see the same client. X-Forwarded-For is read
+// from the right, past the trusted proxies that appended to it, to the
+// first address they did not vouch for; without it, X-Real-IP is used.
+// Requests from anywhere else keep their peer address, whatever headers
+// they send.
+func (srv *Server) resolveClientIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip, ok := srv.forwardedClientIP(r); ok {
+			r.RemoteAddr = ip
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// forwardedClientIP returns the client address r's trusted proxies
+// forwarded, if it came through any.
+func (srv *Server) forwardedClientIP(r *http.Request) (string, bool) {
+	if len(srv.trustedProxies) == 0 {
+		return "", false
+	}
+	peer, err := netip.ParseAddr(clientIP(r))
+	if err != nil || !srv.trusted(peer.Unmap()) {
+		return "", false
+	}
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		client := peer
+		for i := len(hops) - 1; i >= 0; i-- {
+			addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				// A proxy we trust would not have written this; the
+				// address before it is as far as we can believe.
+				break
+			}
+			client = addr.Unmap()
+			if !srv.trusted(client) {
+				break
+			}
+		}
+		return client.String(), client != peer
+	}
+	if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return addr.Unmap().String(), true
+	}
+	return "", false
+}
+
+// clientIP is the host part of r.RemoteAddr, which resolveClientIP has set
+// to the client's address.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useTrustedProxies has the test's server trust ranges.
+func useTrustedProxies(t *testing.T, ranges ...string) {
+	t.Helper()
+	server.trustedProxies = nil
+	for _, s := range ranges {
+		p, err := parseIPRange(s)
+		require.NoError(t, err)
+		server.trustedProxies = append(server.trustedProxies, p)
+	}
+}
+
+// ========== Client IP Resolution Tests ==========
+
+func TestForwardedClientIP(t *testing.T) {
+	setupRouter()
+	useTrustedProxies(t, "10.0.0.0/8", "2001:db8::/32")
+	tests := []struct {
+		name   string
+		peer   string
+		xff    []string
+		realIP string
+		want   string
+	}{
+		{"untrusted peer", "203.0.113.9:1234", []string{"198.51.100.1"}, "198.51.100.2", "203.0.113.9"},
+		{"no headers", "10.0.0.1:1234", nil, "", "10.0.0.1"},
+		{"one proxy", "10.0.0.1:1234", []string{"198.51.100.1"}, "", "198.51.100.1"},
+		{"spoofed hops", "10.0.0.1:1234", []string{"1.2.3.4, 198.51.100.1"}, "", "198.51.100.1"},
+		{"chained proxies", "10.0.0.1:1234", []string{"198.51.100.1, 10.0.0.2"}, "", "198.51.100.1"},
+		{"repeated headers", "10.0.0.1:1234", []string{"198.51.100.1", "10.0.0.2"}, "", "198.51.100.1"},
+		{"only proxies", "10.0.0.1:1234", []string{"10.0.0.3, 10.0.0.2"}, "", "10.0.0.3"},
+		{"garbage", "10.0.0.1:1234", []string{"198.51.100.1, nonsense"}, "", "10.0.0.1"},
+		{"forwarded first", "10.0.0.1:1234", []string{"198.51.100.1"}, "198.51.100.2", "198.51.100.1"},
+		{"real ip", "10.0.0.1:1234", nil, "198.51.100.2", "198.51.100.2"},
+		{"bad real ip", "10.0.0.1:1234", nil, "nonsense", "10.0.0.1"},
+		{"v6", "[2001:db8::1]:1234", []string{"2001:db8:ffff::1, 2001:db8::2"}, "", "2001:db8:ffff::1"},
+		{"mapped", "[::ffff:10.0.0.1]:1234", []string{"::ffff:198.51.100.1"}, "", "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.peer
+			for _, v := range tt.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			var got string
+			server.resolveClientIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = clientIP(r)
+			})).ServeHTTP(httptest.NewRecorder(), r)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestForwardedClientIP_NoTrustedProxies(t *testing.T) {
+	setupRouter()
+	useTrustedProxies(t)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+	_, ok := server.forwardedClientIP(r)
+	assert.False(t, ok)
+}
+
+func TestResolveClientIP_Consistent(t *testing.T) {
+	clearRuntimeEnv(t)
+	setupRouter()
+	useLiveConfig(t, writeConfigFile(t, `{"rateLimit":1}`))
+	useTrustedProxies(t, "192.0.2.1")
+	useAdminIPRules(t, IPRules{Deny: []string{"198.51.100.1"}})
+	var buf bytes.Buffer
+	router := server.newRouter(server.newAccessLog(&buf, 0))
+
+	forwarded := func(path, client string) int {
+		req := newAdminRequest(http.MethodGet, path, "")
+		req.Header.Set("X-Forwarded-For", client)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusOK, forwarded("/users", "198.51.100.2"))
+	assert.Equal(t, http.StatusOK, forwarded("/users", "198.51.100.3"), "clients behind the proxy are limited separately")
+	assert.Equal(t, http.StatusTooManyRequests, forwarded("/users", "198.51.100.2"))
+	assert.Equal(t, http.StatusForbidden, forwarded("/admin/flags", "198.51.100.1"))
+
+	entries := accessLogEntries(t, &buf)
+	require.Len(t, entries, 2, "refused requests stop before the access log")
+	assert.Equal(t, "198.51.100.2", entries[0].RemoteAddr)
+	assert.Equal(t, "198.51.100.3", entries[1].RemoteAddr)
+}
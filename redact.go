@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Struct fields holding personal data or credentials carry a redact tag
+// naming how they are masked in logs:
+//
+//	Email string `json:"email" redact:"email"`    // a***@example.com
+//	Token string `json:"token" redact:"secret"`   // ***
+//
+// The access log masks every JSON member, query parameter and path
+// parameter named like a tagged field of any API body type, wherever it
+// appears; see logRedactor.
+const (
+	redactEmail  = "email"
+	redactSecret = "secret"
+)
+
+// masked replaces secrets, and emails that are not emails.
+const masked = "***"
+
+// maskEmail keeps the first letter and the domain of an email:
+// alice@example.com becomes a***@example.com.
+func maskEmail(email string) string {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || local == "" {
+		return masked
+	}
+	return local[:1] + masked + "@" + domain
+}
+
+// maskAs masks value as kind.
+func maskAs(kind, value string) string {
+	if kind == redactEmail {
+		return maskEmail(value)
+	}
+	return masked
+}
+
+// redacted returns a copy of v, a struct, with its tagged string fields
+// masked.
+func redacted[T any](v T) T {
+	rv := reflect.ValueOf(&v).Elem()
+	for i := 0; i < rv.NumField(); i++ {
+		kind := rv.Type().Field(i).Tag.Get("redact")
+		if f := rv.Field(i); kind != "" && f.Kind() == reflect.String && f.CanSet() {
+			f.SetString(maskAs(kind, f.String()))
+		}
+	}
+	return v
+}
+
+// redactor masks the JSON members and URL parameters named like tagged
+// fields.
+type redactor struct {
+	// names maps a member name, as declared and as renamed by either
+	// JSON_NAMING, to how it is masked.
+	names map[string]string
+	// pairs matches "name": "value" for the names.
+	pairs *regexp.Regexp
+}
+
+// newRedactor collects the tagged fields of types, values of the types to
+// walk, and of every type reachable from their fields.
+func newRedactor(types ...interface{}) *redactor {
+	rd := &redactor{names: map[string]string{}}
+	seen := map[reflect.Type]bool{}
+	for _, v := range types {
+		if v != nil {
+			rd.collect(reflect.TypeOf(v), seen)
+		}
+	}
+	var names []string
+	for n := range rd.names {
+		names = append(names, regexp.QuoteMeta(n))
+	}
+	sort.Strings(names)
+	rd.pairs = regexp.MustCompile(`"(` + strings.Join(names, "|") + `)"\s*:\s*"((?:[^"\\]|\\.)*)"`)
+	return rd
+}
+
+func (rd *redactor) collect(t reflect.Type, seen map[reflect.Type]bool) {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return
+	}
+	seen[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if kind := f.Tag.Get("redact"); kind != "" {
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "" {
+				name = f.Name
+			}
+			for _, n := range []string{name, snakeCase(name), camelCase(name)} {
+				rd.names[n] = kind
+			}
+		}
+		rd.collect(f.Type, seen)
+	}
+}
+
+// emailPattern finds emails in text that is not JSON.
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+
+// body masks a captured body. JSON bodies have their tagged members
+// masked at any depth, and any other occurrence of a masked secret, such
+// as a token inside a link, is masked too. Anything else, including JSON
+// cut short by the capture limit, has its emails and its "name": "value"
+// pairs for tagged names masked.
+func (rd *redactor) body(text string) string {
+	var v interface{}
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err == nil && !dec.More() {
+		var secrets []string
+		if rd.walk(v, &secrets) {
+			var buf bytes.Buffer
+			enc := json.NewEncoder(&buf)
+			enc.SetEscapeHTML(false)
+			if enc.Encode(v) == nil {
+				text = strings.TrimSuffix(buf.String(), "\n")
+			}
+		}
+		// Longest first, so that a secret containing another is masked
+		// whole.
+		sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+		for _, s := range secrets {
+			text = strings.ReplaceAll(text, s, masked)
+		}
+		return text
+	}
+	text = rd.pairs.ReplaceAllStringFunc(text, func(pair string) string {
+		m := rd.pairs.FindStringSubmatch(pair)
+		return strings.TrimSuffix(pair, m[2]+`"`) + maskAs(rd.names[m[1]], m[2]) + `"`
+	})
+	return emailPattern.ReplaceAllStringFunc(text, maskEmail)
+}
+
+// walk masks the tagged members of v in place, collecting the secrets it
+// masks, and reports whether it masked any.
+func (rd *redactor) walk(v interface{}, secrets *[]string) bool {
+	changed := false
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, member := range v {
+			kind, tagged := rd.names[k]
+			if s, ok := member.(string); tagged && ok && s != "" {
+				v[k] = maskAs(kind, s)
+				if kind == redactSecret && len(s) >= 8 {
+					*secrets = append(*secrets, s)
+				}
+				changed = true
+				continue
+			}
+			changed = rd.walk(member, secrets) || changed
+		}
+	case []interface{}:
+		for _, item := range v {
+			changed = rd.walk(item, secrets) || changed
+		}
+	}
+	return changed
+}
+
+// requestURI returns r's request URI with tagged query parameters and
+// path parameters masked, or unchanged when it has neither. Path
+// parameters are known only once the router has routed r.
+func (rd *redactor) requestURI(r *http.Request) string {
+	path, changed := r.URL.EscapedPath(), false
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		for i, key := range rctx.URLParams.Keys {
+			if kind, ok := rd.names[key]; ok && i < len(rctx.URLParams.Values) && rctx.URLParams.Values[i] != "" {
+				value := rctx.URLParams.Values[i]
+				path = strings.ReplaceAll(path, url.PathEscape(value), url.PathEscape(maskAs(kind, value)))
+				changed = true
+			}
+		}
+	}
+	query := r.URL.Query()
+	for key, values := range query {
+		if kind, ok := rd.names[key]; ok {
+			for i, v := range values {
+				values[i] = maskAs(kind, v)
+			}
+			changed = true
+		}
+	}
+	switch {
+	case !changed:
+		return r.URL.RequestURI()
+	case len(query) == 0:
+		return path
+	}
+	return path + "?" + query.Encode()
+}
+
+// logRedactor is the redactor of the route table's request and response
+// types.
+var logRedactor = sync.OnceValue(func() *redactor {
+	var srv Server
+	var types []interface{}
+	for _, def := range srv.apiRouteDefs() {
+		types = append(types, def.RequestType)
+		for _, t := range def.ResponseTypes {
+			types = append(types, t)
+		}
+	}
+	return newRedactor(types...)
+})
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// ========== Masking Tests ==========
+
+func TestMaskEmail(t *testing.T) {
+	tests := []struct {
+		email string
+		want  string
+	}{
+		{"alice@example.com", "a***@example.com"},
+		{"a@example.com", "a***@example.com"},
+		{"not-an-email", "***"},
+		{"@example.com", "***"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			assert.Equal(t, tt.want, maskEmail(tt.email))
+		})
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	m := Mail{To: "alice@example.com", Subject: "Hello", Body: "Hi"}
+
+	assert.Equal(t, Mail{To: "a***@example.com", Subject: "Hello", Body: "Hi"}, redacted(m))
+	assert.Equal(t, "alice@example.com", m.To, "the original is untouched")
+	assert.Equal(t, Login{Email: "a***@example.com", Password: "***", Code: "***"},
+		redacted(Login{Email: "alice@example.com", Password: "hunter22", Code: "123456"}))
+}
+
+// ========== Redactor Tests ==========
+
+func TestRedactor_Body(t *testing.T) {
+	rd := logRedactor()
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"untagged", `{"name":"Carol","title":"Hi"}`, `{"name":"Carol","title":"Hi"}`},
+		{"email", `{"email":"carol@example.com","name":"Carol"}`, `{"email":"c***@example.com","name":"Carol"}`},
+		{"nested", `[{"user":{"email":"carol@example.com"}}]`, `[{"user":{"email":"c***@example.com"}}]`},
+		{"secret elsewhere", `{"token":"abcdefghij","url":"http://x/invites/abcdefghij"}`, `{"token":"***","url":"http://x/invites/***"}`},
+		{"renamed", `{"current_password":"hunter22"}`, `{"current_password":"***"}`},
+		{"truncated", `{"email": "carol@example.com", "password": "hun`, `{"email": "c***@example.com", "password": "hun`},
+		{"text", "from carol@example.com", "from c***@example.com"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, rd.body(tt.body))
+		})
+	}
+}
+
+func TestRedactor_RequestURI(t *testing.T) {
+	rd := logRedactor()
+	tests := []struct {
+		name   string
+		target string
+		params map[string]string
+		want   string
+	}{
+		{"untouched", "/users?sort=name&fields=id", nil, "/users?sort=name&fields=id"},
+		{"query", "/verify?token=abc", nil, "/verify?token=%2A%2A%2A"},
+		{"path", "/invites/abc/accept", map[string]string{"token": "abc"}, "/invites/%2A%2A%2A/accept"},
+		{"untagged path", "/users/1", map[string]string{"id": "1"}, "/users/1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.params != nil {
+				rctx := chi.NewRouteContext()
+				for k, v := range tt.params {
+					rctx.URLParams.Add(k, v)
+				}
+				r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+			}
+			assert.Equal(t, tt.want, rd.requestURI(r))
+		})
+	}
+}
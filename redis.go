@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/api2spec/api2spec-fixture-chi/flags"
+)
+
+// redisKeyPrefix namespaces every key the server writes.
+const redisKeyPrefix = "api2spec:"
+
+// redisTimeout bounds each call to Redis. Callers treat a timeout like any
+// other Redis failure and fall back to in-process state.
+const redisTimeout = time.Second
+
+// newRedisClient connects to the Redis server at url, a redis:// or
+// rediss:// URL, and checks that it answers.
+func newRedisClient(ctx context.Context, url string) (*redis.Client, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	client := redis.NewClient(opts)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+func init() {
+	// Every type a breakerStore read can return, so that redisReadCache can
+	// encode it behind an interface.
+	for _, v := range []interface{}{
+		[]User{}, User{}, []UserSuggestion{}, []Post{}, Post{}, []Todo{}, Todo{},
+		[]Album{}, Album{}, []Photo{}, Photo{}, []byte{}, []Place{},
+		[]IngestEvent{}, IngestEvent{}, []Webhook{}, Webhook{},
+		[]Tenant{}, Tenant{}, flags.Set{}, []Delivery{},
+	} {
+		gob.Register(v)
+	}
+}
+
+// redisReadCacheTTL is how long a cached read survives without being
+// refreshed.
+const redisReadCacheTTL = 24 * time.Hour
+
+// redisReadCache is a readCache shared through Redis. A Redis failure is
+// logged and treated as a miss, so the breaker degrades to failing the read
+// rather than the cache failing it.
+type redisReadCache struct {
+	client *redis.Client
+	log    logFunc
+	prefix string
+}
+
+func newRedisReadCache(client *redis.Client, log logFunc) *redisReadCache {
+	return &redisReadCache{client: client, log: log, prefix: redisKeyPrefix + "read:"}
+}
+
+// redisCachedRead is a cachedRead as stored in Redis. Err is the message of
+// one of domainErrors, or empty.
+type redisCachedRead struct {
+	V   interface{}
+	Err string
+}
+
+func (c *redisReadCache) load(key string) (cachedRead, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	data, err := c.client.Get(ctx, c.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return cachedRead{}, false
+	}
+	if err != nil {
+		c.log.at("warn", "read cache: redis: %v", err)
+		return cachedRead{}, false
+	}
+	var stored redisCachedRead
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&stored); err != nil {
+		c.log.at("warn", "read cache: decoding %s: %v", key, err)
+		return cachedRead{}, false
+	}
+	r := cachedRead{v: stored.V}
+	if stored.Err != "" {
+		r.err = errors.New(stored.Err)
+		for _, domain := range domainErrors {
+			if domain.Error() == stored.Err {
+				r.err = domain
+			}
+		}
+	}
+	return r, true
+}
+
+func (c *redisReadCache) save(key string, r cachedRead) {
+	stored := redisCachedRead{V: r.v}
+	if r.err != nil {
+		stored.Err = r.err.Error()
+		for _, domain := range domainErrors {
+			if errors.Is(r.err, domain) {
+				stored.Err = domain.Error()
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(stored); err != nil {
+		c.log.at("warn", "read cache: encoding %s: %v", key, err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := c.client.Set(ctx, c.prefix+key, buf.Bytes(), redisReadCacheTTL).Err(); err != nil {
+		c.log.at("warn", "read cache: redis: %v", err)
+	}
+}
+
+func (c *redisReadCache) clear() {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	iter := c.client.Scan(ctx, 0, c.prefix+"*", 100).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		c.log.at("warn", "read cache: redis: %v", err)
+		return
+	}
+	if len(keys) == 0 {
+		return
+	}
+	if err := c.client.Del(ctx, keys...).Err(); err != nil {
+		c.log.at("warn", "read cache: redis: %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useRedis starts an in-process Redis server and gives the test's server a
+// client for it.
+func useRedis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	server.Redis = client
+	t.Cleanup(func() { client.Close() })
+	return mr
+}
+
+// ========== Shared Rate Limit Tests ==========
+
+func TestRateLimiter_SharedWindows(t *testing.T) {
+	setupRouter()
+	mr := useRedis(t)
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	a, b, tenant := newRateLimiter("client"), newRateLimiter("client"), newRateLimiter("tenant")
+	a.now, b.now = clock.Now, clock.Now
+	a.shared, b.shared, tenant.shared = server.redisClient, server.redisClient, server.redisClient
+
+	ok, remaining, reset := a.allow("k", 2)
+	assert.True(t, ok)
+	assert.Equal(t, 1, remaining)
+	assert.Equal(t, clock.t.Add(rateWindow), reset)
+
+	ok, remaining, _ = b.allow("k", 2)
+	assert.True(t, ok, "instances count against the same window")
+	assert.Equal(t, 0, remaining)
+
+	ok, remaining, reset = a.allow("k", 2)
+	assert.False(t, ok)
+	assert.Equal(t, 0, remaining)
+	assert.Equal(t, clock.t.Add(rateWindow), reset)
+
+	ok, _, _ = tenant.allow("k", 2)
+	assert.True(t, ok, "scopes count separately")
+
+	mr.FastForward(rateWindow)
+	ok, remaining, _ = b.allow("k", 2)
+	assert.True(t, ok, "the window expires")
+	assert.Equal(t, 1, remaining)
+}
+
+func TestBanList_Shared(t *testing.T) {
+	setupRouter()
+	mr := useRedis(t)
+	a, b := newBanList("client"), newBanList("client")
+	a.shared, b.shared = server.redisClient, server.redisClient
+
+	a.ban("1", time.Hour)
+	_, ok := b.banned("1")
+	assert.True(t, ok, "instances share bans")
+
+	mr.FastForward(time.Hour)
+	_, ok = b.banned("1")
+	assert.False(t, ok, "the ban expires")
+}
+
+func TestRateLimiter_FallsBackWhenRedisFails(t *testing.T) {
+	setupRouter()
+	mr := useRedis(t)
+	l := newRateLimiter("client")
+	l.shared = server.redisClient
+	mr.Close()
+
+	ok, remaining, _ := l.allow("k", 1)
+	assert.True(t, ok)
+	assert.Equal(t, 0, remaining)
+	ok, _, _ = l.allow("k", 1)
+	assert.False(t, ok, "the limit is still enforced in process")
+}
+
+func TestClientLimits_SharedAcrossRouters(t *testing.T) {
+	clearRuntimeEnv(t)
+	config := writeConfigFile(t, `{"rateLimit":1}`)
+	first := setupRouter()
+	useRedis(t)
+	useLiveConfig(t, config)
+	shared := server.Redis
+	second := setupRouter()
+	server.Redis = shared
+	useLiveConfig(t, config)
+
+	w := httptest.NewRecorder()
+	first.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	second.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+}
+
+// ========== Shared Read Cache Tests ==========
+
+// newTestBreakerStore returns a breaker-guarded flakyStore caching reads
+// in client, as the store subsystem wires it.
+func newTestBreakerStore(client *redis.Client) (*breakerStore, *flakyStore) {
+	backend := &flakyStore{memoryStore: newMemoryStore(server.events)}
+	bs := newBreakerStore(backend, newBreaker(1, 30*time.Second))
+	bs.cache = newRedisReadCache(client, nil)
+	return bs, backend
+}
+
+func TestBreakerStore_SharedReadCache(t *testing.T) {
+	setupRouter()
+	useRedis(t)
+	first, _ := newTestBreakerStore(server.Redis)
+	second, backend := newTestBreakerStore(server.Redis)
+	require.IsType(t, &redisReadCache{}, second.cache)
+
+	fresh := first.ListUsers()
+	require.Len(t, fresh, 2)
+	_, err := first.GetUser(999)
+	require.ErrorIs(t, err, errNotFound)
+
+	backend.down = true
+	assert.Equal(t, fresh, second.ListUsers(), "a read cached by one instance is served by another")
+	assert.Equal(t, breakerOpen, second.b.State())
+	_, err = second.GetUser(999)
+	assert.ErrorIs(t, err, errNotFound, "domain errors are cached too")
+	_, err = second.GetUser(1)
+	assert.ErrorIs(t, err, errUnavailable)
+}
+
+func TestBreakerStore_RestoreClearsSharedCache(t *testing.T) {
+	setupRouter()
+	mr := useRedis(t)
+	s, backend := newTestBreakerStore(server.Redis)
+	s.ListUsers()
+	s.GetFlags()
+	require.Len(t, mr.Keys(), 2)
+
+	s.Restore(s.Snapshot())
+	assert.Empty(t, mr.Keys())
+	backend.down = true
+	assert.Empty(t, s.ListUsers())
+}
+
+func TestRedisReadCache_FailsAsMiss(t *testing.T) {
+	setupRouter()
+	mr := useRedis(t)
+	cache := newRedisReadCache(server.Redis, nil)
+	cache.save("k", cachedRead{v: User{ID: 1}})
+	r, ok := cache.load("k")
+	require.True(t, ok)
+	assert.Equal(t, User{ID: 1}, r.v)
+
+	mr.Close()
+	cache.save("k", cachedRead{v: User{ID: 2}})
+	_, ok = cache.load("k")
+	assert.False(t, ok)
+	cache.clear()
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// referenceRequests is the suite run against both the fixture and the
+// reference API. It sticks to the users and posts the two have in common.
+var referenceRequests = []struct {
+	method string
+	path   string
+	body   string
+}{
+	{http.MethodGet, "/users", ""},
+	{http.MethodGet, "/users/1", ""},
+	{http.MethodGet, "/users/999", ""},
+	{http.MethodGet, "/users/1/posts", ""},
+	{http.MethodGet, "/posts", ""},
+	{http.MethodGet, "/posts/1", ""},
+	{http.MethodGet, "/posts/999", ""},
+	{http.MethodPost, "/posts", `{"userId":1,"title":"Reference","body":"Compared"}`},
+}
+
+// jsonShape records the JSON type of every member of v under path, with
+// "[]" standing for any array element: {"a":[{"b":1}]} has the shape
+// $: object, $.a: array, $.a[]: object, $.a[].b: number.
+func jsonShape(path string, v interface{}, shape map[string]string) {
+	kind := "null"
+	switch v := v.(type) {
+	case map[string]interface{}:
+		kind = "object"
+		for k, member := range v {
+			jsonShape(path+"."+k, member, shape)
+		}
+	case []interface{}:
+		kind = "array"
+		for _, elem := range v {
+			jsonShape(path+"[]", elem, shape)
+		}
+	case string:
+		kind = "string"
+	case float64:
+		kind = "number"
+	case bool:
+		kind = "boolean"
+	}
+	// A null says nothing about the member's type; let a value win.
+	if shape[path] == "" || shape[path] == "null" {
+		shape[path] = kind
+	}
+}
+
+// shapeDiff compares two shapes. mismatched lists the paths both have with
+// different types; only lists the paths just one of them has.
+func shapeDiff(fixture, reference map[string]string) (mismatched, onlyFixture, onlyReference []string) {
+	for path, kind := range fixture {
+		ref, ok := reference[path]
+		switch {
+		case !ok:
+			onlyFixture = append(onlyFixture, path)
+		case kind != ref && kind != "null" && ref != "null":
+			mismatched = append(mismatched, path+": "+kind+" here, "+ref+" in the reference")
+		}
+	}
+	for path := range reference {
+		if _, ok := fixture[path]; !ok {
+			onlyReference = append(onlyReference, path)
+		}
+	}
+	sort.Strings(mismatched)
+	sort.Strings(onlyFixture)
+	sort.Strings(onlyReference)
+	return mismatched, onlyFixture, onlyReference
+}
+
+// responseShape returns the shape of a JSON response body. Bodies that are
+// empty or not JSON have no shape.
+func responseShape(t *testing.T, body []byte) map[string]string {
+	t.Helper()
+	shape := map[string]string{}
+	var v interface{}
+	if json.Unmarshal(body, &v) == nil {
+		jsonShape("$", v, shape)
+	}
+	return shape
+}
+
+// compareWithReference runs referenceRequests against the fixture and the
+// API at baseURL. Status codes must match, as must the types of members
+// both sides return. Members only one side has are logged: the fixture
+// adds versions and the reference richer users, and neither is a bug.
+func compareWithReference(t *testing.T, baseURL string) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	for _, rr := range referenceRequests {
+		rr := rr
+		t.Run(rr.method+" "+rr.path, func(t *testing.T) {
+			router := setupRouter()
+			req := httptest.NewRequest(rr.method, rr.path, strings.NewReader(rr.body))
+			if rr.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			refReq, err := http.NewRequest(rr.method, strings.TrimSuffix(baseURL, "/")+rr.path, strings.NewReader(rr.body))
+			require.NoError(t, err)
+			if rr.body != "" {
+				refReq.Header.Set("Content-Type", "application/json")
+			}
+			resp, err := client.Do(refReq)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			refBody, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			assert.Equal(t, resp.StatusCode, w.Code, "status code")
+			mismatched, onlyFixture, onlyReference := shapeDiff(responseShape(t, w.Body.Bytes()), responseShape(t, refBody))
+			assert.Empty(t, mismatched, "members typed differently")
+			if len(onlyFixture) > 0 {
+				t.Logf("only in the fixture: %s", strings.Join(onlyFixture, ", "))
+			}
+			if len(onlyReference) > 0 {
+				t.Logf("only in the reference: %s", strings.Join(onlyReference, ", "))
+			}
+		})
+	}
+}
+
+// ========== Reference API Tests ==========
+
+func TestJSONShape(t *testing.T) {
+	shape := map[string]string{}
+	jsonShape("$", map[string]interface{}{
+		"id":   float64(1),
+		"tags": []interface{}{"a"},
+		"due":  nil,
+		"items": []interface{}{
+			map[string]interface{}{"done": nil},
+			map[string]interface{}{"done": true},
+		},
+	}, shape)
+
+	assert.Equal(t, map[string]string{
+		"$":              "object",
+		"$.id":           "number",
+		"$.tags":         "array",
+		"$.tags[]":       "string",
+		"$.due":          "null",
+		"$.items":        "array",
+		"$.items[]":      "object",
+		"$.items[].done": "boolean",
+	}, shape)
+}
+
+func TestShapeDiff(t *testing.T) {
+	fixture := map[string]string{"$": "object", "$.id": "number", "$.version": "number", "$.due": "null"}
+	reference := map[string]string{"$": "object", "$.id": "string", "$.phone": "string", "$.due": "string"}
+
+	mismatched, onlyFixture, onlyReference := shapeDiff(fixture, reference)
+	assert.Equal(t, []string{"$.id: number here, string in the reference"}, mismatched)
+	assert.Equal(t, []string{"$.version"}, onlyFixture)
+	assert.Equal(t, []string{"$.phone"}, onlyReference)
+}
+
+// fakeReference answers referenceRequests the way jsonplaceholder does.
+func fakeReference(w http.ResponseWriter, r *http.Request) {
+	const user = `{"id":1,"name":"Leanne Graham","username":"Bret","email":"Sincere@april.biz","address":{"street":"Kulas Light","city":"Gwenborough"},"phone":"1-770-736-8031","website":"hildegard.org","company":{"name":"Romaguera-Crona"}}`
+	const post = `{"userId":1,"id":1,"title":"sunt aut facere","body":"quia et suscipit"}`
+	responses := map[string]struct {
+		status int
+		body   string
+	}{
+		"GET /users":         {http.StatusOK, "[" + user + "]"},
+		"GET /users/1":       {http.StatusOK, user},
+		"GET /users/1/posts": {http.StatusOK, "[" + post + "]"},
+		"GET /posts":         {http.StatusOK, "[" + post + "]"},
+		"GET /posts/1":       {http.StatusOK, post},
+		"POST /posts":        {http.StatusCreated, `{"userId":1,"title":"Reference","body":"Compared","id":101}`},
+	}
+	resp, ok := responses[r.Method+" "+r.URL.Path]
+	if !ok {
+		resp.status, resp.body = http.StatusNotFound, "{}"
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(resp.status)
+	io.WriteString(w, resp.body)
+}
+
+func TestReference_AgainstFake(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(fakeReference))
+	t.Cleanup(server.Close)
+	compareWithReference(t, server.URL)
+}
+
+// TestReference_RemoteAPI compares the fixture with the API at
+// REFERENCE_API_URL, such as https://jsonplaceholder.typicode.com. It is
+// skipped when the variable is unset.
+func TestReference_RemoteAPI(t *testing.T) {
+	baseURL := os.Getenv("REFERENCE_API_URL")
+	if baseURL == "" {
+		t.Skip("REFERENCE_API_URL is not set")
+	}
+	compareWithReference(t, baseURL)
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// RuntimeConfig holds the settings that can change without a restart. They
+// are read from CONFIG_FILE, overridden by the environment, and reloaded on
+// SIGHUP.
+type RuntimeConfig struct {
+	// LogLevel is debug, info, warn or error. Requests are logged at info.
+	LogLevel string `json:"logLevel"`
+	// RateLimit is the number of requests each client IP may make per
+	// minute. Zero means unlimited.
+	RateLimit int `json:"rateLimit"`
+	// CORSOrigins lists the origins, or "*", that may read responses to
+	// requests made without a tenant.
+	CORSOrigins []string `json:"corsOrigins"`
+}
+
+// Where a runtime setting's value came from.
+const (
+	sourceDefault = "default"
+	sourceFile    = "file"
+	sourceEnv     = "env"
+)
+
+// logLevels orders the accepted log levels from most to least verbose.
+var logLevels = map[string]int{"debug": 0, "info": 1, "warn": 2, "error": 3}
+
+// Setting is a runtime setting's effective value and its source.
+type Setting struct {
+	Value  interface{} `json:"value"`
+	Source string      `json:"source"`
+}
+
+// ConfigReport describes the effective runtime configuration.
+type ConfigReport struct {
+	// File is CONFIG_FILE, if set.
+	File     string             `json:"file,omitempty"`
+	LoadedAt time.Time          `json:"loadedAt"`
+	Settings map[string]Setting `json:"settings"`
+}
+
+// runtimeFile is the CONFIG_FILE document. Omitted settings keep their
+// defaults.
+type runtimeFile struct {
+	LogLevel    *string  `json:"logLevel"`
+	RateLimit   *int     `json:"rateLimit"`
+	CORSOrigins []string `json:"corsOrigins"`
+}
+
+// loadRuntimeConfig reads the runtime settings from the file at path (if
+// any) and then the LOG_LEVEL, RATE_LIMIT and CORS_ORIGINS environment
+// variables, returning each setting's source keyed by its JSON name.
+func loadRuntimeConfig(path string) (RuntimeConfig, map[string]string, error) {
+	rc, sources := defaultRuntimeConfig()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return RuntimeConfig{}, nil, err
+		}
+		var f runtimeFile
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&f); err != nil {
+			return RuntimeConfig{}, nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if f.LogLevel != nil {
+			rc.LogLevel, sources["logLevel"] = *f.LogLevel, sourceFile
+		}
+		if f.RateLimit != nil {
+			rc.RateLimit, sources["rateLimit"] = *f.RateLimit, sourceFile
+		}
+		if f.CORSOrigins != nil {
+			rc.CORSOrigins, sources["corsOrigins"] = f.CORSOrigins, sourceFile
+		}
+		if err := rc.validate(); err != nil {
+			return RuntimeConfig{}, nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		rc.LogLevel, sources["logLevel"] = v, sourceEnv
+	}
+	if v := os.Getenv("RATE_LIMIT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return RuntimeConfig{}, nil, fmt.Errorf("RATE_LIMIT: %w", err)
+		}
+		rc.RateLimit, sources["rateLimit"] = n, sourceEnv
+	}
+	if v := os.Getenv("CORS_ORIGINS"); v != "" {
+		rc.CORSOrigins = nil
+		for _, o := range strings.Split(v, ",") {
+			rc.CORSOrigins = append(rc.CORSOrigins, strings.TrimSpace(o))
+		}
+		sources["corsOrigins"] = sourceEnv
+	}
+	if err := rc.validate(); err != nil {
+		return RuntimeConfig{}, nil, err
+	}
+	return rc, sources, nil
+}
+
+// defaultRuntimeConfig returns the default settings, all sourced from
+// sourceDefault.
+func defaultRuntimeConfig() (RuntimeConfig, map[string]string) {
+	return RuntimeConfig{LogLevel: "info"}, map[string]string{
+		"logLevel":    sourceDefault,
+		"rateLimit":   sourceDefault,
+		"corsOrigins": sourceDefault,
+	}
+}
+
+func (rc RuntimeConfig) validate() error {
+	if _, ok := logLevels[rc.LogLevel]; !ok {
+		return fmt.Errorf("logLevel: unknown level %q (want debug, info, warn or error)", rc.LogLevel)
+	}
+	if rc.RateLimit < 0 {
+		return fmt.Errorf("rateLimit: %d is negative", rc.RateLimit)
+	}
+	for _, o := range rc.CORSOrigins {
+		if !validOrigin(o) {
+			return fmt.Errorf("corsOrigins: %q is not \"*\" or a scheme and host", o)
+		}
+	}
+	return nil
+}
+
+// liveConfig is the runtime configuration currently in effect.
+type liveConfig struct {
+	mu       sync.RWMutex
+	file     string
+	cfg      RuntimeConfig
+	sources  map[string]string
+	loadedAt time.Time
+}
+
+// newLiveConfig returns the defaults, to be replaced by reloading file.
+func newLiveConfig(file string) *liveConfig {
+	cfg, sources := defaultRuntimeConfig()
+	return &liveConfig{file: file, cfg: cfg, sources: sources, loadedAt: time.Now().UTC()}
+}
+
+// current returns the settings in effect.
+func (l *liveConfig) current() RuntimeConfig {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.cfg
+}
+
+// reload rereads the configuration. On error the current settings stay in
+// effect.
+func (l *liveConfig) reload() error {
+	l.mu.RLock()
+	file := l.file
+	l.mu.RUnlock()
+
+	cfg, sources, err := loadRuntimeConfig(file)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	l.cfg, l.sources, l.loadedAt = cfg, sources, time.Now().UTC()
+	l.mu.Unlock()
+	return nil
+}
+
+// report describes the settings in effect and their sources.
+func (l *liveConfig) report() ConfigReport {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	origins := l.cfg.CORSOrigins
+	if origins == nil {
+		origins = []string{}
+	}
+	return ConfigReport{
+		File:     l.file,
+		LoadedAt: l.loadedAt,
+		Settings: map[string]Setting{
+			"logLevel":    {Value: l.cfg.LogLevel, Source: l.sources["logLevel"]},
+			"rateLimit":   {Value: l.cfg.RateLimit, Source: l.sources["rateLimit"]},
+			"corsOrigins": {Value: origins, Source: l.sources["corsOrigins"]},
+		},
+	}
+}
+
+// logRequests logs each request, as middleware.Logger does, while the log
+// level is info or more verbose.
+func (srv *Server) logRequests(next http.Handler) http.Handler {
+	logged := middleware.Logger(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if srv.logEnabled("info") {
+			// The line is begun before routing, so only query parameters
+			// can be masked.
+			lr := *r
+			lr.RequestURI = logRedactor().requestURI(r)
+			logged.ServeHTTP(w, &lr)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// newClientLimits returns middleware refusing srv's banned clients and
+// enforcing the live per-client rate limit and CORS origins. Requests made
+// for a tenant are left to the tenant's own configuration.
+func (srv *Server) newClientLimits() func(http.Handler) http.Handler {
+	limiter := newRateLimiter("client")
+	limiter.shared, limiter.log, limiter.bans = srv.redisClient, srv.logAt, srv.bans
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := tenantFromContext(r.Context()); ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cfg := srv.live.current()
+			if !limiter.admit(w, r, clientIP(r), cfg.RateLimit) {
+				return
+			}
+			if len(cfg.CORSOrigins) > 0 {
+				// Responses to requests without an Origin lack the CORS
+				// headers, so they vary by it too.
+				w.Header().Add("Vary", "Origin")
+			}
+			if origin := r.Header.Get("Origin"); origin != "" && len(cfg.CORSOrigins) > 0 {
+				if originAllowed(cfg.CORSOrigins, origin) && allowCORS(w, r, origin) {
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (srv *Server) getConfig(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, srv.live.report())
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeConfigFile writes content to a CONFIG_FILE in a temporary directory.
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+// useLiveConfig gives the test's server a live config reading file.
+func useLiveConfig(t *testing.T, file string) *liveConfig {
+	t.Helper()
+	server.live = newLiveConfig(file)
+	require.NoError(t, server.live.reload())
+	return server.live
+}
+
+// clearRuntimeEnv unsets the runtime settings' environment variables.
+func clearRuntimeEnv(t *testing.T) {
+	for _, key := range []string{"LOG_LEVEL", "RATE_LIMIT", "CORS_ORIGINS"} {
+		t.Setenv(key, "")
+	}
+}
+
+// ========== Runtime Config Tests ==========
+
+func TestLoadRuntimeConfig_Defaults(t *testing.T) {
+	clearRuntimeEnv(t)
+
+	rc, sources, err := loadRuntimeConfig("")
+	require.NoError(t, err)
+	assert.Equal(t, RuntimeConfig{LogLevel: "info"}, rc)
+	assert.Equal(t, map[string]string{"logLevel": "default", "rateLimit": "default", "corsOrigins": "default"}, sources)
+}
+
+func TestLoadRuntimeConfig_FileAndEnv(t *testing.T) {
+	clearRuntimeEnv(t)
+	t.Setenv("RATE_LIMIT", "100")
+	path := writeConfigFile(t, `{"logLevel":"debug","rateLimit":5,"corsOrigins":["https://a.example"]}`)
+
+	rc, sources, err := loadRuntimeConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, RuntimeConfig{LogLevel: "debug", RateLimit: 100, CORSOrigins: []string{"https://a.example"}}, rc)
+	assert.Equal(t, map[string]string{"logLevel": "file", "rateLimit": "env", "corsOrigins": "file"}, sources)
+}
+
+func TestLoadRuntimeConfig_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		env      map[string]string
+		contains string
+	}{
+		{"unknown field", `{"logLevel":"info","port":80}`, nil, "port"},
+		{"bad level", `{"logLevel":"loud"}`, nil, "logLevel"},
+		{"negative rate limit", `{"rateLimit":-1}`, nil, "rateLimit"},
+		{"bad origin", `{"corsOrigins":["a.example"]}`, nil, "corsOrigins"},
+		{"bad env rate limit", `{}`, map[string]string{"RATE_LIMIT": "lots"}, "RATE_LIMIT"},
+		{"bad env level", `{}`, map[string]string{"LOG_LEVEL": "loud"}, "logLevel"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearRuntimeEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			_, _, err := loadRuntimeConfig(writeConfigFile(t, tt.file))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.contains)
+		})
+	}
+}
+
+func TestLoadRuntimeConfig_MissingFile(t *testing.T) {
+	clearRuntimeEnv(t)
+	_, _, err := loadRuntimeConfig(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
+
+// ========== Reload Tests ==========
+
+func TestLiveConfig_Reload(t *testing.T) {
+	clearRuntimeEnv(t)
+	setupRouter()
+	path := writeConfigFile(t, `{"rateLimit":5}`)
+	lc := useLiveConfig(t, path)
+	assert.Equal(t, 5, lc.current().RateLimit)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"rateLimit":7}`), 0o644))
+	require.NoError(t, lc.reload())
+	assert.Equal(t, 7, lc.current().RateLimit)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"rateLimit":"many"}`), 0o644))
+	assert.Error(t, lc.reload())
+	assert.Equal(t, 7, lc.current().RateLimit, "a bad file keeps the previous config")
+}
+
+func TestReloadOnHangup(t *testing.T) {
+	clearRuntimeEnv(t)
+	setupRouter()
+	path := writeConfigFile(t, `{"logLevel":"warn"}`)
+	lc := useLiveConfig(t, path)
+	hangup := background("config-reload", server.reloadOnHangup)
+	require.NoError(t, hangup.Start(context.Background()))
+	t.Cleanup(func() { hangup.Stop(context.Background()) })
+	// Give signal.Notify a moment to register before signalling.
+	time.Sleep(50 * time.Millisecond)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"logLevel":"error"}`), 0o644))
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+
+	assert.Eventually(t, func() bool { return lc.current().LogLevel == "error" }, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestLogEnabled(t *testing.T) {
+	clearRuntimeEnv(t)
+	setupRouter()
+	useLiveConfig(t, writeConfigFile(t, `{"logLevel":"warn"}`))
+
+	assert.False(t, server.logEnabled("debug"))
+	assert.False(t, server.logEnabled("info"))
+	assert.True(t, server.logEnabled("warn"))
+	assert.True(t, server.logEnabled("error"))
+}
+
+// ========== Admin Config Tests ==========
+
+func TestGetConfig(t *testing.T) {
+	clearRuntimeEnv(t)
+	t.Setenv("LOG_LEVEL", "debug")
+	path := writeConfigFile(t, `{"corsOrigins":["*"]}`)
+	router := setupAdminRouter(t)
+	useLiveConfig(t, path)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodGet, "/admin/config", ""))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var report ConfigReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, path, report.File)
+	assert.False(t, report.LoadedAt.IsZero())
+	assert.Equal(t, map[string]Setting{
+		"logLevel":    {Value: "debug", Source: "env"},
+		"rateLimit":   {Value: float64(0), Source: "default"},
+		"corsOrigins": {Value: []interface{}{"*"}, Source: "file"},
+	}, report.Settings)
+}
+
+// ========== Client Limit Tests ==========
+
+func TestClientLimits_RateLimit(t *testing.T) {
+	clearRuntimeEnv(t)
+	router := setupRouter()
+	useLiveConfig(t, writeConfigFile(t, `{"rateLimit":1}`))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.RemoteAddr = "203.0.113.9:4000"
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "limits are per client IP")
+}
+
+func TestClientLimits_TenantExempt(t *testing.T) {
+	clearRuntimeEnv(t)
+	router := setupRouter()
+	useLiveConfig(t, writeConfigFile(t, `{"rateLimit":1}`))
+	tenant := createTestTenant(t, Tenant{Name: "Acme"})
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, tenantRequest(http.MethodGet, "/users", tenant.ID))
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+}
+
+func TestClientLimits_CORS(t *testing.T) {
+	clearRuntimeEnv(t)
+	router := setupRouter()
+	useLiveConfig(t, writeConfigFile(t, `{"corsOrigins":["https://a.example"]}`))
+
+	tests := []struct {
+		origin        string
+		expectedAllow string
+	}{
+		{"https://a.example", "https://a.example"},
+		{"https://b.example", ""},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.Header.Set("Origin", tt.origin)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code, tt.origin)
+		assert.Equal(t, tt.expectedAllow, w.Header().Get("Access-Control-Allow-Origin"), tt.origin)
+	}
+}
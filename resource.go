@@ -0,0 +1,238 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// entity is what a Resource needs of the values it serves.
+type entity[T any] interface {
+	entityID() int
+	entityVersion() int
+	lastModified() time.Time
+	// withID returns a copy with the given ID, for replacing the entity
+	// at a path.
+	withID(id int) T
+}
+
+// Resource serves the list, get, create, update and delete routes of one
+// kind of entity from the request's store. The store funcs are usually
+// method expressions such as Store.ListUsers; leave one nil when the
+// resource has no such route. Its handlers are methods, mounted from
+// apiRouteDefs.
+type Resource[T entity[T]] struct {
+	srv *Server
+	// Name names the entity in error details, such as "user".
+	Name string
+	// Path is the collection's path, such as "/users". An entity's
+	// Location is Path/{id}.
+	Path string
+
+	List   func(s Store) []T
+	Get    func(s Store, id int) (T, error)
+	Create func(s Store, v T) (T, error)
+	// Update replaces the entity with v's ID. It returns errVersionConflict
+	// with the current entity when v's version is stale.
+	Update func(s Store, v T) (T, error)
+	Delete func(s Store, id int, unmodifiedSince time.Time) error
+
+	// Check returns the violations that make a decoded create or update
+	// body a 400, after filling in any defaults. Nil accepts every body.
+	Check func(v *T) []string
+	// Duplicate is the detail of the 409 when v would duplicate existing.
+	// Nil says this Name already exists.
+	Duplicate func(v, existing T) string
+}
+
+func (res *Resource[T]) list(w http.ResponseWriter, r *http.Request) {
+	respondList(w, r, res.List(res.srv.requestStore(r)), res.srv.Config.PageEnvelope)
+}
+
+func (res *Resource[T]) get(w http.ResponseWriter, r *http.Request) {
+	if id, ok := pathID(w, r); ok {
+		res.respond(w, r, id)
+	}
+}
+
+// respond serves entity id, with its Last-Modified time.
+func (res *Resource[T]) respond(w http.ResponseWriter, r *http.Request, id int) {
+	v, err := res.Get(res.srv.requestStore(r), id)
+	if err != nil {
+		respondNotFound(w, r, res.Name, id)
+		return
+	}
+	setLastModified(w, v.lastModified())
+	respondJSON(w, http.StatusOK, v)
+}
+
+func (res *Resource[T]) create(w http.ResponseWriter, r *http.Request) {
+	v, ok := res.decode(w, r)
+	if !ok {
+		return
+	}
+	created, err := res.Create(res.srv.requestStore(r), v)
+	switch {
+	case errors.Is(err, errDuplicate):
+		res.respondDuplicate(w, r, v, created)
+		return
+	case err != nil:
+		res.respondStoreError(w, r, err)
+		return
+	}
+	w.Header().Set("Location", res.location(created))
+	respondJSON(w, http.StatusCreated, created)
+}
+
+func (res *Resource[T]) update(w http.ResponseWriter, r *http.Request) {
+	if id, ok := pathID(w, r); ok {
+		res.replace(w, r, id)
+	}
+}
+
+// replace replaces entity id with the request body, which must carry the
+// version it replaces.
+func (res *Resource[T]) replace(w http.ResponseWriter, r *http.Request, id int) {
+	v, ok := res.decode(w, r)
+	if !ok {
+		return
+	}
+	if v.entityVersion() == 0 {
+		respondError(w, r, http.StatusPreconditionRequired, "version required")
+		return
+	}
+	updated, err := res.Update(res.srv.requestStore(r), v.withID(id))
+	switch {
+	case errors.Is(err, errVersionConflict):
+		respondJSON(w, http.StatusConflict, updated)
+		return
+	case errors.Is(err, errDuplicate):
+		res.respondDuplicate(w, r, v, updated)
+		return
+	case errors.Is(err, errNotFound):
+		respondNotFound(w, r, res.Name, id)
+		return
+	case err != nil:
+		res.respondStoreError(w, r, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, updated)
+}
+
+func (res *Resource[T]) delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	switch err := res.Delete(res.srv.requestStore(r), id, ifUnmodifiedSince(r)); {
+	case errors.Is(err, errModified):
+		respondError(w, r, http.StatusPreconditionFailed, "precondition failed")
+		return
+	case errors.Is(err, errNotFound):
+		respondNotFound(w, r, res.Name, id)
+		return
+	case err != nil:
+		res.respondStoreError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// respondStoreError reports a write the store failed: 503 while it is
+// unavailable, as degradedMode does, and 500 otherwise.
+func (res *Resource[T]) respondStoreError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, errUnavailable) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(res.srv.retryAfter().Seconds())))
+		respondError(w, r, http.StatusServiceUnavailable, "store unavailable")
+		return
+	}
+	respondError(w, r, http.StatusInternalServerError, "internal error")
+}
+
+// decode reads and checks a create or update body. It has responded when
+// ok is false.
+func (res *Resource[T]) decode(w http.ResponseWriter, r *http.Request) (v T, ok bool) {
+	if err := decodeJSON(r, &v); err != nil {
+		respondDecodeError(w, r, err)
+		return v, false
+	}
+	if res.Check != nil {
+		if violations := res.Check(&v); len(violations) > 0 {
+			respondProblem(w, r, http.StatusBadRequest, "invalid request", Problem{Violations: violations})
+			return v, false
+		}
+	}
+	return v, true
+}
+
+func (res *Resource[T]) respondDuplicate(w http.ResponseWriter, r *http.Request, v, existing T) {
+	detail := "this " + res.Name + " already exists"
+	if res.Duplicate != nil {
+		detail = res.Duplicate(v, existing)
+	}
+	respondDuplicate(w, r, detail, res.location(existing))
+}
+
+func (res *Resource[T]) location(v T) string {
+	return res.Path + "/" + strconv.Itoa(v.entityID())
+}
+
+// users is the /users resource.
+func (srv *Server) users() *Resource[User] {
+	return &Resource[User]{
+		srv: srv, Name: "user", Path: "/users",
+		List: Store.ListUsers, Get: Store.GetUser, Create: Store.CreateUser,
+		Update: Store.UpdateUser, Delete: Store.DeleteUser,
+		Duplicate: func(User, User) string { return "a user with this email already exists" },
+	}
+}
+
+// posts is the /posts resource. Posts are also created under their
+// author, by createUserPost.
+func (srv *Server) posts() *Resource[Post] {
+	return &Resource[Post]{
+		srv: srv, Name: "post", Path: "/posts",
+		List: Store.ListPosts, Get: Store.GetPost, Create: Store.CreatePost,
+		Update: Store.UpdatePost, Delete: Store.DeletePost,
+		Check:     func(p *Post) []string { return validatePostSlug(*p) },
+		Duplicate: duplicatePostDetail,
+	}
+}
+
+// albums is the /albums resource.
+func (srv *Server) albums() *Resource[Album] {
+	return &Resource[Album]{
+		srv: srv, Name: "album", Path: "/albums",
+		List: Store.ListAlbums, Get: Store.GetAlbum, Create: Store.CreateAlbum,
+	}
+}
+
+// todos is the /todos resource. Its list is listTodos, which filters.
+func (srv *Server) todos() *Resource[Todo] {
+	return &Resource[Todo]{
+		srv: srv, Name: "todo", Path: "/todos",
+		Get: Store.GetTodo, Create: Store.CreateTodo,
+		Check: checkTodo,
+	}
+}
+
+func (u User) entityID() int           { return u.ID }
+func (u User) entityVersion() int      { return u.Version }
+func (u User) lastModified() time.Time { return u.UpdatedAt }
+func (u User) withID(id int) User      { u.ID = id; return u }
+
+func (p Post) entityID() int           { return p.ID }
+func (p Post) entityVersion() int      { return p.Version }
+func (p Post) lastModified() time.Time { return p.UpdatedAt }
+func (p Post) withID(id int) Post      { p.ID = id; return p }
+
+func (a Album) entityID() int           { return a.ID }
+func (a Album) entityVersion() int      { return a.Version }
+func (a Album) lastModified() time.Time { return a.UpdatedAt }
+func (a Album) withID(id int) Album     { a.ID = id; return a }
+
+func (t Todo) entityID() int           { return t.ID }
+func (t Todo) entityVersion() int      { return t.Version }
+func (t Todo) lastModified() time.Time { return t.UpdatedAt }
+func (t Todo) withID(id int) Todo      { t.ID = id; return t }
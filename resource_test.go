@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deleteFailStore fails every DeleteUser with err.
+type deleteFailStore struct {
+	*memoryStore
+	err error
+}
+
+func (s deleteFailStore) DeleteUser(int, time.Time) error { return s.err }
+
+// ========== Resource Tests ==========
+
+func TestResource_GetSetsLastModified(t *testing.T) {
+	for _, path := range []string{"/users/1", "/posts/1", "/albums/1", "/todos/1"} {
+		t.Run(path, func(t *testing.T) {
+			router := setupRouter()
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.NotEmpty(t, w.Header().Get("Last-Modified"))
+		})
+	}
+}
+
+func TestResource_CreateStoreError(t *testing.T) {
+	router := setupRouter()
+	server.Store = &flakyStore{memoryStore: newMemoryStore(server.events), down: true}
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":"Carol","email":"carol@example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+}
+
+func TestResource_DeleteStoreError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"not found", errNotFound, http.StatusNotFound},
+		{"unavailable", errUnavailable, http.StatusServiceUnavailable},
+		{"backend down", errBackendDown, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+			server.Store = deleteFailStore{memoryStore: newMemoryStore(server.events), err: tt.err}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/users/1", nil))
+
+			assert.Equal(t, tt.status, w.Code)
+			if tt.status == http.StatusServiceUnavailable {
+				assert.NotEmpty(t, w.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
+
+func TestResource_DuplicateDetail(t *testing.T) {
+	res := &Resource[Album]{Name: "album", Path: "/albums"}
+	req := httptest.NewRequest(http.MethodPost, "/albums", nil)
+	w := httptest.NewRecorder()
+	res.respondDuplicate(w, req, Album{}, Album{ID: 3})
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), `"detail":"this album already exists"`)
+	assert.Contains(t, w.Body.String(), `"resource":"/albums/3"`)
+}
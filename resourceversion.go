@@ -0,0 +1,24 @@
+package main
+
+import "net/http"
+
+// headerResourceVersion names the version of the representation a
+// subtree's responses use.
+const headerResourceVersion = "X-Resource-Version"
+
+// postsResourceVersion is the post representation's version, sent on every
+// response under /posts. It goes up when Post's members change; 2 added
+// slug.
+const postsResourceVersion = "2"
+
+// resourceVersion sets X-Resource-Version to version on every response,
+// errors included. It is installed with r.Use on a resource's subrouter,
+// so it applies to that subtree and no other.
+func resourceVersion(version string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(headerResourceVersion, version)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
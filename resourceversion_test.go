@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ========== Resource Version Tests ==========
+
+func TestResourceVersion_PostsSubtree(t *testing.T) {
+	router := setupRouter()
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/posts", http.StatusOK},
+		{"/posts/", http.StatusOK},
+		{"/posts/1", http.StatusOK},
+		{"/posts/slug/first-post", http.StatusOK},
+		{"/posts/count", http.StatusOK},
+		{"/posts/999", http.StatusNotFound},
+		{"/posts/1/nowhere", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			assert.Equal(t, tt.code, w.Code)
+			assert.Equal(t, postsResourceVersion, w.Header().Get(headerResourceVersion))
+		})
+	}
+}
+
+func TestResourceVersion_DoesNotLeak(t *testing.T) {
+	router := setupRouter()
+
+	for _, path := range []string{"/users", "/users/1", "/users/1/posts", "/users/1/posts/1/comments/1", "/postsx", "/todos"} {
+		t.Run(path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			assert.Empty(t, w.Header().Values(headerResourceVersion))
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// jsonBuffer pairs a buffer with an encoder that writes into it, so both can
+// be reused across responses.
+type jsonBuffer struct {
+	buf bytes.Buffer
+	enc *json.Encoder
+}
+
+// maxPooledBufferSize keeps unusually large responses from pinning memory
+// in the pool.
+const maxPooledBufferSize = 64 << 10
+
+var jsonBufferPool = sync.Pool{
+	New: func() interface{} {
+		jb := &jsonBuffer{}
+		jb.enc = json.NewEncoder(&jb.buf)
+		return jb
+	},
+}
+
+// respondJSON encodes v and writes it with the given status. The body is
+// encoded before anything is written, so an encoding failure can still be
+// reported as a 500. Field names follow JSON_NAMING.
+func respondJSON(w http.ResponseWriter, status int, v interface{}) {
+	writeJSON(w, status, "application/json", v)
+}
+
+// respondFormat is respondJSON for documents in a third-party format, such
+// as AsyncAPI or a Postman collection, whose member names the format fixes:
+// JSON_NAMING does not apply to them.
+func respondFormat(w http.ResponseWriter, status int, v interface{}) {
+	respondJSON(namingWriter{ResponseWriter: w}, status, v)
+}
+
+// writeJSON is respondJSON with an explicit JSON media type, such as
+// application/problem+json.
+func writeJSON(w http.ResponseWriter, status int, contentType string, v interface{}) {
+	jb := jsonBufferPool.Get().(*jsonBuffer)
+	defer func() {
+		if jb.buf.Cap() <= maxPooledBufferSize {
+			jb.buf.Reset()
+			jsonBufferPool.Put(jb)
+		}
+	}()
+
+	stop := writerPhaseTimer(w).time("encode")
+	var err error
+	if c := responseCodec(w); contentType == "application/json" && status < 400 && c.MediaType() != contentType {
+		// Error responses stay JSON whatever the route negotiated.
+		contentType = c.MediaType()
+		if _, ok := c.(bridgeCodec); ok {
+			v = renamed(v, writerFieldNaming(w))
+		}
+		err = c.Encode(&jb.buf, v)
+	} else {
+		err = jb.enc.Encode(renamed(v, writerFieldNaming(w)))
+	}
+	stop()
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		if w.Header().Get("Cache-Control") != "" {
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error":"internal error"}` + "\n"))
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if status >= 400 && w.Header().Get("Cache-Control") != "" {
+		// A route's caching policy covers its successful responses only.
+		w.Header().Set("Cache-Control", "no-store")
+	}
+	w.WriteHeader(status)
+	w.Write(jb.buf.Bytes())
+}
+
+// respondError writes the standard {"error": msg} body, with msg translated
+// into the language negotiated from r's Accept-Language header.
+func respondError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	respondJSON(w, status, map[string]string{"error": localize(w, r, msg)})
+}
+
+// Problem is an RFC 7807 problem details document.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// Resource is an extension member linking to an existing resource the
+	// request conflicts with.
+	Resource string `json:"resource,omitempty"`
+	// Violations is an extension member listing each way the request
+	// failed validation.
+	Violations []string `json:"violations,omitempty"`
+	// Supported is an extension member listing the media types a request
+	// body may have.
+	Supported []string `json:"supported,omitempty"`
+}
+
+// respondProblem writes an application/problem+json response. Type,
+// Status, and Instance default to about:blank, status, and the request path,
+// and the title is the localized msg.
+func respondProblem(w http.ResponseWriter, r *http.Request, status int, msg string, p Problem) {
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+	p.Title = localize(w, r, msg)
+	p.Status = status
+	writeJSON(w, status, "application/problem+json", p)
+}
+
+// respondNotFound reports that the resource with the given id does not
+// exist.
+func respondNotFound(w http.ResponseWriter, r *http.Request, resource string, id int) {
+	respondProblem(w, r, http.StatusNotFound, "not found", Problem{
+		Detail: fmt.Sprintf("%s %d does not exist", resource, id),
+	})
+}
+
+// respondDuplicate reports that the request would duplicate the existing
+// resource at location.
+func respondDuplicate(w http.ResponseWriter, r *http.Request, detail, location string) {
+	respondProblem(w, r, http.StatusConflict, "already exists", Problem{
+		Detail:   detail,
+		Resource: location,
+	})
+}
+
+// maxBodyBytes caps request bodies accepted by decodeJSON.
+const maxBodyBytes = 1 << 20
+
+var errBodyTooLarge = errors.New("request body too large")
+
+// decodeJSON strictly decodes a single value from the request body into v,
+// in the format negotiateCodec chose: JSON unless the route offers others,
+// with the field names nameFields gives. Unknown fields and trailing data
+// are rejected, and bodies over maxBodyBytes return errBodyTooLarge.
+func decodeJSON(r *http.Request, v interface{}) error {
+	return decodeJSONLimit(r, v, maxBodyBytes)
+}
+
+// decodeJSONLimit is decodeJSON with a body limit of limit bytes.
+func decodeJSONLimit(r *http.Request, v interface{}, limit int64) error {
+	defer phaseTimerFrom(r.Context()).time("decode")()
+	err := decodeNamed(requestCodec(r), http.MaxBytesReader(nil, r.Body, limit), v, requestFieldNaming(r))
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return errBodyTooLarge
+	}
+	return err
+}
+
+// respondDecodeError maps a decodeJSON error to a response.
+func respondDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, errBodyTooLarge) {
+		respondError(w, r, http.StatusRequestEntityTooLarge, "request body too large")
+		return
+	}
+	respondError(w, r, http.StatusBadRequest, "invalid json")
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== respondJSON Tests ==========
+
+func TestRespondJSON_WritesStatusAndBody(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	respondJSON(w, http.StatusCreated, map[string]int{"id": 7})
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"id":7}`, w.Body.String())
+}
+
+func TestRespondJSON_EncodeFailureIs500(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	// Channels cannot be encoded; nothing of the partial body may leak.
+	respondJSON(w, http.StatusOK, map[string]interface{}{"ok": true, "bad": make(chan int)})
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.JSONEq(t, `{"error":"internal error"}`, w.Body.String())
+}
+
+// ========== decodeJSON Tests ==========
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr bool
+	}{
+		{"valid", `{"name":"Alice","email":"a@example.com"}`, false},
+		{"empty object", `{}`, false},
+		{"trailing whitespace", "{}\n\t ", false},
+		{"unknown field", `{"name":"Alice","admin":true}`, true},
+		{"trailing data", `{"name":"Alice"}garbage`, true},
+		{"two values", `{}{}`, true},
+		{"wrong type", `{"name":42}`, true},
+		{"empty body", ``, true},
+		{"not json", `not json`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+
+			var user User
+			err := decodeJSON(req, &user)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDecodeJSON_BodyTooLarge(t *testing.T) {
+	body := `{"name":"` + strings.Repeat("a", maxBodyBytes) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+
+	var user User
+	err := decodeJSON(req, &user)
+
+	require.Error(t, err)
+	assert.ErrorIs(t, err, errBodyTooLarge)
+}
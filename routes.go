@@ -0,0 +1,908 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/api2spec/api2spec-fixture-chi/flags"
+)
+
+// RouteDef declares one route. The same table builds the chi router, feeds
+// generateSpec and is listed by GET /_routes. OperationID, Tag and Summary
+// are the route's stable identity in openapi.yaml, where SDK generators turn
+// them into method and client names; the contract tests hold the two in
+// step.
+type RouteDef struct {
+	Method      string
+	Pattern     string
+	Handler     http.HandlerFunc
+	OperationID string
+	Tag         string
+	Summary     string
+	// RequestType is a zero value of the request body, nil for none. A
+	// MediaType stands for a body that is not JSON.
+	RequestType interface{}
+	// ResponseTypes maps each success status, and any error status whose
+	// body is not an error document, to a zero value of its body; nil is
+	// an empty body.
+	ResponseTypes map[int]interface{}
+	// CacheControl is the Cache-Control policy of the route's successful
+	// responses, one of the cache* constants. Every GET route declares one.
+	CacheControl string
+	// Middlewares wrap Handler alone, such as requireAdmin.
+	Middlewares []func(http.Handler) http.Handler
+	// LatencyBudget is how long the route may take; slower requests are
+	// counted as violations. Zero is defaultLatencyBudget.
+	LatencyBudget time.Duration
+	// Codecs are the formats, besides JSON, its JSON bodies may be sent
+	// and requested in; see negotiateCodec. Error responses stay JSON.
+	Codecs []Codec
+	// Deprecated marks the route deprecated, nil if it is not.
+	Deprecated *Deprecation
+}
+
+// MediaType stands in RouteDef for a body of that media type, such as
+// "image/png", that has no JSON schema.
+type MediaType string
+
+// MediaTypes stands in RouteDef for a body that may have any of several
+// media types.
+type MediaTypes []MediaType
+
+// RouteInfo describes a route in GET /_routes.
+type RouteInfo struct {
+	Method      string `json:"method"`
+	Pattern     string `json:"pattern"`
+	OperationID string `json:"operationId"`
+	Tag         string `json:"tag"`
+	Summary     string `json:"summary"`
+	// RequestType and ResponseTypes name the bodies' schemas or media
+	// types, as described by typeName.
+	RequestType   string            `json:"requestType,omitempty"`
+	ResponseTypes map[string]string `json:"responseTypes"`
+	CacheControl  string            `json:"cacheControl,omitempty"`
+	// LatencyBudgetMS is the route's latency budget in milliseconds.
+	LatencyBudgetMS float64      `json:"latencyBudgetMs"`
+	Deprecated      *Deprecation `json:"deprecated,omitempty"`
+}
+
+// mountRoutes registers defs on r, each timed against its latency budget,
+// accepting only the request bodies it declares, negotiating its codecs and
+// announcing its deprecation.
+func (srv *Server) mountRoutes(r chi.Router, defs []RouteDef) {
+	for _, d := range defs {
+		mws := []func(http.Handler) http.Handler{srv.latencyBudget(d)}
+		if len(d.Codecs) > 0 {
+			mws = append(mws, negotiateCodec(d.Codecs))
+		}
+		mws = append(mws, d.Middlewares...)
+		if d.RequestType != nil {
+			mws = append(mws, requireContentType(d.requestMediaTypes()))
+		}
+		if d.CacheControl != "" {
+			mws = append([]func(http.Handler) http.Handler{cacheControl(d.CacheControl)}, mws...)
+		}
+		if d.Deprecated != nil {
+			mws = append([]func(http.Handler) http.Handler{deprecated(*d.Deprecated)}, mws...)
+		}
+		r.With(mws...).Method(d.Method, d.Pattern, d.Handler)
+	}
+}
+
+// apiRouteDefs is the public API's route table.
+func (srv *Server) apiRouteDefs() []RouteDef {
+	admin := []func(http.Handler) http.Handler{srv.requireAdmin}
+	v2 := []func(http.Handler) http.Handler{requireFlag(flags.EnableV2Users)}
+	me := []func(http.Handler) http.Handler{requireUser}
+	users, posts, albums, todos := srv.users(), srv.posts(), srv.albums(), srv.todos()
+
+	return []RouteDef{
+		// Spec routes
+		{
+			Method: http.MethodGet, Pattern: "/openapi.yaml", Handler: srv.specHandler,
+			OperationID: "getSpec", Tag: "spec", Summary: "Download this OpenAPI document",
+			ResponseTypes: map[int]interface{}{http.StatusOK: MediaType("application/yaml")},
+			CacheControl:  cachePublic,
+		},
+
+		{
+			Method: http.MethodGet, Pattern: "/postman.json", Handler: srv.postmanHandler,
+			OperationID: "getPostmanCollection", Tag: "spec", Summary: "Download a Postman collection of this API",
+			ResponseTypes: map[int]interface{}{http.StatusOK: rawJSON},
+			CacheControl:  cachePublic,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/insomnia.json", Handler: srv.insomniaHandler,
+			OperationID: "getInsomniaExport", Tag: "spec", Summary: "Download an Insomnia export of this API",
+			ResponseTypes: map[int]interface{}{http.StatusOK: rawJSON},
+			CacheControl:  cachePublic,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/asyncapi.json", Handler: srv.asyncAPIHandler,
+			OperationID: "getAsyncAPI", Tag: "spec", Summary: "Download the AsyncAPI document of the event channels",
+			ResponseTypes: map[int]interface{}{http.StatusOK: rawJSON},
+			CacheControl:  cachePublic,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/spec/diff", Handler: srv.getSpecDiff,
+			OperationID: "diffSpecs", Tag: "spec", Summary: "Compare the OpenAPI documents of two API versions",
+			ResponseTypes: map[int]interface{}{http.StatusOK: SpecDiff{}},
+			CacheControl:  cachePublic,
+		},
+
+		// Stats routes
+		{
+			Method: http.MethodGet, Pattern: "/stats", Handler: srv.statsHandler,
+			OperationID: "getStats", Tag: "stats", Summary: "Get request and entity statistics",
+			ResponseTypes: map[int]interface{}{http.StatusOK: Stats{}},
+			CacheControl:  cacheNoStore,
+		},
+
+		// User routes
+		{
+			Method: http.MethodGet, Pattern: "/users", Handler: users.list,
+			OperationID: "listUsers", Tag: "users", Summary: "List users",
+			ResponseTypes: map[int]interface{}{http.StatusOK: []User{}, http.StatusPartialContent: []User{}},
+			CacheControl:  cachePublic,
+			Codecs:        bodyCodecs,
+		},
+		{
+			Method: http.MethodPost, Pattern: "/users", Handler: users.create,
+			OperationID: "createUser", Tag: "users", Summary: "Create a user",
+			RequestType:   User{},
+			ResponseTypes: map[int]interface{}{http.StatusCreated: User{}},
+			Codecs:        bodyCodecs,
+		},
+		// The static /users/... routes share their segment with {id}. chi
+		// tries static segments first whatever the order here, and falls
+		// back to {id} for methods they lack: PUT /users/me is PUT
+		// /users/{id} with an invalid ID.
+		{
+			Method: http.MethodGet, Pattern: "/users/count", Handler: srv.countUsers,
+			OperationID: "countUsers", Tag: "users", Summary: "Count users",
+			ResponseTypes: map[int]interface{}{http.StatusOK: Count{}},
+			CacheControl:  cachePublic,
+		},
+		{
+			Method: http.MethodPost, Pattern: "/users/import", Handler: srv.importUsers,
+			OperationID: "importUsers", Tag: "users", Summary: "Create users from a CSV file",
+			RequestType:   MediaTypes{"multipart/form-data", "text/csv"},
+			ResponseTypes: map[int]interface{}{http.StatusOK: ImportReport{}},
+			LatencyBudget: 2 * time.Second,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/users/me", Handler: srv.getMe,
+			OperationID: "getMyUser", Tag: "users", Summary: "Get the authenticated user, as GET /me",
+			ResponseTypes: map[int]interface{}{http.StatusOK: User{}},
+			CacheControl:  cacheNoStore,
+			Codecs:        bodyCodecs,
+			Middlewares:   me,
+			Deprecated:    &Deprecation{Since: usersMeDeprecated, Sunset: &usersMeSunset, Successor: "/me"},
+		},
+		{
+			Method: http.MethodGet, Pattern: "/users/new", Handler: srv.newUser,
+			OperationID: "newUser", Tag: "users", Summary: "Get the blank user a create form starts from",
+			ResponseTypes: map[int]interface{}{http.StatusOK: User{}},
+			CacheControl:  cachePublic,
+			Codecs:        bodyCodecs,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/users/sample", Handler: srv.sampleUsers,
+			OperationID: "sampleUsers", Tag: "users", Summary: "Pick users at random",
+			ResponseTypes: map[int]interface{}{http.StatusOK: []User{}},
+			CacheControl:  cacheNoStore,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/users/suggest", Handler: srv.suggestUsers,
+			OperationID: "suggestUsers", Tag: "users", Summary: "Suggest users whose names start with a prefix",
+			ResponseTypes: map[int]interface{}{http.StatusOK: []UserSuggestion{}},
+			CacheControl:  cachePublic,
+			// Called on every keystroke.
+			LatencyBudget: 50 * time.Millisecond,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/users/{id}", Handler: users.get,
+			OperationID: "getUser", Tag: "users", Summary: "Get a user",
+			ResponseTypes: map[int]interface{}{http.StatusOK: User{}},
+			CacheControl:  cachePrivate,
+			Codecs:        userPageCodecs,
+		},
+		{
+			Method: http.MethodPut, Pattern: "/users/{id}", Handler: users.update,
+			OperationID: "updateUser", Tag: "users", Summary: "Replace a user",
+			RequestType:   User{},
+			ResponseTypes: map[int]interface{}{http.StatusOK: User{}, http.StatusConflict: User{}},
+			Codecs:        bodyCodecs,
+		},
+		{
+			Method: http.MethodDelete, Pattern: "/users/{id}", Handler: users.delete,
+			OperationID: "deleteUser", Tag: "users", Summary: "Delete a user",
+			ResponseTypes: map[int]interface{}{http.StatusNoContent: nil},
+		},
+		{
+			Method: http.MethodPost, Pattern: "/users/{id}/password", Handler: srv.changePassword,
+			OperationID: "changePassword", Tag: "users", Summary: "Change the caller's password",
+			RequestType:   PasswordChange{},
+			ResponseTypes: map[int]interface{}{http.StatusNoContent: nil},
+			Middlewares:   me,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/users/{id}/posts", Handler: srv.getUserPosts,
+			OperationID: "listUserPosts", Tag: "users", Summary: "List a user's posts",
+			ResponseTypes: map[int]interface{}{http.StatusOK: []Post{}, http.StatusPartialContent: []Post{}},
+			CacheControl:  cachePrivate,
+			Codecs:        bodyCodecs,
+		},
+		{
+			Method: http.MethodPost, Pattern: "/users/{id}/posts", Handler: srv.createUserPost,
+			OperationID: "createUserPost", Tag: "users", Summary: "Create a post by a user",
+			RequestType:   Post{},
+			ResponseTypes: map[int]interface{}{http.StatusCreated: Post{}},
+			Codecs:        bodyCodecs,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/users/{id}/gravatar", Handler: srv.getUserGravatar,
+			OperationID: "getUserGravatar", Tag: "users", Summary: "Get a user's Gravatar profile",
+			ResponseTypes: map[int]interface{}{http.StatusOK: Gravatar{}},
+			CacheControl:  cachePrivate,
+			// A call to Gravatar, retries included.
+			LatencyBudget: 2 * time.Second,
+		},
+		// Named apart from {id}, the three IDs say which is which. chi
+		// keeps parameter names per route, so they share the segment.
+		{
+			Method: http.MethodGet, Pattern: "/users/{userId}/posts/{postId}/comments/{commentId}", Handler: srv.getUserPostComment,
+			OperationID: "getUserPostComment", Tag: "users", Summary: "Get a comment on a user's post",
+			ResponseTypes: map[int]interface{}{http.StatusOK: Comment{}},
+			CacheControl:  cachePrivate,
+		},
+		{
+			Method: http.MethodPost, Pattern: "/users/{id}/verify/send", Handler: srv.sendVerification,
+			OperationID: "sendVerification", Tag: "users", Summary: "Mail the caller an email verification link",
+			ResponseTypes: map[int]interface{}{http.StatusAccepted: nil},
+			Middlewares:   me,
+		},
+
+		// Version 2 user routes
+		{
+			Method: http.MethodGet, Pattern: "/v2/users", Handler: srv.listUsersV2,
+			OperationID: "listUsersV2", Tag: "v2", Summary: "List users with links",
+			ResponseTypes: map[int]interface{}{http.StatusOK: []UserV2{}},
+			CacheControl:  cachePublic,
+			Middlewares:   v2,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/v2/users/{id}", Handler: srv.getUserV2,
+			OperationID: "getUserV2", Tag: "v2", Summary: "Get a user with links",
+			ResponseTypes: map[int]interface{}{http.StatusOK: UserV2{}},
+			CacheControl:  cachePrivate,
+			Middlewares:   v2,
+		},
+
+		// Post routes
+		{
+			Method: http.MethodGet, Pattern: "/posts", Handler: posts.list,
+			OperationID: "listPosts", Tag: "posts", Summary: "List posts",
+			ResponseTypes: map[int]interface{}{http.StatusOK: []Post{}, http.StatusPartialContent: []Post{}},
+			CacheControl:  cachePublic,
+			Codecs:        postCodecs,
+		},
+		{
+			Method: http.MethodPost, Pattern: "/posts", Handler: posts.create,
+			OperationID: "createPost", Tag: "posts", Summary: "Create a post",
+			RequestType:   Post{},
+			ResponseTypes: map[int]interface{}{http.StatusCreated: Post{}},
+			Codecs:        postCodecs,
+		},
+		{
+			Method: http.MethodPost, Pattern: "/posts/bulk", Handler: srv.bulkCreatePosts,
+			OperationID: "bulkCreatePosts", Tag: "posts", Summary: "Create posts from JSON Lines",
+			RequestType:   MediaType(ndjsonMediaType),
+			ResponseTypes: map[int]interface{}{http.StatusOK: MediaType(ndjsonMediaType)},
+			LatencyBudget: 2 * time.Second,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/posts/count", Handler: srv.countPosts,
+			OperationID: "countPosts", Tag: "posts", Summary: "Count posts, optionally by one user",
+			ResponseTypes: map[int]interface{}{http.StatusOK: Count{}},
+			CacheControl:  cachePublic,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/posts/feed.atom", Handler: srv.getAtomFeed,
+			OperationID: "getPostsAtomFeed", Tag: "posts", Summary: "Get the newest posts as an Atom feed",
+			ResponseTypes: map[int]interface{}{http.StatusOK: MediaType(atomMediaType)},
+			CacheControl:  cachePublic,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/posts/feed.rss", Handler: srv.getRSSFeed,
+			OperationID: "getPostsRSSFeed", Tag: "posts", Summary: "Get the newest posts as an RSS feed",
+			ResponseTypes: map[int]interface{}{http.StatusOK: MediaType(rssMediaType)},
+			CacheControl:  cachePublic,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/posts/random", Handler: srv.randomPost,
+			OperationID: "getRandomPost", Tag: "posts", Summary: "Pick a post at random",
+			ResponseTypes: map[int]interface{}{http.StatusOK: Post{}},
+			CacheControl:  cacheNoStore,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/posts/slug/{slug}", Handler: srv.getPostBySlug,
+			OperationID: "getPostBySlug", Tag: "posts", Summary: "Get a post by its slug",
+			ResponseTypes: map[int]interface{}{http.StatusOK: Post{}},
+			CacheControl:  cachePrivate,
+			Codecs:        postCodecs,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/posts/{id}", Handler: posts.get,
+			OperationID: "getPost", Tag: "posts", Summary: "Get a post",
+			ResponseTypes: map[int]interface{}{http.StatusOK: Post{}},
+			CacheControl:  cachePrivate,
+			Codecs:        postPageCodecs,
+		},
+		{
+			Method: http.MethodPut, Pattern: "/posts/{id}", Handler: posts.update,
+			OperationID: "updatePost", Tag: "posts", Summary: "Replace a post",
+			RequestType:   Post{},
+			ResponseTypes: map[int]interface{}{http.StatusOK: Post{}, http.StatusConflict: Post{}},
+			Codecs:        postCodecs,
+		},
+		{
+			Method: http.MethodDelete, Pattern: "/posts/{id}", Handler: posts.delete,
+			OperationID: "deletePost", Tag: "posts", Summary: "Delete a post",
+			ResponseTypes: map[int]interface{}{http.StatusNoContent: nil},
+		},
+
+		// Album routes
+		{
+			Method: http.MethodGet, Pattern: "/albums", Handler: albums.list,
+			OperationID: "listAlbums", Tag: "albums", Summary: "List albums",
+			ResponseTypes: map[int]interface{}{http.StatusOK: []Album{}, http.StatusPartialContent: []Album{}},
+			CacheControl:  cachePublic,
+		},
+		{
+			Method: http.MethodPost, Pattern: "/albums", Handler: albums.create,
+			OperationID: "createAlbum", Tag: "albums", Summary: "Create an album",
+			RequestType:   Album{},
+			ResponseTypes: map[int]interface{}{http.StatusCreated: Album{}},
+		},
+		{
+			Method: http.MethodGet, Pattern: "/albums/{id}", Handler: albums.get,
+			OperationID: "getAlbum", Tag: "albums", Summary: "Get an album",
+			ResponseTypes: map[int]interface{}{http.StatusOK: Album{}},
+			CacheControl:  cachePrivate,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/albums/{id}/photos", Handler: srv.getAlbumPhotos,
+			OperationID: "listAlbumPhotos", Tag: "albums", Summary: "List an album's photos",
+			ResponseTypes: map[int]interface{}{http.StatusOK: []Photo{}, http.StatusPartialContent: []Photo{}},
+			CacheControl:  cachePublic,
+		},
+		{
+			Method: http.MethodPost, Pattern: "/albums/{id}/photos", Handler: srv.uploadPhoto,
+			OperationID: "uploadPhoto", Tag: "albums", Summary: "Upload a photo to an album",
+			RequestType:   MediaType("multipart/form-data"),
+			ResponseTypes: map[int]interface{}{http.StatusCreated: Photo{}},
+			LatencyBudget: time.Second,
+		},
+
+		// Photo routes
+		{
+			Method: http.MethodGet, Pattern: "/photos/{id}", Handler: srv.getPhoto,
+			OperationID: "getPhoto", Tag: "photos", Summary: "Get a photo's metadata",
+			ResponseTypes: map[int]interface{}{http.StatusOK: Photo{}},
+			CacheControl:  cachePrivate,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/photos/{id}/thumbnail", Handler: srv.getPhotoThumbnail,
+			OperationID: "getPhotoThumbnail", Tag: "photos", Summary: "Get a photo's PNG thumbnail",
+			ResponseTypes: map[int]interface{}{http.StatusOK: MediaType("image/png")},
+			CacheControl:  cachePrivate,
+			LatencyBudget: 500 * time.Millisecond,
+		},
+
+		// Ingest routes
+		{
+			Method: http.MethodGet, Pattern: "/ingest/events", Handler: srv.listIngestEvents,
+			OperationID: "listIngestEvents", Tag: "ingest", Summary: "List accepted ingest events",
+			ResponseTypes: map[int]interface{}{http.StatusOK: []IngestEvent{}},
+			CacheControl:  cacheNoStore,
+			Middlewares:   admin,
+		},
+		{
+			Method: http.MethodPost, Pattern: "/ingest/events", Handler: srv.ingestEvent,
+			OperationID: "ingestEvent", Tag: "ingest", Summary: "Accept a signed event",
+			RequestType:   rawJSON,
+			ResponseTypes: map[int]interface{}{http.StatusAccepted: IngestEvent{}},
+		},
+		{
+			Method: http.MethodGet, Pattern: "/ingest/events/{id}", Handler: srv.getIngestEvent,
+			OperationID: "getIngestEvent", Tag: "ingest", Summary: "Get an accepted ingest event",
+			ResponseTypes: map[int]interface{}{http.StatusOK: IngestEvent{}},
+			CacheControl:  cacheNoStore,
+			Middlewares:   admin,
+		},
+
+		// Invite routes
+		{
+			Method: http.MethodPost, Pattern: "/invites", Handler: srv.createInvite,
+			OperationID: "createInvite", Tag: "invites", Summary: "Invite an email to create a user",
+			RequestType:   Invite{},
+			ResponseTypes: map[int]interface{}{http.StatusCreated: Invite{}},
+			Middlewares:   admin,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/invites/{token}", Handler: srv.getInvite,
+			OperationID: "getInvite", Tag: "invites", Summary: "Check an invite",
+			ResponseTypes: map[int]interface{}{http.StatusOK: Invite{}},
+			CacheControl:  cacheNoStore,
+		},
+		{
+			Method: http.MethodPost, Pattern: "/invites/{token}/accept", Handler: srv.acceptInvite,
+			OperationID: "acceptInvite", Tag: "invites", Summary: "Accept an invite, creating the user",
+			RequestType:   InviteAcceptance{},
+			ResponseTypes: map[int]interface{}{http.StatusCreated: User{}},
+		},
+
+		// Auth routes
+		{
+			Method: http.MethodPost, Pattern: "/auth/login", Handler: srv.login,
+			OperationID: "login", Tag: "auth", Summary: "Get a user token with an email and password",
+			RequestType:   Login{},
+			ResponseTypes: map[int]interface{}{http.StatusOK: UserToken{}},
+		},
+		{
+			Method: http.MethodPost, Pattern: "/auth/2fa/setup", Handler: srv.setupTwoFactor,
+			OperationID: "setupTwoFactor", Tag: "auth", Summary: "Start setting up two-factor authentication",
+			ResponseTypes: map[int]interface{}{http.StatusOK: TwoFactorSetup{}},
+			Middlewares:   me,
+		},
+		{
+			Method: http.MethodPost, Pattern: "/auth/2fa/verify", Handler: srv.verifyTwoFactor,
+			OperationID: "verifyTwoFactor", Tag: "auth", Summary: "Enable two-factor authentication with a code",
+			RequestType:   TwoFactorCode{},
+			ResponseTypes: map[int]interface{}{http.StatusNoContent: nil},
+			Middlewares:   me,
+		},
+		{
+			Method: http.MethodPost, Pattern: "/auth/password-reset", Handler: srv.requestPasswordReset,
+			OperationID: "requestPasswordReset", Tag: "auth", Summary: "Mail a password reset token",
+			RequestType:   PasswordResetRequest{},
+			ResponseTypes: map[int]interface{}{http.StatusAccepted: nil},
+		},
+		{
+			Method: http.MethodPost, Pattern: "/auth/password-reset/confirm", Handler: srv.confirmPasswordReset,
+			OperationID: "confirmPasswordReset", Tag: "auth", Summary: "Choose a new password with a reset token",
+			RequestType:   PasswordReset{},
+			ResponseTypes: map[int]interface{}{http.StatusNoContent: nil},
+		},
+		{
+			Method: http.MethodGet, Pattern: "/verify", Handler: srv.verifyEmail,
+			OperationID: "verifyEmail", Tag: "auth", Summary: "Verify an email with a mailed token",
+			ResponseTypes: map[int]interface{}{http.StatusOK: User{}},
+			CacheControl:  cacheNoStore,
+		},
+
+		// Me routes
+		{
+			Method: http.MethodGet, Pattern: "/me", Handler: srv.getMe,
+			OperationID: "getMe", Tag: "me", Summary: "Get the authenticated user",
+			ResponseTypes: map[int]interface{}{http.StatusOK: User{}},
+			CacheControl:  cacheNoStore,
+			Codecs:        bodyCodecs,
+			Middlewares:   me,
+		},
+		{
+			Method: http.MethodPut, Pattern: "/me", Handler: srv.updateMe,
+			OperationID: "updateMe", Tag: "me", Summary: "Replace the authenticated user",
+			RequestType:   User{},
+			ResponseTypes: map[int]interface{}{http.StatusOK: User{}, http.StatusConflict: User{}},
+			Codecs:        bodyCodecs,
+			Middlewares:   me,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/me/posts", Handler: srv.getMyPosts,
+			OperationID: "listMyPosts", Tag: "me", Summary: "List the authenticated user's posts",
+			ResponseTypes: map[int]interface{}{http.StatusOK: []Post{}, http.StatusPartialContent: []Post{}},
+			CacheControl:  cacheNoStore,
+			Codecs:        bodyCodecs,
+			Middlewares:   me,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/me/sessions", Handler: srv.listMySessions,
+			OperationID: "listMySessions", Tag: "me", Summary: "List the caller's active sessions",
+			ResponseTypes: map[int]interface{}{http.StatusOK: []Session{}},
+			CacheControl:  cacheNoStore,
+			Middlewares:   me,
+		},
+		{
+			Method: http.MethodDelete, Pattern: "/me/sessions/{id}", Handler: srv.revokeMySession,
+			OperationID: "revokeMySession", Tag: "me", Summary: "Revoke one of the caller's sessions",
+			ResponseTypes: map[int]interface{}{http.StatusNoContent: nil},
+			Middlewares:   me,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/me/usage", Handler: srv.getMyUsage,
+			OperationID: "getMyUsage", Tag: "me", Summary: "Get the caller's usage of their daily quota",
+			ResponseTypes: map[int]interface{}{http.StatusOK: Usage{}},
+			CacheControl:  cacheNoStore,
+			Middlewares:   me,
+		},
+
+		// Metrics routes
+		{
+			Method: http.MethodGet, Pattern: "/metrics/posts", Handler: srv.getPostMetrics,
+			OperationID: "getPostMetrics", Tag: "metrics", Summary: "Count posts created per hour or day",
+			ResponseTypes: map[int]interface{}{http.StatusOK: []MetricBucket{}},
+			CacheControl:  cachePublic,
+		},
+
+		// Place routes
+		{
+			Method: http.MethodGet, Pattern: "/places", Handler: srv.listPlaces,
+			OperationID: "listPlaces", Tag: "places", Summary: "List places near a point",
+			ResponseTypes: map[int]interface{}{http.StatusOK: []NearbyPlace{}},
+			CacheControl:  cachePublic,
+		},
+
+		// Proxy routes
+		{
+			Method: http.MethodGet, Pattern: proxyPrefix + "/*", Handler: srv.proxy,
+			OperationID: "proxyGet", Tag: "proxy", Summary: "Forward a GET request to an upstream service",
+			ResponseTypes: map[int]interface{}{http.StatusOK: MediaType("*/*")},
+			CacheControl:  cacheNoStore,
+			LatencyBudget: proxyLatencyBudget,
+		},
+		{
+			Method: http.MethodPost, Pattern: proxyPrefix + "/*", Handler: srv.proxy,
+			OperationID: "proxyPost", Tag: "proxy", Summary: "Forward a POST request to an upstream service",
+			ResponseTypes: map[int]interface{}{http.StatusOK: MediaType("*/*")},
+			LatencyBudget: proxyLatencyBudget,
+		},
+		{
+			Method: http.MethodPut, Pattern: proxyPrefix + "/*", Handler: srv.proxy,
+			OperationID: "proxyPut", Tag: "proxy", Summary: "Forward a PUT request to an upstream service",
+			ResponseTypes: map[int]interface{}{http.StatusOK: MediaType("*/*")},
+			LatencyBudget: proxyLatencyBudget,
+		},
+		{
+			Method: http.MethodPatch, Pattern: proxyPrefix + "/*", Handler: srv.proxy,
+			OperationID: "proxyPatch", Tag: "proxy", Summary: "Forward a PATCH request to an upstream service",
+			ResponseTypes: map[int]interface{}{http.StatusOK: MediaType("*/*")},
+			LatencyBudget: proxyLatencyBudget,
+		},
+		{
+			Method: http.MethodDelete, Pattern: proxyPrefix + "/*", Handler: srv.proxy,
+			OperationID: "proxyDelete", Tag: "proxy", Summary: "Forward a DELETE request to an upstream service",
+			ResponseTypes: map[int]interface{}{http.StatusOK: MediaType("*/*")},
+			LatencyBudget: proxyLatencyBudget,
+		},
+
+		// Tenant routes
+		{
+			Method: http.MethodGet, Pattern: "/tenants", Handler: srv.listTenants,
+			OperationID: "listTenants", Tag: "tenants", Summary: "List tenants",
+			ResponseTypes: map[int]interface{}{http.StatusOK: []Tenant{}},
+			CacheControl:  cacheNoStore,
+			Middlewares:   admin,
+		},
+		{
+			Method: http.MethodPost, Pattern: "/tenants", Handler: srv.createTenant,
+			OperationID: "createTenant", Tag: "tenants", Summary: "Create a tenant",
+			RequestType:   Tenant{},
+			ResponseTypes: map[int]interface{}{http.StatusCreated: Tenant{}},
+			Middlewares:   admin,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/tenants/{id}", Handler: srv.getTenant,
+			OperationID: "getTenant", Tag: "tenants", Summary: "Get a tenant",
+			ResponseTypes: map[int]interface{}{http.StatusOK: Tenant{}},
+			CacheControl:  cacheNoStore,
+			Middlewares:   admin,
+		},
+		{
+			Method: http.MethodPut, Pattern: "/tenants/{id}", Handler: srv.updateTenant,
+			OperationID: "updateTenant", Tag: "tenants", Summary: "Replace a tenant",
+			RequestType:   Tenant{},
+			ResponseTypes: map[int]interface{}{http.StatusOK: Tenant{}, http.StatusConflict: Tenant{}},
+			Middlewares:   admin,
+		},
+		{
+			Method: http.MethodDelete, Pattern: "/tenants/{id}", Handler: srv.deleteTenant,
+			OperationID: "deleteTenant", Tag: "tenants", Summary: "Delete a tenant",
+			ResponseTypes: map[int]interface{}{http.StatusNoContent: nil},
+			Middlewares:   admin,
+		},
+
+		// Todo routes
+		{
+			Method: http.MethodGet, Pattern: "/todos", Handler: srv.listTodos,
+			OperationID: "listTodos", Tag: "todos", Summary: "List todos",
+			ResponseTypes: map[int]interface{}{http.StatusOK: []Todo{}, http.StatusPartialContent: []Todo{}},
+			CacheControl:  cachePublic,
+		},
+		{
+			Method: http.MethodPost, Pattern: "/todos", Handler: todos.create,
+			OperationID: "createTodo", Tag: "todos", Summary: "Create a todo",
+			RequestType:   Todo{},
+			ResponseTypes: map[int]interface{}{http.StatusCreated: Todo{}},
+		},
+		{
+			Method: http.MethodGet, Pattern: "/todos/{id}", Handler: todos.get,
+			OperationID: "getTodo", Tag: "todos", Summary: "Get a todo",
+			ResponseTypes: map[int]interface{}{http.StatusOK: Todo{}},
+			CacheControl:  cachePrivate,
+		},
+
+		// Webhook routes
+		{
+			Method: http.MethodGet, Pattern: "/webhooks", Handler: srv.listWebhooks,
+			OperationID: "listWebhooks", Tag: "webhooks", Summary: "List webhooks",
+			ResponseTypes: map[int]interface{}{http.StatusOK: []Webhook{}},
+			CacheControl:  cacheNoStore,
+			Middlewares:   admin,
+		},
+		{
+			Method: http.MethodPost, Pattern: "/webhooks", Handler: srv.createWebhook,
+			OperationID: "createWebhook", Tag: "webhooks", Summary: "Create a webhook",
+			RequestType:   Webhook{},
+			ResponseTypes: map[int]interface{}{http.StatusCreated: Webhook{}},
+			Middlewares:   admin,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/webhooks/{id}", Handler: srv.getWebhook,
+			OperationID: "getWebhook", Tag: "webhooks", Summary: "Get a webhook",
+			ResponseTypes: map[int]interface{}{http.StatusOK: Webhook{}},
+			CacheControl:  cacheNoStore,
+			Middlewares:   admin,
+		},
+		{
+			Method: http.MethodDelete, Pattern: "/webhooks/{id}", Handler: srv.deleteWebhook,
+			OperationID: "deleteWebhook", Tag: "webhooks", Summary: "Delete a webhook",
+			ResponseTypes: map[int]interface{}{http.StatusNoContent: nil},
+			Middlewares:   admin,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/webhooks/{id}/deliveries", Handler: srv.getWebhookDeliveries,
+			OperationID: "listWebhookDeliveries", Tag: "webhooks", Summary: "List a webhook's deliveries",
+			ResponseTypes: map[int]interface{}{http.StatusOK: []Delivery{}},
+			CacheControl:  cacheNoStore,
+			Middlewares:   admin,
+		},
+
+		// Batch routes
+		{
+			Method: http.MethodPost, Pattern: "/batch", Handler: srv.runBatch,
+			OperationID: "runBatch", Tag: "batch", Summary: "Run several requests in one",
+			RequestType:   []BatchOperation{},
+			ResponseTypes: map[int]interface{}{http.StatusOK: []BatchResult{}},
+			// Up to maxBatchOperations requests, each with its own budget.
+			LatencyBudget: 5 * time.Second,
+		},
+	}
+}
+
+// opsRouteDefs is the operations listener's route table. The profiler,
+// mounted alongside, is not part of it.
+func (srv *Server) opsRouteDefs() []RouteDef {
+	admin := []func(http.Handler) http.Handler{srv.requireAdmin}
+
+	return []RouteDef{
+		// Health routes
+		{
+			Method: http.MethodGet, Pattern: "/health", Handler: srv.healthHandler,
+			OperationID: "getHealth", Tag: "health", Summary: "Check liveness",
+			ResponseTypes: map[int]interface{}{http.StatusOK: HealthStatus{}},
+			CacheControl:  cacheNoStore,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/health/ready", Handler: srv.readyHandler,
+			OperationID: "getReadiness", Tag: "health", Summary: "Check readiness",
+			ResponseTypes: map[int]interface{}{http.StatusOK: HealthStatus{}},
+			CacheControl:  cacheNoStore,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/health/history", Handler: srv.getHealthHistory,
+			OperationID: "getHealthHistory", Tag: "health", Summary: "List recent readiness transitions",
+			ResponseTypes: map[int]interface{}{http.StatusOK: []HealthTransition{}},
+			CacheControl:  cacheNoStore,
+		},
+
+		// Prometheus routes
+		{
+			Method: http.MethodGet, Pattern: "/metrics", Handler: srv.prometheusHandler,
+			OperationID: "getMetrics", Tag: "metrics", Summary: "Export metrics in the Prometheus text format",
+			ResponseTypes: map[int]interface{}{http.StatusOK: MediaType("text/plain")},
+			CacheControl:  cacheNoStore,
+		},
+
+		// Route table routes
+		{
+			Method: http.MethodGet, Pattern: "/_routes", Handler: srv.listRoutes,
+			OperationID: "listRoutes", Tag: "routes", Summary: "List every route",
+			ResponseTypes: map[int]interface{}{http.StatusOK: []RouteInfo{}},
+			CacheControl:  cacheNoStore,
+		},
+
+		// Admin routes
+		{
+			Method: http.MethodGet, Pattern: "/admin/config", Handler: srv.getConfig,
+			OperationID: "getConfig", Tag: "admin", Summary: "Get the effective runtime configuration",
+			ResponseTypes: map[int]interface{}{http.StatusOK: ConfigReport{}},
+			CacheControl:  cacheNoStore,
+			Middlewares:   admin,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/admin/dbstats", Handler: srv.getDBStats,
+			OperationID: "getDBStats", Tag: "admin", Summary: "Get database connection pool statistics",
+			ResponseTypes: map[int]interface{}{http.StatusOK: DBStats{}},
+			CacheControl:  cacheNoStore,
+			Middlewares:   admin,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/admin/events", Handler: srv.streamEvents,
+			OperationID: "streamEvents", Tag: "admin", Summary: "Stream entity events as server-sent events",
+			ResponseTypes: map[int]interface{}{http.StatusOK: MediaType("text/event-stream")},
+			CacheControl:  cacheNoStore,
+			Middlewares:   admin,
+			// The stream lasts as long as the client listens.
+			LatencyBudget: 24 * time.Hour,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/admin/flags", Handler: srv.getFlags,
+			OperationID: "getFlags", Tag: "admin", Summary: "Get the feature flags",
+			ResponseTypes: map[int]interface{}{http.StatusOK: flags.Set{}},
+			CacheControl:  cacheNoStore,
+			Middlewares:   admin,
+		},
+		{
+			Method: http.MethodPatch, Pattern: "/admin/flags", Handler: srv.patchFlags,
+			OperationID: "updateFlags", Tag: "admin", Summary: "Change some feature flags",
+			RequestType:   flags.Set{},
+			ResponseTypes: map[int]interface{}{http.StatusOK: flags.Set{}},
+			Middlewares:   admin,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/admin/ip-rules", Handler: srv.getIPRules,
+			OperationID: "getIPRules", Tag: "admin", Summary: "Get the client address ranges allowed and denied /admin",
+			ResponseTypes: map[int]interface{}{http.StatusOK: IPRules{}},
+			CacheControl:  cacheNoStore,
+			Middlewares:   admin,
+		},
+		{
+			Method: http.MethodPut, Pattern: "/admin/ip-rules", Handler: srv.putIPRules,
+			OperationID: "updateIPRules", Tag: "admin", Summary: "Replace the client address ranges allowed and denied /admin",
+			RequestType:   IPRules{},
+			ResponseTypes: map[int]interface{}{http.StatusOK: IPRules{}},
+			Middlewares:   admin,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/admin/jobs", Handler: srv.listJobs,
+			OperationID: "listJobs", Tag: "admin", Summary: "List the scheduled jobs with their last runs",
+			ResponseTypes: map[int]interface{}{http.StatusOK: []JobStatus{}},
+			CacheControl:  cacheNoStore,
+			Middlewares:   admin,
+		},
+		{
+			Method: http.MethodPost, Pattern: "/admin/jobs/{name}/run", Handler: srv.runJob,
+			OperationID: "runJob", Tag: "admin", Summary: "Run a scheduled job now",
+			ResponseTypes: map[int]interface{}{http.StatusOK: JobRun{}},
+			Middlewares:   admin,
+			// A job runs to the end before the response is sent.
+			LatencyBudget: time.Minute,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/admin/leader", Handler: srv.getLeader,
+			OperationID: "getLeader", Tag: "admin", Summary: "Get this instance's view of the background job leader election",
+			ResponseTypes: map[int]interface{}{http.StatusOK: LeaderStatus{}},
+			CacheControl:  cacheNoStore,
+			Middlewares:   admin,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/admin/rules", Handler: srv.listRules,
+			OperationID: "listRules", Tag: "admin", Summary: "List the response override rules",
+			ResponseTypes: map[int]interface{}{http.StatusOK: []Rule{}},
+			CacheControl:  cacheNoStore,
+			Middlewares:   admin,
+		},
+		{
+			Method: http.MethodPost, Pattern: "/admin/rules", Handler: srv.createRule,
+			OperationID: "createRule", Tag: "admin", Summary: "Register a response override rule",
+			RequestType:   Rule{},
+			ResponseTypes: map[int]interface{}{http.StatusCreated: Rule{}},
+			Middlewares:   admin,
+		},
+		{
+			Method: http.MethodDelete, Pattern: "/admin/rules/{id}", Handler: srv.deleteRule,
+			OperationID: "deleteRule", Tag: "admin", Summary: "Delete a response override rule",
+			ResponseTypes: map[int]interface{}{http.StatusNoContent: nil},
+			Middlewares:   admin,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/admin/shadow", Handler: srv.getShadowReport,
+			OperationID: "getShadowReport", Tag: "admin", Summary: "Compare the shadow upstream's responses with this server's",
+			ResponseTypes: map[int]interface{}{http.StatusOK: ShadowReport{}},
+			CacheControl:  cacheNoStore,
+			Middlewares:   admin,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/admin/state", Handler: srv.getState,
+			OperationID: "getState", Tag: "admin", Summary: "Dump the entire store",
+			ResponseTypes: map[int]interface{}{http.StatusOK: State{}},
+			CacheControl:  cacheNoStore,
+			Middlewares:   admin,
+			LatencyBudget: time.Second,
+		},
+		{
+			Method: http.MethodPut, Pattern: "/admin/state", Handler: srv.putState,
+			OperationID: "restoreState", Tag: "admin", Summary: "Replace the entire store",
+			RequestType:   State{},
+			ResponseTypes: map[int]interface{}{http.StatusOK: State{}},
+			Middlewares:   admin,
+			LatencyBudget: 2 * time.Second,
+		},
+		{
+			Method: http.MethodGet, Pattern: "/admin/ui", Handler: srv.adminUI,
+			OperationID: "getAdminUI", Tag: "admin", Summary: "Get the admin UI",
+			ResponseTypes: map[int]interface{}{http.StatusOK: MediaType("text/html")},
+			CacheControl:  cachePublic,
+		},
+		{
+			Method: http.MethodPost, Pattern: "/admin/users/{id}/token", Handler: srv.issueUserToken,
+			OperationID: "issueUserToken", Tag: "admin", Summary: "Issue a bearer token for a user",
+			ResponseTypes: map[int]interface{}{http.StatusOK: UserToken{}},
+			Middlewares:   admin,
+		},
+	}
+}
+
+// info describes d for GET /_routes.
+func (d RouteDef) info() RouteInfo {
+	info := RouteInfo{
+		Method:          d.Method,
+		Pattern:         d.Pattern,
+		OperationID:     d.OperationID,
+		Tag:             d.Tag,
+		Summary:         d.Summary,
+		ResponseTypes:   make(map[string]string, len(d.ResponseTypes)),
+		CacheControl:    d.CacheControl,
+		LatencyBudgetMS: float64(d.budget()) / float64(time.Millisecond),
+		Deprecated:      d.Deprecated,
+	}
+	if d.RequestType != nil {
+		info.RequestType = typeName(d.RequestType)
+	}
+	for status, body := range d.ResponseTypes {
+		info.ResponseTypes[strconv.Itoa(status)] = typeName(body)
+	}
+	return info
+}
+
+// listRoutes serves every route in the API and operations tables, sorted
+// by pattern and then method.
+func (srv *Server) listRoutes(w http.ResponseWriter, r *http.Request) {
+	var routes []RouteInfo
+	for _, d := range append(srv.apiRouteDefs(), srv.opsRouteDefs()...) {
+		routes = append(routes, d.info())
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Pattern != routes[j].Pattern {
+			return routes[i].Pattern < routes[j].Pattern
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	respondJSON(w, http.StatusOK, routes)
+}
+
+// rawJSON is the RequestType of a body that may be any JSON document.
+var rawJSON = json.RawMessage{}
+
+// GET /users/me, which predates GET /me, is deprecated in its favour.
+var (
+	usersMeDeprecated = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	usersMeSunset     = time.Date(2027, 6, 1, 0, 0, 0, 0, time.UTC)
+)
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== Route Table Tests ==========
+
+func TestListRoutes(t *testing.T) {
+	router := setupRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/_routes", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assertJSONContentType(t, w)
+
+	var routes []RouteInfo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &routes))
+	assert.Len(t, routes, len(server.apiRouteDefs())+len(server.opsRouteDefs()))
+	assert.Contains(t, routes, RouteInfo{
+		Method: http.MethodPut, Pattern: "/users/{id}", OperationID: "updateUser", Tag: "users", Summary: "Replace a user",
+		RequestType: "User", ResponseTypes: map[string]string{"200": "User", "409": "User"}, LatencyBudgetMS: 250,
+	})
+	assert.Contains(t, routes, RouteInfo{
+		Method: http.MethodPatch, Pattern: "/admin/flags", OperationID: "updateFlags", Tag: "admin", Summary: "Change some feature flags",
+		RequestType: "Flags", ResponseTypes: map[string]string{"200": "Flags"}, LatencyBudgetMS: 250,
+	})
+	assert.Contains(t, routes, RouteInfo{
+		Method: http.MethodDelete, Pattern: "/users/{id}", OperationID: "deleteUser", Tag: "users", Summary: "Delete a user",
+		ResponseTypes: map[string]string{"204": ""}, LatencyBudgetMS: 250,
+	})
+	assert.Equal(t, "/_routes", routes[0].Pattern, "sorted by pattern")
+	assert.Equal(t, map[string]string{"200": "[]RouteInfo"}, routes[0].ResponseTypes)
+}
+
+func TestMountRoutes_AppliesRouteMiddlewares(t *testing.T) {
+	router := setupRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tenants", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "requireAdmin guards the tenant routes")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "route middlewares do not leak onto other routes")
+}
+
+func TestRoutes_StaticSegmentsBeforeID(t *testing.T) {
+	router := setupRouter()
+	useAuthSecret(t)
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		auth   bool
+		want   int
+		name   string
+	}{
+		{http.MethodGet, "/users/new", "", false, http.StatusOK, ""},
+		{http.MethodGet, "/users/me", "", true, http.StatusOK, "Alice"},
+		{http.MethodGet, "/users/me", "", false, http.StatusUnauthorized, ""},
+		{http.MethodGet, "/users/2", "", false, http.StatusOK, "Bob"},
+		{http.MethodGet, "/users/count", "", false, http.StatusOK, ""},
+		{http.MethodGet, "/users/newer", "", false, http.StatusBadRequest, ""},
+		{http.MethodGet, "/users/NEW", "", false, http.StatusBadRequest, ""},
+		{http.MethodPut, "/users/me", `{"name":"Alicia","version":1}`, true, http.StatusBadRequest, ""},
+		{http.MethodDelete, "/users/new", "", false, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.auth {
+				req = newUserRequest(1, tt.method, tt.path, tt.body)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code, w.Body.String())
+			if tt.name != "" {
+				var u User
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &u))
+				assert.Equal(t, tt.name, u.Name)
+			}
+		})
+	}
+}
+
+func TestNewUser(t *testing.T) {
+	router := setupRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/new", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"id":0,"name":"","email":"","postCount":0,"verified":false,"version":0,"createdAt":"0001-01-01T00:00:00Z","updatedAt":"0001-01-01T00:00:00Z"}`, w.Body.String())
+}
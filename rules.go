@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+const (
+	// ruleTimeout bounds one run of a rule's script.
+	ruleTimeout = 100 * time.Millisecond
+	// maxRuleDelay caps the delay a rule may add to a request.
+	maxRuleDelay = 30 * time.Second
+)
+
+// Rule is a scripted override of the API's responses, registered with
+// POST /admin/rules for testing how clients cope with failures. It applies
+// to requests whose method and path match, until it expires or is deleted;
+// rules never apply to /admin.
+//
+// Script is Lua, run before the handler with a global request table
+// holding method, path, and query and headers tables of first values. It
+// returns nil to leave the request alone, or a table: status short-circuits
+// the request with that status, body (default a problem document) and
+// headers; without a status, headers are added to the handler's response.
+// delay_ms holds the request for that long first, at most 30s.
+type Rule struct {
+	ID int `json:"id"`
+	// Method is the HTTP method the rule matches; empty matches any.
+	Method string `json:"method,omitempty"`
+	// Path is a path.Match pattern for the request path, such as
+	// "/users/*".
+	Path   string `json:"path"`
+	Script string `json:"script"`
+	// TTL is how long the rule lasts, as a duration such as "1m"; empty
+	// lasts until it is deleted or the server restarts.
+	TTL string `json:"ttl,omitempty"`
+	// ExpiresAt is set from TTL; ignored on writes.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// compiledRule is a Rule with its script compiled.
+type compiledRule struct {
+	Rule
+	proto *lua.FunctionProto
+}
+
+// ruleResult is what a rule's script returned.
+type ruleResult struct {
+	Status  int
+	Body    string
+	Headers http.Header
+	Delay   time.Duration
+}
+
+// ruleSet holds the registered rules, ordered by ID.
+type ruleSet struct {
+	mu     sync.Mutex
+	nextID int
+	rules  []*compiledRule
+}
+
+func newRuleSet() *ruleSet {
+	return &ruleSet{nextID: 1}
+}
+
+// add compiles rule, stamps its expiry from now and registers it. It
+// returns the violations that make rule invalid.
+func (s *ruleSet) add(rule Rule, now time.Time) (Rule, []string) {
+	var violations []string
+	rule.Method = strings.ToUpper(rule.Method)
+	if rule.Path == "" || !strings.HasPrefix(rule.Path, "/") {
+		violations = append(violations, "request body /path: must be an absolute path pattern")
+	} else if _, err := path.Match(rule.Path, "/"); err != nil {
+		violations = append(violations, "request body /path: "+err.Error())
+	}
+	rule.ExpiresAt = nil
+	if rule.TTL != "" {
+		ttl, err := time.ParseDuration(rule.TTL)
+		if err != nil || ttl <= 0 {
+			violations = append(violations, "request body /ttl: must be a positive duration such as 1m")
+		} else {
+			expires := now.Add(ttl).UTC()
+			rule.ExpiresAt = &expires
+		}
+	}
+	proto, err := compileRuleScript(rule.Script)
+	if err != nil {
+		violations = append(violations, "request body /script: "+err.Error())
+	}
+	if len(violations) > 0 {
+		return Rule{}, violations
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rule.ID = s.nextID
+	s.nextID++
+	s.rules = append(s.rules, &compiledRule{Rule: rule, proto: proto})
+	return rule, nil
+}
+
+// list returns the rules in effect at now, dropping expired ones.
+func (s *ruleSet) list(now time.Time) []Rule {
+	out := []Rule{}
+	for _, c := range s.live(now) {
+		out = append(out, c.Rule)
+	}
+	return out
+}
+
+// live returns the rules in effect at now, dropping expired ones.
+func (s *ruleSet) live(now time.Time) []*compiledRule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.rules[:0]
+	for _, c := range s.rules {
+		if c.ExpiresAt == nil || now.Before(*c.ExpiresAt) {
+			kept = append(kept, c)
+		}
+	}
+	s.rules = kept
+	return append([]*compiledRule(nil), kept...)
+}
+
+// purge drops the rules expired at now, returning how many there were.
+func (s *ruleSet) purge(now time.Time) int {
+	s.mu.Lock()
+	n := len(s.rules)
+	s.mu.Unlock()
+	return n - len(s.live(now))
+}
+
+// remove deletes rule id, reporting whether it existed.
+func (s *ruleSet) remove(id int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := sort.Search(len(s.rules), func(i int) bool { return s.rules[i].ID >= id })
+	if i == len(s.rules) || s.rules[i].ID != id {
+		return false
+	}
+	s.rules = append(s.rules[:i], s.rules[i+1:]...)
+	return true
+}
+
+// matches reports whether c applies to r.
+func (c *compiledRule) matches(r *http.Request) bool {
+	if c.Method != "" && c.Method != r.Method {
+		return false
+	}
+	ok, _ := path.Match(c.Path, r.URL.Path)
+	return ok
+}
+
+// compileRuleScript parses and compiles a rule's Lua script.
+func compileRuleScript(src string) (*lua.FunctionProto, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, fmt.Errorf("must not be empty")
+	}
+	chunk, err := parse.Parse(strings.NewReader(src), "rule")
+	if err != nil {
+		return nil, err
+	}
+	return lua.Compile(chunk, "rule")
+}
+
+// run runs c's script for r in a fresh state holding only the base, table,
+// string and math libraries. A nil result leaves r alone.
+func (c *compiledRule) run(r *http.Request) (*ruleResult, error) {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	defer L.Close()
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	for _, name := range []string{"dofile", "loadfile", "load", "loadstring", "print"} {
+		L.SetGlobal(name, lua.LNil)
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), ruleTimeout)
+	defer cancel()
+	L.SetContext(ctx)
+	L.SetGlobal("request", ruleRequestTable(L, r))
+
+	L.Push(L.NewFunctionFromProto(c.proto))
+	if err := L.PCall(0, 1, nil); err != nil {
+		return nil, err
+	}
+	ret := L.Get(-1)
+	if ret == lua.LNil {
+		return nil, nil
+	}
+	tbl, ok := ret.(*lua.LTable)
+	if !ok {
+		return nil, fmt.Errorf("script returned a %s, want a table or nil", ret.Type())
+	}
+	return ruleResultFrom(tbl)
+}
+
+// ruleRequestTable is r as the request global of a rule's script.
+func ruleRequestTable(L *lua.LState, r *http.Request) *lua.LTable {
+	req := L.NewTable()
+	req.RawSetString("method", lua.LString(r.Method))
+	req.RawSetString("path", lua.LString(r.URL.Path))
+	query := L.NewTable()
+	for k, v := range r.URL.Query() {
+		query.RawSetString(k, lua.LString(v[0]))
+	}
+	req.RawSetString("query", query)
+	headers := L.NewTable()
+	for k, v := range r.Header {
+		headers.RawSetString(k, lua.LString(v[0]))
+	}
+	req.RawSetString("headers", headers)
+	return req
+}
+
+// ruleResultFrom reads the table a rule's script returned.
+func ruleResultFrom(tbl *lua.LTable) (*ruleResult, error) {
+	res := &ruleResult{Headers: http.Header{}}
+	if v := tbl.RawGetString("status"); v != lua.LNil {
+		n, ok := v.(lua.LNumber)
+		if !ok || n < 100 || n > 599 {
+			return nil, fmt.Errorf("status %s is not an HTTP status", v)
+		}
+		res.Status = int(n)
+	}
+	if v := tbl.RawGetString("body"); v != lua.LNil {
+		res.Body = v.String()
+	}
+	if v := tbl.RawGetString("delay_ms"); v != lua.LNil {
+		n, ok := v.(lua.LNumber)
+		if !ok || n < 0 {
+			return nil, fmt.Errorf("delay_ms %s is not a number of milliseconds", v)
+		}
+		res.Delay = min(time.Duration(n)*time.Millisecond, maxRuleDelay)
+	}
+	if v, ok := tbl.RawGetString("headers").(*lua.LTable); ok {
+		v.ForEach(func(k, v lua.LValue) {
+			res.Headers.Set(k.String(), v.String())
+		})
+	}
+	return res, nil
+}
+
+// applyRules runs the rules matching each request, in ID order, before the
+// handler. A script that fails is logged and skipped.
+func (srv *Server) applyRules(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/admin" || strings.HasPrefix(r.URL.Path, "/admin/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		for _, c := range srv.rules.live(srv.Clock.Now()) {
+			if !c.matches(r) {
+				continue
+			}
+			res, err := c.run(r)
+			if err != nil {
+				srv.logAt("warn", "rule %d: %v", c.ID, err)
+				continue
+			}
+			if res == nil {
+				continue
+			}
+			if res.Delay > 0 {
+				select {
+				case <-time.After(res.Delay):
+				case <-r.Context().Done():
+					return
+				}
+			}
+			for k, v := range res.Headers {
+				w.Header()[k] = v
+			}
+			if res.Status != 0 {
+				respondRule(w, r, c.ID, res)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// respondRule sends the response a rule short-circuited a request with.
+func respondRule(w http.ResponseWriter, r *http.Request, id int, res *ruleResult) {
+	if res.Body == "" {
+		respondProblem(w, r, res.Status, "overridden", Problem{
+			Detail: fmt.Sprintf("rule %d overrode this response", id),
+		})
+		return
+	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.WriteHeader(res.Status)
+	w.Write([]byte(res.Body))
+}
+
+func (srv *Server) listRules(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, srv.rules.list(srv.Clock.Now()))
+}
+
+// createRule registers a rule. Its script is compiled now, so syntax errors
+// are a 400.
+func (srv *Server) createRule(w http.ResponseWriter, r *http.Request) {
+	var rule Rule
+	if err := decodeJSON(r, &rule); err != nil {
+		respondDecodeError(w, r, err)
+		return
+	}
+	rule, violations := srv.rules.add(rule, srv.Clock.Now())
+	if len(violations) > 0 {
+		respondProblem(w, r, http.StatusBadRequest, "invalid request", Problem{Violations: violations})
+		return
+	}
+	srv.logAt("info", "rule %d: %s %s", rule.ID, rule.Method, rule.Path)
+	w.Header().Set("Location", "/admin/rules/"+strconv.Itoa(rule.ID))
+	respondJSON(w, http.StatusCreated, rule)
+}
+
+func (srv *Server) deleteRule(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	if !srv.rules.remove(id) {
+		respondNotFound(w, r, "rule", id)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
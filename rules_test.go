@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// addRule registers rule through router and returns it.
+func addRule(t *testing.T, router http.Handler, rule string) Rule {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/admin/rules", rule))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created Rule
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	return created
+}
+
+// ========== Rule Tests ==========
+
+func TestRules_ForceStatusUntilExpiry(t *testing.T) {
+	router := setupAdminRouter(t)
+	clock := freezeClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	addRule(t, router, `{"method":"get","path":"/users","script":"return {status = 500}","ttl":"1m"}`)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "rule 1 overrode this response")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "other paths are untouched")
+
+	clock.t = clock.t.Add(time.Minute)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodGet, "/admin/rules", ""))
+	assert.JSONEq(t, `[]`, w.Body.String())
+}
+
+func TestRules_Results(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		status int
+		header string
+		body   string
+	}{
+		{"nil passes through", `return nil`, http.StatusOK, "", ""},
+		{"headers only", `return {headers = {["X-Rule"] = "hit"}}`, http.StatusOK, "hit", ""},
+		{"custom body", `return {status = 503, body = '{"down":true}', headers = {["X-Rule"] = "hit"}}`, http.StatusServiceUnavailable, "hit", `{"down":true}`},
+		{"reads the request", `if request.query.fail == "1" and request.headers["X-Test"] == "yes" then return {status = 418} end`, http.StatusTeapot, "", ""},
+		{"runtime error passes through", `error("boom")`, http.StatusOK, "", ""},
+		{"bad status passes through", `return {status = 42}`, http.StatusOK, "", ""},
+		{"sandboxed", `return {status = (os == nil and io == nil and dofile == nil) and 418 or 500}`, http.StatusTeapot, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupAdminRouter(t)
+			body, err := json.Marshal(Rule{Path: "/users/*", Script: tt.script})
+			require.NoError(t, err)
+			addRule(t, router, string(body))
+
+			req := httptest.NewRequest(http.MethodGet, "/users/1?fail=1", nil)
+			req.Header.Set("X-Test", "yes")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, tt.header, w.Header().Get("X-Rule"))
+			if tt.body != "" {
+				assert.JSONEq(t, tt.body, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRules_NeverApplyToAdmin(t *testing.T) {
+	router := setupAdminRouter(t)
+	addRule(t, router, `{"path":"/admin/*","script":"return {status = 500}"}`)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodGet, "/admin/rules", ""))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRules_Invalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		violation string
+	}{
+		{"syntax error", `{"path":"/users","script":"return {"}`, "request body /script"},
+		{"empty script", `{"path":"/users","script":" "}`, "request body /script: must not be empty"},
+		{"relative path", `{"path":"users","script":"return nil"}`, "request body /path"},
+		{"bad pattern", `{"path":"/users/[","script":"return nil"}`, "request body /path"},
+		{"bad ttl", `{"path":"/users","script":"return nil","ttl":"soon"}`, "request body /ttl"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupAdminRouter(t)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/admin/rules", tt.body))
+
+			require.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tt.violation)
+		})
+	}
+}
+
+func TestRules_Delete(t *testing.T) {
+	router := setupAdminRouter(t)
+	rule := addRule(t, router, `{"path":"/users","script":"return {status = 500}"}`)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodDelete, "/admin/rules/1", ""))
+	require.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, 1, rule.ID)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodDelete, "/admin/rules/1", ""))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
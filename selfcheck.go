@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/go-chi/chi/v5"
+)
+
+// selfCheckTimeout bounds the store ping of the startup self-check.
+const selfCheckTimeout = 5 * time.Second
+
+// routerCheck is a router and the route table it must serve.
+type routerCheck struct {
+	name   string
+	router chi.Routes
+	defs   []RouteDef
+}
+
+// selfCheck verifies the server's wiring before it takes traffic: that
+// each router serves exactly its route table, that the OpenAPI document
+// spec describes exactly the routes of all of them, under the same
+// operationIds and tags, and that s answers. It returns a report of
+// every inconsistency found, or nil.
+func selfCheck(ctx context.Context, spec []byte, s Store, routers ...routerCheck) error {
+	var problems []string
+	var all []RouteDef
+	for _, rc := range routers {
+		problems = append(problems, checkRouter(rc)...)
+		all = append(all, rc.defs...)
+	}
+	problems = append(problems, checkSpec(ctx, spec, all)...)
+
+	ctx, cancel := context.WithTimeout(ctx, selfCheckTimeout)
+	defer cancel()
+	if err := s.Ping(ctx); err != nil {
+		problems = append(problems, fmt.Sprintf("store (%s): %v", s.Info().Backend, err))
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("self-check failed:\n  - %s", strings.Join(problems, "\n  - "))
+}
+
+// checkRouter compares what rc.router routes with rc.defs.
+func checkRouter(rc routerCheck) []string {
+	var problems []string
+	declared := map[string]bool{}
+	for _, d := range rc.defs {
+		key := d.Method + " " + d.Pattern
+		if declared[key] {
+			problems = append(problems, fmt.Sprintf("%s router: %s is declared twice", rc.name, key))
+		}
+		declared[key] = true
+		if d.Handler == nil {
+			problems = append(problems, fmt.Sprintf("%s router: %s has no handler", rc.name, key))
+		}
+	}
+
+	routed := map[string]bool{}
+	err := chi.Walk(rc.router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		// The profiler is mounted for operators, outside the route table.
+		if strings.HasPrefix(route, "/debug/") {
+			return nil
+		}
+		// A subrouter's root walks as its mount path with a trailing
+		// slash: /posts/ for /posts.
+		if len(route) > 1 {
+			route = strings.TrimSuffix(route, "/")
+		}
+		key := method + " " + route
+		routed[key] = true
+		if !declared[key] {
+			problems = append(problems, fmt.Sprintf("%s router: %s is routed but not in the route table", rc.name, key))
+		}
+		return nil
+	})
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("%s router: %v", rc.name, err))
+	}
+	for _, d := range rc.defs {
+		if key := d.Method + " " + d.Pattern; !routed[key] {
+			problems = append(problems, fmt.Sprintf("%s router: %s is in the route table but not routed", rc.name, key))
+		}
+	}
+	return problems
+}
+
+// checkSpec compares the operations spec documents with defs.
+func checkSpec(ctx context.Context, spec []byte, defs []RouteDef) []string {
+	doc, err := openapi3.NewLoader().LoadFromData(spec)
+	if err != nil {
+		return []string{fmt.Sprintf("spec: %v", err)}
+	}
+	if err := doc.Validate(ctx); err != nil {
+		return []string{fmt.Sprintf("spec: %v", err)}
+	}
+
+	var problems []string
+	operationIDs := map[string]string{}
+	for _, d := range defs {
+		key := d.Method + " " + d.Pattern
+		if other, ok := operationIDs[d.OperationID]; ok {
+			problems = append(problems, fmt.Sprintf("spec: %s and %s share operationId %q", other, key, d.OperationID))
+		}
+		operationIDs[d.OperationID] = key
+
+		item := doc.Paths.Value(d.Pattern)
+		if item == nil || item.GetOperation(d.Method) == nil {
+			problems = append(problems, fmt.Sprintf("spec: %s is routed but not documented", key))
+			continue
+		}
+		op := item.GetOperation(d.Method)
+		if op.OperationID != d.OperationID {
+			problems = append(problems, fmt.Sprintf("spec: %s is documented as operationId %q, not %q", key, op.OperationID, d.OperationID))
+		}
+		if !slices.Equal(op.Tags, []string{d.Tag}) {
+			problems = append(problems, fmt.Sprintf("spec: %s is documented with tags %v, not [%s]", key, op.Tags, d.Tag))
+		}
+	}
+
+	routed := map[string]bool{}
+	for _, d := range defs {
+		routed[d.Method+" "+d.Pattern] = true
+	}
+	paths := doc.Paths.InMatchingOrder()
+	slices.Sort(paths)
+	for _, path := range paths {
+		ops := doc.Paths.Value(path).Operations()
+		methods := make([]string, 0, len(ops))
+		for method := range ops {
+			methods = append(methods, method)
+		}
+		slices.Sort(methods)
+		for _, method := range methods {
+			if key := method + " " + path; !routed[key] {
+				problems = append(problems, fmt.Sprintf("spec: %s is documented but not routed", key))
+			}
+		}
+	}
+	return problems
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pingFailStore is a Store whose backend does not answer.
+type pingFailStore struct {
+	*memoryStore
+}
+
+func (pingFailStore) Ping(ctx context.Context) error { return errors.New("connection refused") }
+
+// ========== Self-Check Tests ==========
+
+func TestSelfCheck_Passes(t *testing.T) {
+	router := setupRouter()
+
+	err := selfCheck(context.Background(), openAPISpec, server.Store,
+		routerCheck{"api", router, append(server.apiRouteDefs(), server.opsRouteDefs()...)})
+	assert.NoError(t, err)
+}
+
+func TestSelfCheck_SplitListeners(t *testing.T) {
+	setupRouter()
+
+	err := selfCheck(context.Background(), openAPISpec, server.Store,
+		routerCheck{"api", server.newAPIRouter(), server.apiRouteDefs()},
+		routerCheck{"ops", server.newOpsRouter(), server.opsRouteDefs()})
+	assert.NoError(t, err)
+}
+
+func TestSelfCheck_ReportsEveryProblem(t *testing.T) {
+	setupRouter()
+	defs := []RouteDef{
+		{Method: http.MethodGet, Pattern: "/health", Handler: server.healthHandler, OperationID: "getHealth", Tag: "health"},
+		{Method: http.MethodGet, Pattern: "/health/ready", Handler: server.readyHandler, OperationID: "getHealth", Tag: "ops"},
+		{Method: http.MethodGet, Pattern: "/unrouted", Handler: server.healthHandler, OperationID: "getUnrouted", Tag: "health"},
+	}
+	router := chi.NewRouter()
+	server.mountRoutes(router, defs[:2])
+	router.Get("/extra", server.healthHandler)
+
+	err := selfCheck(context.Background(), openAPISpec, pingFailStore{newMemoryStore(server.events)},
+		routerCheck{"ops", router, defs})
+	require.Error(t, err)
+	report := err.Error()
+	for _, want := range []string{
+		"self-check failed:\n  - ",
+		"ops router: GET /extra is routed but not in the route table",
+		"ops router: GET /unrouted is in the route table but not routed",
+		`spec: GET /health and GET /health/ready share operationId "getHealth"`,
+		`spec: GET /health/ready is documented as operationId "getReadiness", not "getHealth"`,
+		"spec: GET /health/ready is documented with tags [health], not [ops]",
+		"spec: GET /unrouted is routed but not documented",
+		"spec: GET /users is documented but not routed",
+		"store (memory): connection refused",
+	} {
+		assert.Contains(t, report, want)
+	}
+}
+
+func TestSelfCheck_InvalidSpec(t *testing.T) {
+	setupRouter()
+
+	err := selfCheck(context.Background(), []byte("openapi: [nope"), server.Store)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "spec: ")
+}
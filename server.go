@@ -0,0 +1,136 @@
+package main
+
+import (
+	"log"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/redis/go-redis/v9"
+)
+
+// Server is the state the handlers share. main builds one from the loaded
+// Config; tests build their own, and may swap any field for a fake.
+type Server struct {
+	Store  Store
+	Logger *log.Logger
+	Config Config
+	Clock  Clock
+	Mailer MailSender
+	// Redis, when set, holds the state instances behind a load balancer
+	// must agree on: rate-limit windows and bans, the store breaker's read
+	// cache and the leader lease. When nil, all are kept in process. The
+	// redis subsystem sets it from REDIS_URL.
+	Redis *redis.Client
+
+	live           *liveConfig
+	naming         func(string) string
+	trustedProxies []netip.Prefix
+	adminIPs       adminIPRules
+	bans           *banList
+	plugins        []Plugin
+	rules          *ruleSet
+	health         *healthHistory
+	leader         *leaderElection
+	jobs           *scheduler
+	outbound       *outboundClient
+
+	// events carries the store's entity events to their subscribers.
+	events *EventBus
+	// startTime is when the server was built, by its clock, and the
+	// counters tally what it has served since.
+	startTime    time.Time
+	requestStats *requestCounters
+	budgetStats  *budgetCounters
+	abuseStats   *abuseCounters
+	shadowStats  *shadowCounters
+
+	// spec is the OpenAPI document served, with the naming's field names,
+	// specDoc parses it and specVersions splits it by API version.
+	spec         []byte
+	specDoc      func() (*openapi3.T, error)
+	specVersions func() (map[string]*openapi3.T, error)
+}
+
+// newServer returns a Server over s configured by cfg, logging through the
+// standard logger and reading the wall clock, with the built-in plugins
+// registered and the built-in jobs scheduled. It leads the background jobs
+// once its leader election runs, against an in-process lease until told of
+// a shared one. s should publish to the server's events; when s is nil,
+// the caller sets Store once it has built one that does.
+func newServer(cfg Config, s Store) *Server {
+	srv := &Server{Store: s, Logger: log.Default(), Config: cfg, Clock: systemClock{}, live: newLiveConfig(cfg.ConfigFile), rules: newRuleSet(), health: newHealthHistory(healthHistorySize)}
+	srv.events = NewEventBus()
+	srv.startTime = srv.Clock.Now()
+	srv.requestStats = newRequestCounters()
+	srv.budgetStats = newBudgetCounters()
+	srv.abuseStats = newAbuseCounters()
+	srv.shadowStats = &shadowCounters{}
+	srv.Mailer = logMailSender{log: srv.logAt}
+	srv.useClock(s)
+	// loadConfig has checked these.
+	naming, _ := parseFieldNaming(cfg.JSONNaming)
+	srv.setFieldNaming(naming)
+	for _, r := range cfg.TrustedProxies {
+		p, _ := parseIPRange(r)
+		srv.trustedProxies = append(srv.trustedProxies, p)
+	}
+	adminIPs, _ := parseIPRules(cfg.AdminIPs)
+	srv.setAdminIPRules(adminIPs)
+	srv.bans = newBanList("client")
+	srv.bans.shared, srv.bans.log = srv.redisClient, srv.logAt
+
+	now := serverClock{srv}.Now
+	srv.leader = newLeaderElection(cfg.Leader, newMemoryLease(now), now)
+	srv.leader.log = srv.logAt
+	srv.jobs = newScheduler(now)
+	srv.jobs.log = srv.logAt
+	srv.outbound = newOutboundClient(cfg.Outbound)
+	srv.outbound.log = srv.logAt
+	for _, job := range srv.builtinJobs() {
+		if err := srv.jobs.add(job); err != nil {
+			panic(err)
+		}
+	}
+	srv.Register(srv.requestStatsPlugin(), srv.webhooksPlugin())
+	return srv
+}
+
+// logAt logs a message through the server's logger if level is enabled.
+func (srv *Server) logAt(level, format string, args ...interface{}) {
+	if srv.logEnabled(level) {
+		srv.Logger.Printf(strings.ToUpper(level)+" "+format, args...)
+	}
+}
+
+// logEnabled reports whether messages at level are logged under the live
+// log level.
+func (srv *Server) logEnabled(level string) bool {
+	return logLevels[level] >= logLevels[srv.live.current().LogLevel]
+}
+
+// redisClient returns srv.Redis. Rate limiters and ban lists, built with
+// the routers before Redis is connected, call it on every use.
+func (srv *Server) redisClient() *redis.Client {
+	return srv.Redis
+}
+
+// logFunc logs a message at a level, as Server.logAt does, for the parts
+// of the server that are not handlers. A nil logFunc logs nothing.
+type logFunc func(level, format string, args ...interface{})
+
+func (f logFunc) at(level, format string, args ...interface{}) {
+	if f != nil {
+		f(level, format, args...)
+	}
+}
+
+// newMemoryServer returns newServer for cfg over a memory store of the seed
+// data, publishing to the server's events.
+func newMemoryServer(cfg Config) *Server {
+	srv := newServer(cfg, nil)
+	srv.Store = newMemoryStore(srv.events)
+	srv.useClock(srv.Store)
+	return srv
+}
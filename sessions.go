@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Session is a user token issued by POST /auth/login or
+// POST /admin/users/{id}/token. Tokens name their session, and revoking
+// the session puts it on the revocation list authenticate checks, which
+// stops the token working.
+type Session struct {
+	ID int `json:"id"`
+	// UserID is whose session it is; GET /me/sessions only lists the
+	// caller's own.
+	UserID int `json:"-"`
+	// UserAgent is the User-Agent of the request the token was issued to.
+	UserAgent string    `json:"userAgent"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Current is set on the session of the request listing the sessions.
+	// It is not stored.
+	Current bool `json:"current"`
+}
+
+// listMySessions lists the caller's sessions that are not revoked.
+func (srv *Server) listMySessions(w http.ResponseWriter, r *http.Request) {
+	p, _ := principalFrom(r.Context())
+	sessions := srv.requestStore(r).ListSessions(p.UserID)
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == p.SessionID
+	}
+	respondJSON(w, http.StatusOK, sessions)
+}
+
+// revokeMySession revokes one of the caller's sessions, which may be the
+// one the request is made in.
+func (srv *Server) revokeMySession(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	p, _ := principalFrom(r.Context())
+	switch err := srv.requestStore(r).RevokeSession(p.UserID, id); {
+	case errors.Is(err, errNotFound):
+		respondNotFound(w, r, "session", id)
+		return
+	case err != nil:
+		respondError(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
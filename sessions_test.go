@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loginTestSession issues a token for userID through the admin endpoint,
+// as from a client identified by userAgent.
+func loginTestSession(t *testing.T, router http.Handler, userID int, userAgent string) string {
+	t.Helper()
+	req := newAdminRequest(http.MethodPost, "/admin/users/"+strconv.Itoa(userID)+"/token", "")
+	req.Header.Set("User-Agent", userAgent)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var token UserToken
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &token))
+	return token.Token
+}
+
+// newTokenRequest is a request bearing token.
+func newTokenRequest(token, method, path string) *http.Request {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+// ========== Session Endpoint Tests ==========
+
+func TestListMySessions(t *testing.T) {
+	router := setupAdminRouter(t)
+	useAuthSecret(t)
+	phone := loginTestSession(t, router, 1, "phone")
+	laptop := loginTestSession(t, router, 1, "laptop")
+	loginTestSession(t, router, 2, "bob's phone")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newTokenRequest(laptop, http.MethodGet, "/me/sessions"))
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var sessions []Session
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sessions))
+	require.Len(t, sessions, 2, "only the caller's sessions")
+	p, _ := server.parseUserToken(phone)
+	assert.Equal(t, p.SessionID, sessions[0].ID)
+	assert.Equal(t, "phone", sessions[0].UserAgent)
+	assert.False(t, sessions[0].Current)
+	assert.Equal(t, "laptop", sessions[1].UserAgent)
+	assert.True(t, sessions[1].Current)
+	assert.False(t, sessions[1].CreatedAt.IsZero())
+}
+
+func TestRevokeMySession(t *testing.T) {
+	router := setupAdminRouter(t)
+	useAuthSecret(t)
+	phone := loginTestSession(t, router, 1, "phone")
+	laptop := loginTestSession(t, router, 1, "laptop")
+	p, _ := server.parseUserToken(phone)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newTokenRequest(laptop, http.MethodDelete, "/me/sessions/"+strconv.Itoa(p.SessionID)))
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newTokenRequest(phone, http.MethodGet, "/me"))
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "revoked tokens stop working")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newTokenRequest(laptop, http.MethodGet, "/me/sessions"))
+	var sessions []Session
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sessions))
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "laptop", sessions[0].UserAgent)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newTokenRequest(laptop, http.MethodDelete, "/me/sessions/"+strconv.Itoa(p.SessionID)))
+	assert.Equal(t, http.StatusNotFound, w.Code, "already revoked")
+}
+
+func TestRevokeMySession_Current(t *testing.T) {
+	router := setupAdminRouter(t)
+	useAuthSecret(t)
+	token := loginTestSession(t, router, 1, "phone")
+	p, _ := server.parseUserToken(token)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newTokenRequest(token, http.MethodDelete, "/me/sessions/"+strconv.Itoa(p.SessionID)))
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newTokenRequest(token, http.MethodGet, "/me/sessions"))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestRevokeMySession_OtherUsers(t *testing.T) {
+	router := setupAdminRouter(t)
+	useAuthSecret(t)
+	bob := loginTestSession(t, router, 2, "bob's phone")
+	p, _ := server.parseUserToken(bob)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newUserRequest(1, http.MethodDelete, "/me/sessions/"+strconv.Itoa(p.SessionID), ""))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newTokenRequest(bob, http.MethodGet, "/me"))
+	assert.Equal(t, http.StatusOK, w.Code, "Bob's session is untouched")
+}
+
+func TestSessions_RequireUser(t *testing.T) {
+	router := setupAdminRouter(t)
+	useAuthSecret(t)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/me/sessions", nil),
+		httptest.NewRequest(http.MethodDelete, "/me/sessions/1", nil),
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "%s %s", req.Method, req.URL)
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// maxShadowsInFlight bounds the mirrored requests awaiting the upstream;
+// requests beyond it are not mirrored, so a slow upstream cannot pile up
+// goroutines.
+const maxShadowsInFlight = 64
+
+// shadowRecent is how many comparisons GET /admin/shadow lists.
+const shadowRecent = 20
+
+// ShadowReport is GET /admin/shadow's comparison of the shadow upstream's
+// responses with this server's.
+type ShadowReport struct {
+	// Upstream is the base URL requests are mirrored to, and Rate the
+	// share of requests mirrored.
+	Upstream string  `json:"upstream"`
+	Rate     float64 `json:"rate"`
+	// Mirrored counts the requests sent upstream, Failed those it did not
+	// answer, and Dropped those not sent because too many were in flight.
+	Mirrored int64 `json:"mirrored"`
+	Failed   int64 `json:"failed"`
+	Dropped  int64 `json:"dropped"`
+	// StatusMismatches counts the answers whose status differed from ours.
+	StatusMismatches int64 `json:"statusMismatches"`
+	// MeanLatencyDeltaMS is the upstream's mean latency less ours, in
+	// milliseconds, over the requests it answered.
+	MeanLatencyDeltaMS float64 `json:"meanLatencyDeltaMs"`
+	// Recent lists the latest comparisons, newest first.
+	Recent []ShadowComparison `json:"recent"`
+}
+
+// ShadowComparison is one mirrored request: how this server and the
+// upstream answered it.
+type ShadowComparison struct {
+	Method string `json:"method"`
+	// Path is the request's path and query.
+	Path      string  `json:"path"`
+	Status    int     `json:"status"`
+	LatencyMS float64 `json:"latencyMs"`
+	// ShadowStatus is 0, and Error says why, if the upstream did not
+	// answer.
+	ShadowStatus    int     `json:"shadowStatus"`
+	ShadowLatencyMS float64 `json:"shadowLatencyMs"`
+	Error           string  `json:"error,omitempty"`
+}
+
+// shadowCounters accumulate the comparisons behind a ShadowReport.
+type shadowCounters struct {
+	mu                        sync.Mutex
+	mirrored, failed, dropped int64
+	mismatches, answered      int64
+	latencyDelta              time.Duration
+	recent                    []ShadowComparison
+}
+
+func (c *shadowCounters) record(cmp ShadowComparison, latency, shadowLatency time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mirrored++
+	if cmp.ShadowStatus == 0 {
+		c.failed++
+	} else {
+		c.answered++
+		c.latencyDelta += shadowLatency - latency
+		if cmp.ShadowStatus != cmp.Status {
+			c.mismatches++
+		}
+	}
+	c.recent = append(c.recent, cmp)
+	if len(c.recent) > shadowRecent {
+		c.recent = c.recent[1:]
+	}
+}
+
+func (c *shadowCounters) drop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dropped++
+}
+
+// report returns the counts for shadowing configured by cfg.
+func (c *shadowCounters) report(cfg ShadowConfig) ShadowReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rep := ShadowReport{
+		Upstream:         cfg.URL,
+		Rate:             cfg.Rate,
+		Mirrored:         c.mirrored,
+		Failed:           c.failed,
+		Dropped:          c.dropped,
+		StatusMismatches: c.mismatches,
+		Recent:           make([]ShadowComparison, 0, len(c.recent)),
+	}
+	if c.answered > 0 {
+		rep.MeanLatencyDeltaMS = float64(c.latencyDelta) / float64(c.answered) / float64(time.Millisecond)
+	}
+	for i := len(c.recent) - 1; i >= 0; i-- {
+		rep.Recent = append(rep.Recent, c.recent[i])
+	}
+	return rep
+}
+
+// shadower mirrors a share of requests, bodies included, to another
+// implementation of the API once this server has answered them, and
+// records in its counters how the two answers compare. The client only
+// ever sees this server's answer.
+type shadower struct {
+	cfg      ShadowConfig
+	client   *http.Client
+	counters *shadowCounters
+	inFlight chan struct{}
+	log      logFunc
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// newShadower returns the shadowing middleware. seed makes the choice of
+// requests reproducible.
+func (srv *Server) newShadower(cfg ShadowConfig, counters *shadowCounters, seed int64) func(http.Handler) http.Handler {
+	s := &shadower{
+		cfg:      cfg,
+		client:   &http.Client{Timeout: cfg.Timeout},
+		counters: counters,
+		inFlight: make(chan struct{}, maxShadowsInFlight),
+		log:      srv.logAt,
+		rng:      rand.New(rand.NewSource(seed)),
+	}
+	return s.middleware
+}
+
+// roll reports whether to mirror a request.
+func (s *shadower) roll() bool {
+	if s.cfg.Rate <= 0 {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rng.Float64() < s.cfg.Rate
+}
+
+func (s *shadower) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.roll() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, "unreadable request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		mirror := ShadowComparison{Method: r.Method, Path: r.URL.RequestURI()}
+		header := r.Header.Clone()
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+		next.ServeHTTP(ww, r)
+		latency := time.Since(start)
+		if mirror.Status = ww.Status(); mirror.Status == 0 {
+			mirror.Status = http.StatusOK
+		}
+		mirror.LatencyMS = float64(latency) / float64(time.Millisecond)
+
+		select {
+		case s.inFlight <- struct{}{}:
+			go func() {
+				defer func() { <-s.inFlight }()
+				s.send(mirror, header, body, latency)
+			}()
+		default:
+			s.counters.drop()
+		}
+	})
+}
+
+// send mirrors a request to the upstream and records the comparison with
+// this server's answer, which took latency.
+func (s *shadower) send(cmp ShadowComparison, header http.Header, body []byte, latency time.Duration) {
+	req, err := http.NewRequestWithContext(context.Background(), cmp.Method, s.cfg.URL+cmp.Path, bytes.NewReader(body))
+	if err != nil {
+		cmp.Error = err.Error()
+		s.counters.record(cmp, latency, 0)
+		return
+	}
+	req.Header = header
+	start := time.Now()
+	resp, err := s.client.Do(req)
+	shadowLatency := time.Since(start)
+	cmp.ShadowLatencyMS = float64(shadowLatency) / float64(time.Millisecond)
+	if err != nil {
+		s.log.at("warn", "shadow: %s %s: %v", cmp.Method, cmp.Path, err)
+		cmp.Error = err.Error()
+		s.counters.record(cmp, latency, shadowLatency)
+		return
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	cmp.ShadowStatus = resp.StatusCode
+	if cmp.ShadowStatus != cmp.Status {
+		s.log.at("debug", "shadow: %s %s: %d here, %d upstream", cmp.Method, cmp.Path, cmp.Status, cmp.ShadowStatus)
+	}
+	s.counters.record(cmp, latency, shadowLatency)
+}
+
+// getShadowReport serves the shadowing comparison. Without SHADOW_URL
+// nothing is mirrored, and the report is 404.
+func (srv *Server) getShadowReport(w http.ResponseWriter, r *http.Request) {
+	if srv.Config.Shadow.URL == "" {
+		respondProblem(w, r, http.StatusNotFound, "not found", Problem{Detail: "traffic shadowing is off; set SHADOW_URL to turn it on"})
+		return
+	}
+	respondJSON(w, http.StatusOK, srv.shadowStats.report(srv.Config.Shadow))
+}
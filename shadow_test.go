@@ -0,0 +1,130 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== Traffic Shadowing Tests ==========
+
+// shadowUpstream is a stand-in for another implementation of the API,
+// answering every request with status and remembering what it was sent.
+type shadowUpstream struct {
+	mu       sync.Mutex
+	requests []string
+	bodies   []string
+	headers  []http.Header
+}
+
+func newShadowUpstream(t *testing.T, status int) (*shadowUpstream, string) {
+	u := &shadowUpstream{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		u.mu.Lock()
+		u.requests = append(u.requests, r.Method+" "+r.URL.RequestURI())
+		u.bodies = append(u.bodies, string(body))
+		u.headers = append(u.headers, r.Header)
+		u.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(ts.Close)
+	return u, ts.URL
+}
+
+func (u *shadowUpstream) count() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return len(u.requests)
+}
+
+func TestShadow_MirrorsRequests(t *testing.T) {
+	upstream, url := newShadowUpstream(t, http.StatusNotFound)
+	counters := &shadowCounters{}
+	cfg := ShadowConfig{URL: url, Rate: 1, Timeout: time.Second}
+	handler := server.newShadower(cfg, counters, 1)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, `{"name":"Carol"}`, string(body), "the handler reads the body too")
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/users?notify=1", strings.NewReader(`{"name":"Carol"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code, "the client gets this server's answer")
+
+	require.Eventually(t, func() bool { return counters.report(cfg).Mirrored == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"POST /users?notify=1"}, upstream.requests)
+	assert.Equal(t, []string{`{"name":"Carol"}`}, upstream.bodies)
+	assert.Equal(t, "application/json", upstream.headers[0].Get("Content-Type"))
+
+	report := counters.report(cfg)
+	assert.Equal(t, url, report.Upstream)
+	assert.Equal(t, int64(1), report.StatusMismatches)
+	assert.Zero(t, report.Failed)
+	require.Len(t, report.Recent, 1)
+	assert.Equal(t, "/users?notify=1", report.Recent[0].Path)
+	assert.Equal(t, http.StatusCreated, report.Recent[0].Status)
+	assert.Equal(t, http.StatusNotFound, report.Recent[0].ShadowStatus)
+}
+
+func TestShadow_Rate(t *testing.T) {
+	upstream, url := newShadowUpstream(t, http.StatusOK)
+	counters := &shadowCounters{}
+	handler := server.newShadower(ShadowConfig{URL: url, Rate: 0, Timeout: time.Second}, counters, 1)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i := 0; i < 10; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users", nil))
+	}
+	time.Sleep(20 * time.Millisecond)
+	assert.Zero(t, upstream.count())
+	assert.Zero(t, counters.report(ShadowConfig{}).Mirrored)
+}
+
+func TestShadow_UpstreamDown(t *testing.T) {
+	ts := httptest.NewServer(nil)
+	ts.Close()
+	counters := &shadowCounters{}
+	cfg := ShadowConfig{URL: ts.URL, Rate: 1, Timeout: time.Second}
+	handler := server.newShadower(cfg, counters, 1)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+
+	require.Eventually(t, func() bool { return counters.report(cfg).Mirrored == 1 }, time.Second, 5*time.Millisecond)
+	report := counters.report(cfg)
+	assert.Equal(t, int64(1), report.Failed)
+	assert.Zero(t, report.StatusMismatches, "an unanswered request is not a mismatch")
+	assert.Zero(t, report.Recent[0].ShadowStatus)
+	assert.NotEmpty(t, report.Recent[0].Error)
+}
+
+func TestShadowCounters_Report(t *testing.T) {
+	c := &shadowCounters{}
+	for i := 0; i < shadowRecent+5; i++ {
+		c.record(ShadowComparison{Path: "/users/" + string(rune('a'+i)), Status: 200, ShadowStatus: 200}, 10*time.Millisecond, 30*time.Millisecond)
+	}
+	c.drop()
+
+	report := c.report(ShadowConfig{Rate: 0.5})
+	assert.Equal(t, int64(shadowRecent+5), report.Mirrored)
+	assert.Equal(t, int64(1), report.Dropped)
+	assert.Equal(t, 20.0, report.MeanLatencyDeltaMS)
+	require.Len(t, report.Recent, shadowRecent)
+	assert.Equal(t, "/users/"+string(rune('a'+shadowRecent+4)), report.Recent[0].Path, "newest first")
+}
+
+func TestGetShadowReport_Off(t *testing.T) {
+	router := setupAdminRouter(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodGet, "/admin/shadow", ""))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "SHADOW_URL")
+}
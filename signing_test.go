@@ -0,0 +1,143 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testSigningKey = SigningKey{ID: "k1", Secret: []byte("s3cret")}
+
+// ========== Signature Helper Tests ==========
+
+func TestSign_KnownValue(t *testing.T) {
+	// echo -n '{"status":"ok"}' | openssl dgst -sha256 -hmac s3cret
+	assert.Equal(t,
+		"sha256=689eb2043596f6f560b55122ea872885a2c859345841580439368ad2769f92fc",
+		sign([]byte("s3cret"), []byte(`{"status":"ok"}`)))
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	valid := sign(testSigningKey.Secret, body)
+
+	tests := []struct {
+		name      string
+		body      []byte
+		signature string
+		expected  error
+	}{
+		{"valid", body, valid, nil},
+		{"tampered body", []byte(`{"id":2}`), valid, errSignatureInvalid},
+		{"wrong secret", body, sign([]byte("other"), body), errSignatureInvalid},
+		{"missing", body, "", errSignatureMissing},
+		{"no prefix", body, strings.TrimPrefix(valid, "sha256="), errSignatureInvalid},
+		{"not hex", body, "sha256=zz", errSignatureInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifySignature(testSigningKey.Secret, tt.body, tt.signature)
+			if tt.expected == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.expected)
+			}
+		})
+	}
+}
+
+// ========== Response Signing Middleware Tests ==========
+
+func TestSignResponses_SignsEveryResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		expected int
+	}{
+		{"ok", http.MethodGet, "/users/1", "", http.StatusOK},
+		{"created", http.MethodPost, "/users", `{"name":"Carol"}`, http.StatusCreated},
+		{"no content", http.MethodDelete, "/users/2", "", http.StatusNoContent},
+		{"not found", http.MethodGet, "/users/99", "", http.StatusNotFound},
+		{"binary", http.MethodGet, "/photos/1/thumbnail", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupRouter()
+			srv := httptest.NewServer(server.newRouter(signResponses(testSigningKey)))
+			defer srv.Close()
+
+			req, err := http.NewRequest(tt.method, srv.URL+tt.path, strings.NewReader(tt.body))
+			require.NoError(t, err)
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.expected, resp.StatusCode)
+			assert.Equal(t, "k1", resp.Header.Get(headerSignatureKey))
+			require.NoError(t, verifyResponse(resp, []SigningKey{testSigningKey}))
+
+			// The body is still readable after verification.
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, sign(testSigningKey.Secret, body), resp.Header.Get(headerSignature))
+		})
+	}
+}
+
+func TestSignResponses_PreservesHeaders(t *testing.T) {
+	setupRouter()
+	router := server.newRouter(signResponses(testSigningKey))
+
+	req := httptest.NewRequest(http.MethodPost, "/posts", strings.NewReader(`{"userId":1,"title":"Signed"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/posts/3", w.Header().Get("Location"))
+	assertJSONContentType(t, w)
+	assert.Equal(t, sign(testSigningKey.Secret, w.Body.Bytes()), w.Header().Get(headerSignature))
+}
+
+func TestVerifyResponse_KeyRotation(t *testing.T) {
+	oldKey := SigningKey{ID: "old", Secret: []byte("old-secret")}
+	newKey := SigningKey{ID: "new", Secret: []byte("new-secret")}
+	setupRouter()
+	srv := httptest.NewServer(server.newRouter(signResponses(oldKey)))
+	defer srv.Close()
+
+	get := func() *http.Response {
+		resp, err := http.Get(srv.URL + "/health")
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	assert.NoError(t, verifyResponse(get(), []SigningKey{newKey, oldKey}))
+	assert.ErrorIs(t, verifyResponse(get(), []SigningKey{newKey}), errSignatureUnknown)
+	assert.ErrorIs(t, verifyResponse(get(), []SigningKey{{ID: "old", Secret: []byte("guess")}}), errSignatureInvalid)
+}
+
+func TestVerifyResponse_Unsigned(t *testing.T) {
+	setupRouter()
+	srv := httptest.NewServer(server.newRouter())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/health")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.ErrorIs(t, verifyResponse(resp, []SigningKey{testSigningKey}), errSignatureMissing)
+}
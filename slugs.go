@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxSlugLength bounds derived slugs, before any numeric suffix.
+const maxSlugLength = 80
+
+// slugPattern is the form of a post slug: runs of lowercase ASCII letters
+// and digits joined by single hyphens.
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// slugify derives a slug from title: it is lowercased, and each run of
+// anything but ASCII letters and digits becomes a hyphen. A title with
+// neither gives "post".
+func slugify(title string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, c := range strings.ToLower(title) {
+		if c >= 'a' && c <= 'z' || c >= '0' && c <= '9' {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			hyphen = false
+			b.WriteRune(c)
+			if b.Len() >= maxSlugLength {
+				break
+			}
+			continue
+		}
+		hyphen = true
+	}
+	if b.Len() == 0 {
+		return "post"
+	}
+	return b.String()
+}
+
+// uniqueSlug returns base, or the first of base-2, base-3, ... that is not
+// taken.
+func uniqueSlug(base string, taken func(slug string) bool) string {
+	slug := base
+	for n := 2; taken(slug); n++ {
+		slug = base + "-" + strconv.Itoa(n)
+	}
+	return slug
+}
+
+// withSlugs returns posts with a derived, unique slug given to each that
+// has none, for restoring a State from before posts had slugs.
+func withSlugs(posts []Post) []Post {
+	out := make([]Post, len(posts))
+	copy(out, posts)
+	taken := map[string]bool{}
+	for _, p := range out {
+		taken[p.Slug] = true
+	}
+	for i, p := range out {
+		if p.Slug == "" {
+			out[i].Slug = uniqueSlug(slugify(p.Title), func(slug string) bool { return taken[slug] })
+			taken[out[i].Slug] = true
+		}
+	}
+	return out
+}
+
+// validatePostSlug returns what is wrong with a post's slug, which may be
+// omitted to have one derived from the title.
+func validatePostSlug(p Post) []string {
+	if p.Slug == "" || slugPattern.MatchString(p.Slug) {
+		return nil
+	}
+	return []string{"request body /slug: must be lowercase letters and digits joined by single hyphens"}
+}
+
+// duplicatePostDetail explains why p could not be created alongside
+// existing: the same author and title, or the same slug.
+func duplicatePostDetail(p, existing Post) string {
+	if existing.UserID == p.UserID && existing.Title == p.Title {
+		return "this user already has a post with this title"
+	}
+	return "a post with this slug already exists"
+}
+
+func (srv *Server) getPostBySlug(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "slug")
+	post, err := srv.requestStore(r).GetPostBySlug(slug)
+	if err != nil {
+		respondProblem(w, r, http.StatusNotFound, "not found", Problem{
+			Detail: fmt.Sprintf("post %q does not exist", slug),
+		})
+		return
+	}
+	setLastModified(w, post.UpdatedAt)
+	respondJSON(w, http.StatusOK, post)
+}
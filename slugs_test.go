@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func postPost(t *testing.T, router http.Handler, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/posts", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// ========== Slug Tests ==========
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"First Post", "first-post"},
+		{"  Hello, World!  ", "hello-world"},
+		{"Go 1.21 -- what's new?", "go-1-21-what-s-new"},
+		{"Crème brûlée", "cr-me-br-l-e"},
+		{"!!!", "post"},
+		{"", "post"},
+		{strings.Repeat("ab ", 50), strings.TrimSuffix(strings.Repeat("ab-", 27), "-")[:maxSlugLength]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			got := slugify(tt.title)
+			assert.Equal(t, tt.want, got)
+			assert.Regexp(t, slugPattern, got)
+		})
+	}
+}
+
+func TestUniqueSlug(t *testing.T) {
+	taken := map[string]bool{"post": true, "post-2": true}
+	isTaken := func(slug string) bool { return taken[slug] }
+
+	assert.Equal(t, "post-3", uniqueSlug("post", isTaken))
+	assert.Equal(t, "other", uniqueSlug("other", isTaken))
+}
+
+func TestWithSlugs(t *testing.T) {
+	posts := []Post{
+		{ID: 1, Title: "Hello", Slug: "hello"},
+		{ID: 2, Title: "Hello"},
+		{ID: 3, Title: "Hello"},
+	}
+
+	got := withSlugs(posts)
+
+	assert.Equal(t, []string{"hello", "hello-2", "hello-3"}, []string{got[0].Slug, got[1].Slug, got[2].Slug})
+	assert.Empty(t, posts[1].Slug, "the input is left as it is")
+}
+
+// ========== Slug Endpoint Tests ==========
+
+func TestCreatePost_DerivesSlug(t *testing.T) {
+	router := setupRouter()
+
+	var first, second Post
+	w := postPost(t, router, `{"userId":1,"title":"Hello, World"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &first))
+	w = postPost(t, router, `{"userId":2,"title":"hello world"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &second))
+
+	assert.Equal(t, "hello-world", first.Slug)
+	assert.Equal(t, "hello-world-2", second.Slug, "derived slugs are made unique")
+}
+
+func TestCreatePost_DuplicateSlug(t *testing.T) {
+	router := setupRouter()
+
+	w := postPost(t, router, `{"userId":2,"title":"Another","slug":"first-post"}`)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	var problem Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, "a post with this slug already exists", problem.Detail)
+	assert.Equal(t, "/posts/1", problem.Resource)
+
+	w = postPost(t, router, `{"userId":1,"title":"First Post","slug":"fresh"}`)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, "this user already has a post with this title", problem.Detail)
+}
+
+func TestCreatePost_InvalidSlug(t *testing.T) {
+	router := setupRouter()
+
+	for _, slug := range []string{"Upper", "two--hyphens", "-lead", "trail-", "sp ace", "ünï"} {
+		w := postPost(t, router, `{"userId":1,"title":"T","slug":"`+slug+`"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code, slug)
+	}
+}
+
+func TestGetPostBySlug(t *testing.T) {
+	router := setupRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/posts/slug/first-post", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var post Post
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &post))
+	assert.Equal(t, 1, post.ID)
+	assert.NotEmpty(t, w.Header().Get("Last-Modified"))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/posts/slug/no-such-post", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), `post \"no-such-post\" does not exist`)
+}
+
+func TestRestore_GivesSlugs(t *testing.T) {
+	setupRouter()
+
+	server.Store.Restore(State{Posts: []Post{
+		{ID: 4, UserID: 1, Title: "Old Post", Version: 1},
+		{ID: 5, UserID: 2, Title: "Old Post", Version: 1},
+	}})
+
+	for id, want := range map[int]string{4: "old-post", 5: "old-post-2"} {
+		p, err := server.Store.GetPost(id)
+		require.NoError(t, err)
+		assert.Equal(t, want, p.Slug)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"net/http"
+
+	"gopkg.in/yaml.v3"
+)
+
+// openAPISource is openapi.yaml, the OpenAPI document describing this API.
+// It is embedded so the binary always serves the spec it was built with.
+//
+//go:embed openapi.yaml
+var openAPISource []byte
+
+// openAPISpec is openAPISource with the examples in testdata/examples.
+var openAPISpec = withExamples(openAPISource)
+
+func (srv *Server) specHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(srv.spec)
+}
+
+// renameSpec returns spec with the names of its schemas' properties, and
+// of their required lists, passed through rename, to describe the bodies
+// of a server with that JSON_NAMING. Examples are written with the
+// declared names, so they are left out.
+func renameSpec(spec []byte, rename func(string) string) ([]byte, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(spec, &root); err != nil {
+		return nil, err
+	}
+	renameSchemas(&root, rename)
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&root); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), enc.Close()
+}
+
+// renameSchemas applies renameSpec to n and everything under it. The keys
+// of a properties mapping are names rather than schema keywords, so only
+// the schemas they map to are searched further.
+func renameSchemas(n *yaml.Node, rename func(string) string) {
+	if n.Kind != yaml.MappingNode {
+		for _, c := range n.Content {
+			renameSchemas(c, rename)
+		}
+		return
+	}
+	content := n.Content[:0]
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		switch {
+		case key.Value == "example" || key.Value == "examples":
+			continue
+		case key.Value == "properties" && value.Kind == yaml.MappingNode:
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				value.Content[j].Value = rename(value.Content[j].Value)
+				renameSchemas(value.Content[j+1], rename)
+			}
+		case key.Value == "required" && value.Kind == yaml.SequenceNode:
+			for _, name := range value.Content {
+				name.Value = rename(name.Value)
+			}
+		default:
+			renameSchemas(value, rename)
+		}
+		content = append(content, key, value)
+	}
+	n.Content = content
+}
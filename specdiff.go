@@ -0,0 +1,344 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// SpecDiff is GET /spec/diff's report of how one API version's OpenAPI
+// document differs from another's.
+type SpecDiff struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Breaking lists the changes that can break a client written against
+	// From, NonBreaking the rest; both are sorted.
+	Breaking    []SpecChange `json:"breaking"`
+	NonBreaking []SpecChange `json:"nonBreaking"`
+}
+
+// SpecChange is one difference, in one operation.
+type SpecChange struct {
+	// Operation is the method and path, such as "GET /users".
+	Operation string `json:"operation"`
+	// Change says where and what changed, such as
+	// "response 200 application/json /postCount: removed".
+	Change string `json:"change"`
+}
+
+// specVersions splits doc into the API versions GET /spec/diff compares.
+// v1 is every path but those under /v2. v2 is what a client of the v2 API
+// sees: v1 with each /v2 operation in place of the one at the same path
+// without the prefix.
+func specVersions(doc *openapi3.T) map[string]*openapi3.T {
+	v1, v2 := *doc, *doc
+	v1.Paths, v2.Paths = openapi3.NewPaths(), openapi3.NewPaths()
+	for _, path := range doc.Paths.InMatchingOrder() {
+		if !strings.HasPrefix(path, "/v2/") {
+			v1.Paths.Set(path, doc.Paths.Value(path))
+			item := *doc.Paths.Value(path)
+			v2.Paths.Set(path, &item)
+		}
+	}
+	for _, path := range doc.Paths.InMatchingOrder() {
+		versioned, ok := strings.CutPrefix(path, "/v2")
+		if !ok || !strings.HasPrefix(versioned, "/") {
+			continue
+		}
+		item := v2.Paths.Value(versioned)
+		if item == nil {
+			item = &openapi3.PathItem{}
+			v2.Paths.Set(versioned, item)
+		}
+		for method, op := range doc.Paths.Value(path).Operations() {
+			item.SetOperation(method, op)
+		}
+	}
+	return map[string]*openapi3.T{"v1": &v1, "v2": &v2}
+}
+
+// specDiffer collects the changes between two documents. Request and
+// response schemas are compared in opposite directions: a property a
+// request may no longer send breaks clients, as does one a response no
+// longer sends.
+type specDiffer struct {
+	diff SpecDiff
+	// seen holds the pairs of schemas being compared, so a recursive
+	// schema is not followed into itself.
+	seen map[[2]*openapi3.Schema]bool
+}
+
+// diffSpecs compares the operations of from and to.
+func diffSpecs(fromName string, from *openapi3.T, toName string, to *openapi3.T) SpecDiff {
+	d := &specDiffer{
+		diff: SpecDiff{From: fromName, To: toName, Breaking: []SpecChange{}, NonBreaking: []SpecChange{}},
+		seen: map[[2]*openapi3.Schema]bool{},
+	}
+	fromOps, toOps := operationsByKey(from), operationsByKey(to)
+	for key, op := range fromOps {
+		if toOps[key] == nil {
+			d.add(true, key, "operation removed")
+			continue
+		}
+		d.operation(key, op, toOps[key])
+	}
+	for key := range toOps {
+		if fromOps[key] == nil {
+			d.add(false, key, "operation added")
+		}
+	}
+	for _, changes := range [][]SpecChange{d.diff.Breaking, d.diff.NonBreaking} {
+		sort.Slice(changes, func(i, j int) bool {
+			if changes[i].Operation != changes[j].Operation {
+				return changes[i].Operation < changes[j].Operation
+			}
+			return changes[i].Change < changes[j].Change
+		})
+	}
+	return d.diff
+}
+
+// operationsByKey indexes doc's operations by method and path.
+func operationsByKey(doc *openapi3.T) map[string]*openapi3.Operation {
+	ops := map[string]*openapi3.Operation{}
+	for path, item := range doc.Paths.Map() {
+		for method, op := range item.Operations() {
+			ops[method+" "+path] = op
+		}
+	}
+	return ops
+}
+
+func (d *specDiffer) add(breaking bool, op, format string, args ...any) {
+	change := SpecChange{Operation: op, Change: fmt.Sprintf(format, args...)}
+	if breaking {
+		d.diff.Breaking = append(d.diff.Breaking, change)
+	} else {
+		d.diff.NonBreaking = append(d.diff.NonBreaking, change)
+	}
+}
+
+func (d *specDiffer) operation(key string, from, to *openapi3.Operation) {
+	if !from.Deprecated && to.Deprecated {
+		d.add(false, key, "deprecated")
+	}
+
+	fromParams, toParams := parametersByKey(from), parametersByKey(to)
+	for name, p := range fromParams {
+		q := toParams[name]
+		if q == nil {
+			d.add(true, key, "%s: removed", name)
+			continue
+		}
+		if !p.Required && q.Required {
+			d.add(true, key, "%s: now required", name)
+		}
+		if p.Required && !q.Required {
+			d.add(false, key, "%s: now optional", name)
+		}
+		d.schema(key, name, "", p.Schema, q.Schema, true)
+	}
+	for name, q := range toParams {
+		if fromParams[name] == nil {
+			d.add(q.Required, key, "%s: added", name)
+		}
+	}
+
+	fromBody, toBody := requestBody(from), requestBody(to)
+	switch {
+	case fromBody == nil && toBody != nil:
+		d.add(toBody.Required, key, "request body: added")
+	case fromBody != nil && toBody == nil:
+		d.add(true, key, "request body: removed")
+	case fromBody != nil:
+		if !fromBody.Required && toBody.Required {
+			d.add(true, key, "request body: now required")
+		}
+		d.content(key, "request body", fromBody.Content, toBody.Content, true)
+	}
+
+	fromResponses, toResponses := responses(from), responses(to)
+	for status, resp := range fromResponses {
+		other := toResponses[status]
+		if other == nil {
+			d.add(strings.HasPrefix(status, "2"), key, "response %s: removed", status)
+			continue
+		}
+		d.content(key, "response "+status, resp.Content, other.Content, false)
+	}
+	for status := range toResponses {
+		if fromResponses[status] == nil {
+			d.add(false, key, "response %s: added", status)
+		}
+	}
+}
+
+// parametersByKey indexes op's parameters by location and name, such as
+// `query parameter "page"`.
+func parametersByKey(op *openapi3.Operation) map[string]*openapi3.Parameter {
+	params := map[string]*openapi3.Parameter{}
+	for _, ref := range op.Parameters {
+		if ref.Value != nil {
+			params[fmt.Sprintf("%s parameter %q", ref.Value.In, ref.Value.Name)] = ref.Value
+		}
+	}
+	return params
+}
+
+func requestBody(op *openapi3.Operation) *openapi3.RequestBody {
+	if op.RequestBody == nil {
+		return nil
+	}
+	return op.RequestBody.Value
+}
+
+func responses(op *openapi3.Operation) map[string]*openapi3.Response {
+	out := map[string]*openapi3.Response{}
+	if op.Responses == nil {
+		return out
+	}
+	for status, ref := range op.Responses.Map() {
+		if ref.Value != nil {
+			out[status] = ref.Value
+		}
+	}
+	return out
+}
+
+// content compares the media types of a request or response body.
+func (d *specDiffer) content(key, where string, from, to openapi3.Content, request bool) {
+	for mediaType, m := range from {
+		n := to[mediaType]
+		if n == nil {
+			d.add(true, key, "%s %s: removed", where, mediaType)
+			continue
+		}
+		d.schema(key, where+" "+mediaType, "", m.Schema, n.Schema, request)
+	}
+	for mediaType := range to {
+		if from[mediaType] == nil {
+			d.add(false, key, "%s %s: added", where, mediaType)
+		}
+	}
+}
+
+// schema compares the schemas at ptr, a JSON pointer, in the parameter or
+// body at loc. For a request, from is what clients send and to what the
+// server now accepts; for a response, from is what clients expect and to
+// what the server now sends.
+func (d *specDiffer) schema(key, loc, ptr string, fromRef, toRef *openapi3.SchemaRef, request bool) {
+	if fromRef == nil || toRef == nil || fromRef.Value == nil || toRef.Value == nil {
+		return
+	}
+	from, to := fromRef.Value, toRef.Value
+	pair := [2]*openapi3.Schema{from, to}
+	if d.seen[pair] {
+		return
+	}
+	d.seen[pair] = true
+	defer delete(d.seen, pair)
+	at := strings.TrimSpace(loc + " " + ptr)
+
+	if !reflect.DeepEqual(from.Type.Slice(), to.Type.Slice()) {
+		d.add(true, key, "%s: type changed from %s to %s", at, schemaType(from), schemaType(to))
+		return
+	}
+	if from.Format != to.Format {
+		d.add(true, key, "%s: format changed from %q to %q", at, from.Format, to.Format)
+	}
+	for _, v := range from.Enum {
+		if len(to.Enum) > 0 && !containsValue(to.Enum, v) {
+			d.add(request, key, "%s: value %v removed", at, v)
+		}
+	}
+	for _, v := range to.Enum {
+		if len(from.Enum) > 0 && !containsValue(from.Enum, v) {
+			d.add(!request, key, "%s: value %v added", at, v)
+		}
+	}
+
+	for name, p := range from.Properties {
+		q := to.Properties[name]
+		if q == nil {
+			// A request property the server no longer knows is rejected
+			// unless the schema allows others.
+			d.add(!request || !allowsAdditional(to), key, "%s %s/%s: removed", loc, ptr, name)
+			continue
+		}
+		d.schema(key, loc, ptr+"/"+name, p, q, request)
+	}
+	for name := range to.Properties {
+		if from.Properties[name] == nil {
+			d.add(request && slices.Contains(to.Required, name), key, "%s %s/%s: added", loc, ptr, name)
+		}
+	}
+	for _, name := range to.Required {
+		if !slices.Contains(from.Required, name) && from.Properties[name] != nil {
+			d.add(request, key, "%s %s/%s: now required", loc, ptr, name)
+		}
+	}
+	for _, name := range from.Required {
+		if !slices.Contains(to.Required, name) && to.Properties[name] != nil {
+			d.add(!request, key, "%s %s/%s: now optional", loc, ptr, name)
+		}
+	}
+	d.schema(key, loc, ptr+"/items", from.Items, to.Items, request)
+}
+
+func schemaType(s *openapi3.Schema) string {
+	if types := s.Type.Slice(); len(types) > 0 {
+		return strings.Join(types, "|")
+	}
+	return "any"
+}
+
+func allowsAdditional(s *openapi3.Schema) bool {
+	return s.AdditionalProperties.Has == nil || *s.AdditionalProperties.Has || s.AdditionalProperties.Schema != nil
+}
+
+func containsValue(list []any, v any) bool {
+	for _, w := range list {
+		if reflect.DeepEqual(w, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// getSpecDiff compares the documents of the versions named by ?from= and
+// ?to=.
+func (srv *Server) getSpecDiff(w http.ResponseWriter, r *http.Request) {
+	versions, err := srv.specVersions()
+	if err != nil {
+		srv.logAt("error", "parsing openapi.yaml: %v", err)
+		respondError(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+	names := make([]string, 0, len(versions))
+	for name := range versions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var violations []string
+	for _, param := range []string{"from", "to"} {
+		v := r.URL.Query().Get(param)
+		switch {
+		case v == "":
+			violations = append(violations, fmt.Sprintf("query parameter %q: is required", param))
+		case versions[v] == nil:
+			violations = append(violations, fmt.Sprintf("query parameter %q: must be one of %s", param, strings.Join(names, ", ")))
+		}
+	}
+	if len(violations) > 0 {
+		respondProblem(w, r, http.StatusBadRequest, "invalid request", Problem{Violations: violations})
+		return
+	}
+	from, to := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+	respondJSON(w, http.StatusOK, diffSpecs(from, versions[from], to, versions[to]))
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const diffFromSpec = `
+openapi: 3.0.3
+info: {title: Pets, version: "1"}
+paths:
+  /pets:
+    get:
+      parameters:
+        - {name: page, in: query, schema: {type: integer}}
+      responses:
+        "200":
+          description: The pets
+          content:
+            application/json:
+              schema: {type: array, items: {$ref: "#/components/schemas/Pet"}}
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema: {$ref: "#/components/schemas/Pet"}
+      responses:
+        "201": {description: Created}
+  /owners:
+    get:
+      responses:
+        "200": {description: The owners}
+components:
+  schemas:
+    Pet:
+      type: object
+      additionalProperties: false
+      required: [name]
+      properties:
+        name: {type: string}
+        age: {type: integer}
+        kind: {type: string, enum: [cat, dog]}
+`
+
+const diffToSpec = `
+openapi: 3.0.3
+info: {title: Pets, version: "2"}
+paths:
+  /pets:
+    get:
+      parameters:
+        - {name: page, in: query, schema: {type: string}}
+        - {name: sort, in: query, schema: {type: string}}
+      responses:
+        "200":
+          description: The pets
+          content:
+            application/json:
+              schema: {type: array, items: {$ref: "#/components/schemas/Pet"}}
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema: {$ref: "#/components/schemas/Pet"}
+      responses:
+        "201": {description: Created}
+  /pets/{id}:
+    get:
+      parameters:
+        - {name: id, in: path, required: true, schema: {type: integer}}
+      responses:
+        "200": {description: A pet}
+components:
+  schemas:
+    Pet:
+      type: object
+      additionalProperties: false
+      required: [name, owner]
+      properties:
+        name: {type: string}
+        owner: {type: string}
+        kind: {type: string, enum: [cat, dog, bird]}
+`
+
+// ========== Spec Diff Tests ==========
+
+func TestDiffSpecs(t *testing.T) {
+	from, err := openapi3.NewLoader().LoadFromData([]byte(diffFromSpec))
+	require.NoError(t, err)
+	to, err := openapi3.NewLoader().LoadFromData([]byte(diffToSpec))
+	require.NoError(t, err)
+
+	diff := diffSpecs("v1", from, "v2", to)
+
+	assert.Equal(t, []SpecChange{
+		{"GET /owners", "operation removed"},
+		{"GET /pets", `query parameter "page": type changed from integer to string`},
+		{"GET /pets", "response 200 application/json /items/age: removed"},
+		{"GET /pets", "response 200 application/json /items/kind: value bird added"},
+		{"POST /pets", "request body application/json /age: removed"},
+		{"POST /pets", "request body application/json /owner: added"},
+	}, diff.Breaking)
+	assert.Equal(t, []SpecChange{
+		{"GET /pets", `query parameter "sort": added`},
+		{"GET /pets", "response 200 application/json /items/owner: added"},
+		{"GET /pets/{id}", "operation added"},
+		{"POST /pets", "request body application/json /kind: value bird added"},
+	}, diff.NonBreaking)
+}
+
+func TestDiffSpecs_Identical(t *testing.T) {
+	setupRouter()
+	versions, err := server.specVersions()
+	require.NoError(t, err)
+
+	diff := diffSpecs("v1", versions["v1"], "v1", versions["v1"])
+
+	assert.Empty(t, diff.Breaking)
+	assert.Empty(t, diff.NonBreaking)
+}
+
+func TestGetSpecDiff_InvalidVersions(t *testing.T) {
+	router := setupRouter()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/spec/diff?from=v1", nil))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `query parameter \"to\"`)
+}
+
+// The versions are split from the served document, so the diff names
+// fields as the API serves them.
+func TestGetSpecDiff_FieldNaming(t *testing.T) {
+	router := setupRouter()
+	useFieldNaming(t, "snake")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/spec/diff?from=v1&to=v2", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var diff SpecDiff
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &diff))
+	assert.Contains(t, diff.Breaking, SpecChange{"GET /users", "response 200 application/json /items/post_count: removed"})
+	assert.NotContains(t, w.Body.String(), "postCount")
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/api2spec/api2spec-fixture-chi/flags"
+)
+
+// maxStateBytes bounds a PUT /admin/state body. States carry photo images,
+// so they may be much larger than other request bodies.
+const maxStateBytes = 64 << 20
+
+// State is the entire contents of the store, served by GET /admin/state
+// and restored by PUT /admin/state. Collections are ordered by ID.
+type State struct {
+	Users        []User        `json:"users"`
+	Posts        []Post        `json:"posts"`
+	Comments     []Comment     `json:"comments"`
+	Todos        []Todo        `json:"todos"`
+	Albums       []Album       `json:"albums"`
+	Photos       []StatePhoto  `json:"photos"`
+	Places       []Place       `json:"places"`
+	IngestEvents []IngestEvent `json:"ingestEvents"`
+	Webhooks     []Webhook     `json:"webhooks"`
+	Deliveries   []Delivery    `json:"deliveries"`
+	Tenants      []Tenant      `json:"tenants"`
+	Flags        flags.Set     `json:"flags"`
+	NextIDs      NextIDs       `json:"nextIds"`
+}
+
+// StatePhoto is a photo's metadata with its encoded image.
+type StatePhoto struct {
+	Photo
+	Data []byte `json:"data"`
+}
+
+// NextIDs are the IDs the store will assign next. On restore each is
+// raised, if needed, past the largest ID in its collection.
+type NextIDs struct {
+	Users        int `json:"users"`
+	Posts        int `json:"posts"`
+	Todos        int `json:"todos"`
+	Albums       int `json:"albums"`
+	Photos       int `json:"photos"`
+	IngestEvents int `json:"ingestEvents"`
+	Webhooks     int `json:"webhooks"`
+	Deliveries   int `json:"deliveries"`
+	Tenants      int `json:"tenants"`
+}
+
+// validate returns one violation per invalid or duplicate ID in st.
+func (st State) validate() []string {
+	var violations []string
+	check := func(collection string, n int, id func(i int) int) {
+		seen := map[int]bool{}
+		for i := 0; i < n; i++ {
+			switch id := id(i); {
+			case id < 1 || id > maxID:
+				violations = append(violations, fmt.Sprintf("request body /%s/%d/id: must be between 1 and %d", collection, i, maxID))
+			case seen[id]:
+				violations = append(violations, fmt.Sprintf("request body /%s/%d/id: duplicate id %d", collection, i, id))
+			default:
+				seen[id] = true
+			}
+		}
+	}
+	check("users", len(st.Users), func(i int) int { return st.Users[i].ID })
+	check("posts", len(st.Posts), func(i int) int { return st.Posts[i].ID })
+	check("comments", len(st.Comments), func(i int) int { return st.Comments[i].ID })
+	check("todos", len(st.Todos), func(i int) int { return st.Todos[i].ID })
+	check("albums", len(st.Albums), func(i int) int { return st.Albums[i].ID })
+	check("photos", len(st.Photos), func(i int) int { return st.Photos[i].ID })
+	check("places", len(st.Places), func(i int) int { return st.Places[i].ID })
+	check("ingestEvents", len(st.IngestEvents), func(i int) int { return st.IngestEvents[i].ID })
+	check("webhooks", len(st.Webhooks), func(i int) int { return st.Webhooks[i].ID })
+	check("deliveries", len(st.Deliveries), func(i int) int { return st.Deliveries[i].ID })
+	check("tenants", len(st.Tenants), func(i int) int { return st.Tenants[i].ID })
+	return violations
+}
+
+func (srv *Server) getState(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, srv.requestStore(r).Snapshot())
+}
+
+func (srv *Server) putState(w http.ResponseWriter, r *http.Request) {
+	var st State
+	if err := decodeJSONLimit(r, &st, maxStateBytes); err != nil {
+		respondDecodeError(w, r, err)
+		return
+	}
+	if violations := st.validate(); len(violations) > 0 {
+		respondProblem(w, r, http.StatusBadRequest, "invalid request", Problem{Violations: violations})
+		return
+	}
+	srv.requestStore(r).Restore(st)
+	respondJSON(w, http.StatusOK, srv.requestStore(r).Snapshot())
+}
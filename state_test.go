@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// getTestState fetches GET /admin/state.
+func getTestState(t *testing.T, router http.Handler) State {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodGet, "/admin/state", ""))
+	require.Equal(t, http.StatusOK, w.Code)
+	var st State
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &st))
+	return st
+}
+
+// putTestState sends st to PUT /admin/state.
+func putTestState(t *testing.T, router http.Handler, st State) *httptest.ResponseRecorder {
+	t.Helper()
+	body, err := json.Marshal(st)
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPut, "/admin/state", string(body)))
+	return w
+}
+
+// ========== State Tests ==========
+
+func TestGetState(t *testing.T) {
+	router := setupAdminRouter(t)
+	st := getTestState(t, router)
+
+	assert.Len(t, st.Users, 2)
+	assert.Len(t, st.Posts, 2)
+	assert.NotEmpty(t, st.Photos)
+	assert.NotEmpty(t, st.Photos[0].Data, "photos carry their images")
+	assert.Equal(t, time.Date(2024, 1, 1, 9, 15, 0, 0, time.UTC), st.Posts[0].CreatedAt)
+	assert.Equal(t, 3, st.NextIDs.Users)
+	assert.NotNil(t, st.Webhooks, "empty collections are arrays, not null")
+}
+
+func TestPutState_RoundTrip(t *testing.T) {
+	router := setupAdminRouter(t)
+	snapshot := getTestState(t, router)
+
+	createTestUser(t, router)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/users/2", nil))
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.NotEqual(t, snapshot, getTestState(t, router))
+
+	w = putTestState(t, router, snapshot)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, snapshot, getTestState(t, router))
+
+	// IDs continue where the snapshot left off.
+	createTestUser(t, router)
+	_, err := server.Store.GetUser(snapshot.NextIDs.Users)
+	assert.NoError(t, err)
+}
+
+func TestPutState_RaisesNextIDs(t *testing.T) {
+	router := setupAdminRouter(t)
+	st := State{Users: []User{{ID: 41, Name: "Ada", Email: "ada@example.com", Version: 1}}}
+
+	w := putTestState(t, router, st)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var restored State
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &restored))
+	assert.Equal(t, 42, restored.NextIDs.Users)
+	assert.Equal(t, 1, restored.NextIDs.Posts)
+	assert.Empty(t, restored.Posts)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/41", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestPutState_RevokesSessions(t *testing.T) {
+	router := setupAdminRouter(t)
+	useAuthSecret(t)
+	snapshot := getTestState(t, router)
+	token := loginTestSession(t, router, 1, "laptop")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newTokenRequest(token, http.MethodGet, "/me"))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	require.Equal(t, http.StatusOK, putTestState(t, router, snapshot).Code)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newTokenRequest(token, http.MethodGet, "/me"))
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "user 1 may be someone else after a restore")
+	assert.Empty(t, server.Store.ListSessions(1))
+}
+
+func TestPutState_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		state    State
+		contains string
+	}{
+		{"zero id", State{Users: []User{{ID: 0}}}, "/users/0/id"},
+		{"duplicate id", State{Todos: []Todo{{ID: 1, Priority: PriorityLow}, {ID: 1, Priority: PriorityLow}}}, "duplicate id 1"},
+		{"id too large", State{Tenants: []Tenant{{ID: maxID + 1, Name: "Acme"}}}, fmt.Sprintf("between 1 and %d", maxID)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupAdminRouter(t)
+			before := getTestState(t, router)
+
+			w := putTestState(t, router, tt.state)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tt.contains)
+			assert.Equal(t, before, getTestState(t, router), "a rejected state changes nothing")
+		})
+	}
+}
+
+func TestState_RequiresAdmin(t *testing.T) {
+	router := setupAdminRouter(t)
+
+	for _, method := range []string{http.MethodGet, http.MethodPut} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, "/admin/state", nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code, method)
+	}
+}
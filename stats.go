@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Stats is the document served by GET /stats.
+type Stats struct {
+	Resources     ResourceCounts `json:"resources"`
+	Requests      RequestCounts  `json:"requests"`
+	StartedAt     time.Time      `json:"startedAt"`
+	UptimeSeconds float64        `json:"uptimeSeconds"`
+	Store         StoreInfo      `json:"store"`
+}
+
+// ResourceCounts is the number of stored entities per resource.
+type ResourceCounts struct {
+	Users  int `json:"users"`
+	Posts  int `json:"posts"`
+	Todos  int `json:"todos"`
+	Albums int `json:"albums"`
+}
+
+// RequestCounts summarizes requests served since startup, keyed by status
+// class ("2xx", "4xx", ...).
+type RequestCounts struct {
+	Total    int64            `json:"total"`
+	ByStatus map[string]int64 `json:"byStatus"`
+}
+
+// StoreInfo describes the store backend.
+type StoreInfo struct {
+	Backend string `json:"backend"`
+	// Breaker is the state of the circuit breaker guarding the backend,
+	// if there is one.
+	Breaker string `json:"breaker,omitempty"`
+}
+
+// requestCounters is fed by requestStatsPlugin.
+type requestCounters struct {
+	mu       sync.Mutex
+	total    int64
+	byStatus map[string]int64
+}
+
+func newRequestCounters() *requestCounters {
+	return &requestCounters{byStatus: make(map[string]int64)}
+}
+
+func (c *requestCounters) record(status int) {
+	class := strconv.Itoa(status/100) + "xx"
+	c.mu.Lock()
+	c.total++
+	c.byStatus[class]++
+	c.mu.Unlock()
+}
+
+func (c *requestCounters) snapshot() RequestCounts {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	byStatus := make(map[string]int64, len(c.byStatus))
+	for k, v := range c.byStatus {
+		byStatus[k] = v
+	}
+	return RequestCounts{Total: c.total, ByStatus: byStatus}
+}
+
+// requestStatsPlugin records every response's status in srv.requestStats.
+func (srv *Server) requestStatsPlugin() Plugin {
+	return Plugin{
+		Name: "request-stats",
+		OnResponse: func(r *http.Request, status int, header http.Header) {
+			srv.requestStats.record(status)
+		},
+	}
+}
+
+func (srv *Server) statsHandler(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, Stats{
+		Resources: ResourceCounts{
+			Users:  len(srv.requestStore(r).ListUsers()),
+			Posts:  len(srv.requestStore(r).ListPosts()),
+			Todos:  len(srv.requestStore(r).ListTodos()),
+			Albums: len(srv.requestStore(r).ListAlbums()),
+		},
+		Requests:      srv.requestStats.snapshot(),
+		StartedAt:     srv.startTime.UTC(),
+		UptimeSeconds: srv.Clock.Now().Sub(srv.startTime).Seconds(),
+		Store:         srv.requestStore(r).Info(),
+	})
+}
+
+// prometheusHandler serves the /stats figures in the Prometheus text
+// exposition format.
+func (srv *Server) prometheusHandler(w http.ResponseWriter, r *http.Request) {
+	counts := srv.requestStats.snapshot()
+	classes := make([]string, 0, len(counts.ByStatus))
+	for class := range counts.ByStatus {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintln(w, "# HELP http_requests_total Requests served since startup, by status class.")
+	fmt.Fprintln(w, "# TYPE http_requests_total counter")
+	for _, class := range classes {
+		fmt.Fprintf(w, "http_requests_total{status=%q} %d\n", class, counts.ByStatus[class])
+	}
+	fmt.Fprintln(w, "# HELP store_resources Stored entities, by resource.")
+	fmt.Fprintln(w, "# TYPE store_resources gauge")
+	for _, rc := range []struct {
+		name  string
+		count int
+	}{
+		{"albums", len(srv.requestStore(r).ListAlbums())},
+		{"posts", len(srv.requestStore(r).ListPosts())},
+		{"todos", len(srv.requestStore(r).ListTodos())},
+		{"users", len(srv.requestStore(r).ListUsers())},
+	} {
+		fmt.Fprintf(w, "store_resources{resource=%q} %d\n", rc.name, rc.count)
+	}
+	fmt.Fprintln(w, "# HELP route_latency_budget_seconds Latency budget, by operation.")
+	fmt.Fprintln(w, "# TYPE route_latency_budget_seconds gauge")
+	defs := append(srv.apiRouteDefs(), srv.opsRouteDefs()...)
+	sort.Slice(defs, func(i, j int) bool { return defs[i].OperationID < defs[j].OperationID })
+	for _, d := range defs {
+		fmt.Fprintf(w, "route_latency_budget_seconds{operation=%q} %g\n", d.OperationID, d.budget().Seconds())
+	}
+	budgets := srv.budgetStats.snapshot()
+	fmt.Fprintln(w, "# HELP route_requests_total Requests served, by operation.")
+	fmt.Fprintln(w, "# TYPE route_requests_total counter")
+	for _, b := range budgets {
+		fmt.Fprintf(w, "route_requests_total{operation=%q} %d\n", b.OperationID, b.Requests)
+	}
+	fmt.Fprintln(w, "# HELP route_latency_budget_violations_total Requests that overran their route's latency budget, by operation.")
+	fmt.Fprintln(w, "# TYPE route_latency_budget_violations_total counter")
+	for _, b := range budgets {
+		fmt.Fprintf(w, "route_latency_budget_violations_total{operation=%q} %d\n", b.OperationID, b.Violations)
+	}
+	hits, bans := srv.abuseStats.snapshot()
+	fmt.Fprintln(w, "# HELP abuse_honeypot_hits_total Requests for honeypot decoy paths, by path.")
+	fmt.Fprintln(w, "# TYPE abuse_honeypot_hits_total counter")
+	for _, h := range hits {
+		fmt.Fprintf(w, "abuse_honeypot_hits_total{path=%q} %d\n", h.Path, h.Hits)
+	}
+	fmt.Fprintln(w, "# HELP abuse_bans_total Clients banned for hitting a honeypot.")
+	fmt.Fprintln(w, "# TYPE abuse_bans_total counter")
+	fmt.Fprintf(w, "abuse_bans_total %d\n", bans)
+	upstreams := srv.outbound.snapshot()
+	fmt.Fprintln(w, "# HELP outbound_requests_total Calls to external services, by upstream and outcome.")
+	fmt.Fprintln(w, "# TYPE outbound_requests_total counter")
+	for _, u := range upstreams {
+		outcomes := make([]string, 0, len(u.Outcomes))
+		for outcome := range u.Outcomes {
+			outcomes = append(outcomes, outcome)
+		}
+		sort.Strings(outcomes)
+		for _, outcome := range outcomes {
+			fmt.Fprintf(w, "outbound_requests_total{upstream=%q,outcome=%q} %d\n", u.Name, outcome, u.Outcomes[outcome])
+		}
+	}
+	fmt.Fprintln(w, "# HELP outbound_retries_total Further attempts at calls to external services, by upstream.")
+	fmt.Fprintln(w, "# TYPE outbound_retries_total counter")
+	for _, u := range upstreams {
+		fmt.Fprintf(w, "outbound_retries_total{upstream=%q} %d\n", u.Name, u.Retries)
+	}
+	fmt.Fprintln(w, "# HELP outbound_request_duration_seconds Time calls to external services took, retries included, by upstream.")
+	fmt.Fprintln(w, "# TYPE outbound_request_duration_seconds summary")
+	for _, u := range upstreams {
+		fmt.Fprintf(w, "outbound_request_duration_seconds_sum{upstream=%q} %g\n", u.Name, u.Seconds)
+		fmt.Fprintf(w, "outbound_request_duration_seconds_count{upstream=%q} %d\n", u.Name, u.Calls)
+	}
+	fmt.Fprintln(w, "# HELP outbound_breaker_open Whether the circuit breaker guarding an external service refuses calls, by upstream.")
+	fmt.Fprintln(w, "# TYPE outbound_breaker_open gauge")
+	for _, u := range upstreams {
+		open := 0
+		if u.Breaker == breakerOpen {
+			open = 1
+		}
+		fmt.Fprintf(w, "outbound_breaker_open{upstream=%q} %d\n", u.Name, open)
+	}
+	fmt.Fprintln(w, "# HELP process_uptime_seconds Seconds since startup.")
+	fmt.Fprintln(w, "# TYPE process_uptime_seconds gauge")
+	fmt.Fprintf(w, "process_uptime_seconds %g\n", srv.Clock.Now().Sub(srv.startTime).Seconds())
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== Stats Endpoint Tests ==========
+
+func getStats(t *testing.T, router http.Handler) Stats {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assertJSONContentType(t, w)
+
+	var stats Stats
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	return stats
+}
+
+func TestStats_InitialState(t *testing.T) {
+	router := setupRouter()
+
+	stats := getStats(t, router)
+
+	assert.Equal(t, ResourceCounts{Users: 2, Posts: 2, Todos: 3, Albums: 1}, stats.Resources)
+	assert.Equal(t, int64(0), stats.Requests.Total)
+	assert.Equal(t, StoreInfo{Backend: "memory"}, stats.Store)
+	assert.GreaterOrEqual(t, stats.UptimeSeconds, 0.0)
+	assert.False(t, stats.StartedAt.IsZero())
+}
+
+func TestStats_UptimeByServerClock(t *testing.T) {
+	router := setupRouter()
+	freezeClock(server.startTime.Add(90 * time.Second))
+
+	stats := getStats(t, router)
+
+	assert.Equal(t, server.startTime.UTC(), stats.StartedAt)
+	assert.Equal(t, 90.0, stats.UptimeSeconds)
+}
+
+func TestStats_CountsRequestsAndResources(t *testing.T) {
+	router := setupRouter()
+
+	requests := []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/users", nil),
+		httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":"Carol"}`)),
+		httptest.NewRequest(http.MethodPost, "/posts", strings.NewReader(`{"title":"t"}`)),
+		httptest.NewRequest(http.MethodDelete, "/users/2", nil),
+		httptest.NewRequest(http.MethodGet, "/users/abc", nil),
+		httptest.NewRequest(http.MethodGet, "/nonexistent", nil),
+	}
+	for _, req := range requests {
+		if req.Body != http.NoBody {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	stats := getStats(t, router)
+
+	assert.Equal(t, ResourceCounts{Users: 2, Posts: 3, Todos: 3, Albums: 1}, stats.Resources)
+	assert.Equal(t, int64(6), stats.Requests.Total)
+	assert.Equal(t, map[string]int64{"2xx": 4, "4xx": 2}, stats.Requests.ByStatus)
+}
+
+// ========== Prometheus Endpoint Tests ==========
+
+func TestPrometheus_Exposition(t *testing.T) {
+	router := setupRouter()
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nonexistent", nil))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/plain; version=0.0.4", w.Header().Get("Content-Type"))
+	body := w.Body.String()
+	assert.Contains(t, body, "# TYPE http_requests_total counter\n")
+	assert.Contains(t, body, `http_requests_total{status="2xx"} 1`+"\n")
+	assert.Contains(t, body, `http_requests_total{status="4xx"} 1`+"\n")
+	assert.Contains(t, body, `store_resources{resource="users"} 2`+"\n")
+	assert.Contains(t, body, `store_resources{resource="todos"} 3`+"\n")
+	assert.Contains(t, body, "process_uptime_seconds ")
+}
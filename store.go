@@ -0,0 +1,1283 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/api2spec/api2spec-fixture-chi/flags"
+)
+
+var (
+	errNotFound        = errors.New("not found")
+	errVersionConflict = errors.New("version conflict")
+	errDuplicate       = errors.New("duplicate")
+	errModified        = errors.New("modified")
+	errQuotaExceeded   = errors.New("quota exceeded")
+)
+
+// Store is the persistence layer behind the handlers. Implementations
+// publish an Event for every successful create, update, and delete.
+type Store interface {
+	ListUsers() []User
+	// SuggestUsers returns at most limit users whose names start with
+	// prefix, ignoring case, ordered by lowercased name and then ID.
+	SuggestUsers(prefix string, limit int) []UserSuggestion
+	CountUsers() int
+	GetUser(id int) (User, error)
+	// CreateUser stores u under a new ID. If another user already has u's
+	// email it returns that user and errDuplicate.
+	CreateUser(u User) (User, error)
+	// UpdateUser replaces the user with u.ID if u.Version matches the stored
+	// version. On a mismatch it returns the current user and
+	// errVersionConflict; if u's email belongs to another user it returns
+	// that user and errDuplicate.
+	UpdateUser(u User) (User, error)
+	// DeleteUser removes user id. If unmodifiedSince is not zero and the
+	// user was modified after it, the user is kept and errModified
+	// returned.
+	DeleteUser(id int, unmodifiedSince time.Time) error
+	// VerifyUser marks user id Verified if their email is still email,
+	// compared case-insensitively, and returns the user. Otherwise it
+	// returns errNotFound.
+	VerifyUser(id int, email string) (User, error)
+	// SetPasswordHash stores hash, a bcrypt hash, as user id's password.
+	// Password hashes are never part of a User.
+	SetPasswordHash(id int, hash []byte) error
+	// PasswordHash returns user id's password hash, or nil if the user has
+	// no password.
+	PasswordHash(id int) ([]byte, error)
+	// SetTwoFactor stores user id's two-factor state. Like password hashes
+	// it is never part of a User.
+	SetTwoFactor(id int, tf TwoFactor) error
+	// TwoFactor returns user id's two-factor state, the zero TwoFactor if
+	// they never set it up.
+	TwoFactor(id int) (TwoFactor, error)
+
+	ListPosts() []Post
+	// ListPostsByUser returns the posts written by userID, ordered by ID.
+	ListPostsByUser(userID int) []Post
+	// CountPosts counts the posts written by userID, or every post when
+	// userID is 0.
+	CountPosts(userID int) int
+	GetPost(id int) (Post, error)
+	GetPostBySlug(slug string) (Post, error)
+	// CreatePost stores p under a new ID and increments its author's
+	// PostCount, if the author exists. If the same user already has a post
+	// with p's title, or another post has p's slug, it returns that post
+	// and errDuplicate. Without a slug, p is given one derived from its
+	// title, suffixed to make it unique.
+	CreatePost(p Post) (Post, error)
+	// CreateUserPost is CreatePost for an author that must exist: the post
+	// is stored and p.UserID's PostCount incremented in one transaction. If
+	// the user does not exist nothing changes and errNotFound is returned.
+	CreateUserPost(p Post) (Post, error)
+	// UpdatePost replaces the post with p.ID if p.Version matches, as
+	// UpdateUser does. The post keeps its author, and its slug when p has
+	// none. If another post by the author has p's title, or another post
+	// has p's slug, it returns that post and errDuplicate.
+	UpdatePost(p Post) (Post, error)
+	// DeletePost removes post id, with the same unmodifiedSince check as
+	// DeleteUser, and decrements its author's PostCount.
+	DeletePost(id int, unmodifiedSince time.Time) error
+
+	// GetComment returns comment id. Comments are read-only except
+	// through Restore, and outlive their posts.
+	GetComment(id int) (Comment, error)
+
+	// ListTodos returns every todo, ordered by ID.
+	ListTodos() []Todo
+	GetTodo(id int) (Todo, error)
+	CreateTodo(t Todo) (Todo, error)
+
+	ListAlbums() []Album
+	GetAlbum(id int) (Album, error)
+	CreateAlbum(a Album) (Album, error)
+	// ListPhotosByAlbum returns the photos in albumID, ordered by ID.
+	ListPhotosByAlbum(albumID int) []Photo
+	GetPhoto(id int) (Photo, error)
+	// CreatePhoto stores p under a new ID along with its encoded image.
+	CreatePhoto(p Photo, data []byte) (Photo, error)
+	// PhotoData returns the encoded image of photo id.
+	PhotoData(id int) ([]byte, error)
+
+	// ListPlaces returns every place, ordered by ID. Places are read-only
+	// except through Restore.
+	ListPlaces() []Place
+
+	ListIngestEvents() []IngestEvent
+	GetIngestEvent(id int) (IngestEvent, error)
+	// CreateIngestEvent stores e under a new ID, stamped with the time it
+	// was received.
+	CreateIngestEvent(e IngestEvent) (IngestEvent, error)
+
+	ListWebhooks() []Webhook
+	GetWebhook(id int) (Webhook, error)
+	CreateWebhook(w Webhook) (Webhook, error)
+	DeleteWebhook(id int) error
+
+	ListTenants() []Tenant
+	GetTenant(id int) (Tenant, error)
+	CreateTenant(t Tenant) (Tenant, error)
+	// UpdateTenant replaces the tenant with t.ID if t.Version matches the
+	// stored version. On a mismatch it returns the current tenant and
+	// errVersionConflict.
+	UpdateTenant(t Tenant) (Tenant, error)
+	DeleteTenant(id int) error
+
+	// GetFlags returns the current feature flags.
+	GetFlags() flags.Set
+	// UpdateFlags applies p to the feature flags and returns the result.
+	UpdateFlags(p flags.Patch) flags.Set
+
+	// EnqueueDelivery adds d to the outbox as pending and due immediately.
+	// Outbox changes are not published as events.
+	EnqueueDelivery(d Delivery) (Delivery, error)
+	// DueDeliveries returns the pending deliveries due at or before now,
+	// oldest first.
+	DueDeliveries(now time.Time) []Delivery
+	// UpdateDelivery replaces the delivery with d.ID.
+	UpdateDelivery(d Delivery) error
+	// ListDeliveries returns webhookID's delivery history, oldest first.
+	ListDeliveries(webhookID int) []Delivery
+
+	// ChargeUsage counts a request by userID on day, a UTC date, and
+	// returns the day's count. If limit requests were already counted that
+	// day it counts nothing and returns the count with errQuotaExceeded. A
+	// limit of zero is no limit.
+	ChargeUsage(userID int, day time.Time, limit int) (int, error)
+	// Usage returns the requests counted for userID on day.
+	Usage(userID int, day time.Time) int
+
+	// CreateSession records a session for session.UserID, assigning its ID
+	// and CreatedAt.
+	CreateSession(session Session) (Session, error)
+	// ListSessions returns userID's sessions that are not revoked, oldest
+	// first.
+	ListSessions(userID int) []Session
+	// RevokeSession puts session id of userID on the revocation list. It is
+	// errNotFound if userID has no such session, or it is already revoked.
+	RevokeSession(userID, id int) error
+	// SessionRevoked reports whether session id is on the revocation list.
+	SessionRevoked(id int) (bool, error)
+
+	// Snapshot returns the store's entire contents. Usage counts and
+	// sessions are not part of it. Restore leaves usage counts as they are
+	// and revokes every live session, since the users they were issued to
+	// may not survive it, and it never un-revokes one. Password hashes are
+	// not part of it either, nor is two-factor state, and Restore clears
+	// both.
+	Snapshot() State
+	// Restore replaces the store's entire contents with st, whose IDs must
+	// be valid and unique per collection. No events are published.
+	Restore(st State)
+
+	// Info describes the backend for diagnostics.
+	Info() StoreInfo
+	// Ping checks that the backend answers.
+	Ping(ctx context.Context) error
+}
+
+// memoryStore is an in-process Store seeded with the fixture's sample data.
+type memoryStore struct {
+	mu           sync.RWMutex
+	bus          *EventBus
+	clock        Clock
+	users        map[int]User
+	userNames    nameIndex
+	posts        map[int]Post
+	comments     map[int]Comment
+	todos        map[int]Todo
+	albums       map[int]Album
+	photos       map[int]Photo
+	photoData    map[int][]byte
+	places       []Place
+	ingested     map[int]IngestEvent
+	webhooks     map[int]Webhook
+	deliveries   map[int]Delivery
+	tenants      map[int]Tenant
+	flags        flags.Set
+	usage        map[int]dailyUsage
+	passwords    map[int][]byte
+	twoFactor    map[int]TwoFactor
+	sessions     map[int]Session
+	revoked      map[int]bool
+	nextUserID   int
+	nextPostID   int
+	nextTodoID   int
+	nextAlbumID  int
+	nextPhotoID  int
+	nextEventID  int
+	nextHookID   int
+	nextDelivID  int
+	nextTenantID int
+	nextSessID   int
+}
+
+func newMemoryStore(bus *EventBus) *memoryStore {
+	s := &memoryStore{
+		bus:        bus,
+		clock:      systemClock{},
+		users:      make(map[int]User),
+		posts:      make(map[int]Post),
+		comments:   make(map[int]Comment),
+		todos:      make(map[int]Todo),
+		albums:     make(map[int]Album),
+		photos:     make(map[int]Photo),
+		photoData:  make(map[int][]byte),
+		ingested:   make(map[int]IngestEvent),
+		webhooks:   make(map[int]Webhook),
+		deliveries: make(map[int]Delivery),
+		tenants:    make(map[int]Tenant),
+		usage:      make(map[int]dailyUsage),
+		passwords:  make(map[int][]byte),
+		twoFactor:  make(map[int]TwoFactor),
+		sessions:   make(map[int]Session),
+		revoked:    make(map[int]bool),
+		nextSessID: 1,
+	}
+	for _, u := range []User{
+		{ID: 1, Name: "Alice", Email: "alice@example.com", Version: 1, PostCount: 2, CreatedAt: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)},
+		{ID: 2, Name: "Bob", Email: "bob@example.com", Version: 1, CreatedAt: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)},
+	} {
+		u.UpdatedAt = u.CreatedAt
+		s.users[u.ID] = u
+		s.userNames.add(u.Name, u.ID)
+	}
+	for _, p := range []Post{
+		{ID: 1, UserID: 1, Title: "First Post", Slug: "first-post", Body: "Hello world", Version: 1, CreatedAt: time.Date(2024, 1, 1, 9, 15, 0, 0, time.UTC)},
+		{ID: 2, UserID: 1, Title: "Second Post", Slug: "second-post", Body: "Another post", Version: 1, CreatedAt: time.Date(2024, 1, 2, 14, 30, 0, 0, time.UTC)},
+	} {
+		p.UpdatedAt = p.CreatedAt
+		s.posts[p.ID] = p
+	}
+	for _, c := range []Comment{
+		{ID: 1, PostID: 1, UserID: 2, Body: "Welcome aboard!", CreatedAt: time.Date(2024, 1, 1, 10, 5, 0, 0, time.UTC)},
+		{ID: 2, PostID: 1, UserID: 1, Body: "Thanks, Bob.", CreatedAt: time.Date(2024, 1, 1, 10, 20, 0, 0, time.UTC)},
+		{ID: 3, PostID: 2, UserID: 2, Body: "Keep them coming.", CreatedAt: time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)},
+	} {
+		c.UpdatedAt = c.CreatedAt
+		s.comments[c.ID] = c
+	}
+	due := func(v string) *time.Time {
+		t, _ := time.Parse(time.RFC3339, v)
+		return &t
+	}
+	for _, t := range []Todo{
+		{ID: 1, Title: "Write the spec", Completed: true, Priority: PriorityHigh, Due: due("2024-01-10T09:00:00Z"), Version: 1, CreatedAt: time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)},
+		{ID: 2, Title: "Review pull requests", Priority: PriorityMed, Due: due("2024-02-01T17:00:00Z"), Version: 1, CreatedAt: time.Date(2024, 1, 1, 8, 5, 0, 0, time.UTC)},
+		{ID: 3, Title: "Plan the sprint", Priority: PriorityLow, Version: 1, CreatedAt: time.Date(2024, 1, 1, 8, 10, 0, 0, time.UTC)},
+	} {
+		t.UpdatedAt = t.CreatedAt
+		s.todos[t.ID] = t
+	}
+	albumCreated := time.Date(2024, 1, 3, 18, 0, 0, 0, time.UTC)
+	s.albums[1] = Album{ID: 1, UserID: 1, Title: "Vacation", Version: 1, CreatedAt: albumCreated, UpdatedAt: albumCreated}
+	s.photos[1] = Photo{ID: 1, AlbumID: 1, Title: "Sunset", ContentType: "image/png", Width: 64, Height: 48, Version: 1, CreatedAt: albumCreated, UpdatedAt: albumCreated}
+	s.photoData[1] = samplePhoto()
+	s.places = []Place{
+		{ID: 1, Name: "Brandenburg Gate", Lat: 52.5163, Lng: 13.3777},
+		{ID: 2, Name: "Berlin TV Tower", Lat: 52.5208, Lng: 13.4094},
+		{ID: 3, Name: "Checkpoint Charlie", Lat: 52.5075, Lng: 13.3904},
+		{ID: 4, Name: "Eiffel Tower", Lat: 48.8584, Lng: 2.2945},
+		{ID: 5, Name: "Statue of Liberty", Lat: 40.6892, Lng: -74.0445},
+		{ID: 6, Name: "Sydney Opera House", Lat: -33.8568, Lng: 151.2153},
+	}
+	s.nextUserID = len(s.users) + 1
+	s.nextPostID = len(s.posts) + 1
+	s.nextTodoID = len(s.todos) + 1
+	s.nextAlbumID = len(s.albums) + 1
+	s.nextPhotoID = len(s.photos) + 1
+	s.nextEventID = 1
+	s.nextHookID = 1
+	s.nextDelivID = 1
+	s.nextTenantID = 1
+	return s
+}
+
+func (s *memoryStore) setClock(c Clock) { s.clock = c }
+
+// now is the store's clock reading, in UTC.
+func (s *memoryStore) now() time.Time {
+	return s.clock.Now().UTC()
+}
+
+func (s *memoryStore) ListUsers() []User {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	users := make([]User, 0, len(s.users))
+	for _, u := range s.users {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users
+}
+
+func (s *memoryStore) CountUsers() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.users)
+}
+
+func (s *memoryStore) SuggestUsers(prefix string, limit int) []UserSuggestion {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := s.userNames.prefix(prefix, limit)
+	suggestions := make([]UserSuggestion, len(ids))
+	for i, id := range ids {
+		suggestions[i] = UserSuggestion{ID: id, Name: s.users[id].Name}
+	}
+	return suggestions
+}
+
+func (s *memoryStore) GetUser(id int) (User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	u, ok := s.users[id]
+	if !ok {
+		return User{}, errNotFound
+	}
+	return u, nil
+}
+
+// userByEmail returns the user other than exceptID whose email matches,
+// ignoring case. Empty emails never match. Callers must hold s.mu.
+func (s *memoryStore) userByEmail(email string, exceptID int) (User, bool) {
+	if email == "" {
+		return User{}, false
+	}
+	for _, u := range s.users {
+		if u.ID != exceptID && strings.EqualFold(u.Email, email) {
+			return u, true
+		}
+	}
+	return User{}, false
+}
+
+func (s *memoryStore) CreateUser(u User) (User, error) {
+	s.mu.Lock()
+	if existing, ok := s.userByEmail(u.Email, 0); ok {
+		s.mu.Unlock()
+		return existing, errDuplicate
+	}
+	u.ID = s.nextUserID
+	u.Version = 1
+	u.PostCount = 0
+	u.Verified = false
+	u.CreatedAt = s.now()
+	u.UpdatedAt = u.CreatedAt
+	s.nextUserID++
+	s.users[u.ID] = u
+	s.userNames.add(u.Name, u.ID)
+	s.mu.Unlock()
+
+	s.bus.Publish(Event{Type: EventCreated, Resource: "users", ID: u.ID, Data: u})
+	return u, nil
+}
+
+func (s *memoryStore) UpdateUser(u User) (User, error) {
+	s.mu.Lock()
+	current, ok := s.users[u.ID]
+	if !ok {
+		s.mu.Unlock()
+		return User{}, errNotFound
+	}
+	if u.Version != current.Version {
+		s.mu.Unlock()
+		return current, errVersionConflict
+	}
+	if existing, ok := s.userByEmail(u.Email, u.ID); ok {
+		s.mu.Unlock()
+		return existing, errDuplicate
+	}
+	u.Version = current.Version + 1
+	u.PostCount = current.PostCount
+	u.Verified = current.Verified && strings.EqualFold(u.Email, current.Email)
+	u.CreatedAt = current.CreatedAt
+	u.UpdatedAt = s.now()
+	s.users[u.ID] = u
+	s.userNames.remove(current.Name, u.ID)
+	s.userNames.add(u.Name, u.ID)
+	s.mu.Unlock()
+
+	s.bus.Publish(Event{Type: EventUpdated, Resource: "users", ID: u.ID, Data: u})
+	return u, nil
+}
+
+func (s *memoryStore) DeleteUser(id int, unmodifiedSince time.Time) error {
+	s.mu.Lock()
+	u, ok := s.users[id]
+	if !ok {
+		s.mu.Unlock()
+		return errNotFound
+	}
+	if modifiedSince(u.UpdatedAt, unmodifiedSince) {
+		s.mu.Unlock()
+		return errModified
+	}
+	delete(s.users, id)
+	s.userNames.remove(u.Name, id)
+	delete(s.passwords, id)
+	delete(s.twoFactor, id)
+	for sid, session := range s.sessions {
+		if session.UserID == id {
+			delete(s.sessions, sid)
+			s.revoked[sid] = true
+		}
+	}
+	s.mu.Unlock()
+
+	s.bus.Publish(Event{Type: EventDeleted, Resource: "users", ID: id})
+	return nil
+}
+
+func (s *memoryStore) VerifyUser(id int, email string) (User, error) {
+	s.mu.Lock()
+	u, ok := s.users[id]
+	if !ok || u.Email == "" || !strings.EqualFold(u.Email, email) {
+		s.mu.Unlock()
+		return User{}, errNotFound
+	}
+	if u.Verified {
+		s.mu.Unlock()
+		return u, nil
+	}
+	u.Verified = true
+	u.Version++
+	u.UpdatedAt = s.now()
+	s.users[id] = u
+	s.mu.Unlock()
+
+	s.bus.Publish(Event{Type: EventUpdated, Resource: "users", ID: u.ID, Data: u})
+	return u, nil
+}
+
+func (s *memoryStore) SetPasswordHash(id int, hash []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[id]; !ok {
+		return errNotFound
+	}
+	s.passwords[id] = append([]byte(nil), hash...)
+	return nil
+}
+
+func (s *memoryStore) PasswordHash(id int) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, ok := s.users[id]; !ok {
+		return nil, errNotFound
+	}
+	return s.passwords[id], nil
+}
+
+func (s *memoryStore) SetTwoFactor(id int, tf TwoFactor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[id]; !ok {
+		return errNotFound
+	}
+	tf.Secret = append([]byte(nil), tf.Secret...)
+	s.twoFactor[id] = tf
+	return nil
+}
+
+func (s *memoryStore) TwoFactor(id int) (TwoFactor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, ok := s.users[id]; !ok {
+		return TwoFactor{}, errNotFound
+	}
+	return s.twoFactor[id], nil
+}
+
+func (s *memoryStore) ListPosts() []Post {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	posts := make([]Post, 0, len(s.posts))
+	for _, p := range s.posts {
+		posts = append(posts, p)
+	}
+	sort.Slice(posts, func(i, j int) bool { return posts[i].ID < posts[j].ID })
+	return posts
+}
+
+func (s *memoryStore) ListPostsByUser(userID int) []Post {
+	posts := []Post{}
+	for _, p := range s.ListPosts() {
+		if p.UserID == userID {
+			posts = append(posts, p)
+		}
+	}
+	return posts
+}
+
+func (s *memoryStore) GetPostBySlug(slug string) (Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.postBySlug(slug)
+	if !ok {
+		return Post{}, errNotFound
+	}
+	return p, nil
+}
+
+// postBySlug returns the post with slug. Callers must hold s.mu.
+func (s *memoryStore) postBySlug(slug string) (Post, bool) {
+	for _, p := range s.posts {
+		if p.Slug == slug {
+			return p, true
+		}
+	}
+	return Post{}, false
+}
+
+func (s *memoryStore) CountPosts(userID int) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if userID == 0 {
+		return len(s.posts)
+	}
+	n := 0
+	for _, p := range s.posts {
+		if p.UserID == userID {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *memoryStore) GetPost(id int) (Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.posts[id]
+	if !ok {
+		return Post{}, errNotFound
+	}
+	return p, nil
+}
+
+func (s *memoryStore) CreatePost(p Post) (Post, error) {
+	return s.createPost(p, false)
+}
+
+func (s *memoryStore) CreateUserPost(p Post) (Post, error) {
+	return s.createPost(p, true)
+}
+
+// createPost stores p and counts it on its author. With authorRequired a
+// missing author is errNotFound; the checks all come before any change, so
+// a failure leaves the store untouched.
+func (s *memoryStore) createPost(p Post, authorRequired bool) (Post, error) {
+	s.mu.Lock()
+	author, authorExists := s.users[p.UserID]
+	if authorRequired && !authorExists {
+		s.mu.Unlock()
+		return Post{}, errNotFound
+	}
+	if p.Title != "" {
+		for _, existing := range s.posts {
+			if existing.UserID == p.UserID && existing.Title == p.Title {
+				s.mu.Unlock()
+				return existing, errDuplicate
+			}
+		}
+	}
+	if p.Slug == "" {
+		p.Slug = uniqueSlug(slugify(p.Title), func(slug string) bool {
+			_, taken := s.postBySlug(slug)
+			return taken
+		})
+	} else if existing, taken := s.postBySlug(p.Slug); taken {
+		s.mu.Unlock()
+		return existing, errDuplicate
+	}
+	p.ID = s.nextPostID
+	p.Version = 1
+	p.CreatedAt = s.now()
+	p.UpdatedAt = p.CreatedAt
+	s.nextPostID++
+	s.posts[p.ID] = p
+	if authorExists {
+		author.PostCount++
+		s.users[author.ID] = author
+	}
+	s.mu.Unlock()
+
+	s.bus.Publish(Event{Type: EventCreated, Resource: "posts", ID: p.ID, Data: p})
+	return p, nil
+}
+
+func (s *memoryStore) UpdatePost(p Post) (Post, error) {
+	s.mu.Lock()
+	current, ok := s.posts[p.ID]
+	if !ok {
+		s.mu.Unlock()
+		return Post{}, errNotFound
+	}
+	if p.Version != current.Version {
+		s.mu.Unlock()
+		return current, errVersionConflict
+	}
+	p.UserID = current.UserID
+	if p.Slug == "" {
+		p.Slug = current.Slug
+	}
+	for _, existing := range s.posts {
+		if existing.ID != p.ID && (existing.Slug == p.Slug || p.Title != "" && existing.UserID == p.UserID && existing.Title == p.Title) {
+			s.mu.Unlock()
+			return existing, errDuplicate
+		}
+	}
+	p.Version = current.Version + 1
+	p.CreatedAt = current.CreatedAt
+	p.UpdatedAt = s.now()
+	s.posts[p.ID] = p
+	s.mu.Unlock()
+
+	s.bus.Publish(Event{Type: EventUpdated, Resource: "posts", ID: p.ID, Data: p})
+	return p, nil
+}
+
+func (s *memoryStore) DeletePost(id int, unmodifiedSince time.Time) error {
+	s.mu.Lock()
+	p, ok := s.posts[id]
+	if !ok {
+		s.mu.Unlock()
+		return errNotFound
+	}
+	if modifiedSince(p.UpdatedAt, unmodifiedSince) {
+		s.mu.Unlock()
+		return errModified
+	}
+	delete(s.posts, id)
+	if author, ok := s.users[p.UserID]; ok && author.PostCount > 0 {
+		author.PostCount--
+		s.users[author.ID] = author
+	}
+	s.mu.Unlock()
+
+	s.bus.Publish(Event{Type: EventDeleted, Resource: "posts", ID: id})
+	return nil
+}
+
+// restoredTimes returns the creation and update times of an entity being
+// restored. States written before entities carried timestamps lack them:
+// a missing update time is the time of the restore, and a missing creation
+// time the update time.
+func restoredTimes(created, updated, now time.Time) (time.Time, time.Time) {
+	if updated.IsZero() {
+		updated = now
+	}
+	if created.IsZero() {
+		created = updated
+	}
+	return created, updated
+}
+
+// modifiedSince reports whether an entity last updated at updated has
+// changed after since, compared at the one-second resolution of HTTP dates.
+// A zero since never matches.
+func modifiedSince(updated, since time.Time) bool {
+	return !since.IsZero() && updated.Truncate(time.Second).After(since)
+}
+
+func (s *memoryStore) ListTodos() []Todo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	todos := make([]Todo, 0, len(s.todos))
+	for _, t := range s.todos {
+		todos = append(todos, t)
+	}
+	sort.Slice(todos, func(i, j int) bool { return todos[i].ID < todos[j].ID })
+	return todos
+}
+
+func (s *memoryStore) GetTodo(id int) (Todo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, ok := s.todos[id]
+	if !ok {
+		return Todo{}, errNotFound
+	}
+	return t, nil
+}
+
+func (s *memoryStore) CreateTodo(t Todo) (Todo, error) {
+	s.mu.Lock()
+	t.ID = s.nextTodoID
+	t.Version = 1
+	t.CreatedAt = s.now()
+	t.UpdatedAt = t.CreatedAt
+	s.nextTodoID++
+	s.todos[t.ID] = t
+	s.mu.Unlock()
+
+	s.bus.Publish(Event{Type: EventCreated, Resource: "todos", ID: t.ID, Data: t})
+	return t, nil
+}
+
+func (s *memoryStore) ListAlbums() []Album {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	albums := make([]Album, 0, len(s.albums))
+	for _, a := range s.albums {
+		albums = append(albums, a)
+	}
+	sort.Slice(albums, func(i, j int) bool { return albums[i].ID < albums[j].ID })
+	return albums
+}
+
+func (s *memoryStore) GetAlbum(id int) (Album, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	a, ok := s.albums[id]
+	if !ok {
+		return Album{}, errNotFound
+	}
+	return a, nil
+}
+
+func (s *memoryStore) CreateAlbum(a Album) (Album, error) {
+	s.mu.Lock()
+	a.ID = s.nextAlbumID
+	a.Version = 1
+	a.CreatedAt = s.now()
+	a.UpdatedAt = a.CreatedAt
+	s.nextAlbumID++
+	s.albums[a.ID] = a
+	s.mu.Unlock()
+
+	s.bus.Publish(Event{Type: EventCreated, Resource: "albums", ID: a.ID, Data: a})
+	return a, nil
+}
+
+func (s *memoryStore) ListPhotosByAlbum(albumID int) []Photo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	photos := []Photo{}
+	for _, p := range s.photos {
+		if p.AlbumID == albumID {
+			photos = append(photos, p)
+		}
+	}
+	sort.Slice(photos, func(i, j int) bool { return photos[i].ID < photos[j].ID })
+	return photos
+}
+
+func (s *memoryStore) GetPhoto(id int) (Photo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.photos[id]
+	if !ok {
+		return Photo{}, errNotFound
+	}
+	return p, nil
+}
+
+func (s *memoryStore) CreatePhoto(p Photo, data []byte) (Photo, error) {
+	s.mu.Lock()
+	p.ID = s.nextPhotoID
+	p.Version = 1
+	p.CreatedAt = s.now()
+	p.UpdatedAt = p.CreatedAt
+	s.nextPhotoID++
+	s.photos[p.ID] = p
+	s.photoData[p.ID] = data
+	s.mu.Unlock()
+
+	s.bus.Publish(Event{Type: EventCreated, Resource: "photos", ID: p.ID, Data: p})
+	return p, nil
+}
+
+func (s *memoryStore) PhotoData(id int) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	data, ok := s.photoData[id]
+	if !ok {
+		return nil, errNotFound
+	}
+	return data, nil
+}
+
+func (s *memoryStore) GetComment(id int) (Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.comments[id]
+	if !ok {
+		return Comment{}, errNotFound
+	}
+	return c, nil
+}
+
+func (s *memoryStore) ListPlaces() []Place {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Place(nil), s.places...)
+}
+
+func (s *memoryStore) ListIngestEvents() []IngestEvent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	events := make([]IngestEvent, 0, len(s.ingested))
+	for _, e := range s.ingested {
+		events = append(events, e)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	return events
+}
+
+func (s *memoryStore) GetIngestEvent(id int) (IngestEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.ingested[id]
+	if !ok {
+		return IngestEvent{}, errNotFound
+	}
+	return e, nil
+}
+
+func (s *memoryStore) CreateIngestEvent(e IngestEvent) (IngestEvent, error) {
+	s.mu.Lock()
+	e.ID = s.nextEventID
+	e.ReceivedAt = s.now()
+	s.nextEventID++
+	s.ingested[e.ID] = e
+	s.mu.Unlock()
+
+	s.bus.Publish(Event{Type: EventCreated, Resource: "ingest/events", ID: e.ID, Data: e})
+	return e, nil
+}
+
+func (s *memoryStore) ListWebhooks() []Webhook {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	hooks := make([]Webhook, 0, len(s.webhooks))
+	for _, w := range s.webhooks {
+		hooks = append(hooks, w)
+	}
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].ID < hooks[j].ID })
+	return hooks
+}
+
+func (s *memoryStore) GetWebhook(id int) (Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	w, ok := s.webhooks[id]
+	if !ok {
+		return Webhook{}, errNotFound
+	}
+	return w, nil
+}
+
+func (s *memoryStore) CreateWebhook(w Webhook) (Webhook, error) {
+	s.mu.Lock()
+	w.ID = s.nextHookID
+	w.Version = 1
+	s.nextHookID++
+	s.webhooks[w.ID] = w
+	s.mu.Unlock()
+
+	s.bus.Publish(Event{Type: EventCreated, Resource: "webhooks", ID: w.ID, Data: w})
+	return w, nil
+}
+
+func (s *memoryStore) DeleteWebhook(id int) error {
+	s.mu.Lock()
+	if _, ok := s.webhooks[id]; !ok {
+		s.mu.Unlock()
+		return errNotFound
+	}
+	delete(s.webhooks, id)
+	s.mu.Unlock()
+
+	s.bus.Publish(Event{Type: EventDeleted, Resource: "webhooks", ID: id})
+	return nil
+}
+
+func (s *memoryStore) ListTenants() []Tenant {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tenants := make([]Tenant, 0, len(s.tenants))
+	for _, t := range s.tenants {
+		tenants = append(tenants, t)
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].ID < tenants[j].ID })
+	return tenants
+}
+
+func (s *memoryStore) GetTenant(id int) (Tenant, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, ok := s.tenants[id]
+	if !ok {
+		return Tenant{}, errNotFound
+	}
+	return t, nil
+}
+
+func (s *memoryStore) CreateTenant(t Tenant) (Tenant, error) {
+	s.mu.Lock()
+	t.ID = s.nextTenantID
+	t.Version = 1
+	s.nextTenantID++
+	s.tenants[t.ID] = t
+	s.mu.Unlock()
+
+	s.bus.Publish(Event{Type: EventCreated, Resource: "tenants", ID: t.ID, Data: t})
+	return t, nil
+}
+
+func (s *memoryStore) UpdateTenant(t Tenant) (Tenant, error) {
+	s.mu.Lock()
+	current, ok := s.tenants[t.ID]
+	if !ok {
+		s.mu.Unlock()
+		return Tenant{}, errNotFound
+	}
+	if t.Version != current.Version {
+		s.mu.Unlock()
+		return current, errVersionConflict
+	}
+	t.Version = current.Version + 1
+	s.tenants[t.ID] = t
+	s.mu.Unlock()
+
+	s.bus.Publish(Event{Type: EventUpdated, Resource: "tenants", ID: t.ID, Data: t})
+	return t, nil
+}
+
+func (s *memoryStore) DeleteTenant(id int) error {
+	s.mu.Lock()
+	if _, ok := s.tenants[id]; !ok {
+		s.mu.Unlock()
+		return errNotFound
+	}
+	delete(s.tenants, id)
+	s.mu.Unlock()
+
+	s.bus.Publish(Event{Type: EventDeleted, Resource: "tenants", ID: id})
+	return nil
+}
+
+func (s *memoryStore) GetFlags() flags.Set {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.flags
+}
+
+func (s *memoryStore) UpdateFlags(p flags.Patch) flags.Set {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flags = p.Apply(s.flags)
+	return s.flags
+}
+
+// dailyUsage is a user's request count on day. memoryStore keeps only the
+// latest day.
+type dailyUsage struct {
+	day      time.Time
+	requests int
+}
+
+func (s *memoryStore) ChargeUsage(userID int, day time.Time, limit int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.usage[userID]
+	if !u.day.Equal(day) {
+		u = dailyUsage{day: day}
+	}
+	if limit > 0 && u.requests >= limit {
+		return u.requests, errQuotaExceeded
+	}
+	u.requests++
+	s.usage[userID] = u
+	return u.requests, nil
+}
+
+func (s *memoryStore) Usage(userID int, day time.Time) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if u := s.usage[userID]; u.day.Equal(day) {
+		return u.requests
+	}
+	return 0
+}
+
+func (s *memoryStore) CreateSession(session Session) (Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[session.UserID]; !ok {
+		return Session{}, errNotFound
+	}
+	session.ID = s.nextSessID
+	s.nextSessID++
+	session.CreatedAt = s.now()
+	session.Current = false
+	s.sessions[session.ID] = session
+	return session, nil
+}
+
+func (s *memoryStore) ListSessions(userID int) []Session {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sessions := []Session{}
+	for _, session := range s.sessions {
+		if session.UserID == userID {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID < sessions[j].ID })
+	return sessions
+}
+
+func (s *memoryStore) RevokeSession(userID, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if session, ok := s.sessions[id]; !ok || session.UserID != userID {
+		return errNotFound
+	}
+	delete(s.sessions, id)
+	s.revoked[id] = true
+	return nil
+}
+
+func (s *memoryStore) SessionRevoked(id int) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.revoked[id], nil
+}
+
+func (s *memoryStore) EnqueueDelivery(d Delivery) (Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d.ID = s.nextDelivID
+	s.nextDelivID++
+	d.Status = DeliveryPending
+	d.Attempts = 0
+	d.CreatedAt = s.now()
+	d.NextAttemptAt = d.CreatedAt
+	s.deliveries[d.ID] = d
+	return d, nil
+}
+
+func (s *memoryStore) DueDeliveries(now time.Time) []Delivery {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	due := []Delivery{}
+	for _, d := range s.deliveries {
+		if d.Status == DeliveryPending && !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].ID < due[j].ID })
+	return due
+}
+
+func (s *memoryStore) UpdateDelivery(d Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.deliveries[d.ID]; !ok {
+		return errNotFound
+	}
+	s.deliveries[d.ID] = d
+	return nil
+}
+
+func (s *memoryStore) ListDeliveries(webhookID int) []Delivery {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	history := []Delivery{}
+	for _, d := range s.deliveries {
+		if d.WebhookID == webhookID {
+			history = append(history, d)
+		}
+	}
+	sort.Slice(history, func(i, j int) bool { return history[i].ID < history[j].ID })
+	return history
+}
+
+func (s *memoryStore) Snapshot() State {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	st := State{
+		Users:        make([]User, 0, len(s.users)),
+		Posts:        make([]Post, 0, len(s.posts)),
+		Comments:     make([]Comment, 0, len(s.comments)),
+		Todos:        make([]Todo, 0, len(s.todos)),
+		Albums:       make([]Album, 0, len(s.albums)),
+		Photos:       make([]StatePhoto, 0, len(s.photos)),
+		Places:       append([]Place{}, s.places...),
+		IngestEvents: make([]IngestEvent, 0, len(s.ingested)),
+		Webhooks:     make([]Webhook, 0, len(s.webhooks)),
+		Deliveries:   make([]Delivery, 0, len(s.deliveries)),
+		Tenants:      make([]Tenant, 0, len(s.tenants)),
+		Flags:        s.flags,
+		NextIDs: NextIDs{
+			Users:        s.nextUserID,
+			Posts:        s.nextPostID,
+			Todos:        s.nextTodoID,
+			Albums:       s.nextAlbumID,
+			Photos:       s.nextPhotoID,
+			IngestEvents: s.nextEventID,
+			Webhooks:     s.nextHookID,
+			Deliveries:   s.nextDelivID,
+			Tenants:      s.nextTenantID,
+		},
+	}
+	for _, u := range s.users {
+		st.Users = append(st.Users, u)
+	}
+	for _, p := range s.posts {
+		st.Posts = append(st.Posts, p)
+	}
+	for _, c := range s.comments {
+		st.Comments = append(st.Comments, c)
+	}
+	for _, t := range s.todos {
+		st.Todos = append(st.Todos, t)
+	}
+	for _, a := range s.albums {
+		st.Albums = append(st.Albums, a)
+	}
+	for _, p := range s.photos {
+		st.Photos = append(st.Photos, StatePhoto{Photo: p, Data: s.photoData[p.ID]})
+	}
+	for _, e := range s.ingested {
+		st.IngestEvents = append(st.IngestEvents, e)
+	}
+	for _, w := range s.webhooks {
+		st.Webhooks = append(st.Webhooks, w)
+	}
+	for _, d := range s.deliveries {
+		st.Deliveries = append(st.Deliveries, d)
+	}
+	for _, t := range s.tenants {
+		st.Tenants = append(st.Tenants, t)
+	}
+	sort.Slice(st.Users, func(i, j int) bool { return st.Users[i].ID < st.Users[j].ID })
+	sort.Slice(st.Posts, func(i, j int) bool { return st.Posts[i].ID < st.Posts[j].ID })
+	sort.Slice(st.Comments, func(i, j int) bool { return st.Comments[i].ID < st.Comments[j].ID })
+	sort.Slice(st.Todos, func(i, j int) bool { return st.Todos[i].ID < st.Todos[j].ID })
+	sort.Slice(st.Albums, func(i, j int) bool { return st.Albums[i].ID < st.Albums[j].ID })
+	sort.Slice(st.Photos, func(i, j int) bool { return st.Photos[i].ID < st.Photos[j].ID })
+	sort.Slice(st.IngestEvents, func(i, j int) bool { return st.IngestEvents[i].ID < st.IngestEvents[j].ID })
+	sort.Slice(st.Webhooks, func(i, j int) bool { return st.Webhooks[i].ID < st.Webhooks[j].ID })
+	sort.Slice(st.Deliveries, func(i, j int) bool { return st.Deliveries[i].ID < st.Deliveries[j].ID })
+	sort.Slice(st.Tenants, func(i, j int) bool { return st.Tenants[i].ID < st.Tenants[j].ID })
+	return st
+}
+
+func (s *memoryStore) Restore(st State) {
+	// nextID is at least next and past every ID in ids.
+	nextID := func(next int, ids ...int) int {
+		for _, id := range ids {
+			if id >= next {
+				next = id + 1
+			}
+		}
+		return next
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.users = make(map[int]User, len(st.Users))
+	s.userNames = nil
+	s.passwords = make(map[int][]byte)
+	s.twoFactor = make(map[int]TwoFactor)
+	for sid := range s.sessions {
+		s.revoked[sid] = true
+	}
+	s.sessions = make(map[int]Session)
+	var userIDs []int
+	for _, u := range st.Users {
+		u.CreatedAt, u.UpdatedAt = restoredTimes(u.CreatedAt, u.UpdatedAt, now)
+		s.users[u.ID] = u
+		s.userNames.add(u.Name, u.ID)
+		userIDs = append(userIDs, u.ID)
+	}
+	s.posts = make(map[int]Post, len(st.Posts))
+	var postIDs []int
+	for _, p := range withSlugs(st.Posts) {
+		p.CreatedAt, p.UpdatedAt = restoredTimes(p.CreatedAt, p.UpdatedAt, now)
+		s.posts[p.ID] = p
+		postIDs = append(postIDs, p.ID)
+	}
+	s.comments = make(map[int]Comment, len(st.Comments))
+	for _, c := range st.Comments {
+		c.CreatedAt, c.UpdatedAt = restoredTimes(c.CreatedAt, c.UpdatedAt, now)
+		s.comments[c.ID] = c
+	}
+	s.todos = make(map[int]Todo, len(st.Todos))
+	var todoIDs []int
+	for _, t := range st.Todos {
+		t.CreatedAt, t.UpdatedAt = restoredTimes(t.CreatedAt, t.UpdatedAt, now)
+		s.todos[t.ID] = t
+		todoIDs = append(todoIDs, t.ID)
+	}
+	s.albums = make(map[int]Album, len(st.Albums))
+	var albumIDs []int
+	for _, a := range st.Albums {
+		a.CreatedAt, a.UpdatedAt = restoredTimes(a.CreatedAt, a.UpdatedAt, now)
+		s.albums[a.ID] = a
+		albumIDs = append(albumIDs, a.ID)
+	}
+	s.photos = make(map[int]Photo, len(st.Photos))
+	s.photoData = make(map[int][]byte, len(st.Photos))
+	var photoIDs []int
+	for _, p := range st.Photos {
+		p.CreatedAt, p.UpdatedAt = restoredTimes(p.CreatedAt, p.UpdatedAt, now)
+		s.photos[p.ID] = p.Photo
+		s.photoData[p.ID] = p.Data
+		photoIDs = append(photoIDs, p.ID)
+	}
+	s.places = append([]Place(nil), st.Places...)
+	sort.Slice(s.places, func(i, j int) bool { return s.places[i].ID < s.places[j].ID })
+	s.ingested = make(map[int]IngestEvent, len(st.IngestEvents))
+	var eventIDs []int
+	for _, e := range st.IngestEvents {
+		s.ingested[e.ID] = e
+		eventIDs = append(eventIDs, e.ID)
+	}
+	s.webhooks = make(map[int]Webhook, len(st.Webhooks))
+	var hookIDs []int
+	for _, w := range st.Webhooks {
+		s.webhooks[w.ID] = w
+		hookIDs = append(hookIDs, w.ID)
+	}
+	s.deliveries = make(map[int]Delivery, len(st.Deliveries))
+	var delivIDs []int
+	for _, d := range st.Deliveries {
+		s.deliveries[d.ID] = d
+		delivIDs = append(delivIDs, d.ID)
+	}
+	s.tenants = make(map[int]Tenant, len(st.Tenants))
+	var tenantIDs []int
+	for _, t := range st.Tenants {
+		s.tenants[t.ID] = t
+		tenantIDs = append(tenantIDs, t.ID)
+	}
+	s.flags = st.Flags
+
+	s.nextUserID = nextID(max(st.NextIDs.Users, 1), userIDs...)
+	s.nextPostID = nextID(max(st.NextIDs.Posts, 1), postIDs...)
+	s.nextTodoID = nextID(max(st.NextIDs.Todos, 1), todoIDs...)
+	s.nextAlbumID = nextID(max(st.NextIDs.Albums, 1), albumIDs...)
+	s.nextPhotoID = nextID(max(st.NextIDs.Photos, 1), photoIDs...)
+	s.nextEventID = nextID(max(st.NextIDs.IngestEvents, 1), eventIDs...)
+	s.nextHookID = nextID(max(st.NextIDs.Webhooks, 1), hookIDs...)
+	s.nextDelivID = nextID(max(st.NextIDs.Deliveries, 1), delivIDs...)
+	s.nextTenantID = nextID(max(st.NextIDs.Tenants, 1), tenantIDs...)
+}
+
+func (s *memoryStore) Info() StoreInfo {
+	return StoreInfo{Backend: "memory"}
+}
+
+func (s *memoryStore) Ping(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ========== Memory Store Tests ==========
+
+func TestMemoryStore_CreateAssignsIDAndVersion(t *testing.T) {
+	s := newMemoryStore(NewEventBus())
+
+	u1, err := s.CreateUser(User{Name: "Carol"})
+	require.NoError(t, err)
+	u2, err := s.CreateUser(User{Name: "Dave", ID: 99, Version: 7})
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, u1.ID)
+	assert.Equal(t, 4, u2.ID)
+	assert.Equal(t, 1, u2.Version)
+}
+
+func TestMemoryStore_UpdateBumpsVersion(t *testing.T) {
+	s := newMemoryStore(NewEventBus())
+
+	updated, err := s.UpdateUser(User{ID: 1, Name: "Alicia", Version: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 2, updated.Version)
+
+	stored, err := s.GetUser(1)
+	require.NoError(t, err)
+	assert.Equal(t, updated, stored)
+}
+
+func TestMemoryStore_UpdateStaleVersion(t *testing.T) {
+	s := newMemoryStore(NewEventBus())
+
+	_, err := s.UpdateUser(User{ID: 1, Name: "Alicia", Version: 1})
+	require.NoError(t, err)
+
+	current, err := s.UpdateUser(User{ID: 1, Name: "Stale", Version: 1})
+	assert.ErrorIs(t, err, errVersionConflict)
+	assert.Equal(t, "Alicia", current.Name)
+	assert.Equal(t, 2, current.Version)
+}
+
+func TestMemoryStore_NotFound(t *testing.T) {
+	s := newMemoryStore(NewEventBus())
+
+	_, err := s.GetUser(404)
+	assert.ErrorIs(t, err, errNotFound)
+	_, err = s.UpdateUser(User{ID: 404, Version: 1})
+	assert.ErrorIs(t, err, errNotFound)
+	assert.ErrorIs(t, s.DeleteUser(404, time.Time{}), errNotFound)
+	_, err = s.GetPost(404)
+	assert.ErrorIs(t, err, errNotFound)
+}
+
+func TestMemoryStore_DeleteUnmodifiedSince(t *testing.T) {
+	s := newMemoryStore(NewEventBus())
+	updated := time.Date(2024, 3, 1, 12, 0, 0, 500, time.UTC)
+	s.clock = &fakeClock{t: updated}
+	_, err := s.UpdateUser(User{ID: 1, Name: "Alicia", Version: 1})
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, s.DeleteUser(1, updated.Add(-time.Second)), errModified)
+	_, err = s.GetUser(1)
+	assert.NoError(t, err, "a modified user is kept")
+	assert.NoError(t, s.DeleteUser(1, updated.Truncate(time.Second)), "dates compare at second resolution")
+
+	assert.ErrorIs(t, s.DeletePost(1, time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC)), errModified)
+	assert.NoError(t, s.DeletePost(1, time.Time{}))
+	assert.ErrorIs(t, s.DeletePost(1, time.Time{}), errNotFound)
+}
+
+func TestMemoryStore_DuplicateUserEmail(t *testing.T) {
+	s := newMemoryStore(NewEventBus())
+
+	existing, err := s.CreateUser(User{Name: "Alice Again", Email: "ALICE@example.com"})
+	assert.ErrorIs(t, err, errDuplicate)
+	assert.Equal(t, 1, existing.ID)
+
+	// Empty emails never collide.
+	_, err = s.CreateUser(User{Name: "No Email 1"})
+	require.NoError(t, err)
+	_, err = s.CreateUser(User{Name: "No Email 2"})
+	require.NoError(t, err)
+
+	// Updating Bob to Alice's email collides; keeping his own does not.
+	existing, err = s.UpdateUser(User{ID: 2, Email: "alice@example.com", Version: 1})
+	assert.ErrorIs(t, err, errDuplicate)
+	assert.Equal(t, 1, existing.ID)
+	_, err = s.UpdateUser(User{ID: 2, Name: "Robert", Email: "bob@example.com", Version: 1})
+	require.NoError(t, err)
+}
+
+func TestMemoryStore_DuplicatePostTitle(t *testing.T) {
+	s := newMemoryStore(NewEventBus())
+
+	existing, err := s.CreatePost(Post{UserID: 1, Title: "First Post"})
+	assert.ErrorIs(t, err, errDuplicate)
+	assert.Equal(t, 1, existing.ID)
+
+	// Same title for a different user, and untitled posts, are fine.
+	_, err = s.CreatePost(Post{UserID: 2, Title: "First Post"})
+	require.NoError(t, err)
+	_, err = s.CreatePost(Post{UserID: 1})
+	require.NoError(t, err)
+	_, err = s.CreatePost(Post{UserID: 1})
+	require.NoError(t, err)
+}
+
+func TestMemoryStore_PostCount(t *testing.T) {
+	s := newMemoryStore(NewEventBus())
+	postCount := func(id int) int {
+		u, err := s.GetUser(id)
+		require.NoError(t, err)
+		return u.PostCount
+	}
+	require.Equal(t, 2, postCount(1))
+
+	p, err := s.CreateUserPost(Post{UserID: 1, Title: "Third Post"})
+	require.NoError(t, err)
+	assert.Equal(t, 3, postCount(1))
+	_, err = s.CreatePost(Post{UserID: 2, Title: "Hello"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, postCount(2))
+	require.NoError(t, s.DeletePost(p.ID, time.Time{}))
+	assert.Equal(t, 2, postCount(1))
+
+	// Users cannot set the count themselves.
+	u, err := s.UpdateUser(User{ID: 1, Name: "Alice", Version: 1, PostCount: 99})
+	require.NoError(t, err)
+	assert.Equal(t, 2, u.PostCount)
+	u, err = s.CreateUser(User{Name: "Carol", PostCount: 99})
+	require.NoError(t, err)
+	assert.Zero(t, u.PostCount)
+}
+
+func TestMemoryStore_CreateUserPostIsAllOrNothing(t *testing.T) {
+	s := newMemoryStore(NewEventBus())
+	before := s.Snapshot()
+
+	_, err := s.CreateUserPost(Post{UserID: 999, Title: "Orphan"})
+	assert.ErrorIs(t, err, errNotFound)
+	existing, err := s.CreateUserPost(Post{UserID: 1, Title: "First Post"})
+	assert.ErrorIs(t, err, errDuplicate)
+	assert.Equal(t, 1, existing.ID)
+
+	assert.Equal(t, before, s.Snapshot(), "failed writes change nothing")
+}
+
+func TestMemoryStore_PasswordHash(t *testing.T) {
+	s := newMemoryStore(NewEventBus())
+
+	hash, err := s.PasswordHash(1)
+	require.NoError(t, err)
+	assert.Nil(t, hash)
+	require.NoError(t, s.SetPasswordHash(1, []byte("hash")))
+	hash, err = s.PasswordHash(1)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hash"), hash)
+
+	assert.ErrorIs(t, s.SetPasswordHash(999, []byte("hash")), errNotFound)
+	_, err = s.PasswordHash(999)
+	assert.ErrorIs(t, err, errNotFound)
+
+	s.Restore(s.Snapshot())
+	hash, err = s.PasswordHash(1)
+	require.NoError(t, err)
+	assert.Nil(t, hash, "Restore clears passwords")
+}
+
+func TestMemoryStore_TwoFactor(t *testing.T) {
+	s := newMemoryStore(NewEventBus())
+
+	tf, err := s.TwoFactor(1)
+	require.NoError(t, err)
+	assert.Equal(t, TwoFactor{}, tf)
+	want := TwoFactor{Secret: []byte("sealed"), Enabled: true}
+	require.NoError(t, s.SetTwoFactor(1, want))
+	tf, err = s.TwoFactor(1)
+	require.NoError(t, err)
+	assert.Equal(t, want, tf)
+
+	assert.ErrorIs(t, s.SetTwoFactor(999, want), errNotFound)
+	_, err = s.TwoFactor(999)
+	assert.ErrorIs(t, err, errNotFound)
+
+	s.Restore(s.Snapshot())
+	tf, err = s.TwoFactor(1)
+	require.NoError(t, err)
+	assert.Equal(t, TwoFactor{}, tf, "Restore clears two-factor state")
+}
+
+func TestMemoryStore_Sessions(t *testing.T) {
+	s := newMemoryStore(NewEventBus())
+
+	first, err := s.CreateSession(Session{UserID: 1, UserAgent: "phone"})
+	require.NoError(t, err)
+	second, err := s.CreateSession(Session{UserID: 1, UserAgent: "laptop"})
+	require.NoError(t, err)
+	bobs, err := s.CreateSession(Session{UserID: 2})
+	require.NoError(t, err)
+	_, err = s.CreateSession(Session{UserID: 999})
+	assert.ErrorIs(t, err, errNotFound)
+	assert.Equal(t, []Session{first, second}, s.ListSessions(1))
+
+	assert.ErrorIs(t, s.RevokeSession(1, bobs.ID), errNotFound, "another user's")
+	require.NoError(t, s.RevokeSession(1, first.ID))
+	assert.ErrorIs(t, s.RevokeSession(1, first.ID), errNotFound, "already revoked")
+	assert.Equal(t, []Session{second}, s.ListSessions(1))
+	revoked, err := s.SessionRevoked(first.ID)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+	revoked, err = s.SessionRevoked(second.ID)
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	s.Restore(s.Snapshot())
+	revoked, _ = s.SessionRevoked(first.ID)
+	assert.True(t, revoked, "Restore keeps the revocation list")
+
+	require.NoError(t, s.DeleteUser(2, time.Time{}))
+	revoked, _ = s.SessionRevoked(bobs.ID)
+	assert.True(t, revoked, "deleting a user revokes their sessions")
+}
+
+func TestMemoryStore_ChargeUsage(t *testing.T) {
+	s := newMemoryStore(NewEventBus())
+	day := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	for want := 1; want <= 2; want++ {
+		n, err := s.ChargeUsage(1, day, 2)
+		require.NoError(t, err)
+		assert.Equal(t, want, n)
+	}
+	n, err := s.ChargeUsage(1, day, 2)
+	assert.ErrorIs(t, err, errQuotaExceeded)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, 2, s.Usage(1, day))
+	assert.Zero(t, s.Usage(2, day))
+
+	next := day.AddDate(0, 0, 1)
+	assert.Zero(t, s.Usage(1, next))
+	n, err = s.ChargeUsage(1, next, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n, "the count starts again each day")
+}
+
+func TestMemoryStore_PublishesOnlySuccessfulWrites(t *testing.T) {
+	bus := NewEventBus()
+	s := newMemoryStore(bus)
+
+	var got []Event
+	bus.Subscribe(func(e Event) { got = append(got, e) })
+
+	s.CreateUser(User{Name: "Carol"})
+	s.CreateUser(User{Name: "Alice", Email: "alice@example.com"}) // duplicate
+	s.UpdateUser(User{ID: 1, Version: 1})
+	s.UpdateUser(User{ID: 1, Version: 1}) // stale
+	s.DeleteUser(2, time.Time{})
+	s.DeleteUser(2, time.Time{}) // already gone
+	s.CreatePost(Post{Title: "t"})
+
+	require.Len(t, got, 4)
+	assert.Equal(t, []EventType{EventCreated, EventUpdated, EventDeleted, EventCreated},
+		[]EventType{got[0].Type, got[1].Type, got[2].Type, got[3].Type})
+}
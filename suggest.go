@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// UserSuggestion is the projection of a User returned by GET
+// /users/suggest: just enough to show and pick it.
+type UserSuggestion struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// Suggestion limits for GET /users/suggest.
+const (
+	defaultSuggestLimit = 10
+	maxSuggestLimit     = 50
+)
+
+// nameIndex is a prefix index over user names: one entry per user, sorted
+// by lowercased name and then ID, so that the names starting with a
+// prefix are a contiguous run.
+type nameIndex []nameEntry
+
+type nameEntry struct {
+	key string
+	id  int
+}
+
+// search returns the position of key and id, or where they would go.
+func (ix nameIndex) search(key string, id int) int {
+	i, _ := slices.BinarySearchFunc(ix, nameEntry{key, id}, func(e, target nameEntry) int {
+		if c := strings.Compare(e.key, target.key); c != 0 {
+			return c
+		}
+		return e.id - target.id
+	})
+	return i
+}
+
+func (ix *nameIndex) add(name string, id int) {
+	key := strings.ToLower(name)
+	*ix = slices.Insert(*ix, ix.search(key, id), nameEntry{key, id})
+}
+
+func (ix *nameIndex) remove(name string, id int) {
+	key := strings.ToLower(name)
+	if i := ix.search(key, id); i < len(*ix) && (*ix)[i] == (nameEntry{key, id}) {
+		*ix = slices.Delete(*ix, i, i+1)
+	}
+}
+
+// prefix returns the IDs of at most limit names starting with prefix,
+// ignoring case, in index order.
+func (ix nameIndex) prefix(prefix string, limit int) []int {
+	key := strings.ToLower(prefix)
+	ids := []int{}
+	for i := ix.search(key, 0); i < len(ix) && len(ids) < limit && strings.HasPrefix(ix[i].key, key); i++ {
+		ids = append(ids, ix[i].id)
+	}
+	return ids
+}
+
+// parseSuggestQuery reads the required ?q= and the optional ?limit= from
+// r, returning one violation per bad parameter.
+func parseSuggestQuery(r *http.Request) (prefix string, limit int, violations []string) {
+	q := r.URL.Query()
+	prefix, limit = q.Get("q"), defaultSuggestLimit
+	if prefix == "" {
+		violations = append(violations, fmt.Sprintf("query parameter %q: is required", "q"))
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxSuggestLimit {
+			violations = append(violations, fmt.Sprintf("query parameter %q: must be an integer between 1 and %d", "limit", maxSuggestLimit))
+		}
+		limit = n
+	}
+	return prefix, limit, violations
+}
+
+// suggestUsers returns the users whose names start with ?q=, for search
+// as you type. It answers from the store's name index alone.
+func (srv *Server) suggestUsers(w http.ResponseWriter, r *http.Request) {
+	prefix, limit, violations := parseSuggestQuery(r)
+	if len(violations) > 0 {
+		respondProblem(w, r, http.StatusBadRequest, "invalid request", Problem{Violations: violations})
+		return
+	}
+	respondJSON(w, http.StatusOK, srv.requestStore(r).SuggestUsers(prefix, limit))
+}
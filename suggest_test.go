@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getSuggestions(t *testing.T, router http.Handler, query string) []UserSuggestion {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/suggest?"+query, nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var got []UserSuggestion
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	return got
+}
+
+// ========== Name Index Tests ==========
+
+func TestNameIndex(t *testing.T) {
+	var ix nameIndex
+	ix.add("bob", 2)
+	ix.add("Alice", 3)
+	ix.add("alice", 1)
+	ix.add("Alfred", 4)
+
+	assert.Equal(t, []int{4, 1, 3}, ix.prefix("AL", 10), "ordered by lowercased name, then ID")
+	assert.Equal(t, []int{4, 1}, ix.prefix("al", 2))
+	assert.Equal(t, []int{1, 3}, ix.prefix("alice", 10))
+	assert.Empty(t, ix.prefix("alices", 10))
+	assert.Equal(t, []int{4, 1, 3, 2}, ix.prefix("", 10))
+
+	ix.remove("ALICE", 3)
+	ix.remove("Carol", 9)
+	assert.Equal(t, []int{4, 1}, ix.prefix("al", 10))
+}
+
+// ========== Suggestion Endpoint Tests ==========
+
+func TestSuggestUsers(t *testing.T) {
+	router := setupRouter()
+	for _, name := range []string{"Albert", "alan", "Bobby"} {
+		_, err := server.Store.CreateUser(User{Name: name})
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, []UserSuggestion{{ID: 4, Name: "alan"}, {ID: 3, Name: "Albert"}, {ID: 1, Name: "Alice"}},
+		getSuggestions(t, router, "q=al"))
+	assert.Equal(t, []UserSuggestion{{ID: 4, Name: "alan"}}, getSuggestions(t, router, "q=AL&limit=1"))
+	assert.Equal(t, []UserSuggestion{}, getSuggestions(t, router, "q=%25"), "wildcards match literally")
+}
+
+func TestSuggestUsers_FollowsChanges(t *testing.T) {
+	router := setupRouter()
+
+	alice, err := server.Store.GetUser(1)
+	require.NoError(t, err)
+	alice.Name = "Zoe"
+	_, err = server.Store.UpdateUser(alice)
+	require.NoError(t, err)
+	assert.Empty(t, getSuggestions(t, router, "q=ali"))
+	assert.Equal(t, []UserSuggestion{{ID: 1, Name: "Zoe"}}, getSuggestions(t, router, "q=z"))
+
+	require.NoError(t, server.Store.DeleteUser(1, time.Time{}))
+	assert.Empty(t, getSuggestions(t, router, "q=z"))
+
+	server.Store.Restore(State{Users: []User{{ID: 7, Name: "Yara", Version: 1}}})
+	assert.Equal(t, []UserSuggestion{{ID: 7, Name: "Yara"}}, getSuggestions(t, router, "q=y"))
+	assert.Empty(t, getSuggestions(t, router, "q=b"))
+}
+
+func TestSuggestUsers_Validation(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{`query parameter "q": is required`}},
+		{"q=a&limit=0", []string{`query parameter "limit": must be an integer between 1 and 50`}},
+		{"q=a&limit=51", []string{`query parameter "limit": must be an integer between 1 and 50`}},
+		{"limit=x", []string{
+			`query parameter "q": is required`,
+			`query parameter "limit": must be an integer between 1 and 50`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			router := setupRouter()
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/suggest?"+tt.query, nil))
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			var problem Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			assert.Equal(t, tt.want, problem.Violations)
+		})
+	}
+}
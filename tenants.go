@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/api2spec/api2spec-fixture-chi/ctxkit"
+)
+
+// headerTenantID selects the tenant a request is made on behalf of.
+// Requests without it are not subject to any tenant's configuration.
+const headerTenantID = "X-Tenant-ID"
+
+// Tenant is a per-tenant configuration, enforced by tenantMiddleware on
+// every request that names the tenant in X-Tenant-ID.
+type Tenant struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// RateLimit is the number of requests allowed per minute. Zero means
+	// unlimited.
+	RateLimit int `json:"rateLimit"`
+	// Flags toggles tenantFlags for the tenant.
+	Flags map[string]bool `json:"flags,omitempty"`
+	// AllowedOrigins lists the CORS origins the tenant may be called from,
+	// such as "https://app.example.com", or "*" for any. Empty allows none.
+	AllowedOrigins []string `json:"allowedOrigins,omitempty"`
+	Version        int      `json:"version"`
+}
+
+// Tenant flags.
+const (
+	// flagReadOnly rejects every request that is not GET, HEAD or OPTIONS.
+	flagReadOnly = "read_only"
+)
+
+// tenantFlags is the set of flags a Tenant may set.
+var tenantFlags = map[string]bool{
+	flagReadOnly: true,
+}
+
+// tenantKey carries the tenant tenantMiddleware resolved.
+var tenantKey = ctxkit.NewKey[Tenant]("tenant")
+
+// tenantFromContext returns the tenant resolved by tenantMiddleware.
+func tenantFromContext(ctx context.Context) (Tenant, bool) {
+	return tenantKey.From(ctx)
+}
+
+// tenantFlag reports whether the request's tenant has flag set.
+func tenantFlag(ctx context.Context, flag string) bool {
+	t, ok := tenantFromContext(ctx)
+	return ok && t.Flags[flag]
+}
+
+// validate returns one violation per invalid field of t.
+func (t Tenant) validate() []string {
+	var violations []string
+	if strings.TrimSpace(t.Name) == "" {
+		violations = append(violations, "request body /name: is required")
+	}
+	if t.RateLimit < 0 {
+		violations = append(violations, "request body /rateLimit: must not be negative")
+	}
+	for flag := range t.Flags {
+		if !tenantFlags[flag] {
+			violations = append(violations, fmt.Sprintf("request body /flags/%s: unknown flag", flag))
+		}
+	}
+	for i, origin := range t.AllowedOrigins {
+		if !validOrigin(origin) {
+			violations = append(violations, fmt.Sprintf("request body /allowedOrigins/%d: must be \"*\" or a scheme and host", i))
+		}
+	}
+	return violations
+}
+
+// validOrigin reports whether origin is "*" or a bare http(s) origin.
+func validOrigin(origin string) bool {
+	if origin == "*" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" &&
+		u.Path == "" && u.RawQuery == "" && u.Fragment == "" && u.User == nil
+}
+
+// allowsOrigin reports whether t may be called from origin.
+func (t Tenant) allowsOrigin(origin string) bool {
+	return originAllowed(t.AllowedOrigins, origin)
+}
+
+// newTenantMiddleware returns middleware that resolves X-Tenant-ID, stores
+// the tenant in the request context, and enforces its rate limit, allowed
+// origins and flags. CORS preflight requests from allowed origins are
+// answered here.
+func (srv *Server) newTenantMiddleware() func(http.Handler) http.Handler {
+	limiter := newRateLimiter("tenant")
+	limiter.shared, limiter.log = srv.redisClient, srv.logAt
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			v := r.Header.Get(headerTenantID)
+			if v == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			id, err := parseID(v)
+			if err != nil {
+				respondError(w, r, http.StatusBadRequest, "unknown tenant")
+				return
+			}
+			tenant, err := srv.requestStore(r).GetTenant(id)
+			if err != nil {
+				respondError(w, r, http.StatusBadRequest, "unknown tenant")
+				return
+			}
+
+			if !limiter.admit(w, r, strconv.Itoa(tenant.ID), tenant.RateLimit) {
+				return
+			}
+
+			w.Header().Add("Vary", "Origin")
+			if origin := r.Header.Get("Origin"); origin != "" {
+				if !tenant.allowsOrigin(origin) {
+					respondError(w, r, http.StatusForbidden, "origin not allowed")
+					return
+				}
+				if allowCORS(w, r, origin) {
+					return
+				}
+			}
+
+			if tenant.Flags[flagReadOnly] {
+				switch r.Method {
+				case http.MethodGet, http.MethodHead, http.MethodOptions:
+				default:
+					respondError(w, r, http.StatusForbidden, "tenant is read-only")
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r.WithContext(tenantKey.With(r.Context(), tenant)))
+		})
+	}
+}
+
+// requireAdmin rejects requests that do not carry the configured AdminToken
+// as a bearer token. While there is none every admin request is rejected.
+func (srv *Server) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || len(srv.Config.AdminToken) == 0 || subtle.ConstantTimeCompare([]byte(token), srv.Config.AdminToken) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			respondError(w, r, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (srv *Server) listTenants(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, srv.requestStore(r).ListTenants())
+}
+
+func (srv *Server) getTenant(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	tenant, err := srv.requestStore(r).GetTenant(id)
+	if err != nil {
+		respondNotFound(w, r, "tenant", id)
+		return
+	}
+	respondJSON(w, http.StatusOK, tenant)
+}
+
+func (srv *Server) createTenant(w http.ResponseWriter, r *http.Request) {
+	var tenant Tenant
+	if err := decodeJSON(r, &tenant); err != nil {
+		respondDecodeError(w, r, err)
+		return
+	}
+	if violations := tenant.validate(); len(violations) > 0 {
+		respondProblem(w, r, http.StatusBadRequest, "invalid request", Problem{Violations: violations})
+		return
+	}
+	tenant, err := srv.requestStore(r).CreateTenant(tenant)
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+	w.Header().Set("Location", "/tenants/"+strconv.Itoa(tenant.ID))
+	respondJSON(w, http.StatusCreated, tenant)
+}
+
+func (srv *Server) updateTenant(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var tenant Tenant
+	if err := decodeJSON(r, &tenant); err != nil {
+		respondDecodeError(w, r, err)
+		return
+	}
+	if tenant.Version == 0 {
+		respondError(w, r, http.StatusPreconditionRequired, "version required")
+		return
+	}
+	if violations := tenant.validate(); len(violations) > 0 {
+		respondProblem(w, r, http.StatusBadRequest, "invalid request", Problem{Violations: violations})
+		return
+	}
+	tenant.ID = id
+	updated, err := srv.requestStore(r).UpdateTenant(tenant)
+	switch {
+	case errors.Is(err, errVersionConflict):
+		respondJSON(w, http.StatusConflict, updated)
+		return
+	case errors.Is(err, errNotFound):
+		respondNotFound(w, r, "tenant", id)
+		return
+	}
+	respondJSON(w, http.StatusOK, updated)
+}
+
+func (srv *Server) deleteTenant(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	if err := srv.requestStore(r).DeleteTenant(id); err != nil {
+		respondNotFound(w, r, "tenant", id)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupAdminRouter is setupRouter with an admin token configured.
+func setupAdminRouter(t *testing.T) http.Handler {
+	t.Helper()
+	router := setupRouter()
+	server.Config.AdminToken = []byte("admin-secret")
+	return router
+}
+
+func newAdminRequest(method, path, body string) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	return req
+}
+
+// createTestTenant stores tenant directly and returns it with its ID.
+func createTestTenant(t *testing.T, tenant Tenant) Tenant {
+	t.Helper()
+	tenant, err := server.Store.CreateTenant(tenant)
+	require.NoError(t, err)
+	return tenant
+}
+
+func tenantRequest(method, path string, tenantID int) *http.Request {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set(headerTenantID, strconv.Itoa(tenantID))
+	return req
+}
+
+// ========== Admin Auth Tests ==========
+
+func TestRequireAdmin(t *testing.T) {
+	tests := []struct {
+		name           string
+		token          string
+		authorization  string
+		expectedStatus int
+	}{
+		{"valid token", "admin-secret", "Bearer admin-secret", http.StatusOK},
+		{"wrong token", "admin-secret", "Bearer nope", http.StatusUnauthorized},
+		{"missing header", "admin-secret", "", http.StatusUnauthorized},
+		{"basic scheme", "admin-secret", "Basic admin-secret", http.StatusUnauthorized},
+		{"no token configured", "", "Bearer ", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+			server.Config.AdminToken = []byte(tt.token)
+
+			req := httptest.NewRequest(http.MethodGet, "/tenants", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusUnauthorized {
+				assert.Equal(t, `Bearer realm="admin"`, w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+// ========== Tenant CRUD Tests ==========
+
+func TestCreateTenant(t *testing.T) {
+	router := setupAdminRouter(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/tenants", `{"name":"Acme","rateLimit":5,"allowedOrigins":["https://acme.example"]}`))
+
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/tenants/1", w.Header().Get("Location"))
+	var tenant Tenant
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tenant))
+	assert.Equal(t, Tenant{ID: 1, Name: "Acme", RateLimit: 5, AllowedOrigins: []string{"https://acme.example"}, Version: 1}, tenant)
+}
+
+func TestCreateTenant_Invalid(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		violations []string
+	}{
+		{"missing name", `{}`, []string{"request body /name: is required"}},
+		{"negative rate limit", `{"name":"a","rateLimit":-1}`, []string{"request body /rateLimit: must not be negative"}},
+		{"unknown flag", `{"name":"a","flags":{"turbo":true}}`, []string{"request body /flags/turbo: unknown flag"}},
+		{"origin with path", `{"name":"a","allowedOrigins":["*","https://a.example/app"]}`, []string{`request body /allowedOrigins/1: must be "*" or a scheme and host`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupAdminRouter(t)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/tenants", tt.body))
+
+			require.Equal(t, http.StatusBadRequest, w.Code)
+			var p Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+			assert.Equal(t, tt.violations, p.Violations)
+		})
+	}
+}
+
+func TestUpdateTenant(t *testing.T) {
+	router := setupAdminRouter(t)
+	createTestTenant(t, Tenant{Name: "Acme"})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPut, "/tenants/1", `{"name":"Acme Corp","rateLimit":10,"version":1}`))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	tenant, err := server.Store.GetTenant(1)
+	require.NoError(t, err)
+	assert.Equal(t, "Acme Corp", tenant.Name)
+	assert.Equal(t, 10, tenant.RateLimit)
+	assert.Equal(t, 2, tenant.Version)
+}
+
+// ========== Tenant Enforcement Tests ==========
+
+func TestTenantMiddleware_NoHeader(t *testing.T) {
+	router := setupRouter()
+	createTestTenant(t, Tenant{Name: "Acme", RateLimit: 1})
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("X-RateLimit-Limit"))
+	}
+}
+
+func TestTenantMiddleware_UnknownTenant(t *testing.T) {
+	for _, id := range []string{"999", "acme"} {
+		router := setupRouter()
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.Header.Set(headerTenantID, id)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, id)
+		assert.JSONEq(t, `{"error":"unknown tenant"}`, w.Body.String())
+	}
+}
+
+func TestTenantMiddleware_RateLimit(t *testing.T) {
+	router := setupRouter()
+	tenant := createTestTenant(t, Tenant{Name: "Acme", RateLimit: 2})
+	other := createTestTenant(t, Tenant{Name: "Globex", RateLimit: 2})
+
+	for i, remaining := range []string{"1", "0"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, tenantRequest(http.MethodGet, "/users", tenant.ID))
+		require.Equal(t, http.StatusOK, w.Code, "request %d", i)
+		assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+		assert.Equal(t, remaining, w.Header().Get("X-RateLimit-Remaining"))
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, tenantRequest(http.MethodGet, "/users", tenant.ID))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, tenantRequest(http.MethodGet, "/users", other.ID))
+	assert.Equal(t, http.StatusOK, w.Code, "limits are per tenant")
+}
+
+func TestTenantMiddleware_Origins(t *testing.T) {
+	tests := []struct {
+		name           string
+		allowed        []string
+		origin         string
+		expectedStatus int
+		expectedAllow  string
+	}{
+		{"no origin", nil, "", http.StatusOK, ""},
+		{"allowed origin", []string{"https://acme.example"}, "https://acme.example", http.StatusOK, "https://acme.example"},
+		{"wildcard", []string{"*"}, "https://any.example", http.StatusOK, "https://any.example"},
+		{"other origin", []string{"https://acme.example"}, "https://evil.example", http.StatusForbidden, ""},
+		{"none allowed", nil, "https://acme.example", http.StatusForbidden, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+			tenant := createTestTenant(t, Tenant{Name: "Acme", AllowedOrigins: tt.allowed})
+
+			req := tenantRequest(http.MethodGet, "/users", tenant.ID)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, tt.expectedAllow, w.Header().Get("Access-Control-Allow-Origin"))
+		})
+	}
+}
+
+func TestTenantMiddleware_Preflight(t *testing.T) {
+	router := setupRouter()
+	tenant := createTestTenant(t, Tenant{Name: "Acme", AllowedOrigins: []string{"https://acme.example"}})
+
+	req := tenantRequest(http.MethodOptions, "/users", tenant.ID)
+	req.Header.Set("Origin", "https://acme.example")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://acme.example", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), http.MethodPost)
+	assert.Equal(t, "Content-Type", w.Header().Get("Access-Control-Allow-Headers"))
+}
+
+func TestTenantMiddleware_ReadOnly(t *testing.T) {
+	router := setupRouter()
+	tenant := createTestTenant(t, Tenant{Name: "Acme", Flags: map[string]bool{flagReadOnly: true}})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, tenantRequest(http.MethodGet, "/users", tenant.ID))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, tenantRequest(http.MethodDelete, "/users/1", tenant.ID))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.JSONEq(t, `{"error":"tenant is read-only"}`, w.Body.String())
+}
+
+func TestTenantMiddleware_Context(t *testing.T) {
+	router := setupRouter()
+	tenant := createTestTenant(t, Tenant{Name: "Acme", Flags: map[string]bool{flagReadOnly: true}})
+
+	var got Tenant
+	var readOnly bool
+	router.Get("/whoami", func(w http.ResponseWriter, r *http.Request) {
+		got, _ = tenantFromContext(r.Context())
+		readOnly = tenantFlag(r.Context(), flagReadOnly)
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), tenantRequest(http.MethodGet, "/whoami", tenant.ID))
+	assert.Equal(t, tenant, got)
+	assert.True(t, readOnly)
+}
@@ -0,0 +1,496 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/api2spec/api2spec-fixture-chi/ctxkit"
+	"github.com/api2spec/api2spec-fixture-chi/flags"
+)
+
+// timingPhases are the phases every Server-Timing header reports, in
+// order: decoding the request body, calling the store and encoding the
+// response body.
+var timingPhases = []string{"decode", "store", "encode"}
+
+// phaseTimer accumulates the time a request spends in each phase. Its
+// methods may be called on a nil *phaseTimer, which times nothing.
+type phaseTimer struct {
+	mu     sync.Mutex
+	start  time.Time
+	phases map[string]time.Duration
+	// handlerStart and budget are set by latencyBudget once the request
+	// is routed.
+	handlerStart time.Time
+	budget       time.Duration
+}
+
+func newPhaseTimer() *phaseTimer {
+	return &phaseTimer{start: time.Now(), phases: map[string]time.Duration{}}
+}
+
+// time starts timing phase and returns the function that stops it:
+//
+//	defer t.time("store")()
+func (t *phaseTimer) time(phase string) func() {
+	if t == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		t.mu.Lock()
+		t.phases[phase] += time.Since(start)
+		t.mu.Unlock()
+	}
+}
+
+// routed records that the route's handler started, with its latency
+// budget.
+func (t *phaseTimer) routed(budget time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.handlerStart, t.budget = time.Now(), budget
+	t.mu.Unlock()
+}
+
+// header formats the Server-Timing header: each of timingPhases, then, for
+// routed requests, the handler's time so far and its budget, and the total
+// time so far.
+func (t *phaseTimer) header() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entries := make([]string, 0, len(timingPhases)+2)
+	for _, phase := range timingPhases {
+		entries = append(entries, serverTimingEntry(phase, t.phases[phase], ""))
+	}
+	if !t.handlerStart.IsZero() {
+		entries = append(entries, serverTimingEntry("handler", time.Since(t.handlerStart), fmt.Sprintf("budget %s", t.budget)))
+	}
+	entries = append(entries, serverTimingEntry("total", time.Since(t.start), ""))
+	return strings.Join(entries, ", ")
+}
+
+// serverTimingEntry formats one Server-Timing metric, with d in
+// milliseconds.
+func serverTimingEntry(name string, d time.Duration, desc string) string {
+	entry := fmt.Sprintf("%s;dur=%.3f", name, float64(d)/float64(time.Millisecond))
+	if desc != "" {
+		entry += fmt.Sprintf(";desc=%q", desc)
+	}
+	return entry
+}
+
+// phaseTimerKey carries the request's timer.
+var phaseTimerKey = ctxkit.NewKey[*phaseTimer]("phaseTimer")
+
+// phaseTimerFrom returns the request's timer, or nil outside
+// withServerTiming.
+func phaseTimerFrom(ctx context.Context) *phaseTimer {
+	t, _ := phaseTimerKey.From(ctx)
+	return t
+}
+
+// withServerTiming times the request's phases and reports them in a
+// Server-Timing header when the response's headers are written.
+func withServerTiming(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t := newPhaseTimer()
+		next.ServeHTTP(&serverTimingWriter{ResponseWriter: w, t: t}, r.WithContext(phaseTimerKey.With(r.Context(), t)))
+	})
+}
+
+// serverTimingWriter sets the Server-Timing header when the response's
+// headers are written.
+type serverTimingWriter struct {
+	http.ResponseWriter
+	t           *phaseTimer
+	wroteHeader bool
+}
+
+func (w *serverTimingWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.Header().Set("Server-Timing", w.t.header())
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *serverTimingWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *serverTimingWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *serverTimingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// timedWriter hands a route's handler the request's timer along with the
+// ResponseWriter, so that writeJSON can time encoding.
+type timedWriter struct {
+	http.ResponseWriter
+	t *phaseTimer
+}
+
+func (w timedWriter) phaseTimer() *phaseTimer { return w.t }
+
+func (w timedWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w timedWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// writerPhaseTimer returns the timer carried by w, or by a writer it
+// wraps, if any.
+func writerPhaseTimer(w http.ResponseWriter) *phaseTimer {
+	for {
+		if tw, ok := w.(interface{ phaseTimer() *phaseTimer }); ok {
+			return tw.phaseTimer()
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return nil
+		}
+		w = u.Unwrap()
+	}
+}
+
+// requestStore returns the store for r's handler, timing every call as
+// r's store phase and reporting cached reads to degradedMode.
+func (srv *Server) requestStore(r *http.Request) Store {
+	s := srv.Store
+	if bs, ok := s.(*breakerStore); ok {
+		if stale, ok := staleReadKey.From(r.Context()); ok {
+			s = bs.reporting(stale)
+		}
+	}
+	t := phaseTimerFrom(r.Context())
+	if t == nil {
+		return s
+	}
+	return timedStore{next: s, t: t}
+}
+
+// timedStore adds the duration of every call to next to t's store phase.
+type timedStore struct {
+	next Store
+	t    *phaseTimer
+}
+
+func (s timedStore) ListUsers() []User {
+	defer s.t.time("store")()
+	return s.next.ListUsers()
+}
+
+func (s timedStore) CountUsers() int {
+	defer s.t.time("store")()
+	return s.next.CountUsers()
+}
+
+func (s timedStore) SuggestUsers(prefix string, limit int) []UserSuggestion {
+	defer s.t.time("store")()
+	return s.next.SuggestUsers(prefix, limit)
+}
+
+func (s timedStore) GetUser(id int) (User, error) {
+	defer s.t.time("store")()
+	return s.next.GetUser(id)
+}
+
+func (s timedStore) CreateUser(u User) (User, error) {
+	defer s.t.time("store")()
+	return s.next.CreateUser(u)
+}
+
+func (s timedStore) UpdateUser(u User) (User, error) {
+	defer s.t.time("store")()
+	return s.next.UpdateUser(u)
+}
+
+func (s timedStore) DeleteUser(id int, unmodifiedSince time.Time) error {
+	defer s.t.time("store")()
+	return s.next.DeleteUser(id, unmodifiedSince)
+}
+
+func (s timedStore) ListPosts() []Post {
+	defer s.t.time("store")()
+	return s.next.ListPosts()
+}
+
+func (s timedStore) GetPostBySlug(slug string) (Post, error) {
+	defer s.t.time("store")()
+	return s.next.GetPostBySlug(slug)
+}
+
+func (s timedStore) CountPosts(userID int) int {
+	defer s.t.time("store")()
+	return s.next.CountPosts(userID)
+}
+
+func (s timedStore) ListPostsByUser(userID int) []Post {
+	defer s.t.time("store")()
+	return s.next.ListPostsByUser(userID)
+}
+
+func (s timedStore) GetPost(id int) (Post, error) {
+	defer s.t.time("store")()
+	return s.next.GetPost(id)
+}
+
+func (s timedStore) CreatePost(p Post) (Post, error) {
+	defer s.t.time("store")()
+	return s.next.CreatePost(p)
+}
+
+func (s timedStore) CreateUserPost(p Post) (Post, error) {
+	defer s.t.time("store")()
+	return s.next.CreateUserPost(p)
+}
+
+func (s timedStore) UpdatePost(p Post) (Post, error) {
+	defer s.t.time("store")()
+	return s.next.UpdatePost(p)
+}
+
+func (s timedStore) DeletePost(id int, unmodifiedSince time.Time) error {
+	defer s.t.time("store")()
+	return s.next.DeletePost(id, unmodifiedSince)
+}
+
+func (s timedStore) ListTodos() []Todo {
+	defer s.t.time("store")()
+	return s.next.ListTodos()
+}
+
+func (s timedStore) GetTodo(id int) (Todo, error) {
+	defer s.t.time("store")()
+	return s.next.GetTodo(id)
+}
+
+func (s timedStore) CreateTodo(t Todo) (Todo, error) {
+	defer s.t.time("store")()
+	return s.next.CreateTodo(t)
+}
+
+func (s timedStore) ListAlbums() []Album {
+	defer s.t.time("store")()
+	return s.next.ListAlbums()
+}
+
+func (s timedStore) GetAlbum(id int) (Album, error) {
+	defer s.t.time("store")()
+	return s.next.GetAlbum(id)
+}
+
+func (s timedStore) CreateAlbum(a Album) (Album, error) {
+	defer s.t.time("store")()
+	return s.next.CreateAlbum(a)
+}
+
+func (s timedStore) ListPhotosByAlbum(albumID int) []Photo {
+	defer s.t.time("store")()
+	return s.next.ListPhotosByAlbum(albumID)
+}
+
+func (s timedStore) GetPhoto(id int) (Photo, error) {
+	defer s.t.time("store")()
+	return s.next.GetPhoto(id)
+}
+
+func (s timedStore) CreatePhoto(p Photo, data []byte) (Photo, error) {
+	defer s.t.time("store")()
+	return s.next.CreatePhoto(p, data)
+}
+
+func (s timedStore) PhotoData(id int) ([]byte, error) {
+	defer s.t.time("store")()
+	return s.next.PhotoData(id)
+}
+
+func (s timedStore) GetComment(id int) (Comment, error) {
+	defer s.t.time("store")()
+	return s.next.GetComment(id)
+}
+
+func (s timedStore) ListPlaces() []Place {
+	defer s.t.time("store")()
+	return s.next.ListPlaces()
+}
+
+func (s timedStore) ListIngestEvents() []IngestEvent {
+	defer s.t.time("store")()
+	return s.next.ListIngestEvents()
+}
+
+func (s timedStore) GetIngestEvent(id int) (IngestEvent, error) {
+	defer s.t.time("store")()
+	return s.next.GetIngestEvent(id)
+}
+
+func (s timedStore) CreateIngestEvent(e IngestEvent) (IngestEvent, error) {
+	defer s.t.time("store")()
+	return s.next.CreateIngestEvent(e)
+}
+
+func (s timedStore) ListWebhooks() []Webhook {
+	defer s.t.time("store")()
+	return s.next.ListWebhooks()
+}
+
+func (s timedStore) GetWebhook(id int) (Webhook, error) {
+	defer s.t.time("store")()
+	return s.next.GetWebhook(id)
+}
+
+func (s timedStore) CreateWebhook(w Webhook) (Webhook, error) {
+	defer s.t.time("store")()
+	return s.next.CreateWebhook(w)
+}
+
+func (s timedStore) DeleteWebhook(id int) error {
+	defer s.t.time("store")()
+	return s.next.DeleteWebhook(id)
+}
+
+func (s timedStore) ListTenants() []Tenant {
+	defer s.t.time("store")()
+	return s.next.ListTenants()
+}
+
+func (s timedStore) GetTenant(id int) (Tenant, error) {
+	defer s.t.time("store")()
+	return s.next.GetTenant(id)
+}
+
+func (s timedStore) CreateTenant(t Tenant) (Tenant, error) {
+	defer s.t.time("store")()
+	return s.next.CreateTenant(t)
+}
+
+func (s timedStore) UpdateTenant(t Tenant) (Tenant, error) {
+	defer s.t.time("store")()
+	return s.next.UpdateTenant(t)
+}
+
+func (s timedStore) DeleteTenant(id int) error {
+	defer s.t.time("store")()
+	return s.next.DeleteTenant(id)
+}
+
+func (s timedStore) GetFlags() flags.Set {
+	defer s.t.time("store")()
+	return s.next.GetFlags()
+}
+
+func (s timedStore) UpdateFlags(p flags.Patch) flags.Set {
+	defer s.t.time("store")()
+	return s.next.UpdateFlags(p)
+}
+
+func (s timedStore) EnqueueDelivery(d Delivery) (Delivery, error) {
+	defer s.t.time("store")()
+	return s.next.EnqueueDelivery(d)
+}
+
+func (s timedStore) DueDeliveries(now time.Time) []Delivery {
+	defer s.t.time("store")()
+	return s.next.DueDeliveries(now)
+}
+
+func (s timedStore) UpdateDelivery(d Delivery) error {
+	defer s.t.time("store")()
+	return s.next.UpdateDelivery(d)
+}
+
+func (s timedStore) ListDeliveries(webhookID int) []Delivery {
+	defer s.t.time("store")()
+	return s.next.ListDeliveries(webhookID)
+}
+
+func (s timedStore) VerifyUser(id int, email string) (User, error) {
+	defer s.t.time("store")()
+	return s.next.VerifyUser(id, email)
+}
+
+func (s timedStore) SetPasswordHash(id int, hash []byte) error {
+	defer s.t.time("store")()
+	return s.next.SetPasswordHash(id, hash)
+}
+
+func (s timedStore) PasswordHash(id int) ([]byte, error) {
+	defer s.t.time("store")()
+	return s.next.PasswordHash(id)
+}
+
+func (s timedStore) CreateSession(session Session) (Session, error) {
+	defer s.t.time("store")()
+	return s.next.CreateSession(session)
+}
+
+func (s timedStore) ListSessions(userID int) []Session {
+	defer s.t.time("store")()
+	return s.next.ListSessions(userID)
+}
+
+func (s timedStore) RevokeSession(userID, id int) error {
+	defer s.t.time("store")()
+	return s.next.RevokeSession(userID, id)
+}
+
+func (s timedStore) SessionRevoked(id int) (bool, error) {
+	defer s.t.time("store")()
+	return s.next.SessionRevoked(id)
+}
+
+func (s timedStore) SetTwoFactor(id int, tf TwoFactor) error {
+	defer s.t.time("store")()
+	return s.next.SetTwoFactor(id, tf)
+}
+
+func (s timedStore) TwoFactor(id int) (TwoFactor, error) {
+	defer s.t.time("store")()
+	return s.next.TwoFactor(id)
+}
+
+func (s timedStore) ChargeUsage(userID int, day time.Time, limit int) (int, error) {
+	defer s.t.time("store")()
+	return s.next.ChargeUsage(userID, day, limit)
+}
+
+func (s timedStore) Usage(userID int, day time.Time) int {
+	defer s.t.time("store")()
+	return s.next.Usage(userID, day)
+}
+
+func (s timedStore) Snapshot() State {
+	defer s.t.time("store")()
+	return s.next.Snapshot()
+}
+
+func (s timedStore) Restore(st State) {
+	defer s.t.time("store")()
+	s.next.Restore(st)
+}
+
+func (s timedStore) Info() StoreInfo {
+	defer s.t.time("store")()
+	return s.next.Info()
+}
+
+func (s timedStore) Ping(ctx context.Context) error {
+	defer s.t.time("store")()
+	return s.next.Ping(ctx)
+}
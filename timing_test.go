@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var serverTimingRE = regexp.MustCompile(`^(\w+);dur=(\d+\.\d{3})(?:;desc="([^"]*)")?$`)
+
+// parseServerTiming returns the durations in a Server-Timing header by
+// metric name, in order, along with each metric's description.
+func parseServerTiming(t *testing.T, header string) (names []string, durs map[string]time.Duration, descs map[string]string) {
+	t.Helper()
+	durs, descs = map[string]time.Duration{}, map[string]string{}
+	for _, entry := range strings.Split(header, ", ") {
+		m := serverTimingRE.FindStringSubmatch(entry)
+		require.NotNil(t, m, "malformed Server-Timing entry %q", entry)
+		ms, err := strconv.ParseFloat(m[2], 64)
+		require.NoError(t, err)
+		names = append(names, m[1])
+		durs[m[1]] = time.Duration(ms * float64(time.Millisecond))
+		descs[m[1]] = m[3]
+	}
+	return names, durs, descs
+}
+
+// ========== Server-Timing Tests ==========
+
+func TestServerTiming_Phases(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		routed bool
+	}{
+		{"read", http.MethodGet, "/users/1", "", http.StatusOK, true},
+		{"write", http.MethodPost, "/users", `{"name":"Timed","email":"timed@example.com"}`, http.StatusCreated, true},
+		{"bad body", http.MethodPost, "/users", `{"name":`, http.StatusBadRequest, true},
+		{"ops route", http.MethodGet, "/health", "", http.StatusOK, true},
+		{"unrouted", http.MethodGet, "/nowhere", "", http.StatusNotFound, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			require.Equal(t, tt.status, w.Code, w.Body.String())
+
+			names, durs, descs := parseServerTiming(t, w.Header().Get("Server-Timing"))
+			want := []string{"decode", "store", "encode", "total"}
+			if tt.routed {
+				want = []string{"decode", "store", "encode", "handler", "total"}
+				assert.Equal(t, "budget 250ms", descs["handler"])
+			}
+			assert.Equal(t, want, names)
+			for _, phase := range timingPhases {
+				assert.LessOrEqual(t, durs[phase], durs["total"], phase)
+			}
+		})
+	}
+}
+
+func TestRequestStore(t *testing.T) {
+	setupRouter()
+	assert.Equal(t, server.Store, server.requestStore(httptest.NewRequest(http.MethodGet, "/", nil)),
+		"requests without a timer use the store directly")
+
+	tm := newPhaseTimer()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(phaseTimerKey.With(req.Context(), tm))
+	user, err := server.requestStore(req).GetUser(1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, user.ID)
+	assert.Positive(t, tm.phases["store"])
+	assert.Zero(t, tm.phases["decode"])
+}
+
+func TestPhaseTimer_Nil(t *testing.T) {
+	var tm *phaseTimer
+	assert.NotPanics(t, func() {
+		tm.time("store")()
+		tm.routed(time.Second)
+	})
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Priority ranks a todo.
+type Priority string
+
+const (
+	PriorityLow  Priority = "low"
+	PriorityMed  Priority = "med"
+	PriorityHigh Priority = "high"
+)
+
+// valid reports whether p is one of the defined priorities.
+func (p Priority) valid() bool {
+	switch p {
+	case PriorityLow, PriorityMed, PriorityHigh:
+		return true
+	}
+	return false
+}
+
+type Todo struct {
+	ID        int        `json:"id"`
+	Title     string     `json:"title"`
+	Completed bool       `json:"completed"`
+	Priority  Priority   `json:"priority"`
+	Due       *time.Time `json:"due,omitempty"`
+	Version   int        `json:"version"`
+	// CreatedAt and UpdatedAt are set by the store and ignored on writes.
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// todoFilter is the parsed query of GET /todos. Nil or empty fields do not
+// filter.
+type todoFilter struct {
+	Completed *bool
+	DueBefore *time.Time
+	DueAfter  *time.Time
+	Priority  Priority
+}
+
+// parseTodoFilter reads ?completed=, ?due_before=, ?due_after=, and
+// ?priority= from r. It returns one violation per malformed parameter.
+func parseTodoFilter(r *http.Request) (todoFilter, []string) {
+	var f todoFilter
+	var violations []string
+	q := r.URL.Query()
+
+	if v := q.Get("completed"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			violations = append(violations, `query parameter "completed": must be true or false`)
+		} else {
+			f.Completed = &b
+		}
+	}
+	for _, p := range []struct {
+		name string
+		dst  **time.Time
+	}{
+		{"due_before", &f.DueBefore},
+		{"due_after", &f.DueAfter},
+	} {
+		if v := q.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				violations = append(violations, fmt.Sprintf("query parameter %q: must be an RFC 3339 date-time", p.name))
+			} else {
+				*p.dst = &t
+			}
+		}
+	}
+	if f.DueBefore != nil && f.DueAfter != nil && f.DueAfter.After(*f.DueBefore) {
+		violations = append(violations, `query parameter "due_after": must not be later than due_before`)
+	}
+	if v := q.Get("priority"); v != "" {
+		if !Priority(v).valid() {
+			violations = append(violations, `query parameter "priority": must be one of low, med, high`)
+		} else {
+			f.Priority = Priority(v)
+		}
+	}
+	return f, violations
+}
+
+// match reports whether t passes every filter. Todos without a due date
+// never match a due_before or due_after filter.
+func (f todoFilter) match(t Todo) bool {
+	if f.Completed != nil && t.Completed != *f.Completed {
+		return false
+	}
+	if f.Priority != "" && t.Priority != f.Priority {
+		return false
+	}
+	if f.DueBefore != nil && (t.Due == nil || !t.Due.Before(*f.DueBefore)) {
+		return false
+	}
+	if f.DueAfter != nil && (t.Due == nil || !t.Due.After(*f.DueAfter)) {
+		return false
+	}
+	return true
+}
+
+func (srv *Server) listTodos(w http.ResponseWriter, r *http.Request) {
+	filter, violations := parseTodoFilter(r)
+	if len(violations) > 0 {
+		respondProblem(w, r, http.StatusBadRequest, "invalid filter", Problem{Violations: violations})
+		return
+	}
+
+	todos := []Todo{}
+	for _, t := range srv.requestStore(r).ListTodos() {
+		if filter.match(t) {
+			todos = append(todos, t)
+		}
+	}
+	respondList(w, r, todos, srv.Config.PageEnvelope)
+}
+
+// checkTodo defaults a todo's priority to med and checks it.
+func checkTodo(t *Todo) []string {
+	if t.Priority == "" {
+		t.Priority = PriorityMed
+	}
+	if !t.Priority.valid() {
+		return []string{"request body /priority: must be one of low, med, high"}
+	}
+	return nil
+}
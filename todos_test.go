@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func todoIDs(t *testing.T, w *httptest.ResponseRecorder) []int {
+	t.Helper()
+	var todos []Todo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &todos))
+	ids := []int{}
+	for _, todo := range todos {
+		ids = append(ids, todo.ID)
+	}
+	return ids
+}
+
+// ========== Todo Filter Tests ==========
+
+func TestListTodos_Filters(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected []int
+	}{
+		{"no filter", "", []int{1, 2, 3}},
+		{"completed", "completed=true", []int{1}},
+		{"not completed", "completed=false", []int{2, 3}},
+		{"priority", "priority=low", []int{3}},
+		{"due before", "due_before=2024-01-15T00:00:00Z", []int{1}},
+		{"due before is exclusive", "due_before=2024-01-10T09:00:00Z", []int{}},
+		{"due after", "due_after=2024-01-15T00:00:00Z", []int{2}},
+		{"due after with offset", "due_after=2024-02-01T18:00:00%2B02:00", []int{2}},
+		{"due window", "due_after=2024-01-01T00:00:00Z&due_before=2024-12-31T00:00:00Z", []int{1, 2}},
+		{"combined", "completed=false&priority=med", []int{2}},
+		{"no match", "completed=true&priority=low", []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+			req := httptest.NewRequest(http.MethodGet, "/todos?"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusOK, w.Code)
+			assertJSONContentType(t, w)
+			assert.Equal(t, tt.expected, todoIDs(t, w))
+		})
+	}
+}
+
+func TestListTodos_InvalidFilters(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		violations []string
+	}{
+		{"completed", "completed=maybe", []string{`query parameter "completed": must be true or false`}},
+		{"due before", "due_before=yesterday", []string{`query parameter "due_before": must be an RFC 3339 date-time`}},
+		{"date only", "due_after=2024-01-01", []string{`query parameter "due_after": must be an RFC 3339 date-time`}},
+		{"inverted window", "due_after=2024-02-01T00:00:00Z&due_before=2024-01-01T00:00:00Z", []string{`query parameter "due_after": must not be later than due_before`}},
+		{"priority", "priority=urgent", []string{`query parameter "priority": must be one of low, med, high`}},
+		{"several", "completed=2&priority=MED", []string{
+			`query parameter "completed": must be true or false`,
+			`query parameter "priority": must be one of low, med, high`,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+			req := httptest.NewRequest(http.MethodGet, "/todos?"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+
+			var problem Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			assert.Equal(t, "invalid filter", problem.Title)
+			assert.Equal(t, tt.violations, problem.Violations)
+		})
+	}
+}
+
+// ========== Todo CRUD Tests ==========
+
+func TestCreateTodo_Success(t *testing.T) {
+	router := setupRouter()
+
+	body := `{"title":"Ship it","priority":"high","due":"2024-06-01T12:00:00Z"}`
+	req := httptest.NewRequest(http.MethodPost, "/todos", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/todos/4", w.Header().Get("Location"))
+
+	var todo Todo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &todo))
+	assert.Equal(t, 4, todo.ID)
+	assert.Equal(t, PriorityHigh, todo.Priority)
+	require.NotNil(t, todo.Due)
+	assert.Equal(t, "2024-06-01T12:00:00Z", todo.Due.Format("2006-01-02T15:04:05Z07:00"))
+	assert.Equal(t, 1, todo.Version)
+}
+
+func TestCreateTodo_DefaultsPriority(t *testing.T) {
+	router := setupRouter()
+
+	req := httptest.NewRequest(http.MethodPost, "/todos", strings.NewReader(`{"title":"Someday"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusCreated, w.Code)
+	var todo Todo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &todo))
+	assert.Equal(t, PriorityMed, todo.Priority)
+	assert.Nil(t, todo.Due)
+	assert.NotContains(t, w.Body.String(), `"due"`)
+}
+
+func TestCreateTodo_InvalidPriority(t *testing.T) {
+	router := setupRouter()
+
+	req := httptest.NewRequest(http.MethodPost, "/todos", strings.NewReader(`{"title":"Now","priority":"urgent"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var problem Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, []string{"request body /priority: must be one of low, med, high"}, problem.Violations)
+}
+
+func TestCreateTodo_InvalidDue(t *testing.T) {
+	router := setupRouter()
+
+	req := httptest.NewRequest(http.MethodPost, "/todos", strings.NewReader(`{"title":"Now","due":"tomorrow"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetTodo(t *testing.T) {
+	router := setupRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/todos/1", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var todo Todo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &todo))
+	assert.Equal(t, "Write the spec", todo.Title)
+	assert.True(t, todo.Completed)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/todos/999", nil))
+	assertNotFoundProblem(t, w, "/todos/999")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/todos/abc", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
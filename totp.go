@@ -0,0 +1,244 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// TOTP codes are RFC 6238's defaults, which is what authenticator apps
+// assume: HMAC-SHA1, six digits, a new code every 30 seconds. A code is
+// accepted for totpSkew periods either side of its own, for clock drift.
+const (
+	totpDigits     = 6
+	totpPeriod     = 30 * time.Second
+	totpSkew       = 1
+	totpSecretSize = 20
+	totpIssuer     = "api2spec-fixture-chi"
+)
+
+// TwoFactor is a user's two-factor state. Secret is the TOTP secret sealed
+// by sealTOTPSecret, nil until the user sets it up; Enabled is set once
+// they confirm it with a code, and from then on logging in needs one.
+type TwoFactor struct {
+	Secret  []byte
+	Enabled bool
+}
+
+// TwoFactorSetup is the body of POST /auth/2fa/setup.
+type TwoFactorSetup struct {
+	// Secret is the base32 secret, for entering by hand.
+	Secret string `json:"secret" redact:"secret"`
+	URI    string `json:"uri" redact:"secret"`
+	// QRCode is a PNG of URI, for scanning.
+	QRCode []byte `json:"qrCode" redact:"secret"`
+}
+
+// TwoFactorCode is the body of POST /auth/2fa/verify.
+type TwoFactorCode struct {
+	Code string `json:"code" redact:"secret"`
+}
+
+// totpEncoding is how secrets are written in otpauth URIs.
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// totpCode returns the code for secret in the period counter.
+func totpCode(secret []byte, counter int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	n := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, n%1000000)
+}
+
+// checkTOTP reports whether code is secret's code at now, give or take
+// totpSkew periods.
+func checkTOTP(secret []byte, code string, now time.Time) bool {
+	counter := now.Unix() / int64(totpPeriod/time.Second)
+	for skew := int64(-totpSkew); skew <= totpSkew; skew++ {
+		if subtle.ConstantTimeCompare([]byte(totpCode(secret, counter+skew)), []byte(code)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// totpAEAD returns the cipher TOTP secrets are sealed with, AES-256-GCM
+// under a key derived from AUTH_SECRET. Changing AUTH_SECRET therefore
+// makes every stored secret unreadable; twoFactor clears those, so their
+// users log in without a code until they set two-factor up again.
+func (srv *Server) totpAEAD() (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, srv.Config.AuthSecret)
+	mac.Write([]byte("totp-secret-key"))
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealTOTPSecret encrypts secret for the store: a random nonce followed by
+// the ciphertext.
+func (srv *Server) sealTOTPSecret(secret []byte) ([]byte, error) {
+	aead, err := srv.totpAEAD()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, secret, nil), nil
+}
+
+// openTOTPSecret decrypts a secret sealed by sealTOTPSecret.
+func (srv *Server) openTOTPSecret(sealed []byte) ([]byte, error) {
+	aead, err := srv.totpAEAD()
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed secret too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
+
+// totpURI returns the otpauth URI authenticator apps add secret for
+// account from.
+func totpURI(account string, secret []byte) string {
+	q := url.Values{}
+	q.Set("secret", totpEncoding.EncodeToString(secret))
+	q.Set("issuer", totpIssuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", strconv.Itoa(totpDigits))
+	q.Set("period", strconv.Itoa(int(totpPeriod/time.Second)))
+	u := url.URL{Scheme: "otpauth", Host: "totp", Path: "/" + totpIssuer + ":" + account, RawQuery: q.Encode()}
+	return u.String()
+}
+
+// twoFactor returns userID's two-factor state from s. A secret sealed
+// under a previous AUTH_SECRET can no longer be checked, so it is cleared
+// and the user is treated as never having set two-factor up.
+func (srv *Server) twoFactor(s Store, userID int) (TwoFactor, error) {
+	tf, err := s.TwoFactor(userID)
+	if err != nil || tf.Secret == nil {
+		return tf, err
+	}
+	if _, err := srv.openTOTPSecret(tf.Secret); err == nil {
+		return tf, nil
+	}
+	srv.logAt("warn", "two-factor: clearing user %d's secret, sealed under a previous AUTH_SECRET", userID)
+	return TwoFactor{}, s.SetTwoFactor(userID, TwoFactor{})
+}
+
+// checkTwoFactor reports whether tf lets code in: it does if two-factor
+// authentication is not enabled, or code is current.
+func (srv *Server) checkTwoFactor(tf TwoFactor, code string) (bool, error) {
+	if !tf.Enabled {
+		return true, nil
+	}
+	secret, err := srv.openTOTPSecret(tf.Secret)
+	if err != nil {
+		return false, err
+	}
+	return checkTOTP(secret, code, srv.Clock.Now()), nil
+}
+
+// setupTwoFactor gives the caller a new TOTP secret, replacing any they
+// have not confirmed yet. It takes effect once confirmed with
+// verifyTwoFactor.
+func (srv *Server) setupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	p, _ := principalFrom(r.Context())
+	user, err := srv.requestStore(r).GetUser(p.UserID)
+	if err != nil {
+		respondNotFound(w, r, "user", p.UserID)
+		return
+	}
+	tf, err := srv.twoFactor(srv.requestStore(r), user.ID)
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if tf.Enabled {
+		respondError(w, r, http.StatusConflict, "two-factor already enabled")
+		return
+	}
+
+	secret := make([]byte, totpSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		respondError(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+	sealed, err := srv.sealTOTPSecret(secret)
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+	account := user.Email
+	if account == "" {
+		account = "user " + strconv.Itoa(user.ID)
+	}
+	setup := TwoFactorSetup{Secret: totpEncoding.EncodeToString(secret), URI: totpURI(account, secret)}
+	if setup.QRCode, err = qrcode.Encode(setup.URI, qrcode.Medium, 256); err != nil {
+		respondError(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if err := srv.requestStore(r).SetTwoFactor(user.ID, TwoFactor{Secret: sealed}); err != nil {
+		respondError(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+	respondJSON(w, http.StatusOK, setup)
+}
+
+// verifyTwoFactor enables two-factor authentication for the caller once
+// they show a current code for the secret from setupTwoFactor.
+func (srv *Server) verifyTwoFactor(w http.ResponseWriter, r *http.Request) {
+	p, _ := principalFrom(r.Context())
+	var body TwoFactorCode
+	if err := decodeJSON(r, &body); err != nil {
+		respondDecodeError(w, r, err)
+		return
+	}
+	tf, err := srv.twoFactor(srv.requestStore(r), p.UserID)
+	if err != nil {
+		respondNotFound(w, r, "user", p.UserID)
+		return
+	}
+	if tf.Secret == nil {
+		respondError(w, r, http.StatusConflict, "two-factor not set up")
+		return
+	}
+	secret, err := srv.openTOTPSecret(tf.Secret)
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+	if !checkTOTP(secret, body.Code, srv.Clock.Now()) {
+		respondError(w, r, http.StatusBadRequest, "invalid two-factor code")
+		return
+	}
+	tf.Enabled = true
+	if err := srv.requestStore(r).SetTwoFactor(p.UserID, tf); err != nil {
+		respondError(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupTestTwoFactor sets up two-factor authentication for userID through
+// the API and returns the secret; enable also confirms it.
+func setupTestTwoFactor(t *testing.T, router http.Handler, userID int, enable bool) []byte {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newUserRequest(userID, http.MethodPost, "/auth/2fa/setup", ""))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var setup TwoFactorSetup
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &setup))
+	secret, err := totpEncoding.DecodeString(setup.Secret)
+	require.NoError(t, err)
+
+	if enable {
+		w = httptest.NewRecorder()
+		body := `{"code":"` + currentTOTP(secret) + `"}`
+		router.ServeHTTP(w, newUserRequest(userID, http.MethodPost, "/auth/2fa/verify", body))
+		require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	}
+	return secret
+}
+
+// currentTOTP returns secret's code by the server's clock.
+func currentTOTP(secret []byte) string {
+	return totpCode(secret, server.Clock.Now().Unix()/30)
+}
+
+// ========== TOTP Tests ==========
+
+func TestTOTPCode_RFC6238(t *testing.T) {
+	// The SHA-1 test vectors of RFC 6238 appendix B, cut to six digits.
+	secret := []byte("12345678901234567890")
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, totpCode(secret, tt.unix/30), "at %d", tt.unix)
+	}
+}
+
+func TestCheckTOTP(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	now := time.Unix(1111111109, 0)
+
+	assert.True(t, checkTOTP(secret, "081804", now))
+	assert.True(t, checkTOTP(secret, "081804", now.Add(30*time.Second)), "one period late")
+	assert.True(t, checkTOTP(secret, "081804", now.Add(-30*time.Second)), "one period early")
+	assert.False(t, checkTOTP(secret, "081804", now.Add(time.Minute+5*time.Second)))
+	assert.False(t, checkTOTP(secret, "000000", now))
+	assert.False(t, checkTOTP(secret, "", now))
+}
+
+func TestSealTOTPSecret(t *testing.T) {
+	useAuthSecret(t)
+	secret := []byte("12345678901234567890")
+
+	sealed, err := server.sealTOTPSecret(secret)
+	require.NoError(t, err)
+	assert.NotContains(t, string(sealed), string(secret))
+	again, err := server.sealTOTPSecret(secret)
+	require.NoError(t, err)
+	assert.NotEqual(t, sealed, again, "nonces are random")
+
+	opened, err := server.openTOTPSecret(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, secret, opened)
+
+	server.Config.AuthSecret = []byte("another secret")
+	_, err = server.openTOTPSecret(sealed)
+	assert.Error(t, err, "sealed under another AUTH_SECRET")
+	_, err = server.openTOTPSecret([]byte("short"))
+	assert.Error(t, err)
+}
+
+func TestTOTPURI(t *testing.T) {
+	u, err := url.Parse(totpURI("alice@example.com", []byte("12345678901234567890")))
+	require.NoError(t, err)
+
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/api2spec-fixture-chi:alice@example.com", u.Path)
+	assert.Equal(t, url.Values{
+		"secret":    {"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"},
+		"issuer":    {"api2spec-fixture-chi"},
+		"algorithm": {"SHA1"},
+		"digits":    {"6"},
+		"period":    {"30"},
+	}, u.Query())
+}
+
+// ========== Two-Factor Endpoint Tests ==========
+
+func TestSetupTwoFactor(t *testing.T) {
+	router := setupRouter()
+	useAuthSecret(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newUserRequest(1, http.MethodPost, "/auth/2fa/setup", ""))
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var setup TwoFactorSetup
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &setup))
+	secret, err := totpEncoding.DecodeString(setup.Secret)
+	require.NoError(t, err)
+	assert.Len(t, secret, totpSecretSize)
+	assert.Equal(t, totpURI("alice@example.com", secret), setup.URI)
+	assert.Equal(t, "\x89PNG", string(setup.QRCode[:4]))
+
+	tf, err := server.Store.TwoFactor(1)
+	require.NoError(t, err)
+	assert.False(t, tf.Enabled, "not until verified")
+	assert.NotContains(t, string(tf.Secret), string(secret), "stored encrypted")
+	opened, err := server.openTOTPSecret(tf.Secret)
+	require.NoError(t, err)
+	assert.Equal(t, secret, opened)
+}
+
+func TestSetupTwoFactor_AlreadyEnabled(t *testing.T) {
+	router := setupRouter()
+	useAuthSecret(t)
+	setupTestTwoFactor(t, router, 1, true)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newUserRequest(1, http.MethodPost, "/auth/2fa/setup", ""))
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestVerifyTwoFactor(t *testing.T) {
+	router := setupRouter()
+	useAuthSecret(t)
+	freezeClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	secret := setupTestTwoFactor(t, router, 1, false)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newUserRequest(1, http.MethodPost, "/auth/2fa/verify", `{"code":"000000"}`))
+	if currentTOTP(secret) != "000000" {
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newUserRequest(1, http.MethodPost, "/auth/2fa/verify", `{"code":"`+currentTOTP(secret)+`"}`))
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	tf, err := server.Store.TwoFactor(1)
+	require.NoError(t, err)
+	assert.True(t, tf.Enabled)
+}
+
+func TestVerifyTwoFactor_NotSetUp(t *testing.T) {
+	router := setupRouter()
+	useAuthSecret(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newUserRequest(1, http.MethodPost, "/auth/2fa/verify", `{"code":"123456"}`))
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestTwoFactor_RequiresUser(t *testing.T) {
+	router := setupRouter()
+	useAuthSecret(t)
+
+	for _, path := range []string{"/auth/2fa/setup", "/auth/2fa/verify"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newAdminRequest(http.MethodPost, path, `{"code":"123456"}`))
+		assert.Equal(t, http.StatusUnauthorized, w.Code, path)
+	}
+}
+
+func TestLogin_TwoFactor(t *testing.T) {
+	router := setupRouter()
+	usePasswords(t)
+	freezeClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	setTestPassword(t, 1, "correct horse")
+
+	// A secret that is set up but not confirmed is not enforced.
+	secret := setupTestTwoFactor(t, router, 1, false)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/auth/login", `{"email":"alice@example.com","password":"correct horse"}`))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	secret = setupTestTwoFactor(t, router, 1, true)
+	wrong := "000000"
+	if currentTOTP(secret) == wrong {
+		wrong = "111111"
+	}
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		msg    string
+	}{
+		{"no code", `{"email":"alice@example.com","password":"correct horse"}`, http.StatusUnauthorized, "two-factor code required"},
+		{"wrong code", `{"email":"alice@example.com","password":"correct horse","code":"` + wrong + `"}`, http.StatusUnauthorized, "invalid two-factor code"},
+		{"wrong password", `{"email":"alice@example.com","password":"guess","code":"` + currentTOTP(secret) + `"}`, http.StatusUnauthorized, "invalid credentials"},
+		{"current code", `{"email":"alice@example.com","password":"correct horse","code":"` + currentTOTP(secret) + `"}`, http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/auth/login", tt.body))
+
+			assert.Equal(t, tt.status, w.Code, w.Body.String())
+			if tt.msg != "" {
+				assert.Contains(t, w.Body.String(), tt.msg)
+			}
+		})
+	}
+}
+
+// A secret sealed under a previous AUTH_SECRET is cleared: the user logs in
+// without a code and can set two-factor up again.
+func TestTwoFactor_AuthSecretRotated(t *testing.T) {
+	router := setupRouter()
+	usePasswords(t)
+	freezeClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	setTestPassword(t, 1, "correct horse")
+	setupTestTwoFactor(t, router, 1, true)
+
+	server.Config.AuthSecret = []byte("rotated-auth-secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/auth/login", `{"email":"alice@example.com","password":"correct horse"}`))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	tf, err := server.Store.TwoFactor(1)
+	require.NoError(t, err)
+	assert.Equal(t, TwoFactor{}, tf)
+
+	secret := setupTestTwoFactor(t, router, 1, true)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/auth/login", `{"email":"alice@example.com","password":"correct horse","code":"`+currentTOTP(secret)+`"}`))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Setting up again needs no login in between.
+	server.Config.AuthSecret = []byte("rotated-again")
+	setupTestTwoFactor(t, router, 1, true)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/mail"
+	"strings"
+)
+
+// ImportReport is the outcome of POST /users/import.
+type ImportReport struct {
+	// Created lists the users created, in file order.
+	Created []User `json:"created"`
+	// Skipped lists rows whose email already belongs to a user, including
+	// one created from an earlier row.
+	Skipped []ImportLine `json:"skipped"`
+	// Errors lists rows that are not valid users.
+	Errors []ImportLine `json:"errors"`
+}
+
+// ImportLine reports why one CSV row was not imported. Line counts from 1
+// for the header.
+type ImportLine struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// importColumns are the CSV columns POST /users/import reads, in any order.
+var importColumns = []string{"name", "email"}
+
+// importUsers creates a user from each row of a CSV file sent as a text/csv
+// body or as the "file" part of a multipart/form-data body. The first row
+// names the columns. Invalid and duplicate rows are reported, not fatal;
+// only a file that cannot be read as CSV at all is rejected.
+func (srv *Server) importUsers(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	body, err := importBody(r)
+	if errors.Is(err, errUnsupportedMediaType) {
+		respondError(w, r, http.StatusUnsupportedMediaType, "unsupported media type")
+		return
+	}
+	if err != nil {
+		respondImportError(w, r, err)
+		return
+	}
+	defer body.Close()
+
+	cr := csv.NewReader(body)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		respondImportError(w, r, err)
+		return
+	}
+	columns, err := importHeader(header)
+	if err != nil {
+		respondProblem(w, r, http.StatusBadRequest, "invalid csv", Problem{Violations: []string{err.Error()}})
+		return
+	}
+
+	report := ImportReport{Created: []User{}, Skipped: []ImportLine{}, Errors: []ImportLine{}}
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			respondImportError(w, r, err)
+			return
+		}
+		line, _ := cr.FieldPos(0)
+		if len(record) != len(header) {
+			report.Errors = append(report.Errors, ImportLine{line, fmt.Sprintf("expected %d fields, got %d", len(header), len(record))})
+			continue
+		}
+		user := User{Name: strings.TrimSpace(record[columns["name"]]), Email: strings.TrimSpace(record[columns["email"]])}
+		if msg := validateImportedUser(user); msg != "" {
+			report.Errors = append(report.Errors, ImportLine{line, msg})
+			continue
+		}
+		created, err := srv.requestStore(r).CreateUser(user)
+		if errors.Is(err, errDuplicate) {
+			report.Skipped = append(report.Skipped, ImportLine{line, fmt.Sprintf("email %s already belongs to user %d", user.Email, created.ID)})
+			continue
+		}
+		report.Created = append(report.Created, created)
+	}
+	respondJSON(w, http.StatusOK, report)
+}
+
+var errUnsupportedMediaType = errors.New("unsupported media type")
+
+// importBody returns the CSV file in r.
+func importBody(r *http.Request) (io.ReadCloser, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, errUnsupportedMediaType
+	}
+	switch mediaType {
+	case "text/csv":
+		return r.Body, nil
+	case "multipart/form-data":
+		if err := r.ParseMultipartForm(maxBodyBytes); err != nil {
+			return nil, err
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		return file, nil
+	}
+	return nil, errUnsupportedMediaType
+}
+
+// respondImportError reports a body that could not be read as a CSV file.
+func respondImportError(w http.ResponseWriter, r *http.Request, err error) {
+	var maxErr *http.MaxBytesError
+	var parseErr *csv.ParseError
+	switch {
+	case errors.As(err, &maxErr):
+		respondError(w, r, http.StatusRequestEntityTooLarge, "request body too large")
+	case errors.As(err, &parseErr):
+		respondProblem(w, r, http.StatusBadRequest, "invalid csv", Problem{Violations: []string{parseErr.Error()}})
+	case err == io.EOF:
+		respondProblem(w, r, http.StatusBadRequest, "invalid csv", Problem{Violations: []string{"missing header row"}})
+	default:
+		respondError(w, r, http.StatusBadRequest, "invalid request")
+	}
+}
+
+// importHeader maps each of importColumns to its index in header. Column
+// names are matched case-insensitively; other columns are an error, so a
+// misspelt name is not silently dropped.
+func importHeader(header []string) (map[string]int, error) {
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		known := false
+		for _, c := range importColumns {
+			known = known || name == c
+		}
+		if !known {
+			return nil, fmt.Errorf("line 1: unknown column %q", name)
+		}
+		if _, ok := columns[name]; ok {
+			return nil, fmt.Errorf("line 1: duplicate column %q", name)
+		}
+		columns[name] = i
+	}
+	for _, c := range importColumns {
+		if _, ok := columns[c]; !ok {
+			return nil, fmt.Errorf("line 1: missing column %q", c)
+		}
+	}
+	return columns, nil
+}
+
+// validateImportedUser returns why u cannot be created, or "".
+func validateImportedUser(u User) string {
+	switch {
+	case u.Name == "":
+		return "name is required"
+	case u.Email == "":
+		return "email is required"
+	}
+	if addr, err := mail.ParseAddress(u.Email); err != nil || addr.Address != u.Email {
+		return fmt.Sprintf("invalid email %q", u.Email)
+	}
+	return ""
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newCSVImportRequest posts csv to /users/import as a text/csv body.
+func newCSVImportRequest(csv string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/users/import", strings.NewReader(csv))
+	req.Header.Set("Content-Type", "text/csv")
+	return req
+}
+
+// ========== Import Tests ==========
+
+func TestImportUsers_Report(t *testing.T) {
+	router := setupRouter()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	freezeClock(now)
+	csv := "name,email\n" +
+		"Carol,carol@example.com\n" +
+		"Alice Again,alice@example.com\n" +
+		",nobody@example.com\n" +
+		"Dave,not-an-email\n" +
+		"Erin\n" +
+		"Carol Again,carol@example.com\n" +
+		"\"Frank, Jr.\", frank@example.com\n"
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newCSVImportRequest(csv))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var report ImportReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	require.Len(t, report.Created, 2)
+	assert.Equal(t, User{ID: 3, Name: "Carol", Email: "carol@example.com", Version: 1, CreatedAt: now, UpdatedAt: now}, report.Created[0])
+	assert.Equal(t, "Frank, Jr.", report.Created[1].Name)
+	assert.Equal(t, []ImportLine{
+		{3, "email alice@example.com already belongs to user 1"},
+		{7, "email carol@example.com already belongs to user 3"},
+	}, report.Skipped)
+	assert.Equal(t, []ImportLine{
+		{4, "name is required"},
+		{5, `invalid email "not-an-email"`},
+		{6, "expected 2 fields, got 1"},
+	}, report.Errors)
+
+	_, err := server.Store.GetUser(4)
+	assert.NoError(t, err, "created users are stored")
+}
+
+func TestImportUsers_Multipart(t *testing.T) {
+	router := setupRouter()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("file", "users.csv")
+	require.NoError(t, err)
+	_, err = part.Write([]byte("\ufeffEmail,Name\r\ncarol@example.com,Carol\r\n"))
+	require.NoError(t, err)
+	require.NoError(t, mw.Close())
+	req := httptest.NewRequest(http.MethodPost, "/users/import", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var report ImportReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	require.Len(t, report.Created, 1)
+	assert.Equal(t, "Carol", report.Created[0].Name)
+	assert.Empty(t, report.Skipped)
+	assert.Empty(t, report.Errors)
+}
+
+func TestImportUsers_Rejected(t *testing.T) {
+	tests := []struct {
+		name           string
+		contentType    string
+		body           string
+		expectedStatus int
+		contains       string
+	}{
+		{"empty file", "text/csv", "", http.StatusBadRequest, "missing header row"},
+		{"unknown column", "text/csv", "name,email,phone\n", http.StatusBadRequest, `unknown column \"phone\"`},
+		{"missing column", "text/csv", "name\nCarol\n", http.StatusBadRequest, `missing column \"email\"`},
+		{"duplicate column", "text/csv", "name,email,name\n", http.StatusBadRequest, `duplicate column \"name\"`},
+		{"malformed csv", "text/csv", "name,email\n\"Carol,carol@example.com\n", http.StatusBadRequest, "invalid csv"},
+		{"no file part", "multipart/form-data; boundary=x", "--x--\r\n", http.StatusBadRequest, "invalid request"},
+		{"json body", "application/json", `[{"name":"Carol"}]`, http.StatusUnsupportedMediaType, "unsupported media type"},
+		{"too large", "text/csv", "name,email\n" + strings.Repeat("a", maxBodyBytes), http.StatusRequestEntityTooLarge, "too large"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+			req := httptest.NewRequest(http.MethodPost, "/users/import", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.contains)
+			assert.Len(t, server.Store.ListUsers(), 2, "nothing is imported")
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// UserV2 is the version 2 user representation, served under /v2/users while
+// the enable_v2_users flag is on. It adds links to related resources.
+type UserV2 struct {
+	ID        int         `json:"id"`
+	Name      string      `json:"name"`
+	Email     string      `json:"email" redact:"email"`
+	Version   int         `json:"version"`
+	CreatedAt time.Time   `json:"createdAt"`
+	UpdatedAt time.Time   `json:"updatedAt"`
+	Links     UserV2Links `json:"links"`
+}
+
+type UserV2Links struct {
+	Self  string `json:"self"`
+	Posts string `json:"posts"`
+}
+
+func userV2(u User) UserV2 {
+	self := "/v2/users/" + strconv.Itoa(u.ID)
+	return UserV2{
+		ID:        u.ID,
+		Name:      u.Name,
+		Email:     u.Email,
+		Version:   u.Version,
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+		Links: UserV2Links{
+			Self:  self,
+			Posts: "/users/" + strconv.Itoa(u.ID) + "/posts",
+		},
+	}
+}
+
+func (srv *Server) listUsersV2(w http.ResponseWriter, r *http.Request) {
+	users := srv.requestStore(r).ListUsers()
+	out := make([]UserV2, 0, len(users))
+	for _, u := range users {
+		out = append(out, userV2(u))
+	}
+	respondJSON(w, http.StatusOK, out)
+}
+
+func (srv *Server) getUserV2(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	user, err := srv.requestStore(r).GetUser(id)
+	if err != nil {
+		respondNotFound(w, r, "user", id)
+		return
+	}
+	respondJSON(w, http.StatusOK, userV2(user))
+}
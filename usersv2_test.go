@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/api2spec/api2spec-fixture-chi/flags"
+)
+
+// ========== Version 2 User Tests ==========
+
+func TestUsersV2_HiddenWhileFlagOff(t *testing.T) {
+	router := setupRouter()
+
+	for _, path := range []string{"/v2/users", "/v2/users/1"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusNotFound, w.Code, path)
+	}
+}
+
+func TestGetUserV2(t *testing.T) {
+	router := setupRouter()
+	setFlag(t, flags.EnableV2Users)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v2/users/1", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var user UserV2
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+	assert.Equal(t, UserV2{
+		ID:        1,
+		Name:      "Alice",
+		Email:     "alice@example.com",
+		Version:   1,
+		CreatedAt: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC),
+		UpdatedAt: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC),
+		Links:     UserV2Links{Self: "/v2/users/1", Posts: "/users/1/posts"},
+	}, user)
+}
+
+func TestListUsersV2(t *testing.T) {
+	router := setupRouter()
+	setFlag(t, flags.EnableV2Users)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v2/users", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var users []UserV2
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &users))
+	require.Len(t, users, 2)
+	assert.Equal(t, "/v2/users/2", users[1].Links.Self)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+func init() {
+	// CSV imports and JSON Lines bodies are validated as opaque strings;
+	// their handlers check each row or line.
+	openapi3filter.RegisterBodyDecoder("text/csv", openapi3filter.FileBodyDecoder)
+	openapi3filter.RegisterBodyDecoder(ndjsonMediaType, openapi3filter.FileBodyDecoder)
+	// Other codecs' bodies stand for JSON ones, so they are checked against
+	// the same schemas.
+	for _, c := range append(bodyCodecs, postCodecs...) {
+		switch c := c.(type) {
+		case bridgeCodec:
+			openapi3filter.RegisterBodyDecoder(c.MediaType(), bridgeBodyDecoder(c))
+		case protobufCodec:
+			openapi3filter.RegisterBodyDecoder(c.MediaType(), protobufBodyDecoder)
+		}
+	}
+}
+
+// bridgeBodyDecoder decodes c's bodies into the values encoding/json would
+// decode the equivalent JSON into.
+func bridgeBodyDecoder(c bridgeCodec) openapi3filter.BodyDecoder {
+	return func(body io.Reader, _ http.Header, _ *openapi3.SchemaRef, _ openapi3filter.EncodingFn) (interface{}, error) {
+		var v interface{}
+		if err := c.Decode(body, &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+}
+
+// newRequestValidator returns middleware that validates path parameters,
+// query parameters, and bodies against the OpenAPI document in spec.
+// Requests that violate it get a 400 problem listing every violation;
+// requests for operations the document does not describe, and bodies of a
+// media type the operation does not accept, pass through to the router
+// unchanged, for it to answer 404 or 415.
+func newRequestValidator(spec []byte) (func(http.Handler) http.Handler, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(spec)
+	if err != nil {
+		return nil, err
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, err
+	}
+	specRouter, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &openapi3filter.Options{
+		MultiError:         true,
+		AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route, pathParams, err := specRouter.FindRoute(withoutTrailingSlash(r))
+			if err != nil || !acceptsContentType(route.Operation, r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			r.Body = http.MaxBytesReader(w, r.Body, bodyLimit(route.Operation))
+			err = openapi3filter.ValidateRequest(r.Context(), &openapi3filter.RequestValidationInput{
+				Request:    r,
+				PathParams: pathParams,
+				Route:      route,
+				Options:    options,
+			})
+			if err != nil {
+				var maxErr *http.MaxBytesError
+				if errors.As(err, &maxErr) {
+					respondError(w, r, http.StatusRequestEntityTooLarge, "request body too large")
+					return
+				}
+				respondProblem(w, r, http.StatusBadRequest, "invalid request", Problem{
+					Violations: violations("request", err),
+				})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+// acceptsContentType reports whether op takes no request body, or takes
+// one of r's media type.
+func acceptsContentType(op *openapi3.Operation, r *http.Request) bool {
+	if op.RequestBody == nil || op.RequestBody.Value == nil {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && op.RequestBody.Value.Content.Get(mediaType) != nil
+}
+
+// bodyLimit is the most request body bytes op accepts: its
+// x-max-body-bytes extension, or maxBodyBytes.
+func bodyLimit(op *openapi3.Operation) int64 {
+	if n, ok := op.Extensions["x-max-body-bytes"].(float64); ok && n > 0 {
+		return int64(n)
+	}
+	return maxBodyBytes
+}
+
+// withoutTrailingSlash returns r with one trailing slash removed from its
+// path, so requests served by middleware.StripSlashes find their operation.
+func withoutTrailingSlash(r *http.Request) *http.Request {
+	path := r.URL.Path
+	if len(path) <= 1 || !strings.HasSuffix(path, "/") {
+		return r
+	}
+	r2 := r.Clone(r.Context())
+	r2.URL.Path = strings.TrimSuffix(path, "/")
+	r2.URL.RawPath = ""
+	return r2
+}
+
+// violations flattens a validation error into one short message per
+// problem, prefixed with where it was found.
+func violations(where string, err error) []string {
+	if multi, ok := err.(openapi3.MultiError); ok {
+		var out []string
+		for _, e := range multi {
+			out = append(out, violations(where, e)...)
+		}
+		return out
+	}
+
+	var requestErr *openapi3filter.RequestError
+	var schemaErr *openapi3.SchemaError
+	switch {
+	case errors.As(err, &requestErr):
+		switch {
+		case requestErr.Parameter != nil:
+			where = fmt.Sprintf("%s parameter %q", requestErr.Parameter.In, requestErr.Parameter.Name)
+		case requestErr.RequestBody != nil:
+			where = "request body"
+		}
+		if requestErr.Err != nil {
+			return violations(where, requestErr.Err)
+		}
+		return []string{where + ": " + requestErr.Reason}
+	case errors.As(err, &schemaErr):
+		if ptr := schemaErr.JSONPointer(); len(ptr) > 0 {
+			where += " /" + strings.Join(ptr, "/")
+		}
+		return []string{where + ": " + schemaErr.Reason}
+	default:
+		return []string{where + ": " + err.Error()}
+	}
+}
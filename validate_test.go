@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupValidatedRouter is setupRouter with the spec validation middleware
+// installed, as in main.
+func setupValidatedRouter(t *testing.T) *chi.Mux {
+	t.Helper()
+	validator, err := newRequestValidator(openAPISpec)
+	require.NoError(t, err)
+
+	server = newMemoryServer(Config{})
+	return server.newRouter(validator)
+}
+
+// ========== Request Validation Middleware Tests ==========
+
+func TestRequestValidator_RejectsNonconformingRequests(t *testing.T) {
+	tests := []struct {
+		name               string
+		method             string
+		path               string
+		body               string
+		expectedViolations []string
+	}{
+		{
+			name:               "non-integer path param",
+			method:             http.MethodGet,
+			path:               "/users/abc",
+			expectedViolations: []string{`path parameter "id": value abc: an invalid integer: invalid syntax`},
+		},
+		{
+			name:   "query params out of range",
+			method: http.MethodGet,
+			path:   "/users/1/posts?page=0&per_page=500",
+			expectedViolations: []string{
+				`query parameter "page": number must be at least 1`,
+				`query parameter "per_page": number must be at most 100`,
+			},
+		},
+		{
+			name:   "body with wrong type and unknown field",
+			method: http.MethodPost,
+			path:   "/users",
+			body:   `{"name":1,"role":"admin"}`,
+			expectedViolations: []string{
+				"request body /name: value must be a string",
+				`request body: property "role" is unsupported`,
+			},
+		},
+		{
+			name:               "trailing slash",
+			method:             http.MethodGet,
+			path:               "/users/abc/",
+			expectedViolations: []string{`path parameter "id": value abc: an invalid integer: invalid syntax`},
+		},
+		{
+			name:               "missing body",
+			method:             http.MethodPost,
+			path:               "/posts",
+			expectedViolations: []string{"request body: value is required but missing"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupValidatedRouter(t)
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.method != http.MethodGet {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+
+			var problem Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			assert.Equal(t, "invalid request", problem.Title)
+			assert.Equal(t, tt.expectedViolations, problem.Violations)
+		})
+	}
+}
+
+func TestRequestValidator_PassesConformingRequests(t *testing.T) {
+	router := setupValidatedRouter(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":"Carol","email":"carol@example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	// The handler still sees the full body after validation.
+	require.Equal(t, http.StatusCreated, w.Code)
+	var user User
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+	assert.Equal(t, "Carol", user.Name)
+}
+
+func TestRequestValidator_UndocumentedRequestsReachRouter(t *testing.T) {
+	router := setupValidatedRouter(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/nonexistent", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/users", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestRequestValidator_UnsupportedMediaTypeReachesRoute(t *testing.T) {
+	router := setupValidatedRouter(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`name=Carol`))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	// The route, not the validator, answers with the types it reads.
+	require.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	var problem Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, []string{"application/json", "application/msgpack", "application/cbor"}, problem.Supported)
+}
+
+func TestRequestValidator_BodyTooLarge(t *testing.T) {
+	router := setupValidatedRouter(t)
+
+	body := `{"title":"` + strings.Repeat("x", maxBodyBytes) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/posts", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestRequestValidator_LocalizedTitle(t *testing.T) {
+	router := setupValidatedRouter(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/abc", nil)
+	req.Header.Set("Accept-Language", "de")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	var problem Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, "ungültige Anfrage", problem.Title)
+	assert.Equal(t, "de", w.Header().Get("Content-Language"))
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strings"
+)
+
+// varyByResponseHeader maps response headers to the request header whose
+// negotiation they report: a response that has one was chosen by the
+// other, so caches must key it on that request header too.
+var varyByResponseHeader = []struct {
+	response, request string
+}{
+	{"Access-Control-Allow-Headers", "Access-Control-Request-Headers"},
+	{"Access-Control-Allow-Methods", "Access-Control-Request-Method"},
+	{"Access-Control-Allow-Origin", "Origin"},
+	{"Content-Encoding", "Accept-Encoding"},
+	{"Content-Language", "Accept-Language"},
+}
+
+// varyHeaders sets each response's Vary header to the request headers it
+// was chosen by, so that shared caches never serve it to a request that
+// would have been answered differently. Middleware that reads such a
+// header without leaving a response header behind, as negotiateCodec does
+// for Accept, adds it to Vary itself; varyHeaders adds the rest from the
+// response headers in varyByResponseHeader, and writes the whole as one
+// sorted, deduplicated Vary.
+func varyHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&varyWriter{ResponseWriter: w}, r)
+	})
+}
+
+// varyWriter sets Vary when the response's headers are written.
+type varyWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *varyWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		setVary(w.Header())
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *varyWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *varyWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *varyWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// setVary rewrites h's Vary as described on varyHeaders. A "*" stands for
+// every request header, so it replaces the rest.
+func setVary(h http.Header) {
+	fields := map[string]bool{}
+	for _, value := range h.Values("Vary") {
+		for _, field := range strings.Split(value, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				fields[textproto.CanonicalMIMEHeaderKey(field)] = true
+			}
+		}
+	}
+	for _, v := range varyByResponseHeader {
+		if h.Get(v.response) != "" {
+			fields[v.request] = true
+		}
+	}
+
+	switch {
+	case len(fields) == 0:
+		h.Del("Vary")
+	case fields["*"]:
+		h.Set("Vary", "*")
+	default:
+		sorted := make([]string, 0, len(fields))
+		for field := range fields {
+			sorted = append(sorted, field)
+		}
+		sort.Strings(sorted)
+		h.Set("Vary", strings.Join(sorted, ", "))
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ========== Vary Tests ==========
+
+func TestSetVary(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   http.Header
+		expected string
+	}{
+		{"none", http.Header{}, ""},
+		{"kept", http.Header{"Vary": {"Accept"}}, "Accept"},
+		{"merged, deduplicated and sorted", http.Header{"Vary": {"origin, Accept", "Accept"}}, "Accept, Origin"},
+		{"from Content-Language", http.Header{"Content-Language": {"de"}}, "Accept-Language"},
+		{"from Content-Encoding", http.Header{"Content-Encoding": {"gzip"}, "Vary": {"Accept"}}, "Accept, Accept-Encoding"},
+		{"from CORS", http.Header{"Access-Control-Allow-Origin": {"https://a.example"}}, "Origin"},
+		{"from a preflight", http.Header{
+			"Access-Control-Allow-Origin":  {"https://a.example"},
+			"Access-Control-Allow-Methods": {"GET"},
+			"Access-Control-Allow-Headers": {"X-Custom"},
+		}, "Access-Control-Request-Headers, Access-Control-Request-Method, Origin"},
+		{"star wins", http.Header{"Vary": {"Accept, *"}, "Content-Language": {"en"}}, "*"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setVary(tt.header)
+			assert.Equal(t, tt.expected, tt.header.Get("Vary"))
+			assert.LessOrEqual(t, len(tt.header.Values("Vary")), 1)
+		})
+	}
+}
+
+// varyVariant is a response to a request that differs from the others in
+// one header.
+type varyVariant struct {
+	status  int
+	header  http.Header
+	body    string
+	varying []string
+}
+
+func serveVariant(router http.Handler, method, path, header, value string) varyVariant {
+	req := httptest.NewRequest(method, path, nil)
+	if value != "" {
+		req.Header.Set(header, value)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var varying []string
+	for _, field := range strings.Split(w.Header().Get("Vary"), ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			varying = append(varying, field)
+		}
+	}
+	return varyVariant{status: w.Code, header: w.Header(), body: w.Body.String(), varying: varying}
+}
+
+// differs reports whether a cache serving v for a request that should have
+// got o would send the wrong response.
+func (v varyVariant) differs(o varyVariant) bool {
+	if v.status != o.status || v.body != o.body {
+		return true
+	}
+	for _, h := range []string{"Content-Type", "Content-Language", "Content-Encoding", "Access-Control-Allow-Origin"} {
+		if v.header.Get(h) != o.header.Get(h) {
+			return true
+		}
+	}
+	return false
+}
+
+// TestVary_CacheSafety sends requests that differ only in one request
+// header. Whenever their responses differ, each of them must name the
+// header in Vary, or a shared cache could serve one for the other.
+func TestVary_CacheSafety(t *testing.T) {
+	config := writeConfigFile(t, `{"corsOrigins":["https://a.example"]}`)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		header string
+		values []string
+	}{
+		{"codec", http.MethodGet, "/users/1", "Accept", []string{"", "application/msgpack", "application/cbor"}},
+		{"codec on errors", http.MethodGet, "/users/999", "Accept", []string{"", "application/msgpack"}},
+		{"language", http.MethodGet, "/users/999", "Accept-Language", []string{"", "es", "de"}},
+		{"language on invalid IDs", http.MethodGet, "/users/0", "Accept-Language", []string{"", "de"}},
+		{"origin", http.MethodGet, "/posts", "Origin", []string{"", "https://a.example", "https://b.example"}},
+		{"origin on errors", http.MethodGet, "/posts/999", "Origin", []string{"", "https://a.example"}},
+		{"encoding", http.MethodGet, "/users", "Accept-Encoding", []string{"", "gzip"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+			useLiveConfig(t, config)
+			variants := make([]varyVariant, len(tt.values))
+			for i, value := range tt.values {
+				variants[i] = serveVariant(router, tt.method, tt.path, tt.header, value)
+			}
+			for i, v := range variants {
+				for j, o := range variants {
+					if i != j && v.differs(o) {
+						assert.Contains(t, v.varying, tt.header, "%s %q vs %q", tt.header, tt.values[i], tt.values[j])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestVary_SingleHeader(t *testing.T) {
+	router := setupRouter()
+	useLiveConfig(t, writeConfigFile(t, `{"corsOrigins":["https://a.example"]}`))
+
+	req := httptest.NewRequest(http.MethodGet, "/users/999", nil)
+	req.Header.Set("Origin", "https://a.example")
+	req.Header.Set("Accept-Language", "de")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, []string{"Accept, Accept-Language, Origin"}, w.Header().Values("Vary"))
+}
+
+func TestVary_NoneWhenNothingNegotiated(t *testing.T) {
+	router := setupRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/todos", nil))
+
+	assert.Empty(t, w.Header().Values("Vary"))
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// verificationTTL is how long an email verification token is good for.
+const verificationTTL = 24 * time.Hour
+
+// verificationToken returns a token verifying email as userID's within
+// verificationTTL. It covers the email, so it stops working once the
+// user's email changes.
+func (srv *Server) verificationToken(userID int, email string) string {
+	return srv.expiringToken("verify", userID, verificationTTL, []byte(strings.ToLower(email)))
+}
+
+// parseVerificationToken returns the user token verifies, and the email it
+// verifies.
+func (srv *Server) parseVerificationToken(s Store, token string) (User, bool) {
+	var user User
+	userID, ok := srv.parseExpiringToken("verify", token, func(id int) ([]byte, error) {
+		var err error
+		user, err = s.GetUser(id)
+		return []byte(strings.ToLower(user.Email)), err
+	})
+	return user, ok && user.ID == userID
+}
+
+// sendVerification mails the user in the path, who must be the caller, a
+// link to GET /verify.
+func (srv *Server) sendVerification(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	if p, _ := principalFrom(r.Context()); p.UserID != id {
+		respondError(w, r, http.StatusForbidden, "forbidden")
+		return
+	}
+	user, err := srv.requestStore(r).GetUser(id)
+	if err != nil {
+		respondNotFound(w, r, "user", id)
+		return
+	}
+	switch {
+	case user.Email == "":
+		respondError(w, r, http.StatusConflict, "no email to verify")
+		return
+	case user.Verified:
+		respondError(w, r, http.StatusConflict, "email already verified")
+		return
+	}
+	link, ok := srv.publicLink(w, r, "/verify?token="+url.QueryEscape(srv.verificationToken(user.ID, user.Email)))
+	if !ok {
+		return
+	}
+	err = srv.Mailer.Send(Mail{
+		To:      user.Email,
+		Subject: "Verify your email",
+		Body:    "To verify your email, open this link within " + verificationTTL.String() + ":\n\n" + link + "\n",
+	})
+	if err != nil {
+		srv.logAt("warn", "verification: mail to user %d: %v", user.ID, err)
+		respondError(w, r, http.StatusBadGateway, "mail not sent")
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// verifyEmail marks the user a token from sendVerification was mailed to
+// verified, and returns them.
+func (srv *Server) verifyEmail(w http.ResponseWriter, r *http.Request) {
+	user, ok := srv.parseVerificationToken(srv.requestStore(r), r.URL.Query().Get("token"))
+	if !ok {
+		respondError(w, r, http.StatusBadRequest, "invalid verification token")
+		return
+	}
+	user, err := srv.requestStore(r).VerifyUser(user.ID, user.Email)
+	if err != nil {
+		// The email changed since the token was checked.
+		respondError(w, r, http.StatusBadRequest, "invalid verification token")
+		return
+	}
+	respondJSON(w, http.StatusOK, user)
+}
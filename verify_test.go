@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mailedVerificationLink asks for userID's verification mail and returns
+// the path and query of the link in it.
+func mailedVerificationLink(t *testing.T, router http.Handler, sent *[]Mail, userID int) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newUserRequest(userID, http.MethodPost, "/users/"+strconv.Itoa(userID)+"/verify/send", ""))
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	require.NotEmpty(t, *sent)
+	body := (*sent)[len(*sent)-1].Body
+	link, err := url.Parse(strings.TrimSpace(body[strings.Index(body, "http"):]))
+	require.NoError(t, err)
+	return link.RequestURI()
+}
+
+// ========== Email Verification Tests ==========
+
+func TestVerifyEmail(t *testing.T) {
+	router := setupRouter()
+	useAuthSecret(t)
+	sent := useTestMailer(t)
+
+	link := mailedVerificationLink(t, router, sent, 1)
+	assert.Equal(t, "alice@example.com", (*sent)[0].To)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, link, nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var user User
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+	assert.True(t, user.Verified)
+	assert.Equal(t, 2, user.Version)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newUserRequest(1, http.MethodPost, "/users/1/verify/send", ""))
+	assert.Equal(t, http.StatusConflict, w.Code, "already verified")
+}
+
+func TestVerifyEmail_EmailChange(t *testing.T) {
+	router := setupRouter()
+	useAuthSecret(t)
+	sent := useTestMailer(t)
+	link := mailedVerificationLink(t, router, sent, 2)
+
+	_, err := server.Store.UpdateUser(User{ID: 2, Name: "Bob", Email: "robert@example.com", Version: 1})
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, link, nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code, "the token was for the old email")
+}
+
+func TestVerifyEmail_ClearedByEmailChange(t *testing.T) {
+	s := newMemoryStore(NewEventBus())
+	u, err := s.VerifyUser(1, "ALICE@example.com")
+	require.NoError(t, err)
+	require.True(t, u.Verified)
+
+	u, err = s.UpdateUser(User{ID: 1, Name: "Alicia", Email: "Alice@Example.com", Version: u.Version})
+	require.NoError(t, err)
+	assert.True(t, u.Verified, "a change of case is the same email")
+
+	u, err = s.UpdateUser(User{ID: 1, Name: "Alicia", Email: "alicia@example.com", Version: u.Version, Verified: true})
+	require.NoError(t, err)
+	assert.False(t, u.Verified)
+
+	_, err = s.VerifyUser(1, "alice@example.com")
+	assert.ErrorIs(t, err, errNotFound)
+}
+
+func TestVerifyEmail_InvalidTokens(t *testing.T) {
+	router := setupRouter()
+	useAuthSecret(t)
+	now := time.Now()
+	clock := freezeClock(now)
+	valid := server.verificationToken(1, "alice@example.com")
+
+	tests := []struct {
+		name  string
+		token string
+		at    time.Time
+	}{
+		{"missing", "", now},
+		{"garbage", "garbage", now},
+		{"other user", "2" + valid[1:], now},
+		{"other purpose", server.expiringToken("password-reset", 1, verificationTTL, []byte("alice@example.com")), now},
+		{"expired", valid, now.Add(verificationTTL)},
+		{"user token", server.userToken(1, 1), now},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock.t = tt.at
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/verify?token="+url.QueryEscape(tt.token), nil))
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+	user, err := server.Store.GetUser(1)
+	require.NoError(t, err)
+	assert.False(t, user.Verified)
+}
+
+func TestSendVerification_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		userID  int
+		path    string
+		mailErr error
+		status  int
+	}{
+		{"another user", 2, "/users/1/verify/send", nil, http.StatusForbidden},
+		{"mail fails", 1, "/users/1/verify/send", errors.New("mail server down"), http.StatusBadGateway},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+			useAuthSecret(t)
+			useTestMailer(t)
+			server.Mailer.(*recordingMailer).err = tt.mailErr
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, newUserRequest(tt.userID, http.MethodPost, tt.path, ""))
+			assert.Equal(t, tt.status, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestSendVerification_NoEmail(t *testing.T) {
+	router := setupRouter()
+	useAuthSecret(t)
+	useTestMailer(t)
+	user, err := server.Store.CreateUser(User{Name: "Nomail"})
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newUserRequest(user.ID, http.MethodPost, "/users/"+strconv.Itoa(user.ID)+"/verify/send", ""))
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestSendVerification_IgnoresHost(t *testing.T) {
+	router := setupRouter()
+	useAuthSecret(t)
+	sent := useTestMailer(t)
+
+	req := newUserRequest(1, http.MethodPost, "/users/1/verify/send", "")
+	req.Host = "evil.example"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	require.Len(t, *sent, 1)
+	assert.Contains(t, (*sent)[0].Body, testPublicURL+"/verify?token=")
+	assert.NotContains(t, (*sent)[0].Body, "evil.example")
+
+	server.Config.PublicURL = ""
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newUserRequest(1, http.MethodPost, "/users/1/verify/send", ""))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Len(t, *sent, 1, "no link is mailed without PUBLIC_URL")
+}
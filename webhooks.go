@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Webhook subscribes a URL to entity lifecycle events.
+type Webhook struct {
+	ID  int    `json:"id"`
+	URL string `json:"url"`
+	// Events filters by "<resource>.<type>" names such as "users.created".
+	// Empty means every event.
+	Events  []string `json:"events,omitempty"`
+	Version int      `json:"version"`
+}
+
+// wants reports whether w subscribes to e.
+func (w Webhook) wants(e Event) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	name := eventName(e)
+	for _, n := range w.Events {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// eventName is the "<resource>.<type>" name webhooks filter on.
+func eventName(e Event) string {
+	return e.Resource + "." + string(e.Type)
+}
+
+// DeliveryStatus is where a delivery is in its lifecycle.
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+// Delivery is one event queued for one webhook: a row of the outbox.
+type Delivery struct {
+	ID             int            `json:"id"`
+	WebhookID      int            `json:"webhookId"`
+	Event          Event          `json:"event"`
+	Status         DeliveryStatus `json:"status"`
+	Attempts       int            `json:"attempts"`
+	CreatedAt      time.Time      `json:"createdAt"`
+	NextAttemptAt  time.Time      `json:"nextAttemptAt"`
+	LastStatusCode int            `json:"lastStatusCode,omitempty"`
+	LastError      string         `json:"lastError,omitempty"`
+}
+
+// webhooksPlugin fills the webhook outbox from entity changes.
+func (srv *Server) webhooksPlugin() Plugin {
+	return Plugin{Name: "webhooks", OnEntityChange: srv.enqueueDeliveries}
+}
+
+// enqueueDeliveries fills the outbox: it records a pending delivery of e
+// for every webhook that wants it. Nothing is sent here; the dispatcher
+// picks the rows up. The entity is stored as the API serves it, with its
+// fields named for JSON_NAMING.
+func (srv *Server) enqueueDeliveries(e Event) {
+	e.Data = renamed(e.Data, srv.naming)
+	for _, w := range srv.Store.ListWebhooks() {
+		if !w.wants(e) {
+			continue
+		}
+		if _, err := srv.Store.EnqueueDelivery(Delivery{WebhookID: w.ID, Event: e}); err != nil {
+			srv.logAt("warn", "webhooks: enqueue %s for webhook %d: %v", eventName(e), w.ID, err)
+		}
+	}
+}
+
+// webhookDispatcher sends due outbox rows, retrying failures with
+// exponential backoff until maxAttempts is reached.
+type webhookDispatcher struct {
+	store       Store
+	client      *http.Client
+	now         func() time.Time
+	log         logFunc
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+	maxAttempts int
+}
+
+func newWebhookDispatcher(s Store) *webhookDispatcher {
+	return &webhookDispatcher{
+		store:       s,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		now:         time.Now,
+		baseBackoff: time.Second,
+		maxBackoff:  time.Hour,
+		maxAttempts: 8,
+	}
+}
+
+// backoff is the wait after the given number of failed attempts:
+// baseBackoff doubled per attempt, capped at maxBackoff.
+func (d *webhookDispatcher) backoff(attempts int) time.Duration {
+	wait := d.baseBackoff
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if wait >= d.maxBackoff {
+			return d.maxBackoff
+		}
+	}
+	return wait
+}
+
+// run dispatches due deliveries every interval until ctx is done.
+func (d *webhookDispatcher) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.dispatchDue(ctx)
+		}
+	}
+}
+
+// dispatchDue attempts every pending delivery whose time has come.
+func (d *webhookDispatcher) dispatchDue(ctx context.Context) {
+	for _, delivery := range d.store.DueDeliveries(d.now()) {
+		d.attempt(ctx, delivery)
+	}
+}
+
+func (d *webhookDispatcher) attempt(ctx context.Context, delivery Delivery) {
+	delivery.Attempts++
+	hook, err := d.store.GetWebhook(delivery.WebhookID)
+	if err != nil {
+		delivery.Status = DeliveryFailed
+		delivery.LastError = "webhook deleted"
+	} else {
+		delivery.LastStatusCode, err = d.send(ctx, hook, delivery)
+		switch {
+		case err == nil:
+			delivery.Status = DeliveryDelivered
+			delivery.LastError = ""
+		case delivery.Attempts >= d.maxAttempts:
+			delivery.Status = DeliveryFailed
+			delivery.LastError = err.Error()
+		default:
+			delivery.LastError = err.Error()
+			delivery.NextAttemptAt = d.now().Add(d.backoff(delivery.Attempts))
+		}
+	}
+	if err := d.store.UpdateDelivery(delivery); err != nil {
+		d.log.at("warn", "webhooks: update delivery %d: %v", delivery.ID, err)
+	}
+}
+
+// send POSTs the delivery's event to hook. Any 2xx response is success.
+func (d *webhookDispatcher) send(ctx context.Context, hook Webhook, delivery Delivery) (int, error) {
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", eventName(delivery.Event))
+	req.Header.Set("X-Webhook-Delivery", strconv.Itoa(delivery.ID))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (srv *Server) listWebhooks(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, srv.requestStore(r).ListWebhooks())
+}
+
+func (srv *Server) getWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	hook, err := srv.requestStore(r).GetWebhook(id)
+	if err != nil {
+		respondNotFound(w, r, "webhook", id)
+		return
+	}
+	respondJSON(w, http.StatusOK, hook)
+}
+
+func (srv *Server) createWebhook(w http.ResponseWriter, r *http.Request) {
+	var hook Webhook
+	if err := decodeJSON(r, &hook); err != nil {
+		respondDecodeError(w, r, err)
+		return
+	}
+	if u, err := url.Parse(hook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		respondProblem(w, r, http.StatusBadRequest, "invalid request", Problem{
+			Violations: []string{"request body /url: must be an absolute http or https URL"},
+		})
+		return
+	}
+	hook, err := srv.requestStore(r).CreateWebhook(hook)
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, "internal error")
+		return
+	}
+	w.Header().Set("Location", "/webhooks/"+strconv.Itoa(hook.ID))
+	respondJSON(w, http.StatusCreated, hook)
+}
+
+func (srv *Server) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	if err := srv.requestStore(r).DeleteWebhook(id); err != nil {
+		respondNotFound(w, r, "webhook", id)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (srv *Server) getWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	if _, err := srv.requestStore(r).GetWebhook(id); err != nil {
+		respondNotFound(w, r, "webhook", id)
+		return
+	}
+	respondJSON(w, http.StatusOK, srv.requestStore(r).ListDeliveries(id))
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupWebhookRouter is setupAdminRouter with the outbox subscribed to the event
+// bus for the duration of the test.
+func setupWebhookRouter(t *testing.T) http.Handler {
+	t.Helper()
+	router := setupAdminRouter(t)
+	t.Cleanup(server.events.Subscribe(server.enqueueDeliveries))
+	return router
+}
+
+// receiver records webhook requests and answers with the next queued status,
+// or 200 once the queue is empty.
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   []string
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.requests = append(rc.requests, r)
+	rc.bodies = append(rc.bodies, string(body))
+	status := http.StatusOK
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+// testDispatcher returns a dispatcher on a fake clock starting at now.
+func testDispatcher(clock *time.Time) *webhookDispatcher {
+	d := newWebhookDispatcher(server.Store)
+	d.now = func() time.Time { return *clock }
+	d.maxAttempts = 3
+	return d
+}
+
+func createTestWebhook(t *testing.T, router http.Handler, body string) Webhook {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/webhooks", body))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var hook Webhook
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &hook))
+	return hook
+}
+
+func createTestUser(t *testing.T, router http.Handler) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":"Hook","email":"hook@example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+}
+
+func getDeliveries(t *testing.T, router http.Handler, id string) []Delivery {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodGet, "/webhooks/"+id+"/deliveries", ""))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var deliveries []Delivery
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &deliveries))
+	return deliveries
+}
+
+// ========== Webhook CRUD Tests ==========
+
+func TestCreateWebhook(t *testing.T) {
+	router := setupWebhookRouter(t)
+
+	hook := createTestWebhook(t, router, `{"url":"https://example.com/hook","events":["users.created"]}`)
+	assert.Equal(t, 1, hook.ID)
+	assert.Equal(t, []string{"users.created"}, hook.Events)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodGet, "/webhooks/1", ""))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestCreateWebhook_InvalidURL(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"missing", `{}`},
+		{"relative", `{"url":"/hook"}`},
+		{"wrong scheme", `{"url":"ftp://example.com/hook"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupAdminRouter(t)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, newAdminRequest(http.MethodPost, "/webhooks", tt.body))
+
+			require.Equal(t, http.StatusBadRequest, w.Code)
+			var p Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+			assert.Equal(t, []string{"request body /url: must be an absolute http or https URL"}, p.Violations)
+		})
+	}
+}
+
+func TestDeleteWebhook(t *testing.T) {
+	router := setupWebhookRouter(t)
+	createTestWebhook(t, router, `{"url":"https://example.com/hook"}`)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodDelete, "/webhooks/1", ""))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminRequest(http.MethodGet, "/webhooks/1/deliveries", ""))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// ========== Outbox Tests ==========
+
+func TestOutbox_EnqueuesMatchingWebhooks(t *testing.T) {
+	router := setupWebhookRouter(t)
+	createTestWebhook(t, router, `{"url":"https://example.com/all"}`)
+	createTestWebhook(t, router, `{"url":"https://example.com/users","events":["users.created"]}`)
+	createTestWebhook(t, router, `{"url":"https://example.com/posts","events":["posts.created"]}`)
+
+	createTestUser(t, router)
+
+	// Webhook 1 also saw the creation of webhooks 1 through 3.
+	all := getDeliveries(t, router, "1")
+	require.Len(t, all, 4)
+	assert.Equal(t, "webhooks", all[0].Event.Resource)
+	assert.Equal(t, "users", all[3].Event.Resource)
+	assert.Equal(t, DeliveryPending, all[3].Status)
+	assert.Zero(t, all[3].Attempts)
+
+	users := getDeliveries(t, router, "2")
+	require.Len(t, users, 1)
+	assert.Equal(t, EventCreated, users[0].Event.Type)
+
+	assert.Empty(t, getDeliveries(t, router, "3"))
+}
+
+// ========== Dispatcher Tests ==========
+
+func TestDispatcher_Delivers(t *testing.T) {
+	rc := &receiver{}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	router := setupWebhookRouter(t)
+	createTestWebhook(t, router, `{"url":"`+srv.URL+`","events":["users.created"]}`)
+	createTestUser(t, router)
+
+	clock := time.Now()
+	testDispatcher(&clock).dispatchDue(context.Background())
+
+	require.Len(t, rc.requests, 1)
+	assert.Equal(t, "users.created", rc.requests[0].Header.Get("X-Webhook-Event"))
+	assert.Equal(t, "1", rc.requests[0].Header.Get("X-Webhook-Delivery"))
+	var e Event
+	require.NoError(t, json.Unmarshal([]byte(rc.bodies[0]), &e))
+	assert.Equal(t, "users", e.Resource)
+	assert.Equal(t, 3, e.ID)
+
+	deliveries := getDeliveries(t, router, "1")
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, DeliveryDelivered, deliveries[0].Status)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+	assert.Equal(t, http.StatusOK, deliveries[0].LastStatusCode)
+
+	// Delivered rows are not sent again.
+	testDispatcher(&clock).dispatchDue(context.Background())
+	assert.Len(t, rc.requests, 1)
+}
+
+// A delivery carries the entity as the API serves it, named for JSON_NAMING.
+func TestDispatcher_FieldNaming(t *testing.T) {
+	rc := &receiver{}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	router := setupWebhookRouter(t)
+	useFieldNaming(t, "snake")
+	createTestWebhook(t, router, `{"url":"`+srv.URL+`","events":["users.created"]}`)
+	createTestUser(t, router)
+
+	clock := time.Now()
+	testDispatcher(&clock).dispatchDue(context.Background())
+
+	require.Len(t, rc.requests, 1)
+	assert.Contains(t, rc.bodies[0], `"post_count":0`)
+	assert.NotContains(t, rc.bodies[0], `"postCount"`)
+}
+
+func TestDispatcher_RetriesWithBackoff(t *testing.T) {
+	rc := &receiver{statuses: []int{http.StatusInternalServerError, http.StatusBadGateway}}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	router := setupWebhookRouter(t)
+	createTestWebhook(t, router, `{"url":"`+srv.URL+`","events":["users.created"]}`)
+	createTestUser(t, router)
+
+	clock := time.Now()
+	d := testDispatcher(&clock)
+
+	d.dispatchDue(context.Background())
+	delivery := getDeliveries(t, router, "1")[0]
+	assert.Equal(t, DeliveryPending, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Equal(t, http.StatusInternalServerError, delivery.LastStatusCode)
+	assert.Equal(t, "unexpected status 500", delivery.LastError)
+	assert.True(t, delivery.NextAttemptAt.Equal(clock.Add(time.Second)))
+
+	// Not yet due.
+	d.dispatchDue(context.Background())
+	assert.Len(t, rc.requests, 1)
+
+	clock = clock.Add(time.Second)
+	d.dispatchDue(context.Background())
+	delivery = getDeliveries(t, router, "1")[0]
+	assert.Equal(t, 2, delivery.Attempts)
+	assert.True(t, delivery.NextAttemptAt.Equal(clock.Add(2*time.Second)), "backoff doubles")
+
+	clock = clock.Add(2 * time.Second)
+	d.dispatchDue(context.Background())
+	delivery = getDeliveries(t, router, "1")[0]
+	assert.Equal(t, DeliveryDelivered, delivery.Status)
+	assert.Equal(t, 3, delivery.Attempts)
+	assert.Empty(t, delivery.LastError)
+}
+
+func TestDispatcher_GivesUp(t *testing.T) {
+	rc := &receiver{statuses: []int{500, 500, 500}}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	router := setupWebhookRouter(t)
+	createTestWebhook(t, router, `{"url":"`+srv.URL+`","events":["users.created"]}`)
+	createTestUser(t, router)
+
+	clock := time.Now()
+	d := testDispatcher(&clock)
+	for i := 0; i < 5; i++ {
+		d.dispatchDue(context.Background())
+		clock = clock.Add(time.Hour)
+	}
+
+	assert.Len(t, rc.requests, 3)
+	delivery := getDeliveries(t, router, "1")[0]
+	assert.Equal(t, DeliveryFailed, delivery.Status)
+	assert.Equal(t, 3, delivery.Attempts)
+}
+
+func TestDispatcher_DeletedWebhook(t *testing.T) {
+	router := setupWebhookRouter(t)
+	createTestWebhook(t, router, `{"url":"https://example.invalid/hook","events":["users.created"]}`)
+	createTestUser(t, router)
+	require.NoError(t, server.Store.DeleteWebhook(1))
+
+	clock := time.Now()
+	testDispatcher(&clock).dispatchDue(context.Background())
+
+	deliveries := server.Store.ListDeliveries(1)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, DeliveryFailed, deliveries[0].Status)
+	assert.Equal(t, "webhook deleted", deliveries[0].LastError)
+}
+
+func TestDispatcher_Backoff(t *testing.T) {
+	d := &webhookDispatcher{baseBackoff: time.Second, maxBackoff: 10 * time.Second}
+
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{50, 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, d.backoff(tt.attempts), "attempts=%d", tt.attempts)
+	}
+}